@@ -186,6 +186,10 @@ func getConfigRequiredCapabilities(cfg *tracee.Config) []cap.Value {
 	if cfg.Output.ExecHash {
 		caps = append(caps, cap.DAC_OVERRIDE)
 	}
+	if cfg.Output.ParentExecHash {
+		// reading the executable of a process of another user
+		caps = append(caps, cap.SYS_PTRACE)
+	}
 	return caps
 }
 
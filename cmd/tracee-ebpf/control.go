@@ -0,0 +1,67 @@
+//go:build control
+// +build control
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aquasecurity/tracee/cmd/tracee-ebpf/flags"
+	"github.com/aquasecurity/tracee/cmd/tracee-ebpf/internal/printer"
+	"github.com/aquasecurity/tracee/pkg/control"
+	tracee "github.com/aquasecurity/tracee/pkg/ebpf"
+	"github.com/aquasecurity/tracee/pkg/logger"
+
+	cli "github.com/urfave/cli/v2"
+)
+
+const controlSocketFlag = "control-socket"
+
+// controlTracer is the tracee controlled by the API, reloading the filters given as the values of --trace
+type controlTracer struct {
+	*tracee.Tracee
+}
+
+func (t controlTracer) ReloadFilters(traceFilters []string) error {
+	filter, err := flags.PrepareFilter(traceFilters)
+	if err != nil {
+		return err
+	}
+	return t.Tracee.ReloadFilters(filter)
+}
+
+func init() {
+	controlFlags = append(controlFlags, &cli.StringFlag{
+		Name:  controlSocketFlag,
+		Usage: "path of the unix socket serving the control API (a gRPC service querying stats, dumping the profile and pausing capture, rotating the output and reloading the filters applied in userspace at runtime). the API has no authentication, so the socket is only accessible by the user running tracee. disabled if not set",
+	})
+	startControl = func(ctx context.Context, c *cli.Context, t *tracee.Tracee, p printer.EventPrinter) error {
+		path := c.String(controlSocketFlag)
+		if path == "" {
+			return nil
+		}
+		listener, err := control.Listen(path)
+		if err != nil {
+			return fmt.Errorf("failed to listen for the control API: %v", err)
+		}
+		var tracer control.Tracer = controlTracer{t}
+		if rotator, ok := p.(printer.Rotator); ok {
+			tracer = struct {
+				controlTracer
+				printer.Rotator
+			}{controlTracer{t}, rotator}
+		}
+		server := control.NewServer(tracer)
+		go func() {
+			if err := server.Serve(listener); err != nil {
+				logger.Error("serving the control API", "error", err)
+			}
+		}()
+		go func() {
+			<-ctx.Done()
+			server.Stop()
+		}()
+		return nil
+	}
+}
@@ -4,26 +4,62 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	tracee "github.com/aquasecurity/tracee/pkg/ebpf"
 )
 
 func CaptureHelp() string {
 	return `Capture artifacts that were written, executed or found to be suspicious.
-Captured artifacts will appear in the 'output-path' directory.
+Captured artifacts will appear in the 'output-path' directory, grouped into a subdirectory per container id.
+Artifacts of processes not running in a container are grouped into 'host', or into 'mntns-<mount namespace>' for executed files of other mount namespaces.
 Possible options:
 
 [artifact:]write[=/path/prefix*]   capture written files. A filter can be given to only capture file writes whose path starts with some prefix (up to 50 characters). Up to 3 filters can be given.
 [artifact:]exec                    capture executed files.
 [artifact:]module                  capture loaded kernel modules.
+[artifact:]so                      capture shared objects loaded by processes (files mapped as executable).
+[artifact:]open=/path/to/file      capture files opened at the given path, even if they are only read (e.g. secrets and configs). Wildcards are supported as in argument filters. Can be given multiple times.
 [artifact:]mem                     capture memory regions that had write+execute (w+x) protection, and then changed to execute (x) only.
 [artifact:]net=interface           capture network traffic of the given interface. Only TCP/UDP/ICMP protocols are currently supported.
 
 dir:/path/to/dir                    path where tracee will save produced artifacts. the artifact will be saved into an 'out' subdirectory. (default: /tmp/tracee).
 profile                             creates a runtime profile of program executions and their metadata for forensics use.
 clear-dir                           clear the captured artifacts output dir before starting (default: false).
+cas                                 store captured executed files and shared objects by their sha256 (as cas/<sha256[:2]>/<sha256>), so identical files of different containers are stored once. Requires --output option:exec-hash, without which files are stored per container. Can't be used with compress.
+mirror-paths                        store captured executed, loaded and opened files under their original path in the container (e.g. host/usr/bin/ls), instead of by their timestamp. Files captured again at a taken path are suffixed by the timestamp of their event.
+compress=ALGORITHM[:LEVEL]          compress the copies of captured executed, loaded and opened files with gzip or zstd (zstd requires building with ZSTD=1), optionally at the given level (gzip: 1-9, zstd: 1-22). The extension of the algorithm is added to their names.
+manifest                            record the captured executed, loaded and opened files, with their source, inode and sha256, in manifest.jsonl in the output dir, one json per line.
+manifest-compact=DURATION           compact the capture manifest every DURATION (e.g. 1h), keeping the latest record of every captured file only. The former manifest is kept as manifest.<time>.jsonl. Implies manifest.
+persist-dedup                       remember the files captured and hashed across restarts (in the output dir), so they aren't captured or hashed again until modified.
 pcap:[per-container|per-process]    capture separate pcap file based on container/process context (default: none - saving one pcap for the entire host).
+pcap-rotate=N                       also save the captured network traffic to libpcap files named by the time of their first packet, starting a new file every N megabytes.
+exclude-comm=comm                   don't capture or hash executed files, or capture shared objects, of processes with the given name. Wildcards are supported as in argument filters.
+exclude-path=/path/to/file          don't capture or hash executed files, or capture shared objects, with the given path. Wildcards are supported as in argument filters.
+parent-comm=comm                    only capture executed, loaded and opened files of processes with an ancestor of the given name (e.g. children of web servers), skipping other lineages. Wildcards are supported as in argument filters. Can be given multiple times.
+parent-path=/path/to/file           only capture executed, loaded and opened files of processes with an ancestor of the given executable path, as parent-comm.
+parent-unknown=capture|skip         capture or skip (default) the files of processes whose lineage ends at an unknown ancestor (e.g. processes started before tracing) without matching parent-comm or parent-path.
+file-type=TYPE                      only capture executed files of the given type, as told by their magic bytes: elf, script, pe or other (files of no known type). Can be given multiple times.
+dedup-inode                         capture executed files once per device and inode rather than per path, so hardlinks to the same binary aren't captured again (or hashed again, with --output option:exec-hash).
+always-capture=/path/to/file        capture executed files with the given path on every exec, even if they were already captured and weren't modified since. Wildcards are supported as in argument filters.
+write-once                          capture written files only as they are first written, skipping any later writes to them (implies write).
+write-tail=N                        add the last N bytes of written files, as they are once written, to vfs_write, vfs_writev and __kernel_write events as the tail argument.
+cmdline                             add the full command line of executed processes to sched_process_exec events, as the cmdline argument.
+hash-xattr                          store the sha256 of captured executed files and kernel modules as their 'user.tracee.sha256' extended attribute, if the output directory supports it.
+hash-mmap=N                         hash files of N megabytes or more by mapping them to memory, which is faster than reading large files (default: files are always read).
+max-open-files=N                    limit the number of files opened concurrently for capturing and hashing, to not exhaust the file descriptors (default: unlimited).
+ns-rate=N                           capture up to N executed files, shared objects and opened files per second for each mount namespace (container), skipping the captures over the rate (default: unlimited).
+ns-max-files=N                      remember up to N captured files for each mount namespace (container), forgetting the oldest ones beyond it to bound memory. forgotten files are captured again if they are seen again (default: unlimited).
+queue-depth=N                       copy captured executed, loaded and opened files by capture workers through a queue of N files, dropping the captures which can't be queued rather than holding back the events (default: files are copied as their events are processed).
+queue-workers=N                     the number of capture workers copying the queued files (default: 4).
+post-hook=COMMAND                   run COMMAND in the background on every executed, loaded and opened file copied into the output dir, e.g. to scan it (post-hook='clamscan --no-summary {path}'). {path} is replaced by the path of the copy (appended if missing), and the command isn't run by a shell. results are emitted as capture_hook events when traced.
+post-hook-timeout=DURATION          kill the hooks running for longer than DURATION (default: 30s).
+post-hook-concurrency=N             run up to N hooks at once, skipping the hooks of files captured while as many run (default: 2).
+warmup=DURATION                     skip capturing files for DURATION (e.g. 30s) after tracee starts, so capturing the files of already running processes doesn't cause a storm of I/O on startup. events are still emitted.
+min-file-age=DURATION               skip capturing executed, loaded and opened files changed within DURATION (e.g. 2s) before their event, as they may be transient. events are still emitted.
+hash-cache-stats=DURATION           log the utilization and hit ratio of the cache of executed files hashes every DURATION (e.g. 1m), for tuning its size. The stats are reported along the errors of tracee.
 
 Examples:
   --capture exec                                           | capture executed files into the default output directory
@@ -32,7 +68,13 @@ Examples:
   --capture profile                                        | capture executed files and create a runtime profile in the output directory
   --capture net=eth0                                       | capture network traffic of eth0
   --capture net=eth0 --capture pcap:per-container          | capture network traffic of eth0, and save pcap for each container
+  --capture net=eth0 --capture pcap-rotate=100             | capture network traffic of eth0, and also save it to libpcap files of 100MB
   --capture exec --output none                             | capture executed files into the default output directory not printing the stream of events
+  --capture exec --capture exclude-comm=containerd-shim*   | capture executed files, except for those executed by containerd shims
+  --capture open=/run/secrets/*                            | capture files opened under /run/secrets/, whether or not they are written
+  --capture exec --capture file-type=elf                   | capture executed ELF binaries, skipping scripts and other files
+  --capture exec --capture parent-comm=nginx               | capture files executed by children (and their descendants) of nginx
+  --capture exec --capture compress=zstd:19                | capture executed files, compressed with zstd at level 19
 
 Use this flag multiple times to choose multiple capture options
 `
@@ -46,6 +88,7 @@ func PrepareCapture(captureSlice []string) (tracee.CaptureConfig, error) {
 
 	netCapturePerContainer := false
 	netCapturePerProcess := false
+	parentUnknown := false
 
 	var filterFileWrite []string
 	for i := range captureSlice {
@@ -53,7 +96,9 @@ func PrepareCapture(captureSlice []string) (tracee.CaptureConfig, error) {
 		if strings.HasPrefix(cap, "artifact:write") ||
 			strings.HasPrefix(cap, "artifact:exec") ||
 			strings.HasPrefix(cap, "artifact:mem") ||
-			strings.HasPrefix(cap, "artifact:module") {
+			strings.HasPrefix(cap, "artifact:module") ||
+			strings.HasPrefix(cap, "artifact:so") ||
+			strings.HasPrefix(cap, "artifact:open") {
 			cap = strings.TrimPrefix(cap, "artifact:")
 		}
 		if cap == "write" {
@@ -65,8 +110,149 @@ func PrepareCapture(captureSlice []string) (tracee.CaptureConfig, error) {
 				return tracee.CaptureConfig{}, fmt.Errorf("capture write filter cannot be empty")
 			}
 			filterFileWrite = append(filterFileWrite, pathPrefix)
+		} else if cap == "write-once" {
+			capture.FileWrite = true
+			capture.FirstWriteOnly = true
+		} else if strings.HasPrefix(cap, "write-tail=") {
+			tailSize, err := strconv.Atoi(strings.TrimPrefix(cap, "write-tail="))
+			if err != nil || tailSize <= 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture write-tail size must be a positive number of bytes")
+			}
+			capture.WriteTailSize = int64(tailSize)
 		} else if cap == "exec" {
 			capture.Exec = true
+		} else if cap == "so" {
+			capture.SharedObjects = true
+		} else if strings.HasPrefix(cap, "open=") {
+			openPath := strings.TrimPrefix(cap, "open=")
+			if len(strings.Trim(openPath, "*")) == 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture open filter cannot be empty")
+			}
+			capture.FileOpenPaths = append(capture.FileOpenPaths, openPath)
+		} else if cap == "dedup-inode" {
+			capture.DedupByInode = true
+		} else if cap == "cmdline" {
+			capture.Cmdline = true
+		} else if cap == "hash-xattr" {
+			capture.HashXattr = true
+		} else if strings.HasPrefix(cap, "exclude-comm=") {
+			comm := strings.TrimPrefix(cap, "exclude-comm=")
+			if len(strings.Trim(comm, "*")) == 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture exclude-comm filter cannot be empty")
+			}
+			capture.ExcludeComms = append(capture.ExcludeComms, comm)
+		} else if strings.HasPrefix(cap, "exclude-path=") {
+			path := strings.TrimPrefix(cap, "exclude-path=")
+			if len(strings.Trim(path, "*")) == 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture exclude-path filter cannot be empty")
+			}
+			capture.ExcludePaths = append(capture.ExcludePaths, path)
+		} else if strings.HasPrefix(cap, "parent-comm=") {
+			comm := strings.TrimPrefix(cap, "parent-comm=")
+			if len(strings.Trim(comm, "*")) == 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture parent-comm filter cannot be empty")
+			}
+			capture.ParentComms = append(capture.ParentComms, comm)
+		} else if strings.HasPrefix(cap, "parent-path=") {
+			path := strings.TrimPrefix(cap, "parent-path=")
+			if len(strings.Trim(path, "*")) == 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture parent-path filter cannot be empty")
+			}
+			capture.ParentPaths = append(capture.ParentPaths, path)
+		} else if strings.HasPrefix(cap, "parent-unknown=") {
+			switch strings.TrimPrefix(cap, "parent-unknown=") {
+			case "capture":
+				capture.CaptureUnknownParents = true
+			case "skip":
+				capture.CaptureUnknownParents = false
+			default:
+				return tracee.CaptureConfig{}, fmt.Errorf("capture parent-unknown must be capture or skip")
+			}
+			parentUnknown = true
+		} else if strings.HasPrefix(cap, "file-type=") {
+			fileType := strings.TrimPrefix(cap, "file-type=")
+			if fileType != tracee.FileTypeELF && fileType != tracee.FileTypeScript && fileType != tracee.FileTypePE && fileType != tracee.FileTypeOther {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture file-type must be one of elf, script, pe or other")
+			}
+			capture.FileTypes = append(capture.FileTypes, fileType)
+		} else if strings.HasPrefix(cap, "always-capture=") {
+			path := strings.TrimPrefix(cap, "always-capture=")
+			if len(strings.Trim(path, "*")) == 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture always-capture filter cannot be empty")
+			}
+			capture.AlwaysCapturePaths = append(capture.AlwaysCapturePaths, path)
+		} else if strings.HasPrefix(cap, "max-open-files=") {
+			maxOpenFiles, err := strconv.Atoi(strings.TrimPrefix(cap, "max-open-files="))
+			if err != nil || maxOpenFiles <= 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture max-open-files must be a positive number")
+			}
+			capture.MaxOpenFiles = maxOpenFiles
+		} else if strings.HasPrefix(cap, "ns-rate=") {
+			rate, err := strconv.Atoi(strings.TrimPrefix(cap, "ns-rate="))
+			if err != nil || rate <= 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture ns-rate must be a positive number")
+			}
+			capture.NamespaceRate = rate
+		} else if strings.HasPrefix(cap, "ns-max-files=") {
+			maxFiles, err := strconv.Atoi(strings.TrimPrefix(cap, "ns-max-files="))
+			if err != nil || maxFiles <= 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture ns-max-files must be a positive number")
+			}
+			capture.NamespaceMaxFiles = maxFiles
+		} else if strings.HasPrefix(cap, "queue-depth=") {
+			depth, err := strconv.Atoi(strings.TrimPrefix(cap, "queue-depth="))
+			if err != nil || depth <= 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture queue-depth must be a positive number")
+			}
+			capture.QueueDepth = depth
+		} else if strings.HasPrefix(cap, "queue-workers=") {
+			workers, err := strconv.Atoi(strings.TrimPrefix(cap, "queue-workers="))
+			if err != nil || workers <= 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture queue-workers must be a positive number")
+			}
+			capture.QueueWorkers = workers
+		} else if strings.HasPrefix(cap, "post-hook=") {
+			command := strings.TrimPrefix(cap, "post-hook=")
+			if strings.TrimSpace(command) == "" {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture post-hook must be a command")
+			}
+			capture.PostHook = command
+		} else if strings.HasPrefix(cap, "post-hook-timeout=") {
+			timeout, err := time.ParseDuration(strings.TrimPrefix(cap, "post-hook-timeout="))
+			if err != nil || timeout <= 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture post-hook-timeout must be a positive duration")
+			}
+			capture.PostHookTimeout = timeout
+		} else if strings.HasPrefix(cap, "post-hook-concurrency=") {
+			concurrency, err := strconv.Atoi(strings.TrimPrefix(cap, "post-hook-concurrency="))
+			if err != nil || concurrency <= 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture post-hook-concurrency must be a positive number")
+			}
+			capture.PostHookConcurrency = concurrency
+		} else if strings.HasPrefix(cap, "warmup=") {
+			delay, err := time.ParseDuration(strings.TrimPrefix(cap, "warmup="))
+			if err != nil || delay <= 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture warmup must be a positive duration")
+			}
+			capture.WarmupDelay = delay
+		} else if strings.HasPrefix(cap, "min-file-age=") {
+			age, err := time.ParseDuration(strings.TrimPrefix(cap, "min-file-age="))
+			if err != nil || age <= 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture min-file-age must be a positive duration")
+			}
+			capture.MinFileAge = age
+		} else if strings.HasPrefix(cap, "hash-cache-stats=") {
+			interval, err := time.ParseDuration(strings.TrimPrefix(cap, "hash-cache-stats="))
+			if err != nil || interval <= 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture hash-cache-stats interval must be a positive duration")
+			}
+			capture.HashCacheStatsInterval = interval
+		} else if strings.HasPrefix(cap, "hash-mmap=") {
+			threshold, err := strconv.Atoi(strings.TrimPrefix(cap, "hash-mmap="))
+			if err != nil || threshold <= 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture hash-mmap threshold must be a positive number of megabytes")
+			}
+			capture.HashMmapThreshold = int64(threshold) * 1024 * 1024
 		} else if cap == "module" {
 			capture.Module = true
 		} else if cap == "mem" {
@@ -86,6 +272,40 @@ func PrepareCapture(captureSlice []string) (tracee.CaptureConfig, error) {
 			} else {
 				return tracee.CaptureConfig{}, fmt.Errorf("invalid network capture option: %s. accepted options - pcap:per-container or pcap:per-process", netCaptureContext)
 			}
+		} else if strings.HasPrefix(cap, "pcap-rotate=") {
+			rotateSize, err := strconv.Atoi(strings.TrimPrefix(cap, "pcap-rotate="))
+			if err != nil || rotateSize <= 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture pcap-rotate size must be a positive number of megabytes")
+			}
+			capture.NetPcapRotateSize = int64(rotateSize) * 1024 * 1024
+		} else if cap == "cas" {
+			capture.ContentAddressed = true
+		} else if cap == "mirror-paths" {
+			capture.MirrorPaths = true
+		} else if strings.HasPrefix(cap, "compress=") {
+			compression := strings.SplitN(strings.TrimPrefix(cap, "compress="), ":", 2)
+			if compression[0] != tracee.CaptureCompressionGzip && compression[0] != tracee.CaptureCompressionZstd {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture compress algorithm must be gzip or zstd")
+			}
+			capture.Compression = compression[0]
+			if len(compression) == 2 {
+				level, err := strconv.Atoi(compression[1])
+				if err != nil || level <= 0 {
+					return tracee.CaptureConfig{}, fmt.Errorf("capture compress level must be a positive number")
+				}
+				capture.CompressionLevel = level
+			}
+		} else if cap == "manifest" {
+			capture.Manifest = true
+		} else if strings.HasPrefix(cap, "manifest-compact=") {
+			interval, err := time.ParseDuration(strings.TrimPrefix(cap, "manifest-compact="))
+			if err != nil || interval <= 0 {
+				return tracee.CaptureConfig{}, fmt.Errorf("capture manifest-compact must be a positive duration")
+			}
+			capture.Manifest = true
+			capture.ManifestCompactInterval = interval
+		} else if cap == "persist-dedup" {
+			capture.PersistDedup = true
 		} else if cap == "clear-dir" {
 			clearDir = true
 		} else if strings.HasPrefix(cap, "dir:") {
@@ -113,5 +333,18 @@ func PrepareCapture(captureSlice []string) (tracee.CaptureConfig, error) {
 	capture.NetPerContainer = netCapturePerContainer
 	capture.NetPerProcess = netCapturePerProcess
 
+	if capture.QueueWorkers > 0 && capture.QueueDepth == 0 {
+		return tracee.CaptureConfig{}, fmt.Errorf("invalid capture flags: queue-workers requires queue-depth")
+	}
+	if (capture.PostHookTimeout > 0 || capture.PostHookConcurrency > 0) && capture.PostHook == "" {
+		return tracee.CaptureConfig{}, fmt.Errorf("invalid capture flags: post-hook-timeout and post-hook-concurrency require post-hook")
+	}
+	if capture.ContentAddressed && capture.Compression != "" {
+		return tracee.CaptureConfig{}, fmt.Errorf("invalid capture flags: files stored by their content (cas) aren't compressed, so cas can't be used with compress")
+	}
+	if parentUnknown && len(capture.ParentComms) == 0 && len(capture.ParentPaths) == 0 {
+		return tracee.CaptureConfig{}, fmt.Errorf("invalid capture flags: parent-unknown requires parent-comm or parent-path")
+	}
+
 	return capture, nil
 }
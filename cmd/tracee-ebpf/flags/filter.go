@@ -18,7 +18,7 @@ Only events that match all trace expressions will be traced (trace flags are AND
 The following types of expressions are supported:
 
 Numerical expressions which compare numbers and allow the following operators: '=', '!=', '<', '>'.
-Available numerical expressions: uid, pid, mntns, pidns.
+Available numerical expressions: uid, gid, pid, mntns, pidns.
 NOTE: Expressions containing '<' or '>' token must be escaped! This is also shown in the examples below.
 
 String expressions which compares text and allow the following operators: '=', '!='.
@@ -30,19 +30,48 @@ Available boolean expressions: container.
 Event arguments can be accessed using 'event_name.event_arg' and provide a way to filter an event by its arguments.
 Event arguments allow the following operators: '=', '!='.
 Strings can be compared as a prefix if ending with '*' or as suffix if starting with '*'.
+The values to equal can be read from a file, one per line, using '=@/path/to/file'. The file is read again once it's modified.
+Arguments added by tracee while processing an event (e.g. 'sched_process_exec.sha256' with '--output option:exec-hash') can be filtered as well.
 
 Event return value can be accessed using 'event_name.retval' and provide a way to filter an event by its return value.
 Event return value expression has the same syntax as a numerical expression.
 
+The number of arguments an event was submitted with can be accessed using 'event_name.argnum', and provides a way to
+drop events whose arguments were truncated. Argnum expressions set a minimum, and allow the operators '>' and '>='.
+
+The binary executed by the process of an event can be accessed using 'event_name.binary', and provides a way to
+trace an event only for processes executing one of the given binaries (by their absolute path, limited to 127 characters).
+Binary expressions allow the operator '=' only, and are filtered by the kernel, so high-volume events of other processes
+are dropped before being submitted.
+
+The field 'time' selects events by their wall clock time, to only trace events within a window (e.g. of an incident).
+Time expressions allow the operators '>', '>=', '<' and '<=', with a time in RFC3339 format. Either bound can be omitted.
+
+The field 'expr' filters events by a Common Expression Language (CEL) expression, for conditions the other fields
+can't express. Expressions access the event context using 'event' (e.g. 'event.pid', 'event.comm', 'event.eventName' and
+'event.retval'), and the event arguments using 'args' (e.g. 'args.pathname'). Multiple expressions are ANDed.
+
 Non-boolean expressions can compare a field to multiple values separated by ','.
 Multiple values are ORed if used with equals operator '=', but are ANDed if used with any other operator.
 
 The field 'container' and 'pid' also support the special value 'new' which selects new containers or pids, respectively.
 
+The field 'gid' selects events by the group id of their process. Unlike the other fields, it is only filtered after
+events are submitted by the kernel, so it doesn't reduce their submission overhead.
+
 The field 'set' selects a set of events to trace according to predefined sets, which can be listed by using the 'list' flag.
 
 The special 'follow' expression declares that not only processes that match the criteria will be traced, but also their descendants.
 
+The special 'first' expression only traces the first event of every event type of a process, dropping its repeats, for a
+summary of what each process did. Processes are forgotten once they exit, so a process reusing their pid is traced as well.
+
+The field 'quiet-exec' selects the binaries whose exec events (sched_process_exec, execve and execveat) are dropped, as system
+daemons (e.g. systemd and cron) execute routinely. Common daemons of the host are quiet by default, while the binaries at
+their paths in containers are traced, and more binaries can be made quiet in all containers and the host ('='), or traced
+again ('!='), by their absolute path, where a trailing '*' matches the paths prefixed by the rest.
+The special '!quiet-exec' expression traces the exec events of the default quiet daemons, keeping the binaries made quiet only.
+
 The field 'net' specifies which interfaces to monitor when tracing network events.
 Notice that the 'net' field is mandatory when tracing network events.
 
@@ -56,6 +85,7 @@ Examples:
   --trace c                                                    | only trace events from containers (same as above)
   --trace '!container'                                         | only trace events from the host
   --trace uid=0                                                | only trace events from uid 0
+  --trace gid!=998                                             | don't trace events from gid 998
   --trace mntns=4026531840                                     | only trace events from mntns id 4026531840
   --trace pidns!=4026531836                                    | only trace events from pidns id not equal to 4026531840
   --trace tree=476165                                          | only trace events that descend from the process with pid 476165
@@ -74,7 +104,19 @@ Examples:
   --trace close.fd=5                                           | only trace 'close' events that have 'fd' equals 5
   --trace openat.pathname=/tmp*                                | only trace 'openat' events that have 'pathname' prefixed by "/tmp"
   --trace openat.pathname!=/tmp/1,/bin/ls                      | don't trace 'openat' events that have 'pathname' equals /tmp/1 or /bin/ls
+  --trace execve.pathname=@/etc/tracee/allowed-binaries        | only trace 'execve' events of the binaries listed in the given file, read again once it's modified
+  --trace execve.argv=--insecure,-k                            | only trace 'execve' events with an element of 'argv' equal to --insecure or -k
+  --trace sched_process_exec.sha256!=<hash>                    | don't trace 'sched_process_exec' events of a binary with the given sha256 (requires exec-hash)
+  --trace 'openat.argnum>=4'                                   | don't trace 'openat' events submitted with less than 4 arguments
+  --trace openat.binary=/usr/sbin/nginx,/usr/bin/curl          | only trace 'openat' events of processes executing /usr/sbin/nginx or /usr/bin/curl
+  --trace 'time>=2022-08-26T10:00:00Z'                         | only trace events from 10:00 UTC of August 26th, 2022
+  --trace 'time<2022-08-26T10:30:00Z'                          | only trace events before 10:30 UTC (a window, when given with the above)
+  --trace 'expr=args.pathname.startsWith("/etc")'              | only trace events that have 'pathname' prefixed by "/etc"
   --trace comm=bash --trace follow                             | trace all events that originated from bash or from one of the processes spawned by bash
+  --trace first --trace set=fs                                 | only trace the first of each file-system related event of every process
+  --trace quiet-exec=/usr/local/bin/agentd                     | don't trace the exec events of /usr/local/bin/agentd, along the quiet system daemons
+  --trace quiet-exec!=/usr/sbin/cron                           | trace the exec events of cron, although it's a quiet system daemon
+  --trace '!quiet-exec'                                        | trace the exec events of all binaries, including system daemons
   --trace net=docker0 			                       | trace the net events over docker0 interface
 
 
@@ -92,6 +134,13 @@ func PrepareFilter(filtersArr []string) (tracee.Filter, error) {
 			Greater:  filters.GreaterNotSetUint,
 			Is32Bit:  true,
 		},
+		GIDFilter: &filters.UIntFilter{
+			Equal:    []uint64{},
+			NotEqual: []uint64{},
+			Less:     filters.LessNotSetUint,
+			Greater:  filters.GreaterNotSetUint,
+			Is32Bit:  true,
+		},
 		PIDFilter: &filters.UIntFilter{
 			Equal:    []uint64{},
 			NotEqual: []uint64{},
@@ -131,9 +180,19 @@ func PrepareFilter(filtersArr []string) (tracee.Filter, error) {
 		RetFilter: &filters.RetFilter{
 			Filters: make(map[events.ID]filters.IntFilter),
 		},
+		ArgnumFilter: &filters.ArgnumFilter{
+			Filters: make(map[events.ID]uint8),
+		},
 		ArgFilter: &filters.ArgFilter{
 			Filters: make(map[events.ID]map[string]filters.ArgFilterVal),
 		},
+		BinaryFilter: &filters.BinaryFilter{
+			Filters: make(map[events.ID][]string),
+		},
+		ExprFilter:       &filters.ExprFilter{},
+		TimeFilter:       &filters.TimeFilter{},
+		FirstEventFilter: &filters.FirstEventFilter{},
+		QuietExecFilter:  filters.NewQuietExecFilter(),
 		ProcessTreeFilter: &filters.ProcessTreeFilter{
 			PIDs: make(map[uint32]bool),
 		},
@@ -144,6 +203,7 @@ func PrepareFilter(filtersArr []string) (tracee.Filter, error) {
 	}
 
 	eventFilter := &filters.StringFilter{Equal: []string{}, NotEqual: []string{}}
+	noDefaultQuietExec := false
 	setFilter := &filters.StringFilter{Equal: []string{}, NotEqual: []string{}}
 
 	eventsNameToID := events.Definitions.NamesToIDs()
@@ -155,6 +215,15 @@ func PrepareFilter(filtersArr []string) (tracee.Filter, error) {
 	}
 
 	for _, f := range filtersArr {
+		// expressions contain operators of their own, so they are taken as is
+		if strings.HasPrefix(f, "expr=") {
+			err := filter.ExprFilter.Parse(strings.TrimPrefix(f, "expr="))
+			if err != nil {
+				return tracee.Filter{}, err
+			}
+			continue
+		}
+
 		filterName := f
 		operatorAndValues := ""
 		operatorIndex := strings.IndexAny(f, "=!<>")
@@ -171,7 +240,25 @@ func PrepareFilter(filtersArr []string) (tracee.Filter, error) {
 			continue
 		}
 
-		if strings.Contains(f, ".") {
+		if strings.Contains(f, ".argnum") {
+			err := filter.ArgnumFilter.Parse(filterName, operatorAndValues, eventsNameToID)
+			if err != nil {
+				return tracee.Filter{}, err
+			}
+			continue
+		}
+
+		// the names of the binary and argument filters are checked without their values, which may have dots of their
+		// own (e.g. comm=foo.binary)
+		if strings.HasSuffix(filterName, ".binary") {
+			err := filter.BinaryFilter.Parse(filterName, operatorAndValues, eventsNameToID)
+			if err != nil {
+				return tracee.Filter{}, err
+			}
+			continue
+		}
+
+		if strings.Contains(filterName, ".") {
 			err := filter.ArgFilter.Parse(filterName, operatorAndValues, eventsNameToID)
 			if err != nil {
 				return tracee.Filter{}, err
@@ -250,6 +337,14 @@ func PrepareFilter(filtersArr []string) (tracee.Filter, error) {
 			continue
 		}
 
+		if filterName == "time" {
+			err := filter.TimeFilter.Parse(operatorAndValues)
+			if err != nil {
+				return tracee.Filter{}, err
+			}
+			continue
+		}
+
 		if filterName == "tree" {
 			err := filter.ProcessTreeFilter.Parse(operatorAndValues)
 			if err != nil {
@@ -292,6 +387,14 @@ func PrepareFilter(filtersArr []string) (tracee.Filter, error) {
 			continue
 		}
 
+		if filterName == "gid" {
+			err := filter.GIDFilter.Parse(operatorAndValues)
+			if err != nil {
+				return tracee.Filter{}, err
+			}
+			continue
+		}
+
 		if strings.HasPrefix("uid", filterName) {
 			err := filter.UIDFilter.Parse(operatorAndValues)
 			if err != nil {
@@ -300,12 +403,34 @@ func PrepareFilter(filtersArr []string) (tracee.Filter, error) {
 			continue
 		}
 
+		if filterName == "quiet-exec" {
+			err := filter.QuietExecFilter.Parse(operatorAndValues)
+			if err != nil {
+				return tracee.Filter{}, err
+			}
+			continue
+		}
+
+		if f == "!quiet-exec" {
+			noDefaultQuietExec = true
+			continue
+		}
+
+		if f == "first" {
+			filter.FirstEventFilter.Enabled = true
+			continue
+		}
+
 		if strings.HasPrefix("follow", f) {
 			filter.Follow = true
 			continue
 		}
 		return tracee.Filter{}, fmt.Errorf("invalid filter option specified, use '--trace help' for more info")
 	}
+	// the default quiet binaries are dropped whether given before or after the ones added
+	if noDefaultQuietExec {
+		filter.QuietExecFilter.RemoveDefaults()
+	}
 
 	var err error
 	filter.EventsToTrace, err = prepareEventsToTrace(eventFilter, setFilter, eventsNameToID)
@@ -4,13 +4,18 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aquasecurity/tracee/cmd/tracee-ebpf/flags"
+	"github.com/aquasecurity/tracee/cmd/tracee-ebpf/internal/printer"
 	tracee "github.com/aquasecurity/tracee/pkg/ebpf"
 	"github.com/aquasecurity/tracee/pkg/events"
 	"github.com/aquasecurity/tracee/pkg/events/queue"
 	"github.com/aquasecurity/tracee/pkg/filters"
+	"github.com/aquasecurity/tracee/types/trace"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -198,6 +203,36 @@ func TestPrepareFilter(t *testing.T) {
 			expectedFilter: tracee.Filter{},
 			expectedError:  errors.New("invalid retval filter format open.retvall"),
 		},
+		{
+			testName:       "invalid argnum filter event name",
+			filters:        []string{"notanevent.argnum>=2"},
+			expectedFilter: tracee.Filter{},
+			expectedError:  errors.New("invalid argnum filter event name: notanevent"),
+		},
+		{
+			testName:       "invalid argnum filter operator",
+			filters:        []string{"openat.argnum=2"},
+			expectedFilter: tracee.Filter{},
+			expectedError:  errors.New("invalid argnum filter operator openat.argnum=2, only '>' and '>=' are supported"),
+		},
+		{
+			testName:       "invalid argnum filter value",
+			filters:        []string{"openat.argnum>=300"},
+			expectedFilter: tracee.Filter{},
+			expectedError:  errors.New("invalid argnum filter value: 300"),
+		},
+		{
+			testName:       "invalid binary filter operator",
+			filters:        []string{"openat.binary!=/usr/bin/ls"},
+			expectedFilter: tracee.Filter{},
+			expectedError:  errors.New("invalid binary filter operator openat.binary!=/usr/bin/ls, only '=' is supported"),
+		},
+		{
+			testName:       "invalid binary filter value",
+			filters:        []string{"openat.binary=ls"},
+			expectedFilter: tracee.Filter{},
+			expectedError:  errors.New("invalid binary filter value: ls, binaries are given by their absolute path"),
+		},
 		{
 			testName:       "invalid wildcard",
 			filters:        []string{"event=blah*"},
@@ -883,6 +918,65 @@ func TestPrepareFilter(t *testing.T) {
 			},
 			expectedError: nil,
 		},
+		{
+			testName: "argnum filter",
+			filters:  []string{"openat.argnum>=4", "close.argnum>0"},
+			expectedFilter: tracee.Filter{
+				UIDFilter: &filters.UIntFilter{
+					Equal:    []uint64{},
+					NotEqual: []uint64{},
+					Less:     filters.LessNotSetUint,
+					Greater:  filters.GreaterNotSetUint,
+					Is32Bit:  true,
+				},
+				PIDFilter: &filters.UIntFilter{
+					Equal:    []uint64{},
+					NotEqual: []uint64{},
+					Less:     filters.LessNotSetUint,
+					Greater:  filters.GreaterNotSetUint,
+					Is32Bit:  true,
+				},
+				NewPidFilter: &filters.BoolFilter{},
+				MntNSFilter: &filters.UIntFilter{
+					Equal:    []uint64{},
+					NotEqual: []uint64{},
+					Less:     filters.LessNotSetUint,
+					Greater:  filters.GreaterNotSetUint,
+				},
+				PidNSFilter: &filters.UIntFilter{
+					Equal:    []uint64{},
+					NotEqual: []uint64{},
+					Less:     filters.LessNotSetUint,
+					Greater:  filters.GreaterNotSetUint,
+				},
+				CommFilter: &filters.StringFilter{
+					Equal:    []string{},
+					NotEqual: []string{},
+					Size:     flags.MaxBpfStrFilterSize,
+				},
+				UTSFilter: &filters.StringFilter{
+					Equal:    []string{},
+					NotEqual: []string{},
+					Size:     flags.MaxBpfStrFilterSize,
+				},
+				ContFilter:    &filters.BoolFilter{},
+				NewContFilter: &filters.BoolFilter{},
+				ArgFilter: &filters.ArgFilter{
+					Filters: map[events.ID]map[string]filters.ArgFilterVal{},
+				},
+				RetFilter: &filters.RetFilter{
+					Filters: map[events.ID]filters.IntFilter{},
+				},
+				ArgnumFilter: &filters.ArgnumFilter{
+					Filters: map[events.ID]uint8{
+						events.Openat: 4,
+						events.Close:  1,
+					},
+					Enabled: true,
+				},
+			},
+			expectedError: nil,
+		},
 		{
 			testName: "wildcard filter",
 			filters:  []string{"event=open*"},
@@ -1074,11 +1168,192 @@ func TestPrepareFilter(t *testing.T) {
 			assert.Equal(t, testcase.expectedFilter.NewContFilter, filter.NewContFilter)
 			assert.Equal(t, testcase.expectedFilter.ArgFilter, filter.ArgFilter)
 			assert.Equal(t, testcase.expectedFilter.RetFilter, filter.RetFilter)
+			if testcase.expectedFilter.ArgnumFilter != nil {
+				assert.Equal(t, testcase.expectedFilter.ArgnumFilter, filter.ArgnumFilter)
+			}
 			assert.Equal(t, testcase.expectedError, err)
 		})
 	}
 }
 
+func TestPrepareFilterBinary(t *testing.T) {
+	filter, err := flags.PrepareFilter([]string{"openat.binary=/usr/sbin/nginx,/usr/bin/curl", "close.binary=/usr/sbin/nginx"})
+	require.NoError(t, err)
+	assert.Equal(t, &filters.BinaryFilter{
+		Filters: map[events.ID][]string{
+			events.Openat: {"/usr/sbin/nginx", "/usr/bin/curl"},
+			events.Close:  {"/usr/sbin/nginx"},
+		},
+		Enabled: true,
+	}, filter.BinaryFilter)
+
+	_, err = flags.PrepareFilter([]string{"openat.binary=/" + strings.Repeat("a", filters.MaxBinaryPathSize)})
+	assert.EqualError(t, err, fmt.Sprintf("invalid binary filter value: /%s, paths are limited to 127 characters", strings.Repeat("a", filters.MaxBinaryPathSize)))
+
+	// values ending like a binary filter are values of other filters
+	filter, err = flags.PrepareFilter([]string{"comm=foo.binary"})
+	require.NoError(t, err)
+	assert.False(t, filter.BinaryFilter.Enabled)
+	assert.Equal(t, []string{"foo.binary"}, filter.CommFilter.Equal)
+}
+
+func TestPrepareFilterTime(t *testing.T) {
+	start := time.Date(2022, 8, 26, 10, 0, 0, 0, time.UTC).UnixNano()
+	end := time.Date(2022, 8, 26, 10, 30, 0, 0, time.UTC).UnixNano()
+
+	testCases := []struct {
+		testName       string
+		filters        []string
+		expectedFilter *filters.TimeFilter
+		expectedError  string
+	}{
+		{
+			testName:       "window",
+			filters:        []string{"time>=2022-08-26T10:00:00Z", "time<=2022-08-26T10:30:00Z"},
+			expectedFilter: &filters.TimeFilter{Start: start, End: end, Enabled: true},
+		},
+		{
+			testName:       "exclusive bounds",
+			filters:        []string{"time>2022-08-26T10:00:00Z", "time<2022-08-26T10:30:00Z"},
+			expectedFilter: &filters.TimeFilter{Start: start + 1, End: end - 1, Enabled: true},
+		},
+		{
+			testName:       "only start",
+			filters:        []string{"time>=2022-08-26T12:00:00+02:00"},
+			expectedFilter: &filters.TimeFilter{Start: start, Enabled: true},
+		},
+		{
+			testName:       "only end",
+			filters:        []string{"time<=2022-08-26T10:30:00Z"},
+			expectedFilter: &filters.TimeFilter{End: end, Enabled: true},
+		},
+		{
+			testName:      "invalid operator",
+			filters:       []string{"time=2022-08-26T10:00:00Z"},
+			expectedError: "invalid operator given to time filter: =2022-08-26T10:00:00Z. accepted operators - '>', '>=', '<' and '<='",
+		},
+		{
+			testName:      "invalid time",
+			filters:       []string{"time>=yesterday"},
+			expectedError: "invalid time given to time filter: yesterday. expected a time in RFC3339 format (e.g. 2022-08-26T10:00:00Z)",
+		},
+		{
+			testName:      "empty window",
+			filters:       []string{"time>=2022-08-26T10:30:00Z", "time<=2022-08-26T10:00:00Z"},
+			expectedError: "invalid time filter: the window starts after it ends",
+		},
+	}
+	for _, testcase := range testCases {
+		t.Run(testcase.testName, func(t *testing.T) {
+			filter, err := flags.PrepareFilter(testcase.filters)
+			if testcase.expectedError != "" {
+				assert.EqualError(t, err, testcase.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testcase.expectedFilter, filter.TimeFilter)
+		})
+	}
+}
+
+func TestPrepareFilterExpression(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		filters       []string
+		expectedError string
+	}{
+		{
+			testName: "expression",
+			filters:  []string{`expr=event.retval < 0 && args.pathname.startsWith("/etc")`},
+		},
+		{
+			testName: "multiple expressions",
+			filters:  []string{"expr=event.uid == 0", "expr=event.pid != 1", "pid>10"},
+		},
+		{
+			testName:      "invalid expression",
+			filters:       []string{"expr=event.retval <"},
+			expectedError: "invalid filter expression event.retval <",
+		},
+		{
+			testName:      "non boolean expression",
+			filters:       []string{"expr=event.retval + 1"},
+			expectedError: "invalid filter expression event.retval + 1: expected a boolean expression",
+		},
+		{
+			testName:      "unknown variable",
+			filters:       []string{"expr=ctx.retval < 0"},
+			expectedError: "invalid filter expression ctx.retval < 0",
+		},
+	}
+	for _, testcase := range testCases {
+		t.Run(testcase.testName, func(t *testing.T) {
+			filter, err := flags.PrepareFilter(testcase.filters)
+			if testcase.expectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testcase.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, filter.ExprFilter.Enabled)
+		})
+	}
+}
+
+func TestPrepareFilterFirst(t *testing.T) {
+	filter, err := flags.PrepareFilter([]string{"first", "comm=bash"})
+	require.NoError(t, err)
+	assert.True(t, filter.FirstEventFilter.Enabled)
+	assert.False(t, filter.Follow)
+
+	filter, err = flags.PrepareFilter([]string{"comm=bash"})
+	require.NoError(t, err)
+	assert.False(t, filter.FirstEventFilter.Enabled)
+}
+
+func TestPrepareFilterQuietExec(t *testing.T) {
+	filter, err := flags.PrepareFilter([]string{"comm=bash"})
+	require.NoError(t, err)
+	assert.Equal(t, filters.NewQuietExecFilter(), filter.QuietExecFilter)
+
+	filter, err = flags.PrepareFilter([]string{"quiet-exec=/opt/agent/bin/agentd", "quiet-exec!=/usr/sbin/cron"})
+	require.NoError(t, err)
+	assert.Equal(t, filters.DefaultQuietExecPaths, filter.QuietExecFilter.Defaults)
+	assert.Equal(t, []string{"/opt/agent/bin/agentd"}, filter.QuietExecFilter.Paths)
+	assert.Equal(t, []string{"/usr/sbin/cron"}, filter.QuietExecFilter.Allowed)
+
+	// the defaults are removed whether given before or after the binaries added
+	for _, filtersArr := range [][]string{
+		{"!quiet-exec", "quiet-exec=/opt/agent/bin/agentd"},
+		{"quiet-exec=/opt/agent/bin/agentd", "!quiet-exec"},
+	} {
+		filter, err = flags.PrepareFilter(filtersArr)
+		require.NoError(t, err)
+		assert.Empty(t, filter.QuietExecFilter.Defaults)
+		assert.Equal(t, []string{"/opt/agent/bin/agentd"}, filter.QuietExecFilter.Paths)
+	}
+
+	_, err = flags.PrepareFilter([]string{"quiet-exec=agentd"})
+	assert.EqualError(t, err, "invalid filter value: agentd")
+}
+
+func TestPrepareFilterGID(t *testing.T) {
+	filter, err := flags.PrepareFilter([]string{"gid=0,1000", "u!=0"})
+	require.NoError(t, err)
+	assert.Equal(t, &filters.UIntFilter{
+		Equal:    []uint64{0, 1000},
+		NotEqual: []uint64{},
+		Less:     filters.LessNotSetUint,
+		Greater:  filters.GreaterNotSetUint,
+		Is32Bit:  true,
+		Enabled:  true,
+	}, filter.GIDFilter)
+	assert.Equal(t, []uint64{0}, filter.UIDFilter.NotEqual)
+
+	_, err = flags.PrepareFilter([]string{"gid=root"})
+	assert.EqualError(t, err, "invalid filter value: root")
+}
+
 func TestPrepareCapture(t *testing.T) {
 	t.Run("various capture options", func(t *testing.T) {
 		testCases := []struct {
@@ -1168,65 +1443,483 @@ func TestPrepareCapture(t *testing.T) {
 				expectedError: nil,
 			},
 			{
-				testName:     "multiple capture options",
-				captureSlice: []string{"write", "exec", "mem", "module"},
+				testName:      "empty capture exclude-comm filter",
+				captureSlice:  []string{"exclude-comm=*"},
+				expectedError: errors.New("capture exclude-comm filter cannot be empty"),
+			},
+			{
+				testName:      "empty capture exclude-path filter",
+				captureSlice:  []string{"exclude-path="},
+				expectedError: errors.New("capture exclude-path filter cannot be empty"),
+			},
+			{
+				testName:     "capture exec with exclusions",
+				captureSlice: []string{"exec", "exclude-comm=containerd-shim*", "exclude-comm=runc", "exclude-path=/usr/sbin/*"},
 				expectedCapture: tracee.CaptureConfig{
-					OutputPath: "/tmp/tracee/out",
-					FileWrite:  true,
-					Mem:        true,
-					Exec:       true,
-					Module:     true,
+					OutputPath:   "/tmp/tracee/out",
+					Exec:         true,
+					ExcludeComms: []string{"containerd-shim*", "runc"},
+					ExcludePaths: []string{"/usr/sbin/*"},
 				},
 				expectedError: nil,
 			},
 			{
-				testName:     "capture exec and enable profile",
-				captureSlice: []string{"exec", "profile"},
+				testName:     "capture compress",
+				captureSlice: []string{"exec", "compress=gzip"},
 				expectedCapture: tracee.CaptureConfig{
-					OutputPath: "/tmp/tracee/out",
-					Exec:       true,
-					Profile:    true,
+					OutputPath:  "/tmp/tracee/out",
+					Exec:        true,
+					Compression: "gzip",
 				},
 				expectedError: nil,
 			},
 			{
-				testName:     "just enable profile",
-				captureSlice: []string{"profile"},
+				testName:     "capture compress with level",
+				captureSlice: []string{"exec", "compress=zstd:19"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:       "/tmp/tracee/out",
+					Exec:             true,
+					Compression:      "zstd",
+					CompressionLevel: 19,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:      "invalid capture compress algorithm",
+				captureSlice:  []string{"compress=lz4"},
+				expectedError: errors.New("capture compress algorithm must be gzip or zstd"),
+			},
+			{
+				testName:      "invalid capture compress level",
+				captureSlice:  []string{"compress=gzip:best"},
+				expectedError: errors.New("capture compress level must be a positive number"),
+			},
+			{
+				testName:     "capture queue",
+				captureSlice: []string{"exec", "queue-depth=1000", "queue-workers=8"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:   "/tmp/tracee/out",
+					Exec:         true,
+					QueueDepth:   1000,
+					QueueWorkers: 8,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:      "invalid capture queue depth",
+				captureSlice:  []string{"queue-depth=0"},
+				expectedError: errors.New("capture queue-depth must be a positive number"),
+			},
+			{
+				testName:      "capture queue workers without a queue",
+				captureSlice:  []string{"exec", "queue-workers=8"},
+				expectedError: errors.New("invalid capture flags: queue-workers requires queue-depth"),
+			},
+			{
+				testName:     "capture post hook",
+				captureSlice: []string{"exec", "post-hook=clamscan --no-summary {path}", "post-hook-timeout=1m", "post-hook-concurrency=4"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:          "/tmp/tracee/out",
+					Exec:                true,
+					PostHook:            "clamscan --no-summary {path}",
+					PostHookTimeout:     time.Minute,
+					PostHookConcurrency: 4,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:      "invalid capture post hook",
+				captureSlice:  []string{"exec", "post-hook= "},
+				expectedError: errors.New("capture post-hook must be a command"),
+			},
+			{
+				testName:      "invalid capture post hook timeout",
+				captureSlice:  []string{"exec", "post-hook=scan", "post-hook-timeout=0s"},
+				expectedError: errors.New("capture post-hook-timeout must be a positive duration"),
+			},
+			{
+				testName:      "capture post hook concurrency without a hook",
+				captureSlice:  []string{"exec", "post-hook-concurrency=4"},
+				expectedError: errors.New("invalid capture flags: post-hook-timeout and post-hook-concurrency require post-hook"),
+			},
+			{
+				testName:      "capture content addressed and compressed",
+				captureSlice:  []string{"exec", "cas", "compress=gzip"},
+				expectedError: errors.New("invalid capture flags: files stored by their content (cas) aren't compressed, so cas can't be used with compress"),
+			},
+			{
+				testName:     "capture file types",
+				captureSlice: []string{"exec", "file-type=elf", "file-type=script"},
 				expectedCapture: tracee.CaptureConfig{
 					OutputPath: "/tmp/tracee/out",
 					Exec:       true,
-					Profile:    true,
+					FileTypes:  []string{"elf", "script"},
 				},
 				expectedError: nil,
 			},
 			{
-				testName:     "network interface",
-				captureSlice: []string{"net=lo"},
+				testName:      "invalid capture file type",
+				captureSlice:  []string{"file-type=jar"},
+				expectedError: errors.New("capture file-type must be one of elf, script, pe or other"),
+			},
+			{
+				testName:     "capture by parent lineage",
+				captureSlice: []string{"exec", "parent-comm=nginx", "parent-comm=httpd*", "parent-path=/usr/sbin/*", "parent-unknown=capture"},
 				expectedCapture: tracee.CaptureConfig{
-					OutputPath: "/tmp/tracee/out",
-					NetIfaces: &tracee.NetIfaces{
-						Ifaces: []string{"lo"},
-					},
+					OutputPath:            "/tmp/tracee/out",
+					Exec:                  true,
+					ParentComms:           []string{"nginx", "httpd*"},
+					ParentPaths:           []string{"/usr/sbin/*"},
+					CaptureUnknownParents: true,
 				},
+				expectedError: nil,
 			},
 			{
-				testName:     "network interface, but repeated",
-				captureSlice: []string{"net=lo", "net=lo"},
+				testName:      "empty capture parent-comm filter",
+				captureSlice:  []string{"parent-comm=*"},
+				expectedError: errors.New("capture parent-comm filter cannot be empty"),
+			},
+			{
+				testName:      "empty capture parent-path filter",
+				captureSlice:  []string{"parent-path="},
+				expectedError: errors.New("capture parent-path filter cannot be empty"),
+			},
+			{
+				testName:      "invalid capture parent-unknown",
+				captureSlice:  []string{"parent-comm=nginx", "parent-unknown=always"},
+				expectedError: errors.New("capture parent-unknown must be capture or skip"),
+			},
+			{
+				testName:      "capture parent-unknown without parent filters",
+				captureSlice:  []string{"exec", "parent-unknown=capture"},
+				expectedError: errors.New("invalid capture flags: parent-unknown requires parent-comm or parent-path"),
+			},
+			{
+				testName:      "empty capture always-capture filter",
+				captureSlice:  []string{"always-capture=*"},
+				expectedError: errors.New("capture always-capture filter cannot be empty"),
+			},
+			{
+				testName:     "capture exec always capturing paths",
+				captureSlice: []string{"exec", "always-capture=/usr/bin/sudo", "always-capture=/opt/agent/*"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:         "/tmp/tracee/out",
+					Exec:               true,
+					AlwaysCapturePaths: []string{"/usr/bin/sudo", "/opt/agent/*"},
+				},
+				expectedError: nil,
+			},
+			{
+				testName:     "capture write-once",
+				captureSlice: []string{"write-once"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:     "/tmp/tracee/out",
+					FileWrite:      true,
+					FirstWriteOnly: true,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:     "capture hash-mmap",
+				captureSlice: []string{"hash-mmap=100"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:        "/tmp/tracee/out",
+					HashMmapThreshold: 100 * 1024 * 1024,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:      "invalid capture hash-mmap",
+				captureSlice:  []string{"hash-mmap=0"},
+				expectedError: errors.New("capture hash-mmap threshold must be a positive number of megabytes"),
+			},
+			{
+				testName:     "capture write-tail",
+				captureSlice: []string{"write-tail=4096"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:    "/tmp/tracee/out",
+					WriteTailSize: 4096,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:      "invalid capture write-tail",
+				captureSlice:  []string{"write-tail=0"},
+				expectedError: errors.New("capture write-tail size must be a positive number of bytes"),
+			},
+			{
+				testName:     "capture hash-cache-stats",
+				captureSlice: []string{"hash-cache-stats=1m"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:             "/tmp/tracee/out",
+					HashCacheStatsInterval: time.Minute,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:      "invalid capture hash-cache-stats",
+				captureSlice:  []string{"hash-cache-stats=never"},
+				expectedError: errors.New("capture hash-cache-stats interval must be a positive duration"),
+			},
+			{
+				testName:     "capture dedup-inode",
+				captureSlice: []string{"exec", "dedup-inode"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:   "/tmp/tracee/out",
+					Exec:         true,
+					DedupByInode: true,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:     "capture manifest",
+				captureSlice: []string{"exec", "manifest"},
 				expectedCapture: tracee.CaptureConfig{
 					OutputPath: "/tmp/tracee/out",
-					NetIfaces: &tracee.NetIfaces{
-						Ifaces: []string{"lo"},
-					},
+					Exec:       true,
+					Manifest:   true,
 				},
+				expectedError: nil,
 			},
-		}
-		for _, tc := range testCases {
-			t.Run(tc.testName, func(t *testing.T) {
-				capture, err := flags.PrepareCapture(tc.captureSlice)
-				if tc.expectedError == nil {
-					require.NoError(t, err)
-					assert.Equal(t, tc.expectedCapture, capture, tc.testName)
-				} else {
+			{
+				testName:     "capture manifest-compact",
+				captureSlice: []string{"exec", "manifest-compact=30m"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:              "/tmp/tracee/out",
+					Exec:                    true,
+					Manifest:                true,
+					ManifestCompactInterval: 30 * time.Minute,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:        "capture invalid manifest-compact",
+				captureSlice:    []string{"exec", "manifest-compact=0s"},
+				expectedCapture: tracee.CaptureConfig{},
+				expectedError:   errors.New("capture manifest-compact must be a positive duration"),
+			},
+			{
+				testName:     "capture persist-dedup",
+				captureSlice: []string{"exec", "persist-dedup"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:   "/tmp/tracee/out",
+					Exec:         true,
+					PersistDedup: true,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:     "capture open",
+				captureSlice: []string{"open=/etc/shadow", "artifact:open=/run/secrets/*"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:    "/tmp/tracee/out",
+					FileOpenPaths: []string{"/etc/shadow", "/run/secrets/*"},
+				},
+				expectedError: nil,
+			},
+			{
+				testName:      "invalid capture open",
+				captureSlice:  []string{"open=*"},
+				expectedError: errors.New("capture open filter cannot be empty"),
+			},
+			{
+				testName:     "capture cas",
+				captureSlice: []string{"exec", "so", "cas"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:       "/tmp/tracee/out",
+					Exec:             true,
+					SharedObjects:    true,
+					ContentAddressed: true,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:     "capture mirror-paths",
+				captureSlice: []string{"exec", "mirror-paths"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:  "/tmp/tracee/out",
+					Exec:        true,
+					MirrorPaths: true,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:     "capture so",
+				captureSlice: []string{"so"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:    "/tmp/tracee/out",
+					SharedObjects: true,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:     "capture cmdline",
+				captureSlice: []string{"cmdline"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Cmdline:    true,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:     "capture hash-xattr",
+				captureSlice: []string{"exec", "hash-xattr"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Exec:       true,
+					HashXattr:  true,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:      "invalid capture pcap-rotate",
+				captureSlice:  []string{"pcap-rotate=-1"},
+				expectedError: errors.New("capture pcap-rotate size must be a positive number of megabytes"),
+			},
+			{
+				testName:     "capture pcap-rotate",
+				captureSlice: []string{"pcap-rotate=100"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:        "/tmp/tracee/out",
+					NetPcapRotateSize: 100 * 1024 * 1024,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:      "invalid capture max-open-files",
+				captureSlice:  []string{"max-open-files=0"},
+				expectedError: errors.New("capture max-open-files must be a positive number"),
+			},
+			{
+				testName:     "capture exec with max-open-files",
+				captureSlice: []string{"exec", "max-open-files=64"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:   "/tmp/tracee/out",
+					Exec:         true,
+					MaxOpenFiles: 64,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:      "invalid capture ns-rate",
+				captureSlice:  []string{"ns-rate=-1"},
+				expectedError: errors.New("capture ns-rate must be a positive number"),
+			},
+			{
+				testName:     "capture exec with ns-rate",
+				captureSlice: []string{"exec", "ns-rate=10"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:    "/tmp/tracee/out",
+					Exec:          true,
+					NamespaceRate: 10,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:      "invalid capture ns-max-files",
+				captureSlice:  []string{"ns-max-files=0"},
+				expectedError: errors.New("capture ns-max-files must be a positive number"),
+			},
+			{
+				testName:     "capture opened files with ns-max-files",
+				captureSlice: []string{"open=/etc/*", "ns-max-files=100000"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:        "/tmp/tracee/out",
+					FileOpenPaths:     []string{"/etc/*"},
+					NamespaceMaxFiles: 100000,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:      "invalid capture warmup",
+				captureSlice:  []string{"warmup=later"},
+				expectedError: errors.New("capture warmup must be a positive duration"),
+			},
+			{
+				testName:     "capture exec with warmup",
+				captureSlice: []string{"exec", "warmup=30s"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath:  "/tmp/tracee/out",
+					Exec:        true,
+					WarmupDelay: 30 * time.Second,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:      "invalid capture min file age",
+				captureSlice:  []string{"min-file-age=0s"},
+				expectedError: errors.New("capture min-file-age must be a positive duration"),
+			},
+			{
+				testName:     "capture exec with min file age",
+				captureSlice: []string{"exec", "min-file-age=2s"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Exec:       true,
+					MinFileAge: 2 * time.Second,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:     "multiple capture options",
+				captureSlice: []string{"write", "exec", "mem", "module"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					FileWrite:  true,
+					Mem:        true,
+					Exec:       true,
+					Module:     true,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:     "capture exec and enable profile",
+				captureSlice: []string{"exec", "profile"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Exec:       true,
+					Profile:    true,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:     "just enable profile",
+				captureSlice: []string{"profile"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					Exec:       true,
+					Profile:    true,
+				},
+				expectedError: nil,
+			},
+			{
+				testName:     "network interface",
+				captureSlice: []string{"net=lo"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					NetIfaces: &tracee.NetIfaces{
+						Ifaces: []string{"lo"},
+					},
+				},
+			},
+			{
+				testName:     "network interface, but repeated",
+				captureSlice: []string{"net=lo", "net=lo"},
+				expectedCapture: tracee.CaptureConfig{
+					OutputPath: "/tmp/tracee/out",
+					NetIfaces: &tracee.NetIfaces{
+						Ifaces: []string{"lo"},
+					},
+				},
+			},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.testName, func(t *testing.T) {
+				capture, err := flags.PrepareCapture(tc.captureSlice)
+				if tc.expectedError == nil {
+					require.NoError(t, err)
+					assert.Equal(t, tc.expectedCapture, capture, tc.testName)
+				} else {
 					require.Equal(t, tc.expectedError, err, tc.testName)
 					assert.Empty(t, capture, tc.testName)
 				}
@@ -1256,7 +1949,7 @@ func TestPrepareOutput(t *testing.T) {
 			outputSlice: []string{"foo"},
 			// it's not the preparer job to validate input. in this case foo is considered an implicit output format.
 			expectedOutput: tracee.OutputConfig{},
-			expectedError:  errors.New("unrecognized output format: foo. Valid format values: 'table', 'table-verbose', 'json', 'gob' or 'gotemplate='. Use '--output help' for more info"),
+			expectedError:  errors.New("unrecognized output format: foo. Valid format values: 'table', 'table-verbose', 'json', 'ecs', 'gob' or 'gotemplate='. Use '--output help' for more info"),
 		},
 		{
 			testName:       "invalid output option",
@@ -1274,7 +1967,7 @@ func TestPrepareOutput(t *testing.T) {
 			testName:       "empty val",
 			outputSlice:    []string{"out-file"},
 			expectedOutput: tracee.OutputConfig{},
-			expectedError:  errors.New("unrecognized output format: out-file. Valid format values: 'table', 'table-verbose', 'json', 'gob' or 'gotemplate='. Use '--output help' for more info"),
+			expectedError:  errors.New("unrecognized output format: out-file. Valid format values: 'table', 'table-verbose', 'json', 'ecs', 'gob' or 'gotemplate='. Use '--output help' for more info"),
 		},
 		{
 			testName:    "option stack-addresses",
@@ -1312,6 +2005,34 @@ func TestPrepareOutput(t *testing.T) {
 			},
 			expectedError: nil,
 		},
+		{
+			testName:    "option parent-exec-hash",
+			outputSlice: []string{"option:parent-exec-hash"},
+			expectedOutput: tracee.OutputConfig{
+				ExecHash:       true,
+				ParentExecHash: true,
+				ParseArguments: true,
+			},
+			expectedError: nil,
+		},
+		{
+			testName:    "option exec-mem-hash",
+			outputSlice: []string{"option:exec-mem-hash"},
+			expectedOutput: tracee.OutputConfig{
+				ExecMemHash:    true,
+				ParseArguments: true,
+			},
+			expectedError: nil,
+		},
+		{
+			testName:    "option exec-group-id",
+			outputSlice: []string{"option:exec-group-id"},
+			expectedOutput: tracee.OutputConfig{
+				ExecGroupID:    true,
+				ParseArguments: true,
+			},
+			expectedError: nil,
+		},
 		{
 			testName:    "option sort-events",
 			outputSlice: []string{"option:sort-events"},
@@ -1321,6 +2042,199 @@ func TestPrepareOutput(t *testing.T) {
 			},
 			expectedError: nil,
 		},
+		{
+			testName:    "option summary",
+			outputSlice: []string{"option:summary"},
+			expectedOutput: tracee.OutputConfig{
+				ParseArguments: true,
+				Summary:        true,
+			},
+			expectedError: nil,
+		},
+		{
+			testName:    "option include-raw",
+			outputSlice: []string{"option:include-raw"},
+			expectedOutput: tracee.OutputConfig{
+				ParseArguments: true,
+				IncludeRaw:     true,
+			},
+			expectedError: nil,
+		},
+		{
+			testName:    "option boot-timestamp",
+			outputSlice: []string{"option:boot-timestamp"},
+			expectedOutput: tracee.OutputConfig{
+				ParseArguments: true,
+				BootTimestamp:  true,
+			},
+			expectedError: nil,
+		},
+		{
+			testName:    "option cgroup-path",
+			outputSlice: []string{"option:cgroup-path"},
+			expectedOutput: tracee.OutputConfig{
+				ParseArguments: true,
+				CgroupPath:     true,
+			},
+			expectedError: nil,
+		},
+		{
+			testName:    "option decode-flags",
+			outputSlice: []string{"option:decode-flags"},
+			expectedOutput: tracee.OutputConfig{
+				ParseArguments: true,
+				DecodeFlags:    true,
+			},
+			expectedError: nil,
+		},
+		{
+			testName:    "option minimal",
+			outputSlice: []string{"option:minimal"},
+			expectedOutput: tracee.OutputConfig{
+				ParseArguments: true,
+				Minimal:        true,
+			},
+			expectedError: nil,
+		},
+		{
+			testName:    "option captured-only",
+			outputSlice: []string{"option:captured-only"},
+			expectedOutput: tracee.OutputConfig{
+				ParseArguments: true,
+				CapturedOnly:   true,
+			},
+			expectedError: nil,
+		},
+		{
+			testName:    "option max-arg-length",
+			outputSlice: []string{"option:max-arg-length=1024"},
+			expectedOutput: tracee.OutputConfig{
+				ParseArguments: true,
+				MaxArgLength:   1024,
+			},
+			expectedError: nil,
+		},
+		{
+			testName:       "invalid option max-arg-length",
+			outputSlice:    []string{"option:max-arg-length=-1"},
+			expectedOutput: tracee.OutputConfig{},
+			expectedError:  errors.New("invalid output option: max-arg-length=-1, max-arg-length must be a positive number"),
+		},
+		{
+			testName:    "option max-events",
+			outputSlice: []string{"option:max-events=100"},
+			expectedOutput: tracee.OutputConfig{
+				ParseArguments: true,
+				MaxEvents:      100,
+			},
+			expectedError: nil,
+		},
+		{
+			testName:       "invalid option max-events",
+			outputSlice:    []string{"option:max-events=0"},
+			expectedOutput: tracee.OutputConfig{},
+			expectedError:  errors.New("invalid output option: max-events=0, max-events must be a positive number"),
+		},
+		{
+			testName:    "option ancestry",
+			outputSlice: []string{"option:ancestry=5"},
+			expectedOutput: tracee.OutputConfig{
+				ParseArguments: true,
+				Ancestry:       5,
+			},
+			expectedError: nil,
+		},
+		{
+			testName:       "invalid option ancestry",
+			outputSlice:    []string{"option:ancestry=-1"},
+			expectedOutput: tracee.OutputConfig{},
+			expectedError:  errors.New("invalid output option: ancestry=-1, ancestry must be a positive number"),
+		},
+		{
+			testName:    "option coalesce",
+			outputSlice: []string{"option:coalesce=1s"},
+			expectedOutput: tracee.OutputConfig{
+				ParseArguments: true,
+				CoalesceWindow: time.Second,
+			},
+			expectedError: nil,
+		},
+		{
+			testName:       "invalid option coalesce",
+			outputSlice:    []string{"option:coalesce=-1s"},
+			expectedOutput: tracee.OutputConfig{},
+			expectedError:  errors.New("invalid output option: coalesce=-1s, coalesce must be a positive duration"),
+		},
+		{
+			testName:    "option dedup-derived",
+			outputSlice: []string{"option:dedup-derived=1m"},
+			expectedOutput: tracee.OutputConfig{
+				ParseArguments:     true,
+				DerivedDedupWindow: time.Minute,
+			},
+			expectedError: nil,
+		},
+		{
+			testName:       "invalid option dedup-derived",
+			outputSlice:    []string{"option:dedup-derived=0s"},
+			expectedOutput: tracee.OutputConfig{},
+			expectedError:  errors.New("invalid output option: dedup-derived=0s, dedup-derived must be a positive duration"),
+		},
+		{
+			testName:    "option drain-timeout",
+			outputSlice: []string{"option:drain-timeout=5s"},
+			expectedOutput: tracee.OutputConfig{
+				ParseArguments: true,
+				DrainTimeout:   5 * time.Second,
+			},
+			expectedError: nil,
+		},
+		{
+			testName:       "invalid option drain-timeout",
+			outputSlice:    []string{"option:drain-timeout=soon"},
+			expectedOutput: tracee.OutputConfig{},
+			expectedError:  errors.New("invalid output option: drain-timeout=soon, drain-timeout must be a positive duration"),
+		},
+		{
+			testName:    "option heartbeat",
+			outputSlice: []string{"option:heartbeat=30s"},
+			expectedOutput: tracee.OutputConfig{
+				ParseArguments:    true,
+				HeartbeatInterval: 30 * time.Second,
+			},
+			expectedError: nil,
+		},
+		{
+			testName:       "invalid option heartbeat",
+			outputSlice:    []string{"option:heartbeat=-1s"},
+			expectedOutput: tracee.OutputConfig{},
+			expectedError:  errors.New("invalid output option: heartbeat=-1s, heartbeat must be a positive duration"),
+		},
+		{
+			testName:    "option self-deleted",
+			outputSlice: []string{"option:self-deleted=10s"},
+			expectedOutput: tracee.OutputConfig{
+				ParseArguments:    true,
+				SelfDeletedWindow: 10 * time.Second,
+			},
+			expectedError: nil,
+		},
+		{
+			testName:       "invalid option self-deleted",
+			outputSlice:    []string{"option:self-deleted=10"},
+			expectedOutput: tracee.OutputConfig{},
+			expectedError:  errors.New("invalid output option: self-deleted=10, self-deleted must be a positive duration"),
+		},
+		{
+			testName:    "summary-file",
+			outputSlice: []string{"summary-file:/tmp/tracee.summary"},
+			expectedOutput: tracee.OutputConfig{
+				ParseArguments: true,
+				Summary:        true,
+				SummaryPath:    "/tmp/tracee.summary",
+			},
+			expectedError: nil,
+		},
 		{
 			testName:    "all options",
 			outputSlice: []string{"option:stack-addresses", "option:detect-syscall", "option:exec-env", "option:exec-hash", "option:sort-events"},
@@ -1347,6 +2261,349 @@ func TestPrepareOutput(t *testing.T) {
 	}
 }
 
+func TestPrepareOutputHashDenylist(t *testing.T) {
+	const hash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	testCases := []struct {
+		testName         string
+		content          string
+		expectedDenylist map[string]struct{}
+		expectedError    string
+	}{
+		{
+			testName:         "hashes",
+			content:          "# known bad\n" + strings.ToUpper(hash) + "\n\n" + hash + " # again\n",
+			expectedDenylist: map[string]struct{}{hash: {}},
+		},
+		{
+			testName:      "invalid hash",
+			content:       hash + "\n" + hash[:10] + "\n",
+			expectedError: "line 2 is not a sha256 hash",
+		},
+	}
+	for _, testcase := range testCases {
+		t.Run(testcase.testName, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "TestPrepareOutputHashDenylist-*")
+			require.NoError(t, err)
+			defer os.Remove(f.Name())
+			_, err = f.WriteString(testcase.content)
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+
+			output, _, err := flags.PrepareOutput([]string{"hash-denylist:" + f.Name()})
+			if testcase.expectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testcase.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, output.ExecHash)
+			assert.Equal(t, testcase.expectedDenylist, output.HashDenylist)
+		})
+	}
+
+	_, _, err := flags.PrepareOutput([]string{"hash-denylist:/non/existing/file"})
+	assert.Error(t, err)
+}
+
+func TestPrepareOutputNamespaceNames(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		content       string
+		expectedNames map[uint32]string
+		expectedError string
+	}{
+		{
+			testName:      "names",
+			content:       "# from the orchestrator\n4026532280 web-frontend\n\n4026532412\tdb # primary\n",
+			expectedNames: map[uint32]string{4026532280: "web-frontend", 4026532412: "db"},
+		},
+		{
+			testName:      "missing name",
+			content:       "4026532280 web-frontend\n4026532412\n",
+			expectedError: "line 2 is not a mntns and a name",
+		},
+		{
+			testName:      "invalid mntns",
+			content:       "web-frontend 4026532280\n",
+			expectedError: "line 1 has an invalid mntns web-frontend",
+		},
+	}
+	for _, testcase := range testCases {
+		t.Run(testcase.testName, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "TestPrepareOutputNamespaceNames-*")
+			require.NoError(t, err)
+			defer os.Remove(f.Name())
+			_, err = f.WriteString(testcase.content)
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+
+			output, _, err := flags.PrepareOutput([]string{"namespace-names:" + f.Name()})
+			if testcase.expectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testcase.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testcase.expectedNames, output.NamespaceNames)
+		})
+	}
+
+	_, _, err := flags.PrepareOutput([]string{"namespace-names:/non/existing/file"})
+	assert.Error(t, err)
+}
+
+func TestPrepareOutputRoute(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestPrepareOutputRoute-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, printerCfg, err := flags.PrepareOutput([]string{"out-file:" + dir + "/out", "route:execve,execveat:" + dir + "/siem"})
+	require.NoError(t, err)
+	require.Len(t, printerCfg.Sinks, 1)
+	assert.Equal(t, dir+"/siem", printerCfg.Sinks[0].OutPath)
+	assert.Equal(t, []events.ID{events.Execve, events.Execveat}, printerCfg.Sinks[0].Events)
+
+	// sets are expanded to their events
+	_, printerCfg, err = flags.PrepareOutput([]string{"route:security_alert:" + dir + "/alerts"})
+	require.NoError(t, err)
+	require.Len(t, printerCfg.Sinks, 1)
+	assert.Contains(t, printerCfg.Sinks[0].Events, events.WriteThenExec)
+	assert.NotContains(t, printerCfg.Sinks[0].Events, events.Openat)
+
+	_, _, err = flags.PrepareOutput([]string{"route:notanevent:" + dir + "/siem"})
+	assert.EqualError(t, err, "invalid output route: notanevent:"+dir+"/siem, notanevent is not an event or a set")
+	_, _, err = flags.PrepareOutput([]string{"route:execve"})
+	assert.EqualError(t, err, "invalid output route: execve, use '--output help' for more info")
+}
+
+func TestPrepareOutputOTLP(t *testing.T) {
+	_, printcfg, err := flags.PrepareOutput([]string{"none", "otlp:http://localhost:4318/", "otlp:spans:https://collector:4318"})
+	require.NoError(t, err)
+	assert.Equal(t, "ignore", printcfg.Kind)
+	assert.Equal(t, []printer.SinkConfig{
+		{OTLP: &printer.OTLPConfig{Endpoint: "http://localhost:4318"}, DropPolicy: printer.DropNewest},
+		{OTLP: &printer.OTLPConfig{Endpoint: "https://collector:4318", Spans: true}, DropPolicy: printer.DropNewest},
+	}, printcfg.Sinks)
+
+	for _, endpoint := range []string{"otlp:localhost:4318", "otlp:spans:", "otlp:grpc://collector:4317"} {
+		_, _, err := flags.PrepareOutput([]string{endpoint})
+		assert.ErrorContains(t, err, "invalid otlp endpoint", endpoint)
+	}
+}
+
+func TestPrepareOutputKafka(t *testing.T) {
+	_, printcfg, err := flags.PrepareOutput([]string{"none", "kafka:kafka:9092/tracee", "kafka:pid:retries=3:kafka-1:9092,kafka-2:9092/events"})
+	require.NoError(t, err)
+	assert.Equal(t, []printer.SinkConfig{
+		{Kafka: &printer.KafkaConfig{Brokers: []string{"kafka:9092"}, Topic: "tracee", PartitionBy: printer.KafkaPartitionByContainer}, DropPolicy: printer.DropNewest},
+		{Kafka: &printer.KafkaConfig{Brokers: []string{"kafka-1:9092", "kafka-2:9092"}, Topic: "events", PartitionBy: printer.KafkaPartitionByPID, Retries: 3}, DropPolicy: printer.DropNewest},
+	}, printcfg.Sinks)
+
+	for _, output := range []string{"kafka:kafka:9092", "kafka:kafka:9092/", "kafka:/tracee", "kafka:kafka:9092,/tracee", "kafka:retries=-1:kafka:9092/tracee", "kafka:retries=3"} {
+		_, _, err := flags.PrepareOutput([]string{output})
+		assert.ErrorContains(t, err, "invalid kafka output", output)
+	}
+}
+
+func TestPrepareOutputSyslog(t *testing.T) {
+	_, printcfg, err := flags.PrepareOutput([]string{
+		"none",
+		"syslog:localhost:514",
+		"syslog:tcp:facility=auth:severity=warning:max-size=8192:siem:6514",
+		"syslog:facility=16:severity=2:[::1]:514",
+		"sink-policy:siem:6514=block",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []printer.SinkConfig{
+		{Syslog: &printer.SyslogConfig{Network: "udp", Address: "localhost:514", Facility: 1, Severity: 6}, DropPolicy: printer.DropNewest},
+		{Syslog: &printer.SyslogConfig{Network: "tcp", Address: "siem:6514", Facility: 4, Severity: 4, MaxMessageSize: 8192}, DropPolicy: printer.Block},
+		{Syslog: &printer.SyslogConfig{Network: "udp", Address: "[::1]:514", Facility: 16, Severity: 2}, DropPolicy: printer.DropNewest},
+	}, printcfg.Sinks)
+
+	for _, output := range []string{"syslog:siem", "syslog:tcp:siem", "syslog:facility=lpt:siem:514", "syslog:facility=24:siem:514", "syslog:severity=warn:siem:514", "syslog:max-size=0:siem:514"} {
+		_, _, err := flags.PrepareOutput([]string{output})
+		assert.ErrorContains(t, err, "invalid syslog output", output)
+	}
+}
+
+func TestPrepareOutputSinkFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestPrepareOutputSinkFilter-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, printcfg, err := flags.PrepareOutput([]string{
+		"out-file:" + dir + "/out",
+		"out-file:" + dir + "/archive",
+		"route:execve:" + dir + "/siem",
+		"kafka:kafka:9092/tracee",
+		"sink-filter:" + dir + "/siem=event.uid == 0",
+		"sink-filter:" + dir + "/siem=args.pathname.startsWith('/tmp')",
+		"sink-filter:kafka:9092/tracee=event.containerId != ''",
+	})
+	require.NoError(t, err)
+	require.Len(t, printcfg.Sinks, 3)
+	assert.Nil(t, printcfg.Sinks[0].Filter)
+	siem := printcfg.Sinks[1].Filter
+	require.NotNil(t, siem)
+	// all the expressions of an output must match
+	assert.True(t, siem.FilterEvent(trace.Event{UserID: 0, Args: []trace.Argument{{ArgMeta: trace.ArgMeta{Name: "pathname"}, Value: "/tmp/x"}}}))
+	assert.False(t, siem.FilterEvent(trace.Event{UserID: 1000, Args: []trace.Argument{{ArgMeta: trace.ArgMeta{Name: "pathname"}, Value: "/tmp/x"}}}))
+	assert.False(t, siem.FilterEvent(trace.Event{UserID: 0, Args: []trace.Argument{{ArgMeta: trace.ArgMeta{Name: "pathname"}, Value: "/etc/passwd"}}}))
+	kafka := printcfg.Sinks[2].Filter
+	require.NotNil(t, kafka)
+	assert.True(t, kafka.FilterEvent(trace.Event{ContainerID: "ab356bc4dd554"}))
+	assert.False(t, kafka.FilterEvent(trace.Event{}))
+
+	for _, testCase := range []struct {
+		outputSlice   []string
+		expectedError string
+	}{
+		{[]string{"sink-filter:/my/siem"}, "invalid sink filter: /my/siem, expected output=expression"},
+		{[]string{"sink-filter:=event.uid == 0"}, "invalid sink filter: =event.uid == 0, expected output=expression"},
+		{[]string{"fifo:/run/tracee.fifo", "sink-filter:/run/tracee.fifo=event.uid +"}, "invalid sink filter of /run/tracee.fifo: invalid filter expression event.uid +"},
+		{[]string{"fifo:/run/tracee.fifo", "sink-filter:/run/tracee.fifo=event.gid == 0"}, "invalid sink filter of /run/tracee.fifo: invalid filter expression event.gid == 0: printed events have no event.gid"},
+		{[]string{"fifo:/run/tracee.fifo", "sink-filter:/run/other.fifo=event.uid == 0"}, "invalid sink filter of /run/other.fifo: no such output"},
+		{[]string{"out-file:" + dir + "/out", "sink-filter:" + dir + "/out=event.uid == 0"}, "invalid sink filter of " + dir + "/out: the first out-file can't be filtered, filter the traced events with --trace instead"},
+	} {
+		_, _, err := flags.PrepareOutput(testCase.outputSlice)
+		assert.ErrorContains(t, err, testCase.expectedError)
+	}
+}
+
+func TestPrepareOutputSinkPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestPrepareOutputSinkPolicy-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, printcfg, err := flags.PrepareOutput([]string{
+		"out-file:" + dir + "/out",
+		"out-file:" + dir + "/archive",
+		"fifo:/run/tracee.fifo",
+		"kafka:retries=3:kafka:9092/tracee",
+		"otlp:http://localhost:4318",
+		"sink-policy:" + dir + "/archive=block",
+		"sink-policy:/run/tracee.fifo=drop-oldest",
+		"sink-policy:kafka:9092/tracee=drop-newest",
+	})
+	require.NoError(t, err)
+	require.Len(t, printcfg.Sinks, 4)
+	assert.Equal(t, printer.Block, printcfg.Sinks[0].DropPolicy)
+	assert.Equal(t, printer.DropOldest, printcfg.Sinks[1].DropPolicy)
+	assert.Equal(t, printer.DropNewest, printcfg.Sinks[2].DropPolicy)
+	// outputs without a policy drop the newest events
+	assert.Equal(t, printer.DropNewest, printcfg.Sinks[3].DropPolicy)
+
+	for _, testCase := range []struct {
+		outputSlice   []string
+		expectedError string
+	}{
+		{[]string{"sink-policy:/run/tracee.fifo"}, "invalid sink policy: /run/tracee.fifo, expected output=policy"},
+		{[]string{"sink-policy:=block"}, "invalid sink policy: =block, expected output=policy"},
+		{[]string{"fifo:/run/tracee.fifo", "sink-policy:/run/tracee.fifo=retry"}, "invalid sink policy of /run/tracee.fifo: retry, expected block, drop-newest or drop-oldest"},
+		{[]string{"fifo:/run/tracee.fifo", "sink-policy:/run/other.fifo=block"}, "invalid sink policy of /run/other.fifo: no such output"},
+		{[]string{"out-file:" + dir + "/out", "sink-policy:" + dir + "/out=drop-oldest"}, "invalid sink policy of " + dir + "/out: the first out-file never drops events"},
+	} {
+		_, _, err := flags.PrepareOutput(testCase.outputSlice)
+		assert.ErrorContains(t, err, testCase.expectedError)
+	}
+}
+
+func TestPrepareOutputFieldMap(t *testing.T) {
+	_, printcfg, err := flags.PrepareOutput([]string{"json", "field-map:pathname=file.path,processName=process.name", "field-map:hostName=host.name"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"pathname":    "file.path",
+		"processName": "process.name",
+		"hostName":    "host.name",
+	}, printcfg.FieldMap)
+
+	for _, value := range []string{"field-map:pathname", "field-map:pathname=", "field-map:=file.path"} {
+		_, _, err := flags.PrepareOutput([]string{value})
+		assert.ErrorContains(t, err, "invalid field map", value)
+	}
+}
+
+func TestPrepareOutputArgSelection(t *testing.T) {
+	outcfg, _, err := flags.PrepareOutput([]string{"include-args:openat.pathname,ancestry", "include-args:openat.flags", "exclude-args:sched_process_exec.env"})
+	require.NoError(t, err)
+	assert.Equal(t, events.ArgNames{
+		All:    map[string]struct{}{"ancestry": {}},
+		Events: map[events.ID]map[string]struct{}{events.Openat: {"pathname": {}, "flags": {}}},
+	}, outcfg.IncludeArgs)
+	assert.Equal(t, events.ArgNames{
+		Events: map[events.ID]map[string]struct{}{events.SchedProcessExec: {"env": {}}},
+	}, outcfg.ExcludeArgs)
+
+	for _, testCase := range []struct {
+		outputSlice   []string
+		expectedError string
+	}{
+		{[]string{"include-args:opennat.pathname"}, "invalid include-args: no such event: opennat"},
+		{[]string{"exclude-args:openat."}, "invalid exclude-args: empty argument name of openat"},
+		{[]string{"exclude-args:pathname,"}, "invalid exclude-args: empty argument name in pathname,"},
+	} {
+		_, _, err := flags.PrepareOutput(testCase.outputSlice)
+		assert.EqualError(t, err, testCase.expectedError)
+	}
+}
+
+func TestPrepareOutputFIFO(t *testing.T) {
+	_, printcfg, err := flags.PrepareOutput([]string{"json", "fifo:/run/tracee.fifo", "fifo:table:/run/table.fifo"})
+	require.NoError(t, err)
+	assert.Equal(t, []printer.SinkConfig{
+		{FIFOPath: "/run/tracee.fifo", DropPolicy: printer.DropNewest, Kind: "json"},
+		{FIFOPath: "/run/table.fifo", DropPolicy: printer.DropNewest, Kind: "table"},
+	}, printcfg.Sinks)
+
+	_, _, err = flags.PrepareOutput([]string{"fifo:json,gzip:/run/tracee.fifo"})
+	assert.EqualError(t, err, "invalid fifo output: json,gzip:/run/tracee.fifo, fifo outputs can't be compressed")
+}
+
+func TestPrepareOutputFileFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestPrepareOutputFileFormat-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, printerCfg, err := flags.PrepareOutput([]string{
+		"json",
+		"out-file:table:" + dir + "/out",
+		"out-file:json,gzip:" + dir + "/archive.gz",
+		"out-file:" + dir + "/copy",
+		"route:execve:" + dir + "/siem",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, dir+"/out", printerCfg.OutPath)
+	assert.Equal(t, "table", printerCfg.Kind)
+	assert.False(t, printerCfg.Gzip)
+	require.Len(t, printerCfg.Sinks, 3)
+	assert.Equal(t, dir+"/archive.gz", printerCfg.Sinks[0].OutPath)
+	assert.Equal(t, "json", printerCfg.Sinks[0].Kind)
+	assert.True(t, printerCfg.Sinks[0].Gzip)
+	// outputs without their own format get the format of all outputs
+	assert.Equal(t, dir+"/copy", printerCfg.Sinks[1].OutPath)
+	assert.Equal(t, "json", printerCfg.Sinks[1].Kind)
+	assert.False(t, printerCfg.Sinks[1].Gzip)
+	assert.Equal(t, "json", printerCfg.Sinks[2].Kind)
+
+	// gzip alone compresses the format of all outputs
+	_, printerCfg, err = flags.PrepareOutput([]string{"gob", "out-file:gzip:" + dir + "/out.gz"})
+	require.NoError(t, err)
+	assert.Equal(t, dir+"/out.gz", printerCfg.OutPath)
+	assert.Equal(t, "gob", printerCfg.Kind)
+	assert.True(t, printerCfg.Gzip)
+}
+
+func TestPrepareOutputPartitionHourly(t *testing.T) {
+	dir := t.TempDir()
+	_, printerCfg, err := flags.PrepareOutput([]string{"json", "out-file:" + dir + "/events.jsonl", "option:partition-hourly"})
+	require.NoError(t, err)
+	assert.True(t, printerCfg.PartitionHourly)
+
+	_, _, err = flags.PrepareOutput([]string{"json", "option:partition-hourly"})
+	assert.EqualError(t, err, "invalid output option: partition-hourly, only out-file and route outputs can be partitioned")
+}
+
 func TestPrepareCache(t *testing.T) {
 	testCases := []struct {
 		testName      string
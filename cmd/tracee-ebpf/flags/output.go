@@ -1,13 +1,22 @@
 package flags
 
 import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aquasecurity/tracee/cmd/tracee-ebpf/internal/printer"
 	tracee "github.com/aquasecurity/tracee/pkg/ebpf"
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/filters"
 )
 
 func OutputHelp() string {
@@ -16,26 +25,72 @@ Possible options:
 [format:]table                                     output events in table format
 [format:]table-verbose                             output events in table format with extra fields per event
 [format:]json                                      output events in json format
+[format:]ecs                                       output events in json format, as Elastic Common Schema (ECS) documents
 [format:]gob                                       output events in gob format
 [format:]gotemplate=/path/to/template              output events formatted using a given gotemplate file
-out-file:/path/to/file                             write the output to a specified file. create/trim the file if exists (default: stdout)
+out-file:/path/to/file                             write the output to a specified file. create/trim the file if exists (default: stdout).
+                                                   when given multiple times, the output is written to all files concurrently. all files but the first drop events they can't keep up with
+out-file:format[,gzip]:/path/to/file               write the output to a specified file in its own format and compression (e.g. out-file:json,gzip:/path/to/file.gz), instead of the ones given for all outputs
+route:event1,event2:/path/to/file                  write only the given events (or the events of the given sets) to a specified file, and not to the other outputs. the other outputs get the events not routed to any file. may be given multiple times
+otlp:[spans:]http://collector:4318                 also export the events to an OpenTelemetry collector, as OTLP log records sent over HTTP (JSON encoded) in batches. events are dropped if the collector can't keep up. with spans, also export a span for the lifetime of every process, correlated to the records of its events
+kafka:[pid:][retries=N:]broker[,broker]/topic      also publish the events to a kafka topic as json, in batches, keyed by their container id (or by their pid with pid, and for host events) so the events of each are consumed in order. failed batches are retried up to N times (default: 0), and events are dropped if kafka can't keep up. requires tracee-ebpf to be built with KAFKA=1
+syslog:[tcp:][facility=F:][severity=S:]host:port   also send the events to a syslog collector, as RFC 5424 messages of the context and the arguments of events as structured data, over udp (or tcp, framed by their length). facility is a name (e.g. auth, local0) or a number (default: user), and severity a name (e.g. warning) or a number (default: info). with max-size=N:, arguments are truncated to fit messages of N bytes (default: 2048)
+fifo:[format:]/path/to/pipe                        also write the output to a named pipe (created if it doesn't exist), e.g. for streaming to a local processor without touching the disk. events are dropped while the pipe has no reader, and counted on exit. gob isn't supported
+sink-filter:output=expression                      write to an output other than the first out-file only the events matching an expression, evaluated on the enriched events as the expr= filters of --trace, which have no event.gid (e.g. sink-filter:/my/siem=event.uid == 0 || event.containerImage != ''). outputs are given by their path, otlp endpoint, kafka broker[,broker]/topic or syslog host:port. all expressions given for an output must match
+sink-policy:output=policy                          set what an output other than the first out-file does when it can't keep up with the events: block (hold back tracing), drop-newest (drop the new events, the default) or drop-oldest (drop the oldest queued events, to print the most recent ones). outputs are given as in sink-filter. the dropped events are counted on exit
+field-map:name=new-name[,name=new-name]            rename fields of events printed as json, for downstream schemas expecting other names (e.g. field-map:pathname=file.path,processName=process.name). top level fields and arguments are renamed by their names. may be given multiple times
+include-args:[event.]arg[,[event.]arg]             emit only the given arguments of events (e.g. include-args:openat.pathname,ancestry). arguments given with an event name are selected for the events of its type only, and the others for all events. the events no argument is given for keep all their arguments. applies to the arguments added by tracee as well. may be given multiple times
+exclude-args:[event.]arg[,[event.]arg]             drop the given arguments of events (e.g. exclude-args:openat.mode,sched_process_exec.env), selected as in include-args. may be given multiple times
 err-file:/path/to/file                             write the errors to a specified file. create/trim the file if exists (default: stderr)
+summary-file:/path/to/file                         write the summary of the run to a specified file (implies option:summary)
+hash-denylist:/path/to/file                        load sha256 hashes of known bad files from a specified file (one per line, '#' starts a comment). tracing malware_hash_match reports executed files and captured kernel modules matching them (implies option:exec-hash)
+namespace-names:/path/to/file                      load names of mount namespaces from a specified file (a mntns and its name per line, '#' starts a comment). events are added a namespace_name argument, which is the raw mntns of namespaces without a name
 none                                               ignore stream of events output, usually used with --capture
-option:{stack-addresses,detect-syscall,exec-env,relative-time,exec-hash,parse-arguments,sort-events}
+option:{stack-addresses,detect-syscall,exec-env,relative-time,boot-timestamp,cgroup-path,exec-hash,parent-exec-hash,exec-mem-hash,exec-group-id,parse-arguments,include-raw,sort-events,summary,decode-flags,minimal,captured-only,max-arg-length=N,max-events=N,ancestry=N,self-deleted=DURATION,coalesce=DURATION,dedup-derived=DURATION,drain-timeout=DURATION,heartbeat=DURATION,gzip,partition-hourly}
                                                    augment output according to given options (default: none)
   stack-addresses                                  include stack memory addresses for each event
   detect-syscall                                   when tracing kernel functions which are not syscalls, detect and show the original syscall that called that function
   exec-env                                         when tracing execve/execveat, show the environment variables that were used for execution
   relative-time                                    use relative timestamp instead of wall timestamp for events
+  boot-timestamp                                   also add the raw timestamp of events, in nanoseconds since boot, as 'timestamp_ns_boot', along their wall (or relative) timestamp
+  cgroup-path                                      add the cgroup path of the process of events as 'cgroup_path' (e.g. of the slice of its kubernetes pod), as read from procfs. empty if no process of its container is alive anymore
   exec-hash                                        when tracing sched_process_exec, show the file hash(sha256) and ctime
+  parent-exec-hash                                 enable exec-hash and also show the hash(sha256) of the parent process' executable as 'parent_sha256'. empty if the parent has already exited
+  exec-mem-hash                                    when tracing sched_process_exec, show the hash(sha256) of the executable image in the memory of the process as 'mem_sha256', which is the code that actually runs even if its file was modified or deleted. empty if the memory can't be read. may be used with or without exec-hash
+  exec-group-id                                    when tracing sched_process_exec, show an id of the command as 'exec_group_id', shared by the execs of the same file with the same arguments (in the same order and with the same whitespace), whatever their process or time. the arguments are the captured command line with --capture cmdline, or else the argv argument
   parse-arguments                                  do not show raw machine-readable values for event arguments, instead parse into human readable strings
   parse-arguments-fds                              enable parse-arguments and enrich fd with its file path translation. This can cause pipeline slowdowns.
+  include-raw                                      enable parse-arguments and keep the raw values of parsed arguments, adding the parsed values as '<arg>_decoded' arguments
   sort-events                                      enable sorting events before passing to them output. This will decrease the overall program efficiency.
   cache-events                                     enable caching events to release perf-buffer pressure. This will decrease amount of event loss until cache is full.
+  decode-flags                                     add a '<arg>_str' argument with the symbolic names of bitmask arguments (open flags, memory protection), keeping their raw values. memory protection also adds a 'wx' argument for writable and executable mappings. socket domains and types are replaced by their names
+  minimal                                          disable the enrichment of events for the maximal throughput: no hashing, captures, derived events or added arguments (e.g. ancestry), whatever the other options
+  captured-only                                    only emit the events which resulted in the capture of a file (e.g. the exec of a binary copied by --capture exec, or the first write of a file captured by --capture write), so the events match the captured files (requires capturing files)
+  gzip                                             compress the events output with gzip. the output is flushed every second
+  partition-hourly                                 split the output files into a file per (UTC) hour, named by the hour before their extension (e.g. events-2024-01-02T15.jsonl for out-file:events.jsonl). gob files aren't partitioned
+  max-arg-length=N                                 truncate string arguments longer than N bytes, marking them with '...(truncated)'
+  max-events=N                                     stop tracing after N events were emitted, flushing the output and writing the summary (e.g. for bounded runs in CI)
+  ancestry=N                                       add an 'ancestry' argument to events, with the names of up to N ancestors of their process, parent first (e.g. [curl bash])
+  self-deleted=DURATION                            with sched_process_exec traced, mark the first event of a process deleting its executable within DURATION (e.g. 10s) of its execution with a 'self_deleted' argument
+  coalesce=DURATION                                merge consecutive identical events (same event, thread and arguments, e.g. reads in a loop) within DURATION (e.g. 1s) into the first one, with a 'repeat_count' argument
+  dedup-derived=DURATION                           report a condition detected by a derived event (e.g. wx_memory_mapping, write_then_exec, privilege_escalation) once per DURATION (e.g. 1m) for each process
+  drain-timeout=DURATION                           on exit, wait at most DURATION (e.g. 5s) for the events in flight, files being captured and buffered output to be flushed, abandoning what is left and logging it
+  heartbeat=DURATION                               emit a 'heartbeat' event every DURATION (e.g. 30s), with the number of events emitted and lost and the uptime, so consumers can tell a quiet tracee from a dead one
+  summary                                          print a summary of the run (events, losses, captures and most executed binaries) to stderr on exit
 Examples:
   --output json                                            | output as json
   --output gotemplate=/path/to/my.tmpl                     | output as the provided go template
   --output out-file:/my/out --output err-file:/my/err      | output to /my/out and errors to /my/err
+  --output out-file:/my/out --output out-file:/my/copy     | output to both /my/out and /my/copy
+  --output out-file:json,gzip:/my/out.gz                   | output to /my/out.gz as gzipped json, whatever the format of the other outputs
+  --output route:execve,execveat:/my/siem                  | output execve and execveat events to /my/siem, and the other events to stdout
+  --output sink-filter:kafka:9092/tracee=event.uid == 0    | publish only the events of root to the kafka:9092/tracee topic of a kafka output
+  --output sink-policy:/my/copy=block                      | never drop the events of the /my/copy out-file, waiting for it to keep up
+  --output include-args:openat.pathname                    | only emit the pathname argument of openat events
+  --output none --output otlp:spans:http://localhost:4318  | only export events and process spans to a local OpenTelemetry collector
+  --output none --output kafka:retries=3:kafka:9092/tracee | only publish events to the tracee kafka topic, retrying failed batches 3 times
+  --output none --output fifo:json:/run/tracee.fifo        | only stream events as json to the reader of /run/tracee.fifo, if any
+  --output none --output syslog:tcp:facility=auth:siem:514 | only send events to the syslog collector at siem:514 over tcp, of the auth facility
   --output none                                            | ignore events output
 Use this flag multiple times to choose multiple output options
 `
@@ -45,8 +100,15 @@ func PrepareOutput(outputSlice []string) (tracee.OutputConfig, printer.Config, e
 	outcfg := tracee.OutputConfig{}
 	printcfg := printer.Config{}
 	printerKind := "table"
-	outPath := ""
+	var outFiles []outputFile
 	errPath := ""
+	var routes []outputRoute
+	var otlpConfigs []printer.OTLPConfig
+	var kafkaConfigs []printer.KafkaConfig
+	var syslogConfigs []printer.SyslogConfig
+	var fifos []outputFile
+	sinkFilters := make(map[string]*filters.ExprFilter)
+	sinkPolicies := make(map[string]printer.DropPolicy)
 	for _, o := range outputSlice {
 		outputParts := strings.SplitN(o, ":", 2)
 		numParts := len(outputParts)
@@ -60,18 +122,147 @@ func PrepareOutput(outputSlice []string) (tracee.OutputConfig, printer.Config, e
 			printerKind = "ignore"
 		case "format":
 			printerKind = outputParts[1]
-			if printerKind != "table" &&
-				printerKind != "table-verbose" &&
-				printerKind != "json" &&
-				printerKind != "gob" &&
-				!strings.HasPrefix(printerKind, "gotemplate=") {
-				return outcfg, printcfg, fmt.Errorf("unrecognized output format: %s. Valid format values: 'table', 'table-verbose', 'json', 'gob' or 'gotemplate='. Use '--output help' for more info", printerKind)
+			if !isOutputFormat(printerKind) {
+				return outcfg, printcfg, fmt.Errorf("unrecognized output format: %s. Valid format values: 'table', 'table-verbose', 'json', 'ecs', 'gob' or 'gotemplate='. Use '--output help' for more info", printerKind)
 			}
 		case "out-file":
-			outPath = outputParts[1]
+			outFiles = append(outFiles, parseOutputFile(outputParts[1]))
+		case "route":
+			route, err := parseRoute(outputParts[1])
+			if err != nil {
+				return outcfg, printcfg, err
+			}
+			routes = append(routes, route)
+		case "otlp":
+			otlpConfig, err := parseOTLP(outputParts[1])
+			if err != nil {
+				return outcfg, printcfg, err
+			}
+			otlpConfigs = append(otlpConfigs, otlpConfig)
+		case "kafka":
+			kafkaConfig, err := parseKafka(outputParts[1])
+			if err != nil {
+				return outcfg, printcfg, err
+			}
+			kafkaConfigs = append(kafkaConfigs, kafkaConfig)
+		case "syslog":
+			syslogConfig, err := parseSyslog(outputParts[1])
+			if err != nil {
+				return outcfg, printcfg, err
+			}
+			syslogConfigs = append(syslogConfigs, syslogConfig)
+		case "fifo":
+			fifo := parseOutputFile(outputParts[1])
+			if fifo.gzip {
+				return outcfg, printcfg, fmt.Errorf("invalid fifo output: %s, fifo outputs can't be compressed", outputParts[1])
+			}
+			fifos = append(fifos, fifo)
+		case "sink-filter":
+			if err := parseSinkFilter(outputParts[1], sinkFilters); err != nil {
+				return outcfg, printcfg, err
+			}
+		case "sink-policy":
+			if err := parseSinkPolicy(outputParts[1], sinkPolicies); err != nil {
+				return outcfg, printcfg, err
+			}
+		case "field-map":
+			if printcfg.FieldMap == nil {
+				printcfg.FieldMap = make(map[string]string)
+			}
+			if err := parseFieldMap(outputParts[1], printcfg.FieldMap); err != nil {
+				return outcfg, printcfg, err
+			}
+		case "include-args":
+			if err := parseArgNames(outputParts[1], &outcfg.IncludeArgs); err != nil {
+				return outcfg, printcfg, fmt.Errorf("invalid include-args: %v", err)
+			}
+		case "exclude-args":
+			if err := parseArgNames(outputParts[1], &outcfg.ExcludeArgs); err != nil {
+				return outcfg, printcfg, fmt.Errorf("invalid exclude-args: %v", err)
+			}
 		case "err-file":
 			errPath = outputParts[1]
+		case "summary-file":
+			outcfg.Summary = true
+			outcfg.SummaryPath = outputParts[1]
+		case "hash-denylist":
+			denylist, err := readHashDenylist(outputParts[1])
+			if err != nil {
+				return outcfg, printcfg, err
+			}
+			outcfg.ExecHash = true
+			outcfg.HashDenylist = denylist
+		case "namespace-names":
+			names, err := readNamespaceNames(outputParts[1])
+			if err != nil {
+				return outcfg, printcfg, err
+			}
+			outcfg.NamespaceNames = names
 		case "option":
+			if strings.HasPrefix(outputParts[1], "max-arg-length=") {
+				maxArgLength, err := strconv.Atoi(strings.TrimPrefix(outputParts[1], "max-arg-length="))
+				if err != nil || maxArgLength <= 0 {
+					return outcfg, printcfg, fmt.Errorf("invalid output option: %s, max-arg-length must be a positive number", outputParts[1])
+				}
+				outcfg.MaxArgLength = maxArgLength
+				continue
+			}
+			if strings.HasPrefix(outputParts[1], "max-events=") {
+				maxEvents, err := strconv.Atoi(strings.TrimPrefix(outputParts[1], "max-events="))
+				if err != nil || maxEvents <= 0 {
+					return outcfg, printcfg, fmt.Errorf("invalid output option: %s, max-events must be a positive number", outputParts[1])
+				}
+				outcfg.MaxEvents = maxEvents
+				continue
+			}
+			if strings.HasPrefix(outputParts[1], "ancestry=") {
+				ancestry, err := strconv.Atoi(strings.TrimPrefix(outputParts[1], "ancestry="))
+				if err != nil || ancestry <= 0 {
+					return outcfg, printcfg, fmt.Errorf("invalid output option: %s, ancestry must be a positive number", outputParts[1])
+				}
+				outcfg.Ancestry = ancestry
+				continue
+			}
+			if strings.HasPrefix(outputParts[1], "coalesce=") {
+				window, err := time.ParseDuration(strings.TrimPrefix(outputParts[1], "coalesce="))
+				if err != nil || window <= 0 {
+					return outcfg, printcfg, fmt.Errorf("invalid output option: %s, coalesce must be a positive duration", outputParts[1])
+				}
+				outcfg.CoalesceWindow = window
+				continue
+			}
+			if strings.HasPrefix(outputParts[1], "dedup-derived=") {
+				window, err := time.ParseDuration(strings.TrimPrefix(outputParts[1], "dedup-derived="))
+				if err != nil || window <= 0 {
+					return outcfg, printcfg, fmt.Errorf("invalid output option: %s, dedup-derived must be a positive duration", outputParts[1])
+				}
+				outcfg.DerivedDedupWindow = window
+				continue
+			}
+			if strings.HasPrefix(outputParts[1], "drain-timeout=") {
+				timeout, err := time.ParseDuration(strings.TrimPrefix(outputParts[1], "drain-timeout="))
+				if err != nil || timeout <= 0 {
+					return outcfg, printcfg, fmt.Errorf("invalid output option: %s, drain-timeout must be a positive duration", outputParts[1])
+				}
+				outcfg.DrainTimeout = timeout
+				continue
+			}
+			if strings.HasPrefix(outputParts[1], "heartbeat=") {
+				interval, err := time.ParseDuration(strings.TrimPrefix(outputParts[1], "heartbeat="))
+				if err != nil || interval <= 0 {
+					return outcfg, printcfg, fmt.Errorf("invalid output option: %s, heartbeat must be a positive duration", outputParts[1])
+				}
+				outcfg.HeartbeatInterval = interval
+				continue
+			}
+			if strings.HasPrefix(outputParts[1], "self-deleted=") {
+				window, err := time.ParseDuration(strings.TrimPrefix(outputParts[1], "self-deleted="))
+				if err != nil || window <= 0 {
+					return outcfg, printcfg, fmt.Errorf("invalid output option: %s, self-deleted must be a positive duration", outputParts[1])
+				}
+				outcfg.SelfDeletedWindow = window
+				continue
+			}
 			switch outputParts[1] {
 			case "stack-addresses":
 				outcfg.StackAddresses = true
@@ -82,15 +273,41 @@ func PrepareOutput(outputSlice []string) (tracee.OutputConfig, printer.Config, e
 			case "relative-time":
 				outcfg.RelativeTime = true
 				printcfg.RelativeTS = true
+			case "boot-timestamp":
+				outcfg.BootTimestamp = true
+			case "cgroup-path":
+				outcfg.CgroupPath = true
 			case "exec-hash":
 				outcfg.ExecHash = true
+			case "parent-exec-hash":
+				outcfg.ExecHash = true
+				outcfg.ParentExecHash = true // no point in hashing the parent's executable only
+			case "exec-mem-hash":
+				outcfg.ExecMemHash = true
+			case "exec-group-id":
+				outcfg.ExecGroupID = true
 			case "parse-arguments":
 				outcfg.ParseArguments = true
 			case "parse-arguments-fds":
 				outcfg.ParseArgumentsFDs = true
 				outcfg.ParseArguments = true // no point in parsing file descriptor args only
+			case "include-raw":
+				outcfg.IncludeRaw = true
+				outcfg.ParseArguments = true
 			case "sort-events":
 				outcfg.EventsSorting = true
+			case "summary":
+				outcfg.Summary = true
+			case "decode-flags":
+				outcfg.DecodeFlags = true
+			case "minimal":
+				outcfg.Minimal = true
+			case "captured-only":
+				outcfg.CapturedOnly = true
+			case "gzip":
+				printcfg.Gzip = true
+			case "partition-hourly":
+				printcfg.PartitionHourly = true
 			default:
 				return outcfg, printcfg, fmt.Errorf("invalid output option: %s, use '--output help' for more info", outputParts[1])
 			}
@@ -99,25 +316,91 @@ func PrepareOutput(outputSlice []string) (tracee.OutputConfig, printer.Config, e
 		}
 	}
 
+	if printcfg.PartitionHourly && len(outFiles) == 0 && len(routes) == 0 {
+		return outcfg, printcfg, fmt.Errorf("invalid output option: partition-hourly, only out-file and route outputs can be partitioned")
+	}
+
+	if err := checkSinkFilters(sinkFilters, outFiles, routes, fifos, otlpConfigs, kafkaConfigs, syslogConfigs); err != nil {
+		return outcfg, printcfg, err
+	}
+	if err := checkSinkPolicies(sinkPolicies, outFiles, routes, fifos, otlpConfigs, kafkaConfigs, syslogConfigs); err != nil {
+		return outcfg, printcfg, err
+	}
+
 	if printerKind == "table" {
 		outcfg.ParseArguments = true
 	}
+	for i, f := range outFiles {
+		if f.gzip && f.kind == "" {
+			outFiles[i].kind = printerKind
+		}
+		if outFiles[i].kind == "table" {
+			outcfg.ParseArguments = true
+		}
+	}
 
+	// the other outputs are given the format of all outputs explicitly, as the main output may have its own
 	printcfg.Kind = printerKind
+	sinkKind, sinkGzip := printcfg.Kind, printcfg.Gzip
+	if len(outFiles) > 0 && outFiles[0].kind != "" {
+		printcfg.Kind = outFiles[0].kind
+		printcfg.Gzip = outFiles[0].gzip
+	}
 
-	if outPath == "" {
+	if len(outFiles) == 0 {
 		printcfg.OutFile = os.Stdout
-	} else {
-		printcfg.OutPath = outPath
-		fileInfo, err := os.Stat(outPath)
-		if err == nil && fileInfo.IsDir() {
-			return outcfg, printcfg, fmt.Errorf("cannot use a path of existing directory %s", outPath)
+	}
+	for i, f := range outFiles {
+		outFile, err := createOutputFile(f.path)
+		if err != nil {
+			return outcfg, printcfg, err
 		}
-		dir := filepath.Dir(outPath)
-		os.MkdirAll(dir, 0755)
-		printcfg.OutFile, err = os.Create(outPath)
+		if i == 0 {
+			printcfg.OutPath = f.path
+			printcfg.OutFile = outFile
+			continue
+		}
+		sinkConfig := printer.SinkConfig{OutPath: f.path, OutFile: outFile, DropPolicy: printer.DropNewest, Kind: f.kind, Gzip: f.gzip}
+		if sinkConfig.Kind == "" {
+			sinkConfig.Kind, sinkConfig.Gzip = sinkKind, sinkGzip
+		}
+		printcfg.Sinks = append(printcfg.Sinks, sinkConfig)
+	}
+	for _, route := range routes {
+		outFile, err := createOutputFile(route.outPath)
 		if err != nil {
-			return outcfg, printcfg, fmt.Errorf("failed to create output path: %v", err)
+			return outcfg, printcfg, err
+		}
+		printcfg.Sinks = append(printcfg.Sinks, printer.SinkConfig{OutPath: route.outPath, OutFile: outFile, DropPolicy: printer.DropNewest, Kind: sinkKind, Gzip: sinkGzip, Events: route.events})
+	}
+
+	for _, fifo := range fifos {
+		sinkConfig := printer.SinkConfig{FIFOPath: fifo.path, DropPolicy: printer.DropNewest, Kind: fifo.kind}
+		if sinkConfig.Kind == "" {
+			sinkConfig.Kind = sinkKind
+		}
+		if sinkConfig.Kind == "table" {
+			outcfg.ParseArguments = true
+		}
+		printcfg.Sinks = append(printcfg.Sinks, sinkConfig)
+	}
+
+	for i := range otlpConfigs {
+		printcfg.Sinks = append(printcfg.Sinks, printer.SinkConfig{OTLP: &otlpConfigs[i], DropPolicy: printer.DropNewest})
+	}
+
+	for i := range kafkaConfigs {
+		printcfg.Sinks = append(printcfg.Sinks, printer.SinkConfig{Kafka: &kafkaConfigs[i], DropPolicy: printer.DropNewest})
+	}
+
+	for i := range syslogConfigs {
+		printcfg.Sinks = append(printcfg.Sinks, printer.SinkConfig{Syslog: &syslogConfigs[i], DropPolicy: printer.DropNewest})
+	}
+
+	for i, sinkConfig := range printcfg.Sinks {
+		printcfg.Sinks[i].Filter = sinkFilters[sinkOutput(sinkConfig)]
+		if policy, ok := sinkPolicies[sinkOutput(sinkConfig)]; ok {
+			printcfg.Sinks[i].DropPolicy = policy
 		}
 	}
 
@@ -139,3 +422,418 @@ func PrepareOutput(outputSlice []string) (tracee.OutputConfig, printer.Config, e
 
 	return outcfg, printcfg, nil
 }
+
+// createOutputFile creates (or trims) a file for printing events, creating its directory if needed
+func createOutputFile(outPath string) (*os.File, error) {
+	fileInfo, err := os.Stat(outPath)
+	if err == nil && fileInfo.IsDir() {
+		return nil, fmt.Errorf("cannot use a path of existing directory %s", outPath)
+	}
+	dir := filepath.Dir(outPath)
+	os.MkdirAll(dir, 0755)
+	outFile, err := os.Create(outPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output path: %v", err)
+	}
+	return outFile, nil
+}
+
+// parseOTLP parses an OpenTelemetry collector to export the events to, given as [spans:]endpoint
+func parseOTLP(value string) (printer.OTLPConfig, error) {
+	config := printer.OTLPConfig{}
+	if strings.HasPrefix(value, "spans:") {
+		config.Spans = true
+		value = strings.TrimPrefix(value, "spans:")
+	}
+	endpoint, err := url.Parse(value)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return config, fmt.Errorf("invalid otlp endpoint: %s, expected the url of an OTLP/HTTP receiver (e.g. http://collector:4318)", value)
+	}
+	config.Endpoint = strings.TrimSuffix(value, "/")
+	return config, nil
+}
+
+// parseKafka parses a kafka topic to publish the events to, given as [pid:][retries=N:]broker[,broker]/topic
+func parseKafka(value string) (printer.KafkaConfig, error) {
+	config := printer.KafkaConfig{PartitionBy: printer.KafkaPartitionByContainer}
+	invalid := fmt.Errorf("invalid kafka output: %s, expected [pid:][retries=N:]broker[,broker]/topic (e.g. kafka:9092/tracee)", value)
+	topic := value
+	if strings.HasPrefix(topic, "pid:") {
+		config.PartitionBy = printer.KafkaPartitionByPID
+		topic = strings.TrimPrefix(topic, "pid:")
+	}
+	if strings.HasPrefix(topic, "retries=") {
+		parts := strings.SplitN(strings.TrimPrefix(topic, "retries="), ":", 2)
+		retries, err := strconv.Atoi(parts[0])
+		if err != nil || retries < 0 || len(parts) != 2 {
+			return config, invalid
+		}
+		config.Retries = retries
+		topic = parts[1]
+	}
+	slash := strings.LastIndex(topic, "/")
+	if slash < 0 || topic[slash+1:] == "" {
+		return config, invalid
+	}
+	for _, broker := range strings.Split(topic[:slash], ",") {
+		if broker == "" {
+			return config, invalid
+		}
+		config.Brokers = append(config.Brokers, broker)
+	}
+	config.Topic = topic[slash+1:]
+	return config, nil
+}
+
+// syslogFacilities are the numbers of the syslog facilities, by their names (see RFC 5424)
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7, "uucp": 8, "cron": 9,
+	"authpriv": 10, "ftp": 11, "ntp": 12, "audit": 13, "alert": 14, "clock": 15, "local0": 16, "local1": 17,
+	"local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSeverities are the numbers of the syslog severities, by their names (see RFC 5424)
+var syslogSeverities = map[string]int{
+	"emerg": 0, "alert": 1, "crit": 2, "err": 3, "warning": 4, "notice": 5, "info": 6, "debug": 7,
+}
+
+// parseSyslogLevel parses a syslog facility or severity, given by its name or number
+func parseSyslogLevel(value string, names map[string]int, max int) (int, bool) {
+	if level, ok := names[value]; ok {
+		return level, true
+	}
+	level, err := strconv.Atoi(value)
+	return level, err == nil && level >= 0 && level <= max
+}
+
+// parseSyslog parses a syslog collector to send the events to, given as
+// [tcp:|udp:][facility=F:][severity=S:][max-size=N:]host:port
+func parseSyslog(value string) (printer.SyslogConfig, error) {
+	config := printer.SyslogConfig{Network: "udp", Facility: syslogFacilities["user"], Severity: syslogSeverities["info"]}
+	invalid := fmt.Errorf("invalid syslog output: %s, expected [tcp:][facility=F:][severity=S:][max-size=N:]host:port (e.g. tcp:facility=auth:siem:514)", value)
+	address := value
+options:
+	for {
+		parts := strings.SplitN(address, ":", 2)
+		if len(parts) != 2 {
+			break
+		}
+		valid := true
+		switch option := parts[0]; {
+		case option == "tcp" || option == "udp":
+			config.Network = option
+		case strings.HasPrefix(option, "facility="):
+			config.Facility, valid = parseSyslogLevel(strings.TrimPrefix(option, "facility="), syslogFacilities, 23)
+		case strings.HasPrefix(option, "severity="):
+			config.Severity, valid = parseSyslogLevel(strings.TrimPrefix(option, "severity="), syslogSeverities, 7)
+		case strings.HasPrefix(option, "max-size="):
+			maxSize, err := strconv.Atoi(strings.TrimPrefix(option, "max-size="))
+			config.MaxMessageSize, valid = maxSize, err == nil && maxSize > 0
+		default:
+			// the options are followed by the host of the collector
+			break options
+		}
+		if !valid {
+			return config, invalid
+		}
+		address = parts[1]
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return config, invalid
+	}
+	config.Address = address
+	return config, nil
+}
+
+// parseSinkFilter parses a filter of an output of the format "output=expression" into the filters of the outputs.
+// Outputs are split from their expression at the first '=', as expressions may contain some
+func parseSinkFilter(value string, sinkFilters map[string]*filters.ExprFilter) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || strings.TrimSpace(parts[1]) == "" {
+		return fmt.Errorf("invalid sink filter: %s, expected output=expression", value)
+	}
+	filter, ok := sinkFilters[parts[0]]
+	if !ok {
+		filter = &filters.ExprFilter{}
+		sinkFilters[parts[0]] = filter
+	}
+	if err := filter.ParsePrinted(parts[1]); err != nil {
+		return fmt.Errorf("invalid sink filter of %s: %v", parts[0], err)
+	}
+	return nil
+}
+
+// checkSinkFilters checks that the filtered outputs are outputs printed by sinks, which the first out-file (the main
+// output) isn't, as its events are filtered by --trace
+func checkSinkFilters(sinkFilters map[string]*filters.ExprFilter, outFiles []outputFile, routes []outputRoute, fifos []outputFile, otlpConfigs []printer.OTLPConfig, kafkaConfigs []printer.KafkaConfig, syslogConfigs []printer.SyslogConfig) error {
+	outputs := sinkOutputs(outFiles, routes, fifos, otlpConfigs, kafkaConfigs, syslogConfigs)
+	for output := range sinkFilters {
+		if _, ok := outputs[output]; ok {
+			continue
+		}
+		if len(outFiles) > 0 && outFiles[0].path == output {
+			return fmt.Errorf("invalid sink filter of %s: the first out-file can't be filtered, filter the traced events with --trace instead", output)
+		}
+		return fmt.Errorf("invalid sink filter of %s: no such output", output)
+	}
+	return nil
+}
+
+// parseSinkPolicy parses the drop policy of an output of the format "output=policy" into the policies of the outputs
+func parseSinkPolicy(value string, sinkPolicies map[string]printer.DropPolicy) error {
+	// outputs may contain '=' (e.g. kafka retries=N:), unlike policies
+	i := strings.LastIndex(value, "=")
+	if i <= 0 {
+		return fmt.Errorf("invalid sink policy: %s, expected output=policy", value)
+	}
+	switch value[i+1:] {
+	case "block":
+		sinkPolicies[value[:i]] = printer.Block
+	case "drop-newest":
+		sinkPolicies[value[:i]] = printer.DropNewest
+	case "drop-oldest":
+		sinkPolicies[value[:i]] = printer.DropOldest
+	default:
+		return fmt.Errorf("invalid sink policy of %s: %s, expected block, drop-newest or drop-oldest", value[:i], value[i+1:])
+	}
+	return nil
+}
+
+// checkSinkPolicies checks that the outputs given a drop policy are outputs printed by sinks, which the first
+// out-file (the main output) isn't, as it never drops events
+func checkSinkPolicies(sinkPolicies map[string]printer.DropPolicy, outFiles []outputFile, routes []outputRoute, fifos []outputFile, otlpConfigs []printer.OTLPConfig, kafkaConfigs []printer.KafkaConfig, syslogConfigs []printer.SyslogConfig) error {
+	outputs := sinkOutputs(outFiles, routes, fifos, otlpConfigs, kafkaConfigs, syslogConfigs)
+	for output := range sinkPolicies {
+		if _, ok := outputs[output]; ok {
+			continue
+		}
+		if len(outFiles) > 0 && outFiles[0].path == output {
+			return fmt.Errorf("invalid sink policy of %s: the first out-file never drops events", output)
+		}
+		return fmt.Errorf("invalid sink policy of %s: no such output", output)
+	}
+	return nil
+}
+
+// sinkOutputs returns the outputs printed by sinks, as they are given by in the sink filters and policies
+func sinkOutputs(outFiles []outputFile, routes []outputRoute, fifos []outputFile, otlpConfigs []printer.OTLPConfig, kafkaConfigs []printer.KafkaConfig, syslogConfigs []printer.SyslogConfig) map[string]struct{} {
+	outputs := make(map[string]struct{})
+	for i, f := range outFiles {
+		if i > 0 {
+			outputs[f.path] = struct{}{}
+		}
+	}
+	for _, route := range routes {
+		outputs[route.outPath] = struct{}{}
+	}
+	for _, fifo := range fifos {
+		outputs[fifo.path] = struct{}{}
+	}
+	for _, config := range otlpConfigs {
+		outputs[config.Endpoint] = struct{}{}
+	}
+	for _, config := range kafkaConfigs {
+		outputs[kafkaOutput(config)] = struct{}{}
+	}
+	for _, config := range syslogConfigs {
+		outputs[config.Address] = struct{}{}
+	}
+	return outputs
+}
+
+// sinkOutput returns the output a sink is given by in the sink filters and policies
+func sinkOutput(sinkConfig printer.SinkConfig) string {
+	switch {
+	case sinkConfig.OTLP != nil:
+		return sinkConfig.OTLP.Endpoint
+	case sinkConfig.Kafka != nil:
+		return kafkaOutput(*sinkConfig.Kafka)
+	case sinkConfig.Syslog != nil:
+		return sinkConfig.Syslog.Address
+	case sinkConfig.FIFOPath != "":
+		return sinkConfig.FIFOPath
+	}
+	return sinkConfig.OutPath
+}
+
+// kafkaOutput returns the output a kafka topic is given by, as broker[,broker]/topic
+func kafkaOutput(config printer.KafkaConfig) string {
+	return strings.Join(config.Brokers, ",") + "/" + config.Topic
+}
+
+// parseFieldMap parses renamed fields of the format "name=new-name[,name=new-name]" into the given field map
+func parseFieldMap(value string, fieldMap map[string]string) error {
+	for _, mapping := range strings.Split(value, ",") {
+		names := strings.SplitN(mapping, "=", 2)
+		if len(names) != 2 || names[0] == "" || names[1] == "" {
+			return fmt.Errorf("invalid field map: %s, expected name=new-name pairs", value)
+		}
+		fieldMap[names[0]] = names[1]
+	}
+	return nil
+}
+
+// parseArgNames parses a list of argument names, given as event.arg for the arguments of an event, or as arg for
+// the arguments of all events
+func parseArgNames(value string, argNames *events.ArgNames) error {
+	eventsNameToID := events.Definitions.NamesToIDs()
+	for _, name := range strings.Split(value, ",") {
+		parts := strings.SplitN(name, ".", 2)
+		if len(parts) == 1 {
+			if name == "" {
+				return fmt.Errorf("empty argument name in %s", value)
+			}
+			argNames.Add(name)
+			continue
+		}
+		id, ok := eventsNameToID[parts[0]]
+		if !ok {
+			return fmt.Errorf("no such event: %s", parts[0])
+		}
+		if parts[1] == "" {
+			return fmt.Errorf("empty argument name of %s", parts[0])
+		}
+		argNames.AddEvent(id, parts[1])
+	}
+	return nil
+}
+
+// isOutputFormat checks if a value is a supported format of the events output
+func isOutputFormat(kind string) bool {
+	switch kind {
+	case "table", "table-verbose", "json", "ecs", "gob":
+		return true
+	}
+	return strings.HasPrefix(kind, "gotemplate=")
+}
+
+// outputFile is a file to which events are printed, in its own format if a kind or gzip is set
+type outputFile struct {
+	path string
+	kind string
+	gzip bool
+}
+
+// parseOutputFile parses an output file of the format "[format[,gzip]:]/path/to/file". A value which isn't
+// prefixed with a known format or gzip is the path of the file
+func parseOutputFile(value string) outputFile {
+	fileParts := strings.SplitN(value, ":", 2)
+	if len(fileParts) != 2 || fileParts[1] == "" {
+		return outputFile{path: value}
+	}
+	f := outputFile{path: fileParts[1]}
+	for _, option := range strings.Split(fileParts[0], ",") {
+		switch {
+		case option == "gzip":
+			f.gzip = true
+		case isOutputFormat(option) && f.kind == "":
+			f.kind = option
+		default:
+			return outputFile{path: value}
+		}
+	}
+	return f
+}
+
+// outputRoute is an output file to which only the given events are printed
+type outputRoute struct {
+	events  []events.ID
+	outPath string
+}
+
+// parseRoute parses a route of the format "event1,event2:/path/to/file". Events are given by their names or by the
+// names of the sets they belong to
+func parseRoute(route string) (outputRoute, error) {
+	routeParts := strings.SplitN(route, ":", 2)
+	if len(routeParts) != 2 || routeParts[0] == "" || routeParts[1] == "" {
+		return outputRoute{}, fmt.Errorf("invalid output route: %s, use '--output help' for more info", route)
+	}
+
+	eventsNameToID := events.Definitions.NamesToIDs()
+	setsToEvents := make(map[string][]events.ID)
+	for id, event := range events.Definitions.Events() {
+		for _, set := range event.Sets {
+			setsToEvents[set] = append(setsToEvents[set], id)
+		}
+	}
+
+	var routedEvents []events.ID
+	for _, name := range strings.Split(routeParts[0], ",") {
+		if id, ok := eventsNameToID[name]; ok {
+			routedEvents = append(routedEvents, id)
+			continue
+		}
+		setEvents, ok := setsToEvents[name]
+		if !ok {
+			return outputRoute{}, fmt.Errorf("invalid output route: %s, %s is not an event or a set", route, name)
+		}
+		routedEvents = append(routedEvents, setEvents...)
+	}
+	return outputRoute{events: routedEvents, outPath: routeParts[1]}, nil
+}
+
+// readHashDenylist reads a file of sha256 hashes, one per line. Empty lines and comments (starting with '#') are
+// ignored
+func readHashDenylist(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open hash denylist: %v", err)
+	}
+	defer f.Close()
+
+	denylist := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		hash := strings.ToLower(strings.TrimSpace(line))
+		if hash == "" {
+			continue
+		}
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != sha256.Size*2 {
+			return nil, fmt.Errorf("invalid hash denylist %s: line %d is not a sha256 hash", path, lineNum)
+		}
+		denylist[hash] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read hash denylist: %v", err)
+	}
+	return denylist, nil
+}
+
+// readNamespaceNames reads a file of names of mount namespaces, of a mntns and its name per line (separated by
+// whitespace). Empty lines and comments (starting with '#') are ignored
+func readNamespaceNames(path string) (map[uint32]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open namespace names: %v", err)
+	}
+	defer f.Close()
+
+	names := make(map[uint32]string)
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid namespace names %s: line %d is not a mntns and a name", path, lineNum)
+		}
+		mntns, err := strconv.ParseUint(fields[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace names %s: line %d has an invalid mntns %s", path, lineNum, fields[0])
+		}
+		names[uint32(mntns)] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read namespace names: %v", err)
+	}
+	return names, nil
+}
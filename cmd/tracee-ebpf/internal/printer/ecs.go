@@ -0,0 +1,248 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/metrics"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// ecsVersion is the version of the Elastic Common Schema the ECS documents follow
+const ecsVersion = "8.4.0"
+
+// ecsDocument is an event in Elastic Common Schema (ECS) format, so it can be ingested into Elasticsearch and used
+// by ECS based dashboards as is. The fields without an ECS equivalent are kept under tracee
+type ecsDocument struct {
+	Timestamp    string           `json:"@timestamp"`
+	ECS          ecsVersionInfo   `json:"ecs"`
+	Event        ecsEvent         `json:"event"`
+	Host         ecsHost          `json:"host"`
+	User         ecsUser          `json:"user"`
+	Process      ecsProcess       `json:"process"`
+	File         *ecsFile         `json:"file,omitempty"`
+	Container    *ecsContainer    `json:"container,omitempty"`
+	Orchestrator *ecsOrchestrator `json:"orchestrator,omitempty"`
+	Tracee       ecsTracee        `json:"tracee"`
+}
+
+type ecsVersionInfo struct {
+	Version string `json:"version"`
+}
+
+type ecsEvent struct {
+	Kind     string   `json:"kind"`
+	Category []string `json:"category,omitempty"`
+	Type     []string `json:"type,omitempty"`
+	Action   string   `json:"action"`
+	Code     string   `json:"code"`
+	Module   string   `json:"module"`
+}
+
+type ecsHost struct {
+	Hostname string `json:"hostname"`
+}
+
+type ecsUser struct {
+	ID string `json:"id"`
+}
+
+type ecsHash struct {
+	SHA256 string `json:"sha256"`
+}
+
+type ecsProcessThread struct {
+	ID int `json:"id"`
+}
+
+type ecsProcessParent struct {
+	PID int `json:"pid"`
+}
+
+type ecsProcess struct {
+	PID         int              `json:"pid"`
+	Name        string           `json:"name"`
+	Executable  string           `json:"executable,omitempty"`
+	Args        []string         `json:"args,omitempty"`
+	CommandLine string           `json:"command_line,omitempty"`
+	Hash        *ecsHash         `json:"hash,omitempty"`
+	Thread      ecsProcessThread `json:"thread"`
+	Parent      ecsProcessParent `json:"parent"`
+	ExitCode    *int             `json:"exit_code,omitempty"`
+}
+
+type ecsFile struct {
+	Path   string `json:"path"`
+	Name   string `json:"name"`
+	Inode  string `json:"inode,omitempty"`
+	Device string `json:"device,omitempty"`
+}
+
+type ecsContainerImage struct {
+	Name string `json:"name,omitempty"`
+}
+
+type ecsContainer struct {
+	ID    string            `json:"id"`
+	Name  string            `json:"name,omitempty"`
+	Image ecsContainerImage `json:"image"`
+}
+
+type ecsOrchestratorResource struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type ecsOrchestrator struct {
+	Namespace string                  `json:"namespace,omitempty"`
+	Resource  ecsOrchestratorResource `json:"resource"`
+}
+
+// ecsTracee holds the fields of the event which aren't in ECS
+type ecsTracee struct {
+	ProcessID      int                    `json:"processId"`
+	ThreadID       int                    `json:"threadId"`
+	ParentPID      int                    `json:"parentProcessId"`
+	MountNamespace int                    `json:"mountNamespace"`
+	PIDNamespace   int                    `json:"pidNamespace"`
+	CgroupID       uint                   `json:"cgroupId"`
+	ReturnValue    int                    `json:"returnValue"`
+	Args           map[string]interface{} `json:"args"`
+	SchemaVersion  string                 `json:"schemaVersion"`
+}
+
+// newECSDocument maps an event to its ECS document. Exec events describe the started process (its executable,
+// arguments and sha256 if hashed), and events of a file (given by their pathname argument) describe the file
+func newECSDocument(event trace.Event) ecsDocument {
+	doc := ecsDocument{
+		Timestamp: time.Unix(0, int64(event.Timestamp)).UTC().Format(time.RFC3339Nano),
+		ECS:       ecsVersionInfo{Version: ecsVersion},
+		Event: ecsEvent{
+			Kind:   "event",
+			Action: event.EventName,
+			Code:   strconv.Itoa(event.EventID),
+			Module: "tracee",
+		},
+		Host: ecsHost{Hostname: event.HostName},
+		User: ecsUser{ID: strconv.Itoa(event.UserID)},
+		Process: ecsProcess{
+			PID:    event.HostProcessID,
+			Name:   event.ProcessName,
+			Thread: ecsProcessThread{ID: event.HostThreadID},
+			Parent: ecsProcessParent{PID: event.HostParentProcessID},
+		},
+		Tracee: ecsTracee{
+			ProcessID:      event.ProcessID,
+			ThreadID:       event.ThreadID,
+			ParentPID:      event.ParentProcessID,
+			MountNamespace: event.MountNS,
+			PIDNamespace:   event.PIDNS,
+			CgroupID:       event.CgroupID,
+			ReturnValue:    event.ReturnValue,
+			Args:           make(map[string]interface{}, len(event.Args)),
+			SchemaVersion:  events.SchemaVersion,
+		},
+	}
+	for _, arg := range event.Args {
+		doc.Tracee.Args[arg.Name] = arg.Value
+	}
+
+	if event.ContainerID != "" {
+		doc.Container = &ecsContainer{
+			ID:    event.ContainerID,
+			Name:  event.ContainerName,
+			Image: ecsContainerImage{Name: event.ContainerImage},
+		}
+	}
+	if event.PodName != "" {
+		doc.Orchestrator = &ecsOrchestrator{
+			Namespace: event.PodNamespace,
+			Resource:  ecsOrchestratorResource{Type: "pod", Name: event.PodName},
+		}
+	}
+
+	switch events.ID(event.EventID) {
+	case events.SchedProcessExec:
+		doc.Event.Category = []string{"process"}
+		doc.Event.Type = []string{"start"}
+		doc.Process.Executable = ecsStringArg(event, "pathname")
+		if argv, ok := ecsArg(event, "argv").([]string); ok {
+			doc.Process.Args = argv
+		}
+		doc.Process.CommandLine = ecsStringArg(event, "cmdline")
+		if doc.Process.CommandLine == "" && len(doc.Process.Args) > 0 {
+			doc.Process.CommandLine = strings.Join(doc.Process.Args, " ")
+		}
+		if hash := ecsStringArg(event, "sha256"); hash != "" {
+			doc.Process.Hash = &ecsHash{SHA256: hash}
+		}
+	case events.SchedProcessExit:
+		doc.Event.Category = []string{"process"}
+		doc.Event.Type = []string{"end"}
+		if exitCode, ok := ecsArg(event, "exit_code").(int64); ok {
+			code := int(exitCode)
+			doc.Process.ExitCode = &code
+		}
+	default:
+		if path := ecsStringArg(event, "pathname"); path != "" {
+			doc.Event.Category = []string{"file"}
+			doc.File = &ecsFile{Path: path, Name: filepath.Base(path)}
+			if inode := ecsArg(event, "inode"); inode != nil {
+				doc.File.Inode = fmt.Sprint(inode)
+			}
+			if dev := ecsArg(event, "dev"); dev != nil {
+				doc.File.Device = fmt.Sprint(dev)
+			}
+		}
+	}
+
+	return doc
+}
+
+func ecsArg(event trace.Event, name string) interface{} {
+	for _, arg := range event.Args {
+		if arg.Name == name {
+			return arg.Value
+		}
+	}
+	return nil
+}
+
+func ecsStringArg(event trace.Event, name string) string {
+	value, _ := ecsArg(event, name).(string)
+	return value
+}
+
+// ecsEventPrinter prints events as ECS documents, one JSON document per line
+type ecsEventPrinter struct {
+	out io.WriteCloser
+	err io.WriteCloser
+}
+
+func (p ecsEventPrinter) Init() error { return nil }
+
+func (p ecsEventPrinter) Preamble() {}
+
+func (p ecsEventPrinter) Print(event trace.Event) {
+	eBytes, err := json.Marshal(newECSDocument(event))
+	if err != nil {
+		p.Error(err)
+		return
+	}
+	fmt.Fprintln(p.out, string(eBytes))
+}
+
+func (p ecsEventPrinter) Error(err error) {
+	fmt.Fprintf(p.err, "%v\n", err)
+}
+
+func (p ecsEventPrinter) Epilogue(stats metrics.Stats) {}
+
+func (p ecsEventPrinter) Close() {
+}
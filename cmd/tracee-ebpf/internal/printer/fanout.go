@@ -0,0 +1,290 @@
+package printer
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/filters"
+	"github.com/aquasecurity/tracee/pkg/metrics"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// defaultSinkBufferSize is the number of events queued for a sink, if not configured otherwise
+const defaultSinkBufferSize = 1024
+
+// DropPolicy defines what happens to events dispatched to a sink whose queue is full
+type DropPolicy int
+
+const (
+	// DropNewest drops the events a sink has no room for, so a slow or failing sink doesn't hold back the others
+	DropNewest DropPolicy = iota
+	// Block waits for room in the sink's queue, so no event is dropped but a slow sink holds back the others
+	Block
+	// DropOldest drops the oldest events queued for a sink to make room for the new ones, so a slow sink prints the
+	// most recent events once it catches up. The rotations queued for the sink are never dropped
+	DropOldest
+)
+
+// SinkConfig is an additional destination of the printed events
+type SinkConfig struct {
+	OutPath string
+	OutFile io.WriteCloser
+	// OutWriter is the output of the sink when OutFile isn't set, which is never closed by the printer
+	OutWriter io.Writer
+	// BufferSize is the number of events queued for the sink (default: 1024)
+	BufferSize int
+	DropPolicy DropPolicy
+	// Kind is the format of the sink's output, and Gzip compresses it. A sink without a kind is printed in the
+	// format (and compression) of the main output
+	Kind string
+	Gzip bool
+	// OTLP exports the events of the sink to an OpenTelemetry collector, instead of printing them to a file
+	OTLP *OTLPConfig
+	// Kafka publishes the events of the sink to a kafka topic, instead of printing them to a file
+	Kafka *KafkaConfig
+	// Syslog sends the events of the sink to a syslog collector, instead of printing them to a file
+	Syslog *SyslogConfig
+	// FIFOPath prints the events of the sink to the named pipe at the path (created if it doesn't exist), instead
+	// of a file. Events are dropped while the pipe has no reader. The output isn't compressed, and the gob format
+	// isn't supported, as readers attached later couldn't decode it
+	FIFOPath string
+	// Events routes the given events to the sink only. Sinks without routed events are the default sinks, which
+	// receive all the events not routed to any sink
+	Events []events.ID
+	// Filter prints only the events matching its expressions to the sink, evaluated on the events as printed (i.e.
+	// enriched). Events routed to the sink and filtered out aren't printed by the default sinks either
+	Filter *filters.ExprFilter
+}
+
+// sink is an events printer fed through its own queue
+type sink struct {
+	name    string
+	printer EventPrinter
+	policy  DropPolicy
+	queue   chan sinkMessage
+	dropped int                    // the events dropped by the policy of the sink
+	events  map[events.ID]struct{} // the events routed to the sink, or nil for a default sink
+	filter  *filters.ExprFilter    // the events printed by the sink, or nil for all the events
+}
+
+// accepts checks if an event should be printed by the sink, given the events routed to any sink
+func (s *sink) accepts(eventID events.ID, routed map[events.ID]struct{}) bool {
+	if s.events == nil {
+		_, isRouted := routed[eventID]
+		return !isRouted
+	}
+	_, isRoutedHere := s.events[eventID]
+	return isRoutedHere
+}
+
+// matches checks if an event accepted by the sink passes its filter
+func (s *sink) matches(event trace.Event) bool {
+	return s.filter == nil || !s.filter.Enabled || s.filter.FilterEvent(event)
+}
+
+// dropOldestEvent drops the oldest event queued for the sink, while its other messages (e.g. a rotation queued
+// before it) are kept in the order they were queued. It returns false if no event is queued. It's called while the
+// fanout printer is locked, so no messages are queued meanwhile
+func (s *sink) dropOldestEvent() bool {
+	var kept []sinkMessage
+	dropped := false
+	for !dropped {
+		select {
+		case oldest := <-s.queue:
+			if oldest.rotate == nil && oldest.err == nil {
+				dropped = true
+			} else {
+				kept = append(kept, oldest)
+			}
+		default:
+			// no events are queued, or they were printed meanwhile
+			for _, msg := range kept {
+				s.queue <- msg
+			}
+			return false
+		}
+	}
+	if len(kept) == 0 {
+		return true
+	}
+	// the messages queued before the dropped event are queued again before the ones queued after it
+	for len(s.queue) > 0 {
+		select {
+		case msg := <-s.queue:
+			kept = append(kept, msg)
+		default:
+		}
+	}
+	for _, msg := range kept {
+		s.queue <- msg
+	}
+	return true
+}
+
+type sinkMessage struct {
+	event  trace.Event
+	err    error
+	rotate chan error // set to rotate the output of the sink, receiving the result of the rotation
+}
+
+// fanoutEventPrinter dispatches the events to a list of sinks, each printing the events in its own goroutine.
+// Events routed to sinks are printed by those sinks only, and all other events by the default sinks
+type fanoutEventPrinter struct {
+	mu     sync.Mutex
+	sinks  []*sink
+	routed map[events.ID]struct{} // the events routed to any sink
+	wg     sync.WaitGroup
+	closed bool
+}
+
+func newFanoutEventPrinter(sinks []*sink) *fanoutEventPrinter {
+	p := &fanoutEventPrinter{sinks: sinks, routed: make(map[events.ID]struct{})}
+	for _, s := range sinks {
+		for eventID := range s.events {
+			p.routed[eventID] = struct{}{}
+		}
+	}
+	for _, s := range sinks {
+		p.wg.Add(1)
+		go func(s *sink) {
+			defer p.wg.Done()
+			for msg := range s.queue {
+				if msg.rotate != nil {
+					msg.rotate <- s.printer.(Rotator).RotateOutput()
+				} else if msg.err != nil {
+					s.printer.Error(msg.err)
+				} else {
+					s.printer.Print(msg.event)
+				}
+			}
+		}(s)
+	}
+	return p
+}
+
+func (p *fanoutEventPrinter) Init() error { return nil }
+
+func (p *fanoutEventPrinter) Preamble() {
+	for _, s := range p.sinks {
+		s.printer.Preamble()
+	}
+}
+
+func (p *fanoutEventPrinter) Print(event trace.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	eventID := events.ID(event.EventID)
+	for _, s := range p.sinks {
+		if s.accepts(eventID, p.routed) && s.matches(event) {
+			p.dispatch(s, sinkMessage{event: event})
+		}
+	}
+}
+
+// Error prints the error once, by the first sink, since sinks usually share the errors output
+func (p *fanoutEventPrinter) Error(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.dispatch(p.sinks[0], sinkMessage{err: err})
+}
+
+func (p *fanoutEventPrinter) dispatch(s *sink, msg sinkMessage) {
+	switch s.policy {
+	case Block:
+		s.queue <- msg
+		return
+	case DropOldest:
+		select {
+		case s.queue <- msg:
+			return
+		default:
+		}
+		// messages are only queued while locked, so there's room once the oldest event is dropped (or once messages
+		// were printed meanwhile). The new message is dropped if only rotations and errors are queued
+		if s.dropOldestEvent() {
+			s.dropped++
+		}
+		select {
+		case s.queue <- msg:
+		default:
+			s.dropped++
+		}
+		return
+	}
+	select {
+	case s.queue <- msg:
+	default:
+		s.dropped++
+	}
+}
+
+// RotateOutput rotates the outputs of the sinks whose output files can be rotated, once they printed the events
+// queued before the rotation, so those events are printed to the rotated files
+func (p *fanoutEventPrinter) RotateOutput() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	var results []chan error
+	for _, s := range p.sinks {
+		if _, ok := s.printer.(Rotator); !ok {
+			continue
+		}
+		result := make(chan error, 1)
+		// the rotation is never dropped, whatever the policy of the sink
+		s.queue <- sinkMessage{rotate: result}
+		results = append(results, result)
+	}
+	p.mu.Unlock()
+
+	var err error
+	for _, result := range results {
+		if rotateErr := <-result; rotateErr != nil && err == nil {
+			err = rotateErr
+		}
+	}
+	return err
+}
+
+// drain stops dispatching events and waits for the sinks to print the queued ones
+func (p *fanoutEventPrinter) drain() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		for _, s := range p.sinks {
+			close(s.queue)
+		}
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// Epilogue waits for the sinks to print the queued events, and reports the events dropped by each sink
+func (p *fanoutEventPrinter) Epilogue(stats metrics.Stats) {
+	p.drain()
+
+	for _, s := range p.sinks {
+		if s.dropped > 0 {
+			p.sinks[0].printer.Error(fmt.Errorf("output %s is too slow, %d events were dropped", s.name, s.dropped))
+		}
+	}
+	for _, s := range p.sinks {
+		s.printer.Epilogue(stats)
+	}
+}
+
+func (p *fanoutEventPrinter) Close() {
+	p.drain()
+	for _, s := range p.sinks {
+		s.printer.Close()
+	}
+}
@@ -0,0 +1,100 @@
+package printer
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/metrics"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingPrinter records the events it prints and its rotations, blocking once it started printing until it's
+// released
+type recordingPrinter struct {
+	mu          sync.Mutex
+	printed     []string
+	printing    chan struct{}
+	printingOne sync.Once
+	release     chan struct{}
+}
+
+func (p *recordingPrinter) record(s string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.printed = append(p.printed, s)
+}
+
+func (p *recordingPrinter) Init() error                  { return nil }
+func (p *recordingPrinter) Preamble()                    {}
+func (p *recordingPrinter) Epilogue(stats metrics.Stats) {}
+func (p *recordingPrinter) Error(err error)              { p.record(err.Error()) }
+func (p *recordingPrinter) Close()                       {}
+
+func (p *recordingPrinter) Print(event trace.Event) {
+	p.printingOne.Do(func() { close(p.printing) })
+	<-p.release
+	p.record(fmt.Sprint(event.Timestamp))
+}
+
+func (p *recordingPrinter) RotateOutput() error {
+	p.record("rotate")
+	return nil
+}
+
+func TestFanoutDropOldest_rotation(t *testing.T) {
+	const bufferSize = 4
+	recorder := &recordingPrinter{printing: make(chan struct{}), release: make(chan struct{})}
+	s := &sink{name: "slow", printer: recorder, policy: DropOldest, queue: make(chan sinkMessage, bufferSize)}
+	p := newFanoutEventPrinter([]*sink{s})
+
+	p.Print(trace.Event{Timestamp: 0})
+	<-recorder.printing
+	p.Print(trace.Event{Timestamp: 1})
+	p.Print(trace.Event{Timestamp: 2})
+	rotated := make(chan error)
+	go func() { rotated <- p.RotateOutput() }()
+	assert.Eventually(t, func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return len(s.queue) == 3
+	}, time.Second, time.Millisecond)
+
+	// the oldest events are dropped, while the rotation queued before them stays in place
+	for i := 3; i < 7; i++ {
+		p.Print(trace.Event{Timestamp: i})
+	}
+	close(recorder.release)
+	assert.NoError(t, <-rotated)
+	p.drain()
+
+	assert.Equal(t, []string{"0", "rotate", "4", "5", "6"}, recorder.printed)
+	assert.Equal(t, 3, s.dropped)
+}
+
+func TestFanoutDropOldest_noEvents(t *testing.T) {
+	recorder := &recordingPrinter{printing: make(chan struct{}), release: make(chan struct{})}
+	s := &sink{name: "slow", printer: recorder, policy: DropOldest, queue: make(chan sinkMessage, 1)}
+	p := newFanoutEventPrinter([]*sink{s})
+
+	p.Print(trace.Event{Timestamp: 0})
+	<-recorder.printing
+	rotated := make(chan error)
+	go func() { rotated <- p.RotateOutput() }()
+	assert.Eventually(t, func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return len(s.queue) == 1
+	}, time.Second, time.Millisecond)
+
+	// only a rotation is queued, so the new event is dropped instead
+	p.Print(trace.Event{Timestamp: 1})
+	close(recorder.release)
+	assert.NoError(t, <-rotated)
+	p.drain()
+
+	assert.Equal(t, []string{"0", "rotate"}, recorder.printed)
+	assert.Equal(t, 1, s.dropped)
+}
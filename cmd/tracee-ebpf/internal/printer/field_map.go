@@ -0,0 +1,60 @@
+package printer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// renameArgs returns the arguments with the names mapped by the given field map renamed. The arguments are copied,
+// as they may be shared with other outputs
+func renameArgs(args []trace.Argument, fieldMap map[string]string) []trace.Argument {
+	renamed := make([]trace.Argument, len(args))
+	copy(renamed, args)
+	for i := range renamed {
+		if name, ok := fieldMap[renamed[i].Name]; ok {
+			renamed[i].Name = name
+		}
+	}
+	return renamed
+}
+
+// renameFields renames the top level fields of a json object mapped by the given field map, keeping the order of
+// the fields. Unmapped fields are kept as they are
+func renameFields(object []byte, fieldMap map[string]string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(object))
+	if token, err := dec.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("failed renaming fields: not a json object")
+	}
+
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed renaming fields: %v", err)
+		}
+		field, _ := token.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, fmt.Errorf("failed renaming fields: %v", err)
+		}
+		if mapped, ok := fieldMap[field]; ok {
+			field = mapped
+		}
+		name, err := json.Marshal(field)
+		if err != nil {
+			return nil, fmt.Errorf("failed renaming fields: %v", err)
+		}
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,164 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/aquasecurity/tracee/pkg/metrics"
+	"github.com/aquasecurity/tracee/types/trace"
+	"golang.org/x/sys/unix"
+)
+
+// FIFOError is an error of an output to a named pipe, as opposed to the errors of regular output files
+type FIFOError struct {
+	Path string
+	Err  error
+}
+
+func (e *FIFOError) Error() string {
+	return fmt.Sprintf("fifo output %s: %v", e.Path, e.Err)
+}
+
+func (e *FIFOError) Unwrap() error {
+	return e.Err
+}
+
+// fifoWriter writes to a named pipe while it has a reader. The pipe is opened without blocking, so a missing reader
+// never blocks printing, and opened again once a reader is attached after the previous one left
+type fifoWriter struct {
+	path string
+	file *os.File // nil while the pipe has no reader
+}
+
+// newFIFOWriter creates a writer to the named pipe at the given path, creating the pipe if it doesn't exist
+func newFIFOWriter(path string) (*fifoWriter, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		if err := unix.Mkfifo(path, 0600); err != nil {
+			return nil, &FIFOError{Path: path, Err: err}
+		}
+	} else if err != nil {
+		return nil, &FIFOError{Path: path, Err: err}
+	} else if info.Mode()&os.ModeNamedPipe == 0 {
+		return nil, &FIFOError{Path: path, Err: errors.New("not a named pipe")}
+	}
+	return &fifoWriter{path: path}, nil
+}
+
+// connect opens the pipe if it isn't open yet, returning false if it has no reader
+func (w *fifoWriter) connect() (bool, error) {
+	if w.file != nil {
+		return true, nil
+	}
+	fd, err := unix.Open(w.path, unix.O_WRONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err == unix.ENXIO {
+		return false, nil
+	}
+	if err != nil {
+		return false, &FIFOError{Path: w.path, Err: err}
+	}
+	// once a reader is attached writes wait for it, so events aren't partially written to a full pipe
+	if err := unix.SetNonblock(fd, false); err != nil {
+		unix.Close(fd)
+		return false, &FIFOError{Path: w.path, Err: err}
+	}
+	w.file = os.NewFile(uintptr(fd), w.path)
+	return true, nil
+}
+
+// connected checks if the pipe has a reader, as of the last write
+func (w *fifoWriter) connected() bool {
+	return w.file != nil
+}
+
+// Write writes to the pipe, dropping the output while there's no reader
+func (w *fifoWriter) Write(p []byte) (int, error) {
+	if w.file == nil {
+		return len(p), nil
+	}
+	n, err := w.file.Write(p)
+	if errors.Is(err, syscall.EPIPE) {
+		// the reader left
+		w.file.Close()
+		w.file = nil
+		return len(p), nil
+	}
+	if err != nil {
+		return n, &FIFOError{Path: w.path, Err: err}
+	}
+	return n, nil
+}
+
+func (w *fifoWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// fifoEventPrinter is an EventPrinter whose output is a named pipe. Events printed while the pipe has no reader are
+// dropped and counted, and the count is reported on exit
+type fifoEventPrinter struct {
+	EventPrinter
+	out     *fifoWriter
+	dropped int
+	failing bool
+}
+
+// newFIFOEventPrinter creates a printer of events to the named pipe at the given path, in the format of the given
+// config
+func newFIFOEventPrinter(path string, config Config) (EventPrinter, error) {
+	if config.Kind == "gob" {
+		return nil, &FIFOError{Path: path, Err: errors.New("the gob format isn't supported")}
+	}
+	out, err := newFIFOWriter(path)
+	if err != nil {
+		return nil, err
+	}
+	config.OutFile = out
+	config.Gzip = false
+	p, err := newEventPrinter(config)
+	if err != nil {
+		return nil, err
+	}
+	return &fifoEventPrinter{EventPrinter: p, out: out}, nil
+}
+
+func (p *fifoEventPrinter) Print(event trace.Event) {
+	connected, err := p.out.connect()
+	if err != nil {
+		// the error is reported once until the pipe can be opened again
+		if !p.failing {
+			p.Error(err)
+		}
+		p.failing = true
+	} else {
+		p.failing = false
+	}
+	if !connected {
+		p.dropped++
+		return
+	}
+	p.EventPrinter.Print(event)
+	if !p.out.connected() {
+		p.dropped++
+	}
+}
+
+func (p *fifoEventPrinter) Epilogue(stats metrics.Stats) {
+	if p.dropped > 0 {
+		p.Error(&FIFOError{Path: p.out.path, Err: fmt.Errorf("no reader, %d events were dropped", p.dropped)})
+	}
+	p.EventPrinter.Epilogue(stats)
+}
+
+func (p *fifoEventPrinter) Close() {
+	p.EventPrinter.Close()
+	if err := p.out.Close(); err != nil {
+		p.Error(&FIFOError{Path: p.out.path, Err: err})
+	}
+}
@@ -0,0 +1,98 @@
+package printer
+
+import (
+	"compress/gzip"
+	"io"
+	"sync"
+	"time"
+)
+
+// defaultGzipFlushInterval is the interval in which compressed output is flushed, if not configured otherwise
+const defaultGzipFlushInterval = time.Second
+
+// gzipWriter compresses the output written to an underlying writer. Compressed data is flushed periodically, so
+// consumers can make progress and little is lost on a crash. Close must be called to write the gzip trailer.
+type gzipWriter struct {
+	mu    sync.Mutex
+	out   io.WriteCloser
+	gz    *gzip.Writer
+	flush *time.Ticker
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func newGzipWriter(out io.WriteCloser, flushInterval time.Duration) *gzipWriter {
+	if flushInterval <= 0 {
+		flushInterval = defaultGzipFlushInterval
+	}
+	w := &gzipWriter{
+		out:   out,
+		gz:    gzip.NewWriter(out),
+		flush: time.NewTicker(flushInterval),
+		done:  make(chan struct{}),
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		for {
+			select {
+			case <-w.flush.C:
+				w.mu.Lock()
+				w.gz.Flush()
+				w.mu.Unlock()
+			case <-w.done:
+				return
+			}
+		}
+	}()
+	return w
+}
+
+func (w *gzipWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.gz.Write(p)
+}
+
+// restart ends the compressed stream of the output, and starts a new stream once the output was rotated by the
+// given function. If it failed, the new stream follows the ended one in the same output, as concatenated gzip
+// streams are still valid
+func (w *gzipWriter) restart(rotate func() error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	closeErr := w.gz.Close()
+	err := rotate()
+	w.gz.Reset(w.out)
+	if closeErr != nil {
+		return closeErr
+	}
+	return err
+}
+
+// Close stops the periodic flush, writes the gzip trailer and closes the underlying writer
+func (w *gzipWriter) Close() error {
+	w.flush.Stop()
+	close(w.done)
+	w.wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.gz.Close(); err != nil {
+		w.out.Close()
+		return err
+	}
+	return w.out.Close()
+}
+
+// gzipEventPrinter is an EventPrinter whose output is compressed
+type gzipEventPrinter struct {
+	EventPrinter
+	out *gzipWriter
+}
+
+func (p *gzipEventPrinter) Close() {
+	p.EventPrinter.Close()
+	if err := p.out.Close(); err != nil {
+		p.Error(err)
+	}
+}
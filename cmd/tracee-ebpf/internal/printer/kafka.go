@@ -0,0 +1,263 @@
+package printer
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/metrics"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+const (
+	// defaultKafkaBatchSize is the number of events published in a batch, if not configured otherwise
+	defaultKafkaBatchSize = 256
+	// defaultKafkaFlushInterval is the longest time events wait for their batch to fill, if not configured otherwise
+	defaultKafkaFlushInterval = time.Second
+	// kafkaPendingBatches is the number of batches waiting to be published. Batches beyond it are dropped, so slow
+	// (or retried) deliveries don't hold back the events pipeline
+	kafkaPendingBatches = 8
+	// kafkaRetryBackoff is the time before the first retry of a failed batch, doubled on every following retry
+	kafkaRetryBackoff = 100 * time.Millisecond
+)
+
+// The keys events are partitioned by. Events of the same key are published to the same partition, and so are
+// consumed in order
+const (
+	// KafkaPartitionByContainer keys the events by their container id, and the events of the host by their pid
+	KafkaPartitionByContainer = "container"
+	// KafkaPartitionByPID keys the events by their (host) pid
+	KafkaPartitionByPID = "pid"
+)
+
+// KafkaMessage is an event encoded for publishing to kafka
+type KafkaMessage struct {
+	Key   []byte
+	Value []byte
+}
+
+// KafkaProducer publishes messages to kafka. Messages of the same key must be published to the same partition, in
+// their order
+type KafkaProducer interface {
+	// Produce publishes a batch of messages to the topic, returning once they were delivered, or failed to be
+	Produce(topic string, messages []KafkaMessage) error
+	Close() error
+}
+
+// KafkaConfig configures publishing the events to a kafka topic, json encoded
+type KafkaConfig struct {
+	// Brokers are the addresses of the kafka brokers to bootstrap from (e.g. kafka:9092)
+	Brokers []string
+	Topic   string
+	// PartitionBy is the key of the events, KafkaPartitionByContainer (default) or KafkaPartitionByPID
+	PartitionBy string
+	// BatchSize is the number of events published in a batch (default: 256)
+	BatchSize int
+	// FlushInterval is the longest time events wait for their batch to fill (default: 1 second)
+	FlushInterval time.Duration
+	// Retries is the number of times a batch which failed to be delivered is published again. Retried batches may
+	// be delivered more than once
+	Retries int
+	// Stats counts the delivered and failed events, if set
+	Stats *metrics.Stats
+	// Producer publishes the events. Without it, a producer of the brokers is created, which requires tracee-ebpf
+	// to be built with the kafka build tag
+	Producer KafkaProducer
+}
+
+// newKafkaProducer creates a producer publishing to the given brokers, when built with the kafka build tag
+var newKafkaProducer func(brokers []string) (KafkaProducer, error)
+
+// kafkaEventPrinter publishes events to kafka in batches. Batches are published by a goroutine of their own, and
+// dropped when kafka can't keep up, so printing an event never blocks on delivering it
+type kafkaEventPrinter struct {
+	config   KafkaConfig
+	producer KafkaProducer
+	fieldMap map[string]string
+	err      io.WriteCloser
+
+	mu       sync.Mutex
+	messages []KafkaMessage
+	dropped  int
+	closed   bool
+
+	batches   chan []KafkaMessage
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	failing   bool // only accessed by the publishing goroutine
+	failed    int  // only accessed by the publishing goroutine
+}
+
+func newKafkaEventPrinter(config KafkaConfig, printerConfig Config) (*kafkaEventPrinter, error) {
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultKafkaBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultKafkaFlushInterval
+	}
+	if config.PartitionBy == "" {
+		config.PartitionBy = KafkaPartitionByContainer
+	}
+	if config.PartitionBy != KafkaPartitionByContainer && config.PartitionBy != KafkaPartitionByPID {
+		return nil, fmt.Errorf("invalid kafka partition key: %s", config.PartitionBy)
+	}
+	producer := config.Producer
+	if producer == nil {
+		if newKafkaProducer == nil {
+			return nil, fmt.Errorf("the kafka output isn't supported by this build of tracee-ebpf, build it with KAFKA=1")
+		}
+		var err error
+		if producer, err = newKafkaProducer(config.Brokers); err != nil {
+			return nil, fmt.Errorf("failed creating kafka producer: %v", err)
+		}
+	}
+	return &kafkaEventPrinter{
+		config:   config,
+		producer: producer,
+		fieldMap: printerConfig.FieldMap,
+		err:      printerConfig.ErrFile,
+		batches:  make(chan []KafkaMessage, kafkaPendingBatches),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+func (p *kafkaEventPrinter) Init() error {
+	p.wg.Add(2)
+	go func() {
+		defer p.wg.Done()
+		for batch := range p.batches {
+			p.publish(batch)
+		}
+	}()
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.config.FlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+				p.mu.Lock()
+				p.flush()
+				p.mu.Unlock()
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *kafkaEventPrinter) Preamble() {}
+
+func (p *kafkaEventPrinter) Print(event trace.Event) {
+	value, err := encodeJSON(event, p.fieldMap)
+	if err != nil {
+		p.Error(err)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.messages = append(p.messages, KafkaMessage{Key: p.key(event), Value: value})
+	if len(p.messages) >= p.config.BatchSize {
+		p.flush()
+	}
+}
+
+// key returns the partition key of an event
+func (p *kafkaEventPrinter) key(event trace.Event) []byte {
+	if p.config.PartitionBy == KafkaPartitionByContainer && event.ContainerID != "" {
+		return []byte(event.ContainerID)
+	}
+	return []byte(strconv.Itoa(event.HostProcessID))
+}
+
+// flush hands the pending messages off to the publishing goroutine, or drops them if too many batches are pending.
+// Must be called with the mutex held
+func (p *kafkaEventPrinter) flush() {
+	if p.closed || len(p.messages) == 0 {
+		return
+	}
+	batch := p.messages
+	p.messages = nil
+	select {
+	case p.batches <- batch:
+	default:
+		p.dropped += len(batch)
+		if p.config.Stats != nil {
+			p.config.Stats.KafkaFailedCount.Increment(len(batch))
+		}
+	}
+}
+
+// publish delivers a batch to kafka, retrying it on failures. Retries are backed off until the printer is closed,
+// and then retried right away. Only the first failure of consecutive failing batches is reported
+func (p *kafkaEventPrinter) publish(batch []KafkaMessage) {
+	backoff := kafkaRetryBackoff
+	err := p.producer.Produce(p.config.Topic, batch)
+	for retry := 0; err != nil && retry < p.config.Retries; retry++ {
+		select {
+		case <-p.done:
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+		err = p.producer.Produce(p.config.Topic, batch)
+	}
+	if err != nil {
+		p.failed += len(batch)
+		if p.config.Stats != nil {
+			p.config.Stats.KafkaFailedCount.Increment(len(batch))
+		}
+		if !p.failing {
+			p.Error(fmt.Errorf("failed publishing events to kafka topic %s: %v", p.config.Topic, err))
+		}
+		p.failing = true
+		return
+	}
+	if p.config.Stats != nil {
+		p.config.Stats.KafkaDeliveredCount.Increment(len(batch))
+	}
+	p.failing = false
+}
+
+func (p *kafkaEventPrinter) Error(err error) {
+	fmt.Fprintf(p.err, "%v\n", err)
+}
+
+func (p *kafkaEventPrinter) Epilogue(stats metrics.Stats) {}
+
+// Close publishes the pending events, and reports the events which couldn't be published
+func (p *kafkaEventPrinter) Close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		p.mu.Lock()
+		p.flush()
+		p.closed = true
+		dropped := p.dropped
+		p.mu.Unlock()
+		close(p.batches)
+		p.wg.Wait()
+
+		if dropped > 0 {
+			p.Error(fmt.Errorf("kafka topic %s is too slow, %d events were dropped", p.config.Topic, dropped))
+		}
+		if p.failed > 0 {
+			p.Error(fmt.Errorf("failed publishing %d events to kafka topic %s", p.failed, p.config.Topic))
+		}
+		if err := p.producer.Close(); err != nil {
+			p.Error(fmt.Errorf("failed closing kafka producer: %v", err))
+		}
+	})
+}
+
+// kafkaSinkName is the name of a sink publishing to kafka
+func kafkaSinkName(config KafkaConfig) string {
+	return fmt.Sprintf("kafka:%s/%s", strings.Join(config.Brokers, ","), config.Topic)
+}
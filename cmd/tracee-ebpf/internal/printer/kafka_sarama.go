@@ -0,0 +1,49 @@
+//go:build kafka
+// +build kafka
+
+package printer
+
+import (
+	"github.com/Shopify/sarama"
+)
+
+func init() {
+	newKafkaProducer = newSaramaProducer
+}
+
+// saramaProducer is a KafkaProducer of the sarama client. Batches are retried by the kafka printer, so the client
+// doesn't retry them on its own
+type saramaProducer struct {
+	producer sarama.SyncProducer
+}
+
+func newSaramaProducer(brokers []string) (KafkaProducer, error) {
+	config := sarama.NewConfig()
+	config.ClientID = "tracee"
+	config.Producer.RequiredAcks = sarama.WaitForLocal
+	config.Producer.Return.Successes = true
+	config.Producer.Retry.Max = 0
+	// the hash partitioner keeps the messages of the same key in the same partition
+	config.Producer.Partitioner = sarama.NewHashPartitioner
+	producer, err := sarama.NewSyncProducer(brokers, config)
+	if err != nil {
+		return nil, err
+	}
+	return &saramaProducer{producer: producer}, nil
+}
+
+func (p *saramaProducer) Produce(topic string, messages []KafkaMessage) error {
+	producerMessages := make([]*sarama.ProducerMessage, 0, len(messages))
+	for _, message := range messages {
+		producerMessages = append(producerMessages, &sarama.ProducerMessage{
+			Topic: topic,
+			Key:   sarama.ByteEncoder(message.Key),
+			Value: sarama.ByteEncoder(message.Value),
+		})
+	}
+	return p.producer.SendMessages(producerMessages)
+}
+
+func (p *saramaProducer) Close() error {
+	return p.producer.Close()
+}
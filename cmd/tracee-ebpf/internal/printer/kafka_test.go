@@ -0,0 +1,168 @@
+package printer_test
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/cmd/tracee-ebpf/internal/printer"
+	"github.com/aquasecurity/tracee/pkg/metrics"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockProducer is a fake kafka producer, recording the batches it's given
+type mockProducer struct {
+	mu      sync.Mutex
+	topics  []string
+	batches [][]printer.KafkaMessage
+	// failures is the number of batches failed before batches are delivered
+	failures int
+	attempts int
+	closed   bool
+}
+
+func (p *mockProducer) Produce(topic string, messages []printer.KafkaMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.attempts++
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("kafka: broker not available")
+	}
+	p.topics = append(p.topics, topic)
+	p.batches = append(p.batches, messages)
+	return nil
+}
+
+func (p *mockProducer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+func newKafkaPrinter(t *testing.T, config printer.KafkaConfig, errOut *syncBuffer) printer.EventPrinter {
+	p, err := printer.New(printer.Config{
+		Kind:    "ignore",
+		OutFile: &syncBuffer{},
+		ErrFile: errOut,
+		Sinks:   []printer.SinkConfig{{Kafka: &config, DropPolicy: printer.Block}},
+	})
+	require.NoError(t, err)
+	return p
+}
+
+func TestKafkaPublish(t *testing.T) {
+	producer := &mockProducer{}
+	stats := &metrics.Stats{}
+	errOut := &syncBuffer{}
+	p := newKafkaPrinter(t, printer.KafkaConfig{
+		Topic:         "tracee",
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		Stats:         stats,
+		Producer:      producer,
+	}, errOut)
+
+	p.Print(trace.Event{EventName: "execve", HostProcessID: 42, ContainerID: "ab356bc4dd554"})
+	p.Print(trace.Event{EventName: "openat", HostProcessID: 43})
+	p.Print(trace.Event{EventName: "close", HostProcessID: 42, ContainerID: "ab356bc4dd554"})
+	p.Close()
+	assert.Empty(t, errOut.String())
+
+	producer.mu.Lock()
+	defer producer.mu.Unlock()
+	assert.True(t, producer.closed)
+	assert.Equal(t, []string{"tracee", "tracee"}, producer.topics)
+	// the last batch is published as the printer is closed
+	require.Len(t, producer.batches, 2)
+	require.Len(t, producer.batches[0], 2)
+	require.Len(t, producer.batches[1], 1)
+
+	// container events are keyed by their container, and host events by their pid
+	keys := []string{}
+	names := []string{}
+	for _, batch := range producer.batches {
+		for _, message := range batch {
+			keys = append(keys, string(message.Key))
+			var event trace.Event
+			require.NoError(t, json.Unmarshal(message.Value, &event))
+			names = append(names, event.EventName)
+		}
+	}
+	assert.Equal(t, []string{"ab356bc4dd554", "43", "ab356bc4dd554"}, keys)
+	assert.Equal(t, []string{"execve", "openat", "close"}, names)
+	assert.Equal(t, int32(3), stats.KafkaDeliveredCount.Read())
+	assert.Equal(t, int32(0), stats.KafkaFailedCount.Read())
+}
+
+func TestKafkaPartitionByPID(t *testing.T) {
+	producer := &mockProducer{}
+	p := newKafkaPrinter(t, printer.KafkaConfig{
+		Topic:       "tracee",
+		PartitionBy: printer.KafkaPartitionByPID,
+		Producer:    producer,
+	}, &syncBuffer{})
+
+	p.Print(trace.Event{EventName: "execve", HostProcessID: 42, ContainerID: "ab356bc4dd554"})
+	p.Print(trace.Event{EventName: "execve", HostProcessID: 43, ContainerID: "ab356bc4dd554"})
+	p.Close()
+
+	producer.mu.Lock()
+	defer producer.mu.Unlock()
+	require.Len(t, producer.batches, 1)
+	require.Len(t, producer.batches[0], 2)
+	assert.Equal(t, "42", string(producer.batches[0][0].Key))
+	assert.Equal(t, "43", string(producer.batches[0][1].Key))
+}
+
+func TestKafkaRetries(t *testing.T) {
+	t.Run("delivered", func(t *testing.T) {
+		producer := &mockProducer{failures: 2}
+		stats := &metrics.Stats{}
+		errOut := &syncBuffer{}
+		p := newKafkaPrinter(t, printer.KafkaConfig{Topic: "tracee", Retries: 2, Stats: stats, Producer: producer}, errOut)
+		p.Print(trace.Event{EventName: "execve", HostProcessID: 42})
+		p.Close()
+
+		assert.Empty(t, errOut.String())
+		assert.Equal(t, 3, producer.attempts)
+		assert.Len(t, producer.batches, 1)
+		assert.Equal(t, int32(1), stats.KafkaDeliveredCount.Read())
+		assert.Equal(t, int32(0), stats.KafkaFailedCount.Read())
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		producer := &mockProducer{failures: 2}
+		stats := &metrics.Stats{}
+		errOut := &syncBuffer{}
+		p := newKafkaPrinter(t, printer.KafkaConfig{Topic: "tracee", Retries: 1, Stats: stats, Producer: producer}, errOut)
+		p.Print(trace.Event{EventName: "execve", HostProcessID: 42})
+		p.Print(trace.Event{EventName: "openat", HostProcessID: 42})
+		p.Close()
+
+		assert.Equal(t, 2, producer.attempts)
+		assert.Empty(t, producer.batches)
+		assert.Equal(t, int32(0), stats.KafkaDeliveredCount.Read())
+		assert.Equal(t, int32(2), stats.KafkaFailedCount.Read())
+		assert.Contains(t, errOut.String(), "failed publishing events to kafka topic tracee: kafka: broker not available")
+		assert.Contains(t, errOut.String(), "failed publishing 2 events to kafka topic tracee")
+	})
+}
+
+func TestKafkaConfig(t *testing.T) {
+	_, err := printer.New(printer.Config{
+		Kind:    "ignore",
+		OutFile: &syncBuffer{},
+		ErrFile: &syncBuffer{},
+		Sinks: []printer.SinkConfig{{
+			Kafka:      &printer.KafkaConfig{Topic: "tracee", PartitionBy: "thread", Producer: &mockProducer{}},
+			DropPolicy: printer.Block,
+		}},
+	})
+	assert.EqualError(t, err, "invalid kafka partition key: thread")
+}
@@ -0,0 +1,520 @@
+package printer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/metrics"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+const (
+	// defaultOTLPBatchSize is the number of log records exported in a request, if not configured otherwise
+	defaultOTLPBatchSize = 512
+	// defaultOTLPFlushInterval is the longest time records wait for their batch to fill, if not configured otherwise
+	defaultOTLPFlushInterval = time.Second
+	// otlpPendingBatches is the number of batches waiting to be exported. Batches beyond it are dropped, so a slow
+	// collector doesn't hold back the events pipeline
+	otlpPendingBatches = 8
+	// otlpMaxProcessSpans bounds the number of processes whose span is open, as processes whose exit was lost
+	// would otherwise keep their span open forever
+	otlpMaxProcessSpans = 16384
+	// otlpExportTimeout is the longest time an export request may take
+	otlpExportTimeout = 10 * time.Second
+	// ContentDigestHeader is the header of export requests carrying the checksum of their body, as specified by
+	// RFC 9530 (e.g. sha-256=:<base64 of the sha256>:)
+	ContentDigestHeader = "Content-Digest"
+)
+
+// OTLPConfig configures exporting the events to an OpenTelemetry collector, as OTLP log records sent over HTTP
+// with the JSON encoding
+type OTLPConfig struct {
+	// Endpoint is the base URL of the collector's OTLP/HTTP receiver (e.g. http://collector:4318). Log records are
+	// sent to Endpoint/v1/logs, and spans to Endpoint/v1/traces
+	Endpoint string
+	// Spans also exports a span for the lifetime of every process, from its exec to its exit. The log records of
+	// the events of a process are correlated to its span
+	Spans bool
+	// BatchSize is the number of log records exported in a request (default: 512)
+	BatchSize int
+	// FlushInterval is the longest time records wait for their batch to fill (default: 1 second)
+	FlushInterval time.Duration
+}
+
+// otlpEventPrinter exports events to an OpenTelemetry collector in batches. Batches are exported by a goroutine of
+// their own, and dropped when the collector can't keep up, so printing an event never blocks on the collector
+type otlpEventPrinter struct {
+	config   OTLPConfig
+	client   *http.Client
+	err      io.WriteCloser
+	resource otlpResource
+
+	mu        sync.Mutex
+	logs      []otlpLogRecord
+	spans     []otlpSpan
+	processes map[int]*otlpProcessSpan // the open spans of processes, by their host pid
+	dropped   int
+	closed    bool
+
+	batches   chan otlpBatch
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	failing   bool // only accessed by the exporting goroutine
+	failed    int  // only accessed by the exporting goroutine
+}
+
+func newOTLPEventPrinter(config OTLPConfig, errFile io.WriteCloser) *otlpEventPrinter {
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultOTLPBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultOTLPFlushInterval
+	}
+	hostName, _ := os.Hostname()
+	return &otlpEventPrinter{
+		config: config,
+		client: &http.Client{Timeout: otlpExportTimeout},
+		err:    errFile,
+		resource: otlpResource{Attributes: []otlpKeyValue{
+			otlpString("service.name", "tracee"),
+			otlpString("host.name", hostName),
+		}},
+		processes: make(map[int]*otlpProcessSpan),
+		batches:   make(chan otlpBatch, otlpPendingBatches),
+		done:      make(chan struct{}),
+	}
+}
+
+func (p *otlpEventPrinter) Init() error {
+	p.wg.Add(2)
+	go func() {
+		defer p.wg.Done()
+		for batch := range p.batches {
+			p.export(batch)
+		}
+	}()
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.config.FlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+				p.mu.Lock()
+				p.flush()
+				p.mu.Unlock()
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *otlpEventPrinter) Preamble() {}
+
+func (p *otlpEventPrinter) Print(event trace.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+
+	var process *otlpProcessSpan
+	if p.config.Spans {
+		process = p.trackProcess(event)
+	}
+	record := newOTLPLogRecord(event)
+	if process != nil {
+		record.TraceID = process.TraceID
+		record.SpanID = process.SpanID
+	}
+	p.logs = append(p.logs, record)
+	if len(p.logs) >= p.config.BatchSize {
+		p.flush()
+	}
+}
+
+// trackProcess opens the span of a process on its exec, and ends it on its exit. It returns the span the event
+// belongs to, if any. Must be called with the mutex held
+func (p *otlpEventPrinter) trackProcess(event trace.Event) *otlpProcessSpan {
+	process := p.processes[event.HostProcessID]
+	switch events.ID(event.EventID) {
+	case events.SchedProcessExec:
+		// a process executing again ends the span of its previous executable
+		if process != nil {
+			p.endProcessSpan(process, event.Timestamp)
+		}
+		if len(p.processes) >= otlpMaxProcessSpans {
+			return nil
+		}
+		process = newOTLPProcessSpan(event)
+		p.processes[event.HostProcessID] = process
+	case events.SchedProcessExit:
+		// other threads exiting don't end the process
+		if process != nil && event.HostThreadID == event.HostProcessID {
+			p.endProcessSpan(process, event.Timestamp)
+		}
+	}
+	return process
+}
+
+func (p *otlpEventPrinter) endProcessSpan(process *otlpProcessSpan, ts int) {
+	span := process.otlpSpan
+	span.EndTimeUnixNano = strconv.Itoa(ts)
+	p.spans = append(p.spans, span)
+	delete(p.processes, process.pid)
+}
+
+// flush hands the pending records off to the exporting goroutine, or drops them if too many batches are pending.
+// Must be called with the mutex held
+func (p *otlpEventPrinter) flush() {
+	if p.closed || (len(p.logs) == 0 && len(p.spans) == 0) {
+		return
+	}
+	batch := otlpBatch{logs: p.logs, spans: p.spans}
+	p.logs, p.spans = nil, nil
+	select {
+	case p.batches <- batch:
+	default:
+		p.dropped += len(batch.logs)
+	}
+}
+
+// export sends a batch to the collector, reporting the first failure of consecutive failing exports only
+func (p *otlpEventPrinter) export(batch otlpBatch) {
+	var err error
+	if len(batch.logs) > 0 {
+		err = p.post("/v1/logs", otlpLogsRequest{ResourceLogs: []otlpResourceLogs{{
+			Resource:  p.resource,
+			ScopeLogs: []otlpScopeLogs{{Scope: otlpScope{Name: "tracee"}, LogRecords: batch.logs}},
+		}}})
+	}
+	if err == nil && len(batch.spans) > 0 {
+		err = p.post("/v1/traces", otlpTracesRequest{ResourceSpans: []otlpResourceSpans{{
+			Resource:   p.resource,
+			ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "tracee"}, Spans: batch.spans}},
+		}}})
+	}
+	if err != nil {
+		p.failed += len(batch.logs)
+		if !p.failing {
+			p.Error(fmt.Errorf("failed exporting events to %s: %v", p.config.Endpoint, err))
+		}
+		p.failing = true
+		return
+	}
+	p.failing = false
+}
+
+func (p *otlpEventPrinter) post(path string, request interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, p.config.Endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// the checksum covers the exact bytes sent, so the collector can detect a batch corrupted or truncated in transit
+	req.Header.Set(ContentDigestHeader, contentDigest(body))
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// contentDigest returns the Content-Digest header value of a request body, by its sha256
+func contentDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+// VerifyContentDigest checks that a received request body matches the checksum of its Content-Digest header, so
+// receivers of exported batches can detect batches corrupted or truncated in transit
+func VerifyContentDigest(digest string, body []byte) error {
+	if digest == "" {
+		return fmt.Errorf("missing %s header", ContentDigestHeader)
+	}
+	if digest != contentDigest(body) {
+		return fmt.Errorf("body doesn't match its %s %s", ContentDigestHeader, digest)
+	}
+	return nil
+}
+
+func (p *otlpEventPrinter) Error(err error) {
+	fmt.Fprintf(p.err, "%v\n", err)
+}
+
+func (p *otlpEventPrinter) Epilogue(stats metrics.Stats) {}
+
+// Close exports the pending records, and reports the events which couldn't be exported. The spans of processes
+// which didn't exit yet aren't exported
+func (p *otlpEventPrinter) Close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		p.mu.Lock()
+		p.flush()
+		p.closed = true
+		dropped := p.dropped
+		p.mu.Unlock()
+		close(p.batches)
+		p.wg.Wait()
+
+		if dropped > 0 {
+			p.Error(fmt.Errorf("collector %s is too slow, %d events were dropped", p.config.Endpoint, dropped))
+		}
+		if p.failed > 0 {
+			p.Error(fmt.Errorf("failed exporting %d events to %s", p.failed, p.config.Endpoint))
+		}
+	})
+}
+
+type otlpBatch struct {
+	logs  []otlpLogRecord
+	spans []otlpSpan
+}
+
+// otlpProcessSpan is the open span of a process
+type otlpProcessSpan struct {
+	otlpSpan
+	pid int
+}
+
+// newOTLPProcessSpan opens the span of a process executing a file. Its ids are derived from the pid and the time
+// of the exec, so the spans of a reused pid differ
+func newOTLPProcessSpan(event trace.Event) *otlpProcessSpan {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%d", event.HostProcessID, event.Timestamp)))
+	executable, _ := otlpArgValue(event, "pathname").(string)
+	attributes := []otlpKeyValue{
+		otlpInt("process.pid", int64(event.HostProcessID)),
+		otlpInt("process.parent_pid", int64(event.HostParentProcessID)),
+	}
+	if executable != "" {
+		attributes = append(attributes, otlpString("process.executable.path", executable))
+	}
+	if hash, ok := otlpArgValue(event, "sha256").(string); ok && hash != "" {
+		attributes = append(attributes, otlpString("process.executable.sha256", hash))
+	}
+	if event.ContainerID != "" {
+		attributes = append(attributes, otlpString("container.id", event.ContainerID))
+	}
+	return &otlpProcessSpan{
+		otlpSpan: otlpSpan{
+			TraceID:           hex.EncodeToString(sum[:16]),
+			SpanID:            hex.EncodeToString(sum[16:24]),
+			Name:              event.ProcessName,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.Itoa(event.Timestamp),
+			Attributes:        attributes,
+		},
+		pid: event.HostProcessID,
+	}
+}
+
+// newOTLPLogRecord maps an event to a log record, whose body is the event name and whose attributes are the event
+// context (named as in the OpenTelemetry semantic conventions when they have an equivalent) and arguments
+func newOTLPLogRecord(event trace.Event) otlpLogRecord {
+	attributes := []otlpKeyValue{
+		otlpString("event.name", event.EventName),
+		otlpInt("event.id", int64(event.EventID)),
+		otlpInt("process.pid", int64(event.HostProcessID)),
+		otlpInt("process.parent_pid", int64(event.HostParentProcessID)),
+		otlpInt("thread.id", int64(event.HostThreadID)),
+		otlpString("process.command", event.ProcessName),
+		otlpInt("process.owner.uid", int64(event.UserID)),
+		otlpInt("tracee.process_id", int64(event.ProcessID)),
+		otlpInt("tracee.mount_namespace", int64(event.MountNS)),
+		otlpInt("tracee.pid_namespace", int64(event.PIDNS)),
+		otlpInt("tracee.return_value", int64(event.ReturnValue)),
+	}
+	if event.ContainerID != "" {
+		attributes = append(attributes,
+			otlpString("container.id", event.ContainerID),
+			otlpString("container.name", event.ContainerName),
+			otlpString("container.image.name", event.ContainerImage),
+		)
+	}
+	if event.PodName != "" {
+		attributes = append(attributes,
+			otlpString("k8s.pod.name", event.PodName),
+			otlpString("k8s.namespace.name", event.PodNamespace),
+			otlpString("k8s.pod.uid", event.PodUID),
+		)
+	}
+	for _, arg := range event.Args {
+		attributes = append(attributes, otlpKeyValue{Key: "tracee.args." + arg.Name, Value: otlpValue(arg.Value)})
+	}
+
+	return otlpLogRecord{
+		TimeUnixNano:   strconv.Itoa(event.Timestamp),
+		SeverityNumber: otlpSeverityInfo,
+		SeverityText:   "INFO",
+		Body:           otlpAnyValue{StringValue: &event.EventName},
+		Attributes:     attributes,
+	}
+}
+
+func otlpArgValue(event trace.Event, name string) interface{} {
+	for _, arg := range event.Args {
+		if arg.Name == name {
+			return arg.Value
+		}
+	}
+	return nil
+}
+
+// The OTLP messages, in their JSON encoding (see https://github.com/open-telemetry/opentelemetry-proto). 64 bit
+// integers are encoded as strings, and ids as hex strings
+
+const (
+	otlpSeverityInfo     = 9
+	otlpSpanKindInternal = 1
+)
+
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano"`
+	SeverityNumber int            `json:"severityNumber"`
+	SeverityText   string         `json:"severityText"`
+	Body           otlpAnyValue   `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes"`
+	TraceID        string         `json:"traceId,omitempty"`
+	SpanID         string         `json:"spanId,omitempty"`
+}
+
+type otlpTracesRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+func otlpString(key string, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &value}}
+}
+
+func otlpInt(key string, value int64) otlpKeyValue {
+	encoded := strconv.FormatInt(value, 10)
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{IntValue: &encoded}}
+}
+
+// otlpValue converts an argument value to an attribute value. Values which aren't numbers, booleans, strings or
+// lists of strings are encoded as their string representation
+func otlpValue(value interface{}) otlpAnyValue {
+	var intValue string
+	switch v := value.(type) {
+	case string:
+		return otlpAnyValue{StringValue: &v}
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case int:
+		intValue = strconv.FormatInt(int64(v), 10)
+	case int8:
+		intValue = strconv.FormatInt(int64(v), 10)
+	case int16:
+		intValue = strconv.FormatInt(int64(v), 10)
+	case int32:
+		intValue = strconv.FormatInt(int64(v), 10)
+	case int64:
+		intValue = strconv.FormatInt(v, 10)
+	case uint8:
+		intValue = strconv.FormatUint(uint64(v), 10)
+	case uint16:
+		intValue = strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		intValue = strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		intValue = strconv.FormatUint(v, 10)
+	case float32:
+		f := float64(v)
+		return otlpAnyValue{DoubleValue: &f}
+	case float64:
+		return otlpAnyValue{DoubleValue: &v}
+	case []string:
+		values := make([]otlpAnyValue, 0, len(v))
+		for i := range v {
+			values = append(values, otlpAnyValue{StringValue: &v[i]})
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	default:
+		s := fmt.Sprint(v)
+		return otlpAnyValue{StringValue: &s}
+	}
+	return otlpAnyValue{IntValue: &intValue}
+}
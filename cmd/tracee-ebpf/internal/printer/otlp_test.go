@@ -0,0 +1,286 @@
+package printer_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/cmd/tracee-ebpf/internal/printer"
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// otlpReceiver is a fake OTLP/HTTP receiver, recording the log records and spans it receives
+type otlpReceiver struct {
+	*httptest.Server
+	mu      sync.Mutex
+	records []map[string]interface{}
+	spans   []map[string]interface{}
+	// block holds requests until it is closed, if set
+	block chan struct{}
+}
+
+func newOTLPReceiver(t *testing.T, block chan struct{}) *otlpReceiver {
+	r := &otlpReceiver{block: block}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/logs", func(w http.ResponseWriter, req *http.Request) {
+		if r.block != nil {
+			<-r.block
+		}
+		var body struct {
+			ResourceLogs []struct {
+				ScopeLogs []struct {
+					LogRecords []map[string]interface{} `json:"logRecords"`
+				} `json:"scopeLogs"`
+			} `json:"resourceLogs"`
+		}
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		payload, err := ioutil.ReadAll(req.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, printer.VerifyContentDigest(req.Header.Get(printer.ContentDigestHeader), payload))
+		assert.NoError(t, json.Unmarshal(payload, &body))
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for _, resourceLogs := range body.ResourceLogs {
+			for _, scopeLogs := range resourceLogs.ScopeLogs {
+				r.records = append(r.records, scopeLogs.LogRecords...)
+			}
+		}
+	})
+	mux.HandleFunc("/v1/traces", func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []map[string]interface{} `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for _, resourceSpans := range body.ResourceSpans {
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				r.spans = append(r.spans, scopeSpans.Spans...)
+			}
+		}
+	})
+	r.Server = httptest.NewServer(mux)
+	t.Cleanup(r.Close)
+	return r
+}
+
+// otlpAttributes returns the attributes of a log record or a span, by key
+func otlpAttributes(t *testing.T, record map[string]interface{}) map[string]interface{} {
+	attributes := make(map[string]interface{})
+	for _, attribute := range record["attributes"].([]interface{}) {
+		kv := attribute.(map[string]interface{})
+		value := kv["value"].(map[string]interface{})
+		require.Len(t, value, 1)
+		for _, v := range value {
+			attributes[kv["key"].(string)] = v
+		}
+	}
+	return attributes
+}
+
+func TestOTLPExport(t *testing.T) {
+	receiver := newOTLPReceiver(t, nil)
+	errOut := &syncBuffer{}
+	p, err := printer.New(printer.Config{
+		Kind:    "ignore",
+		OutFile: &syncBuffer{},
+		ErrFile: errOut,
+		Sinks: []printer.SinkConfig{{
+			OTLP:       &printer.OTLPConfig{Endpoint: receiver.URL, Spans: true, BatchSize: 2},
+			DropPolicy: printer.Block,
+		}},
+	})
+	require.NoError(t, err)
+
+	p.Print(trace.Event{
+		Timestamp:     1000,
+		EventID:       int(events.SchedProcessExec),
+		EventName:     "sched_process_exec",
+		HostProcessID: 42,
+		HostThreadID:  42,
+		ProcessName:   "ls",
+		ContainerID:   "ab356bc4dd554",
+		Args: []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/bin/ls"},
+			{ArgMeta: trace.ArgMeta{Name: "argv", Type: "const char**"}, Value: []string{"ls", "-l"}},
+		},
+	})
+	p.Print(trace.Event{
+		Timestamp:     2000,
+		EventID:       int(events.Openat),
+		EventName:     "openat",
+		HostProcessID: 42,
+		HostThreadID:  42,
+		ProcessName:   "ls",
+		ReturnValue:   3,
+		Args: []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/etc/passwd"},
+			{ArgMeta: trace.ArgMeta{Name: "flags", Type: "int"}, Value: int32(0)},
+		},
+	})
+	p.Print(trace.Event{
+		Timestamp:     3000,
+		EventID:       int(events.SchedProcessExit),
+		EventName:     "sched_process_exit",
+		HostProcessID: 42,
+		HostThreadID:  42,
+		ProcessName:   "ls",
+	})
+	p.Print(trace.Event{Timestamp: 4000, EventID: int(events.Close), EventName: "close", HostProcessID: 42, HostThreadID: 42})
+	p.Close()
+	assert.Empty(t, errOut.String())
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	require.Len(t, receiver.records, 4)
+
+	exec := receiver.records[0]
+	assert.Equal(t, "1000", exec["timeUnixNano"])
+	assert.Equal(t, map[string]interface{}{"stringValue": "sched_process_exec"}, exec["body"])
+	execAttributes := otlpAttributes(t, exec)
+	assert.Equal(t, "sched_process_exec", execAttributes["event.name"])
+	assert.Equal(t, "42", execAttributes["process.pid"])
+	assert.Equal(t, "ls", execAttributes["process.command"])
+	assert.Equal(t, "ab356bc4dd554", execAttributes["container.id"])
+	assert.Equal(t, "/bin/ls", execAttributes["tracee.args.pathname"])
+	assert.Equal(t, map[string]interface{}{"values": []interface{}{
+		map[string]interface{}{"stringValue": "ls"},
+		map[string]interface{}{"stringValue": "-l"},
+	}}, execAttributes["tracee.args.argv"])
+
+	openat := otlpAttributes(t, receiver.records[1])
+	assert.Equal(t, "/etc/passwd", openat["tracee.args.pathname"])
+	assert.Equal(t, "0", openat["tracee.args.flags"])
+	assert.Equal(t, "3", openat["tracee.return_value"])
+
+	// the events of the process are correlated to its span, and events after its exit aren't
+	require.Len(t, receiver.spans, 1)
+	span := receiver.spans[0]
+	assert.Equal(t, "ls", span["name"])
+	assert.Equal(t, "1000", span["startTimeUnixNano"])
+	assert.Equal(t, "3000", span["endTimeUnixNano"])
+	assert.Equal(t, "/bin/ls", otlpAttributes(t, span)["process.executable.path"])
+	assert.Len(t, span["traceId"], 32)
+	assert.Len(t, span["spanId"], 16)
+	for _, record := range receiver.records[:3] {
+		assert.Equal(t, span["traceId"], record["traceId"])
+		assert.Equal(t, span["spanId"], record["spanId"])
+	}
+	assert.NotContains(t, receiver.records[3], "traceId")
+}
+
+func TestOTLPExportBackpressure(t *testing.T) {
+	block := make(chan struct{})
+	receiver := newOTLPReceiver(t, block)
+	errOut := &syncBuffer{}
+	p, err := printer.New(printer.Config{
+		Kind:    "ignore",
+		OutFile: &syncBuffer{},
+		ErrFile: errOut,
+		Sinks: []printer.SinkConfig{{
+			OTLP:       &printer.OTLPConfig{Endpoint: receiver.URL, BatchSize: 1},
+			DropPolicy: printer.Block,
+		}},
+	})
+	require.NoError(t, err)
+
+	// the collector doesn't respond, yet printing isn't blocked
+	printed := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			p.Print(trace.Event{Timestamp: i, EventName: "openat"})
+		}
+		close(printed)
+	}()
+	select {
+	case <-printed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("printing events was blocked by the collector")
+	}
+
+	close(block)
+	p.Close()
+
+	receiver.mu.Lock()
+	received := len(receiver.records)
+	receiver.mu.Unlock()
+	assert.Less(t, received, 100)
+	assert.Contains(t, errOut.String(), "events were dropped")
+}
+
+func TestOTLPExportChecksum(t *testing.T) {
+	var mu sync.Mutex
+	var digest string
+	var payload []byte
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		assert.NoError(t, err)
+		mu.Lock()
+		defer mu.Unlock()
+		digest, payload = req.Header.Get(printer.ContentDigestHeader), body
+	}))
+	defer collector.Close()
+	p, err := printer.New(printer.Config{
+		Kind:    "ignore",
+		OutFile: &syncBuffer{},
+		ErrFile: &syncBuffer{},
+		Sinks: []printer.SinkConfig{{
+			OTLP:       &printer.OTLPConfig{Endpoint: collector.URL},
+			DropPolicy: printer.Block,
+		}},
+	})
+	require.NoError(t, err)
+	p.Print(trace.Event{Timestamp: 1000, EventName: "openat", ProcessName: "cat"})
+	p.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, payload)
+	assert.Regexp(t, `^sha-256=:[A-Za-z0-9+/]{43}=:$`, digest)
+	// the checksum covers the exact bytes sent
+	assert.NoError(t, printer.VerifyContentDigest(digest, payload))
+
+	// a batch corrupted or truncated in transit is detected
+	corrupted := append([]byte{}, payload...)
+	corrupted[len(corrupted)/2] ^= 0x01
+	assert.Error(t, printer.VerifyContentDigest(digest, corrupted))
+	assert.Error(t, printer.VerifyContentDigest(digest, payload[:len(payload)-1]))
+	assert.Error(t, printer.VerifyContentDigest("", payload))
+}
+
+func TestOTLPExportFailure(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer collector.Close()
+
+	errOut := &syncBuffer{}
+	p, err := printer.New(printer.Config{
+		Kind:    "ignore",
+		OutFile: &syncBuffer{},
+		ErrFile: errOut,
+		Sinks: []printer.SinkConfig{{
+			OTLP:       &printer.OTLPConfig{Endpoint: collector.URL, BatchSize: 1},
+			DropPolicy: printer.Block,
+		}},
+	})
+	require.NoError(t, err)
+
+	p.Print(trace.Event{Timestamp: 1, EventName: "openat"})
+	p.Print(trace.Event{Timestamp: 2, EventName: "close"})
+	p.Close()
+
+	assert.Contains(t, errOut.String(), "failed exporting events to "+collector.URL+": collector responded with 503 Service Unavailable")
+	assert.Contains(t, errOut.String(), "failed exporting 2 events to "+collector.URL)
+}
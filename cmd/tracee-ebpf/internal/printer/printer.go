@@ -6,11 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"strings"
 	"text/template"
 	"time"
 
+	"github.com/aquasecurity/tracee/pkg/events"
 	"github.com/aquasecurity/tracee/pkg/metrics"
+	"github.com/aquasecurity/tracee/pkg/utils"
 	"github.com/aquasecurity/tracee/types/trace"
 )
 
@@ -30,16 +33,137 @@ type EventPrinter interface {
 }
 
 type Config struct {
-	Kind          string
-	OutPath       string
-	OutFile       io.WriteCloser
+	Kind    string
+	OutPath string
+	OutFile io.WriteCloser
+	// OutWriter is the events output when OutFile isn't set. Unlike OutFile, it is never closed by the printer, so
+	// it can be any writer owned by the caller (e.g. a buffer, pipe or network connection)
+	OutWriter     io.Writer
 	ErrPath       string
 	ErrFile       io.WriteCloser
 	ContainerMode bool
 	RelativeTS    bool
+	// Gzip compresses the events output, flushing it every GzipFlushInterval (default: 1 second)
+	Gzip              bool
+	GzipFlushInterval time.Duration
+	// Sinks are additional outputs, to which the events are printed concurrently with OutFile
+	Sinks []SinkConfig
+	// PartitionHourly splits the output files into a file per (UTC) hour of the wall clock, named by the hour before
+	// their extension (e.g. events-2024-01-02T15.jsonl for events.jsonl). Outputs which aren't rotatable (see
+	// Rotator) aren't partitioned
+	PartitionHourly bool
+	// Clock is the time the output files are partitioned and rotated by (default: the system clock)
+	Clock utils.Clock
+	// FieldMap renames the fields of events printed as json, for downstream schemas expecting other names (e.g.
+	// pathname to file.path). Top level fields (e.g. processName) and arguments are renamed by their names, and
+	// unmapped fields are printed as they are
+	FieldMap map[string]string
 }
 
 func New(config Config) (EventPrinter, error) {
+	config.OutFile = outFile(config.OutFile, config.OutWriter)
+	config.OutWriter = nil
+	if len(config.Sinks) == 0 {
+		return newEventPrinter(config)
+	}
+
+	// the main output never drops events, as it did before sinks were configured
+	sinksConfig := append([]SinkConfig{{OutPath: config.OutPath, OutFile: config.OutFile, DropPolicy: Block}}, config.Sinks...)
+	sinks := make([]*sink, 0, len(sinksConfig))
+	for _, sinkConfig := range sinksConfig {
+		printerConfig := config
+		printerConfig.OutPath = sinkConfig.OutPath
+		printerConfig.OutFile = outFile(sinkConfig.OutFile, sinkConfig.OutWriter)
+		printerConfig.Sinks = nil
+		if sinkConfig.Kind != "" {
+			printerConfig.Kind = sinkConfig.Kind
+			printerConfig.Gzip = sinkConfig.Gzip
+		}
+		var p EventPrinter
+		var err error
+		if sinkConfig.OTLP != nil {
+			p = newOTLPEventPrinter(*sinkConfig.OTLP, printerConfig.ErrFile)
+			err = p.Init()
+		} else if sinkConfig.Kafka != nil {
+			var kafka *kafkaEventPrinter
+			if kafka, err = newKafkaEventPrinter(*sinkConfig.Kafka, printerConfig); err == nil {
+				p = kafka
+				err = p.Init()
+			}
+		} else if sinkConfig.Syslog != nil {
+			var syslog *syslogEventPrinter
+			if syslog, err = newSyslogEventPrinter(*sinkConfig.Syslog, printerConfig); err == nil {
+				p = syslog
+				err = p.Init()
+			}
+		} else if sinkConfig.FIFOPath != "" {
+			p, err = newFIFOEventPrinter(sinkConfig.FIFOPath, printerConfig)
+		} else {
+			p, err = newEventPrinter(printerConfig)
+		}
+		if err != nil {
+			for _, s := range sinks {
+				s.printer.Close()
+			}
+			return nil, err
+		}
+
+		name := sinkConfig.OutPath
+		if name == "" && sinkConfig.OTLP != nil {
+			name = sinkConfig.OTLP.Endpoint
+		}
+		if name == "" && sinkConfig.Kafka != nil {
+			name = kafkaSinkName(*sinkConfig.Kafka)
+		}
+		if name == "" && sinkConfig.Syslog != nil {
+			name = syslogSinkName(*sinkConfig.Syslog)
+		}
+		if name == "" {
+			name = sinkConfig.FIFOPath
+		}
+		if name == "" {
+			name = fmt.Sprintf("#%d", len(sinks))
+		}
+		bufferSize := sinkConfig.BufferSize
+		if bufferSize <= 0 {
+			bufferSize = defaultSinkBufferSize
+		}
+		var routedEvents map[events.ID]struct{}
+		if len(sinkConfig.Events) > 0 {
+			routedEvents = make(map[events.ID]struct{}, len(sinkConfig.Events))
+			for _, eventID := range sinkConfig.Events {
+				routedEvents[eventID] = struct{}{}
+			}
+		}
+		sinks = append(sinks, &sink{
+			name:    name,
+			printer: p,
+			policy:  sinkConfig.DropPolicy,
+			queue:   make(chan sinkMessage, bufferSize),
+			events:  routedEvents,
+			filter:  sinkConfig.Filter,
+		})
+	}
+
+	return newFanoutEventPrinter(sinks), nil
+}
+
+// outFile returns the output to print to, which is the given file if set, or the given writer otherwise
+func outFile(file io.WriteCloser, writer io.Writer) io.WriteCloser {
+	if file != nil || writer == nil {
+		return file
+	}
+	return nopWriteCloser{writer}
+}
+
+// nopWriteCloser is a writer whose Close does nothing, for outputs which are closed by their owner
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func newEventPrinter(config Config) (EventPrinter, error) {
 	var res EventPrinter
 	kind := config.Kind
 
@@ -50,6 +174,28 @@ func New(config Config) (EventPrinter, error) {
 		return res, fmt.Errorf("err file is not set")
 	}
 
+	if config.Clock == nil {
+		config.Clock = utils.RealClock{}
+	}
+	var rotatingOut *rotatingFile
+	var partitionHour time.Time // the hour of the partitioned output file, if partitioned
+	if rotatable(config) {
+		rotatingOut = &rotatingFile{path: config.OutPath, file: config.OutFile.(*os.File)}
+		if config.PartitionHourly {
+			now := config.Clock.Now()
+			if err := rotatingOut.startPartition(now); err != nil {
+				return nil, err
+			}
+			partitionHour = now.UTC().Truncate(time.Hour)
+		}
+		config.OutFile = rotatingOut
+	}
+	var gzipOut *gzipWriter
+	if config.Gzip {
+		gzipOut = newGzipWriter(config.OutFile, config.GzipFlushInterval)
+		config.OutFile = gzipOut
+	}
+
 	switch {
 	case kind == "ignore":
 		res = &ignoreEventPrinter{
@@ -73,6 +219,12 @@ func New(config Config) (EventPrinter, error) {
 		}
 	case kind == "json":
 		res = &jsonEventPrinter{
+			out:      config.OutFile,
+			err:      config.ErrFile,
+			fieldMap: config.FieldMap,
+		}
+	case kind == "ecs":
+		res = &ecsEventPrinter{
 			out: config.OutFile,
 			err: config.ErrFile,
 		}
@@ -93,6 +245,19 @@ func New(config Config) (EventPrinter, error) {
 	if err != nil {
 		return nil, err
 	}
+	if gzipOut != nil {
+		res = &gzipEventPrinter{EventPrinter: res, out: gzipOut}
+	}
+	if rotatingOut != nil {
+		res = &rotatingEventPrinter{
+			EventPrinter: res,
+			out:          rotatingOut,
+			gz:           gzipOut,
+			clock:        config.Clock,
+			partitioned:  config.PartitionHourly,
+			hour:         partitionHour,
+		}
+	}
 	return res, nil
 }
 
@@ -216,22 +381,45 @@ func (p templateEventPrinter) Close() {
 }
 
 type jsonEventPrinter struct {
-	out io.WriteCloser
-	err io.WriteCloser
+	out      io.WriteCloser
+	err      io.WriteCloser
+	fieldMap map[string]string
 }
 
 func (p jsonEventPrinter) Init() error { return nil }
 
 func (p jsonEventPrinter) Preamble() {}
 
+// versionedEvent is an event along with the version of its structure
+type versionedEvent struct {
+	trace.Event
+	SchemaVersion string `json:"schemaVersion"`
+}
+
 func (p jsonEventPrinter) Print(event trace.Event) {
-	eBytes, err := json.Marshal(event)
+	eBytes, err := encodeJSON(event, p.fieldMap)
 	if err != nil {
 		p.Error(err)
+		return
 	}
 	fmt.Fprintln(p.out, string(eBytes))
 }
 
+// encodeJSON encodes an event as json, with its fields renamed by the field map
+func encodeJSON(event trace.Event, fieldMap map[string]string) ([]byte, error) {
+	if len(fieldMap) > 0 {
+		event.Args = renameArgs(event.Args, fieldMap)
+	}
+	eBytes, err := json.Marshal(versionedEvent{event, events.SchemaVersion})
+	if err != nil {
+		return nil, err
+	}
+	if len(fieldMap) > 0 {
+		return renameFields(eBytes, fieldMap)
+	}
+	return eBytes, nil
+}
+
 func (p jsonEventPrinter) Error(err error) {
 	fmt.Fprintf(p.err, "%v\n", err)
 }
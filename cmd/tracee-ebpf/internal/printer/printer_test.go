@@ -1,13 +1,33 @@
 package printer_test
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/aquasecurity/tracee/cmd/tracee-ebpf/flags"
 	"github.com/aquasecurity/tracee/cmd/tracee-ebpf/internal/printer"
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/filters"
+	"github.com/aquasecurity/tracee/pkg/metrics"
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/aquasecurity/tracee/types/trace"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPrepareOutputPrinterConfig(t *testing.T) {
@@ -22,13 +42,13 @@ func TestPrepareOutputPrinterConfig(t *testing.T) {
 			testName:        "invalid format",
 			outputSlice:     []string{"notaformat"},
 			expectedPrinter: printer.Config{},
-			expectedError:   fmt.Errorf("unrecognized output format: %s. Valid format values: 'table', 'table-verbose', 'json', 'gob' or 'gotemplate='. Use '--output help' for more info", "notaformat"),
+			expectedError:   fmt.Errorf("unrecognized output format: %s. Valid format values: 'table', 'table-verbose', 'json', 'ecs', 'gob' or 'gotemplate='. Use '--output help' for more info", "notaformat"),
 		},
 		{
 			testName:        "invalid format with format prefix",
 			outputSlice:     []string{"format:notaformat2"},
 			expectedPrinter: printer.Config{},
-			expectedError:   fmt.Errorf("unrecognized output format: %s. Valid format values: 'table', 'table-verbose', 'json', 'gob' or 'gotemplate='. Use '--output help' for more info", "notaformat2"),
+			expectedError:   fmt.Errorf("unrecognized output format: %s. Valid format values: 'table', 'table-verbose', 'json', 'ecs', 'gob' or 'gotemplate='. Use '--output help' for more info", "notaformat2"),
 		},
 		{
 			testName:    "default",
@@ -61,6 +81,17 @@ func TestPrepareOutputPrinterConfig(t *testing.T) {
 			},
 			expectedError: nil,
 		},
+		{
+			testName:    "option gzip",
+			outputSlice: []string{"format:json", "option:gzip"},
+			expectedPrinter: printer.Config{
+				Kind:    "json",
+				OutFile: os.Stdout,
+				ErrFile: os.Stderr,
+				Gzip:    true,
+			},
+			expectedError: nil,
+		},
 	}
 	for _, testcase := range testCases {
 		t.Run(testcase.testName, func(t *testing.T) {
@@ -73,3 +104,948 @@ func TestPrepareOutputPrinterConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestGzipOutput(t *testing.T) {
+	f, err := ioutil.TempFile("", "TestGzipOutput-*.json.gz")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	p, err := printer.New(printer.Config{
+		Kind:              "json",
+		OutFile:           f,
+		ErrFile:           os.Stderr,
+		Gzip:              true,
+		GzipFlushInterval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	const eventsNum = 100
+	p.Preamble()
+	for i := 0; i < eventsNum; i++ {
+		p.Print(trace.Event{Timestamp: i, EventName: "openat", ProcessName: "cat"})
+	}
+
+	// flushed output can be consumed before the stream is closed
+	assert.Eventually(t, func() bool {
+		info, err := os.Stat(f.Name())
+		return err == nil && info.Size() > 0
+	}, time.Second, 10*time.Millisecond)
+
+	p.Epilogue(metrics.Stats{})
+	p.Close()
+
+	r, err := os.Open(f.Name())
+	require.NoError(t, err)
+	defer r.Close()
+	gz, err := gzip.NewReader(r)
+	require.NoError(t, err)
+
+	scanner := bufio.NewScanner(gz)
+	i := 0
+	for scanner.Scan() {
+		var event trace.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		assert.Equal(t, i, event.Timestamp)
+		assert.Equal(t, "openat", event.EventName)
+		i++
+	}
+	// reading through the gzip trailer fails if the stream wasn't closed properly
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, eventsNum, i)
+}
+
+// syncBuffer is an in-memory output which can be read while being written to
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Close() error { return nil }
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// failingWriter is an output whose writes always fail
+type failingWriter struct {
+	writes int32
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	atomic.AddInt32(&w.writes, 1)
+	return 0, errors.New("broken pipe")
+}
+
+func (w *failingWriter) Close() error { return nil }
+
+// blockingWriter is an output whose writes block until it's released
+type blockingWriter struct {
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return len(p), nil
+}
+
+func (w *blockingWriter) Close() error { return nil }
+
+func TestFanoutOutput(t *testing.T) {
+	const eventsNum = 2000
+
+	countEvents := func(t *testing.T, output string) int {
+		scanner := bufio.NewScanner(strings.NewReader(output))
+		i := 0
+		for scanner.Scan() {
+			var event trace.Event
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+			assert.Equal(t, i, event.Timestamp)
+			i++
+		}
+		return i
+	}
+
+	t.Run("failing sink", func(t *testing.T) {
+		failing := &failingWriter{}
+		out := &syncBuffer{}
+		p, err := printer.New(printer.Config{
+			Kind:    "json",
+			OutFile: failing,
+			ErrFile: &syncBuffer{},
+			Sinks:   []printer.SinkConfig{{OutFile: out, BufferSize: eventsNum}},
+		})
+		require.NoError(t, err)
+
+		p.Preamble()
+		for i := 0; i < eventsNum; i++ {
+			p.Print(trace.Event{Timestamp: i, EventName: "openat"})
+		}
+		p.Epilogue(metrics.Stats{})
+		p.Close()
+
+		assert.Equal(t, int32(eventsNum), atomic.LoadInt32(&failing.writes))
+		assert.Equal(t, eventsNum, countEvents(t, out.String()))
+	})
+
+	t.Run("slow sink", func(t *testing.T) {
+		slow := &blockingWriter{release: make(chan struct{})}
+		out := &syncBuffer{}
+		errOut := &syncBuffer{}
+		p, err := printer.New(printer.Config{
+			Kind:    "json",
+			OutFile: out,
+			ErrFile: errOut,
+			Sinks:   []printer.SinkConfig{{OutPath: "/slow/sink", OutFile: slow, BufferSize: 10, DropPolicy: printer.DropNewest}},
+		})
+		require.NoError(t, err)
+
+		p.Preamble()
+		for i := 0; i < eventsNum; i++ {
+			p.Print(trace.Event{Timestamp: i, EventName: "openat"})
+		}
+		// the main output keeps up while the slow sink is stuck
+		assert.Eventually(t, func() bool {
+			return strings.Count(out.String(), "\n") == eventsNum
+		}, time.Second, 10*time.Millisecond)
+
+		close(slow.release)
+		p.Epilogue(metrics.Stats{})
+		p.Close()
+
+		assert.Equal(t, eventsNum, countEvents(t, out.String()))
+		assert.Contains(t, errOut.String(), "output /slow/sink is too slow")
+	})
+}
+
+// slowWriter is an output whose writes block until it's released, once it started writing
+type slowWriter struct {
+	syncBuffer
+	writing     chan struct{}
+	writingOnce sync.Once
+	release     chan struct{}
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	w.writingOnce.Do(func() { close(w.writing) })
+	<-w.release
+	return w.syncBuffer.Write(p)
+}
+
+func TestSinkDropPolicies(t *testing.T) {
+	const eventsNum = 100
+	const bufferSize = 10
+
+	timestamps := func(t *testing.T, output string) []int {
+		var printed []int
+		scanner := bufio.NewScanner(strings.NewReader(output))
+		for scanner.Scan() {
+			var event trace.Event
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+			printed = append(printed, event.Timestamp)
+		}
+		return printed
+	}
+	sequence := func(from, to int) []int {
+		var timestamps []int
+		for i := from; i < to; i++ {
+			timestamps = append(timestamps, i)
+		}
+		return timestamps
+	}
+
+	testCases := []struct {
+		name            string
+		policy          printer.DropPolicy
+		expectedPrinted []int
+		expectedError   string
+	}{
+		{
+			name:   "block",
+			policy: printer.Block,
+			// nothing is dropped, the main output waits for the slow sink instead
+			expectedPrinted: sequence(0, eventsNum),
+		},
+		{
+			name:   "drop newest",
+			policy: printer.DropNewest,
+			// the event being written and the queued ones
+			expectedPrinted: sequence(0, bufferSize+1),
+			expectedError:   fmt.Sprintf("output /slow/sink is too slow, %d events were dropped", eventsNum-bufferSize-1),
+		},
+		{
+			name:            "drop oldest",
+			policy:          printer.DropOldest,
+			expectedPrinted: append([]int{0}, sequence(eventsNum-bufferSize, eventsNum)...),
+			expectedError:   fmt.Sprintf("output /slow/sink is too slow, %d events were dropped", eventsNum-bufferSize-1),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			slow := &slowWriter{writing: make(chan struct{}), release: make(chan struct{})}
+			out := &syncBuffer{}
+			errOut := &syncBuffer{}
+			p, err := printer.New(printer.Config{
+				Kind:    "json",
+				OutFile: out,
+				ErrFile: errOut,
+				Sinks:   []printer.SinkConfig{{OutPath: "/slow/sink", OutFile: slow, BufferSize: bufferSize, DropPolicy: tc.policy}},
+			})
+			require.NoError(t, err)
+
+			p.Preamble()
+			p.Print(trace.Event{Timestamp: 0, EventName: "openat"})
+			<-slow.writing
+			printed := make(chan struct{})
+			go func() {
+				defer close(printed)
+				for i := 1; i < eventsNum; i++ {
+					p.Print(trace.Event{Timestamp: i, EventName: "openat"})
+				}
+			}()
+			if tc.policy == printer.Block {
+				// the slow sink holds back the main output until it catches up
+				assert.Eventually(t, func() bool {
+					return strings.Count(out.String(), "\n") == bufferSize+2
+				}, time.Second, 10*time.Millisecond)
+				assert.Never(t, func() bool {
+					return strings.Count(out.String(), "\n") > bufferSize+2
+				}, 100*time.Millisecond, 10*time.Millisecond)
+			} else {
+				<-printed
+			}
+
+			close(slow.release)
+			<-printed
+			p.Epilogue(metrics.Stats{})
+			p.Close()
+
+			assert.Equal(t, sequence(0, eventsNum), timestamps(t, out.String()))
+			assert.Equal(t, tc.expectedPrinted, timestamps(t, slow.String()))
+			if tc.expectedError == "" {
+				assert.NotContains(t, errOut.String(), "too slow")
+			} else {
+				assert.Contains(t, errOut.String(), tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestRoutedOutput(t *testing.T) {
+	eventNames := func(t *testing.T, output string) []string {
+		var names []string
+		scanner := bufio.NewScanner(strings.NewReader(output))
+		for scanner.Scan() {
+			var event trace.Event
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+			names = append(names, event.EventName)
+		}
+		return names
+	}
+
+	out := &syncBuffer{}
+	mirror := &syncBuffer{}
+	siem := &syncBuffer{}
+	debug := &syncBuffer{}
+	p, err := printer.New(printer.Config{
+		Kind:    "json",
+		OutFile: out,
+		ErrFile: &syncBuffer{},
+		Sinks: []printer.SinkConfig{
+			{OutFile: mirror},
+			{OutFile: siem, Events: []events.ID{events.Execve, events.SchedProcessExec}},
+			{OutFile: debug, Events: []events.ID{events.Openat}},
+		},
+	})
+	require.NoError(t, err)
+
+	for _, id := range []events.ID{events.Execve, events.Openat, events.Close, events.SchedProcessExec, events.Openat} {
+		p.Print(trace.Event{EventID: int(id), EventName: events.Definitions.Get(id).Name})
+	}
+	p.Close()
+
+	assert.Equal(t, []string{"execve", "sched_process_exec"}, eventNames(t, siem.String()))
+	assert.Equal(t, []string{"openat", "openat"}, eventNames(t, debug.String()))
+	// unrouted events go to the default sinks only
+	assert.Equal(t, []string{"close"}, eventNames(t, out.String()))
+	assert.Equal(t, []string{"close"}, eventNames(t, mirror.String()))
+}
+
+func TestFilteredOutput(t *testing.T) {
+	// printed returns the host pids of the printed events
+	printed := func(t *testing.T, output string) []int {
+		var pids []int
+		scanner := bufio.NewScanner(strings.NewReader(output))
+		for scanner.Scan() {
+			var event trace.Event
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+			pids = append(pids, event.HostProcessID)
+		}
+		return pids
+	}
+	newFilter := func(t *testing.T, expressions ...string) *filters.ExprFilter {
+		filter := &filters.ExprFilter{}
+		for _, expression := range expressions {
+			require.NoError(t, filter.Parse(expression))
+		}
+		return filter
+	}
+
+	out := &syncBuffer{}
+	archive := &syncBuffer{}
+	siem := &syncBuffer{}
+	setuid := &syncBuffer{}
+	p, err := printer.New(printer.Config{
+		Kind:    "json",
+		OutFile: out,
+		ErrFile: &syncBuffer{},
+		Sinks: []printer.SinkConfig{
+			{OutFile: archive},
+			// the filters are evaluated on the enriched events
+			{OutFile: siem, Filter: newFilter(t, "event.containerImage.startsWith('nginx')", "event.uid == 0")},
+			{OutFile: setuid, Events: []events.ID{events.Setuid}, Filter: newFilter(t, "args.uid == 0")},
+		},
+	})
+	require.NoError(t, err)
+
+	for _, event := range []trace.Event{
+		{HostProcessID: 1, EventID: int(events.Execve), UserID: 0, ContainerImage: "nginx:1.21"},
+		{HostProcessID: 2, EventID: int(events.Execve), UserID: 1000, ContainerImage: "nginx:1.21"},
+		{HostProcessID: 3, EventID: int(events.Execve), UserID: 0},
+		{HostProcessID: 4, EventID: int(events.Setuid), Args: []trace.Argument{{ArgMeta: trace.ArgMeta{Name: "uid", Type: "uid_t"}, Value: int32(0)}}},
+		{HostProcessID: 5, EventID: int(events.Setuid), Args: []trace.Argument{{ArgMeta: trace.ArgMeta{Name: "uid", Type: "uid_t"}, Value: int32(1000)}}},
+		{HostProcessID: 6, EventID: int(events.Openat), UserID: 0, ContainerImage: "nginx:1.21"},
+	} {
+		p.Print(event)
+	}
+	p.Close()
+
+	assert.Equal(t, []int{1, 2, 3, 6}, printed(t, out.String()))
+	assert.Equal(t, []int{1, 2, 3, 6}, printed(t, archive.String()))
+	assert.Equal(t, []int{1, 6}, printed(t, siem.String()))
+	// routed events filtered out by their sink aren't printed by the default sinks
+	assert.Equal(t, []int{4}, printed(t, setuid.String()))
+}
+
+func TestSinkFormats(t *testing.T) {
+	out := &syncBuffer{}
+	archive := &syncBuffer{}
+	webhook := &syncBuffer{}
+	p, err := printer.New(printer.Config{
+		Kind:    "table",
+		OutFile: out,
+		ErrFile: &syncBuffer{},
+		Sinks: []printer.SinkConfig{
+			{OutFile: archive, DropPolicy: printer.Block, Kind: "json", Gzip: true},
+			{OutFile: webhook, DropPolicy: printer.Block, Kind: "json"},
+		},
+	})
+	require.NoError(t, err)
+
+	p.Print(trace.Event{Timestamp: 1, EventName: "openat", ProcessName: "cat"})
+	p.Print(trace.Event{Timestamp: 2, EventName: "close", ProcessName: "cat"})
+	p.Close()
+
+	eventNames := func(t *testing.T, output string) []string {
+		var names []string
+		scanner := bufio.NewScanner(strings.NewReader(output))
+		for scanner.Scan() {
+			var event trace.Event
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+			names = append(names, event.EventName)
+		}
+		return names
+	}
+
+	// the main output keeps its own format
+	assert.Contains(t, out.String(), "openat")
+	assert.Error(t, json.Unmarshal([]byte(strings.SplitN(out.String(), "\n", 2)[0]), &map[string]interface{}{}))
+
+	gz, err := gzip.NewReader(strings.NewReader(archive.String()))
+	require.NoError(t, err)
+	decompressed, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"openat", "close"}, eventNames(t, string(decompressed)))
+
+	assert.Equal(t, []string{"openat", "close"}, eventNames(t, webhook.String()))
+}
+
+func TestJSONSchemaVersion(t *testing.T) {
+	out := &syncBuffer{}
+	p, err := printer.New(printer.Config{
+		Kind:    "json",
+		OutFile: out,
+		ErrFile: &syncBuffer{},
+	})
+	require.NoError(t, err)
+
+	p.Print(trace.Event{Timestamp: 1, EventName: "openat", Args: []trace.Argument{
+		{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/etc/passwd"},
+	}})
+	p.Close()
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(out.String()), &fields))
+	assert.Equal(t, events.SchemaVersion, fields["schemaVersion"])
+
+	// the event fields are kept at the top level
+	assert.Equal(t, "openat", fields["eventName"])
+	var event trace.Event
+	require.NoError(t, json.Unmarshal([]byte(out.String()), &event))
+	assert.Equal(t, "/etc/passwd", event.Args[0].Value)
+}
+
+func TestJSONFieldMap(t *testing.T) {
+	out := &syncBuffer{}
+	sinkOut := &bytes.Buffer{}
+	p, err := printer.New(printer.Config{
+		Kind:     "json",
+		OutFile:  out,
+		ErrFile:  &syncBuffer{},
+		FieldMap: map[string]string{"pathname": "file.path", "processName": "process.name"},
+		Sinks:    []printer.SinkConfig{{OutWriter: sinkOut, DropPolicy: printer.Block}},
+	})
+	require.NoError(t, err)
+
+	args := []trace.Argument{
+		{ArgMeta: trace.ArgMeta{Name: "dirfd", Type: "int"}, Value: int32(-100)},
+		{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/etc/passwd"},
+	}
+	p.Print(trace.Event{Timestamp: 1, EventName: "openat", ProcessName: "cat", Args: args})
+	p.Close()
+
+	// the arguments of the printed event aren't renamed
+	assert.Equal(t, "pathname", args[1].Name)
+
+	for _, printed := range []string{out.String(), sinkOut.String()} {
+		var fields map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(printed), &fields))
+		assert.Equal(t, "cat", fields["process.name"])
+		assert.NotContains(t, fields, "processName")
+		// unmapped fields are kept
+		assert.Equal(t, "openat", fields["eventName"])
+		assert.Equal(t, events.SchemaVersion, fields["schemaVersion"])
+
+		var event trace.Event
+		require.NoError(t, json.Unmarshal([]byte(printed), &event))
+		require.Len(t, event.Args, 2)
+		assert.Equal(t, "dirfd", event.Args[0].Name)
+		assert.Equal(t, "file.path", event.Args[1].Name)
+		assert.Equal(t, "/etc/passwd", event.Args[1].Value)
+	}
+
+	// the order of the fields is kept
+	printed := out.String()
+	assert.Less(t, strings.Index(printed, `"timestamp"`), strings.Index(printed, `"process.name"`))
+	assert.Less(t, strings.Index(printed, `"process.name"`), strings.Index(printed, `"eventName"`))
+}
+
+func TestECSPrinter(t *testing.T) {
+	printECS := func(t *testing.T, event trace.Event) map[string]interface{} {
+		out := &syncBuffer{}
+		p, err := printer.New(printer.Config{
+			Kind:    "ecs",
+			OutFile: out,
+			ErrFile: &syncBuffer{},
+		})
+		require.NoError(t, err)
+		p.Print(event)
+		p.Close()
+
+		var doc map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(out.String()), &doc))
+		return doc
+	}
+
+	t.Run("exec event", func(t *testing.T) {
+		doc := printECS(t, trace.Event{
+			Timestamp:           1661508000123456789,
+			EventID:             int(events.SchedProcessExec),
+			EventName:           "sched_process_exec",
+			HostProcessID:       1234,
+			HostThreadID:        1234,
+			HostParentProcessID: 1,
+			ProcessID:           7,
+			UserID:              1000,
+			ProcessName:         "ls",
+			HostName:            "node-1",
+			ContainerID:         "ab356bc4dd554",
+			ContainerImage:      "alpine:3.16",
+			PodName:             "web-0",
+			PodNamespace:        "default",
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/bin/ls"},
+				{ArgMeta: trace.ArgMeta{Name: "argv", Type: "const char**"}, Value: []string{"ls", "-l"}},
+				{ArgMeta: trace.ArgMeta{Name: "sha256", Type: "const char*"}, Value: "c8b6"},
+			},
+		})
+
+		assert.Equal(t, "2022-08-26T10:00:00.123456789Z", doc["@timestamp"])
+		assert.Equal(t, map[string]interface{}{"version": "8.4.0"}, doc["ecs"])
+		assert.Equal(t, map[string]interface{}{
+			"kind":     "event",
+			"category": []interface{}{"process"},
+			"type":     []interface{}{"start"},
+			"action":   "sched_process_exec",
+			"code":     fmt.Sprint(int(events.SchedProcessExec)),
+			"module":   "tracee",
+		}, doc["event"])
+		assert.Equal(t, map[string]interface{}{
+			"pid":          float64(1234),
+			"name":         "ls",
+			"executable":   "/bin/ls",
+			"args":         []interface{}{"ls", "-l"},
+			"command_line": "ls -l",
+			"hash":         map[string]interface{}{"sha256": "c8b6"},
+			"thread":       map[string]interface{}{"id": float64(1234)},
+			"parent":       map[string]interface{}{"pid": float64(1)},
+		}, doc["process"])
+		assert.Equal(t, map[string]interface{}{"id": "1000"}, doc["user"])
+		assert.Equal(t, map[string]interface{}{"hostname": "node-1"}, doc["host"])
+		assert.Equal(t, map[string]interface{}{
+			"id":    "ab356bc4dd554",
+			"image": map[string]interface{}{"name": "alpine:3.16"},
+		}, doc["container"])
+		assert.Equal(t, map[string]interface{}{
+			"namespace": "default",
+			"resource":  map[string]interface{}{"type": "pod", "name": "web-0"},
+		}, doc["orchestrator"])
+		assert.NotContains(t, doc, "file")
+
+		// fields without an ECS equivalent are kept under tracee
+		fields := doc["tracee"].(map[string]interface{})
+		assert.Equal(t, float64(7), fields["processId"])
+		assert.Equal(t, "/bin/ls", fields["args"].(map[string]interface{})["pathname"])
+		assert.Equal(t, events.SchemaVersion, fields["schemaVersion"])
+	})
+
+	t.Run("file event", func(t *testing.T) {
+		doc := printECS(t, trace.Event{
+			Timestamp:     1,
+			EventID:       int(events.SecurityFileOpen),
+			EventName:     "security_file_open",
+			HostProcessID: 1234,
+			ProcessName:   "cat",
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/etc/shadow"},
+				{ArgMeta: trace.ArgMeta{Name: "dev", Type: "dev_t"}, Value: uint32(2049)},
+				{ArgMeta: trace.ArgMeta{Name: "inode", Type: "unsigned long"}, Value: uint64(1966101)},
+			},
+		})
+
+		assert.Equal(t, []interface{}{"file"}, doc["event"].(map[string]interface{})["category"])
+		assert.Equal(t, map[string]interface{}{
+			"path":   "/etc/shadow",
+			"name":   "shadow",
+			"inode":  "1966101",
+			"device": "2049",
+		}, doc["file"])
+		process := doc["process"].(map[string]interface{})
+		assert.Equal(t, "cat", process["name"])
+		assert.NotContains(t, process, "executable")
+		assert.NotContains(t, doc, "container")
+		assert.NotContains(t, doc, "orchestrator")
+	})
+}
+
+func TestWriterOutput(t *testing.T) {
+	event := trace.Event{Timestamp: 1, EventName: "openat", Args: []trace.Argument{
+		{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/etc/passwd"},
+	}}
+
+	t.Run("main output", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		p, err := printer.New(printer.Config{
+			Kind:      "json",
+			OutWriter: out,
+			ErrFile:   &syncBuffer{},
+		})
+		require.NoError(t, err)
+
+		p.Print(event)
+		p.Print(event)
+		p.Close()
+
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		require.Len(t, lines, 2)
+		for _, line := range lines {
+			var printed trace.Event
+			require.NoError(t, json.Unmarshal([]byte(line), &printed))
+			assert.Equal(t, "openat", printed.EventName)
+			assert.Equal(t, "/etc/passwd", printed.Args[0].Value)
+		}
+	})
+
+	t.Run("sink output", func(t *testing.T) {
+		out := &syncBuffer{}
+		sinkOut := &bytes.Buffer{}
+		p, err := printer.New(printer.Config{
+			Kind:    "json",
+			OutFile: out,
+			ErrFile: &syncBuffer{},
+			Sinks:   []printer.SinkConfig{{OutWriter: sinkOut, DropPolicy: printer.Block}},
+		})
+		require.NoError(t, err)
+
+		p.Print(event)
+		p.Close()
+
+		assert.Equal(t, out.String(), sinkOut.String())
+	})
+
+	t.Run("not closed", func(t *testing.T) {
+		out := &closeRecorder{}
+		p, err := printer.New(printer.Config{
+			Kind:      "json",
+			OutWriter: out,
+			ErrFile:   &syncBuffer{},
+			Gzip:      true,
+		})
+		require.NoError(t, err)
+
+		p.Print(event)
+		p.Close()
+
+		// the gzip trailer is written, but the writer is left open for its owner
+		assert.NotEmpty(t, out.String())
+		assert.False(t, out.closed)
+	})
+}
+
+// readEvents reads the timestamps of the json events printed to a file, which may be compressed
+func readEvents(t *testing.T, path string, compressed bool) []int {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var r io.Reader = f
+	if compressed {
+		gz, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		r = gz
+	}
+	var timestamps []int
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var event trace.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		timestamps = append(timestamps, event.Timestamp)
+	}
+	require.NoError(t, scanner.Err())
+	return timestamps
+}
+
+func TestRotateOutput(t *testing.T) {
+	// rotatedFiles returns the files an output was rotated to
+	rotatedFiles := func(t *testing.T, dir string, pattern string) []string {
+		paths, err := filepath.Glob(filepath.Join(dir, pattern))
+		require.NoError(t, err)
+		return paths
+	}
+
+	t.Run("main output", func(t *testing.T) {
+		dir := t.TempDir()
+		outPath := filepath.Join(dir, "tracee.json")
+		out, err := os.Create(outPath)
+		require.NoError(t, err)
+		p, err := printer.New(printer.Config{Kind: "json", OutPath: outPath, OutFile: out, ErrFile: &syncBuffer{}})
+		require.NoError(t, err)
+		rotator, ok := p.(printer.Rotator)
+		require.True(t, ok)
+
+		p.Print(trace.Event{Timestamp: 1})
+		p.Print(trace.Event{Timestamp: 2})
+		require.NoError(t, rotator.RotateOutput())
+		p.Print(trace.Event{Timestamp: 3})
+		p.Close()
+
+		assert.Equal(t, []int{1, 2}, readEvents(t, outPath, false))
+		rotated := rotatedFiles(t, dir, "tracee.*.json")
+		require.Len(t, rotated, 1)
+		assert.Equal(t, []int{3}, readEvents(t, rotated[0], false))
+	})
+
+	t.Run("compressed sinks", func(t *testing.T) {
+		dir := t.TempDir()
+		outPath := filepath.Join(dir, "tracee.json")
+		out, err := os.Create(outPath)
+		require.NoError(t, err)
+		sinkPath := filepath.Join(dir, "sink.gz")
+		sinkOut, err := os.Create(sinkPath)
+		require.NoError(t, err)
+		p, err := printer.New(printer.Config{
+			Kind:    "json",
+			OutPath: outPath,
+			OutFile: out,
+			ErrFile: &syncBuffer{},
+			Sinks: []printer.SinkConfig{
+				{OutPath: sinkPath, OutFile: sinkOut, DropPolicy: printer.Block, Kind: "json", Gzip: true},
+				{OutWriter: &syncBuffer{}, DropPolicy: printer.Block},
+			},
+		})
+		require.NoError(t, err)
+		rotator, ok := p.(printer.Rotator)
+		require.True(t, ok)
+
+		// rotations and prints may be concurrent, the events queued before a rotation are printed to the rotated files
+		const eventsNum = 100
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < eventsNum; i++ {
+				p.Print(trace.Event{Timestamp: i})
+			}
+		}()
+		wg.Wait()
+		require.NoError(t, rotator.RotateOutput())
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := eventsNum; i < 2*eventsNum; i++ {
+				p.Print(trace.Event{Timestamp: i})
+			}
+		}()
+		// rotating while events are printed doesn't lose events
+		time.Sleep(time.Millisecond)
+		require.NoError(t, rotator.RotateOutput())
+		wg.Wait()
+		p.Close()
+
+		before := readEvents(t, outPath, false)
+		sinkBefore := readEvents(t, sinkPath, true)
+		require.Len(t, before, eventsNum)
+		assert.Equal(t, before, sinkBefore)
+
+		var after, sinkAfter []int
+		for _, path := range rotatedFiles(t, dir, "tracee.*.json") {
+			after = append(after, readEvents(t, path, false)...)
+		}
+		for _, path := range rotatedFiles(t, dir, "sink.*.gz") {
+			sinkAfter = append(sinkAfter, readEvents(t, path, true)...)
+		}
+		sort.Ints(after)
+		sort.Ints(sinkAfter)
+		require.Len(t, after, eventsNum)
+		assert.Equal(t, eventsNum, after[0])
+		assert.Equal(t, after, sinkAfter)
+	})
+
+	t.Run("not a file", func(t *testing.T) {
+		p, err := printer.New(printer.Config{Kind: "json", OutWriter: &syncBuffer{}, ErrFile: &syncBuffer{}})
+		require.NoError(t, err)
+		defer p.Close()
+		_, ok := p.(printer.Rotator)
+		assert.False(t, ok)
+	})
+}
+
+// closeRecorder is a buffer which records if it was closed
+type closeRecorder struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (w *closeRecorder) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestFIFOOutput(t *testing.T) {
+	event := trace.Event{Timestamp: 1, EventName: "openat", Args: []trace.Argument{
+		{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/etc/passwd"},
+	}}
+	newFIFOPrinter := func(t *testing.T, path string, errOut *syncBuffer) printer.EventPrinter {
+		p, err := printer.New(printer.Config{
+			Kind:    "json",
+			OutFile: &syncBuffer{},
+			ErrFile: errOut,
+			Sinks:   []printer.SinkConfig{{FIFOPath: path, DropPolicy: printer.Block}},
+		})
+		require.NoError(t, err)
+		return p
+	}
+
+	t.Run("attached reader", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "events.fifo")
+		require.NoError(t, syscall.Mkfifo(path, 0600))
+		// the reader doesn't wait for a writer, so it's attached before the events are printed
+		reader, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+		require.NoError(t, err)
+		defer reader.Close()
+
+		errOut := &syncBuffer{}
+		p := newFIFOPrinter(t, path, errOut)
+		p.Print(event)
+		p.Print(event)
+		p.Epilogue(metrics.Stats{})
+		p.Close()
+
+		// the pipe is closed by the printer, so its content ends
+		out, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+		require.Len(t, lines, 2)
+		for _, line := range lines {
+			var printed trace.Event
+			require.NoError(t, json.Unmarshal([]byte(line), &printed))
+			assert.Equal(t, "openat", printed.EventName)
+			assert.Equal(t, "/etc/passwd", printed.Args[0].Value)
+		}
+		assert.Empty(t, errOut.String())
+	})
+
+	t.Run("no reader", func(t *testing.T) {
+		// the pipe is created if it doesn't exist
+		path := filepath.Join(t.TempDir(), "events.fifo")
+		errOut := &syncBuffer{}
+		p := newFIFOPrinter(t, path, errOut)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.NotZero(t, info.Mode()&os.ModeNamedPipe)
+
+		// printing isn't blocked without a reader, and the events are dropped
+		printed := make(chan struct{})
+		go func() {
+			for i := 0; i < 3; i++ {
+				p.Print(event)
+			}
+			p.Epilogue(metrics.Stats{})
+			p.Close()
+			close(printed)
+		}()
+		select {
+		case <-printed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("printing events was blocked by the missing reader")
+		}
+		assert.Contains(t, errOut.String(), "fifo output "+path+": no reader, 3 events were dropped")
+	})
+
+	t.Run("not a fifo", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "events")
+		require.NoError(t, ioutil.WriteFile(path, nil, 0600))
+		_, err := printer.New(printer.Config{
+			Kind:    "json",
+			OutFile: &syncBuffer{},
+			ErrFile: &syncBuffer{},
+			Sinks:   []printer.SinkConfig{{FIFOPath: path}},
+		})
+		var fifoErr *printer.FIFOError
+		require.True(t, errors.As(err, &fifoErr))
+		assert.Equal(t, path, fifoErr.Path)
+		assert.EqualError(t, err, "fifo output "+path+": not a named pipe")
+	})
+}
+
+func TestPartitionHourly(t *testing.T) {
+	partition := func(t *testing.T, gz bool) {
+		dir := t.TempDir()
+		outPath := filepath.Join(dir, "events.jsonl")
+		out, err := os.Create(outPath)
+		require.NoError(t, err)
+		clock := utils.NewFakeClock(time.Date(2024, 1, 2, 14, 59, 58, 0, time.UTC), 0)
+		p, err := printer.New(printer.Config{
+			Kind:            "json",
+			OutPath:         outPath,
+			OutFile:         out,
+			ErrFile:         &syncBuffer{},
+			Gzip:            gz,
+			PartitionHourly: true,
+			Clock:           clock,
+		})
+		require.NoError(t, err)
+
+		p.Print(trace.Event{Timestamp: 1})
+		clock.Advance(time.Second)
+		p.Print(trace.Event{Timestamp: 2})
+		// the events after the boundary are printed to the file of the next hour
+		clock.Advance(time.Second)
+		p.Print(trace.Event{Timestamp: 3})
+		clock.Advance(time.Minute)
+		p.Print(trace.Event{Timestamp: 4})
+		p.Close()
+
+		// the original output isn't left behind
+		assert.NoFileExists(t, outPath)
+		files, err := filepath.Glob(filepath.Join(dir, "*"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			filepath.Join(dir, "events-2024-01-02T14.jsonl"),
+			filepath.Join(dir, "events-2024-01-02T15.jsonl"),
+		}, files)
+		assert.Equal(t, []int{1, 2}, readEvents(t, files[0], gz))
+		assert.Equal(t, []int{3, 4}, readEvents(t, files[1], gz))
+	}
+
+	t.Run("json", func(t *testing.T) { partition(t, false) })
+	t.Run("compressed", func(t *testing.T) { partition(t, true) })
+
+	t.Run("clock set back", func(t *testing.T) {
+		dir := t.TempDir()
+		outPath := filepath.Join(dir, "events.jsonl")
+		out, err := os.Create(outPath)
+		require.NoError(t, err)
+		clock := utils.NewFakeClock(time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC), 0)
+		p, err := printer.New(printer.Config{Kind: "json", OutPath: outPath, OutFile: out, ErrFile: &syncBuffer{}, PartitionHourly: true, Clock: clock})
+		require.NoError(t, err)
+
+		p.Print(trace.Event{Timestamp: 1})
+		// a clock adjustment back to the previous hour doesn't reopen (and truncate) its file
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "events-2024-01-02T14.jsonl"), []byte("{}\n"), 0644))
+		clock.Advance(-time.Second)
+		p.Print(trace.Event{Timestamp: 2})
+		p.Close()
+
+		assert.Equal(t, []int{1, 2}, readEvents(t, filepath.Join(dir, "events-2024-01-02T15.jsonl"), false))
+		previous, err := ioutil.ReadFile(filepath.Join(dir, "events-2024-01-02T14.jsonl"))
+		require.NoError(t, err)
+		assert.Equal(t, "{}\n", string(previous))
+	})
+}
@@ -0,0 +1,153 @@
+package printer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// Rotator is implemented by the printers whose output files can be rotated while events are printed, e.g. on
+// demand of external log rotation tooling
+type Rotator interface {
+	// RotateOutput flushes the events printed so far to the output files, closes them, and moves on to new files
+	// named by the time of the rotation (see rotatedPath)
+	RotateOutput() error
+}
+
+// rotatedPath returns the path of an output file rotated at the given time, which is the path of the original
+// output with the timestamp before its extension (e.g. tracee.1661500000000000000.json)
+func rotatedPath(path string, now time.Time) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s.%d%s", strings.TrimSuffix(path, ext), now.UnixNano(), ext)
+}
+
+// partitionLayout is the layout of the hours in the names of partitioned output files
+const partitionLayout = "2006-01-02T15"
+
+// partitionPath returns the path of the output file of the (UTC) hour of the given time, which is the path of the
+// original output with the hour before its extension (e.g. events-2024-01-02T15.jsonl)
+func partitionPath(path string, now time.Time) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(path, ext), now.UTC().Format(partitionLayout), ext)
+}
+
+// rotatable checks if the output of a printer of the given config can be rotated, which it can if it's a regular
+// file. The gob format isn't rotated, as the files following the first one couldn't be decoded on their own
+func rotatable(config Config) bool {
+	file, ok := config.OutFile.(*os.File)
+	if !ok || config.OutPath == "" || config.Kind == "gob" {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode().IsRegular()
+}
+
+// rotatingFile is an output file which can be replaced by a new file while it's written to
+type rotatingFile struct {
+	mu   sync.Mutex
+	path string // of the original output
+	file *os.File
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Write(p)
+}
+
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// rotate closes the current file and moves on to a new file named by the given time. The current file is kept if
+// the new one can't be created
+func (f *rotatingFile) rotate(now time.Time) error {
+	return f.rotateTo(rotatedPath(f.path, now), os.O_TRUNC)
+}
+
+// partition closes the current file and moves on to the file of the hour of the given time. The file of an hour is
+// appended to, so restarting within the hour doesn't truncate the events it already has
+func (f *rotatingFile) partition(now time.Time) error {
+	return f.rotateTo(partitionPath(f.path, now), os.O_APPEND)
+}
+
+// rotateTo closes the current file and moves on to the file at the given path, created with the given flags if it
+// doesn't exist. The current file is kept if the new one can't be opened
+func (f *rotatingFile) rotateTo(path string, flag int) error {
+	newFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|flag, 0644)
+	if err != nil {
+		return fmt.Errorf("failed rotating output %s: %v", f.path, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	oldFile := f.file
+	f.file = newFile
+	if err := oldFile.Close(); err != nil {
+		return fmt.Errorf("failed rotating output %s: %v", f.path, err)
+	}
+	return nil
+}
+
+// startPartition moves the output on from the original output file to the file of the current hour, removing the
+// original file unless something was written to it
+func (f *rotatingFile) startPartition(now time.Time) error {
+	original := f.file
+	if err := f.partition(now); err != nil {
+		return err
+	}
+	if info, err := os.Stat(original.Name()); err == nil && info.Mode().IsRegular() && info.Size() == 0 {
+		os.Remove(original.Name())
+	}
+	return nil
+}
+
+// rotatingEventPrinter is an EventPrinter whose output file can be rotated, and partitioned by the hour if
+// partitioned is set
+type rotatingEventPrinter struct {
+	EventPrinter
+	out   *rotatingFile
+	gz    *gzipWriter // the compression of the output, if it's compressed
+	clock utils.Clock
+
+	partitioned bool
+	hour        time.Time // the hour of the current output file, only accessed by Print
+}
+
+func (p *rotatingEventPrinter) RotateOutput() error {
+	return p.restart(func() error { return p.out.rotate(p.clock.Now()) })
+}
+
+// restart moves the output on to another file by the given function, starting a new compressed stream in it if
+// the output is compressed, so each file is a complete compressed stream
+func (p *rotatingEventPrinter) restart(rotate func() error) error {
+	if p.gz != nil {
+		return p.gz.restart(rotate)
+	}
+	return rotate()
+}
+
+// Print moves the output on to the file of the current hour when the hour changed since the last event, which
+// completes the file of the previous hour before any event is printed to the new one. Partitions only move
+// forward, so the clock being set back doesn't write to the file of an hour already completed
+func (p *rotatingEventPrinter) Print(event trace.Event) {
+	if p.partitioned {
+		now := p.clock.Now()
+		if hour := now.UTC().Truncate(time.Hour); hour.After(p.hour) {
+			// the hour is moved on even if the file can't be opened, so it isn't retried (and reported) per event
+			p.hour = hour
+			if err := p.restart(func() error { return p.out.partition(now) }); err != nil {
+				p.Error(err)
+			}
+		}
+	}
+	p.EventPrinter.Print(event)
+}
@@ -0,0 +1,329 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/metrics"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+const (
+	// defaultSyslogMaxMessageSize is the size of the messages sent to the collector, if not configured otherwise,
+	// which is the size RFC 5424 receivers should accept
+	defaultSyslogMaxMessageSize = 2048
+	// syslogDialTimeout is the longest time connecting to the collector may take
+	syslogDialTimeout = 5 * time.Second
+	// syslogWriteTimeout is the longest time sending a message to the collector may take
+	syslogWriteTimeout = 5 * time.Second
+	// syslogSDID is the SD-ID of the structured data element of the context of events, and syslogArgsSDID the one of
+	// their arguments. 32473 is the enterprise number reserved for documentation (RFC 5612)
+	syslogSDID     = "tracee@32473"
+	syslogArgsSDID = "args@32473"
+	// syslogTruncatedMarker is appended to argument values which were truncated to fit the message size
+	syslogTruncatedMarker = "...(truncated)"
+	// syslogMaxNameLength is the longest MSGID and SD-NAME of RFC 5424
+	syslogMaxNameLength = 32
+)
+
+// SyslogConfig configures sending the events to a syslog collector, as RFC 5424 messages of structured data
+type SyslogConfig struct {
+	// Network is the transport of the messages, "udp" (default) or "tcp". Messages are sent in datagrams of their
+	// own over UDP (RFC 5426), and framed by their length over TCP (octet counting, RFC 6587)
+	Network string
+	// Address is the host:port of the collector (e.g. syslog:514)
+	Address string
+	// Facility and Severity are of the priority of the messages, as numbered by RFC 5424 (e.g. 1 for user-level
+	// messages and 6 for informational messages)
+	Facility int
+	Severity int
+	// MaxMessageSize is the size of the messages, in bytes (default: 2048). The arguments of events whose message
+	// would be larger are truncated, marked by a truncated parameter of the event's structured data
+	MaxMessageSize int
+}
+
+// syslogEventPrinter sends events to a syslog collector. The connection to the collector is opened again once
+// sending a message failed, and the events which couldn't be sent are dropped
+type syslogEventPrinter struct {
+	config     SyslogConfig
+	relativeTS bool
+	procID     string
+	err        io.WriteCloser
+
+	conn    net.Conn // nil once sending failed, until connected again
+	failing bool
+	failed  int
+}
+
+func newSyslogEventPrinter(config SyslogConfig, printerConfig Config) (*syslogEventPrinter, error) {
+	if config.Network == "" {
+		config.Network = "udp"
+	}
+	if config.Network != "udp" && config.Network != "tcp" {
+		return nil, fmt.Errorf("invalid syslog network: %s", config.Network)
+	}
+	if config.Facility < 0 || config.Facility > 23 {
+		return nil, fmt.Errorf("invalid syslog facility: %d", config.Facility)
+	}
+	if config.Severity < 0 || config.Severity > 7 {
+		return nil, fmt.Errorf("invalid syslog severity: %d", config.Severity)
+	}
+	if config.MaxMessageSize <= 0 {
+		config.MaxMessageSize = defaultSyslogMaxMessageSize
+	}
+	return &syslogEventPrinter{
+		config:     config,
+		relativeTS: printerConfig.RelativeTS,
+		procID:     strconv.Itoa(os.Getpid()),
+		err:        printerConfig.ErrFile,
+	}, nil
+}
+
+func (p *syslogEventPrinter) Init() error {
+	conn, err := net.DialTimeout(p.config.Network, p.config.Address, syslogDialTimeout)
+	if err != nil {
+		return fmt.Errorf("failed connecting to syslog collector %s: %v", p.config.Address, err)
+	}
+	p.conn = conn
+	return nil
+}
+
+func (p *syslogEventPrinter) Preamble() {}
+
+func (p *syslogEventPrinter) Print(event trace.Event) {
+	if err := p.send(p.message(event)); err != nil {
+		p.failed++
+		// only the first failure of consecutive failing messages is reported
+		if !p.failing {
+			p.Error(fmt.Errorf("failed sending events to syslog collector %s: %v", p.config.Address, err))
+		}
+		p.failing = true
+		return
+	}
+	p.failing = false
+}
+
+// send sends a message to the collector, connecting to it again if sending the previous message failed
+func (p *syslogEventPrinter) send(message []byte) error {
+	if p.conn == nil {
+		conn, err := net.DialTimeout(p.config.Network, p.config.Address, syslogDialTimeout)
+		if err != nil {
+			return err
+		}
+		p.conn = conn
+	}
+	if p.config.Network == "tcp" {
+		message = append([]byte(strconv.Itoa(len(message))+" "), message...)
+	}
+	p.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+	if _, err := p.conn.Write(message); err != nil {
+		p.conn.Close()
+		p.conn = nil
+		return err
+	}
+	return nil
+}
+
+// message formats an event as an RFC 5424 message, of the context of the event and of its arguments as structured
+// data elements, and without a MSG part. Arguments are truncated (or dropped) to fit the message size
+func (p *syslogEventPrinter) message(event trace.Event) []byte {
+	args := make([]string, 0, len(event.Args))
+	size := len(p.header(event, false)) + len("["+syslogArgsSDID+"]")
+	for _, arg := range event.Args {
+		param := syslogParam(arg.Name, syslogArgValue(arg.Value))
+		args = append(args, param)
+		size += len(param)
+	}
+	truncated := size > p.config.MaxMessageSize
+	if truncated {
+		room := p.config.MaxMessageSize - len(p.header(event, true)) - len("["+syslogArgsSDID+"]")
+		kept := args[:0]
+		for i, arg := range event.Args {
+			if len(args[i]) <= room {
+				kept = append(kept, args[i])
+				room -= len(args[i])
+				continue
+			}
+			// the argument which doesn't fit is cut to the room left, and the following ones are dropped
+			if param, ok := syslogTruncatedParam(arg.Name, syslogArgValue(arg.Value), room); ok {
+				kept = append(kept, param)
+			}
+			break
+		}
+		args = kept
+	}
+
+	var b strings.Builder
+	b.WriteString(p.header(event, truncated))
+	if len(args) > 0 {
+		b.WriteString("[" + syslogArgsSDID)
+		for _, arg := range args {
+			b.WriteString(arg)
+		}
+		b.WriteString("]")
+	}
+	return []byte(b.String())
+}
+
+// header formats the header of the message of an event, followed by the structured data element of its context
+func (p *syslogEventPrinter) header(event trace.Event, truncated bool) string {
+	timestamp := "-"
+	if !p.relativeTS {
+		timestamp = time.Unix(0, int64(event.Timestamp)).UTC().Format("2006-01-02T15:04:05.000000Z")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s tracee %s %s [%s", p.config.Facility*8+p.config.Severity, timestamp,
+		syslogHeaderField(event.HostName, 255), p.procID, syslogHeaderField(event.EventName, syslogMaxNameLength), syslogSDID)
+	params := []struct {
+		name  string
+		value string
+	}{
+		{"processName", event.ProcessName},
+		{"processId", strconv.Itoa(event.ProcessID)},
+		{"hostProcessId", strconv.Itoa(event.HostProcessID)},
+		{"threadId", strconv.Itoa(event.ThreadID)},
+		{"hostThreadId", strconv.Itoa(event.HostThreadID)},
+		{"parentProcessId", strconv.Itoa(event.ParentProcessID)},
+		{"hostParentProcessId", strconv.Itoa(event.HostParentProcessID)},
+		{"userId", strconv.Itoa(event.UserID)},
+		{"mountNamespace", strconv.Itoa(event.MountNS)},
+		{"pidNamespace", strconv.Itoa(event.PIDNS)},
+		{"returnValue", strconv.Itoa(event.ReturnValue)},
+		{"containerId", event.ContainerID},
+		{"containerImage", event.ContainerImage},
+		{"containerName", event.ContainerName},
+		{"podName", event.PodName},
+		{"podNamespace", event.PodNamespace},
+		{"podUID", event.PodUID},
+	}
+	for _, param := range params {
+		// e.g. the container fields of host events
+		if param.value == "" {
+			continue
+		}
+		b.WriteString(syslogParam(param.name, param.value))
+	}
+	if truncated {
+		b.WriteString(syslogParam("truncated", "true"))
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+// syslogArgValue formats the value of an argument as a parameter value. Strings are taken as they are, and other
+// values are json encoded (e.g. ["ls","-l"] for string arrays)
+func syslogArgValue(value interface{}) string {
+	if str, ok := value.(string); ok {
+		return str
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// syslogParam formats an SD-PARAM, preceded by the space separating it from the previous one
+func syslogParam(name string, value string) string {
+	var b strings.Builder
+	b.WriteString(" " + syslogName(name) + `="`)
+	for _, r := range value {
+		b.WriteString(syslogEscape(r))
+	}
+	b.WriteString(`"`)
+	return b.String()
+}
+
+// syslogTruncatedParam formats an SD-PARAM whose value is cut to fit the given size, marked by
+// syslogTruncatedMarker. It returns false if even the name of the parameter doesn't fit
+func syslogTruncatedParam(name string, value string, size int) (string, bool) {
+	var b strings.Builder
+	b.WriteString(" " + syslogName(name) + `="`)
+	room := size - b.Len() - len(syslogTruncatedMarker+`"`)
+	if room < 0 {
+		return "", false
+	}
+	// values are cut between runes, so escaped characters and runes aren't split
+	for _, r := range value {
+		escaped := syslogEscape(r)
+		if len(escaped) > room {
+			break
+		}
+		b.WriteString(escaped)
+		room -= len(escaped)
+	}
+	b.WriteString(syslogTruncatedMarker + `"`)
+	return b.String(), true
+}
+
+// syslogEscape escapes a rune of a PARAM-VALUE, in which '"', '\' and ']' are escaped by a backslash. Values must
+// be UTF-8, and the bytes of invalid UTF-8 are ranged over as utf8.RuneError, so they are replaced by it
+func syslogEscape(r rune) string {
+	switch r {
+	case '"', '\\', ']':
+		return `\` + string(r)
+	}
+	return string(r)
+}
+
+// syslogName formats an SD-NAME, of up to 32 printable US-ASCII characters other than '=', ' ', ']' and '"'.
+// Other characters are replaced by '_'
+func syslogName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name) && i < syslogMaxNameLength; i++ {
+		c := name[i]
+		if c <= ' ' || c > '~' || c == '=' || c == ']' || c == '"' {
+			c = '_'
+		}
+		b.WriteByte(c)
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// syslogHeaderField formats a field of the header of up to the given number of printable US-ASCII characters, or
+// the NILVALUE ("-") if it's empty. Other characters are replaced by '_'
+func syslogHeaderField(value string, maxLength int) string {
+	if value == "" {
+		return "-"
+	}
+	var b strings.Builder
+	for i := 0; i < len(value) && i < maxLength; i++ {
+		c := value[i]
+		if c <= ' ' || c > '~' {
+			c = '_'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func (p *syslogEventPrinter) Error(err error) {
+	fmt.Fprintf(p.err, "%v\n", err)
+}
+
+func (p *syslogEventPrinter) Epilogue(stats metrics.Stats) {}
+
+// Close closes the connection to the collector, and reports the events which couldn't be sent
+func (p *syslogEventPrinter) Close() {
+	if p.failed > 0 {
+		p.Error(fmt.Errorf("failed sending %d events to syslog collector %s", p.failed, p.config.Address))
+	}
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+// syslogSinkName is the name of a sink sending to a syslog collector
+func syslogSinkName(config SyslogConfig) string {
+	return fmt.Sprintf("syslog:%s", config.Address)
+}
@@ -0,0 +1,219 @@
+package printer_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/cmd/tracee-ebpf/internal/printer"
+	"github.com/aquasecurity/tracee/pkg/metrics"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syslogLine matches an RFC 5424 message of structured data only: PRI, VERSION, TIMESTAMP, HOSTNAME, APP-NAME,
+// PROCID, MSGID and SD-ELEMENTs of SD-PARAMs whose values escape '"', '\' and ']'
+var syslogLine = regexp.MustCompile(`^<\d{1,3}>1 (-|\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}Z) [!-~]{1,255} [!-~]{1,48} [!-~]{1,128} [!-~]{1,32} (\[[!#-<>-\\^-~]{1,32}( [!#-<>-\\^-~]{1,32}="([^"\\\]]|\\["\\\]])*")*\])+$`)
+
+func newSyslogPrinter(t *testing.T, config printer.SyslogConfig, errOut *syncBuffer) printer.EventPrinter {
+	p, err := printer.New(printer.Config{
+		Kind:    "ignore",
+		OutFile: &syncBuffer{},
+		ErrFile: errOut,
+		Sinks:   []printer.SinkConfig{{Syslog: &config, DropPolicy: printer.Block}},
+	})
+	require.NoError(t, err)
+	return p
+}
+
+func syslogEvent() trace.Event {
+	return trace.Event{
+		Timestamp:           int(time.Date(2022, 8, 26, 10, 27, 52, 416361017, time.UTC).UnixNano()),
+		ProcessID:           1,
+		HostProcessID:       4242,
+		ThreadID:            1,
+		HostThreadID:        4242,
+		ParentProcessID:     0,
+		HostParentProcessID: 4200,
+		UserID:              0,
+		MountNS:             4026532280,
+		PIDNS:               4026532283,
+		ProcessName:         "sh",
+		HostName:            "web-1",
+		ContainerID:         "0123456789ab",
+		EventName:           "openat",
+		ArgsNum:             3,
+		Args: []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: `/tmp/a "b" [c]\d`},
+			{ArgMeta: trace.ArgMeta{Name: "flags", Type: "int"}, Value: int32(577)},
+			{ArgMeta: trace.ArgMeta{Name: "argv", Type: "const char**"}, Value: []string{"ls", "-l"}},
+		},
+	}
+}
+
+func TestSyslogMessage(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	errOut := &syncBuffer{}
+	p := newSyslogPrinter(t, printer.SyslogConfig{Address: conn.LocalAddr().String(), Facility: 4, Severity: 5}, errOut)
+	p.Print(syslogEvent())
+	p.Close()
+
+	buf := make([]byte, 4096)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	message := string(buf[:n])
+	assert.Regexp(t, syslogLine, message)
+	assert.Equal(t, `<37>1 2022-08-26T10:27:52.416361Z web-1 tracee `+strconv.Itoa(os.Getpid())+` openat `+
+		`[tracee@32473 processName="sh" processId="1" hostProcessId="4242" threadId="1" hostThreadId="4242" parentProcessId="0" hostParentProcessId="4200" userId="0" mountNamespace="4026532280" pidNamespace="4026532283" returnValue="0" containerId="0123456789ab"]`+
+		`[args@32473 pathname="/tmp/a \"b\" [c\]\\d" flags="577" argv="[\"ls\",\"-l\"\]"]`, message)
+	assert.Empty(t, errOut.String())
+}
+
+func TestSyslogTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// messages are framed by their length (octet counting), so they may contain newlines
+		reader := bufio.NewReader(conn)
+		var messages []string
+		for {
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				break
+			}
+			size, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+			if err != nil {
+				break
+			}
+			message := make([]byte, size)
+			if _, err := io.ReadFull(reader, message); err != nil {
+				break
+			}
+			messages = append(messages, string(message))
+		}
+		received <- messages
+	}()
+
+	errOut := &syncBuffer{}
+	p := newSyslogPrinter(t, printer.SyslogConfig{Network: "tcp", Address: listener.Addr().String(), Facility: 1, Severity: 6}, errOut)
+	event := syslogEvent()
+	event.Args[0].Value = "/tmp/multi\nline"
+	p.Print(event)
+	p.Print(syslogEvent())
+	p.Close()
+
+	select {
+	case messages := <-received:
+		require.Len(t, messages, 2)
+		assert.True(t, strings.HasPrefix(messages[0], "<14>1 "))
+		assert.Contains(t, messages[0], `pathname="/tmp/multi`+"\n"+`line"`)
+		assert.Regexp(t, syslogLine, messages[1])
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no syslog messages received")
+	}
+	assert.Empty(t, errOut.String())
+}
+
+func TestSyslogTruncation(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	receive := func(t *testing.T) string {
+		buf := make([]byte, 8192)
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+
+	const maxSize = 600
+	p := newSyslogPrinter(t, printer.SyslogConfig{Address: conn.LocalAddr().String(), Facility: 1, Severity: 6, MaxMessageSize: maxSize}, &syncBuffer{})
+	defer p.Close()
+
+	// messages which fit aren't truncated
+	p.Print(syslogEvent())
+	message := receive(t)
+	assert.NotContains(t, message, `truncated="true"`)
+
+	// the argument which doesn't fit is cut between escaped characters, and the following ones are dropped
+	event := syslogEvent()
+	event.Args[0].Value = strings.Repeat(`"ü]`, 200)
+	p.Print(event)
+	message = receive(t)
+	assert.LessOrEqual(t, len(message), maxSize)
+	assert.Greater(t, len(message), maxSize-10)
+	assert.Regexp(t, syslogLine, message)
+	assert.Contains(t, message, ` containerId="0123456789ab" truncated="true"]`)
+	assert.Regexp(t, `\[args@32473 pathname="(\\"ü\\\])+(\\"ü?)?\.\.\.\(truncated\)"\]$`, message)
+	assert.NotContains(t, message, "argv=")
+
+	// arguments following one which isn't truncated are kept
+	event = syslogEvent()
+	event.Args[2].Value = []string{strings.Repeat("a", 1000)}
+	p.Print(event)
+	message = receive(t)
+	assert.LessOrEqual(t, len(message), maxSize)
+	assert.Regexp(t, syslogLine, message)
+	assert.Contains(t, message, `flags="577" argv="[\"aaaa`)
+}
+
+func TestSyslogErrors(t *testing.T) {
+	for _, config := range []printer.SyslogConfig{
+		{Network: "unix", Address: "127.0.0.1:514"},
+		{Address: "127.0.0.1:514", Facility: 24},
+		{Address: "127.0.0.1:514", Severity: -1},
+	} {
+		_, err := printer.New(printer.Config{Kind: "ignore", OutFile: &syncBuffer{}, ErrFile: &syncBuffer{}, Sinks: []printer.SinkConfig{{Syslog: &config}}})
+		assert.Error(t, err)
+	}
+
+	// the collector is reported unreachable once it refuses connections
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	_, err = printer.New(printer.Config{Kind: "ignore", OutFile: &syncBuffer{}, ErrFile: &syncBuffer{}, Sinks: []printer.SinkConfig{{Syslog: &printer.SyslogConfig{Network: "tcp", Address: address}}}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed connecting to syslog collector "+address)
+
+	// events which can't be sent are dropped, and reported on close
+	listener, err = net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+	errOut := &syncBuffer{}
+	p := newSyslogPrinter(t, printer.SyslogConfig{Network: "tcp", Address: listener.Addr().String()}, errOut)
+	(<-accepted).Close()
+	require.NoError(t, listener.Close())
+	for i := 0; i < 10; i++ {
+		p.Print(syslogEvent())
+		time.Sleep(10 * time.Millisecond)
+	}
+	p.Epilogue(metrics.Stats{})
+	p.Close()
+	assert.Equal(t, 1, strings.Count(errOut.String(), "failed sending events to syslog collector"))
+	assert.Regexp(t, `failed sending \d+ events to syslog collector`, errOut.String())
+}
@@ -7,6 +7,7 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/aquasecurity/libbpfgo/helpers"
 	"github.com/aquasecurity/tracee/cmd/tracee-ebpf/flags"
@@ -26,6 +27,10 @@ var traceeInstallPath string
 var enrich bool
 var version string
 
+// controlFlags and startControl serve the control API, when built with the control tag (see control.go)
+var controlFlags []cli.Flag
+var startControl func(ctx context.Context, c *cli.Context, t *tracee.Tracee, p printer.EventPrinter) error
+
 func main() {
 	app := &cli.App{
 		Name:    "Tracee",
@@ -43,6 +48,10 @@ func main() {
 				return nil
 			}
 
+			if outputPath := c.String("verify-captures"); outputPath != "" {
+				return verifyCaptures(outputPath)
+			}
+
 			// enable debug mode if debug flag is passed
 			if c.Bool("debug") {
 				err := debug.Enable()
@@ -81,11 +90,12 @@ func main() {
 			}
 
 			cfg := tracee.Config{
-				PerfBufferSize:     c.Int("perf-buffer-size"),
-				BlobPerfBufferSize: c.Int("blob-perf-buffer-size"),
-				Debug:              debug,
-				OSInfo:             OSInfo,
-				ContainersEnrich:   enrich,
+				PerfBufferSize:      c.Int("perf-buffer-size"),
+				BlobPerfBufferSize:  c.Int("blob-perf-buffer-size"),
+				Debug:               debug,
+				OSInfo:              OSInfo,
+				ContainersEnrich:    enrich,
+				WriteThenExecWindow: c.Duration("write-then-exec-window"),
 			}
 
 			containerRuntimesSlice := c.StringSlice("crs")
@@ -150,6 +160,7 @@ func main() {
 			}
 
 			printerConfig.ContainerMode = containerMode
+			output.LostChannelSize = c.Int("lost-channel-size")
 			cfg.Output = &output
 
 			capsCfgSlice := c.StringSlice("caps")
@@ -205,6 +216,14 @@ func main() {
 				return fmt.Errorf("error creating Tracee: %v", err)
 			}
 
+			if c.Bool("self-test") {
+				if err := t.SelfTest(); err != nil {
+					t.Close()
+					return err
+				}
+				logger.Info("self-test passed: files are captured and hashed into the output directory")
+			}
+
 			if server.ShouldStart(c) {
 				httpServer := server.New(c.String(server.ListenEndpointFlag), debug)
 
@@ -228,6 +247,13 @@ func main() {
 				go httpServer.Start()
 			}
 
+			// the deliveries to kafka are counted in the stats of tracee
+			for _, sink := range printerConfig.Sinks {
+				if sink.Kafka != nil {
+					sink.Kafka.Stats = t.Stats()
+				}
+			}
+
 			printer, err := printer.New(printerConfig)
 			if err != nil {
 				return err
@@ -250,7 +276,17 @@ func main() {
 				}
 			}()
 
+			rotateOutputOnSignal(ctx, printer)
+
+			if startControl != nil {
+				if err := startControl(ctx, c, t, printer); err != nil {
+					return err
+				}
+			}
+
+			printerDone := make(chan struct{})
 			go func() {
+				defer close(printerDone)
 				printer.Preamble()
 				for {
 					select {
@@ -266,9 +302,33 @@ func main() {
 
 			// always print stats before exiting
 			defer func() {
-				stats := t.Stats()
-				printer.Epilogue(*stats)
-				printer.Close()
+				// tracee may stop by itself (e.g. once it emitted the maximal number of events), so the events it
+				// emitted before stopping are flushed
+				cancel()
+				<-printerDone
+				flushed := make(chan struct{})
+				go func() {
+					defer close(flushed)
+					for len(cfg.ChanEvents) > 0 {
+						printer.Print(<-cfg.ChanEvents)
+					}
+					for len(cfg.ChanErrors) > 0 {
+						printer.Error(<-cfg.ChanErrors)
+					}
+					stats := t.Stats()
+					printer.Epilogue(*stats)
+					printer.Close()
+				}()
+				if cfg.Output.DrainTimeout <= 0 {
+					<-flushed
+					return
+				}
+				// slow outputs (e.g. a collector not responding) are abandoned once the drain timeout passed
+				select {
+				case <-flushed:
+				case <-time.After(cfg.Output.DrainTimeout):
+					fmt.Fprintf(os.Stderr, "abandoned flushing the output after a drain timeout of %s, the events not yet written were dropped\n", cfg.Output.DrainTimeout)
+				}
 			}()
 
 			// initialize tracee for running
@@ -279,7 +339,7 @@ func main() {
 
 			ensureRuntimeCapabilities(OSInfo, &cfg, &capsCfg)
 
-			// run until ctx is cancelled by signal
+			// run until ctx is cancelled by signal, or the events limit is reached
 			return t.Run(ctx)
 		},
 		Flags: []cli.Flag{
@@ -289,6 +349,14 @@ func main() {
 				Value:   false,
 				Usage:   "just list tracable events",
 			},
+			&cli.StringFlag{
+				Name:  "verify-captures",
+				Usage: "verify the files captured in the given output directory against the hashes recorded for them, and exit",
+			},
+			&cli.BoolFlag{
+				Name:  "self-test",
+				Usage: "validate that executed files are captured and hashed into the output directory before tracing, and exit if they aren't",
+			},
 			&cli.StringSliceFlag{
 				Name:    "trace",
 				Aliases: []string{"t"},
@@ -329,6 +397,16 @@ func main() {
 				Value: 1024, // 4 MB of contigous pages
 				Usage: "size, in pages, of the internal perf ring buffer used to send blobs from the kernel",
 			},
+			&cli.IntFlag{
+				Name:  "lost-channel-size",
+				Value: 256, // 2 KB of buffered loss reports
+				Usage: "capacity of the internal channel used to report lost events from the perf ring buffer",
+			},
+			&cli.DurationFlag{
+				Name:  "write-then-exec-window",
+				Value: time.Minute,
+				Usage: "maximal time between writing a file and executing it for the write_then_exec event to be derived",
+			},
 			&cli.BoolFlag{
 				Name:  "debug",
 				Value: false,
@@ -373,12 +451,39 @@ func main() {
 		},
 	}
 
+	app.Flags = append(app.Flags, controlFlags...)
+
 	err := app.Run(os.Args)
 	if err != nil {
 		logger.Fatal("app", "error", err)
 	}
 }
 
+// rotateOutputOnSignal rotates the output files of the printer whenever SIGUSR1 is received, until the context is
+// done, so external log rotation tooling can ask for a new file once it moved the current one
+func rotateOutputOnSignal(ctx context.Context, p printer.EventPrinter) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR1)
+	go func() {
+		defer signal.Stop(sig)
+		for {
+			select {
+			case <-sig:
+				rotator, ok := p.(printer.Rotator)
+				if !ok {
+					logger.Warn("the output isn't a file, so it can't be rotated")
+					continue
+				}
+				if err := rotator.RotateOutput(); err != nil {
+					logger.Error("rotating the output", "error", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
 func checkCommandIsHelp(s []string) bool {
 	if len(s) == 1 && s[0] == "help" {
 		return true
@@ -414,6 +519,21 @@ func getPad(padChar string, padLength int) (pad string) {
 	return
 }
 
+// verifyCaptures prints the captured files whose content doesn't match their recorded hashes
+func verifyCaptures(outputPath string) error {
+	mismatches, err := tracee.VerifyCaptures(outputPath)
+	if err != nil {
+		return fmt.Errorf("error verifying captured files: %v", err)
+	}
+	for _, m := range mismatches {
+		fmt.Printf("%s: expected sha256 %s, found %s\n", m.Path, m.ExpectedHash, m.ActualHash)
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%d captured files don't match their recorded hashes", len(mismatches))
+	}
+	return nil
+}
+
 func printList() {
 	padChar, firstPadLen, secondPadLen := " ", 9, 36
 	titleHeaderPadFirst := getPad(padChar, firstPadLen)
@@ -27,6 +27,7 @@ func TestDecodeContext(t *testing.T) {
 		Comm:        [16]byte{1, 3, 5, 3, 1, 5, 56, 6, 7, 32, 2, 4},
 		UtsName:     [16]byte{5, 6, 7, 8, 9, 4, 3, 2},
 		EventID:     0,
+		Gid:         5432,
 		Retval:      0,
 		StackID:     0,
 		Argnum:      0,
@@ -37,7 +37,7 @@ type Context struct {
 	UtsName     [16]byte
 	Flags       uint32
 	EventID     events.ID //int32
-	Pad2        [4]byte
+	Gid         uint32
 	Retval      int64
 	StackID     uint32
 	ProcessorId uint16
@@ -0,0 +1,280 @@
+//go:build control
+// +build control
+
+// Package control serves an API controlling a running tracee (e.g. by an orchestrator managing a fleet of them),
+// instead of signals. The API is a gRPC service whose messages are encoded as JSON, so clients in any language can
+// call it without generated code.
+//
+// The API has no authentication of its own, and whoever can connect to it can pause capturing and read the profile
+// of the executed files. It's served on a unix socket which only its owner (i.e. the user running tracee, usually
+// root) can connect to (see Listen).
+//
+// Only the filters applied in userspace (e.g. the argument and expression filters) can be reloaded. The traced events
+// and the scope of the traced processes are set in the bpf code once tracee starts, and reloads changing them are
+// refused.
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/aquasecurity/tracee/pkg/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceName is the name of the control gRPC service
+const ServiceName = "tracee.control.v1.Control"
+
+// Tracer is the part of tracee controlled by the API
+type Tracer interface {
+	Stats() *metrics.Stats
+	WriteProfile(w io.Writer) error
+	SetCapturePaused(paused bool)
+	CapturePaused() bool
+}
+
+// OutputRotator is implemented by the tracers whose output files can be rotated
+type OutputRotator interface {
+	RotateOutput() error
+}
+
+// FilterReloader is implemented by the tracers whose filters can be reloaded, given as the values of --trace
+type FilterReloader interface {
+	ReloadFilters(filters []string) error
+}
+
+type StatsRequest struct{}
+
+type StatsResponse struct {
+	EventCount      int32 `json:"eventCount"`
+	EventsFiltered  int32 `json:"eventsFiltered"`
+	NetEventCount   int32 `json:"netEventCount"`
+	ErrorCount      int32 `json:"errorCount"`
+	LostEvents      int32 `json:"lostEvents"`
+	LostWrites      int32 `json:"lostWrites"`
+	LostNetEvents   int32 `json:"lostNetEvents"`
+	CapturedFiles   int32 `json:"capturedFiles"`
+	CapturedBytes   int32 `json:"capturedBytes"`
+	HashCacheHits   int32 `json:"hashCacheHits"`
+	HashCacheMisses int32 `json:"hashCacheMisses"`
+}
+
+type ProfileRequest struct{}
+
+type ProfileResponse struct {
+	// Profile is the profile of the executed files, as written to tracee.profile
+	Profile json.RawMessage `json:"profile"`
+}
+
+type SetCaptureRequest struct {
+	Paused bool `json:"paused"`
+}
+
+type SetCaptureResponse struct {
+	Paused bool `json:"paused"`
+}
+
+type RotateOutputRequest struct{}
+
+type RotateOutputResponse struct{}
+
+type ReloadFiltersRequest struct {
+	// Filters replace the filters of the running tracee, as given to --trace
+	Filters []string `json:"filters"`
+}
+
+type ReloadFiltersResponse struct{}
+
+// controlServer implements the control service
+type controlServer struct {
+	tracer Tracer
+}
+
+func (s *controlServer) Stats(ctx context.Context, req *StatsRequest) (*StatsResponse, error) {
+	stats := s.tracer.Stats()
+	return &StatsResponse{
+		EventCount:      stats.EventCount.Read(),
+		EventsFiltered:  stats.EventsFiltered.Read(),
+		NetEventCount:   stats.NetEvCount.Read(),
+		ErrorCount:      stats.ErrorCount.Read(),
+		LostEvents:      stats.LostEvCount.Read(),
+		LostWrites:      stats.LostWrCount.Read(),
+		LostNetEvents:   stats.LostNtCount.Read(),
+		CapturedFiles:   stats.CapFileCount.Read(),
+		CapturedBytes:   stats.CapBytesCount.Read(),
+		HashCacheHits:   stats.HashCacheHits.Read(),
+		HashCacheMisses: stats.HashCacheMisses.Read(),
+	}, nil
+}
+
+func (s *controlServer) Profile(ctx context.Context, req *ProfileRequest) (*ProfileResponse, error) {
+	profile := &bytes.Buffer{}
+	if err := s.tracer.WriteProfile(profile); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed writing the profile: %v", err)
+	}
+	return &ProfileResponse{Profile: profile.Bytes()}, nil
+}
+
+func (s *controlServer) SetCapture(ctx context.Context, req *SetCaptureRequest) (*SetCaptureResponse, error) {
+	s.tracer.SetCapturePaused(req.Paused)
+	return &SetCaptureResponse{Paused: s.tracer.CapturePaused()}, nil
+}
+
+func (s *controlServer) RotateOutput(ctx context.Context, req *RotateOutputRequest) (*RotateOutputResponse, error) {
+	rotator, ok := s.tracer.(OutputRotator)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "the output isn't a file, so it can't be rotated")
+	}
+	if err := rotator.RotateOutput(); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed rotating the output: %v", err)
+	}
+	return &RotateOutputResponse{}, nil
+}
+
+func (s *controlServer) ReloadFilters(ctx context.Context, req *ReloadFiltersRequest) (*ReloadFiltersResponse, error) {
+	reloader, ok := s.tracer.(FilterReloader)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "the filters of this tracer can't be reloaded")
+	}
+	if err := reloader.ReloadFilters(req.Filters); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed reloading the filters: %v", err)
+	}
+	return &ReloadFiltersResponse{}, nil
+}
+
+// unaryHandler adapts a method of the control server to a gRPC method handler, given a new request to decode
+func unaryHandler(method string, newReq func() interface{}, call func(s *controlServer, ctx context.Context, req interface{}) (interface{}, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: method,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := newReq()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(srv.(*controlServer), ctx, req)
+			}
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/" + method}
+			return interceptor(ctx, req, info, handler)
+		},
+	}
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		unaryHandler("Stats", func() interface{} { return &StatsRequest{} },
+			func(s *controlServer, ctx context.Context, req interface{}) (interface{}, error) {
+				return s.Stats(ctx, req.(*StatsRequest))
+			}),
+		unaryHandler("Profile", func() interface{} { return &ProfileRequest{} },
+			func(s *controlServer, ctx context.Context, req interface{}) (interface{}, error) {
+				return s.Profile(ctx, req.(*ProfileRequest))
+			}),
+		unaryHandler("SetCapture", func() interface{} { return &SetCaptureRequest{} },
+			func(s *controlServer, ctx context.Context, req interface{}) (interface{}, error) {
+				return s.SetCapture(ctx, req.(*SetCaptureRequest))
+			}),
+		unaryHandler("RotateOutput", func() interface{} { return &RotateOutputRequest{} },
+			func(s *controlServer, ctx context.Context, req interface{}) (interface{}, error) {
+				return s.RotateOutput(ctx, req.(*RotateOutputRequest))
+			}),
+		unaryHandler("ReloadFilters", func() interface{} { return &ReloadFiltersRequest{} },
+			func(s *controlServer, ctx context.Context, req interface{}) (interface{}, error) {
+				return s.ReloadFilters(ctx, req.(*ReloadFiltersRequest))
+			}),
+	},
+}
+
+// Listen listens for the control API on a unix socket at the given path, which only its owner can connect to. A
+// socket left at the path (e.g. by a previous tracee which was killed) is replaced, while other files aren't
+func Listen(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// NewServer returns a gRPC server serving the control API of the given tracer
+func NewServer(tracer Tracer, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(append([]grpc.ServerOption{grpc.ForceServerCodec(jsonCodec{})}, opts...)...)
+	s.RegisterService(&serviceDesc, &controlServer{tracer: tracer})
+	return s
+}
+
+// Client calls the control API of a tracee
+type Client struct {
+	conn grpc.ClientConnInterface
+}
+
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{conn: conn}
+}
+
+func (c *Client) invoke(ctx context.Context, method string, req interface{}, resp interface{}) error {
+	return c.conn.Invoke(ctx, "/"+ServiceName+"/"+method, req, resp, grpc.ForceCodec(jsonCodec{}))
+}
+
+// Stats returns the counters of the running tracee
+func (c *Client) Stats(ctx context.Context) (*StatsResponse, error) {
+	resp := &StatsResponse{}
+	return resp, c.invoke(ctx, "Stats", &StatsRequest{}, resp)
+}
+
+// Profile returns the profile of the executed files captured so far
+func (c *Client) Profile(ctx context.Context) (json.RawMessage, error) {
+	resp := &ProfileResponse{}
+	if err := c.invoke(ctx, "Profile", &ProfileRequest{}, resp); err != nil {
+		return nil, err
+	}
+	return resp.Profile, nil
+}
+
+// SetCapture pauses or resumes capturing artifacts
+func (c *Client) SetCapture(ctx context.Context, paused bool) (*SetCaptureResponse, error) {
+	resp := &SetCaptureResponse{}
+	return resp, c.invoke(ctx, "SetCapture", &SetCaptureRequest{Paused: paused}, resp)
+}
+
+// RotateOutput moves the output of the running tracee on to new files
+func (c *Client) RotateOutput(ctx context.Context) error {
+	return c.invoke(ctx, "RotateOutput", &RotateOutputRequest{}, &RotateOutputResponse{})
+}
+
+// ReloadFilters replaces the filters of the running tracee, given as the values of --trace
+func (c *Client) ReloadFilters(ctx context.Context, filters []string) error {
+	return c.invoke(ctx, "ReloadFilters", &ReloadFiltersRequest{Filters: filters}, &ReloadFiltersResponse{})
+}
+
+// jsonCodec encodes the messages of the control API as JSON
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+func (jsonCodec) Name() string { return "json" }
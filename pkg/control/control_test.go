@@ -0,0 +1,216 @@
+//go:build control
+// +build control
+
+package control_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/control"
+	"github.com/aquasecurity/tracee/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeTracer is a tracer controlled by the tests
+type fakeTracer struct {
+	stats      metrics.Stats
+	profile    string
+	profileErr error
+	paused     bool
+}
+
+func (t *fakeTracer) Stats() *metrics.Stats { return &t.stats }
+
+func (t *fakeTracer) WriteProfile(w io.Writer) error {
+	if t.profileErr != nil {
+		return t.profileErr
+	}
+	_, err := io.WriteString(w, t.profile)
+	return err
+}
+
+func (t *fakeTracer) SetCapturePaused(paused bool) { t.paused = paused }
+
+func (t *fakeTracer) CapturePaused() bool { return t.paused }
+
+// newTestClient serves the control API of a tracer in-process, and returns a client connected to it
+func newTestClient(t *testing.T, tracer control.Tracer) *control.Client {
+	listener := bufconn.Listen(1 << 20)
+	server := control.NewServer(tracer)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return control.NewClient(conn)
+}
+
+func TestStats(t *testing.T) {
+	tracer := &fakeTracer{}
+	tracer.stats.EventCount.Increment(42)
+	tracer.stats.LostEvCount.Increment(3)
+	tracer.stats.CapFileCount.Increment()
+	client := newTestClient(t, tracer)
+
+	stats, err := client.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &control.StatsResponse{EventCount: 42, LostEvents: 3, CapturedFiles: 1}, stats)
+}
+
+func TestProfile(t *testing.T) {
+	tracer := &fakeTracer{profile: `{"host/exec.ls:1": {"times": 2}}`}
+	client := newTestClient(t, tracer)
+
+	profile, err := client.Profile(context.Background())
+	require.NoError(t, err)
+	var files map[string]map[string]int
+	require.NoError(t, json.Unmarshal(profile, &files))
+	assert.Equal(t, 2, files["host/exec.ls:1"]["times"])
+
+	tracer.profileErr = errors.New("disk full")
+	_, err = client.Profile(context.Background())
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Contains(t, err.Error(), "disk full")
+}
+
+func TestSetCapture(t *testing.T) {
+	tracer := &fakeTracer{}
+	client := newTestClient(t, tracer)
+
+	resp, err := client.SetCapture(context.Background(), true)
+	require.NoError(t, err)
+	assert.True(t, resp.Paused)
+	assert.True(t, tracer.paused)
+
+	resp, err = client.SetCapture(context.Background(), false)
+	require.NoError(t, err)
+	assert.False(t, resp.Paused)
+	assert.False(t, tracer.paused)
+}
+
+// fakeRotator is a tracer whose output can be rotated
+type fakeRotator struct {
+	fakeTracer
+	rotations int
+	err       error
+}
+
+func (t *fakeRotator) RotateOutput() error {
+	if t.err != nil {
+		return t.err
+	}
+	t.rotations++
+	return nil
+}
+
+func TestRotateOutput(t *testing.T) {
+	tracer := &fakeRotator{}
+	client := newTestClient(t, tracer)
+
+	require.NoError(t, client.RotateOutput(context.Background()))
+	assert.Equal(t, 1, tracer.rotations)
+
+	tracer.err = errors.New("disk full")
+	err := client.RotateOutput(context.Background())
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Contains(t, err.Error(), "disk full")
+
+	// the output of other tracers isn't a file
+	err = newTestClient(t, &fakeTracer{}).RotateOutput(context.Background())
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestListen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+
+	listener, err := control.Listen(path)
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	server := control.NewServer(&fakeTracer{paused: true})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.Dial("unix:"+path, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	resp, err := control.NewClient(conn).SetCapture(context.Background(), false)
+	require.NoError(t, err)
+	assert.False(t, resp.Paused)
+
+	t.Run("stale socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "control.sock")
+		stale, err := net.Listen("unix", path)
+		require.NoError(t, err)
+		// keep the socket file of the stale listener
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		stale.Close()
+
+		listener, err := control.Listen(path)
+		require.NoError(t, err)
+		listener.Close()
+	})
+
+	t.Run("not a socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "control.sock")
+		require.NoError(t, ioutil.WriteFile(path, []byte("data"), 0644))
+
+		_, err := control.Listen(path)
+		assert.Error(t, err)
+		data, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "data", string(data))
+	})
+}
+
+// fakeReloader is a tracer whose filters can be reloaded
+type fakeReloader struct {
+	fakeTracer
+	filters []string
+	err     error
+}
+
+func (t *fakeReloader) ReloadFilters(filters []string) error {
+	if t.err != nil {
+		return t.err
+	}
+	t.filters = filters
+	return nil
+}
+
+func TestReloadFilters(t *testing.T) {
+	tracer := &fakeReloader{}
+	client := newTestClient(t, tracer)
+
+	filters := []string{"event=execve", "execve.pathname=/usr/bin/*"}
+	require.NoError(t, client.ReloadFilters(context.Background(), filters))
+	assert.Equal(t, filters, tracer.filters)
+
+	tracer.err = errors.New("the traced events must not change")
+	err := client.ReloadFilters(context.Background(), []string{"event=openat"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, err.Error(), "the traced events must not change")
+	assert.Equal(t, filters, tracer.filters)
+
+	err = newTestClient(t, &fakeTracer{}).ReloadFilters(context.Background(), filters)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
@@ -0,0 +1,113 @@
+package ebpf
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/events/parse"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// processNode is a process in the process tree
+type processNode struct {
+	hostPpid int
+	name     string
+	// path is the path of the executable of the process, if known
+	path string
+}
+
+// tracksProcessTree checks if the process tree is tracked, for the ancestry of events or for capturing files by the
+// lineage of their processes
+func (t *Tracee) tracksProcessTree() bool {
+	return !t.config.Output.Minimal && (t.config.Output.Ancestry > 0 || t.filtersLineage())
+}
+
+// addAncestry adds an ancestry argument to the event, with the names of the ancestors of its process (up to the
+// configured number of them, parent first). Ancestors which aren't known (e.g. as they exited) end the ancestry
+func (t *Tracee) addAncestry(event *trace.Event) {
+	ancestry := make([]string, 0, t.config.Output.Ancestry)
+	hostPid := event.HostParentProcessID
+	for len(ancestry) < t.config.Output.Ancestry && hostPid > 0 {
+		node, ok := t.processTree[hostPid]
+		if !ok {
+			break
+		}
+		ancestry = append(ancestry, node.name)
+		hostPid = node.hostPpid
+	}
+
+	event.Args = append(event.Args, trace.Argument{
+		ArgMeta: trace.ArgMeta{Name: "ancestry", Type: "const char**"},
+		Value:   ancestry,
+	})
+	event.ArgsNum++
+}
+
+// updateProcessTree records the parent, name and executable of the process of the event, as of the fork, exec and
+// exit events. Processes which started before tracing are recorded by their first event, and their executable is
+// read from procfs when it's needed for capturing files by lineage
+func (t *Tracee) updateProcessTree(event *trace.Event) {
+	if _, ok := t.processTree[event.HostProcessID]; !ok && event.HostProcessID > 0 {
+		node := processNode{hostPpid: event.HostParentProcessID, name: event.ProcessName}
+		if len(t.config.Capture.ParentPaths) > 0 {
+			node.path, _ = os.Readlink(fmt.Sprintf("/proc/%d/exe", event.HostProcessID))
+		}
+		t.processTree[event.HostProcessID] = node
+	}
+
+	switch events.ID(event.EventID) {
+	case events.SchedProcessFork:
+		hostPid, err := parse.ArgInt32Val(event, "child_pid")
+		if err != nil {
+			return
+		}
+		hostTid, err := parse.ArgInt32Val(event, "child_tid")
+		if err != nil || hostTid != hostPid {
+			// a thread isn't a process of its own
+			return
+		}
+		// the child is named as its parent until it executes another binary
+		t.processTree[int(hostPid)] = processNode{
+			hostPpid: event.HostProcessID,
+			name:     event.ProcessName,
+			path:     t.processTree[event.HostProcessID].path,
+		}
+	case events.SchedProcessExec:
+		path, _ := parse.ArgStringVal(event, "pathname")
+		t.processTree[event.HostProcessID] = processNode{hostPpid: event.HostParentProcessID, name: event.ProcessName, path: path}
+	case events.SchedProcessExit:
+		if groupExit, err := parse.ArgBoolVal(event, "process_group_exit"); err == nil && groupExit {
+			delete(t.processTree, event.HostProcessID)
+		}
+	}
+}
+
+// filtersLineage checks if files are captured only for processes of matching lineages
+func (t *Tracee) filtersLineage() bool {
+	return len(t.config.Capture.ParentComms) > 0 || len(t.config.Capture.ParentPaths) > 0
+}
+
+// lineageCaptured checks if the files of the process of an event are captured as of its lineage. With
+// Capture.ParentComms or Capture.ParentPaths, they are captured if an ancestor of the process matches them by its
+// name or executable. A lineage which ends at an unknown ancestor (e.g. one started before tracing, or which exited)
+// without a match is captured as of Capture.CaptureUnknownParents, and a lineage known up to its root isn't
+func (t *Tracee) lineageCaptured(event *trace.Event) bool {
+	if !t.filtersLineage() {
+		return true
+	}
+	hostPid := event.HostParentProcessID
+	// the lineage can't be longer than the known processes, unless reused pids made a loop of it
+	for i := 0; i <= len(t.processTree) && hostPid > 0; i++ {
+		node, ok := t.processTree[hostPid]
+		if !ok {
+			return t.config.Capture.CaptureUnknownParents
+		}
+		if MatchFilter(t.config.Capture.ParentComms, node.name) ||
+			(node.path != "" && MatchFilter(t.config.Capture.ParentPaths, node.path)) {
+			return true
+		}
+		hostPid = node.hostPpid
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package ebpf
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newForkEvent(hostPid int, hostPpid int, comm string, childPid int, childTid int) *trace.Event {
+	return &trace.Event{
+		EventID:             int(events.SchedProcessFork),
+		EventName:           "sched_process_fork",
+		ProcessName:         comm,
+		HostProcessID:       hostPid,
+		HostThreadID:        hostPid,
+		HostParentProcessID: hostPpid,
+		Args: []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Name: "parent_pid", Type: "int"}, Value: int32(hostPid)},
+			{ArgMeta: trace.ArgMeta{Name: "child_pid", Type: "int"}, Value: int32(childPid)},
+			{ArgMeta: trace.ArgMeta{Name: "child_tid", Type: "int"}, Value: int32(childTid)},
+		},
+	}
+}
+
+func newProcessEvent(id events.ID, hostPid int, hostPpid int, comm string, args ...trace.Argument) *trace.Event {
+	return &trace.Event{
+		EventID:             int(id),
+		EventName:           events.Definitions.Get(id).Name,
+		ProcessName:         comm,
+		HostProcessID:       hostPid,
+		HostThreadID:        hostPid,
+		HostParentProcessID: hostPpid,
+		Args:                args,
+	}
+}
+
+func eventAncestry(t *testing.T, event *trace.Event) []string {
+	arg := events.GetArg(event, "ancestry")
+	require.NotNil(t, arg, "event %s has no ancestry argument", event.EventName)
+	ancestry, ok := arg.Value.([]string)
+	require.True(t, ok)
+	return ancestry
+}
+
+func Test_addAncestry(t *testing.T) {
+	trc := newTestTracee(t, Config{Output: &OutputConfig{Ancestry: 3}})
+	process := func(event *trace.Event) *trace.Event {
+		require.NoError(t, trc.processEvent(event))
+		return event
+	}
+
+	// init (1) -> bash (100) -> curl (200) -> sh (300), with bash started before tracing
+	assert.Equal(t, []string{}, eventAncestry(t, process(newProcessEvent(events.Openat, 100, 1, "bash"))))
+	process(newForkEvent(100, 1, "bash", 200, 200))
+	process(newProcessEvent(events.SchedProcessExec, 200, 100, "curl"))
+	// a thread of curl isn't a process of its own
+	process(newForkEvent(200, 100, "curl", 200, 201))
+	process(newForkEvent(200, 100, "curl", 300, 300))
+	assert.Equal(t, []string{"curl", "bash"}, eventAncestry(t, process(newProcessEvent(events.SchedProcessExec, 300, 200, "sh"))))
+	process(newForkEvent(300, 200, "sh", 400, 400))
+	process(newProcessEvent(events.SchedProcessExec, 400, 300, "cat"))
+
+	// the ancestry is bounded
+	assert.Equal(t, []string{"sh", "curl", "bash"}, eventAncestry(t, process(newProcessEvent(events.Openat, 400, 300, "cat"))))
+	assert.Equal(t, []string{"curl", "bash"}, eventAncestry(t, process(newProcessEvent(events.Openat, 300, 200, "sh"))))
+
+	// exited processes are pruned, and end the ancestry of their descendants
+	exit := process(newProcessEvent(events.SchedProcessExit, 200, 100, "curl",
+		trace.Argument{ArgMeta: trace.ArgMeta{Name: "process_group_exit", Type: "bool"}, Value: true}))
+	assert.Equal(t, []string{"bash"}, eventAncestry(t, exit))
+	assert.NotContains(t, trc.processTree, 200)
+	assert.Equal(t, []string{"sh"}, eventAncestry(t, process(newProcessEvent(events.Openat, 400, 300, "cat"))))
+	assert.Equal(t, []string{}, eventAncestry(t, process(newProcessEvent(events.Openat, 300, 200, "sh"))))
+
+	// the exit of a thread doesn't prune its process
+	process(newProcessEvent(events.SchedProcessExit, 300, 200, "sh",
+		trace.Argument{ArgMeta: trace.ArgMeta{Name: "process_group_exit", Type: "bool"}, Value: false}))
+	assert.Contains(t, trc.processTree, 300)
+
+	t.Run("disabled", func(t *testing.T) {
+		trc := newTestTracee(t, Config{})
+		event := newProcessEvent(events.Openat, 100, 1, "bash")
+		require.NoError(t, trc.processEvent(event))
+		assert.Nil(t, events.GetArg(event, "ancestry"))
+		assert.Empty(t, trc.processTree)
+	})
+}
+
+func Test_lineageCaptured(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "binary")
+	require.NoError(t, ioutil.WriteFile(binary, []byte("executed binary"), 0755))
+	// executes the binary by a child of the given parent, as of its own timestamp
+	execBy := func(trc *Tracee, ts int, hostPpid int) {
+		event := newExecEvent(t, binary)
+		event.Timestamp = ts
+		event.HostParentProcessID = hostPpid
+		require.NoError(t, trc.processEvent(event))
+	}
+	execArgs := func(path string) []trace.Argument {
+		return []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: path},
+			{ArgMeta: trace.ArgMeta{Name: "ctime", Type: "unsigned long"}, Value: uint64(1)},
+		}
+	}
+	// systemd (1) -> nginx (100) -> sh (200), and bash (300) whose parent is unknown
+	newLineages := func(t *testing.T, capture CaptureConfig) *Tracee {
+		// the files executed by the lineages aren't there to be captured, so they are executed before capturing
+		exec := capture.Exec
+		capture.Exec = false
+		trc := newTestTracee(t, Config{Capture: &capture})
+		require.NoError(t, trc.processEvent(newProcessEvent(events.Openat, 1, 0, "systemd")))
+		require.NoError(t, trc.processEvent(newProcessEvent(events.SchedProcessExec, 100, 1, "nginx", execArgs("/usr/sbin/nginx")...)))
+		require.NoError(t, trc.processEvent(newForkEvent(100, 1, "nginx", 200, 200)))
+		require.NoError(t, trc.processEvent(newProcessEvent(events.SchedProcessExec, 200, 100, "sh", execArgs("/bin/sh")...)))
+		require.NoError(t, trc.processEvent(newProcessEvent(events.Openat, 300, 250, "bash")))
+		trc.config.Capture.Exec = exec
+		return trc
+	}
+	hostDir := func(trc *Tracee) string { return filepath.Join(trc.outDir.Name(), "host") }
+
+	t.Run("comm", func(t *testing.T) {
+		trc := newLineages(t, CaptureConfig{Exec: true, ParentComms: []string{"ngin*"}})
+		// other lineages aren't captured, whether they are known up to their root or not
+		execBy(trc, 1, 1)
+		execBy(trc, 2, 300)
+		assert.Equal(t, int32(0), trc.stats.CapFileCount.Read())
+		// while descendants of nginx are
+		execBy(trc, 3, 200)
+		assert.FileExists(t, filepath.Join(hostDir(trc), "exec.3.binary"))
+		assert.Equal(t, int32(1), trc.stats.CapFileCount.Read())
+	})
+
+	t.Run("path", func(t *testing.T) {
+		trc := newLineages(t, CaptureConfig{Exec: true, ParentPaths: []string{"/usr/sbin/nginx"}})
+		execBy(trc, 1, 300)
+		execBy(trc, 2, 200)
+		assert.NoFileExists(t, filepath.Join(hostDir(trc), "exec.1.binary"))
+		assert.FileExists(t, filepath.Join(hostDir(trc), "exec.2.binary"))
+	})
+
+	t.Run("unknown parents", func(t *testing.T) {
+		trc := newLineages(t, CaptureConfig{Exec: true, ParentComms: []string{"nginx"}, CaptureUnknownParents: true})
+		// a lineage known up to its root doesn't match, while one ending at an unknown ancestor may
+		execBy(trc, 1, 1)
+		execBy(trc, 2, 300)
+		assert.NoFileExists(t, filepath.Join(hostDir(trc), "exec.1.binary"))
+		assert.FileExists(t, filepath.Join(hostDir(trc), "exec.2.binary"))
+	})
+
+	t.Run("loop", func(t *testing.T) {
+		trc := newTestTracee(t, Config{Capture: &CaptureConfig{Exec: true, ParentComms: []string{"nginx"}}})
+		trc.processTree[100] = processNode{hostPpid: 200, name: "sh"}
+		trc.processTree[200] = processNode{hostPpid: 100, name: "sh"}
+		execBy(trc, 1, 100)
+		assert.Equal(t, int32(0), trc.stats.CapFileCount.Read())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		trc := newTestTracee(t, Config{Capture: &CaptureConfig{Exec: true}})
+		execBy(trc, 1, 300)
+		assert.Equal(t, int32(1), trc.stats.CapFileCount.Read())
+		assert.False(t, trc.tracksProcessTree())
+	})
+}
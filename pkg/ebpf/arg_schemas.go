@@ -0,0 +1,76 @@
+package ebpf
+
+import (
+	"fmt"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/events/parse"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// writeArgSchema is the arguments of the events of written files used by processEvent
+var writeArgSchema = parse.Schema{
+	"pathname": parse.String,
+	"dev":      parse.Uint32,
+	"inode":    parse.Uint64,
+}
+
+// argSchemas are the arguments used by processEvent of the events it processes, by their event ids
+var argSchemas = map[events.ID]parse.Schema{
+	events.SchedProcessFork: {
+		"child_tid":     parse.Int32,
+		"child_pid":     parse.Int32,
+		"child_ns_pid":  parse.Int32,
+		"child_ns_tid":  parse.Int32,
+		"parent_pid":    parse.Int32,
+		"parent_ns_pid": parse.Int32,
+		"start_time":    parse.Uint64,
+	},
+	events.SchedProcessExec: {
+		"pathname": parse.String,
+		"ctime":    parse.Uint64,
+	},
+	events.CgroupMkdir: {
+		"cgroup_id":    parse.Uint64,
+		"cgroup_path":  parse.String,
+		"hierarchy_id": parse.Uint32,
+	},
+	events.CgroupRmdir: {
+		"cgroup_id":    parse.Uint64,
+		"hierarchy_id": parse.Uint32,
+	},
+	events.VfsWrite:    writeArgSchema,
+	events.VfsWritev:   writeArgSchema,
+	events.KernelWrite: writeArgSchema,
+	events.SecurityFileOpen: {
+		"pathname": parse.String,
+		"dev":      parse.Uint32,
+		"inode":    parse.Uint64,
+		"ctime":    parse.Uint64,
+	},
+	events.SharedObjectLoaded: {
+		"pathname": parse.String,
+		"ctime":    parse.Uint64,
+	},
+	events.SecurityInodeRename: {
+		"old_path": parse.String,
+		"new_path": parse.String,
+	},
+	events.SecurityInodeUnlink: {
+		"pathname": parse.String,
+		"dev":      parse.Uint32,
+		"inode":    parse.Uint64,
+	},
+	events.HookedProcFops: {
+		"hooked_fops_pointers": parse.UlongArray,
+	},
+}
+
+// decodeArgs decodes the arguments of an event used by processEvent, as of the schema of the event
+func decodeArgs(event *trace.Event) (parse.Args, error) {
+	args, err := argSchemas[events.ID(event.EventID)].Decode(event)
+	if err != nil {
+		return parse.Args{}, fmt.Errorf("error parsing %s args: %w", event.EventName, err)
+	}
+	return args, nil
+}
@@ -0,0 +1,50 @@
+package ebpf
+
+import (
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_argSchemas(t *testing.T) {
+	// the schemas name arguments of the definitions of their events
+	for eventID, schema := range argSchemas {
+		def := events.Definitions.Get(eventID)
+		params := make(map[string]struct{}, len(def.Params))
+		for _, param := range def.Params {
+			params[param.Name] = struct{}{}
+		}
+		for name := range schema {
+			assert.Contains(t, params, name, "argument %s of %s", name, def.Name)
+		}
+	}
+}
+
+func Test_decodeArgs(t *testing.T) {
+	unlinkEvent := func(inode interface{}) *trace.Event {
+		return &trace.Event{
+			EventID:   int(events.SecurityInodeUnlink),
+			EventName: "security_inode_unlink",
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/tmp/payload"},
+				{ArgMeta: trace.ArgMeta{Name: "inode", Type: "unsigned long"}, Value: inode},
+				{ArgMeta: trace.ArgMeta{Name: "dev", Type: "dev_t"}, Value: uint32(271581185)},
+				{ArgMeta: trace.ArgMeta{Name: "ctime", Type: "u64"}, Value: uint64(1)},
+			},
+		}
+	}
+
+	args, err := decodeArgs(unlinkEvent(uint64(2493759)))
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/payload", args.String("pathname"))
+	assert.Equal(t, uint32(271581185), args.Uint32("dev"))
+	assert.Equal(t, uint64(2493759), args.Uint64("inode"))
+
+	// malformed events fail processing with the error of their event
+	trc := newTestTracee(t, Config{Capture: &CaptureConfig{Exec: true}})
+	err = trc.processEvent(unlinkEvent(int64(2493759)))
+	assert.EqualError(t, err, "error parsing security_inode_unlink args: argument inode is not of type uint64")
+}
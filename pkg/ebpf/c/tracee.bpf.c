@@ -86,6 +86,7 @@ int KERNEL_VERSION SEC("version") = LINUX_VERSION_CODE;
 #define MAX_STACK_ADDRESSES 1024      // max amount of diff stack trace addrs to buffer
 #define MAX_STACK_DEPTH     20        // max depth of each stack trace to track
 #define MAX_STR_FILTER_SIZE 16        // bounded to size of the compared values (comm)
+#define MAX_BIN_PATH_SIZE   128       // bounded to size of the binary paths compared by the binary filter
 #define FILE_MAGIC_HDR_SIZE 32        // magic_write: bytes to save from a file's header
 #define FILE_MAGIC_MASK     31        // magic_write: mask used for verifier boundaries
 #define NET_SEQ_OPS_SIZE    4         // print_net_seq_ops: struct size - TODO: replace with uprobe argument
@@ -482,6 +483,7 @@ enum event_id_e
 #define FILTER_PROC_TREE_OUT     (1 << 20)
 #define FILTER_CGROUP_ID_ENABLED (1 << 21)
 #define FILTER_CGROUP_ID_OUT     (1 << 22)
+#define FILTER_BINARY_ENABLED    (1 << 23)
 
 #define FILTER_MAX_NOT_SET 0
 #define FILTER_MIN_NOT_SET ULLONG_MAX
@@ -683,7 +685,7 @@ typedef struct event_context {
     u64 ts; // Timestamp
     task_context_t task;
     u32 eventid;
-    u32 padding;
+    u32 gid; // Group ID, outside of task_context_t which is cached in task_info
     s64 retval;
     u32 stack_id;
     u16 processor_id; // The ID of the processor which processed the event
@@ -720,6 +722,8 @@ typedef struct task_info {
     bool follow;          // set if this task was traced before. Used with the follow filter
     int should_trace;     // last decision of should_trace()
     u8 container_state;   // the state of the container the task resides in
+    bool binary_resolved; // indicates that binary_id is valid. Used with the binary filter
+    u32 binary_id;        // id of the binary executed by the task in the binary filter, or 0
 } task_info_t;
 
 typedef struct bin_args {
@@ -745,6 +749,15 @@ typedef struct string_filter {
     char str[MAX_STR_FILTER_SIZE];
 } string_filter_t;
 
+typedef struct binary_path {
+    char path[MAX_BIN_PATH_SIZE];
+} binary_path_t;
+
+typedef struct binary_filter_key {
+    u32 event_id;
+    u32 binary_id;
+} binary_filter_key_t;
+
 typedef struct ksym_name {
     char str[MAX_KSYM_NAME_SIZE];
 } ksym_name_t;
@@ -911,6 +924,9 @@ BPF_HASH(pid_ns_filter, u64, u32, 256);                            // filter eve
 BPF_HASH(uts_ns_filter, string_filter_t, u32, 256);                // filter events by uts namespace name
 BPF_HASH(comm_filter, string_filter_t, u32, 256);                  // filter events by command name
 BPF_HASH(cgroup_id_filter, u32, u32, 256);                         // filter events by cgroup id
+BPF_HASH(binary_filter_events, u32, u32, 256);                     // events filtered by the binary of their process
+BPF_HASH(binary_filter_paths, binary_path_t, u32, 1024);           // ids of the binaries of the binary filter
+BPF_HASH(binary_filter, binary_filter_key_t, u32, 1024);           // binary ids allowed for each filtered event
 BPF_HASH(bin_args_map, u64, bin_args_t, 256);                      // persist args for send_bin funtion
 BPF_HASH(sys_32_to_64_map, u32, u32, 1024);                        // map 32bit to 64bit syscalls
 BPF_HASH(params_types_map, u32, u64, 1024);                        // encoded parameters types for event
@@ -924,6 +940,7 @@ BPF_LRU_HASH(network_map, net_id_t, net_ctx_t, 10240);             // network id
 BPF_ARRAY(config_map, config_entry_t, 1);                          // various configurations
 BPF_ARRAY(file_filter, path_filter_t, 3);                          // filter vfs_write events
 BPF_PERCPU_ARRAY(bufs, buf_t, MAX_BUFFERS);                        // percpu global buffer variables
+BPF_PERCPU_ARRAY(binary_path_bufs, binary_path_t, 1);              // percpu binary filter paths lookup key
 BPF_PROG_ARRAY(prog_array, MAX_TAIL_CALL);                         // store programs for tail calls
 BPF_PROG_ARRAY(prog_array_tp, MAX_TAIL_CALL);                      // store programs for tail calls
 BPF_PROG_ARRAY(sys_enter_tails, MAX_EVENT_ID);                     // store syscall specific programs for tail calls from sys_enter
@@ -1633,7 +1650,9 @@ init_context(event_context_t *context, struct task_struct *task, u32 options)
     context->task.ppid = get_task_ns_ppid(task);
     context->task.mnt_id = get_task_mnt_ns_id(task);
     context->task.pid_id = get_task_pid_ns_id(task);
-    context->task.uid = bpf_get_current_uid_gid();
+    u64 uid_gid = bpf_get_current_uid_gid();
+    context->task.uid = uid_gid;
+    context->gid = uid_gid >> 32;
     context->task.flags = 0;
     bpf_get_current_comm(&context->task.comm, sizeof(context->task.comm));
     char *uts_name = get_task_uts_name(task);
@@ -1684,6 +1703,7 @@ static __always_inline task_info_t *init_task_info(u32 key, bool *initialized)
         task_info->follow = false;
         task_info->recompute_scope = true;
         task_info->container_state = CONTAINER_UNKNOWN;
+        task_info->binary_resolved = false;
     }
     return task_info;
 }
@@ -2166,8 +2186,26 @@ static __always_inline int save_args_str_arr_to_buf(
 
 // INTERNAL: PERF BUFFER ---------------------------------------------------------------------------
 
+// binary_filter_matches checks if an event is allowed for the binary executed by its process (see
+// resolve_binary_id). Events without allowed binaries are always allowed.
+static __always_inline bool binary_filter_matches(event_data_t *data, u32 id)
+{
+    if (bpf_map_lookup_elem(&binary_filter_events, &id) == NULL)
+        return true;
+
+    // binaries are resolved once the task executes them, or once it's first seen
+    if (!data->task_info->binary_resolved || data->task_info->binary_id == 0)
+        return false;
+
+    binary_filter_key_t key = {.event_id = id, .binary_id = data->task_info->binary_id};
+    return bpf_map_lookup_elem(&binary_filter, &key) != NULL;
+}
+
 static __always_inline int events_perf_submit(event_data_t *data, u32 id, long ret)
 {
+    if ((data->config->filters & FILTER_BINARY_ENABLED) && !binary_filter_matches(data, id))
+        return 0;
+
     data->context.eventid = id;
     data->context.retval = ret;
 
@@ -2350,6 +2388,37 @@ static __always_inline void *get_dentry_path_str(struct dentry *dentry)
     return &string_p->buf[buf_off];
 }
 
+// resolve_binary_id looks up the binary executed by a task in the binaries of the binary filter, keeping its id in
+// the task_info of the task, so the path of the binary is only walked when the task executes it (or when it's first
+// seen), and not for each of its events. Binaries which aren't filtered get the id 0
+static __always_inline void resolve_binary_id(task_info_t *task_info, struct task_struct *task)
+{
+    task_info->binary_resolved = true;
+    task_info->binary_id = 0;
+
+    int zero = 0;
+    binary_path_t *key = bpf_map_lookup_elem(&binary_path_bufs, &zero);
+    if (key == NULL)
+        return;
+    __builtin_memset(key, 0, sizeof(binary_path_t));
+
+    // kernel threads don't execute a binary
+    struct mm_struct *mm = get_mm_from_task(task);
+    if (mm == NULL)
+        return;
+    struct file *exe_file = READ_KERN(mm->exe_file);
+    if (exe_file == NULL)
+        return;
+    void *exe_path = get_path_str(GET_FIELD_ADDR(exe_file->f_path));
+    if (exe_path == NULL)
+        return;
+    bpf_probe_read_str(key->path, MAX_BIN_PATH_SIZE, exe_path);
+
+    u32 *binary_id = bpf_map_lookup_elem(&binary_filter_paths, key);
+    if (binary_id != NULL)
+        task_info->binary_id = *binary_id;
+}
+
 // INTERNAL: ARGUMENTS -----------------------------------------------------------------------------
 
 static __always_inline int save_args(args_t *args, u32 event_id)
@@ -2883,6 +2952,12 @@ int sys_enter_init(struct bpf_raw_tracepoint_args *ctx)
         return 0;
     }
 
+    // tasks which were running before tracee are resolved by the first syscall they're seen in
+    int zero = 0;
+    config_entry_t *config = bpf_map_lookup_elem(&config_map, &zero);
+    if (config != NULL && (config->filters & FILTER_BINARY_ENABLED) && !task_info->binary_resolved)
+        resolve_binary_id(task_info, task);
+
     syscall_data_t *sys = &(task_info->syscall_data);
     sys->id = ctx->args[1];
 
@@ -3386,6 +3461,9 @@ int tracepoint__sched__sched_process_exec(struct bpf_raw_tracepoint_args *ctx)
     data.task_info->new_task = true;
     data.task_info->recompute_scope = true;
 
+    if (data.config->filters & FILTER_BINARY_ENABLED)
+        resolve_binary_id(data.task_info, data.task);
+
     if (!should_trace(&data))
         return 0;
 
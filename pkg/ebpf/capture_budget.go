@@ -0,0 +1,81 @@
+package ebpf
+
+import (
+	"os"
+	"sync"
+
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+// fileBudget limits the number of files opened concurrently while capturing, so bursts of captures can't exhaust
+// the file descriptors of the process. A nil fileBudget is unlimited.
+type fileBudget struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	max  int
+	used int
+}
+
+func newFileBudget(max int) *fileBudget {
+	if max <= 0 {
+		return nil
+	}
+	b := &fileBudget{max: max}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// acquire waits until n files can be opened. Files opened together (e.g. the source and destination of a copy)
+// are acquired at once, so concurrent captures can't each hold a part of the budget and wait for each other
+func (b *fileBudget) acquire(n int) {
+	if b == nil {
+		return
+	}
+	if n > b.max {
+		n = b.max
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.used+n > b.max {
+		b.cond.Wait()
+	}
+	b.used += n
+}
+
+// release returns n files acquired from the budget
+func (b *fileBudget) release(n int) {
+	if b == nil {
+		return
+	}
+	if n > b.max {
+		n = b.max
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= n
+	b.cond.Broadcast()
+}
+
+// budgetedFile is a file which returns its budget when closed
+type budgetedFile struct {
+	*os.File
+	once   sync.Once
+	budget *fileBudget
+}
+
+func (f *budgetedFile) Close() error {
+	err := f.File.Close()
+	f.once.Do(func() { f.budget.release(1) })
+	return err
+}
+
+// openAt opens a file relative to the given directory, like utils.OpenAt, once the budget allows it
+func (b *fileBudget) openAt(dir *os.File, relativePath string, flags int, perm os.FileMode) (*budgetedFile, error) {
+	b.acquire(1)
+	f, err := utils.OpenAt(dir, relativePath, flags, perm)
+	if err != nil {
+		b.release(1)
+		return nil, err
+	}
+	return &budgetedFile{File: f, budget: b}, nil
+}
@@ -0,0 +1,100 @@
+package ebpf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_fileBudget(t *testing.T) {
+	const maxOpenFiles = 3
+	budget := newFileBudget(maxOpenFiles)
+
+	var open, maxOpen int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			budget.acquire(n)
+			cur := atomic.AddInt32(&open, int32(n))
+			for {
+				prev := atomic.LoadInt32(&maxOpen)
+				if cur <= prev || atomic.CompareAndSwapInt32(&maxOpen, prev, cur) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&open, -int32(n))
+			budget.release(n)
+		}(i%2 + 1) // both single files and copies
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, maxOpen, int32(maxOpenFiles))
+	assert.Equal(t, 0, budget.used)
+}
+
+func Test_fileBudget_openAt(t *testing.T) {
+	d, err := ioutil.TempDir("", "Test_fileBudget_openAt-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+	dir, err := os.Open(d)
+	require.NoError(t, err)
+	defer dir.Close()
+
+	budget := newFileBudget(1)
+	f, err := budget.openAt(dir, "a", os.O_CREATE|os.O_WRONLY, 0640)
+	require.NoError(t, err)
+
+	// the second file can only be opened once the first is closed
+	opened := make(chan *budgetedFile)
+	go func() {
+		f, err := budget.openAt(dir, "b", os.O_CREATE|os.O_WRONLY, 0640)
+		assert.NoError(t, err)
+		opened <- f
+	}()
+	select {
+	case <-opened:
+		t.Fatal("file opened beyond the budget")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, f.Close())
+	// closing twice doesn't return the budget twice
+	assert.Error(t, f.Close())
+	f = <-opened
+	require.NoError(t, f.Close())
+	assert.Equal(t, 0, budget.used)
+
+	// failing to open doesn't hold the budget
+	_, err = budget.openAt(dir, "notexist/c", os.O_RDONLY, 0)
+	assert.Error(t, err)
+	assert.Equal(t, 0, budget.used)
+}
+
+func Test_fileBudget_unlimited(t *testing.T) {
+	budget := newFileBudget(0)
+	assert.Nil(t, budget)
+
+	d, err := ioutil.TempDir("", "Test_fileBudget_unlimited-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+	dir, err := os.Open(d)
+	require.NoError(t, err)
+	defer dir.Close()
+
+	for i := 0; i < 10; i++ {
+		budget.acquire(2)
+		f, err := budget.openAt(dir, fmt.Sprintf("file%d", i), os.O_CREATE|os.O_WRONLY, 0640)
+		require.NoError(t, err)
+		defer f.Close()
+	}
+}
@@ -0,0 +1,167 @@
+package ebpf
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+// The algorithms captured files can be compressed with
+const (
+	CaptureCompressionGzip = "gzip"
+	CaptureCompressionZstd = "zstd"
+)
+
+// compressionXattr is the extended attribute recording the algorithm a captured file was compressed with, set along
+// with its hash (with HashXattr), so the file can be verified by its content
+const compressionXattr = "user.tracee.compression"
+
+// captureCompressor compresses captured files with an algorithm
+type captureCompressor struct {
+	// ext is the extension added to the names of compressed files
+	ext string
+	// minLevel and maxLevel are the range of the compression levels of the algorithm
+	minLevel int
+	maxLevel int
+	// newWriter creates a writer compressing to w at the given level, or at the default level of the algorithm if
+	// it's 0
+	newWriter func(w io.Writer, level int) (io.WriteCloser, error)
+	newReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+// captureCompressors are the supported compression algorithms, by their names. zstd is only supported when built
+// with the zstd build tag
+var captureCompressors = map[string]captureCompressor{
+	CaptureCompressionGzip: {
+		ext:      ".gz",
+		minLevel: gzip.BestSpeed,
+		maxLevel: gzip.BestCompression,
+		newWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+			if level == 0 {
+				level = gzip.DefaultCompression
+			}
+			return gzip.NewWriterLevel(w, level)
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+}
+
+// validateCaptureCompression checks the capture compression algorithm and level are supported
+func validateCaptureCompression(algorithm string, level int) error {
+	if algorithm == "" {
+		if level != 0 {
+			return fmt.Errorf("invalid capture compression level - requires a capture compression algorithm")
+		}
+		return nil
+	}
+	compressor, ok := captureCompressors[algorithm]
+	if !ok {
+		if algorithm == CaptureCompressionZstd {
+			return fmt.Errorf("invalid capture compression - zstd isn't supported by this build of tracee-ebpf, build it with ZSTD=1")
+		}
+		return fmt.Errorf("invalid capture compression - unknown algorithm %s", algorithm)
+	}
+	if level != 0 && (level < compressor.minLevel || level > compressor.maxLevel) {
+		return fmt.Errorf("invalid capture compression level - must be between %d and %d for %s", compressor.minLevel, compressor.maxLevel, algorithm)
+	}
+	return nil
+}
+
+// captureCompressor returns the compressor of captured files, if they are compressed
+func (t *Tracee) captureCompressor() (captureCompressor, bool) {
+	if t.config.Capture.Compression == "" {
+		return captureCompressor{}, false
+	}
+	compressor, ok := captureCompressors[t.config.Capture.Compression]
+	return compressor, ok
+}
+
+// compressedCapture checks if a file captured in the output directory was compressed, as told by its name
+func (t *Tracee) compressedCapture(fileName string) (captureCompressor, bool) {
+	compressor, ok := t.captureCompressor()
+	if !ok || !strings.HasSuffix(fileName, compressor.ext) {
+		return captureCompressor{}, false
+	}
+	return compressor, true
+}
+
+// copyCompressed copies a regular file to a path relative to the given directory, compressing it at the given level.
+// It returns the number of (uncompressed) bytes copied
+func copyCompressed(srcName string, dstDir *os.File, dstName string, compressor captureCompressor, level int) (int64, error) {
+	info, err := os.Stat(srcName)
+	if err != nil {
+		return 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return 0, fmt.Errorf("%s is not a regular file", srcName)
+	}
+	source, err := os.Open(srcName)
+	if err != nil {
+		return 0, err
+	}
+	defer source.Close()
+	destination, err := utils.CreateAt(dstDir, dstName)
+	if err != nil {
+		return 0, err
+	}
+	defer destination.Close()
+
+	w, err := compressor.newWriter(destination, level)
+	if err != nil {
+		return 0, err
+	}
+	copied, err := io.Copy(w, source)
+	if err != nil {
+		w.Close()
+		return 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	return copied, nil
+}
+
+// hashCompressed returns the sha256 of the decompressed content of a compressed file
+func hashCompressed(r io.Reader, compressor captureCompressor) (string, error) {
+	decompressed, err := compressor.newReader(r)
+	if err != nil {
+		return "", err
+	}
+	defer decompressed.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, decompressed); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashCapturedFile returns the sha256 of the content of a file captured in the output directory, decompressing it
+// if it was compressed
+func (t *Tracee) hashCapturedFile(f *os.File, fileName string) (string, error) {
+	if compressor, ok := t.compressedCapture(fileName); ok {
+		return hashCompressed(f, compressor)
+	}
+	return computeFileHash(f, t.config.Capture.HashMmapThreshold)
+}
+
+// decompressingReader reads the decompressed content of a file, closing both once it's closed
+type decompressingReader struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (r decompressingReader) Close() error {
+	err := r.ReadCloser.Close()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
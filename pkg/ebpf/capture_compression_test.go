@@ -0,0 +1,132 @@
+package ebpf
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// compressionAlgorithms returns the names of the compression algorithms of this build, sorted
+func compressionAlgorithms() []string {
+	var algorithms []string
+	for algorithm := range captureCompressors {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	return algorithms
+}
+
+func Test_captureCompression(t *testing.T) {
+	dir := t.TempDir()
+	binaryPath := filepath.Join(dir, "binary")
+	content := bytes.Repeat([]byte("\x7fELF compressible captured binary "), 1024)
+	require.NoError(t, ioutil.WriteFile(binaryPath, content, 0755))
+	expectedHash, err := computeFileHashAtPath(binaryPath, 0)
+	require.NoError(t, err)
+
+	for _, algorithm := range compressionAlgorithms() {
+		t.Run(algorithm, func(t *testing.T) {
+			trc := newTestTracee(t, Config{
+				Capture: &CaptureConfig{Exec: true, HashXattr: true, Compression: algorithm},
+				Output:  &OutputConfig{ExecHash: true},
+			})
+			xattrs := unix.Setxattr(trc.outDir.Name(), "user.tracee.test", []byte("1"), 0) != unix.EOPNOTSUPP
+			event := newExecEvent(t, binaryPath)
+			event.Timestamp = 1
+			require.NoError(t, trc.processEvent(event))
+
+			// the copy is compressed, and named by the algorithm
+			capturedPath := filepath.Join(trc.outDir.Name(), "host", "exec.1.binary"+captureCompressors[algorithm].ext)
+			info, err := os.Stat(capturedPath)
+			require.NoError(t, err)
+			assert.Less(t, info.Size(), int64(len(content)))
+			assert.Equal(t, int32(len(content)), trc.stats.CapBytesCount.Read())
+
+			// the content is read as it was captured
+			assert.Equal(t, string(content), readCapturedFile(t, trc, event))
+			assert.Equal(t, expectedHash, event.Args[len(event.Args)-1].Value)
+
+			if !xattrs {
+				t.Skip("the output directory doesn't support extended attributes")
+			}
+			mismatches, err := VerifyCaptures(trc.outDir.Name())
+			require.NoError(t, err)
+			assert.Empty(t, mismatches)
+
+			writeCorrupted(t, capturedPath)
+			mismatches, err = VerifyCaptures(trc.outDir.Name())
+			require.NoError(t, err)
+			require.Len(t, mismatches, 1)
+			assert.Equal(t, expectedHash, mismatches[0].ExpectedHash)
+		})
+	}
+}
+
+func Test_validateCaptureCompression(t *testing.T) {
+	testCases := []struct {
+		name        string
+		algorithm   string
+		level       int
+		expectedErr error
+	}{
+		{name: "no compression"},
+		{name: "default level", algorithm: "gzip"},
+		{name: "level", algorithm: "gzip", level: 9},
+		{name: "invalid level", algorithm: "gzip", level: 10, expectedErr: errors.New("invalid capture compression level - must be between 1 and 9 for gzip")},
+		{name: "level without algorithm", level: 3, expectedErr: errors.New("invalid capture compression level - requires a capture compression algorithm")},
+		{name: "unknown algorithm", algorithm: "lz4", expectedErr: errors.New("invalid capture compression - unknown algorithm lz4")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateCaptureCompression(tc.algorithm, tc.level)
+			if tc.expectedErr != nil {
+				assert.EqualError(t, err, tc.expectedErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// BenchmarkCaptureCompression compares the speed and the ratio of the compression algorithms, on the test binary
+// as a representative executable
+func BenchmarkCaptureCompression(b *testing.B) {
+	binaryPath, err := os.Executable()
+	require.NoError(b, err)
+	info, err := os.Stat(binaryPath)
+	require.NoError(b, err)
+	dir, err := os.Open(b.TempDir())
+	require.NoError(b, err)
+	defer dir.Close()
+
+	for _, algorithm := range compressionAlgorithms() {
+		compressor := captureCompressors[algorithm]
+		for _, level := range []int{compressor.minLevel, 0, compressor.maxLevel} {
+			name := algorithm + "/default"
+			if level != 0 {
+				name = algorithm + "/level-" + strconv.Itoa(level)
+			}
+			b.Run(name, func(b *testing.B) {
+				b.SetBytes(info.Size())
+				for i := 0; i < b.N; i++ {
+					if _, err := copyCompressed(binaryPath, dir, "captured", compressor, level); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.StopTimer()
+				compressed, err := os.Stat(filepath.Join(dir.Name(), "captured"))
+				require.NoError(b, err)
+				b.ReportMetric(float64(info.Size())/float64(compressed.Size()), "ratio")
+			})
+		}
+	}
+}
@@ -0,0 +1,39 @@
+//go:build zstd
+// +build zstd
+
+package ebpf
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_zstdCaptureCompression(t *testing.T) {
+	compressor, ok := captureCompressors[CaptureCompressionZstd]
+	require.True(t, ok)
+	assert.NoError(t, validateCaptureCompression(CaptureCompressionZstd, 22))
+	assert.EqualError(t, validateCaptureCompression(CaptureCompressionZstd, 23), "invalid capture compression level - must be between 1 and 22 for zstd")
+
+	// all the levels round trip, including the ones mapped to the same level of the encoder
+	content := bytes.Repeat([]byte("\x7fELF compressible captured binary "), 1024)
+	for _, level := range []int{0, 1, 3, 19, 22} {
+		var compressed bytes.Buffer
+		w, err := compressor.newWriter(&compressed, level)
+		require.NoError(t, err)
+		_, err = w.Write(content)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		assert.Less(t, compressed.Len(), len(content))
+
+		r, err := compressor.newReader(&compressed)
+		require.NoError(t, err)
+		decompressed, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		assert.Equal(t, content, decompressed)
+	}
+}
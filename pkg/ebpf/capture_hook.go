@@ -0,0 +1,214 @@
+package ebpf
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+const (
+	// defaultPostHookTimeout and defaultPostHookConcurrency bound the capture hooks, if not configured otherwise
+	defaultPostHookTimeout     = 30 * time.Second
+	defaultPostHookConcurrency = 2
+	// postHookOutputSize is the size of the output of a capture hook kept for its capture_hook event
+	postHookOutputSize = 4096
+	// postHookPathPlaceholder is replaced by the path of the captured file in the arguments of the hook command
+	postHookPathPlaceholder = "{path}"
+)
+
+// captureHooks runs the Capture.PostHook command on the captured files, in the background. The hooks running at
+// once are bounded by Capture.PostHookConcurrency, and the captures made while all of them run aren't hooked (and
+// are counted). A nil captureHooks runs no hook
+type captureHooks struct {
+	args     []string
+	timeout  time.Duration
+	slots    chan struct{} // a slot is taken by every running hook
+	mu       sync.Mutex
+	stopped  bool
+	stopping chan struct{} // closed once tracee stops, as the events may not be read anymore
+	running  sync.WaitGroup
+}
+
+func newCaptureHooks(command string, timeout time.Duration, concurrency int) *captureHooks {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = defaultPostHookTimeout
+	}
+	if concurrency <= 0 {
+		concurrency = defaultPostHookConcurrency
+	}
+	return &captureHooks{
+		args:     args,
+		timeout:  timeout,
+		slots:    make(chan struct{}, concurrency),
+		stopping: make(chan struct{}),
+	}
+}
+
+// command returns the hook command of a captured file, whose path replaces the placeholder of the arguments, or is
+// the last argument if there's none
+func (h *captureHooks) command(path string) *exec.Cmd {
+	args := make([]string, 0, len(h.args)+1)
+	replaced := false
+	for _, arg := range h.args {
+		if strings.Contains(arg, postHookPathPlaceholder) {
+			arg = strings.ReplaceAll(arg, postHookPathPlaceholder, path)
+			replaced = true
+		}
+		args = append(args, arg)
+	}
+	if !replaced {
+		args = append(args, path)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	// the hook runs in a process group of its own, so the processes it started are killed along with it
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd
+}
+
+// stop waits for the running hooks to finish (within their timeout). Files captured since aren't hooked
+func (h *captureHooks) stop() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	if !h.stopped {
+		h.stopped = true
+		close(h.stopping)
+	}
+	h.mu.Unlock()
+	h.running.Wait()
+}
+
+// postHookOutput keeps the start of the output of a capture hook
+type postHookOutput struct {
+	bytes.Buffer
+}
+
+func (o *postHookOutput) Write(p []byte) (int, error) {
+	if room := postHookOutputSize - o.Len(); room > 0 {
+		if len(p) > room {
+			o.Buffer.Write(p[:room])
+		} else {
+			o.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// runPostHook runs the Capture.PostHook command on a file copied into the output directory, in the background,
+// emitting its result as a capture_hook event if selected. Hooks running for longer than Capture.PostHookTimeout
+// are killed
+func (t *Tracee) runPostHook(capturedPath string) {
+	h := t.captureHooks
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopped {
+		return
+	}
+	select {
+	case h.slots <- struct{}{}:
+	default:
+		t.stats.CapHookSkippedCount.Increment()
+		t.log(logger.DebugLevel, "skipped capture hook as too many are running", "path", capturedPath)
+		return
+	}
+	h.running.Add(1)
+	go func() {
+		defer h.running.Done()
+		path := filepath.Join(t.outDir.Name(), capturedPath)
+		exitCode, output, timedOut := h.run(path)
+		<-h.slots
+		if timedOut {
+			t.log(logger.WarnLevel, "capture hook timed out", "path", path, "timeout", h.timeout.String())
+		} else if exitCode != 0 {
+			t.log(logger.DebugLevel, "capture hook failed", "path", path, "exit_code", exitCode)
+		}
+		t.emitPostHookResult(path, exitCode, output, timedOut)
+	}()
+}
+
+// run runs the hook command of a captured file, returning its exit code (or -1 if it didn't exit, e.g. as it
+// couldn't be started), the start of its output, and whether it was killed as it timed out
+func (h *captureHooks) run(path string) (int, string, bool) {
+	cmd := h.command(path)
+	var output postHookOutput
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Start(); err != nil {
+		return -1, err.Error(), false
+	}
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		cmd.Wait()
+	}()
+
+	timer := time.NewTimer(h.timeout)
+	defer timer.Stop()
+	timedOut := false
+	select {
+	case <-exited:
+	case <-timer.C:
+		timedOut = true
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-exited
+	}
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+	return exitCode, output.String(), timedOut
+}
+
+// emitPostHookResult emits a capture_hook event with the result of the hook of a captured file. Hooks run outside of
+// the events pipeline, so the event is sent to the output directly, and counted against the events limit as the
+// events of the pipeline are
+func (t *Tracee) emitPostHookResult(path string, exitCode int, output string, timedOut bool) {
+	if !t.events[events.CaptureHook].emit {
+		return
+	}
+	if t.maxEventsReached() {
+		return
+	}
+	def := events.Definitions.Get(events.CaptureHook)
+	event := trace.Event{
+		Timestamp:   int(t.eventTimestamp(uint64(t.clock.MonotonicNano()))),
+		ProcessName: "tracee-ebpf",
+		EventID:     int(events.CaptureHook),
+		EventName:   def.Name,
+		ArgsNum:     4,
+		Args: []trace.Argument{
+			{ArgMeta: def.Params[0], Value: path},
+			{ArgMeta: def.Params[1], Value: int32(exitCode)},
+			{ArgMeta: def.Params[2], Value: output},
+			{ArgMeta: def.Params[3], Value: timedOut},
+		},
+	}
+	select {
+	case t.config.ChanEvents <- event:
+	case <-t.captureHooks.stopping:
+		// the output may not read the events anymore once tracee stops, so the event is only sent if there's room
+		select {
+		case t.config.ChanEvents <- event:
+		default:
+			return
+		}
+	}
+	t.stats.EventCount.Increment()
+	t.countEmitted()
+}
@@ -0,0 +1,122 @@
+package ebpf
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_runPostHook(t *testing.T) {
+	scripts := t.TempDir()
+	hookScript := func(name string, script string) string {
+		path := filepath.Join(scripts, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755))
+		return path
+	}
+	scan := hookScript("scan", `echo "scanned $2 with $1"; exit 3`)
+	// the hook waits for a process of its own, which is killed along with it
+	hang := hookScript("hang", "sleep 10")
+
+	newHookedTracee := func(t *testing.T, config CaptureConfig) *Tracee {
+		trc := newTestTracee(t, Config{Capture: &config, ChanEvents: make(chan trace.Event, 10), ChanErrors: make(chan error, 10)})
+		trc.captureHooks = newCaptureHooks(config.PostHook, config.PostHookTimeout, config.PostHookConcurrency)
+		trc.events = map[events.ID]eventConfig{events.CaptureHook: {submit: true, emit: true}}
+		t.Cleanup(trc.captureHooks.stop)
+		return trc
+	}
+	receive := func(t *testing.T, trc *Tracee) trace.Event {
+		select {
+		case event := <-trc.config.ChanEvents:
+			return event
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "no capture_hook event")
+			return trace.Event{}
+		}
+	}
+
+	t.Run("fast hook", func(t *testing.T) {
+		trc := newHookedTracee(t, CaptureConfig{Exec: true, PostHook: scan + " --quick {path}"})
+		dir := t.TempDir()
+		exe := filepath.Join(dir, "tool")
+		require.NoError(t, ioutil.WriteFile(exe, []byte("binary"), 0755))
+
+		require.NoError(t, trc.processEvent(newExecEvent(t, exe)))
+		event := receive(t, trc)
+		assert.Equal(t, "capture_hook", event.EventName)
+		require.Len(t, event.Args, 4)
+		captured := event.Args[0].Value.(string)
+		assert.Equal(t, trc.outDir.Name(), filepath.Dir(filepath.Dir(captured)))
+		assert.FileExists(t, captured)
+		assert.Equal(t, int32(3), event.Args[1].Value)
+		assert.Equal(t, "scanned "+captured+" with --quick\n", event.Args[2].Value)
+		assert.Equal(t, false, event.Args[3].Value)
+	})
+
+	t.Run("timing out hook", func(t *testing.T) {
+		trc := newHookedTracee(t, CaptureConfig{PostHook: hang, PostHookTimeout: 100 * time.Millisecond, PostHookConcurrency: 1})
+		start := time.Now()
+		trc.runPostHook("host/exec.1.tool")
+		// files captured while as many hooks as allowed run aren't hooked
+		trc.runPostHook("host/exec.2.tool")
+		assert.Equal(t, int32(1), trc.stats.CapHookSkippedCount.Read())
+
+		event := receive(t, trc)
+		assert.Less(t, time.Since(start), 5*time.Second)
+		require.Len(t, event.Args, 4)
+		assert.Equal(t, filepath.Join(trc.outDir.Name(), "host/exec.1.tool"), event.Args[0].Value)
+		assert.Equal(t, int32(-1), event.Args[1].Value)
+		assert.Equal(t, true, event.Args[3].Value)
+		assert.EqualError(t, <-trc.config.ChanErrors, "capture hook timed out")
+
+		// the slot of the killed hook is free again
+		trc.runPostHook("host/exec.3.tool")
+		assert.Equal(t, int32(1), trc.stats.CapHookSkippedCount.Read())
+		trc.captureHooks.stop()
+		trc.runPostHook("host/exec.4.tool")
+		assert.Len(t, trc.config.ChanEvents, 1)
+	})
+
+	t.Run("not a command", func(t *testing.T) {
+		trc := newHookedTracee(t, CaptureConfig{PostHook: filepath.Join(scripts, "missing")})
+		trc.runPostHook("host/exec.1.tool")
+		event := receive(t, trc)
+		assert.Equal(t, int32(-1), event.Args[1].Value)
+		assert.Contains(t, event.Args[2].Value, "no such file or directory")
+		assert.Equal(t, false, event.Args[3].Value)
+	})
+
+	t.Run("events limit", func(t *testing.T) {
+		trc := newHookedTracee(t, CaptureConfig{PostHook: scan + " {path}"})
+		trc.config.Output.MaxEvents = 1
+		stopped := make(chan struct{})
+		trc.stopRun = func() { close(stopped) }
+
+		trc.runPostHook("host/exec.1.tool")
+		event := receive(t, trc)
+		assert.Equal(t, filepath.Join(trc.outDir.Name(), "host/exec.1.tool"), event.Args[0].Value)
+		// the run is stopped once the limit is reached, and the results of later hooks aren't emitted
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "the run wasn't stopped")
+		}
+		trc.runPostHook("host/exec.2.tool")
+		trc.captureHooks.stop()
+		assert.Empty(t, trc.config.ChanEvents)
+		assert.Equal(t, int32(1), trc.stats.EventCount.Read())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var hooks *captureHooks
+		assert.Nil(t, newCaptureHooks(" ", 0, 0))
+		hooks.stop()
+		trc := newTestTracee(t, Config{})
+		trc.runPostHook("host/exec.1.tool")
+	})
+}
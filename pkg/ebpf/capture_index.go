@@ -0,0 +1,294 @@
+package ebpf
+
+import (
+	"bufio"
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"golang.org/x/sys/unix"
+)
+
+// captureManifestFile is the file of the output directory recording the captured files, one json record per line
+const captureManifestFile = "manifest.jsonl"
+
+// CaptureRecord is a captured file, as recorded in the capture manifest
+type CaptureRecord struct {
+	// Path is the path of the copy of the file, relative to the output directory (or absolute, as loaded by
+	// LoadCaptureIndex)
+	Path string `json:"path"`
+	// SourcePath is the path the file was copied from, through the root of a process of its mount namespace
+	SourcePath string `json:"source_path"`
+	// Device (as major:minor) and Inode identify the captured file in its filesystem
+	Device string `json:"device"`
+	Inode  uint64 `json:"inode"`
+	Ctime  int64  `json:"ctime"`
+	Size   int64  `json:"size"`
+	// SHA256 is the sha256 of the content of the file, decompressed if its copy is compressed
+	SHA256 string `json:"sha256"`
+	// Time is the time the file was captured, in nanoseconds since the epoch
+	Time int64 `json:"time"`
+}
+
+// openCaptureManifest opens the capture manifest of the output directory for appending the records of the captured
+// files, so the manifest of an output directory spans the runs capturing into it
+func (t *Tracee) openCaptureManifest() error {
+	f, err := utils.OpenAt(t.outDir, captureManifestFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening capture manifest: %v", err)
+	}
+	t.manifestMu.Lock()
+	t.manifest = f
+	t.manifestMu.Unlock()
+	return nil
+}
+
+// closeCaptureManifest closes the capture manifest. Files captured after it is closed (e.g. the queued files copied
+// after shutdown) aren't recorded
+func (t *Tracee) closeCaptureManifest() error {
+	t.manifestMu.Lock()
+	defer t.manifestMu.Unlock()
+	if t.manifest == nil {
+		return nil
+	}
+	err := t.manifest.Close()
+	t.manifest = nil
+	return err
+}
+
+// recordCapture records a captured file in the capture manifest, with Capture.Manifest. The source file is
+// described as it is once copied, and the copy is hashed if its hash isn't given
+func (t *Tracee) recordCapture(sourcePath string, capturedPath string, hash string) {
+	if !t.config.Capture.Manifest {
+		return
+	}
+	record := CaptureRecord{Path: capturedPath, SourcePath: sourcePath, SHA256: hash, Time: t.clock.Now().UnixNano()}
+	if info, err := os.Stat(sourcePath); err == nil {
+		record.Size = info.Size()
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			record.Device = fmt.Sprintf("%d:%d", unix.Major(stat.Dev), unix.Minor(stat.Dev))
+			record.Inode = stat.Ino
+			record.Ctime = stat.Ctim.Nano()
+		}
+	}
+	if record.SHA256 == "" {
+		t.openFiles.acquire(1)
+		f, err := utils.OpenAt(t.outDir, capturedPath, os.O_RDONLY, 0)
+		if err == nil {
+			record.SHA256, err = t.hashCapturedFile(f, capturedPath)
+			f.Close()
+		}
+		t.openFiles.release(1)
+		if err != nil {
+			t.log(logger.DebugLevel, "failed hashing captured file for the capture manifest", "path", capturedPath, "error", err)
+		}
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		t.handleError(fmt.Errorf("error encoding capture manifest record: %v", err))
+		return
+	}
+
+	t.manifestMu.Lock()
+	defer t.manifestMu.Unlock()
+	if t.manifest == nil {
+		return
+	}
+	if _, err := t.manifest.Write(append(line, '\n')); err != nil {
+		t.handleError(fmt.Errorf("error writing capture manifest: %v", err))
+	}
+}
+
+// CompactCaptureManifest rewrites the capture manifest of the output directory with the latest record of every
+// captured file only, dropping the records superseded by captures of the file made since (i.e. once it was
+// modified). A file is told by its namespace (the first element of the path of its capture) and its device and
+// inode, or by its source path if it couldn't be stated. The manifest being compacted is kept, rotated to a file
+// named by the time of the compaction (e.g. manifest.1661500000000000000.jsonl).
+// It may be called while tracee is running, e.g. periodically (see Capture.ManifestCompactInterval) or on demand
+func (t *Tracee) CompactCaptureManifest() error {
+	if t.outDir == nil {
+		return fmt.Errorf("capture output directory is not initialized")
+	}
+	t.manifestMu.Lock()
+	defer t.manifestMu.Unlock()
+
+	f, err := utils.OpenAt(t.outDir, captureManifestFile, os.O_RDONLY, 0)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error opening capture manifest: %v", err)
+	}
+	index, err := NewCaptureIndex(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	compacted := captureManifestFile + ".tmp"
+	out, err := utils.CreateAt(t.outDir, compacted)
+	if err != nil {
+		return fmt.Errorf("error creating compacted capture manifest: %v", err)
+	}
+	encoder := json.NewEncoder(out)
+	for _, record := range latestCaptureRecords(index.records) {
+		if err := encoder.Encode(record); err != nil {
+			out.Close()
+			removeAt(t.outDir, compacted)
+			return fmt.Errorf("error writing compacted capture manifest: %v", err)
+		}
+	}
+	if err := out.Close(); err != nil {
+		removeAt(t.outDir, compacted)
+		return fmt.Errorf("error writing compacted capture manifest: %v", err)
+	}
+
+	// records of captures made while compacting are appended to the compacted manifest, once reopened
+	ext := filepath.Ext(captureManifestFile)
+	rotated := fmt.Sprintf("%s.%d%s", strings.TrimSuffix(captureManifestFile, ext), t.clock.Now().UnixNano(), ext)
+	if err := utils.RenameAt(t.outDir, captureManifestFile, t.outDir, rotated); err != nil {
+		removeAt(t.outDir, compacted)
+		return fmt.Errorf("error rotating capture manifest: %v", err)
+	}
+	if err := utils.RenameAt(t.outDir, compacted, t.outDir, captureManifestFile); err != nil {
+		return fmt.Errorf("error replacing capture manifest: %v", err)
+	}
+	if t.manifest == nil {
+		return nil
+	}
+	t.manifest.Close()
+	t.manifest, err = utils.OpenAt(t.outDir, captureManifestFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening capture manifest: %v", err)
+	}
+	return nil
+}
+
+// compactCaptureManifestPeriodically compacts the capture manifest every configured interval, until the context is
+// cancelled
+func (t *Tracee) compactCaptureManifestPeriodically(ctx gocontext.Context) {
+	ticker := t.clock.NewTicker(t.config.Capture.ManifestCompactInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			if err := t.CompactCaptureManifest(); err != nil {
+				t.handleError(err)
+			}
+		}
+	}
+}
+
+// latestCaptureRecords returns the latest record of every captured file (see CompactCaptureManifest), in the order
+// they were recorded
+func latestCaptureRecords(records []CaptureRecord) []CaptureRecord {
+	latest := make(map[string]int, len(records))
+	for i, record := range records {
+		latest[captureRecordKey(record)] = i
+	}
+	kept := make([]CaptureRecord, 0, len(latest))
+	for i, record := range records {
+		if latest[captureRecordKey(record)] == i {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
+
+// captureRecordKey returns the key telling the captures of a file apart from the captures of other files
+func captureRecordKey(record CaptureRecord) string {
+	namespace := strings.SplitN(filepath.ToSlash(record.Path), "/", 2)[0]
+	if record.Inode == 0 {
+		return fmt.Sprintf("%s:%s", namespace, record.SourcePath)
+	}
+	return fmt.Sprintf("%s:%s:%d", namespace, record.Device, record.Inode)
+}
+
+// CaptureIndex looks up the captured files recorded in a capture manifest, by their inode or their sha256
+type CaptureIndex struct {
+	records []CaptureRecord
+	byInode map[uint64][]int
+	byHash  map[string][]int
+}
+
+// NewCaptureIndex indexes the records of a capture manifest
+func NewCaptureIndex(manifest io.Reader) (*CaptureIndex, error) {
+	index := &CaptureIndex{byInode: make(map[uint64][]int), byHash: make(map[string][]int)}
+	scanner := bufio.NewScanner(manifest)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var record CaptureRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("invalid capture manifest record at line %d: %v", line, err)
+		}
+		index.add(record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading capture manifest: %v", err)
+	}
+	return index, nil
+}
+
+// LoadCaptureIndex indexes the capture manifest of the given output directory. The paths of the records are
+// resolved in the output directory
+func LoadCaptureIndex(outputPath string) (*CaptureIndex, error) {
+	f, err := os.Open(filepath.Join(outputPath, captureManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("error opening capture manifest: %v", err)
+	}
+	defer f.Close()
+	index, err := NewCaptureIndex(f)
+	if err != nil {
+		return nil, err
+	}
+	for i := range index.records {
+		index.records[i].Path = filepath.Join(outputPath, index.records[i].Path)
+	}
+	return index, nil
+}
+
+func (i *CaptureIndex) add(record CaptureRecord) {
+	record.SHA256 = strings.ToLower(record.SHA256)
+	i.records = append(i.records, record)
+	n := len(i.records) - 1
+	i.byInode[record.Inode] = append(i.byInode[record.Inode], n)
+	if record.SHA256 != "" {
+		i.byHash[record.SHA256] = append(i.byHash[record.SHA256], n)
+	}
+}
+
+// Records returns all the captured files, in the order they were captured
+func (i *CaptureIndex) Records() []CaptureRecord {
+	return append([]CaptureRecord(nil), i.records...)
+}
+
+// ByInode returns the captures of files of the given inode, in the order they were captured. Inodes are only unique
+// within a filesystem, so captures of other devices may be returned as well, to be told apart by their Device
+func (i *CaptureIndex) ByInode(inode uint64) []CaptureRecord {
+	return i.lookup(i.byInode[inode])
+}
+
+// ByHash returns the captures of files of the given sha256 (e.g. the same file captured for different containers),
+// in the order they were captured
+func (i *CaptureIndex) ByHash(sha256 string) []CaptureRecord {
+	return i.lookup(i.byHash[strings.ToLower(sha256)])
+}
+
+func (i *CaptureIndex) lookup(indices []int) []CaptureRecord {
+	records := make([]CaptureRecord, 0, len(indices))
+	for _, n := range indices {
+		records = append(records, i.records[n])
+	}
+	return records
+}
@@ -0,0 +1,164 @@
+package ebpf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func Test_captureIndex(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first")
+	second := filepath.Join(dir, "second")
+	other := filepath.Join(dir, "other")
+	require.NoError(t, ioutil.WriteFile(first, []byte("captured binary"), 0755))
+	require.NoError(t, ioutil.WriteFile(second, []byte("captured binary"), 0755))
+	require.NoError(t, ioutil.WriteFile(other, []byte("other binary"), 0755))
+	sum := sha256.Sum256([]byte("captured binary"))
+	hash := hex.EncodeToString(sum[:])
+	var stat syscall.Stat_t
+	require.NoError(t, syscall.Stat(first, &stat))
+
+	// captures the files, returning the index of their manifest
+	capture := func(t *testing.T, config CaptureConfig) (*Tracee, *CaptureIndex) {
+		trc := newTestTracee(t, Config{Capture: &config})
+		require.NoError(t, trc.openCaptureManifest())
+		firstExec := newExecEvent(t, first)
+		firstExec.Timestamp = 1
+		require.NoError(t, trc.processEvent(firstExec))
+		require.NoError(t, trc.processEvent(newSharedObjectLoadedEvent(2, second, 1)))
+		otherExec := newExecEvent(t, other)
+		otherExec.Timestamp = 3
+		require.NoError(t, trc.processEvent(otherExec))
+		require.NoError(t, trc.closeCaptureManifest())
+
+		index, err := LoadCaptureIndex(trc.outDir.Name())
+		require.NoError(t, err)
+		return trc, index
+	}
+
+	trc, index := capture(t, CaptureConfig{Exec: true, SharedObjects: true, Manifest: true})
+	assert.Len(t, index.Records(), 3)
+
+	byInode := index.ByInode(stat.Ino)
+	require.Len(t, byInode, 1)
+	record := byInode[0]
+	assert.Equal(t, filepath.Join(trc.outDir.Name(), "host", "exec.1.first"), record.Path)
+	assert.FileExists(t, record.Path)
+	assert.True(t, strings.HasSuffix(record.SourcePath, first))
+	assert.Equal(t, fmt.Sprintf("%d:%d", unix.Major(stat.Dev), unix.Minor(stat.Dev)), record.Device)
+	assert.Equal(t, stat.Ctim.Nano(), record.Ctime)
+	assert.Equal(t, int64(len("captured binary")), record.Size)
+	assert.Equal(t, hash, record.SHA256)
+	assert.NotZero(t, record.Time)
+
+	// identical files are told by their hash, whatever its case
+	byHash := index.ByHash(strings.ToUpper(hash))
+	require.Len(t, byHash, 2)
+	assert.Equal(t, record, byHash[0])
+	assert.True(t, strings.HasSuffix(byHash[1].SourcePath, second))
+	assert.NotEqual(t, record.Inode, byHash[1].Inode)
+
+	assert.Empty(t, index.ByHash(strings.Repeat("0", 64)))
+	assert.Empty(t, index.ByInode(0))
+
+	t.Run("compressed", func(t *testing.T) {
+		_, index := capture(t, CaptureConfig{Exec: true, Manifest: true, Compression: CaptureCompressionGzip})
+		byHash := index.ByHash(hash)
+		require.Len(t, byHash, 1)
+		assert.True(t, strings.HasSuffix(byHash[0].Path, "exec.1.first.gz"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		trc := newTestTracee(t, Config{Capture: &CaptureConfig{Exec: true}})
+		require.NoError(t, trc.processEvent(newExecEvent(t, first)))
+		assert.NoFileExists(t, filepath.Join(trc.outDir.Name(), captureManifestFile))
+	})
+}
+
+func TestNewCaptureIndex(t *testing.T) {
+	index, err := NewCaptureIndex(strings.NewReader(`{"path":"host/exec.1.ls","inode":42,"sha256":"ABCD"}
+
+{"path":"host/exec.2.ls","inode":42}
+`))
+	require.NoError(t, err)
+	// the paths aren't resolved without an output directory
+	assert.Equal(t, []CaptureRecord{
+		{Path: "host/exec.1.ls", Inode: 42, SHA256: "abcd"},
+		{Path: "host/exec.2.ls", Inode: 42},
+	}, index.ByInode(42))
+	assert.Len(t, index.ByHash("abcd"), 1)
+
+	_, err = NewCaptureIndex(strings.NewReader("{\"path\":\"host/exec.1.ls\"}\n{\"path\":"))
+	assert.EqualError(t, err, "invalid capture manifest record at line 2: unexpected end of JSON input")
+}
+
+func TestCompactCaptureManifest(t *testing.T) {
+	records := []CaptureRecord{
+		{Path: "host/exec.1.ls", SourcePath: "/proc/1/root/bin/ls", Device: "8:1", Inode: 42, SHA256: "aa", Time: 1},
+		{Path: "host/exec.2.sh", SourcePath: "/proc/1/root/bin/sh", Device: "8:1", Inode: 43, SHA256: "bb", Time: 2},
+		// the same file, captured again once modified
+		{Path: "host/exec.3.ls", SourcePath: "/proc/2/root/bin/ls", Device: "8:1", Inode: 42, SHA256: "cc", Time: 3},
+		// the same inode, of another device or container
+		{Path: "host/exec.4.ls", SourcePath: "/proc/3/root/bin/ls", Device: "8:2", Inode: 42, SHA256: "dd", Time: 4},
+		{Path: "container1/exec.5.ls", SourcePath: "/proc/4/root/bin/ls", Device: "8:1", Inode: 42, SHA256: "ee", Time: 5},
+		// files which couldn't be stated are told by their source path
+		{Path: "host/write.dev-0.inode-0", SourcePath: "/proc/5/root/tmp/gone", SHA256: "ff", Time: 6},
+		{Path: "host/write.dev-0.inode-0.1", SourcePath: "/proc/5/root/tmp/gone", SHA256: "00", Time: 7},
+		{Path: "host/exec.8.ls", SourcePath: "/proc/6/root/bin/ls", Device: "8:1", Inode: 42, SHA256: "11", Time: 8},
+	}
+	var manifest strings.Builder
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		require.NoError(t, err)
+		manifest.Write(append(line, '\n'))
+	}
+
+	trc := newTestTracee(t, Config{Capture: &CaptureConfig{Manifest: true}})
+	trc.clock = utils.NewFakeClock(time.Unix(1661500000, 0), 0)
+	dir := trc.outDir.Name()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, captureManifestFile), []byte(manifest.String()), 0644))
+	require.NoError(t, trc.openCaptureManifest())
+	require.NoError(t, trc.CompactCaptureManifest())
+
+	// superseded records are dropped, the latest ones are kept in the order they were recorded
+	index, err := LoadCaptureIndex(dir)
+	require.NoError(t, err)
+	var times []int64
+	for _, record := range index.Records() {
+		times = append(times, record.Time)
+	}
+	assert.Equal(t, []int64{2, 4, 5, 7, 8}, times)
+	assert.NoFileExists(t, filepath.Join(dir, captureManifestFile+".tmp"))
+
+	// the former manifest is rotated
+	rotated, err := ioutil.ReadFile(filepath.Join(dir, "manifest.1661500000000000000.jsonl"))
+	require.NoError(t, err)
+	assert.Equal(t, manifest.String(), string(rotated))
+
+	// captures recorded since are appended to the compacted manifest
+	trc.recordCapture("/proc/7/root/bin/true", "host/exec.9.true", "22")
+	require.NoError(t, trc.closeCaptureManifest())
+	index, err = LoadCaptureIndex(dir)
+	require.NoError(t, err)
+	require.Len(t, index.Records(), 6)
+	assert.Len(t, index.ByHash("22"), 1)
+
+	t.Run("no manifest", func(t *testing.T) {
+		trc := newTestTracee(t, Config{Capture: &CaptureConfig{Manifest: true}})
+		require.NoError(t, trc.CompactCaptureManifest())
+		assert.NoFileExists(t, filepath.Join(trc.outDir.Name(), captureManifestFile))
+	})
+}
@@ -0,0 +1,103 @@
+package ebpf
+
+import (
+	"container/list"
+	"strings"
+)
+
+// capturedFilesLimit bounds the number of files known to be captured of each namespace (see
+// CaptureConfig.NamespaceMaxFiles), keeping the ids of the captured files of every namespace in the order they were
+// captured, so the oldest ones are evicted once a namespace has too many. A nil capturedFilesLimit is unlimited.
+type capturedFilesLimit struct {
+	max        int
+	namespaces map[string]*list.List    // ids of the captured files of each namespace, from the oldest
+	entries    map[string]*list.Element // captured file ids, in the list of their namespace
+}
+
+func newCapturedFilesLimit(max int) *capturedFilesLimit {
+	if max <= 0 {
+		return nil
+	}
+	return &capturedFilesLimit{
+		max:        max,
+		namespaces: make(map[string]*list.List),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// capturedFileNamespace returns the namespace of a captured file id, which is prefixed by the capture dir of the
+// container or mount namespace the file was captured for
+func capturedFileNamespace(id string) string {
+	if i := strings.IndexByte(id, ':'); i >= 0 {
+		return id[:i]
+	}
+	return id
+}
+
+// add records a captured file as the newest of its namespace (also if it was captured before, as it's captured
+// again once modified), returning the id of the oldest file of the namespace if it has too many
+func (l *capturedFilesLimit) add(id string) (string, bool) {
+	if l == nil {
+		return "", false
+	}
+	if entry, ok := l.entries[id]; ok {
+		l.namespaces[capturedFileNamespace(id)].MoveToBack(entry)
+		return "", false
+	}
+	namespace := capturedFileNamespace(id)
+	ids, ok := l.namespaces[namespace]
+	if !ok {
+		ids = list.New()
+		l.namespaces[namespace] = ids
+	}
+	l.entries[id] = ids.PushBack(id)
+	if ids.Len() <= l.max {
+		return "", false
+	}
+	oldest := ids.Front().Value.(string)
+	l.remove(oldest)
+	return oldest, true
+}
+
+// remove forgets a captured file
+func (l *capturedFilesLimit) remove(id string) {
+	if l == nil {
+		return
+	}
+	entry, ok := l.entries[id]
+	if !ok {
+		return
+	}
+	delete(l.entries, id)
+	namespace := capturedFileNamespace(id)
+	ids := l.namespaces[namespace]
+	ids.Remove(entry)
+	if ids.Len() == 0 {
+		delete(l.namespaces, namespace)
+	}
+}
+
+// markCaptured records a file as captured with the given ctime. With Capture.NamespaceMaxFiles, the oldest captured
+// file of its namespace is forgotten if the namespace has too many, so it's captured again if it's seen again
+func (t *Tracee) markCaptured(capturedFileID string, ctime int64) {
+	t.capturedMu.Lock()
+	defer t.capturedMu.Unlock()
+	t.capturedFiles[capturedFileID] = ctime
+	t.indexCaptured(capturedFileID, true)
+	if evicted, ok := t.capturedLimit.add(capturedFileID); ok {
+		delete(t.capturedFiles, evicted)
+		delete(t.capturedHashes, evicted)
+		t.indexCaptured(evicted, false)
+		t.stats.CapEvictedCount.Increment()
+	}
+}
+
+// forgetCaptured forgets a captured file, so it's captured again if it's seen again
+func (t *Tracee) forgetCaptured(capturedFileID string) {
+	t.capturedMu.Lock()
+	defer t.capturedMu.Unlock()
+	delete(t.capturedFiles, capturedFileID)
+	delete(t.capturedHashes, capturedFileID)
+	t.capturedLimit.remove(capturedFileID)
+	t.indexCaptured(capturedFileID, false)
+}
@@ -0,0 +1,96 @@
+package ebpf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_capturedFilesLimit(t *testing.T) {
+	limit := newCapturedFilesLimit(2)
+
+	_, evicted := limit.add("mntns-1:open:dev-1.inode-1")
+	assert.False(t, evicted)
+	_, evicted = limit.add("mntns-1:open:dev-1.inode-2")
+	assert.False(t, evicted)
+	_, evicted = limit.add("mntns-2:open:dev-1.inode-1")
+	assert.False(t, evicted)
+
+	// a file captured again is the newest of its namespace
+	_, evicted = limit.add("mntns-1:open:dev-1.inode-1")
+	assert.False(t, evicted)
+	oldest, evicted := limit.add("mntns-1:open:dev-1.inode-3")
+	assert.True(t, evicted)
+	assert.Equal(t, "mntns-1:open:dev-1.inode-2", oldest)
+
+	// forgotten files make room in their namespace
+	limit.remove("mntns-1:open:dev-1.inode-1")
+	_, evicted = limit.add("mntns-1:open:dev-1.inode-4")
+	assert.False(t, evicted)
+	limit.remove("mntns-2:open:dev-1.inode-1")
+	assert.NotContains(t, limit.namespaces, "mntns-2")
+
+	t.Run("unlimited", func(t *testing.T) {
+		var limit *capturedFilesLimit
+		for i := 0; i < 10; i++ {
+			_, evicted := limit.add(fmt.Sprintf("host:open:dev-1.inode-%d", i))
+			assert.False(t, evicted)
+		}
+		limit.remove("host:open:dev-1.inode-1")
+	})
+}
+
+func Test_namespaceMaxFiles(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < 4; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("secret%d", i))
+		require.NoError(t, ioutil.WriteFile(path, []byte("password"), 0600))
+		paths = append(paths, path)
+	}
+
+	trc := newTestTracee(t, Config{Capture: &CaptureConfig{FileOpenPaths: []string{dir + "/*"}, NamespaceMaxFiles: 2}})
+	trc.capturedLimit = newCapturedFilesLimit(2)
+	trc.hostMntns = 4026531840
+	open := func(mntns int, path string) {
+		event := newFileOpenEvent(t, 1000, path)
+		event.MountNS = mntns
+		require.NoError(t, trc.processEvent(event))
+	}
+	capturedIn := func(captureDir string) int {
+		n := 0
+		for id := range trc.capturedFiles {
+			if capturedFileNamespace(id) == captureDir {
+				n++
+			}
+		}
+		return n
+	}
+
+	open(2, paths[0])
+	open(1, paths[0])
+	open(1, paths[1])
+	assert.Equal(t, int32(3), trc.stats.CapFileCount.Read())
+
+	// a namespace over its limit forgets its oldest captured files only
+	open(1, paths[2])
+	open(1, paths[3])
+	assert.Equal(t, int32(5), trc.stats.CapFileCount.Read())
+	assert.Equal(t, int32(2), trc.stats.CapEvictedCount.Read())
+	assert.Equal(t, 2, capturedIn("mntns-1"))
+	assert.Equal(t, 1, capturedIn("mntns-2"))
+
+	// files still known aren't captured again, while the forgotten ones are
+	open(1, paths[3])
+	open(2, paths[0])
+	assert.Equal(t, int32(5), trc.stats.CapFileCount.Read())
+	open(1, paths[0])
+	assert.Equal(t, int32(6), trc.stats.CapFileCount.Read())
+	assert.Equal(t, int32(3), trc.stats.CapEvictedCount.Read())
+	assert.Equal(t, 2, capturedIn("mntns-1"))
+	assert.Equal(t, 1, capturedIn("mntns-2"))
+}
@@ -0,0 +1,60 @@
+package ebpf
+
+import "fmt"
+
+// defaultCaptureWorkers is the number of capture workers, if not configured otherwise
+const defaultCaptureWorkers = 4
+
+// captureJob is the copy of a captured file, queued for the capture workers
+type captureJob struct {
+	sourcePath          string
+	destinationFilePath string
+}
+
+// startCaptureQueue starts the capture workers, copying the files queued by the events pipeline
+func (t *Tracee) startCaptureQueue() {
+	t.captureQueue = make(chan captureJob, t.config.Capture.QueueDepth)
+	workers := t.config.Capture.QueueWorkers
+	if workers <= 0 {
+		workers = defaultCaptureWorkers
+	}
+	for i := 0; i < workers; i++ {
+		t.workers.start("captures", t.processCaptureQueue)
+	}
+}
+
+// stopCaptureQueue stops the capture workers once they copied the queued files. It must only be called once the
+// pipeline stopped queueing
+func (t *Tracee) stopCaptureQueue() {
+	if t.captureQueue != nil {
+		close(t.captureQueue)
+	}
+}
+
+// queueCapture queues the copy of a captured file for the capture workers, without waiting for the queue. It returns
+// false if the queue is full, in which case the capture is dropped, and counted
+func (t *Tracee) queueCapture(job captureJob) bool {
+	select {
+	case t.captureQueue <- job:
+		return true
+	default:
+		t.stats.CapDroppedCount.Increment()
+		return false
+	}
+}
+
+// processCaptureQueue copies the queued files until the queue is stopped. Since files are marked as captured once
+// queued, files which fail to be copied aren't captured again until they are modified
+func (t *Tracee) processCaptureQueue() {
+	for job := range t.captureQueue {
+		capturedPath, err := t.copyCapturedFile(job.sourcePath, job.destinationFilePath)
+		if err != nil {
+			t.handleError(fmt.Errorf("error capturing file %s: %v", job.sourcePath, err), "capture_file", job.destinationFilePath)
+			continue
+		}
+		// the copy is hashed for its extended attribute, as the hash of the event may not be known yet
+		if t.config.Capture.HashXattr {
+			t.storeCapturedFileHash(capturedPath, "")
+		}
+	}
+}
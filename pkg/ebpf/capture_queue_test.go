@@ -0,0 +1,71 @@
+package ebpf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_captureQueue(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < 10; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("binary-%d", i))
+		require.NoError(t, ioutil.WriteFile(path, []byte(fmt.Sprintf("binary %d", i)), 0755))
+		paths = append(paths, path)
+	}
+
+	trc := newTestTracee(t, Config{
+		Capture: &CaptureConfig{Exec: true, QueueDepth: 4, QueueWorkers: 1},
+	})
+	// the capture worker is stalled by holding the files budget, leaving enough of it for the pipeline to read the
+	// head of executed files
+	trc.openFiles = newFileBudget(3)
+	trc.openFiles.acquire(2)
+	trc.startCaptureQueue()
+
+	captureExec := func(i int) {
+		event := newExecEvent(t, paths[i])
+		event.Timestamp = i + 1
+		require.NoError(t, trc.processEvent(event))
+	}
+	// the first capture is taken by the worker, which stalls copying it
+	captureExec(0)
+	require.Eventually(t, func() bool { return len(trc.captureQueue) == 0 }, 5*time.Second, time.Millisecond)
+
+	// a flood of captures fills the queue, and the captures over it are dropped rather than blocking the pipeline
+	flooded := make(chan struct{})
+	go func() {
+		defer close(flooded)
+		for i := 1; i < len(paths); i++ {
+			captureExec(i)
+		}
+	}()
+	select {
+	case <-flooded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the pipeline was blocked by the capture queue")
+	}
+	assert.Equal(t, int32(5), trc.stats.CapDroppedCount.Read())
+	// dropped captures aren't marked as captured, so the files are captured when they are executed again
+	assert.Len(t, trc.capturedFiles, 5)
+
+	// the queued captures are copied once the worker resumes
+	trc.openFiles.release(2)
+	trc.stopCaptureQueue()
+	assert.Empty(t, trc.workers.wait(5*time.Second))
+	assert.Equal(t, int32(5), trc.stats.CapFileCount.Read())
+	for i := range paths {
+		capturedPath := filepath.Join(trc.outDir.Name(), "host", fmt.Sprintf("exec.%d.binary-%d", i+1, i))
+		if i < 5 {
+			assert.FileExists(t, capturedPath)
+		} else {
+			assert.NoFileExists(t, capturedPath)
+		}
+	}
+}
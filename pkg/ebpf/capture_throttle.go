@@ -0,0 +1,106 @@
+package ebpf
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// captureThrottlePruneInterval is how often the buckets of mount namespaces which didn't capture for a while (and
+// are full again) are forgotten
+const captureThrottlePruneInterval = time.Minute
+
+// captureThrottle limits the rate of captures of each mount namespace with a token bucket per namespace, so the
+// captures of a noisy container can't monopolize the disk and the open files budget. A namespace may capture up to
+// rate files at once, and then rate files per second. A nil captureThrottle is unlimited.
+type captureThrottle struct {
+	mu        sync.Mutex
+	rate      float64
+	buckets   map[uint32]*tokenBucket
+	lastPrune time.Time
+}
+
+// tokenBucket is the capture budget of a mount namespace, as it was last updated
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newCaptureThrottle(rate int) *captureThrottle {
+	if rate <= 0 {
+		return nil
+	}
+	return &captureThrottle{
+		rate:    float64(rate),
+		buckets: make(map[uint32]*tokenBucket),
+	}
+}
+
+// allow checks if the given mount namespace may capture a file at the given time, taking a token of its bucket if
+// it may
+func (c *captureThrottle) allow(mntns uint32, now time.Time) bool {
+	if c == nil {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastPrune) > captureThrottlePruneInterval {
+		for ns, bucket := range c.buckets {
+			if c.refill(bucket, now) >= c.rate {
+				delete(c.buckets, ns)
+			}
+		}
+		c.lastPrune = now
+	}
+
+	bucket, ok := c.buckets[mntns]
+	if !ok {
+		bucket = &tokenBucket{tokens: c.rate, last: now}
+		c.buckets[mntns] = bucket
+	}
+	if c.refill(bucket, now) < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// refill adds the tokens accumulated by a bucket since it was last updated, up to the burst, returning its tokens
+func (c *captureThrottle) refill(bucket *tokenBucket, now time.Time) float64 {
+	if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.tokens += elapsed.Seconds() * c.rate
+		if bucket.tokens > c.rate {
+			bucket.tokens = c.rate
+		}
+		bucket.last = now
+	}
+	return bucket.tokens
+}
+
+// captureThrottled checks if capturing a file for the given event exceeds the capture rate of its mount namespace,
+// counting the throttled captures. Files which were already captured (with the same ctime) aren't copied again, so
+// they don't count towards the rate
+func (t *Tracee) captureThrottled(event *trace.Event, capturedFileID string, ctime int64) bool {
+	if t.captureThrottle == nil {
+		return false
+	}
+	if lastCtime, ok := t.capturedFiles[capturedFileID]; ok && lastCtime == ctime {
+		return false
+	}
+	if t.captureThrottle.allow(uint32(event.MountNS), t.clock.Now()) {
+		return false
+	}
+	t.stats.CapThrottledCount.Increment()
+	return true
+}
+
+// fileTooNew checks if a file is too new to be captured, as it was changed within Capture.MinFileAge before the
+// event (see CaptureConfig.MinFileAge)
+func (t *Tracee) fileTooNew(event *trace.Event, ctime int64) bool {
+	if t.config.Capture.MinFileAge <= 0 {
+		return false
+	}
+	return t.eventWallTime(event)-ctime < t.config.Capture.MinFileAge.Nanoseconds()
+}
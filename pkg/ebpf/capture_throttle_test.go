@@ -0,0 +1,129 @@
+package ebpf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_captureThrottle(t *testing.T) {
+	now := time.Unix(1661500000, 0)
+	throttle := newCaptureThrottle(2)
+
+	// a namespace captures a burst of up to the rate
+	assert.True(t, throttle.allow(1, now))
+	assert.True(t, throttle.allow(1, now))
+	assert.False(t, throttle.allow(1, now))
+	// the buckets of namespaces are independent
+	assert.True(t, throttle.allow(2, now))
+
+	// tokens are refilled over time, up to the burst
+	assert.False(t, throttle.allow(1, now.Add(400*time.Millisecond)))
+	assert.True(t, throttle.allow(1, now.Add(500*time.Millisecond)))
+	assert.True(t, throttle.allow(1, now.Add(time.Hour)))
+	assert.True(t, throttle.allow(1, now.Add(time.Hour)))
+	assert.False(t, throttle.allow(1, now.Add(time.Hour)))
+
+	// namespaces whose buckets are full again are forgotten
+	assert.NotContains(t, throttle.buckets, uint32(2))
+
+	t.Run("unlimited", func(t *testing.T) {
+		var throttle *captureThrottle
+		for i := 0; i < 10; i++ {
+			assert.True(t, throttle.allow(1, now))
+		}
+	})
+}
+
+func Test_captureThrottled(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < 6; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("secret%d", i))
+		require.NoError(t, ioutil.WriteFile(path, []byte("password"), 0600))
+		paths = append(paths, path)
+	}
+
+	trc := newTestTracee(t, Config{Capture: &CaptureConfig{FileOpenPaths: []string{dir + "/*"}, NamespaceRate: 2}})
+	trc.captureThrottle = newCaptureThrottle(2)
+	clock := utils.NewFakeClock(time.Unix(1661500000, 0), 0)
+	trc.clock = clock
+	open := func(mntns int, path string) {
+		event := newFileOpenEvent(t, 1000, path)
+		event.MountNS = mntns
+		require.NoError(t, trc.processEvent(event))
+	}
+
+	// the captures of a noisy namespace over its rate are throttled
+	for _, path := range paths[:4] {
+		open(1, path)
+	}
+	assert.Equal(t, int32(2), trc.stats.CapFileCount.Read())
+	assert.Equal(t, int32(2), trc.stats.CapThrottledCount.Read())
+
+	// while another namespace captures
+	open(2, paths[4])
+	assert.Equal(t, int32(3), trc.stats.CapFileCount.Read())
+
+	// files already captured aren't throttled, as they aren't copied again
+	open(1, paths[0])
+	assert.Equal(t, int32(3), trc.stats.CapFileCount.Read())
+	assert.Equal(t, int32(2), trc.stats.CapThrottledCount.Read())
+
+	// the noisy namespace captures again once its rate allows it
+	clock.Advance(time.Second)
+	open(1, paths[2])
+	open(1, paths[3])
+	assert.Equal(t, int32(5), trc.stats.CapFileCount.Read())
+	assert.Equal(t, int32(2), trc.stats.CapThrottledCount.Read())
+}
+
+func Test_fileTooNew(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "build.tmp")
+	require.NoError(t, ioutil.WriteFile(path, []byte("transient binary"), 0755))
+
+	trc := newTestTracee(t, Config{
+		Capture: &CaptureConfig{Exec: true, FileOpenPaths: []string{dir + "/*"}, MinFileAge: 2 * time.Second},
+		Output:  &OutputConfig{ExecHash: true},
+	})
+	exec := func(age time.Duration) *trace.Event {
+		event := newExecEvent(t, path)
+		ctime := events.GetArg(event, "ctime").Value.(uint64)
+		event.Timestamp = int(ctime + uint64(age))
+		require.NoError(t, trc.processEvent(event))
+		return event
+	}
+
+	// files changed right before their event aren't captured, while their events are still processed
+	event := exec(time.Second)
+	assert.NotNil(t, events.GetArg(event, "sha256"))
+	assert.Equal(t, int32(0), trc.stats.CapFileCount.Read())
+	open := newFileOpenEvent(t, 0, path)
+	open.Timestamp = int(events.GetArg(open, "ctime").Value.(uint64)) + int(time.Second)
+	require.NoError(t, trc.processEvent(open))
+	assert.Equal(t, int32(0), trc.stats.CapFileCount.Read())
+
+	// files are captured once they're old enough
+	exec(2 * time.Second)
+	assert.Equal(t, int32(1), trc.stats.CapFileCount.Read())
+
+	// relative timestamps are converted to the wall clock time of the event
+	trc = newTestTracee(t, Config{
+		Capture: &CaptureConfig{Exec: true, MinFileAge: 2 * time.Second},
+		Output:  &OutputConfig{RelativeTime: true},
+	})
+	trc.startTime = uint64(time.Hour)
+	ctime := events.GetArg(newExecEvent(t, path), "ctime").Value.(uint64)
+	trc.bootTime = ctime - trc.startTime
+	assert.True(t, trc.fileTooNew(&trace.Event{Timestamp: int(time.Second)}, int64(ctime)))
+	assert.False(t, trc.fileTooNew(&trace.Event{Timestamp: int(3 * time.Second)}, int64(ctime)))
+}
@@ -0,0 +1,189 @@
+package ebpf
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/events/parse"
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/aquasecurity/tracee/types/trace"
+	"golang.org/x/sys/unix"
+)
+
+// OpenCapturedFile opens the artifact captured for the given event, so its content can be read without knowing
+// the layout of the capture output directory. Supported events are sched_process_exec (when capturing executed
+// files), shared_object_loaded (when capturing shared objects), security_file_open (when capturing opened files) and
+// vfs_write, vfs_writev and kernel_write (when capturing written files). Executed files and shared objects captured
+// by their content are found by the sha256 argument of their event, and files mirrored at their original paths by
+// their pathname argument. Compressed copies are read decompressed.
+// It is the caller's responsibility to close the returned reader.
+func (t *Tracee) OpenCapturedFile(event *trace.Event) (io.ReadCloser, error) {
+	if t.outDir == nil {
+		return nil, fmt.Errorf("capture output directory is not initialized")
+	}
+
+	var relativePath string
+	var err error
+	switch events.ID(event.EventID) {
+	case events.SchedProcessExec, events.SharedObjectLoaded:
+		if t.contentAddressed() {
+			relativePath, err = capturedContentPath(event)
+		} else if events.ID(event.EventID) == events.SchedProcessExec {
+			relativePath, err = t.capturedCopyPath(event, "exec")
+		} else {
+			relativePath, err = t.capturedCopyPath(event, "so")
+		}
+	case events.SecurityFileOpen:
+		relativePath, err = t.capturedCopyPath(event, "open")
+	case events.VfsWrite, events.VfsWritev, events.KernelWrite:
+		relativePath, err = t.capturedWritePath(event)
+	default:
+		return nil, fmt.Errorf("no captured file for event %s", event.EventName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := utils.OpenAt(t.outDir, relativePath, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	// written files and files stored by their content aren't compressed, and are never named as compressed copies
+	if compressor, ok := t.compressedCapture(relativePath); ok {
+		decompressed, err := compressor.newReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error decompressing captured file %s: %v", relativePath, err)
+		}
+		return decompressingReader{ReadCloser: decompressed, file: f}, nil
+	}
+	return f, nil
+}
+
+// capturedCopyPath returns the path of the capture of an executed, loaded or opened file (named with the given
+// prefix) which was valid at the time of the given event. Since a file is only captured again after it was
+// modified, this is the latest capture of the file made up to the event's timestamp.
+func (t *Tracee) capturedCopyPath(event *trace.Event, prefix string) (string, error) {
+	filePath, err := parse.ArgStringVal(event, "pathname")
+	if err != nil {
+		return "", fmt.Errorf("error parsing %s args: %v", event.EventName, err)
+	}
+
+	dirPath := t.captureDir(event.ContainerID, uint32(event.MountNS))
+	if t.config.Capture.MirrorPaths {
+		return t.capturedMirroredPath(event, dirPath, filePath)
+	}
+	dir, err := utils.OpenAt(t.outDir, dirPath, unix.O_RDONLY|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return "", err
+	}
+	defer dir.Close()
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return "", err
+	}
+
+	suffix := "." + filepath.Base(filePath)
+	if compressor, ok := t.captureCompressor(); ok {
+		suffix += compressor.ext
+	}
+	found := ""
+	var foundTs int64
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix+".") || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		ts, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, prefix+"."), suffix), 10, 64)
+		if err != nil || ts > int64(event.Timestamp) {
+			continue
+		}
+		if found == "" || ts > foundTs {
+			found = name
+			foundTs = ts
+		}
+	}
+	if found == "" {
+		return "", fmt.Errorf("no captured file of %s found in %s: %w", filePath, dirPath, os.ErrNotExist)
+	}
+
+	return filepath.Join(dirPath, found), nil
+}
+
+// capturedMirroredPath returns the path of the capture of a file mirrored at its original path in the given capture
+// directory (see captureDestination) which was valid at the time of the given event: the latest capture suffixed by
+// the timestamp of its event up to the event's timestamp, or else the first capture of the file, which isn't suffixed
+func (t *Tracee) capturedMirroredPath(event *trace.Event, dirPath string, filePath string) (string, error) {
+	relativePath, err := mirroredPath(strings.TrimSuffix(filePath, deletedSuffix))
+	if err != nil {
+		return "", err
+	}
+	destinationFilePath := filepath.Join(dirPath, relativePath)
+	dir, err := utils.OpenAt(t.outDir, filepath.Dir(destinationFilePath), unix.O_RDONLY|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return "", err
+	}
+	defer dir.Close()
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return "", err
+	}
+
+	base := filepath.Base(destinationFilePath)
+	var ext string
+	if compressor, ok := t.captureCompressor(); ok {
+		ext = compressor.ext
+	}
+	first := false
+	found := ""
+	var foundTs int64
+	for _, name := range names {
+		if name == base+ext {
+			first = true
+			continue
+		}
+		if !strings.HasPrefix(name, base+".") || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		ts, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, base+"."), ext), 10, 64)
+		if err != nil || ts > int64(event.Timestamp) {
+			continue
+		}
+		if found == "" || ts > foundTs {
+			found = name
+			foundTs = ts
+		}
+	}
+	if found == "" && first {
+		found = base + ext
+	}
+	if found == "" {
+		return "", fmt.Errorf("no captured file of %s found in %s: %w", filePath, dirPath, os.ErrNotExist)
+	}
+
+	return filepath.Join(filepath.Dir(destinationFilePath), found), nil
+}
+
+// capturedWritePath returns the path of the written file capture of the given event
+func (t *Tracee) capturedWritePath(event *trace.Event) (string, error) {
+	dev, err := parse.ArgUint32Val(event, "dev")
+	if err != nil {
+		return "", fmt.Errorf("error parsing %s args: %v", event.EventName, err)
+	}
+	inode, err := parse.ArgUint64Val(event, "inode")
+	if err != nil {
+		return "", fmt.Errorf("error parsing %s args: %v", event.EventName, err)
+	}
+
+	// same naming as in processFileWrites, where writes to /dev/null are captured per process
+	relativePath := fmt.Sprintf("%s/write.dev-%d.inode-%d", t.captureDir(event.ContainerID, 0), dev, inode)
+	if filePath, err := parse.ArgStringVal(event, "pathname"); err == nil && strings.HasPrefix(filePath, "/dev/null") {
+		relativePath = fmt.Sprintf("%s.pid-%d", relativePath, event.HostProcessID)
+	}
+
+	return relativePath, nil
+}
@@ -0,0 +1,93 @@
+package ebpf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readCapturedFile(t *testing.T, trc *Tracee, event *trace.Event) string {
+	r, err := trc.OpenCapturedFile(event)
+	require.NoError(t, err)
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestOpenCapturedFile(t *testing.T) {
+	t.Run("executed file", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "TestOpenCapturedFile-*")
+		require.NoError(t, err)
+		defer os.Remove(f.Name())
+		_, err = f.WriteString("first version")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		trc := newTestTracee(t, Config{
+			Capture: &CaptureConfig{Exec: true},
+		})
+
+		// not captured yet
+		event := newExecEvent(t, f.Name())
+		event.Timestamp = 10
+		_, err = trc.OpenCapturedFile(event)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		require.NoError(t, trc.processEvent(event))
+		assert.Equal(t, "first version", readCapturedFile(t, trc, event))
+
+		// an unmodified file is not captured again, so a later exec resolves to the first capture
+		event = newExecEvent(t, f.Name())
+		event.Timestamp = 20
+		require.NoError(t, trc.processEvent(event))
+		assert.Equal(t, "first version", readCapturedFile(t, trc, event))
+
+		// a modified file is captured again, while the earlier exec still resolves to the first capture
+		require.NoError(t, ioutil.WriteFile(f.Name(), []byte("second version"), 0644))
+		event = newExecEvent(t, f.Name())
+		event.Args[1].Value = event.Args[1].Value.(uint64) + 1 // make sure ctime changed
+		event.Timestamp = 30
+		require.NoError(t, trc.processEvent(event))
+		assert.Equal(t, "second version", readCapturedFile(t, trc, event))
+
+		event.Timestamp = 25
+		assert.Equal(t, "first version", readCapturedFile(t, trc, event))
+	})
+
+	t.Run("written file", func(t *testing.T) {
+		trc := newTestTracee(t, Config{})
+		d := filepath.Join(trc.outDir.Name(), "host")
+		require.NoError(t, os.Mkdir(d, 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(d, "write.dev-1.inode-2"), []byte("written"), 0640))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(d, "write.dev-1.inode-3.pid-4"), []byte("discarded"), 0640))
+
+		event := &trace.Event{
+			EventID:   int(events.VfsWrite),
+			EventName: "vfs_write",
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/tmp/written"},
+				{ArgMeta: trace.ArgMeta{Name: "dev", Type: "dev_t"}, Value: uint32(1)},
+				{ArgMeta: trace.ArgMeta{Name: "inode", Type: "unsigned long"}, Value: uint64(2)},
+			},
+		}
+		assert.Equal(t, "written", readCapturedFile(t, trc, event))
+
+		event.HostProcessID = 4
+		event.Args[0].Value = "/dev/null"
+		event.Args[2].Value = uint64(3)
+		assert.Equal(t, "discarded", readCapturedFile(t, trc, event))
+	})
+
+	t.Run("unsupported event", func(t *testing.T) {
+		trc := newTestTracee(t, Config{})
+		_, err := trc.OpenCapturedFile(&trace.Event{EventID: int(events.SchedProcessExit), EventName: "sched_process_exit"})
+		assert.EqualError(t, err, "no captured file for event sched_process_exit")
+	})
+}
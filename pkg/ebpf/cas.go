@@ -0,0 +1,124 @@
+package ebpf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/aquasecurity/tracee/types/trace"
+	"golang.org/x/sys/unix"
+)
+
+// casDir is the directory (relative to the output dir) in which files are captured by their content
+const casDir = "cas"
+
+// contentAddressed checks if captured files are stored by their content. The layout relies on the hashes of the
+// captured files, so files are captured in the plain layout when exec hashing is off
+func (t *Tracee) contentAddressed() bool {
+	return t.config.Capture.ContentAddressed && t.config.Output.ExecHash
+}
+
+// casPath returns the path (relative to the output dir) of the captured content with the given sha256
+func casPath(hash string) string {
+	return filepath.Join(casDir, hash[:2], hash)
+}
+
+// captureContent captures a file in the content addressed layout, unless it was already captured with the same
+// ctime. The file is copied to a temporary file while it's hashed, which is then renamed by its hash, so identical
+// content (e.g. the same binary executed in many containers) is stored once.
+// It returns the sha256 of the file, and the path of the stored content if it wasn't stored before
+func (t *Tracee) captureContent(sourcePath string, capturedFileID string, ctime int64) (string, string, error) {
+	if lastCtime, ok := t.capturedFiles[capturedFileID]; ok && lastCtime == ctime {
+		if hash, ok := t.capturedHashes[capturedFileID]; ok {
+			return hash, "", nil
+		}
+	}
+
+	if err := utils.MkdirAtExist(t.outDir, casDir, 0755); err != nil {
+		return "", "", err
+	}
+	tmpPath := filepath.Join(casDir, fmt.Sprintf(".tmp.%d", t.clock.MonotonicNano()))
+	t.openFiles.acquire(2)
+	hash, copied, err := copyAndHash(sourcePath, t.outDir, tmpPath)
+	t.openFiles.release(2)
+	if err != nil {
+		removeAt(t.outDir, tmpPath)
+		return "", "", err
+	}
+	t.markCaptured(capturedFileID, ctime)
+	t.capturedHashes[capturedFileID] = hash
+	t.eventCaptured = true
+
+	storedPath := casPath(hash)
+	if stored, err := utils.OpenAt(t.outDir, storedPath, os.O_RDONLY, 0); err == nil {
+		// the content was already stored for another file
+		stored.Close()
+		removeAt(t.outDir, tmpPath)
+		t.recordCapture(sourcePath, storedPath, hash)
+		return hash, "", nil
+	}
+	if err := utils.MkdirAtExist(t.outDir, filepath.Dir(storedPath), 0755); err != nil {
+		removeAt(t.outDir, tmpPath)
+		return "", "", err
+	}
+	if err := utils.RenameAt(t.outDir, tmpPath, t.outDir, storedPath); err != nil {
+		removeAt(t.outDir, tmpPath)
+		return "", "", err
+	}
+	t.stats.CapFileCount.Increment()
+	t.stats.CapBytesCount.Increment(int(copied))
+	t.recordCapture(sourcePath, storedPath, hash)
+	t.runPostHook(storedPath)
+	return hash, storedPath, nil
+}
+
+// copyAndHash copies a regular file to a path relative to the given directory, returning the sha256 of its content
+// and the number of bytes copied
+func copyAndHash(srcName string, dstDir *os.File, dstName string) (string, int64, error) {
+	info, err := os.Stat(srcName)
+	if err != nil {
+		return "", 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return "", 0, fmt.Errorf("%s is not a regular file", srcName)
+	}
+	source, err := os.Open(srcName)
+	if err != nil {
+		return "", 0, err
+	}
+	defer source.Close()
+	destination, err := utils.CreateAt(dstDir, dstName)
+	if err != nil {
+		return "", 0, err
+	}
+	defer destination.Close()
+
+	h := sha256.New()
+	copied, err := io.Copy(io.MultiWriter(destination, h), source)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), copied, nil
+}
+
+// removeAt removes a file given by its path relative to a directory, ignoring failures
+func removeAt(dir *os.File, relativePath string) {
+	_ = unix.Unlinkat(int(dir.Fd()), relativePath, 0)
+}
+
+// capturedContentPath returns the path of the content captured for an event, as referenced by its sha256 argument
+func capturedContentPath(event *trace.Event) (string, error) {
+	for _, arg := range event.Args {
+		if arg.Name != "sha256" {
+			continue
+		}
+		if hash, ok := arg.Value.(string); ok && len(hash) == 2*sha256.Size {
+			return casPath(hash), nil
+		}
+	}
+	return "", fmt.Errorf("no captured content referenced by event %s: %w", event.EventName, os.ErrNotExist)
+}
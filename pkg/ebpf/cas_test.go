@@ -0,0 +1,133 @@
+package ebpf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func eventSha256(t *testing.T, event *trace.Event) string {
+	arg := events.GetArg(event, "sha256")
+	require.NotNil(t, arg, "event %s has no sha256 argument", event.EventName)
+	hash, ok := arg.Value.(string)
+	require.True(t, ok)
+	return hash
+}
+
+func Test_captureContent(t *testing.T) {
+	content := "shared library"
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])
+
+	f, err := ioutil.TempFile("", "Test_captureContent-*.so")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	t.Run("identical content is stored once", func(t *testing.T) {
+		trc := newTestTracee(t, Config{
+			Capture: &CaptureConfig{SharedObjects: true, ContentAddressed: true},
+			Output:  &OutputConfig{ExecHash: true},
+		})
+
+		// the same library loaded in two containers
+		first := newSharedObjectLoadedEvent(1000, f.Name(), 1)
+		first.ContainerID = "aaaaaaaaaaaa"
+		second := newSharedObjectLoadedEvent(2000, f.Name(), 1)
+		second.ContainerID = "bbbbbbbbbbbb"
+		require.NoError(t, trc.processEvent(first))
+		require.NoError(t, trc.processEvent(second))
+
+		assert.Equal(t, hash, eventSha256(t, first))
+		assert.Equal(t, hash, eventSha256(t, second))
+		assert.Equal(t, int32(1), trc.stats.CapFileCount.Read())
+		assert.Equal(t, int32(len(content)), trc.stats.CapBytesCount.Read())
+
+		blobs, err := filepath.Glob(filepath.Join(trc.outDir.Name(), casDir, "*", "*"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{filepath.Join(trc.outDir.Name(), casDir, hash[:2], hash)}, blobs)
+		// no temporary files are left
+		names, err := ioutil.ReadDir(filepath.Join(trc.outDir.Name(), casDir))
+		require.NoError(t, err)
+		assert.Len(t, names, 1)
+
+		assert.Equal(t, content, readCapturedFile(t, trc, first))
+		assert.Equal(t, content, readCapturedFile(t, trc, second))
+
+		// loading the library again in a container references the stored content without copying it
+		again := newSharedObjectLoadedEvent(3000, f.Name(), 1)
+		again.ContainerID = "aaaaaaaaaaaa"
+		require.NoError(t, trc.processEvent(again))
+		assert.Equal(t, hash, eventSha256(t, again))
+		assert.Equal(t, int32(1), trc.stats.CapFileCount.Read())
+	})
+
+	t.Run("executed files", func(t *testing.T) {
+		trc := newTestTracee(t, Config{
+			Capture: &CaptureConfig{Exec: true, ContentAddressed: true},
+			Output:  &OutputConfig{ExecHash: true},
+		})
+
+		first := newExecEvent(t, f.Name())
+		first.Timestamp = 10
+		first.ContainerID = "aaaaaaaaaaaa"
+		second := newExecEvent(t, f.Name())
+		second.Timestamp = 20
+		second.ContainerID = "bbbbbbbbbbbb"
+		require.NoError(t, trc.processEvent(first))
+		require.NoError(t, trc.processEvent(second))
+
+		assert.Equal(t, hash, eventSha256(t, first))
+		assert.Equal(t, hash, eventSha256(t, second))
+		assert.Equal(t, int32(1), trc.stats.CapFileCount.Read())
+		// the files were hashed while they were captured
+		assert.Equal(t, int32(0), trc.stats.HashCacheMisses.Read())
+		assert.Equal(t, content, readCapturedFile(t, trc, first))
+		assert.Equal(t, content, readCapturedFile(t, trc, second))
+	})
+
+	t.Run("profiled files", func(t *testing.T) {
+		trc := newTestTracee(t, Config{
+			Capture: &CaptureConfig{Exec: true, Profile: true, ContentAddressed: true},
+			Output:  &OutputConfig{ExecHash: true},
+		})
+
+		first := newExecEvent(t, f.Name())
+		first.ContainerID = "aaaaaaaaaaaa"
+		second := newExecEvent(t, f.Name())
+		second.ContainerID = "bbbbbbbbbbbb"
+		require.NoError(t, trc.processEvent(first))
+		require.NoError(t, trc.processEvent(second))
+
+		// both files are profiled as the stored content, though it was only copied for the first one
+		require.Len(t, trc.profiledFiles, 2)
+		trc.updateFileSHA()
+		for key, profiled := range trc.profiledFiles {
+			assert.Equal(t, casPath(hash), profiled.CapturedPath, key)
+			assert.Equal(t, hash, profiled.FileHash, key)
+		}
+	})
+
+	t.Run("plain layout without exec hashing", func(t *testing.T) {
+		trc := newTestTracee(t, Config{
+			Capture: &CaptureConfig{SharedObjects: true, ContentAddressed: true},
+		})
+
+		loaded := newSharedObjectLoadedEvent(1000, f.Name(), 1)
+		require.NoError(t, trc.processEvent(loaded))
+		assert.Nil(t, events.GetArg(loaded, "sha256"))
+		assert.Equal(t, content, readCapturedFile(t, trc, loaded))
+		_, err := os.Stat(filepath.Join(trc.outDir.Name(), casDir))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
@@ -0,0 +1,110 @@
+package ebpf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// cgroupPathsCacheSize is the number of cgroup paths cached, of mount namespaces and of host processes
+const cgroupPathsCacheSize = 4096
+
+// cgroupPathKey is the key of a cached cgroup path: the mount namespace of a container, or a host process
+type cgroupPathKey struct {
+	mntns   uint32
+	hostPid int // of host processes only
+}
+
+// addCgroupPath adds the cgroup path of the process of an event as a cgroup_path argument, with Output.CgroupPath.
+// The path is empty if it can't be resolved (e.g. as all known processes of its namespace exited)
+func (t *Tracee) addCgroupPath(event *trace.Event) {
+	event.Args = append(event.Args, trace.Argument{
+		ArgMeta: trace.ArgMeta{Name: "cgroup_path", Type: "const char*"},
+		Value:   t.cgroupPath(uint32(event.MountNS), event.HostProcessID, events.ID(event.EventID) == events.SchedProcessExit),
+	})
+	event.ArgsNum++
+}
+
+// cgroupPath resolves the cgroup path of a process from /proc/PID/cgroup. The processes of a container share its
+// cgroup, so it's read from a live process of its mount namespace and cached by the namespace. Host processes are of
+// different cgroups (e.g. systemd services), so their own cgroup is read and cached by their pid, until they exit
+// (see forgetCgroupPath). The cgroup of an exiting host process isn't cached again, as its pid may be reused
+func (t *Tracee) cgroupPath(mntns uint32, hostPid int, exiting bool) string {
+	key := cgroupPathKey{mntns: mntns}
+	pids := t.pidsInMntns.GetBucket(mntns)
+	if mntns == t.hostMntns {
+		key.hostPid = hostPid
+		pids = []uint32{uint32(hostPid)}
+	}
+	if path, ok := t.cgroupPaths.Get(key); ok {
+		return path.(string)
+	}
+	for _, pid := range pids {
+		f, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+		if err != nil {
+			continue
+		}
+		path, err := parseCgroupPath(f)
+		f.Close()
+		if err != nil {
+			t.log(logger.DebugLevel, "failed reading cgroup path", "pid", pid, "error", err)
+			continue
+		}
+		if key.hostPid == 0 || !exiting {
+			t.cgroupPaths.Add(key, path)
+		}
+		return path
+	}
+	return ""
+}
+
+// forgetCgroupPath drops the cached cgroup path of an exited host process, as its pid may be reused
+func (t *Tracee) forgetCgroupPath(mntns uint32, hostPid int) {
+	if t.cgroupPaths == nil || mntns != t.hostMntns {
+		return
+	}
+	t.cgroupPaths.Remove(cgroupPathKey{mntns: mntns, hostPid: hostPid})
+}
+
+// parseCgroupPath returns the cgroup path of a process as of its cgroup file (see cgroups(7)). With cgroups v2 this
+// is the path in the unified hierarchy, and with cgroups v1 the path in the systemd hierarchy, which is named after
+// the pod slices and the container scopes as well (or the path in the first hierarchy, if there's no systemd one)
+func parseCgroupPath(cgroup io.Reader) (string, error) {
+	var unified, systemd, first string
+	scanner := bufio.NewScanner(cgroup)
+	for scanner.Scan() {
+		// hierarchy-ID:controller-list:cgroup-path, where the path may contain ':'
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		switch {
+		case fields[0] == "0" && fields[1] == "":
+			unified = fields[2]
+		case fields[1] == "name=systemd":
+			systemd = fields[2]
+		case first == "":
+			first = fields[2]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	// with the hybrid hierarchy, the unified one is only used by systemd and doesn't have controllers
+	if systemd != "" {
+		return systemd, nil
+	}
+	if unified != "" {
+		return unified, nil
+	}
+	if first != "" {
+		return first, nil
+	}
+	return "", fmt.Errorf("no cgroup hierarchy found")
+}
@@ -0,0 +1,121 @@
+package ebpf
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseCgroupPath(t *testing.T) {
+	testCases := []struct {
+		name         string
+		cgroup       string
+		expectedPath string
+		expectedErr  string
+	}{
+		{
+			name:         "cgroups v2",
+			cgroup:       "0::/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod1234.slice/cri-containerd-abcd.scope\n",
+			expectedPath: "/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod1234.slice/cri-containerd-abcd.scope",
+		},
+		{
+			name: "cgroups v1",
+			cgroup: "12:memory:/kubepods/besteffort/pod1234/abcd\n" +
+				"11:cpu,cpuacct:/kubepods/besteffort/pod1234/abcd\n" +
+				"1:name=systemd:/kubepods/besteffort/pod1234/abcd\n",
+			expectedPath: "/kubepods/besteffort/pod1234/abcd",
+		},
+		{
+			name: "hybrid",
+			cgroup: "12:memory:/system.slice/docker-abcd.scope\n" +
+				"1:name=systemd:/system.slice/docker-abcd.scope\n" +
+				"0::/system.slice/docker-abcd.scope\n",
+			expectedPath: "/system.slice/docker-abcd.scope",
+		},
+		{
+			name:         "cgroups v1 without systemd",
+			cgroup:       "3:cpuset:/docker/abcd\n2:memory:/docker/abcd\n",
+			expectedPath: "/docker/abcd",
+		},
+		{
+			name:         "path with colons",
+			cgroup:       "0::/system.slice/a:b.service\n",
+			expectedPath: "/system.slice/a:b.service",
+		},
+		{
+			name:        "empty",
+			cgroup:      "",
+			expectedErr: "no cgroup hierarchy found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := parseCgroupPath(strings.NewReader(tc.cgroup))
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedPath, path)
+		})
+	}
+}
+
+func Test_addCgroupPath(t *testing.T) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		t.Skip("cgroups aren't available")
+	}
+	ownPath, err := parseCgroupPath(f)
+	f.Close()
+	require.NoError(t, err)
+
+	trc := newTestTracee(t, Config{Output: &OutputConfig{CgroupPath: true}})
+	trc.cgroupPaths, err = lru.New(cgroupPathsCacheSize)
+	require.NoError(t, err)
+	trc.pidsInMntns.Init(5)
+	trc.hostMntns = 4026531840
+	const containerMntns = 4026532000
+	pid := os.Getpid()
+	cgroupPath := func(mntns uint32, hostPid int, eventID events.ID) interface{} {
+		event := &trace.Event{EventID: int(eventID), MountNS: int(mntns), HostProcessID: hostPid}
+		trc.addCgroupPath(event)
+		require.Equal(t, 1, event.ArgsNum)
+		arg := events.GetArg(event, "cgroup_path")
+		require.NotNil(t, arg)
+		return arg.Value
+	}
+
+	t.Run("container", func(t *testing.T) {
+		// the path is read from a known process of the namespace, whichever process the event is of
+		trc.pidsInMntns.AddBucketItem(containerMntns, uint32(pid))
+		assert.Equal(t, ownPath, cgroupPath(containerMntns, 1337, events.Openat))
+		// and cached for the namespace
+		trc.cgroupPaths.Add(cgroupPathKey{mntns: containerMntns}, "/kubepods/pod1234/abcd")
+		assert.Equal(t, "/kubepods/pod1234/abcd", cgroupPath(containerMntns, 1338, events.Openat))
+		// no process of the namespace is known
+		assert.Equal(t, "", cgroupPath(containerMntns+1, pid, events.Openat))
+	})
+
+	t.Run("host", func(t *testing.T) {
+		// the path is read from the process of the event itself
+		assert.Equal(t, ownPath, cgroupPath(trc.hostMntns, pid, events.Openat))
+		trc.cgroupPaths.Add(cgroupPathKey{mntns: trc.hostMntns, hostPid: pid}, "/system.slice/tracee.service")
+		assert.Equal(t, "/system.slice/tracee.service", cgroupPath(trc.hostMntns, pid, events.Openat))
+
+		// exited processes are forgotten, and their exit isn't cached
+		trc.forgetCgroupPath(trc.hostMntns, pid)
+		assert.Equal(t, ownPath, cgroupPath(trc.hostMntns, pid, events.SchedProcessExit))
+		assert.False(t, trc.cgroupPaths.Contains(cgroupPathKey{mntns: trc.hostMntns, hostPid: pid}))
+		// the namespaces of containers aren't forgotten along their processes
+		trc.forgetCgroupPath(containerMntns, pid)
+		assert.True(t, trc.cgroupPaths.Contains(cgroupPathKey{mntns: containerMntns}))
+	})
+}
@@ -0,0 +1,110 @@
+package ebpf
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// eventsCoalescer merges consecutive identical events into the first of them, which counts them in a repeat_count
+// argument. Events are identical if they have the same event ID, process, thread and argument values (e.g. the fd,
+// buffer and count of a read), regardless of their return value. Events are merged within a window starting at the
+// first of them, measured with the event timestamps
+type eventsCoalescer struct {
+	window  time.Duration
+	pending *trace.Event
+	repeats int
+}
+
+// add coalesces an event. It returns the previous event once it can't be merged anymore, or nil
+func (c *eventsCoalescer) add(event *trace.Event) *trace.Event {
+	if c.pending != nil && sameEventIdentity(c.pending, event) &&
+		time.Duration(event.Timestamp-c.pending.Timestamp) <= c.window {
+		c.repeats++
+		return nil
+	}
+	completed := c.flush()
+	c.pending = event
+	c.repeats = 1
+	return completed
+}
+
+// flush returns the pending event, with its repeat_count argument if other events were merged into it, or nil
+func (c *eventsCoalescer) flush() *trace.Event {
+	event := c.pending
+	if event == nil {
+		return nil
+	}
+	if c.repeats > 1 {
+		event.Args = append(event.Args, trace.Argument{
+			ArgMeta: trace.ArgMeta{Name: "repeat_count", Type: "int"},
+			Value:   int32(c.repeats),
+		})
+		event.ArgsNum++
+	}
+	c.pending = nil
+	c.repeats = 0
+	return event
+}
+
+func sameEventIdentity(a *trace.Event, b *trace.Event) bool {
+	if a.EventID != b.EventID || a.HostProcessID != b.HostProcessID || a.HostThreadID != b.HostThreadID ||
+		len(a.Args) != len(b.Args) {
+		return false
+	}
+	for i := range a.Args {
+		if a.Args[i].Name != b.Args[i].Name || !reflect.DeepEqual(a.Args[i].Value, b.Args[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// coalesceEvents is the pipeline stage merging consecutive identical events. An event is held until an event
+// which can't be merged into it comes, or for a window at most
+func (t *Tracee) coalesceEvents(ctx context.Context, in <-chan *trace.Event) (<-chan *trace.Event, <-chan error) {
+	out := make(chan *trace.Event, 10000)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errc)
+
+		coalescer := &eventsCoalescer{window: t.config.Output.CoalesceWindow}
+		ticker := t.clock.NewTicker(t.config.Output.CoalesceWindow)
+		defer ticker.Stop()
+
+		send := func(event *trace.Event) bool {
+			if event == nil {
+				return true
+			}
+			select {
+			case out <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for {
+			select {
+			case event, ok := <-in:
+				if !ok {
+					send(coalescer.flush())
+					return
+				}
+				if !send(coalescer.add(event)) {
+					return
+				}
+			case <-ticker.C():
+				// the stream may be idle, so a held event isn't delayed for more than a window
+				if !send(coalescer.flush()) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, errc
+}
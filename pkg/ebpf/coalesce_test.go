@@ -0,0 +1,108 @@
+package ebpf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReadEvent(ts int, tid int, fd int32) *trace.Event {
+	return &trace.Event{
+		Timestamp:     ts,
+		EventID:       int(events.Read),
+		EventName:     "read",
+		HostProcessID: 42,
+		HostThreadID:  tid,
+		ReturnValue:   ts % 512,
+		ArgsNum:       3,
+		Args: []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Name: "fd", Type: "int"}, Value: fd},
+			{ArgMeta: trace.ArgMeta{Name: "buf", Type: "void*"}, Value: uintptr(0x7fff0000)},
+			{ArgMeta: trace.ArgMeta{Name: "count", Type: "size_t"}, Value: uint64(512)},
+		},
+	}
+}
+
+func repeatCount(event *trace.Event) interface{} {
+	arg := events.GetArg(event, "repeat_count")
+	if arg == nil {
+		return nil
+	}
+	return arg.Value
+}
+
+func Test_eventsCoalescer(t *testing.T) {
+	window := time.Millisecond
+
+	t.Run("burst of identical events", func(t *testing.T) {
+		c := &eventsCoalescer{window: window}
+		for i := 0; i < 1000; i++ {
+			assert.Nil(t, c.add(newReadEvent(1000+i, 42, 3)))
+		}
+		coalesced := c.flush()
+		require.NotNil(t, coalesced)
+		assert.Equal(t, 1000, coalesced.Timestamp)
+		assert.Equal(t, int32(1000), repeatCount(coalesced))
+		assert.Equal(t, 4, coalesced.ArgsNum)
+		assert.Nil(t, c.flush())
+	})
+
+	t.Run("reset on a differing event", func(t *testing.T) {
+		c := &eventsCoalescer{window: window}
+		assert.Nil(t, c.add(newReadEvent(1000, 42, 3)))
+		assert.Nil(t, c.add(newReadEvent(1001, 42, 3)))
+
+		// another fd
+		completed := c.add(newReadEvent(1002, 42, 4))
+		require.NotNil(t, completed)
+		assert.Equal(t, int32(2), repeatCount(completed))
+		// another thread
+		completed = c.add(newReadEvent(1003, 43, 4))
+		require.NotNil(t, completed)
+		assert.Equal(t, 1002, completed.Timestamp)
+		assert.Nil(t, repeatCount(completed), "a single event has no repeat count")
+		// another event
+		write := newReadEvent(1004, 43, 4)
+		write.EventID = int(events.Write)
+		completed = c.add(write)
+		require.NotNil(t, completed)
+		assert.Equal(t, 1003, completed.Timestamp)
+
+		assert.Equal(t, write, c.flush())
+	})
+
+	t.Run("events out of the window", func(t *testing.T) {
+		c := &eventsCoalescer{window: window}
+		assert.Nil(t, c.add(newReadEvent(1000, 42, 3)))
+		assert.Nil(t, c.add(newReadEvent(1000+int(window), 42, 3)))
+		completed := c.add(newReadEvent(1001+int(window), 42, 3))
+		require.NotNil(t, completed)
+		assert.Equal(t, int32(2), repeatCount(completed))
+	})
+}
+
+func Test_coalesceEvents(t *testing.T) {
+	trc := newTestTracee(t, Config{Output: &OutputConfig{CoalesceWindow: time.Hour}})
+
+	in := make(chan *trace.Event, 8)
+	for i := 0; i < 5; i++ {
+		in <- newReadEvent(1000+i, 42, 3)
+	}
+	in <- newReadEvent(2000, 42, 4)
+	close(in)
+
+	out, _ := trc.coalesceEvents(context.Background(), in)
+	var coalesced []*trace.Event
+	for event := range out {
+		coalesced = append(coalesced, event)
+	}
+	require.Len(t, coalesced, 2)
+	assert.Equal(t, int32(5), repeatCount(coalesced[0]))
+	// the last event is sent once the input is closed
+	assert.Equal(t, 2000, coalesced[1].Timestamp)
+}
@@ -0,0 +1,241 @@
+package ebpf
+
+import (
+	"archive/tar"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// overlay whiteouts, as they are represented in (OCI image) layer tarballs
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+	// opaqueXattr marks overlay directories whose lower layers are hidden
+	opaqueXattr = "trusted.overlay.opaque"
+)
+
+// ExportContainerChanges exports the files changed by a container, which are the files of the writable (upper) layer
+// of the overlay root filesystem of its mount namespace, to a tarball at dst, e.g. to snapshot a container for
+// incident response once a security event happened in it. Deleted files are exported as whiteouts, as in the layers
+// of container images. Exporting requires Capture.ContainerExport. With Capture.ContainerExportMaxSize, files which
+// would exceed the size of the exported content are skipped, and an error is returned once the tarball is written.
+func (t *Tracee) ExportContainerChanges(mntns uint32, dst string) error {
+	if !t.config.Capture.ContainerExport {
+		return errors.New("exporting container changes is disabled")
+	}
+	upperDir, err := t.containerUpperDir(mntns)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("error creating container export: %v", err)
+	}
+	tw := tar.NewWriter(f)
+	skipped, err := t.exportUpperDir(tw, upperDir, t.config.Capture.ContainerExportMaxSize)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("error exporting changes of mount namespace %d: %v", mntns, err)
+	}
+	if err := tw.Close(); err != nil {
+		f.Close()
+		return fmt.Errorf("error exporting changes of mount namespace %d: %v", mntns, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error exporting changes of mount namespace %d: %v", mntns, err)
+	}
+	if skipped > 0 {
+		return fmt.Errorf("changes of mount namespace %d exceeded the export size limit of %d bytes, %d files were skipped", mntns, t.config.Capture.ContainerExportMaxSize, skipped)
+	}
+	return nil
+}
+
+// containerUpperDir returns the upper directory of the overlay root filesystem of a mount namespace, as mounted
+// by a process in the namespace
+func (t *Tracee) containerUpperDir(mntns uint32) (string, error) {
+	pids := t.pidsInMntns.GetBucket(mntns)
+	if len(pids) == 0 {
+		return "", fmt.Errorf("no process of mount namespace %d is known", mntns)
+	}
+	var err error
+	for _, pid := range pids {
+		var f *os.File
+		f, err = os.Open(fmt.Sprintf("/proc/%d/mountinfo", pid))
+		if err != nil {
+			continue
+		}
+		var upperDir string
+		upperDir, err = parseOverlayUpperDir(f)
+		f.Close()
+		if err == nil {
+			return upperDir, nil
+		}
+	}
+	return "", fmt.Errorf("error finding the overlay root of mount namespace %d: %v", mntns, err)
+}
+
+// parseOverlayUpperDir returns the upper directory of the overlay filesystem mounted as root, as of a mountinfo file
+// (see proc(5))
+func parseOverlayUpperDir(mountinfo io.Reader) (string, error) {
+	upperDir := ""
+	scanner := bufio.NewScanner(mountinfo)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// the optional fields end with a separator, followed by the filesystem type, source and super options
+		separator := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				separator = i
+				break
+			}
+		}
+		if len(fields) < 5 || separator < 0 || separator+3 >= len(fields) {
+			continue
+		}
+		if fields[4] != "/" || fields[separator+1] != "overlay" {
+			continue
+		}
+		// the last root mount is the one seen by the processes
+		upperDir = ""
+		for _, option := range strings.Split(fields[separator+3], ",") {
+			if strings.HasPrefix(option, "upperdir=") {
+				upperDir = unescapeMountinfo(strings.TrimPrefix(option, "upperdir="))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if upperDir == "" {
+		return "", errors.New("the root filesystem isn't an overlay with an upper directory")
+	}
+	return upperDir, nil
+}
+
+// unescapeMountinfo unescapes the octal escapes of white space and backslashes in the paths of mountinfo
+func unescapeMountinfo(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// exportUpperDir writes the files of an overlay upper directory to a tarball, converting the overlay whiteouts to the
+// whiteouts of image layers. Regular files which would exceed the given size of the exported content (if positive)
+// are skipped, returning their number. Files removed while they are exported are skipped as well
+func (t *Tracee) exportUpperDir(tw *tar.Writer, upperDir string, maxSize int64) (int, error) {
+	var size int64
+	skipped := 0
+	err := filepath.Walk(upperDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if path == upperDir {
+			return nil
+		}
+		relPath, err := filepath.Rel(upperDir, path)
+		if err != nil {
+			return err
+		}
+
+		// deleted files are character devices of device number 0
+		if info.Mode()&os.ModeCharDevice != 0 {
+			if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Rdev == 0 {
+				whiteout := filepath.Join(filepath.Dir(relPath), whiteoutPrefix+info.Name())
+				return tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: whiteout, Mode: 0644, ModTime: info.ModTime()})
+			}
+		}
+		// sockets can't be archived, and are of no use once their server is gone
+		if info.Mode()&os.ModeSocket != 0 {
+			return nil
+		}
+		if info.Mode().IsRegular() && maxSize > 0 && size+info.Size() > maxSize {
+			skipped++
+			return nil
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = relPath
+		if info.IsDir() {
+			header.Name += "/"
+		}
+
+		if !info.Mode().IsRegular() {
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			// the lower layers of opaque directories were deleted
+			if info.IsDir() && isOpaqueDir(path) {
+				return tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: filepath.Join(relPath, whiteoutOpaque), Mode: 0644, ModTime: info.ModTime()})
+			}
+			return nil
+		}
+
+		t.openFiles.acquire(1)
+		defer t.openFiles.release(1)
+		f, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		defer f.Close()
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		// the file may be written while it's exported, so it's exported up to its size, or padded if it was truncated
+		n, err := io.Copy(tw, io.LimitReader(f, header.Size))
+		if err != nil {
+			return err
+		}
+		if n < header.Size {
+			if _, err := tw.Write(make([]byte, header.Size-n)); err != nil {
+				return err
+			}
+		}
+		size += header.Size
+		return nil
+	})
+	return skipped, err
+}
+
+// isOpaqueDir checks if an overlay directory is opaque, which can only be told with CAP_SYS_ADMIN
+func isOpaqueDir(path string) bool {
+	value := make([]byte, 1)
+	n, err := unix.Lgetxattr(path, opaqueXattr, value)
+	return err == nil && n == 1 && value[0] == 'y'
+}
@@ -0,0 +1,140 @@
+package ebpf
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func Test_parseOverlayUpperDir(t *testing.T) {
+	testCases := []struct {
+		name          string
+		mountinfo     string
+		expected      string
+		expectedError string
+	}{
+		{
+			name: "container root",
+			mountinfo: `1872 1582 0:211 / / rw,relatime master:629 - overlay overlay rw,lowerdir=/var/lib/docker/overlay2/l/ABC:/var/lib/docker/overlay2/l/DEF,upperdir=/var/lib/docker/overlay2/0f3c/diff,workdir=/var/lib/docker/overlay2/0f3c/work
+1873 1872 0:213 / /proc rw,nosuid,nodev,noexec,relatime - proc proc rw
+1874 1872 0:214 / /dev rw,nosuid - tmpfs tmpfs rw,size=65536k,mode=755`,
+			expected: "/var/lib/docker/overlay2/0f3c/diff",
+		},
+		{
+			name:      "escaped path",
+			mountinfo: `1872 1582 0:211 / / rw,relatime - overlay overlay rw,lowerdir=/lower,upperdir=/var/lib/my\040containers/diff,workdir=/work`,
+			expected:  "/var/lib/my containers/diff",
+		},
+		{
+			name: "overlay mounted elsewhere",
+			mountinfo: `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+1872 22 0:211 / /mnt rw,relatime - overlay overlay rw,lowerdir=/lower,upperdir=/upper,workdir=/work`,
+			expectedError: "the root filesystem isn't an overlay with an upper directory",
+		},
+		{
+			name:          "read only overlay",
+			mountinfo:     `1872 1582 0:211 / / ro,relatime - overlay overlay ro,lowerdir=/lower1:/lower2`,
+			expectedError: "the root filesystem isn't an overlay with an upper directory",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			upperDir, err := parseOverlayUpperDir(strings.NewReader(tc.mountinfo))
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, upperDir)
+		})
+	}
+}
+
+func Test_exportUpperDir(t *testing.T) {
+	// a synthetic upper directory of a container which modified /etc/passwd, dropped a binary and deleted a file
+	upperDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(upperDir, "etc"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(upperDir, "etc", "passwd"), []byte("root:x:0:0::/root:/bin/sh\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(upperDir, "tmp"), 01777))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(upperDir, "tmp", "miner"), []byte(strings.Repeat("x", 1000)), 0755))
+	require.NoError(t, os.Symlink("/tmp/miner", filepath.Join(upperDir, "tmp", "kworker")))
+	whiteouts := unix.Mknod(filepath.Join(upperDir, "etc", "shadow"), unix.S_IFCHR, 0) == nil
+
+	export := func(t *testing.T, maxSize int64) (map[string]*tar.Header, map[string]string, int) {
+		trc := newTestTracee(t, Config{})
+		out := filepath.Join(t.TempDir(), "changes.tar")
+		f, err := os.Create(out)
+		require.NoError(t, err)
+		tw := tar.NewWriter(f)
+		skipped, err := trc.exportUpperDir(tw, upperDir, maxSize)
+		require.NoError(t, err)
+		require.NoError(t, tw.Close())
+		require.NoError(t, f.Close())
+
+		f, err = os.Open(out)
+		require.NoError(t, err)
+		defer f.Close()
+		headers := make(map[string]*tar.Header)
+		contents := make(map[string]string)
+		tr := tar.NewReader(f)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			content, err := ioutil.ReadAll(tr)
+			require.NoError(t, err)
+			headers[header.Name] = header
+			contents[header.Name] = string(content)
+		}
+		return headers, contents, skipped
+	}
+
+	t.Run("changes", func(t *testing.T) {
+		headers, contents, skipped := export(t, 0)
+		assert.Equal(t, 0, skipped)
+
+		require.Contains(t, headers, "etc/")
+		assert.Equal(t, byte(tar.TypeDir), headers["etc/"].Typeflag)
+		assert.Equal(t, "root:x:0:0::/root:/bin/sh\n", contents["etc/passwd"])
+		assert.Equal(t, int64(0755), headers["tmp/miner"].Mode&0777)
+		require.Contains(t, headers, "tmp/kworker")
+		assert.Equal(t, byte(tar.TypeSymlink), headers["tmp/kworker"].Typeflag)
+		assert.Equal(t, "/tmp/miner", headers["tmp/kworker"].Linkname)
+		if whiteouts {
+			// deleted files are exported as the whiteouts of image layers
+			require.Contains(t, headers, "etc/.wh.shadow")
+			assert.Equal(t, byte(tar.TypeReg), headers["etc/.wh.shadow"].Typeflag)
+			assert.NotContains(t, headers, "etc/shadow")
+		}
+	})
+
+	t.Run("size limit", func(t *testing.T) {
+		headers, contents, skipped := export(t, 100)
+		assert.Equal(t, 1, skipped)
+		assert.NotContains(t, headers, "tmp/miner")
+		assert.Equal(t, "root:x:0:0::/root:/bin/sh\n", contents["etc/passwd"])
+		assert.Contains(t, headers, "tmp/kworker")
+	})
+}
+
+func TestExportContainerChanges(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "changes.tar")
+
+	trc := newTestTracee(t, Config{})
+	assert.EqualError(t, trc.ExportContainerChanges(4026532100, dst), "exporting container changes is disabled")
+
+	trc = newTestTracee(t, Config{Capture: &CaptureConfig{ContainerExport: true}})
+	assert.EqualError(t, trc.ExportContainerChanges(4026532100, dst), "no process of mount namespace 4026532100 is known")
+	assert.NoFileExists(t, dst)
+}
@@ -0,0 +1,91 @@
+package ebpf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/pkg/utils"
+)
+
+// dedupStateFile is the file of the output directory persisting the captured files and the cached file hashes
+const dedupStateFile = "dedup_state.json"
+
+// dedupState is the state deduplicating the capture and the hashing of files, as persisted across restarts
+type dedupState struct {
+	// CapturedFiles maps the IDs of captured files to their ctime when captured
+	CapturedFiles map[string]int64 `json:"captured_files"`
+	// FileHashes are the cached file hashes, from the least to the most recently used
+	FileHashes []dedupFileHash `json:"file_hashes"`
+}
+
+type dedupFileHash struct {
+	ID    string `json:"id"`
+	Ctime int64  `json:"ctime"`
+	Hash  string `json:"hash"`
+}
+
+// saveDedupState writes the captured files and the cached file hashes to the output directory. The captured files are
+// copied first, since the pipeline may still be capturing files (e.g. without a drain timeout, or once it timed out)
+func (t *Tracee) saveDedupState() error {
+	t.capturedMu.Lock()
+	capturedFiles := make(map[string]int64, len(t.capturedFiles))
+	for id, ctime := range t.capturedFiles {
+		capturedFiles[id] = ctime
+	}
+	t.capturedMu.Unlock()
+	state := dedupState{CapturedFiles: capturedFiles}
+	for _, key := range t.fileHashes.Keys() {
+		value, ok := t.fileHashes.Peek(key)
+		if !ok {
+			continue
+		}
+		info := value.(fileExecInfo)
+		state.FileHashes = append(state.FileHashes, dedupFileHash{ID: key.(string), Ctime: info.LastCtime, Hash: info.Hash})
+	}
+
+	f, err := utils.CreateAt(t.outDir, dedupStateFile)
+	if err != nil {
+		return fmt.Errorf("error creating dedup state file: %v", err)
+	}
+	defer f.Close()
+	if err := json.NewEncoder(f).Encode(state); err != nil {
+		return fmt.Errorf("error writing dedup state file: %v", err)
+	}
+	return nil
+}
+
+// loadDedupState restores the captured files and the cached file hashes saved by a previous run, if any. Entries of
+// files modified since are kept, since their ctime no longer matches, so the files are captured and hashed again.
+// An unreadable state is discarded with a warning, as it only leads to capturing and hashing files again
+func (t *Tracee) loadDedupState() {
+	f, err := utils.OpenAt(t.outDir, dedupStateFile, os.O_RDONLY, 0)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		t.log(logger.WarnLevel, "can't read the dedup state, starting without it", "error", err)
+		return
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.log(logger.WarnLevel, "can't read the dedup state, starting without it", "error", err)
+		return
+	}
+	var state dedupState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.log(logger.WarnLevel, "can't parse the dedup state, starting without it", "error", fmt.Errorf("invalid dedup state file: %v", err))
+		return
+	}
+
+	for id, ctime := range state.CapturedFiles {
+		t.markCaptured(id, ctime)
+	}
+	for _, h := range state.FileHashes {
+		t.fileHashes.Add(h.ID, fileExecInfo{LastCtime: h.Ctime, Hash: h.Hash})
+	}
+}
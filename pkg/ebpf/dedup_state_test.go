@@ -0,0 +1,111 @@
+package ebpf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupState(t *testing.T) {
+	executed, err := ioutil.TempFile("", "TestDedupState-*")
+	require.NoError(t, err)
+	defer os.Remove(executed.Name())
+	_, err = executed.WriteString("executed before the restart")
+	require.NoError(t, err)
+	require.NoError(t, executed.Close())
+
+	config := Config{
+		Capture: &CaptureConfig{Exec: true, PersistDedup: true},
+		Output:  &OutputConfig{ExecHash: true},
+	}
+	before := newTestTracee(t, config)
+	require.NoError(t, before.processEvent(newExecEvent(t, executed.Name())))
+	require.Equal(t, int32(1), before.stats.CapFileCount.Read())
+	require.NoError(t, before.saveDedupState())
+
+	// a restarted tracee uses the same output directory
+	after := newTestTracee(t, config)
+	after.outDir = before.outDir
+	after.loadDedupState()
+	assert.Equal(t, before.capturedFiles, after.capturedFiles)
+	assert.Equal(t, before.fileHashes.Len(), after.fileHashes.Len())
+
+	event := newExecEvent(t, executed.Name())
+	require.NoError(t, after.processEvent(event))
+	assert.Equal(t, int32(0), after.stats.CapFileCount.Read(), "files captured before the restart shouldn't be captured again")
+	assert.Equal(t, int32(1), after.stats.HashCacheHits.Read())
+	assert.Equal(t, int32(0), after.stats.HashCacheMisses.Read())
+	expectedHash, err := computeFileHashAtPath(executed.Name(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, expectedHash, events.GetArg(event, "sha256").Value)
+
+	// stale entries of files modified since are ignored
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, ioutil.WriteFile(executed.Name(), []byte("modified after the restart"), 0755))
+	require.NoError(t, after.processEvent(newExecEvent(t, executed.Name())))
+	assert.Equal(t, int32(1), after.stats.CapFileCount.Read())
+	assert.Equal(t, int32(1), after.stats.HashCacheMisses.Read())
+}
+
+func TestDedupState_savedWhileCapturing(t *testing.T) {
+	trc := newTestTracee(t, Config{Capture: &CaptureConfig{PersistDedup: true}})
+	started := make(chan struct{})
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for i := 0; ; i++ {
+			if i == 100 {
+				close(started)
+			}
+			select {
+			case <-done:
+				return
+			default:
+			}
+			id := fmt.Sprintf("host:/proc/1/root/tmp/file-%d", i%100)
+			trc.markCaptured(id, int64(i))
+			if i%3 == 0 {
+				trc.forgetCaptured(id)
+			}
+		}
+	}()
+	<-started
+	for i := 0; i < 50; i++ {
+		require.NoError(t, trc.saveDedupState())
+	}
+	close(done)
+	<-stopped
+}
+
+func TestDedupState_invalidState(t *testing.T) {
+	recorder := newRecordingLogger()
+	trc := newTestTracee(t, Config{Logger: recorder.logger})
+	f, err := utils.CreateAt(trc.outDir, dedupStateFile)
+	require.NoError(t, err)
+	_, err = f.WriteString("{truncated")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	trc.loadDedupState()
+	assert.Empty(t, trc.capturedFiles)
+	assert.Equal(t, 0, trc.fileHashes.Len())
+	entries := recorder.logged()
+	require.Len(t, entries, 1)
+	assert.Equal(t, logger.WarnLevel, entries[0].level)
+
+	t.Run("missing state", func(t *testing.T) {
+		recorder := newRecordingLogger()
+		trc := newTestTracee(t, Config{Logger: recorder.logger})
+		trc.loadDedupState()
+		assert.Empty(t, recorder.logged())
+	})
+}
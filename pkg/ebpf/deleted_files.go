@@ -0,0 +1,56 @@
+package ebpf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// deletedSuffix is appended by the kernel to the paths of unlinked files
+const deletedSuffix = " (deleted)"
+
+// execSourcePath returns the path to read an executed file from, given its path under the root of a process.
+// The path of an unlinked file ends with " (deleted)", so it can't be read from the root fs. Instead, it is read
+// through the references the executing process still holds to it: its executable link (/proc/PID/exe), its memory
+// mappings (/proc/PID/map_files) or its open files (/proc/PID/fd). If the process holds no reference to the file,
+// an error is returned, as the file at its path without the suffix (if any) is a different file
+func execSourcePath(sourcePath string, filePath string, hostPid int) (string, error) {
+	if !strings.HasSuffix(filePath, deletedSuffix) {
+		return sourcePath, nil
+	}
+	// the file may actually be named with the suffix
+	if _, err := os.Stat(sourcePath); err == nil {
+		return sourcePath, nil
+	}
+
+	procDir := fmt.Sprintf("/proc/%d", hostPid)
+	if linkTargets(filepath.Join(procDir, "exe"), filePath) {
+		return filepath.Join(procDir, "exe"), nil
+	}
+	for _, dir := range []string{"map_files", "fd"} {
+		links, err := ioutil.ReadDir(filepath.Join(procDir, dir))
+		if err != nil {
+			continue
+		}
+		for _, link := range links {
+			linkPath := filepath.Join(procDir, dir, link.Name())
+			if linkTargets(linkPath, filePath) {
+				return linkPath, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("deleted file %s is no longer referenced by process %d", filePath, hostPid)
+}
+
+// linkTargets tells if a /proc link targets the given path. The link target is resolved relative to the root of
+// the reading process, which may differ from the root of the linked process (e.g. in containers)
+func linkTargets(linkPath string, filePath string) bool {
+	target, err := os.Readlink(linkPath)
+	if err != nil {
+		return false
+	}
+	return strings.HasSuffix(target, filePath)
+}
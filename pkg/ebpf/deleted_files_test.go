@@ -0,0 +1,124 @@
+package ebpf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDeletedFile creates a file with the given content, which is unlinked while this process keeps it open
+func newDeletedFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "Test_deletedFile-*")
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(f.Name()))
+	return f.Name()
+}
+
+func Test_execSourcePath(t *testing.T) {
+	persistent, err := ioutil.TempFile("", "Test_execSourcePath-*")
+	require.NoError(t, err)
+	defer os.Remove(persistent.Name())
+	require.NoError(t, persistent.Close())
+
+	deleted := newDeletedFile(t, "deleted content")
+
+	unreferenced, err := ioutil.TempFile("", "Test_execSourcePath-*")
+	require.NoError(t, err)
+	require.NoError(t, unreferenced.Close())
+	require.NoError(t, os.Remove(unreferenced.Name()))
+
+	pid := os.Getpid()
+	rootPath := func(filePath string) string {
+		return fmt.Sprintf("/proc/%d/root%s", pid, filePath)
+	}
+
+	t.Run("existing file", func(t *testing.T) {
+		readPath, err := execSourcePath(rootPath(persistent.Name()), persistent.Name(), pid)
+		require.NoError(t, err)
+		assert.Equal(t, rootPath(persistent.Name()), readPath)
+	})
+
+	t.Run("deleted file read through its reference", func(t *testing.T) {
+		readPath, err := execSourcePath(rootPath(deleted+deletedSuffix), deleted+deletedSuffix, pid)
+		require.NoError(t, err)
+		assert.Contains(t, readPath, fmt.Sprintf("/proc/%d/", pid))
+		content, err := ioutil.ReadFile(readPath)
+		require.NoError(t, err)
+		assert.Equal(t, "deleted content", string(content))
+	})
+
+	t.Run("deleted file without references", func(t *testing.T) {
+		// a file replaced at its path isn't read instead
+		require.NoError(t, ioutil.WriteFile(unreferenced.Name(), []byte("replacement"), 0644))
+		defer os.Remove(unreferenced.Name())
+		filePath := unreferenced.Name() + deletedSuffix
+		_, err := execSourcePath(rootPath(filePath), filePath, pid)
+		assert.Error(t, err)
+	})
+}
+
+func Test_processEvent_execDeletedFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "Test_processEvent_execDeletedFile-*")
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteString("deleted executable")
+	require.NoError(t, err)
+
+	expectedHash, err := computeFileHashAtPath(f.Name(), 0)
+	require.NoError(t, err)
+
+	trc := newTestTracee(t, Config{
+		Capture: &CaptureConfig{Exec: true},
+		Output:  &OutputConfig{ExecHash: true},
+	})
+
+	event := newExecEvent(t, f.Name())
+	event.Timestamp = 1
+	require.NoError(t, os.Remove(f.Name()))
+	event.Args[0].Value = f.Name() + deletedSuffix
+
+	require.NoError(t, trc.processEvent(event))
+
+	arg := events.GetArg(event, "sha256")
+	require.NotNil(t, arg)
+	assert.Equal(t, expectedHash, arg.Value)
+
+	captured, err := ioutil.ReadFile(filepath.Join(trc.outDir.Name(), "host", "exec.1."+filepath.Base(f.Name())))
+	require.NoError(t, err)
+	assert.Equal(t, "deleted executable", string(captured))
+}
+
+func Test_processEvent_execUnreferencedDeletedFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "Test_processEvent_execUnreferencedDeletedFile-*")
+	require.NoError(t, err)
+	_, err = f.WriteString("deleted executable")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	trc := newTestTracee(t, Config{
+		Capture: &CaptureConfig{Exec: true},
+		Output:  &OutputConfig{ExecHash: true},
+	})
+
+	// the deleted file is replaced at its path by another file
+	event := newExecEvent(t, f.Name())
+	event.Timestamp = 1
+	event.Args[0].Value = f.Name() + deletedSuffix
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte("replacement"), 0644))
+	defer os.Remove(f.Name())
+
+	require.NoError(t, trc.processEvent(event))
+
+	assert.Nil(t, events.GetArg(event, "sha256"))
+	_, err = os.Stat(filepath.Join(trc.outDir.Name(), "host", "exec.1."+filepath.Base(f.Name())))
+	assert.True(t, os.IsNotExist(err))
+}
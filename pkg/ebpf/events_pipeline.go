@@ -7,11 +7,14 @@ import (
 	"fmt"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/aquasecurity/tracee/pkg/bufferdecoder"
+	"github.com/aquasecurity/tracee/pkg/containers"
 	"github.com/aquasecurity/tracee/pkg/events"
 	"github.com/aquasecurity/tracee/pkg/events/derive"
+	"github.com/aquasecurity/tracee/pkg/logger"
 	"github.com/aquasecurity/tracee/types/trace"
 )
 
@@ -42,6 +45,13 @@ func (t *Tracee) handleEvents(ctx context.Context) {
 	eventsChan, errc = t.processEvents(ctx, eventsChan)
 	errcList = append(errcList, errc)
 
+	// Coalesce events stage
+	// In this stage consecutive identical events (e.g. of a process reading in a loop) are merged
+	if t.config.Output.CoalesceWindow > 0 {
+		eventsChan, errc = t.coalesceEvents(ctx, eventsChan)
+		errcList = append(errcList, errc)
+	}
+
 	// Enrichment stage
 	// In this stage container events are enriched with additional runtime data
 	// Events may be enriched in the initial decode state if the enrichment data has been stored in the Containers structure
@@ -137,88 +147,19 @@ func (t *Tracee) decodeEvents(outerCtx context.Context) (<-chan *trace.Event, <-
 		defer close(out)
 		defer close(errc)
 		for dataRaw := range t.eventsChannel {
-			ebpfMsgDecoder := bufferdecoder.New(dataRaw)
-			var ctx bufferdecoder.Context
-			if err := ebpfMsgDecoder.DecodeContext(&ctx); err != nil {
-				t.handleError(err)
-				continue
-			}
-			eventId := events.ID(ctx.EventID)
-			eventDefinition, ok := events.Definitions.GetSafe(eventId)
-			if !ok {
-				t.handleError(fmt.Errorf("failed to get configuration of event %d", eventId))
-				continue
-			}
-
-			args := make([]trace.Argument, 0, ctx.Argnum)
-
-			for i := 0; i < int(ctx.Argnum); i++ {
-				argMeta, argVal, err := bufferdecoder.ReadArgFromBuff(ebpfMsgDecoder, eventDefinition.Params)
-				if err != nil {
-					t.handleError(fmt.Errorf("failed to read argument %d of event %s: %v", i, eventDefinition.Name, err))
-					continue
+			if t.rawEventsRecorder != nil {
+				if err := t.rawEventsRecorder.write(dataRaw); err != nil {
+					t.handleError(err)
 				}
-
-				args = append(args, trace.Argument{ArgMeta: argMeta, Value: argVal})
 			}
 
-			if !t.shouldProcessEvent(&ctx, args) {
-				t.stats.EventsFiltered.Increment()
+			evt := t.decodeEvent(dataRaw)
+			if evt == nil {
 				continue
 			}
 
-			// Add stack trace if needed
-			var StackAddresses []uint64
-			if t.config.Output.StackAddresses {
-				StackAddresses, _ = t.getStackAddresses(ctx.StackID)
-			}
-
-			// Currently, the timestamp received from the bpf code is of the monotonic clock.
-			// Todo: The monotonic clock doesn't take into account system sleep time.
-			// Starting from kernel 5.7, we can get the timestamp relative to the system boot time instead which is preferable.
-			if t.config.Output.RelativeTime {
-				// To get the monotonic time since tracee was started, we have to subtract the start time from the timestamp.
-				ctx.Ts -= t.startTime
-			} else {
-				// To get the current ("wall") time, we add the boot time into it.
-				ctx.Ts += t.bootTime
-			}
-
-			containerInfo := t.containers.GetCgroupInfo(ctx.CgroupID).Container
-
-			evt := trace.Event{
-				Timestamp:           int(ctx.Ts),
-				ThreadStartTime:     int(ctx.StartTime),
-				ProcessorID:         int(ctx.ProcessorId),
-				ProcessID:           int(ctx.Pid),
-				ThreadID:            int(ctx.Tid),
-				ParentProcessID:     int(ctx.Ppid),
-				HostProcessID:       int(ctx.HostPid),
-				HostThreadID:        int(ctx.HostTid),
-				HostParentProcessID: int(ctx.HostPpid),
-				UserID:              int(ctx.Uid),
-				MountNS:             int(ctx.MntID),
-				PIDNS:               int(ctx.PidID),
-				ProcessName:         string(bytes.TrimRight(ctx.Comm[:], "\x00")),
-				HostName:            string(bytes.TrimRight(ctx.UtsName[:], "\x00")),
-				CgroupID:            uint(ctx.CgroupID),
-				ContainerID:         containerInfo.ContainerId,
-				ContainerImage:      containerInfo.Image,
-				ContainerName:       containerInfo.Name,
-				PodName:             containerInfo.Pod.Name,
-				PodNamespace:        containerInfo.Pod.Namespace,
-				PodUID:              containerInfo.Pod.UID,
-				EventID:             int(ctx.EventID),
-				EventName:           eventDefinition.Name,
-				ArgsNum:             int(ctx.Argnum),
-				ReturnValue:         int(ctx.Retval),
-				Args:                args,
-				StackAddresses:      StackAddresses,
-				ContextFlags:        parseContextFlags(ctx.Flags),
-			}
-
 			select {
-			case out <- &evt:
+			case out <- evt:
 			case <-outerCtx.Done():
 				return
 			}
@@ -227,6 +168,85 @@ func (t *Tracee) decodeEvents(outerCtx context.Context) (<-chan *trace.Event, <-
 	return out, errc
 }
 
+// decodeEvent parses a raw event received from the BPF programs into trace.Event type.
+// It returns nil if the event couldn't be decoded or was filtered out
+func (t *Tracee) decodeEvent(dataRaw []byte) *trace.Event {
+	ebpfMsgDecoder := bufferdecoder.New(dataRaw)
+	var ctx bufferdecoder.Context
+	if err := ebpfMsgDecoder.DecodeContext(&ctx); err != nil {
+		t.handleError(err)
+		return nil
+	}
+	eventId := events.ID(ctx.EventID)
+	eventDefinition, ok := events.Definitions.GetSafe(eventId)
+	if !ok {
+		t.handleError(fmt.Errorf("failed to get configuration of event %d", eventId), "event_id", int32(eventId))
+		return nil
+	}
+
+	args := make([]trace.Argument, 0, ctx.Argnum)
+
+	for i := 0; i < int(ctx.Argnum); i++ {
+		argMeta, argVal, err := bufferdecoder.ReadArgFromBuff(ebpfMsgDecoder, eventDefinition.Params)
+		if err != nil {
+			t.handleError(fmt.Errorf("failed to read argument %d of event %s: %v", i, eventDefinition.Name, err), "event", eventDefinition.Name, "arg", i)
+			continue
+		}
+
+		args = append(args, trace.Argument{ArgMeta: argMeta, Value: argVal})
+	}
+
+	if !t.shouldProcessEvent(&ctx, args) {
+		t.stats.EventsFiltered.Increment()
+		return nil
+	}
+
+	// Add stack trace if needed
+	var StackAddresses []uint64
+	if t.config.Output.StackAddresses && t.StackAddressesMap != nil {
+		StackAddresses, _ = t.getStackAddresses(ctx.StackID)
+	}
+
+	ctx.Ts = t.eventTimestamp(ctx.Ts)
+
+	var cgroupInfo containers.CgroupInfo
+	if t.containers != nil {
+		cgroupInfo = t.containers.GetCgroupInfo(ctx.CgroupID)
+	}
+	containerInfo := cgroupInfo.Container
+
+	return &trace.Event{
+		Timestamp:           int(ctx.Ts),
+		ThreadStartTime:     int(ctx.StartTime),
+		ProcessorID:         int(ctx.ProcessorId),
+		ProcessID:           int(ctx.Pid),
+		ThreadID:            int(ctx.Tid),
+		ParentProcessID:     int(ctx.Ppid),
+		HostProcessID:       int(ctx.HostPid),
+		HostThreadID:        int(ctx.HostTid),
+		HostParentProcessID: int(ctx.HostPpid),
+		UserID:              int(ctx.Uid),
+		MountNS:             int(ctx.MntID),
+		PIDNS:               int(ctx.PidID),
+		ProcessName:         string(bytes.TrimRight(ctx.Comm[:], "\x00")),
+		HostName:            string(bytes.TrimRight(ctx.UtsName[:], "\x00")),
+		CgroupID:            uint(ctx.CgroupID),
+		ContainerID:         containerInfo.ContainerId,
+		ContainerImage:      containerInfo.Image,
+		ContainerName:       containerInfo.Name,
+		PodName:             containerInfo.Pod.Name,
+		PodNamespace:        containerInfo.Pod.Namespace,
+		PodUID:              containerInfo.Pod.UID,
+		EventID:             int(ctx.EventID),
+		EventName:           eventDefinition.Name,
+		ArgsNum:             int(ctx.Argnum),
+		ReturnValue:         int(ctx.Retval),
+		Args:                args,
+		StackAddresses:      StackAddresses,
+		ContextFlags:        parseContextFlags(ctx.Flags),
+	}
+}
+
 func parseContextFlags(flags uint32) trace.ContextFlags {
 	const (
 		ContainerStartFlag = 1 << iota
@@ -243,25 +263,10 @@ func (t *Tracee) processEvents(ctx context.Context, in <-chan *trace.Event) (<-c
 		defer close(out)
 		defer close(errc)
 		for event := range in {
-			err := t.processEvent(event)
-			if err != nil {
-				t.handleError(err)
+			if !t.processDecodedEvent(event) {
 				continue
 			}
 
-			if (t.config.Filter.ContFilter.Value || t.config.Filter.NewContFilter.Enabled) && event.ContainerID == "" {
-				// Don't trace false container positives -
-				// a container filter is set by the user, but this event wasn't originated in a container.
-				// Although kernel filters shouldn't submit such events, we do this check to be on the safe side.
-				// For example, it might be that a new cgroup was created, and not by a container runtime,
-				// while we still didn't processed the cgroup_mkdir event and removed the cgroupid from the bpf container map.
-				id := events.ID(event.EventID)
-				// don't skip cgroup_mkdir and cgroup_rmdir so we can derive container_create and container_remove events
-				if id != events.CgroupMkdir && id != events.CgroupRmdir {
-					continue
-				}
-			}
-
 			select {
 			case out <- event:
 			case <-ctx.Done():
@@ -272,6 +277,45 @@ func (t *Tracee) processEvents(ctx context.Context, in <-chan *trace.Event) (<-c
 	return out, errc
 }
 
+// processDecodedEvent performs the event specific logic on a decoded event.
+// It returns false if the event should be dropped
+func (t *Tracee) processDecodedEvent(event *trace.Event) bool {
+	captured, err := t.processEventCaptured(event)
+	if err != nil {
+		t.handleError(err, "event", event.EventName)
+		return false
+	}
+	if t.config.Output.CapturedOnly && !captured {
+		t.stats.EventsFiltered.Increment()
+		return false
+	}
+
+	if !t.shouldProcessEnrichedEvent(event) {
+		t.stats.EventsFiltered.Increment()
+		return false
+	}
+
+	t.config.ArgTransforms.Apply(event)
+	if t.config.Output.MaxArgLength > 0 {
+		truncateStringArgs(event, t.config.Output.MaxArgLength)
+	}
+
+	if (t.config.Filter.ContFilter.Value || t.config.Filter.NewContFilter.Enabled) && event.ContainerID == "" {
+		// Don't trace false container positives -
+		// a container filter is set by the user, but this event wasn't originated in a container.
+		// Although kernel filters shouldn't submit such events, we do this check to be on the safe side.
+		// For example, it might be that a new cgroup was created, and not by a container runtime,
+		// while we still didn't processed the cgroup_mkdir event and removed the cgroupid from the bpf container map.
+		id := events.ID(event.EventID)
+		// don't skip cgroup_mkdir and cgroup_rmdir so we can derive container_create and container_remove events
+		if id != events.CgroupMkdir && id != events.CgroupRmdir {
+			return false
+		}
+	}
+
+	return true
+}
+
 // deriveEvents is the derivation pipeline stage
 func (t *Tracee) deriveEvents(ctx context.Context, in <-chan *trace.Event) (<-chan *trace.Event, <-chan error) {
 	out := make(chan *trace.Event)
@@ -287,13 +331,8 @@ func (t *Tracee) deriveEvents(ctx context.Context, in <-chan *trace.Event) (<-ch
 				out <- event
 
 				// Derive event before parse its arguments
-				derivatives, errors := derive.DeriveEvent(*event, t.eventDerivations)
-
-				for _, err := range errors {
-					t.handleError(err)
-				}
-
-				for _, derivative := range derivatives {
+				for _, derivative := range t.deriveEvent(event) {
+					derivative := derivative
 					out <- &derivative
 				}
 
@@ -306,36 +345,70 @@ func (t *Tracee) deriveEvents(ctx context.Context, in <-chan *trace.Event) (<-ch
 	return out, errc
 }
 
+// deriveEvent returns the events derived from the given event. Derived events repeating a condition already
+// reported for their process within the dedup window are dropped
+func (t *Tracee) deriveEvent(event *trace.Event) []trace.Event {
+	if t.config.Output.Minimal {
+		return nil
+	}
+	derivatives, errors := derive.DeriveEvent(*event, t.eventDerivations)
+	for _, err := range errors {
+		t.handleError(err)
+	}
+	if t.derivedDedup == nil {
+		return derivatives
+	}
+
+	emitted := derivatives[:0]
+	for _, derivative := range derivatives {
+		if !t.derivedDedup.Emit(derivative) {
+			t.stats.EventsFiltered.Increment()
+			continue
+		}
+		emitted = append(emitted, derivative)
+	}
+	return emitted
+}
+
+// maxEventsReached tells if Output.MaxEvents events were emitted
+func (t *Tracee) maxEventsReached() bool {
+	return t.config.Output.MaxEvents > 0 && int(atomic.LoadInt32(&t.emittedEvents)) >= t.config.Output.MaxEvents
+}
+
+// countEmitted counts an event sent to the output against Output.MaxEvents, whether it was emitted by the pipeline
+// or outside of it, and stops the run once the limit is reached. It returns false once no more events are emitted
+func (t *Tracee) countEmitted() bool {
+	emitted := atomic.AddInt32(&t.emittedEvents, 1)
+	if t.config.Output.MaxEvents > 0 && int(emitted) >= t.config.Output.MaxEvents {
+		// the emitted events were already sent, so shutting down doesn't lose any of them
+		t.stopRun()
+		return false
+	}
+	return true
+}
+
 func (t *Tracee) sinkEvents(ctx context.Context, in <-chan *trace.Event) <-chan error {
 	errc := make(chan error, 1)
 
 	go func() {
 		defer close(errc)
 		for event := range in {
-			// Only emit events requested by the user
-			id := events.ID(event.EventID)
-			if t.events[id].emit {
-				if t.config.Output.ParseArguments {
-					err := events.ParseArgs(event)
-					if err != nil {
-						t.handleError(err)
-						continue
-					}
-					if t.config.Output.ParseArgumentsFDs {
-						err := events.ParseArgsFDs(event, t.FDArgPathMap)
-						if err != nil {
-							t.handleError(err)
-							continue
-						}
-					}
-				}
-				select {
-				case t.config.ChanEvents <- *event:
-					t.stats.EventCount.Increment()
-					event = nil
-				case <-ctx.Done():
-					return
-				}
+			if !t.prepareEmittedEvent(event) {
+				continue
+			}
+			if t.maxEventsReached() {
+				return
+			}
+			select {
+			case t.config.ChanEvents <- *event:
+				t.stats.EventCount.Increment()
+				event = nil
+			case <-ctx.Done():
+				return
+			}
+
+			if !t.countEmitted() {
+				return
 			}
 		}
 	}()
@@ -343,6 +416,47 @@ func (t *Tracee) sinkEvents(ctx context.Context, in <-chan *trace.Event) <-chan
 	return errc
 }
 
+// prepareEmittedEvent parses the arguments of an event about to be sent to the output, adding its raw timestamp with
+// Output.BootTimestamp, the cgroup path of its process with Output.CgroupPath and the name of its mount namespace with
+// Output.NamespaceNames, and pruning them with Output.IncludeArgs and Output.ExcludeArgs.
+// It returns false if the event shouldn't be emitted
+func (t *Tracee) prepareEmittedEvent(event *trace.Event) bool {
+	// Only emit events requested by the user
+	id := events.ID(event.EventID)
+	if !t.events[id].emit {
+		return false
+	}
+	if t.config.Output.BootTimestamp {
+		t.addBootTimestamp(event)
+	}
+	if t.config.Output.CgroupPath && !t.config.Output.Minimal {
+		t.addCgroupPath(event)
+	}
+	if len(t.config.Output.NamespaceNames) > 0 && !t.config.Output.Minimal {
+		t.addNamespaceName(event)
+	}
+	events.SelectArgs(event, t.config.Output.IncludeArgs, t.config.Output.ExcludeArgs)
+	if t.config.Output.ParseArguments {
+		parseArgs := events.ParseArgs
+		if t.config.Output.IncludeRaw {
+			parseArgs = events.ParseArgsIncludeRaw
+		}
+		err := parseArgs(event)
+		if err != nil {
+			t.handleError(err, "event", event.EventName)
+			return false
+		}
+		if t.config.Output.ParseArgumentsFDs {
+			err := events.ParseArgsFDs(event, t.FDArgPathMap)
+			if err != nil {
+				t.handleError(err, "event", event.EventName)
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func (t *Tracee) getStackAddresses(StackID uint32) ([]uint64, error) {
 	StackAddresses := make([]uint64, maxStackDepth)
 	stackFrameSize := (strconv.IntSize / 8)
@@ -352,6 +466,7 @@ func (t *Tracee) getStackAddresses(StackID uint32) ([]uint64, error) {
 	// Stack IDs in it's Map
 	stackBytes, err := t.StackAddressesMap.GetValue(unsafe.Pointer(&StackID))
 	if err != nil {
+		t.log(logger.DebugLevel, "stack trace not found in map", "stack_id", StackID)
 		return StackAddresses[0:0], nil
 	}
 
@@ -413,7 +528,8 @@ func MergeErrors(cs ...<-chan error) <-chan error {
 	return out
 }
 
-func (t *Tracee) handleError(err error) {
+// handleError reports an error, with optional key-value pairs of fields describing it (see Logger)
+func (t *Tracee) handleError(err error, keysAndValues ...interface{}) {
 	t.stats.ErrorCount.Increment()
-	t.config.ChanErrors <- err
+	t.log(logger.ErrorLevel, err.Error(), append([]interface{}{"error", err}, keysAndValues...)...)
 }
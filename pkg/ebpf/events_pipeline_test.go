@@ -0,0 +1,198 @@
+package ebpf
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/events/derive"
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_handleEvents_maxEvents(t *testing.T) {
+	trc := newReplayTestTracee(t)
+	trc.config.Output.MaxEvents = 3
+
+	rawEvents := [][]byte{
+		newRawOpenatEvent(t, 1000, 42, "/etc/passwd", 0),
+		// filtered events don't count
+		newRawOpenatEvent(t, 2000, 42, "/etc/shadow", 0),
+	}
+	for i := 0; i < 4; i++ {
+		rawEvents = append(rawEvents, newRawOpenatEvent(t, uint64(3000+i), 43, fmt.Sprintf("/tmp/file-%d", i), 0))
+	}
+	trc.eventsChannel = make(chan []byte, len(rawEvents))
+	for _, dataRaw := range rawEvents {
+		trc.eventsChannel <- dataRaw
+	}
+	close(trc.eventsChannel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	trc.stopRun = cancel
+	go trc.handleEvents(ctx)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the run wasn't stopped after emitting the maximal number of events")
+	}
+
+	emitted := collectEvents(trc.config.ChanEvents)
+	require.Len(t, emitted, 3)
+	assert.Equal(t, "/etc/passwd", emitted[0].Args[0].Value)
+	assert.Equal(t, "/tmp/file-0", emitted[1].Args[0].Value)
+	assert.Equal(t, "/tmp/file-1", emitted[2].Args[0].Value)
+	assert.Equal(t, int32(3), trc.stats.EventCount.Read())
+}
+
+func Test_processDecodedEvent_capturedOnly(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "tool")
+	require.NoError(t, ioutil.WriteFile(exe, []byte("binary"), 0755))
+	newWriteEvent := func(pathname string, inode uint64) *trace.Event {
+		return &trace.Event{
+			EventID:   int(events.VfsWrite),
+			EventName: "vfs_write",
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: pathname},
+				{ArgMeta: trace.ArgMeta{Name: "dev", Type: "dev_t"}, Value: uint32(1)},
+				{ArgMeta: trace.ArgMeta{Name: "inode", Type: "unsigned long"}, Value: inode},
+			},
+		}
+	}
+	openEvent := &trace.Event{
+		EventID:   int(events.Openat),
+		EventName: "openat",
+		Args:      []trace.Argument{{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: exe}},
+	}
+
+	trc := newTestTracee(t, Config{
+		Capture: &CaptureConfig{Exec: true, FileWrite: true, FilterFileWrite: []string{"/tmp"}},
+		Output:  &OutputConfig{CapturedOnly: true},
+	})
+	// the exec copying the file is emitted, and not the execs of the captured file or the other events
+	assert.True(t, trc.processDecodedEvent(newExecEvent(t, exe)))
+	assert.False(t, trc.processDecodedEvent(newExecEvent(t, exe)))
+	assert.False(t, trc.processDecodedEvent(openEvent))
+	// the first write of a file indexes its capture, unless its data isn't captured
+	assert.True(t, trc.processDecodedEvent(newWriteEvent("/tmp/out", 2)))
+	assert.False(t, trc.processDecodedEvent(newWriteEvent("/tmp/out", 2)))
+	assert.False(t, trc.processDecodedEvent(newWriteEvent("/var/log/out", 3)))
+	assert.Equal(t, int32(4), trc.stats.EventsFiltered.Read())
+
+	trc.config.Output.CapturedOnly = false
+	assert.True(t, trc.processDecodedEvent(newExecEvent(t, exe)))
+	assert.True(t, trc.processDecodedEvent(openEvent))
+}
+
+func Test_prepareEmittedEvent_bootTimestamp(t *testing.T) {
+	const bootTs = 5 * uint64(time.Second)
+	trc := newReplayTestTracee(t)
+	trc.clock = utils.NewFakeClock(time.Unix(1661500000, 0), int64(bootTs))
+	trc.initTimestamps()
+	emit := func() *trace.Event {
+		event := trc.decodeEvent(newRawOpenatEvent(t, bootTs+1000, 42, "/etc/passwd", 0))
+		require.NotNil(t, event)
+		require.True(t, trc.prepareEmittedEvent(event))
+		return event
+	}
+
+	// the raw timestamp isn't emitted unless requested
+	event := emit()
+	assert.Equal(t, time.Unix(1661500000, 1000).UnixNano(), int64(event.Timestamp))
+	assert.Nil(t, events.GetArg(event, "timestamp_ns_boot"))
+
+	// the raw timestamp is emitted along the wall clock timestamp
+	trc.config.Output.BootTimestamp = true
+	event = emit()
+	assert.Equal(t, time.Unix(1661500000, 1000).UnixNano(), int64(event.Timestamp))
+	require.NotNil(t, events.GetArg(event, "timestamp_ns_boot"))
+	assert.Equal(t, bootTs+1000, events.GetArg(event, "timestamp_ns_boot").Value)
+	assert.Equal(t, 3, event.ArgsNum)
+
+	// and along the relative timestamp
+	trc.config.Output.RelativeTime = true
+	event = emit()
+	assert.Equal(t, 1000, event.Timestamp)
+	assert.Equal(t, bootTs+1000, events.GetArg(event, "timestamp_ns_boot").Value)
+}
+
+func Test_prepareEmittedEvent_namespaceName(t *testing.T) {
+	trc := newReplayTestTracee(t)
+	emit := func() *trace.Event {
+		event := trc.decodeEvent(newRawOpenatEvent(t, 1000, 42, "/etc/passwd", 0))
+		require.NotNil(t, event)
+		require.True(t, trc.prepareEmittedEvent(event))
+		return event
+	}
+
+	// namespaces aren't named unless names are given
+	event := emit()
+	assert.Nil(t, events.GetArg(event, "namespace_name"))
+
+	// events of an unmapped namespace are named after their raw mntns
+	trc.config.Output.NamespaceNames = map[uint32]string{2: "web-frontend"}
+	event = emit()
+	require.NotNil(t, events.GetArg(event, "namespace_name"))
+	assert.Equal(t, "1", events.GetArg(event, "namespace_name").Value)
+	assert.Equal(t, 3, event.ArgsNum)
+
+	// and events of a mapped namespace after its name
+	trc.config.Output.NamespaceNames[1] = "db"
+	event = emit()
+	assert.Equal(t, "db", events.GetArg(event, "namespace_name").Value)
+
+	trc.config.Output.Minimal = true
+	event = emit()
+	assert.Nil(t, events.GetArg(event, "namespace_name"))
+}
+
+func Test_deriveEvent_dedup(t *testing.T) {
+	trc := newTestTracee(t, Config{Output: &OutputConfig{DerivedDedupWindow: time.Second}})
+	trc.derivedDedup = derive.NewDedup(trc.config.Output.DerivedDedupWindow)
+	trc.eventDerivations = derive.Table{
+		events.Mprotect: {
+			events.WXMemoryMapping: {DeriveFunction: derive.WXMemoryMapping(), Enabled: true},
+		},
+	}
+	mprotect := func(ts int, hostPid int) *trace.Event {
+		return &trace.Event{
+			Timestamp:     ts,
+			EventID:       int(events.Mprotect),
+			EventName:     "mprotect",
+			HostProcessID: hostPid,
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "addr", Type: "void*"}, Value: uintptr(ts)},
+				{ArgMeta: trace.ArgMeta{Name: "len", Type: "size_t"}, Value: uint64(4096)},
+				{ArgMeta: trace.ArgMeta{Name: "prot", Type: "int"}, Value: int32(0x7)},
+			},
+		}
+	}
+
+	// a process making mappings writable and executable in a loop is reported once per window
+	var derived []trace.Event
+	for i := 0; i < 10; i++ {
+		derived = append(derived, trc.deriveEvent(mprotect(1000+i, 42))...)
+	}
+	derived = append(derived, trc.deriveEvent(mprotect(2000, 43))...)
+	derived = append(derived, trc.deriveEvent(mprotect(1000+int(time.Second)+1, 42))...)
+
+	require.Len(t, derived, 3)
+	assert.Equal(t, 1000, derived[0].Timestamp)
+	assert.Equal(t, 43, derived[1].HostProcessID)
+	assert.Equal(t, 1000+int(time.Second)+1, derived[2].Timestamp)
+	assert.Equal(t, int32(9), trc.stats.EventsFiltered.Read())
+
+	t.Run("disabled", func(t *testing.T) {
+		trc.derivedDedup = nil
+		assert.Len(t, trc.deriveEvent(mprotect(1000+int(time.Second)+2, 42)), 1)
+	})
+}
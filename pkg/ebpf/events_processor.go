@@ -1,13 +1,17 @@
 package ebpf
 
 import (
+	"bytes"
 	"fmt"
-	"path/filepath"
+	"io/ioutil"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/aquasecurity/tracee/pkg/filters"
+	"github.com/aquasecurity/tracee/pkg/logger"
 	"github.com/aquasecurity/tracee/pkg/utils"
 
 	"github.com/aquasecurity/tracee/pkg/bufferdecoder"
@@ -38,49 +42,80 @@ func MatchFilter(filters []string, argValStr string) bool {
 }
 
 func (t *Tracee) processLostEvents() {
-	for {
-		lost := <-t.lostEvChannel
+	// the channel is closed once the perf buffer is stopped
+	for lost := range t.lostEvChannel {
 		// When terminating tracee-ebpf the lost channel receives multiple "0 lost events" events.
 		// This check prevents those 0 lost events messages to be written to stderr until the bug is fixed:
 		// https://github.com/aquasecurity/libbpfgo/issues/122
 		if lost > 0 {
 			t.stats.LostEvCount.Increment(int(lost))
-			t.config.ChanErrors <- fmt.Errorf("lost %d events", lost)
+			t.log(logger.WarnLevel, fmt.Sprintf("lost %d events", lost), "count", lost, "buffer", "events")
 		}
 	}
 }
 
 // shouldProcessEvent decides whether or not to drop an event before further processing it
 func (t *Tracee) shouldProcessEvent(ctx *bufferdecoder.Context, args []trace.Argument) bool {
-	if t.config.Filter.RetFilter.Enabled {
-		if filter, ok := t.config.Filter.RetFilter.Filters[ctx.EventID]; ok {
+	filter := t.filters()
+
+	// exited processes are forgotten by the first event filter even if their exit is filtered out
+	if ctx.EventID == events.SchedProcessExit {
+		for _, arg := range args {
+			if arg.Name == "process_group_exit" && arg.Value == true {
+				defer filter.FirstEventFilter.ProcessExited(ctx.HostPid)
+			}
+		}
+	}
+
+	// the uid is filtered by the bpf code, so it's filtered again only for replayed events, which weren't. The bpf code
+	// follows the descendants of matching processes even if their uid changed, so they are kept as well
+	if t.replaying && !filter.Follow && !filter.UIDFilter.Filter(uint64(ctx.Uid)) {
+		return false
+	}
+	if !filter.GIDFilter.Filter(uint64(ctx.Gid)) {
+		return false
+	}
+
+	// the timestamp is still of the monotonic clock, and the window is of the wall clock (also with relative timestamps)
+	if !filter.TimeFilter.Filter(int64(ctx.Ts + t.bootTime)) {
+		return false
+	}
+
+	if filter.ArgnumFilter.Enabled {
+		if min, ok := filter.ArgnumFilter.Filters[ctx.EventID]; ok && ctx.Argnum < min {
+			return false
+		}
+	}
+
+	if filter.RetFilter.Enabled {
+		if retFilter, ok := filter.RetFilter.Filters[ctx.EventID]; ok {
 			retVal := ctx.Retval
 			match := false
-			for _, f := range filter.Equal {
+			for _, f := range retFilter.Equal {
 				if retVal == f {
 					match = true
 					break
 				}
 			}
-			if !match && len(filter.Equal) > 0 {
+			if !match && len(retFilter.Equal) > 0 {
 				return false
 			}
-			for _, f := range filter.NotEqual {
+			for _, f := range retFilter.NotEqual {
 				if retVal == f {
 					return false
 				}
 			}
-			if (filter.Greater != filters.GreaterNotSetInt) && retVal <= filter.Greater {
+			if (retFilter.Greater != filters.GreaterNotSetInt) && retVal <= retFilter.Greater {
 				return false
 			}
-			if (filter.Less != filters.LessNotSetInt) && retVal >= filter.Less {
+			if (retFilter.Less != filters.LessNotSetInt) && retVal >= retFilter.Less {
 				return false
 			}
 		}
 	}
 
-	if t.config.Filter.ArgFilter.Enabled {
-		for argName, filter := range t.config.Filter.ArgFilter.Filters[events.ID(ctx.EventID)] {
+	if filter.ArgFilter.Enabled {
+		for argName, argFilter := range filter.ArgFilter.Filters[events.ID(ctx.EventID)] {
 			var argVal interface{}
 			ok := false
 			for _, arg := range args {
@@ -92,22 +127,147 @@ func (t *Tracee) shouldProcessEvent(ctx *bufferdecoder.Context, args []trace.Arg
 			if !ok {
 				continue
 			}
-			// TODO: use type assertion instead of string conversion
-			argValStr := fmt.Sprint(argVal)
-			match := MatchFilter(filter.Equal, argValStr)
-			if !match && len(filter.Equal) > 0 {
-				return false
-			}
-			matchExclude := MatchFilter(filter.NotEqual, argValStr)
-			if matchExclude {
+			if !matchArgFilter(argFilter, argVal) {
 				return false
 			}
 		}
 	}
 
+	if filter.ExprFilter.Enabled {
+		if !filter.ExprFilter.Filter(exprFilterContext(ctx), filters.ExprArgs(args)) {
+			return false
+		}
+	}
+
+	// only the events passing all other filters count as the first of their process, and the events submitted for tracee
+	// itself only (e.g. the exec of a process executing again) are kept for its state, also of quiet binaries.
+	// Events of an unknown host mount namespace aren't of the host, so the default quiet binaries are traced
+	if t.events[ctx.EventID].emit {
+		hostMntns := t.hostMntns != 0 && ctx.MntID == t.hostMntns
+		if !filter.QuietExecFilter.Filter(ctx.EventID, hostMntns, args) {
+			return false
+		}
+		return filter.FirstEventFilter.Filter(ctx.EventID, ctx.HostPid)
+	}
+	return true
+}
+
+// exprFilterContext returns the context fields of an event available to filter expressions, named as in the
+// context filters. Expressions compare integers of the same type only, so all fields are given as int
+func exprFilterContext(ctx *bufferdecoder.Context) map[string]interface{} {
+	eventName := ""
+	if def, ok := events.Definitions.GetSafe(ctx.EventID); ok {
+		eventName = def.Name
+	}
+	return map[string]interface{}{
+		"timestamp":   int64(ctx.Ts),
+		"processorId": int64(ctx.ProcessorId),
+		"pid":         int64(ctx.Pid),
+		"tid":         int64(ctx.Tid),
+		"ppid":        int64(ctx.Ppid),
+		"hostPid":     int64(ctx.HostPid),
+		"hostTid":     int64(ctx.HostTid),
+		"hostPpid":    int64(ctx.HostPpid),
+		"uid":         int64(ctx.Uid),
+		"gid":         int64(ctx.Gid),
+		"mntns":       int64(ctx.MntID),
+		"pidns":       int64(ctx.PidID),
+		"comm":        string(bytes.TrimRight(ctx.Comm[:], "\x00")),
+		"uts":         string(bytes.TrimRight(ctx.UtsName[:], "\x00")),
+		"cgroupId":    int64(ctx.CgroupID),
+		"eventId":     int64(ctx.EventID),
+		"eventName":   eventName,
+		"argnum":      int64(ctx.Argnum),
+		"retval":      ctx.Retval,
+	}
+}
+
+// shouldProcessEnrichedEvent decides whether or not to drop an event after it was processed.
+// It applies the argument filters on arguments which are added in userspace (see events.EnrichmentParams),
+// which shouldProcessEvent can't apply as they don't exist yet at that stage.
+func (t *Tracee) shouldProcessEnrichedEvent(event *trace.Event) bool {
+	argFilter := t.filters().ArgFilter
+	if !argFilter.Enabled {
+		return true
+	}
+
+	eventId := events.ID(event.EventID)
+	for argName, filter := range argFilter.Filters[eventId] {
+		if !events.IsEnrichmentParam(eventId, argName) {
+			continue
+		}
+		arg := events.GetArg(event, argName)
+		if arg == nil {
+			continue
+		}
+		if !matchArgFilter(filter, arg.Value) {
+			return false
+		}
+	}
+
 	return true
 }
 
+// matchArgFilter checks if an argument value passes the given argument filter. The elements of slice values (e.g.
+// argv) are matched one by one: a slice passes an equality filter if any of its elements matches it, and a
+// non-equality filter if none of them does
+func matchArgFilter(filter filters.ArgFilterVal, argVal interface{}) bool {
+	// TODO: use type assertion instead of string conversion
+	argValStrs := argFilterValues(argVal)
+	if len(filter.Equal) > 0 || len(filter.EqualFiles) > 0 {
+		match := false
+		for _, argValStr := range argValStrs {
+			if matchArgFilterEqual(filter, argValStr) {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+	for _, argValStr := range argValStrs {
+		if MatchFilter(filter.NotEqual, argValStr) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchArgFilterEqual checks if a string value matches the equality patterns of the given argument filter, given
+// directly or by files
+func matchArgFilterEqual(filter filters.ArgFilterVal, argValStr string) bool {
+	if MatchFilter(filter.Equal, argValStr) {
+		return true
+	}
+	for _, set := range filter.EqualFiles {
+		if MatchFilter(set.Values(), argValStr) {
+			return true
+		}
+	}
+	return false
+}
+
+// argFilterValues returns the string values of an argument matched by filters: the elements of slices, and the
+// value itself for other types (including byte slices, which are buffers rather than lists)
+func argFilterValues(argVal interface{}) []string {
+	switch v := argVal.(type) {
+	case []string:
+		return v
+	case []byte:
+		return []string{fmt.Sprint(v)}
+	}
+	rv := reflect.ValueOf(argVal)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return []string{fmt.Sprint(argVal)}
+	}
+	values := make([]string, rv.Len())
+	for i := range values {
+		values[i] = fmt.Sprint(rv.Index(i).Interface())
+	}
+	return values
+}
+
 func (t *Tracee) deleteProcInfoDelayed(hostTid int) {
 	// wait 5 seconds before deleting from the map - because there might events coming in the context of this process,
 	// after we receive its sched_process_exit. this mainly happens from network events, because these events come from
@@ -116,49 +276,173 @@ func (t *Tracee) deleteProcInfoDelayed(hostTid int) {
 	t.procInfo.DeleteElement(hostTid)
 }
 
+// processEventMinimal keeps the state of processes and containers in minimal mode, without enriching the event
+func (t *Tracee) processEventMinimal(event *trace.Event) error {
+	switch events.ID(event.EventID) {
+	case events.SchedProcessExit, events.SchedProcessFork, events.CgroupMkdir, events.CgroupRmdir:
+		return t.processStateEvent(event)
+	}
+	return nil
+}
+
+// processStateEvent updates the state of processes and containers kept by tracee by their events
+func (t *Tracee) processStateEvent(event *trace.Event) error {
+	switch events.ID(event.EventID) {
+	case events.SchedProcessExit:
+		if t.config.ProcessInfo {
+			if t.config.Capture.NetPerProcess {
+				pcapContext, _, err := t.getPcapContextFromTid(uint32(event.HostThreadID))
+				if err == nil {
+					go t.netExit(pcapContext)
+				}
+			}
+
+			go t.deleteProcInfoDelayed(event.HostThreadID)
+		}
+		if groupExit, err := parse.ArgBoolVal(event, "process_group_exit"); err == nil && groupExit {
+			t.forgetCgroupPath(uint32(event.MountNS), event.HostProcessID)
+		}
+	case events.SchedProcessFork:
+		if t.config.ProcessInfo {
+			args, err := decodeArgs(event)
+			if err != nil {
+				return err
+			}
+			hostTid := args.Int32("child_tid")
+			hostPid := args.Int32("child_pid")
+			pid := args.Int32("child_ns_pid")
+			ppid := args.Int32("parent_ns_pid")
+			hostPpid := args.Int32("parent_pid")
+			tid := args.Int32("child_ns_tid")
+			startTime := args.Uint64("start_time")
+			processData := procinfo.ProcessCtx{
+				StartTime:   int(startTime),
+				ContainerID: event.ContainerID,
+				Pid:         uint32(pid),
+				Tid:         uint32(tid),
+				Ppid:        uint32(ppid),
+				HostTid:     uint32(hostTid),
+				HostPid:     uint32(hostPid),
+				HostPpid:    uint32(hostPpid),
+				Uid:         uint32(event.UserID),
+				MntId:       uint32(event.MountNS),
+				PidId:       uint32(event.PIDNS),
+				Comm:        event.ProcessName,
+			}
+			t.procInfo.UpdateElement(int(hostTid), processData)
+		}
+	case events.CgroupMkdir:
+		args, err := decodeArgs(event)
+		if err != nil {
+			return err
+		}
+		cgroupId := args.Uint64("cgroup_id")
+		hId := args.Uint32("hierarchy_id")
+		info, err := t.containers.CgroupMkdir(cgroupId, args.String("cgroup_path"), hId)
+		if err == nil && info.Container.ContainerId == "" {
+			// If cgroupId is from a regular cgroup directory, and not the
+			// container base directory (from known runtimes), it should be
+			// removed from the containers bpf map.
+			t.containers.RemoveFromBpfMap(t.bpfModule, cgroupId, hId)
+		}
+
+	case events.CgroupRmdir:
+		args, err := decodeArgs(event)
+		if err != nil {
+			return err
+		}
+		cgroupId := args.Uint64("cgroup_id")
+
+		if t.config.Capture.NetPerContainer {
+			if info := t.containers.GetCgroupInfo(cgroupId); info.Container.ContainerId != "" {
+				pcapContext := t.getContainerPcapContext(info.Container.ContainerId)
+				go t.netExit(pcapContext)
+			}
+		}
+
+		t.containers.CgroupRemove(cgroupId, args.Uint32("hierarchy_id"))
+	}
+	return nil
+}
+
 const (
 	StructFopsPointer int = iota
 	IterateShared
 	Iterate
 )
 
+// processEventCaptured processes an event as processEvent does, also returning whether a file was captured by it
+// (copied or queued for copying, or indexed as written), for Output.CapturedOnly. Events are processed one at a time,
+// so the captures made while processing an event are its own
+func (t *Tracee) processEventCaptured(event *trace.Event) (bool, error) {
+	t.eventCaptured = false
+	err := t.processEvent(event)
+	return t.eventCaptured, err
+}
+
 func (t *Tracee) processEvent(event *trace.Event) error {
 	eventId := events.ID(event.EventID)
+	if t.config.Output.Minimal {
+		return t.processEventMinimal(event)
+	}
+	// exec events start watching a new executable, so they are checked once it is tracked
+	if t.config.Output.SelfDeletedWindow > 0 && eventId != events.SchedProcessExec {
+		t.checkSelfDeleted(event)
+	}
+	if t.tracksProcessTree() {
+		t.updateProcessTree(event)
+	}
+	if t.config.Output.Ancestry > 0 {
+		t.addAncestry(event)
+	}
 	switch eventId {
 
 	case events.VfsWrite, events.VfsWritev, events.KernelWrite:
+		//add the trailing bytes of written files
+		if t.config.Capture.WriteTailSize > 0 {
+			if err := t.addWriteTail(event); err != nil {
+				return err
+			}
+		}
 		//capture written files
 		if t.config.Capture.FileWrite {
-			filePath, err := parse.ArgStringVal(event, "pathname")
+			args, err := decodeArgs(event)
 			if err != nil {
-				return fmt.Errorf("error parsing vfs_write args: %v", err)
+				return err
 			}
+			dev := args.Uint32("dev")
+			inode := args.Uint64("inode")
+			//add the device of written files as major:minor, as filesystem tools tell them
+			event.Args = append(event.Args, trace.Argument{
+				ArgMeta: trace.ArgMeta{Name: "device", Type: "const char*"},
+				Value:   formatKernelDev(dev),
+			})
+			event.ArgsNum++
+
+			filePath := args.String("pathname")
 			// path should be absolute, except for e.g memfd_create files
 			if filePath == "" || filePath[0] != '/' {
 				return nil
 			}
-			dev, err := parse.ArgUint32Val(event, "dev")
-			if err != nil {
-				return fmt.Errorf("error parsing vfs_write args: %v", err)
-			}
-			inode, err := parse.ArgUint64Val(event, "inode")
-			if err != nil {
-				return fmt.Errorf("error parsing vfs_write args: %v", err)
+
+			// with first write only capture, a file is indexed once
+			if t.config.Capture.FirstWriteOnly {
+				if ok, _ := t.indexedWrites.ContainsOrAdd(fileInode{dev, inode}, struct{}{}); ok {
+					return nil
+				}
 			}
 
 			// stop processing if write was already indexed
-			containerId := event.ContainerID
-			if containerId == "" {
-				containerId = "host"
-			}
-			fileName := fmt.Sprintf("%s/write.dev-%d.inode-%d", containerId, dev, inode)
+			// file write chunks carry no mount namespace, so index them by container only (see processFileWrites)
+			fileName := fmt.Sprintf("%s/write.dev-%d.inode-%d", t.captureDir(event.ContainerID, 0), dev, inode)
 			indexName, ok := t.writtenFiles[fileName]
 			if ok && indexName == filePath {
 				return nil
 			}
 
 			// index written file by original filepath
-			t.writtenFiles[fileName] = filePath
+			t.indexWrittenFile(fileName, filePath)
+			t.eventCaptured = capturesWrittenPath(t.config.Capture.FilterFileWrite, filePath)
 		}
 
 	case events.SchedProcessExec:
@@ -177,83 +461,95 @@ func (t *Tracee) processEvent(event *trace.Event) error {
 		} else {
 			t.pidsInMntns.AddBucketItem(uint32(event.MountNS), uint32(event.HostProcessID))
 		}
+		//capture the command line, which the argv argument only holds a bounded part of
+		if t.config.Capture.Cmdline {
+			event.Args = append(event.Args, trace.Argument{
+				ArgMeta: trace.ArgMeta{Name: "cmdline", Type: "const char**"},
+				Value:   readCmdline(event.HostProcessID),
+			})
+			event.ArgsNum++
+		}
+		//watch the executed file, to detect processes deleting their own executable
+		if t.config.Output.SelfDeletedWindow > 0 {
+			args, err := decodeArgs(event)
+			if err != nil {
+				return err
+			}
+			filePath := args.String("pathname")
+			// files without an absolute path (e.g memfd_create files) have no path to be deleted from
+			if filePath != "" && filePath[0] == '/' {
+				t.trackExec(event.HostProcessID, filePath)
+				t.checkSelfDeleted(event)
+			}
+		}
+		//hash the executable image which runs, whatever its file
+		if t.config.Output.ExecMemHash {
+			event.Args = append(event.Args, trace.Argument{
+				ArgMeta: trace.ArgMeta{Name: "mem_sha256", Type: "const char*"},
+				Value:   t.execMemHash(event.HostProcessID),
+			})
+			event.ArgsNum++
+		}
+		//group the execs of the same command, whatever their process
+		if t.config.Output.ExecGroupID {
+			if err := t.addExecGroupID(event); err != nil {
+				return err
+			}
+		}
 		//capture executed files
 		if t.config.Capture.Exec || t.config.Output.ExecHash {
-			filePath, err := parse.ArgStringVal(event, "pathname")
+			args, err := decodeArgs(event)
 			if err != nil {
-				return fmt.Errorf("error parsing sched_process_exec args: %v", err)
+				return err
 			}
+			filePath := args.String("pathname")
 			// path should be absolute, except for e.g memfd_create files
 			if filePath == "" || filePath[0] != '/' {
 				return nil
 			}
+			// the event is still emitted for excluded processes, only the file isn't captured
+			if MatchFilter(t.config.Capture.ExcludeComms, event.ProcessName) || MatchFilter(t.config.Capture.ExcludePaths, filePath) {
+				return nil
+			}
 
 			// try to access the root fs via another process in the same mount namespace (since the current process might have already died)
 			pids := t.pidsInMntns.GetBucket(uint32(event.MountNS))
 			for _, pid := range pids { // will break on success
 				err = nil
 				sourceFilePath := fmt.Sprintf("/proc/%s/root%s", strconv.Itoa(int(pid)), filePath)
-				sourceFileCtime, err := parse.ArgUint64Val(event, "ctime")
-				if err != nil {
-					return fmt.Errorf("error parsing sched_process_exec args: %v", err)
-				}
-				castedSourceFileCtime := int64(sourceFileCtime)
+				castedSourceFileCtime := int64(args.Uint64("ctime"))
 
-				containerId := event.ContainerID
-				if containerId == "" {
-					containerId = "host"
+				// unlinked files are read through the references of the executing process, and are neither captured
+				// nor hashed once it holds none
+				readFilePath, err := execSourcePath(sourceFilePath, filePath, event.HostProcessID)
+				if err != nil {
+					t.log(logger.DebugLevel, "skipped capture of executed file", "path", filePath, "error", err)
+					return nil
 				}
-				capturedFileID := fmt.Sprintf("%s:%s", containerId, sourceFilePath)
-				if t.config.Capture.Exec {
-					destinationDirPath := containerId
-					if err := utils.MkdirAtExist(t.outDir, destinationDirPath, 0755); err != nil {
-						return err
-					}
-					destinationFilePath := filepath.Join(destinationDirPath, fmt.Sprintf("exec.%d.%s", event.Timestamp, filepath.Base(filePath)))
-
-					// create an in-memory profile
-					if t.config.Capture.Profile {
-						t.updateProfile(fmt.Sprintf("%s:%d", filepath.Join(destinationDirPath, fmt.Sprintf("exec.%s", filepath.Base(filePath))), castedSourceFileCtime), uint64(event.Timestamp))
-					}
-
-					//don't capture same file twice unless it was modified
-					lastCtime, ok := t.capturedFiles[capturedFileID]
-					if !ok || lastCtime != castedSourceFileCtime {
-						//capture
-						err = utils.CopyRegularFileByRelativePath(sourceFilePath, t.outDir, destinationFilePath)
-						if err != nil {
-							return err
-						}
-						//mark this file as captured
-						t.capturedFiles[capturedFileID] = castedSourceFileCtime
-					}
+				currentHash, err := t.captureExecFile(event, sourceFilePath, readFilePath, filePath, castedSourceFileCtime)
+				if err != nil {
+					return err
 				}
 
 				if t.config.Output.ExecHash {
-					var hashInfoObj fileExecInfo
-					var currentHash string
-					hashInfoInterface, ok := t.fileHashes.Get(capturedFileID)
-
-					// cast to fileExecInfo
-					if ok {
-						hashInfoObj = hashInfoInterface.(fileExecInfo)
-					}
-					// Check if cache can be used
-					if ok && hashInfoObj.LastCtime == castedSourceFileCtime {
-						currentHash = hashInfoObj.Hash
-					} else {
-						currentHash, err = computeFileHashAtPath(sourceFilePath)
-						if err == nil {
-							hashInfoObj = fileExecInfo{castedSourceFileCtime, currentHash}
-							t.fileHashes.Add(capturedFileID, hashInfoObj)
-						}
-					}
-
 					event.Args = append(event.Args, trace.Argument{
 						ArgMeta: trace.ArgMeta{Name: "sha256", Type: "const char*"},
 						Value:   currentHash,
 					})
 					event.ArgsNum += 1
+
+					if t.config.Output.ParentExecHash {
+						event.Args = append(event.Args, trace.Argument{
+							ArgMeta: trace.ArgMeta{Name: "parent_sha256", Type: "const char*"},
+							Value:   t.parentExecHash(event.HostParentProcessID),
+						})
+						event.ArgsNum += 1
+					}
+				}
+
+				// executed scripts are run by their interpreter, which is captured along with them
+				if err := t.captureScriptInterpreter(event, pid, readFilePath); err != nil {
+					return err
 				}
 				if true { // so loop is conditionally terminated (#SA4044)
 					break
@@ -261,102 +557,27 @@ func (t *Tracee) processEvent(event *trace.Event) error {
 			}
 			return err
 		}
-	case events.SchedProcessExit:
-		if t.config.ProcessInfo {
-			if t.config.Capture.NetPerProcess {
-				pcapContext, _, err := t.getPcapContextFromTid(uint32(event.HostThreadID))
-				if err == nil {
-					go t.netExit(pcapContext)
-				}
-			}
-
-			go t.deleteProcInfoDelayed(event.HostThreadID)
-		}
-	case events.SchedProcessFork:
-		if t.config.ProcessInfo {
-			hostTid, err := parse.ArgInt32Val(event, "child_tid")
-			if err != nil {
-				return err
-			}
-			hostPid, err := parse.ArgInt32Val(event, "child_pid")
-			if err != nil {
-				return err
-			}
-			pid, err := parse.ArgInt32Val(event, "child_ns_pid")
-			if err != nil {
-				return err
-			}
-			ppid, err := parse.ArgInt32Val(event, "parent_ns_pid")
-			if err != nil {
-				return err
-			}
-			hostPpid, err := parse.ArgInt32Val(event, "parent_pid")
-			if err != nil {
-				return err
-			}
-			tid, err := parse.ArgInt32Val(event, "child_ns_tid")
-			if err != nil {
-				return err
-			}
-			startTime, err := parse.ArgUint64Val(event, "start_time")
-			if err != nil {
-				return err
-			}
-			processData := procinfo.ProcessCtx{
-				StartTime:   int(startTime),
-				ContainerID: event.ContainerID,
-				Pid:         uint32(pid),
-				Tid:         uint32(tid),
-				Ppid:        uint32(ppid),
-				HostTid:     uint32(hostTid),
-				HostPid:     uint32(hostPid),
-				HostPpid:    uint32(hostPpid),
-				Uid:         uint32(event.UserID),
-				MntId:       uint32(event.MountNS),
-				PidId:       uint32(event.PIDNS),
-				Comm:        event.ProcessName,
-			}
-			t.procInfo.UpdateElement(int(hostTid), processData)
-		}
-	case events.CgroupMkdir:
-		cgroupId, err := parse.ArgUint64Val(event, "cgroup_id")
-		if err != nil {
-			return fmt.Errorf("error parsing cgroup_mkdir args: %w", err)
-		}
-		path, err := parse.ArgStringVal(event, "cgroup_path")
-		if err != nil {
-			return fmt.Errorf("error parsing cgroup_mkdir args: %w", err)
-		}
-		hId, err := parse.ArgUint32Val(event, "hierarchy_id")
-		if err != nil {
-			return fmt.Errorf("error parsing cgroup_mkdir args: %w", err)
-		}
-		info, err := t.containers.CgroupMkdir(cgroupId, path, hId)
-		if err == nil && info.Container.ContainerId == "" {
-			// If cgroupId is from a regular cgroup directory, and not the
-			// container base directory (from known runtimes), it should be
-			// removed from the containers bpf map.
-			t.containers.RemoveFromBpfMap(t.bpfModule, cgroupId, hId)
+	case events.SharedObjectLoaded:
+		//capture loaded shared objects
+		if t.config.Capture.SharedObjects {
+			return t.captureSharedObject(event)
 		}
-
-	case events.CgroupRmdir:
-		cgroupId, err := parse.ArgUint64Val(event, "cgroup_id")
-		if err != nil {
-			return fmt.Errorf("error parsing cgroup_rmdir args: %w", err)
+	case events.SecurityFileOpen:
+		//capture opened files
+		if len(t.config.Capture.FileOpenPaths) > 0 {
+			return t.captureOpenedFile(event)
 		}
+	case events.SchedProcessExit, events.SchedProcessFork, events.CgroupMkdir, events.CgroupRmdir:
+		return t.processStateEvent(event)
 
-		if t.config.Capture.NetPerContainer {
-			if info := t.containers.GetCgroupInfo(cgroupId); info.Container.ContainerId != "" {
-				pcapContext := t.getContainerPcapContext(info.Container.ContainerId)
-				go t.netExit(pcapContext)
-			}
+	case events.SecurityInodeRename:
+		if t.capturesFiles() {
+			return t.processFileRename(event)
 		}
-
-		hId, err := parse.ArgUint32Val(event, "hierarchy_id")
-		if err != nil {
-			return fmt.Errorf("error parsing cgroup_mkdir args: %w", err)
+	case events.SecurityInodeUnlink:
+		if t.capturesFiles() {
+			return t.processFileUnlink(event)
 		}
-		t.containers.CgroupRemove(cgroupId, hId)
 
 	// in case FinitModule and InitModule occurs it means that a kernel module was loaded
 	// and we will want to check if it hooked the syscall table and seq_ops
@@ -374,9 +595,13 @@ func (t *Tracee) processEvent(event *trace.Event) error {
 		}
 
 	case events.HookedProcFops:
-		fopsAddresses, err := parse.ArgUlongArrVal(event, "hooked_fops_pointers")
-		if err != nil || fopsAddresses == nil {
-			return fmt.Errorf("error parsing hooked_proc_fops args: %w", err)
+		args, err := decodeArgs(event)
+		if err != nil {
+			return err
+		}
+		fopsAddresses := args.UlongArray("hooked_fops_pointers")
+		if fopsAddresses == nil {
+			return fmt.Errorf("error parsing hooked_proc_fops args: no hooked fops pointers")
 		}
 		hookedFops := make([]trace.HookedSymbolData, 0)
 		for idx, addr := range fopsAddresses {
@@ -422,6 +647,9 @@ func (t *Tracee) processEvent(event *trace.Event) error {
 }
 
 func (t *Tracee) updateProfile(sourceFilePath string, executionTs uint64) {
+	t.profileMtx.Lock()
+	defer t.profileMtx.Unlock()
+
 	if pf, ok := t.profiledFiles[sourceFilePath]; !ok {
 		t.profiledFiles[sourceFilePath] = profilerInfo{
 			Times:            1,
@@ -432,3 +660,71 @@ func (t *Tracee) updateProfile(sourceFilePath string, executionTs uint64) {
 		t.profiledFiles[sourceFilePath] = pf // update
 	}
 }
+
+// recordProfiledCapture records the path of the first capture of a profiled file, which is hashed by updateFileSHA
+func (t *Tracee) recordProfiledCapture(sourceFilePath string, capturedPath string) {
+	t.profileMtx.Lock()
+	defer t.profileMtx.Unlock()
+
+	if pf, ok := t.profiledFiles[sourceFilePath]; ok && pf.CapturedPath == "" {
+		pf.CapturedPath = capturedPath
+		t.profiledFiles[sourceFilePath] = pf
+	}
+}
+
+// captureDir returns the directory (relative to the output dir) which groups the artifacts captured for the given
+// container or mount namespace. Container artifacts are grouped by container id, and other artifacts by mount
+// namespace, where the host mount namespace (or an unknown one, given as 0) is named "host"
+func (t *Tracee) captureDir(containerId string, mntns uint32) string {
+	if containerId != "" {
+		return containerId
+	}
+	if mntns == 0 || t.hostMntns == 0 || mntns == t.hostMntns {
+		return "host"
+	}
+	return fmt.Sprintf("mntns-%d", mntns)
+}
+
+// readCmdline reads the command line of a process. The process may have already exited (or exec'ed again), in which
+// case an empty command line is returned, since its original one can no longer be read
+func readCmdline(hostPid int) []string {
+	blob, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", hostPid))
+	if err != nil {
+		return []string{}
+	}
+	return parseCmdline(blob)
+}
+
+// parseCmdline splits the content of /proc/<pid>/cmdline to its arguments. Arguments are NUL terminated and may be
+// empty. A process may overwrite its arguments area, so the last argument might not be terminated
+func parseCmdline(blob []byte) []string {
+	args := []string{}
+	for len(blob) > 0 {
+		end := bytes.IndexByte(blob, 0)
+		if end < 0 {
+			args = append(args, string(blob))
+			break
+		}
+		args = append(args, string(blob[:end]))
+		blob = blob[end+1:]
+	}
+	return args
+}
+
+// truncatedArgMarker is appended to string arguments which were truncated
+const truncatedArgMarker = "...(truncated)"
+
+// truncateStringArgs truncates string arguments of the event longer than maxLength bytes, without splitting a rune
+func truncateStringArgs(event *trace.Event, maxLength int) {
+	for i := range event.Args {
+		str, isString := event.Args[i].Value.(string)
+		if !isString || len(str) <= maxLength {
+			continue
+		}
+		cut := maxLength
+		for cut > 0 && !utf8.RuneStart(str[cut]) {
+			cut--
+		}
+		event.Args[i].Value = str[:cut] + truncatedArgMarker
+	}
+}
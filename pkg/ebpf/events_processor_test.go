@@ -0,0 +1,1238 @@
+package ebpf
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/bufferdecoder"
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/filters"
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/aquasecurity/tracee/types/trace"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTracee creates a Tracee with the userspace state required by processEvent
+func newTestTracee(t testing.TB, config Config) *Tracee {
+	if config.Filter == nil {
+		config.Filter = &Filter{
+			ArgFilter:     &filters.ArgFilter{Filters: make(map[events.ID]map[string]filters.ArgFilterVal)},
+			RetFilter:     &filters.RetFilter{Filters: make(map[events.ID]filters.IntFilter)},
+			ArgnumFilter:  &filters.ArgnumFilter{Filters: make(map[events.ID]uint8)},
+			ExprFilter:    &filters.ExprFilter{},
+			ContFilter:    &filters.BoolFilter{},
+			NewContFilter: &filters.BoolFilter{},
+		}
+	}
+	if config.Capture == nil {
+		config.Capture = &CaptureConfig{}
+	}
+	if config.Output == nil {
+		config.Output = &OutputConfig{}
+	}
+
+	d, err := ioutil.TempDir("", "Test_tracee_outdir-*")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(d) })
+
+	outDir, err := utils.OpenExistingDir(d)
+	require.NoError(t, err)
+	t.Cleanup(func() { outDir.Close() })
+
+	fileHashes, err := lru.New(fileHashesCacheSize)
+	require.NoError(t, err)
+	recentExecs, err := lru.New(recentExecsSize)
+	require.NoError(t, err)
+	indexedWrites, err := lru.New(firstWritesCacheSize)
+	require.NoError(t, err)
+	firstWrites, err := lru.New(firstWritesCacheSize)
+	require.NoError(t, err)
+
+	trc := &Tracee{
+		config:         config,
+		clock:          utils.RealClock{},
+		outDir:         outDir,
+		fileHashes:     fileHashes,
+		recentExecs:    recentExecs,
+		capturedFiles:  make(map[string]int64),
+		capturedHashes: make(map[string]string),
+		writtenFiles:   make(map[string]string),
+		indexedWrites:  indexedWrites,
+		firstWrites:    firstWrites,
+		profiledFiles:  make(map[string]profilerInfo),
+		processTree:    make(map[int]processNode),
+	}
+	trc.pidsInMntns.Init(5)
+
+	return trc
+}
+
+// newExecEvent creates a sched_process_exec event of the current process executing the given file
+func newExecEvent(t testing.TB, pathname string) *trace.Event {
+	info, err := os.Stat(pathname)
+	require.NoError(t, err)
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	require.True(t, ok)
+
+	return &trace.Event{
+		EventID:       int(events.SchedProcessExec),
+		EventName:     "sched_process_exec",
+		ProcessID:     os.Getpid(),
+		HostProcessID: os.Getpid(),
+		MountNS:       1,
+		ArgsNum:       2,
+		Args: []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: pathname},
+			{ArgMeta: trace.ArgMeta{Name: "ctime", Type: "unsigned long"}, Value: uint64(stat.Ctim.Nano())},
+		},
+	}
+}
+
+func Test_shouldProcessEvent_argnum(t *testing.T) {
+	argnumFilter := &filters.ArgnumFilter{
+		Filters: map[events.ID]uint8{events.Openat: 4},
+		Enabled: true,
+	}
+	trc := newTestTracee(t, Config{
+		Filter: &Filter{
+			RetFilter:    &filters.RetFilter{Filters: make(map[events.ID]filters.IntFilter)},
+			ArgFilter:    &filters.ArgFilter{Filters: make(map[events.ID]map[string]filters.ArgFilterVal)},
+			ArgnumFilter: argnumFilter,
+			ExprFilter:   &filters.ExprFilter{},
+		},
+	})
+
+	testCases := []struct {
+		name           string
+		eventID        events.ID
+		argnum         uint8
+		expectedResult bool
+	}{
+		{
+			name:           "under threshold",
+			eventID:        events.Openat,
+			argnum:         2,
+			expectedResult: false,
+		},
+		{
+			name:           "at threshold",
+			eventID:        events.Openat,
+			argnum:         4,
+			expectedResult: true,
+		},
+		{
+			name:           "over threshold",
+			eventID:        events.Openat,
+			argnum:         5,
+			expectedResult: true,
+		},
+		{
+			name:           "no threshold for event",
+			eventID:        events.Close,
+			argnum:         0,
+			expectedResult: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := &bufferdecoder.Context{EventID: tc.eventID, Argnum: tc.argnum}
+			assert.Equal(t, tc.expectedResult, trc.shouldProcessEvent(ctx, nil))
+		})
+	}
+}
+
+func Test_shouldProcessEvent_argsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allowlist")
+	modTime := time.Unix(1661500000, 0)
+	require.NoError(t, ioutil.WriteFile(path, []byte("/usr/bin/ls\n/usr/sbin/*\n"), 0644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+
+	trc := newTestTracee(t, Config{})
+	require.NoError(t, trc.config.Filter.ArgFilter.Parse("execve.pathname", "=@"+path, events.Definitions.NamesToIDs()))
+	trc.config.Filter.ArgFilter.Filters[events.Execve]["pathname"].EqualFiles[0].CheckInterval = 0
+
+	shouldProcess := func(pathname string) bool {
+		ctx := &bufferdecoder.Context{EventID: events.Execve}
+		args := []trace.Argument{{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: pathname}}
+		return trc.shouldProcessEvent(ctx, args)
+	}
+	assert.True(t, shouldProcess("/usr/bin/ls"))
+	assert.True(t, shouldProcess("/usr/sbin/nginx"))
+	assert.False(t, shouldProcess("/usr/bin/curl"))
+
+	// the allowlist changes without changing the filter
+	require.NoError(t, ioutil.WriteFile(path, []byte("/usr/bin/curl\n"), 0644))
+	require.NoError(t, os.Chtimes(path, modTime.Add(time.Second), modTime.Add(time.Second)))
+	assert.True(t, shouldProcess("/usr/bin/curl"))
+	assert.False(t, shouldProcess("/usr/bin/ls"))
+}
+
+func Test_shouldProcessEvent_argsSlice(t *testing.T) {
+	testCases := []struct {
+		name           string
+		filter         string
+		argv           []string
+		expectedResult bool
+	}{
+		{
+			name:           "equal to an element",
+			filter:         "=--insecure",
+			argv:           []string{"curl", "--insecure", "https://example.com"},
+			expectedResult: true,
+		},
+		{
+			name:           "equal to a pattern of an element",
+			filter:         "=https://*",
+			argv:           []string{"curl", "--insecure", "https://example.com"},
+			expectedResult: true,
+		},
+		{
+			name:           "equal to no element",
+			filter:         "=--insecure,-k",
+			argv:           []string{"curl", "https://example.com"},
+			expectedResult: false,
+		},
+		{
+			name:           "equal to an empty slice",
+			filter:         "=--insecure",
+			argv:           []string{},
+			expectedResult: false,
+		},
+		{
+			name:           "not equal to any element",
+			filter:         "!=--insecure,-k",
+			argv:           []string{"curl", "https://example.com"},
+			expectedResult: true,
+		},
+		{
+			name:           "not equal to an element",
+			filter:         "!=--insecure,-k",
+			argv:           []string{"curl", "-k", "https://example.com"},
+			expectedResult: false,
+		},
+		{
+			name:           "not equal to an empty slice",
+			filter:         "!=--insecure",
+			argv:           []string{},
+			expectedResult: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			trc := newTestTracee(t, Config{})
+			require.NoError(t, trc.config.Filter.ArgFilter.Parse("execve.argv", tc.filter, events.Definitions.NamesToIDs()))
+
+			ctx := &bufferdecoder.Context{EventID: events.Execve}
+			args := []trace.Argument{{ArgMeta: trace.ArgMeta{Name: "argv", Type: "const char*const*"}, Value: tc.argv}}
+			assert.Equal(t, tc.expectedResult, trc.shouldProcessEvent(ctx, args))
+		})
+	}
+
+	t.Run("slices of other types", func(t *testing.T) {
+		assert.True(t, matchArgFilter(filters.ArgFilterVal{Equal: []string{"3"}}, []int32{0, 3}))
+		assert.False(t, matchArgFilter(filters.ArgFilterVal{NotEqual: []string{"3"}}, []uint64{0, 3}))
+		// byte buffers are matched as a whole
+		assert.True(t, matchArgFilter(filters.ArgFilterVal{Equal: []string{"[1 2]"}}, []byte{1, 2}))
+	})
+}
+
+func Test_shouldProcessEvent_time(t *testing.T) {
+	const (
+		bootTime = 1661500000000000000
+		start    = 1661508000000000000
+		end      = 1661509800000000000
+	)
+
+	testCases := []struct {
+		name           string
+		filter         *filters.TimeFilter
+		ts             uint64 // of the monotonic clock
+		expectedResult bool
+	}{
+		{
+			name:           "before start",
+			filter:         &filters.TimeFilter{Start: start, End: end, Enabled: true},
+			ts:             start - bootTime - 1,
+			expectedResult: false,
+		},
+		{
+			name:           "at start",
+			filter:         &filters.TimeFilter{Start: start, End: end, Enabled: true},
+			ts:             start - bootTime,
+			expectedResult: true,
+		},
+		{
+			name:           "at end",
+			filter:         &filters.TimeFilter{Start: start, End: end, Enabled: true},
+			ts:             end - bootTime,
+			expectedResult: true,
+		},
+		{
+			name:           "after end",
+			filter:         &filters.TimeFilter{Start: start, End: end, Enabled: true},
+			ts:             end - bootTime + 1,
+			expectedResult: false,
+		},
+		{
+			name:           "only start",
+			filter:         &filters.TimeFilter{Start: start, Enabled: true},
+			ts:             end - bootTime + 1,
+			expectedResult: true,
+		},
+		{
+			name:           "before only start",
+			filter:         &filters.TimeFilter{Start: start, Enabled: true},
+			ts:             start - bootTime - 1,
+			expectedResult: false,
+		},
+		{
+			name:           "only end",
+			filter:         &filters.TimeFilter{End: end, Enabled: true},
+			ts:             0,
+			expectedResult: true,
+		},
+		{
+			name:           "after only end",
+			filter:         &filters.TimeFilter{End: end, Enabled: true},
+			ts:             end - bootTime + 1,
+			expectedResult: false,
+		},
+		{
+			name:           "no window",
+			ts:             0,
+			expectedResult: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			trc := newTestTracee(t, Config{})
+			trc.config.Filter.TimeFilter = tc.filter
+			trc.bootTime = bootTime
+
+			ctx := &bufferdecoder.Context{EventID: events.Openat, Ts: tc.ts}
+			assert.Equal(t, tc.expectedResult, trc.shouldProcessEvent(ctx, nil))
+		})
+	}
+}
+
+func Test_shouldProcessEvent_first(t *testing.T) {
+	trc := newTestTracee(t, Config{})
+	trc.config.Filter.FirstEventFilter = &filters.FirstEventFilter{Enabled: true}
+	trc.config.Filter.UIDFilter = &filters.UIntFilter{NotEqual: []uint64{}, Less: filters.LessNotSetUint, Greater: filters.GreaterNotSetUint, Is32Bit: true}
+	require.NoError(t, trc.config.Filter.UIDFilter.Parse("=0"))
+	trc.replaying = true
+	trc.events = map[events.ID]eventConfig{
+		events.Openat:           {submit: true, emit: true},
+		events.SchedProcessExit: {submit: true, emit: true},
+		events.SchedProcessExec: {submit: true},
+	}
+	shouldProcess := func(eventID events.ID, hostPid uint32, uid uint32, args ...trace.Argument) bool {
+		ctx := &bufferdecoder.Context{EventID: eventID, HostPid: hostPid, HostTid: hostPid, Uid: uid}
+		return trc.shouldProcessEvent(ctx, args)
+	}
+	groupExit := func(exited bool) trace.Argument {
+		return trace.Argument{ArgMeta: trace.ArgMeta{Name: "process_group_exit", Type: "bool"}, Value: exited}
+	}
+
+	// events filtered out don't count as the first of their process
+	assert.False(t, shouldProcess(events.Openat, 1000, 1000))
+	assert.True(t, shouldProcess(events.Openat, 1000, 0))
+	assert.False(t, shouldProcess(events.Openat, 1000, 0))
+	assert.True(t, shouldProcess(events.Openat, 1001, 0))
+	// events not selected are kept for the state of tracee
+	assert.True(t, shouldProcess(events.SchedProcessExec, 1000, 0))
+	assert.True(t, shouldProcess(events.SchedProcessExec, 1000, 0))
+
+	// the exit of a thread doesn't end its process
+	assert.True(t, shouldProcess(events.SchedProcessExit, 1000, 0, groupExit(false)))
+	assert.False(t, shouldProcess(events.Openat, 1000, 0))
+	// a process is forgotten once it exits, even if its exit is filtered out
+	assert.False(t, shouldProcess(events.SchedProcessExit, 1000, 1000, groupExit(true)))
+	assert.True(t, shouldProcess(events.Openat, 1000, 0))
+	assert.False(t, shouldProcess(events.Openat, 1001, 0))
+}
+
+func Test_shouldProcessEvent_quietExec(t *testing.T) {
+	trc := newTestTracee(t, Config{})
+	trc.config.Filter.QuietExecFilter = filters.NewQuietExecFilter()
+	trc.hostMntns = 4026531840
+	trc.events = map[events.ID]eventConfig{
+		events.SchedProcessExec: {submit: true, emit: true},
+		events.Execve:           {submit: true},
+	}
+	shouldProcess := func(eventID events.ID, mntns uint32, path string) bool {
+		ctx := &bufferdecoder.Context{EventID: eventID, HostPid: 1000, MntID: mntns}
+		args := []trace.Argument{{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: path}}
+		return trc.shouldProcessEvent(ctx, args)
+	}
+
+	assert.False(t, shouldProcess(events.SchedProcessExec, trc.hostMntns, "/usr/sbin/cron"))
+	assert.False(t, shouldProcess(events.SchedProcessExec, trc.hostMntns, "/lib/systemd/systemd-udevd"))
+	assert.True(t, shouldProcess(events.SchedProcessExec, trc.hostMntns, "/usr/bin/curl"))
+	// a container exec of the path of a daemon is traced
+	assert.True(t, shouldProcess(events.SchedProcessExec, 4026532280, "/usr/sbin/cron"))
+	// events submitted for tracee itself only are kept for its state
+	assert.True(t, shouldProcess(events.Execve, trc.hostMntns, "/usr/sbin/cron"))
+
+	require.NoError(t, trc.config.Filter.QuietExecFilter.Parse("!=/usr/sbin/cron"))
+	assert.True(t, shouldProcess(events.SchedProcessExec, trc.hostMntns, "/usr/sbin/cron"))
+
+	// without a known host mount namespace, no event is of the host
+	trc.hostMntns = 0
+	assert.True(t, shouldProcess(events.SchedProcessExec, 0, "/lib/systemd/systemd-udevd"))
+}
+
+func Test_shouldProcessEvent_expression(t *testing.T) {
+	comm := [16]byte{}
+	copy(comm[:], "bash")
+	ctx := &bufferdecoder.Context{EventID: events.Openat, Pid: 1000, Uid: 0, Comm: comm, Retval: -2, Argnum: 2}
+	args := []trace.Argument{
+		{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/etc/shadow"},
+		{ArgMeta: trace.ArgMeta{Name: "flags", Type: "int"}, Value: int32(2)},
+	}
+
+	testCases := []struct {
+		name           string
+		expressions    []string
+		expectedResult bool
+	}{
+		{
+			name:           "failed event under path",
+			expressions:    []string{`event.retval < 0 && args.pathname.startsWith("/etc")`},
+			expectedResult: true,
+		},
+		{
+			name:           "other path",
+			expressions:    []string{`args.pathname.startsWith("/tmp")`},
+			expectedResult: false,
+		},
+		{
+			name:           "context fields",
+			expressions:    []string{`event.eventName == "openat" && event.comm == "bash" && event.uid == 0 && event.pid > 100`},
+			expectedResult: true,
+		},
+		{
+			name:           "integer argument",
+			expressions:    []string{`args.flags == 2`},
+			expectedResult: true,
+		},
+		{
+			name:           "missing argument",
+			expressions:    []string{`args.fd == 3`},
+			expectedResult: false,
+		},
+		{
+			name:           "guarded missing argument",
+			expressions:    []string{`!has(args.fd) || args.fd == 3`},
+			expectedResult: true,
+		},
+		{
+			name:           "all expressions match",
+			expressions:    []string{`event.retval < 0`, `args.pathname.endsWith("shadow")`},
+			expectedResult: true,
+		},
+		{
+			name:           "one expression fails",
+			expressions:    []string{`event.retval < 0`, `event.comm == "sh"`},
+			expectedResult: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exprFilter := &filters.ExprFilter{}
+			for _, expression := range tc.expressions {
+				require.NoError(t, exprFilter.Parse(expression))
+			}
+			trc := newTestTracee(t, Config{
+				Filter: &Filter{
+					RetFilter:    &filters.RetFilter{Filters: make(map[events.ID]filters.IntFilter)},
+					ArgFilter:    &filters.ArgFilter{Filters: make(map[events.ID]map[string]filters.ArgFilterVal)},
+					ArgnumFilter: &filters.ArgnumFilter{Filters: make(map[events.ID]uint8)},
+					ExprFilter:   exprFilter,
+				},
+			})
+			assert.Equal(t, tc.expectedResult, trc.shouldProcessEvent(ctx, args))
+		})
+	}
+}
+
+func Test_shouldProcessEvent_uidGid(t *testing.T) {
+	newUIntFilter := func(t *testing.T, expressions []string) *filters.UIntFilter {
+		filter := &filters.UIntFilter{
+			Equal:    []uint64{},
+			NotEqual: []uint64{},
+			Less:     filters.LessNotSetUint,
+			Greater:  filters.GreaterNotSetUint,
+			Is32Bit:  true,
+		}
+		for _, expression := range expressions {
+			require.NoError(t, filter.Parse(expression))
+		}
+		return filter
+	}
+
+	testCases := []struct {
+		name           string
+		uidFilter      []string
+		gidFilter      []string
+		uid            uint32
+		gid            uint32
+		expectedResult bool
+	}{
+		{
+			name:           "no filters",
+			uid:            1000,
+			expectedResult: true,
+		},
+		{
+			name:           "root uid",
+			uidFilter:      []string{"=0"},
+			uid:            0,
+			expectedResult: true,
+		},
+		{
+			name:           "not root uid",
+			uidFilter:      []string{"=0"},
+			uid:            1000,
+			expectedResult: false,
+		},
+		{
+			name:           "excluded uid",
+			uidFilter:      []string{"!=998"},
+			uid:            998,
+			expectedResult: false,
+		},
+		{
+			name:           "other than excluded uid",
+			uidFilter:      []string{"!=998"},
+			uid:            1000,
+			expectedResult: true,
+		},
+		{
+			name:           "uid in range",
+			uidFilter:      []string{">999", "<2000"},
+			uid:            1000,
+			expectedResult: true,
+		},
+		{
+			name:           "uid out of range",
+			uidFilter:      []string{">999", "<2000"},
+			uid:            2000,
+			expectedResult: false,
+		},
+		{
+			name:           "gid",
+			gidFilter:      []string{"=0"},
+			gid:            0,
+			expectedResult: true,
+		},
+		{
+			name:           "other gid",
+			gidFilter:      []string{"=0"},
+			gid:            100,
+			expectedResult: false,
+		},
+		{
+			name:           "uid and gid",
+			uidFilter:      []string{"=1000"},
+			gidFilter:      []string{"!=1000"},
+			uid:            1000,
+			gid:            1000,
+			expectedResult: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			trc := newTestTracee(t, Config{})
+			trc.config.Filter.UIDFilter = newUIntFilter(t, tc.uidFilter)
+			trc.config.Filter.GIDFilter = newUIntFilter(t, tc.gidFilter)
+			// the uid of traced events was filtered by the bpf code, so it's filtered for replayed events only
+			trc.replaying = true
+
+			ctx := &bufferdecoder.Context{EventID: events.Openat, Uid: tc.uid, Gid: tc.gid}
+			assert.Equal(t, tc.expectedResult, trc.shouldProcessEvent(ctx, nil))
+		})
+	}
+
+	t.Run("traced events", func(t *testing.T) {
+		trc := newTestTracee(t, Config{})
+		trc.config.Filter.UIDFilter = newUIntFilter(t, []string{"=0"})
+		trc.config.Filter.GIDFilter = newUIntFilter(t, []string{"=0"})
+
+		assert.True(t, trc.shouldProcessEvent(&bufferdecoder.Context{EventID: events.Openat, Uid: 1000}, nil))
+		assert.False(t, trc.shouldProcessEvent(&bufferdecoder.Context{EventID: events.Openat, Gid: 1000}, nil))
+	})
+
+	t.Run("followed descendants", func(t *testing.T) {
+		// descendants of a root process which dropped their privileges are followed by the bpf code
+		trc := newTestTracee(t, Config{})
+		trc.config.Filter.UIDFilter = newUIntFilter(t, []string{"=0"})
+		trc.config.Filter.Follow = true
+		ctx := &bufferdecoder.Context{EventID: events.Openat, Uid: 1000}
+		assert.True(t, trc.shouldProcessEvent(ctx, nil))
+
+		trc.replaying = true
+		assert.True(t, trc.shouldProcessEvent(ctx, nil))
+	})
+}
+
+func Test_shouldProcessEnrichedEvent(t *testing.T) {
+	f, err := ioutil.TempFile("", "Test_shouldProcessEnrichedEvent-*")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("foo bar baz")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	fileHash, err := computeFileHashAtPath(f.Name(), 0)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name           string
+		filter         filters.ArgFilterVal
+		expectedResult bool
+	}{
+		{
+			name:           "no filter on sha256",
+			expectedResult: true,
+		},
+		{
+			name:           "sha256 equals hash",
+			filter:         filters.ArgFilterVal{Equal: []string{fileHash}},
+			expectedResult: true,
+		},
+		{
+			name:           "sha256 equals other hash",
+			filter:         filters.ArgFilterVal{Equal: []string{"deadbeef"}},
+			expectedResult: false,
+		},
+		{
+			name:           "sha256 not equals hash",
+			filter:         filters.ArgFilterVal{NotEqual: []string{fileHash}},
+			expectedResult: false,
+		},
+		{
+			name:           "sha256 not equals other hash",
+			filter:         filters.ArgFilterVal{NotEqual: []string{"deadbeef"}},
+			expectedResult: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			argFilter := &filters.ArgFilter{
+				Filters: make(map[events.ID]map[string]filters.ArgFilterVal),
+				Enabled: true,
+			}
+			if len(tc.filter.Equal) > 0 || len(tc.filter.NotEqual) > 0 {
+				argFilter.Filters[events.SchedProcessExec] = map[string]filters.ArgFilterVal{"sha256": tc.filter}
+			}
+			trc := newTestTracee(t, Config{
+				Filter: &Filter{ArgFilter: argFilter},
+				Output: &OutputConfig{ExecHash: true},
+			})
+
+			event := newExecEvent(t, f.Name())
+			require.NoError(t, trc.processEvent(event))
+
+			arg := events.GetArg(event, "sha256")
+			require.NotNil(t, arg)
+			assert.Equal(t, fileHash, arg.Value)
+			assert.Equal(t, tc.expectedResult, trc.shouldProcessEnrichedEvent(event))
+		})
+	}
+}
+
+func Test_processLostEvents(t *testing.T) {
+	const lostChannelSize = 64
+	const floodFactor = 10
+
+	chanErrors := make(chan error)
+	trc := newTestTracee(t, Config{
+		Output:     &OutputConfig{LostChannelSize: lostChannelSize},
+		ChanErrors: chanErrors,
+	})
+	trc.lostEvChannel = make(chan uint64, trc.config.Output.LostChannelSize)
+
+	// the buffer should absorb a burst of loss reports while nobody is reading them
+	for i := 0; i < lostChannelSize; i++ {
+		select {
+		case trc.lostEvChannel <- 1:
+		default:
+			t.Fatalf("lost events channel blocked after %d reports", i)
+		}
+	}
+
+	go trc.processLostEvents()
+	go func() {
+		for range chanErrors {
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < lostChannelSize*floodFactor; i++ {
+			trc.lostEvChannel <- 1
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out flooding the lost events channel")
+	}
+	assert.Eventually(t, func() bool {
+		return trc.stats.LostEvCount.Read() == lostChannelSize*(floodFactor+1)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func Test_processEvent_captureDir(t *testing.T) {
+	f, err := ioutil.TempFile("", "Test_processEvent_captureDir-*")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	require.NoError(t, f.Close())
+
+	const hostMntns = 4026531840
+
+	testCases := []struct {
+		name        string
+		containerId string
+		mntns       int
+		expectedDir string
+	}{
+		{
+			name:        "container",
+			containerId: "c6b1ea1ab2a0",
+			mntns:       4026532100,
+			expectedDir: "c6b1ea1ab2a0",
+		},
+		{
+			name:        "host",
+			mntns:       hostMntns,
+			expectedDir: "host",
+		},
+		{
+			name:        "no container",
+			mntns:       4026532200,
+			expectedDir: "mntns-4026532200",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			trc := newTestTracee(t, Config{
+				Capture: &CaptureConfig{Exec: true},
+			})
+			trc.hostMntns = hostMntns
+
+			event := newExecEvent(t, f.Name())
+			event.ContainerID = tc.containerId
+			event.MountNS = tc.mntns
+			event.Timestamp = 1
+			require.NoError(t, trc.processEvent(event))
+
+			capturedPath := filepath.Join(trc.outDir.Name(), tc.expectedDir, "exec.1."+filepath.Base(f.Name()))
+			assert.FileExists(t, capturedPath)
+
+			// capturing the same file again is deduplicated within the same directory
+			require.NoError(t, os.Remove(capturedPath))
+			require.NoError(t, trc.processEvent(event))
+			assert.NoFileExists(t, capturedPath)
+			assert.Len(t, trc.capturedFiles, 1)
+			for capturedFileID := range trc.capturedFiles {
+				assert.True(t, strings.HasPrefix(capturedFileID, tc.expectedDir+":"))
+			}
+		})
+	}
+}
+
+func Test_processEvent_captureTimestamp(t *testing.T) {
+	f, err := ioutil.TempFile("", "Test_processEvent_captureTimestamp-*")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	require.NoError(t, f.Close())
+
+	now := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	const monotonicNow = 1000 * int64(time.Second)
+
+	testCases := []struct {
+		name         string
+		relativeTime bool
+		expectedTs   int64
+	}{
+		{
+			name:       "wall time",
+			expectedTs: now.Add(5 * time.Second).UnixNano(),
+		},
+		{
+			name:         "relative time",
+			relativeTime: true,
+			expectedTs:   int64(5 * time.Second),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			trc := newTestTracee(t, Config{
+				Capture: &CaptureConfig{Exec: true},
+				Output:  &OutputConfig{RelativeTime: tc.relativeTime},
+			})
+			clock := utils.NewFakeClock(now, monotonicNow)
+			trc.clock = clock
+			trc.initTimestamps()
+
+			// the bpf code timestamps the exec 5 seconds after tracee was started
+			clock.Advance(5 * time.Second)
+			event := newExecEvent(t, f.Name())
+			event.Timestamp = int(trc.eventTimestamp(uint64(clock.MonotonicNano())))
+			require.NoError(t, trc.processEvent(event))
+
+			assert.Equal(t, tc.expectedTs, int64(event.Timestamp))
+			assert.FileExists(t, filepath.Join(trc.outDir.Name(), "host", fmt.Sprintf("exec.%d.%s", tc.expectedTs, filepath.Base(f.Name()))))
+		})
+	}
+}
+
+func Test_processEvent_parentExecHash(t *testing.T) {
+	f, err := ioutil.TempFile("", "Test_processEvent_parentExecHash-*")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	require.NoError(t, f.Close())
+
+	selfHash, err := computeFileHashAtPath("/proc/self/exe", 0)
+	require.NoError(t, err)
+
+	// a reaped child is a parent which has already exited
+	child := exec.Command("true")
+	require.NoError(t, child.Run())
+
+	testCases := []struct {
+		name         string
+		parentPid    int
+		expectedHash string
+	}{
+		{
+			name:         "live parent",
+			parentPid:    os.Getpid(),
+			expectedHash: selfHash,
+		},
+		{
+			name:         "dead parent",
+			parentPid:    child.Process.Pid,
+			expectedHash: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			trc := newTestTracee(t, Config{
+				Output: &OutputConfig{ExecHash: true, ParentExecHash: true},
+			})
+
+			event := newExecEvent(t, f.Name())
+			event.HostParentProcessID = tc.parentPid
+			require.NoError(t, trc.processEvent(event))
+
+			arg := events.GetArg(event, "parent_sha256")
+			require.NotNil(t, arg)
+			assert.Equal(t, tc.expectedHash, arg.Value)
+			assert.Equal(t, len(event.Args), event.ArgsNum)
+
+			// the parent's executable is hashed once, along with the executed file
+			expectedCached := 1
+			if tc.expectedHash != "" {
+				expectedCached = 2
+			}
+			assert.Equal(t, expectedCached, trc.fileHashes.Len())
+		})
+	}
+}
+
+func Test_processEvent_firstWriteOnly(t *testing.T) {
+	trc := newTestTracee(t, Config{
+		Capture: &CaptureConfig{FileWrite: true, FirstWriteOnly: true},
+	})
+
+	for i := 0; i < 100; i++ {
+		event := &trace.Event{
+			EventID:   int(events.VfsWrite),
+			EventName: "vfs_write",
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/tmp/file"},
+				{ArgMeta: trace.ArgMeta{Name: "dev", Type: "dev_t"}, Value: uint32(1)},
+				{ArgMeta: trace.ArgMeta{Name: "inode", Type: "unsigned long"}, Value: uint64(2)},
+			},
+		}
+		require.NoError(t, trc.processEvent(event))
+	}
+
+	assert.Equal(t, map[string]string{"host/write.dev-1.inode-2": "/tmp/file"}, trc.writtenFiles)
+	assert.Equal(t, 1, trc.indexedWrites.Len())
+}
+
+func Test_processEvent_writeDevice(t *testing.T) {
+	newWriteEvent := func(pathname string, dev uint32) *trace.Event {
+		return &trace.Event{
+			EventID:   int(events.VfsWrite),
+			EventName: "vfs_write",
+			ArgsNum:   3,
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: pathname},
+				{ArgMeta: trace.ArgMeta{Name: "dev", Type: "dev_t"}, Value: dev},
+				{ArgMeta: trace.ArgMeta{Name: "inode", Type: "unsigned long"}, Value: uint64(2)},
+			},
+		}
+	}
+
+	trc := newTestTracee(t, Config{Capture: &CaptureConfig{FileWrite: true}})
+	for _, tc := range []struct {
+		pathname string
+		dev      uint32
+		expected string
+	}{
+		{pathname: "/tmp/file", dev: 8<<20 | 1, expected: "8:1"},
+		{pathname: "/tmp/file", dev: 259<<20 | 70000, expected: "259:70000"},
+		// files without a path aren't captured, but still have a device
+		{pathname: "memfd:payload", dev: 1, expected: "0:1"},
+	} {
+		event := newWriteEvent(tc.pathname, tc.dev)
+		require.NoError(t, trc.processEvent(event))
+		arg := events.GetArg(event, "device")
+		require.NotNil(t, arg)
+		assert.Equal(t, tc.expected, arg.Value)
+		assert.Equal(t, len(event.Args), event.ArgsNum)
+		// the raw arguments are kept
+		assert.Equal(t, tc.dev, events.GetArg(event, "dev").Value)
+		assert.Equal(t, uint64(2), events.GetArg(event, "inode").Value)
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		trc := newTestTracee(t, Config{})
+		event := newWriteEvent("/tmp/file", 1)
+		require.NoError(t, trc.processEvent(event))
+		assert.Nil(t, events.GetArg(event, "device"))
+	})
+}
+
+func Test_processEvent_captureExclusions(t *testing.T) {
+	f, err := ioutil.TempFile("", "Test_processEvent_captureExclusions-*")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	require.NoError(t, f.Close())
+
+	testCases := []struct {
+		name             string
+		captureConfig    CaptureConfig
+		expectedCaptured bool
+	}{
+		{
+			name:             "no exclusions",
+			captureConfig:    CaptureConfig{Exec: true},
+			expectedCaptured: true,
+		},
+		{
+			name:             "excluded comm",
+			captureConfig:    CaptureConfig{Exec: true, ExcludeComms: []string{"containerd-shim*"}},
+			expectedCaptured: false,
+		},
+		{
+			name:             "other comm",
+			captureConfig:    CaptureConfig{Exec: true, ExcludeComms: []string{"runc"}},
+			expectedCaptured: true,
+		},
+		{
+			name:             "excluded path",
+			captureConfig:    CaptureConfig{Exec: true, ExcludePaths: []string{filepath.Dir(f.Name()) + "/*"}},
+			expectedCaptured: false,
+		},
+		{
+			name:             "other path",
+			captureConfig:    CaptureConfig{Exec: true, ExcludePaths: []string{"/usr/sbin/*"}},
+			expectedCaptured: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			captureConfig := tc.captureConfig
+			trc := newTestTracee(t, Config{
+				Capture: &captureConfig,
+				Output:  &OutputConfig{ExecHash: true},
+			})
+
+			event := newExecEvent(t, f.Name())
+			event.ProcessName = "containerd-shim-runc-v2"
+			event.Timestamp = 1
+			require.NoError(t, trc.processEvent(event))
+
+			capturedPath := filepath.Join(trc.outDir.Name(), "host", "exec.1."+filepath.Base(f.Name()))
+			if tc.expectedCaptured {
+				assert.FileExists(t, capturedPath)
+				assert.NotNil(t, events.GetArg(event, "sha256"))
+			} else {
+				assert.NoFileExists(t, capturedPath)
+				assert.Nil(t, events.GetArg(event, "sha256"))
+			}
+		})
+	}
+}
+
+func Test_processEvent_alwaysCapture(t *testing.T) {
+	dir := t.TempDir()
+	alwaysCaptured := filepath.Join(dir, "always")
+	other := filepath.Join(dir, "other")
+	require.NoError(t, ioutil.WriteFile(alwaysCaptured, []byte("always captured binary"), 0755))
+	require.NoError(t, ioutil.WriteFile(other, []byte("other binary"), 0755))
+
+	trc := newTestTracee(t, Config{
+		Capture: &CaptureConfig{Exec: true, AlwaysCapturePaths: []string{filepath.Join(dir, "alw*")}},
+		Output:  &OutputConfig{},
+	})
+	for ts := 1; ts <= 2; ts++ {
+		for _, path := range []string{alwaysCaptured, other} {
+			event := newExecEvent(t, path)
+			event.Timestamp = ts
+			require.NoError(t, trc.processEvent(event))
+		}
+	}
+
+	// matching files are captured on every exec, while other files are captured once until they are modified
+	hostDir := filepath.Join(trc.outDir.Name(), "host")
+	assert.FileExists(t, filepath.Join(hostDir, "exec.1.always"))
+	assert.FileExists(t, filepath.Join(hostDir, "exec.2.always"))
+	assert.FileExists(t, filepath.Join(hostDir, "exec.1.other"))
+	assert.NoFileExists(t, filepath.Join(hostDir, "exec.2.other"))
+	assert.Equal(t, int32(3), trc.stats.CapFileCount.Read())
+}
+
+func Test_processEvents_argTransforms(t *testing.T) {
+	argTransforms := events.ArgTransforms{}
+	argTransforms.Register(events.Socket, "domain", events.DecodeSocketDomain)
+	trc := newTestTracee(t, Config{ArgTransforms: argTransforms})
+
+	in := make(chan *trace.Event, 1)
+	in <- &trace.Event{
+		EventID:   int(events.Socket),
+		EventName: "socket",
+		ArgsNum:   2,
+		Args: []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Name: "domain", Type: "int"}, Value: int32(2)},
+			{ArgMeta: trace.ArgMeta{Name: "type", Type: "int"}, Value: int32(1)},
+		},
+	}
+	close(in)
+
+	out, _ := trc.processEvents(context.Background(), in)
+	event := <-out
+	require.NotNil(t, event)
+	assert.Equal(t, []trace.Argument{
+		{ArgMeta: trace.ArgMeta{Name: "domain", Type: "string"}, Value: "AF_INET"},
+		{ArgMeta: trace.ArgMeta{Name: "type", Type: "int"}, Value: int32(1)},
+	}, event.Args)
+}
+
+func Test_truncateStringArgs(t *testing.T) {
+	testCases := []struct {
+		name      string
+		value     interface{}
+		maxLength int
+		expected  interface{}
+	}{
+		{
+			name:      "under limit",
+			value:     "/usr/bin/ls",
+			maxLength: 20,
+			expected:  "/usr/bin/ls",
+		},
+		{
+			name:      "at limit",
+			value:     "/usr/bin/ls",
+			maxLength: 11,
+			expected:  "/usr/bin/ls",
+		},
+		{
+			name:      "over limit",
+			value:     "/usr/bin/ls",
+			maxLength: 8,
+			expected:  "/usr/bin" + truncatedArgMarker,
+		},
+		{
+			name:      "multibyte under limit",
+			value:     "héllo wörld",
+			maxLength: 20,
+			expected:  "héllo wörld",
+		},
+		{
+			name:      "multibyte at limit",
+			value:     "héllo wörld", // 13 bytes
+			maxLength: 13,
+			expected:  "héllo wörld",
+		},
+		{
+			name:      "multibyte over limit at rune boundary",
+			value:     "héllo wörld",
+			maxLength: 3,
+			expected:  "hé" + truncatedArgMarker,
+		},
+		{
+			name:      "multibyte over limit inside rune",
+			value:     "héllo wörld",
+			maxLength: 2,
+			expected:  "h" + truncatedArgMarker,
+		},
+		{
+			name:      "multibyte over limit inside 4 byte rune",
+			value:     "ab😀cd",
+			maxLength: 5,
+			expected:  "ab" + truncatedArgMarker,
+		},
+		{
+			name:      "non string",
+			value:     []string{"/usr/bin/ls", "-la"},
+			maxLength: 2,
+			expected:  []string{"/usr/bin/ls", "-la"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			event := &trace.Event{
+				Args: []trace.Argument{
+					{ArgMeta: trace.ArgMeta{Name: "arg", Type: "const char*"}, Value: tc.value},
+				},
+			}
+			truncateStringArgs(event, tc.maxLength)
+			assert.Equal(t, tc.expected, event.Args[0].Value)
+		})
+	}
+}
+
+func Test_parseCmdline(t *testing.T) {
+	testCases := []struct {
+		name     string
+		blob     []byte
+		expected []string
+	}{
+		{
+			name:     "empty",
+			blob:     []byte{},
+			expected: []string{},
+		},
+		{
+			name:     "single arg",
+			blob:     []byte("/bin/ls\x00"),
+			expected: []string{"/bin/ls"},
+		},
+		{
+			name:     "many args",
+			blob:     []byte("/bin/ls\x00-l\x00/tmp\x00"),
+			expected: []string{"/bin/ls", "-l", "/tmp"},
+		},
+		{
+			name:     "empty args",
+			blob:     []byte("/bin/echo\x00\x00a\x00\x00"),
+			expected: []string{"/bin/echo", "", "a", ""},
+		},
+		{
+			name:     "only empty arg",
+			blob:     []byte("\x00"),
+			expected: []string{""},
+		},
+		{
+			name:     "unterminated last arg",
+			blob:     []byte("nginx: worker process"),
+			expected: []string{"nginx: worker process"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, parseCmdline(tc.blob))
+		})
+	}
+}
+
+func Test_processEvent_cmdline(t *testing.T) {
+	// a reaped child is a process which has already exited
+	child := exec.Command("true")
+	require.NoError(t, child.Run())
+
+	selfCmdline, err := ioutil.ReadFile("/proc/self/cmdline")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		pid      int
+		expected []string
+	}{
+		{
+			name:     "live process",
+			pid:      os.Getpid(),
+			expected: parseCmdline(selfCmdline),
+		},
+		{
+			name:     "exited process",
+			pid:      child.Process.Pid,
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			trc := newTestTracee(t, Config{
+				Capture: &CaptureConfig{Cmdline: true},
+			})
+
+			event := newExecEvent(t, "/bin/true")
+			event.HostProcessID = tc.pid
+			require.NoError(t, trc.processEvent(event))
+
+			arg := events.GetArg(event, "cmdline")
+			require.NotNil(t, arg)
+			assert.Equal(t, tc.expected, arg.Value)
+			assert.Equal(t, len(event.Args), event.ArgsNum)
+		})
+	}
+}
+
+func Test_processEvent_minimal(t *testing.T) {
+	trc := newTestTracee(t, Config{
+		Capture: &CaptureConfig{Exec: true, Cmdline: true},
+		Output: &OutputConfig{
+			ExecHash:          true,
+			ParentExecHash:    true,
+			SelfDeletedWindow: time.Minute,
+			Ancestry:          3,
+			Minimal:           true,
+		},
+	})
+
+	event := newExecEvent(t, "/bin/true")
+	expectedArgs := append([]trace.Argument{}, event.Args...)
+	require.NoError(t, trc.processEvent(event))
+
+	// no enrichment argument is added, and the executed file isn't hashed or captured
+	assert.Equal(t, expectedArgs, event.Args)
+	assert.Equal(t, len(event.Args), event.ArgsNum)
+	assert.Zero(t, trc.fileHashes.Len())
+	captured, err := ioutil.ReadDir(trc.outDir.Name())
+	require.NoError(t, err)
+	assert.Empty(t, captured)
+	assert.Empty(t, trc.processTree)
+}
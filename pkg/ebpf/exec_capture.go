@@ -0,0 +1,219 @@
+package ebpf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// captureExecFile captures an executed file (with Capture.Exec) and hashes it (with Output.ExecHash), returning its
+// sha256, or an empty string if it isn't hashed or can't be hashed. sourceFilePath is the path of the file through
+// the root of a process in the mount namespace of the event, by which the file is known to be captured (unless it's
+// known by its inode, see execCaptureID), and readFilePath is the path its content is read from
+func (t *Tracee) captureExecFile(event *trace.Event, sourceFilePath string, readFilePath string, filePath string, ctime int64) (string, error) {
+	var capturedPath, capturedHash string
+	var err error
+	captureDir := t.captureDir(event.ContainerID, uint32(event.MountNS))
+	capturedFileID := t.execCaptureID(captureDir, sourceFilePath, readFilePath)
+	fileName := filepath.Base(strings.TrimSuffix(filePath, deletedSuffix))
+	// files which are always captured are captured again as if they never were
+	if t.config.Capture.Exec && MatchFilter(t.config.Capture.AlwaysCapturePaths, filePath) {
+		t.forgetCaptured(capturedFileID)
+	}
+	if t.config.Capture.Exec && !t.captureSkipped() && t.lineageCaptured(event) && !t.fileTooNew(event, ctime) &&
+		t.captureFileType(readFilePath) && !t.captureThrottled(event, capturedFileID, ctime) {
+		destinationDirPath := captureDir
+
+		// create an in-memory profile
+		profileKey := fmt.Sprintf("%s:%d", filepath.Join(destinationDirPath, fmt.Sprintf("exec.%s", fileName)), ctime)
+		if t.config.Capture.Profile {
+			t.updateProfile(profileKey, uint64(event.Timestamp))
+		}
+
+		if t.contentAddressed() {
+			capturedHash, capturedPath, err = t.captureContent(readFilePath, capturedFileID, ctime)
+			if err != nil {
+				return "", err
+			}
+			// the profiled file is captured as its stored content, which may have been stored for another file
+			if t.config.Capture.Profile {
+				t.recordProfiledCapture(profileKey, casPath(capturedHash))
+			}
+		} else {
+			destinationFilePath, err := t.captureDestination(destinationDirPath, "exec", strings.TrimSuffix(filePath, deletedSuffix), event.Timestamp)
+			if err != nil {
+				return "", err
+			}
+			capturedPath, err = t.captureFile(readFilePath, capturedFileID, destinationFilePath, ctime)
+			if err != nil {
+				return "", err
+			}
+			if t.config.Capture.Profile && capturedPath != "" {
+				t.recordProfiledCapture(profileKey, capturedPath)
+			}
+		}
+	}
+
+	var currentHash string
+	if t.config.Output.ExecHash {
+		var hashInfoObj fileExecInfo
+		hashInfoInterface, ok := t.fileHashes.Get(capturedFileID)
+
+		// cast to fileExecInfo
+		if ok {
+			hashInfoObj = hashInfoInterface.(fileExecInfo)
+		}
+		// Check if cache can be used
+		if capturedHash != "" {
+			// the file was hashed while it was captured
+			currentHash = capturedHash
+			t.fileHashes.Add(capturedFileID, fileExecInfo{ctime, currentHash})
+		} else if ok && hashInfoObj.LastCtime == ctime {
+			t.stats.HashCacheHits.Increment()
+			currentHash = hashInfoObj.Hash
+		} else {
+			t.stats.HashCacheMisses.Increment()
+			t.openFiles.acquire(1)
+			currentHash, err = computeFileHashAtPath(readFilePath, t.config.Capture.HashMmapThreshold)
+			t.openFiles.release(1)
+			if err == nil {
+				hashInfoObj = fileExecInfo{ctime, currentHash}
+				t.fileHashes.Add(capturedFileID, hashInfoObj)
+			}
+		}
+	}
+
+	if capturedPath != "" && t.config.Capture.HashXattr {
+		t.storeCapturedFileHash(capturedPath, currentHash)
+	}
+	return currentHash, nil
+}
+
+// shebangSize is the size of the head of a file the kernel reads the #! line of a script from (BINPRM_BUF_SIZE)
+const shebangSize = 256
+
+// interpreterSearchPath is the PATH scripts run by env are resolved by
+var interpreterSearchPath = []string{"/usr/local/sbin", "/usr/local/bin", "/usr/sbin", "/usr/bin", "/sbin", "/bin"}
+
+// readShebang returns the interpreter named by the #! line of a script, or an empty string if the file isn't a
+// script. For scripts run by env (e.g. #!/usr/bin/env python3), it's the name of the program env runs
+func readShebang(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, shebangSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	head = head[:n]
+	if !bytes.HasPrefix(head, []byte("#!")) {
+		return "", nil
+	}
+	line := head[2:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return "", nil
+	}
+	interpreter := fields[0]
+	if filepath.Base(interpreter) == "env" {
+		// skip the options and the variables set by env
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				return field, nil
+			}
+		}
+	}
+	return interpreter, nil
+}
+
+// execCaptureID returns the id by which an executed file is known to be captured and hashed, which is its path
+// through the root of a process, or its device and inode with Capture.DedupByInode, so hardlinks to the same file
+// are captured once (by the name they are first executed by). Files which can't be stated are known by their path
+func (t *Tracee) execCaptureID(captureDir string, sourceFilePath string, readFilePath string) string {
+	if t.config.Capture.DedupByInode {
+		var stat syscall.Stat_t
+		if err := syscall.Stat(readFilePath, &stat); err == nil {
+			// as the devices of the file events, by which the captures of deleted files are forgotten
+			return fmt.Sprintf("%s:exec:dev-%d.inode-%d", captureDir, kernelDev(stat.Dev), stat.Ino)
+		}
+	}
+	return fmt.Sprintf("%s:%s", captureDir, sourceFilePath)
+}
+
+// resolveInterpreter returns the absolute path of an interpreter of a script in the given root, searching the
+// interpreterSearchPath for interpreters named without a path, or an empty string if it can't be resolved
+func resolveInterpreter(root string, interpreter string) string {
+	if filepath.IsAbs(interpreter) {
+		return interpreter
+	}
+	// paths relative to the working directory of the process can't be resolved after the exec
+	if strings.Contains(interpreter, "/") {
+		return ""
+	}
+	for _, dir := range interpreterSearchPath {
+		path := filepath.Join(dir, interpreter)
+		if info, err := os.Stat(root + path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+	}
+	return ""
+}
+
+// captureScriptInterpreter captures and hashes the interpreter of an executed script, read from the given path,
+// adding its path (as a script_interpreter argument) and its sha256 (as an interpreter_sha256 argument, with
+// Output.ExecHash) to the sched_process_exec event. The interpreter is resolved through the root of the given process
+// in the mount namespace of the event. Events of executed files which aren't scripts, or whose interpreter can't be
+// resolved, are left as they are
+func (t *Tracee) captureScriptInterpreter(event *trace.Event, pid uint32, readFilePath string) error {
+	t.openFiles.acquire(1)
+	interpreter, err := readShebang(readFilePath)
+	t.openFiles.release(1)
+	if err != nil || interpreter == "" {
+		return nil
+	}
+	root := fmt.Sprintf("/proc/%d/root", pid)
+	interpreterPath := resolveInterpreter(root, interpreter)
+	if interpreterPath == "" || MatchFilter(t.config.Capture.ExcludePaths, interpreterPath) {
+		return nil
+	}
+	sourceFilePath := root + interpreterPath
+	info, err := os.Stat(sourceFilePath)
+	if err != nil {
+		return nil
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	interpreterHash, err := t.captureExecFile(event, sourceFilePath, sourceFilePath, interpreterPath, stat.Ctim.Nano())
+	if err != nil {
+		return err
+	}
+	event.Args = append(event.Args, trace.Argument{
+		ArgMeta: trace.ArgMeta{Name: "script_interpreter", Type: "const char*"},
+		Value:   interpreterPath,
+	})
+	event.ArgsNum++
+	if t.config.Output.ExecHash {
+		event.Args = append(event.Args, trace.Argument{
+			ArgMeta: trace.ArgMeta{Name: "interpreter_sha256", Type: "const char*"},
+			Value:   interpreterHash,
+		})
+		event.ArgsNum++
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package ebpf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_processEvent_scriptInterpreter(t *testing.T) {
+	sha := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(sum[:])
+	}
+
+	t.Run("shell script", func(t *testing.T) {
+		dir := t.TempDir()
+		interpreter := filepath.Join(dir, "sh")
+		const interpreterContent = "\x7fELF interpreter"
+		require.NoError(t, ioutil.WriteFile(interpreter, []byte(interpreterContent), 0755))
+		script := filepath.Join(dir, "run.sh")
+		scriptContent := "#!" + interpreter + " -e\necho hello\n"
+		require.NoError(t, ioutil.WriteFile(script, []byte(scriptContent), 0755))
+
+		trc := newTestTracee(t, Config{
+			Capture: &CaptureConfig{Exec: true},
+			Output:  &OutputConfig{ExecHash: true},
+		})
+		event := newExecEvent(t, script)
+		event.Timestamp = 1
+		require.NoError(t, trc.processEvent(event))
+
+		assert.Equal(t, sha(scriptContent), events.GetArg(event, "sha256").Value)
+		assert.Equal(t, interpreter, events.GetArg(event, "script_interpreter").Value)
+		assert.Equal(t, sha(interpreterContent), events.GetArg(event, "interpreter_sha256").Value)
+		assert.Equal(t, len(event.Args), event.ArgsNum)
+
+		// both the script and its interpreter are captured
+		captureDir := filepath.Join(trc.outDir.Name(), trc.captureDir(event.ContainerID, uint32(event.MountNS)))
+		assert.FileExists(t, filepath.Join(captureDir, "exec.1.run.sh"))
+		assert.FileExists(t, filepath.Join(captureDir, "exec.1.sh"))
+	})
+
+	t.Run("compiled binary", func(t *testing.T) {
+		executable, err := os.Executable()
+		require.NoError(t, err)
+
+		trc := newTestTracee(t, Config{Output: &OutputConfig{ExecHash: true}})
+		event := newExecEvent(t, executable)
+		require.NoError(t, trc.processEvent(event))
+
+		assert.NotEmpty(t, events.GetArg(event, "sha256").Value)
+		assert.Nil(t, events.GetArg(event, "script_interpreter"))
+		assert.Nil(t, events.GetArg(event, "interpreter_sha256"))
+	})
+}
+
+func Test_processEvent_dedupByInode(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "busybox")
+	require.NoError(t, ioutil.WriteFile(binary, []byte("\x7fELF multi-call binary"), 0755))
+	hardlink := filepath.Join(dir, "sh")
+	require.NoError(t, os.Link(binary, hardlink))
+
+	// executes the binary by its path and then by its hardlink
+	execBoth := func(t *testing.T, capture CaptureConfig) (*Tracee, []string) {
+		trc := newTestTracee(t, Config{Capture: &capture, Output: &OutputConfig{ExecHash: true}})
+		var hashes []string
+		for ts, path := range []string{binary, hardlink} {
+			event := newExecEvent(t, path)
+			event.Timestamp = ts + 1
+			require.NoError(t, trc.processEvent(event))
+			hashes = append(hashes, eventSha256(t, event))
+		}
+		return trc, hashes
+	}
+
+	t.Run("by inode", func(t *testing.T) {
+		trc, hashes := execBoth(t, CaptureConfig{Exec: true, DedupByInode: true})
+		hostDir := filepath.Join(trc.outDir.Name(), "host")
+		assert.FileExists(t, filepath.Join(hostDir, "exec.1.busybox"))
+		assert.NoFileExists(t, filepath.Join(hostDir, "exec.2.sh"))
+		assert.Equal(t, int32(1), trc.stats.CapFileCount.Read())
+		// the hardlink is hashed once as well
+		assert.Equal(t, hashes[0], hashes[1])
+		assert.Equal(t, 1, trc.fileHashes.Len())
+		assert.Equal(t, int32(1), trc.stats.HashCacheHits.Read())
+	})
+
+	t.Run("by path", func(t *testing.T) {
+		trc, hashes := execBoth(t, CaptureConfig{Exec: true})
+		hostDir := filepath.Join(trc.outDir.Name(), "host")
+		assert.FileExists(t, filepath.Join(hostDir, "exec.1.busybox"))
+		assert.FileExists(t, filepath.Join(hostDir, "exec.2.sh"))
+		assert.Equal(t, int32(2), trc.stats.CapFileCount.Read())
+		assert.Equal(t, hashes[0], hashes[1])
+	})
+}
+
+func Test_readShebang(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{name: "interpreter", content: "#!/bin/sh\necho hello\n", expected: "/bin/sh"},
+		{name: "interpreter with argument", content: "#! /bin/bash -eu\n", expected: "/bin/bash"},
+		{name: "env", content: "#!/usr/bin/env python3\n", expected: "python3"},
+		{name: "env with options", content: "#!/usr/bin/env -S LANG=C perl -w\n", expected: "perl"},
+		{name: "no newline", content: "#!/bin/sh", expected: "/bin/sh"},
+		{name: "empty shebang", content: "#!\n", expected: ""},
+		{name: "not a script", content: "\x7fELF\x02\x01\x01", expected: ""},
+		{name: "empty file", content: "", expected: ""},
+	}
+
+	dir := t.TempDir()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, "file")
+			require.NoError(t, ioutil.WriteFile(path, []byte(tc.content), 0644))
+
+			interpreter, err := readShebang(path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, interpreter)
+		})
+	}
+}
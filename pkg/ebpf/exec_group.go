@@ -0,0 +1,69 @@
+package ebpf
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/events/parse"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// execGroupIDLength is the number of bytes of the sha256 kept in an exec group id
+const execGroupIDLength = 8
+
+// execGroupArgSchema is the arguments of exec events used by addExecGroupID
+var execGroupArgSchema = parse.Schema{
+	"pathname": parse.String,
+	"argv":     parse.StringArray,
+}
+
+// addExecGroupID adds the group id of the command of an exec event as an exec_group_id argument, with
+// Output.ExecGroupID. The arguments of the command are its complete command line if captured (see
+// Capture.Cmdline), and else its argv argument, which holds a bounded part of it
+func (t *Tracee) addExecGroupID(event *trace.Event) error {
+	args, err := execGroupArgSchema.Decode(event)
+	if err != nil {
+		return fmt.Errorf("error parsing %s args: %w", event.EventName, err)
+	}
+	argv := args.StringArray("argv")
+	if cmdline := events.GetArg(event, "cmdline"); cmdline != nil {
+		// the command line of processes which already exited can't be read
+		if cmdlineArgs, ok := cmdline.Value.([]string); ok && len(cmdlineArgs) > 0 {
+			argv = cmdlineArgs
+		}
+	}
+	event.Args = append(event.Args, trace.Argument{
+		ArgMeta: trace.ArgMeta{Name: "exec_group_id", Type: "const char*"},
+		Value:   execGroupID(args.String("pathname"), argv),
+	})
+	event.ArgsNum++
+	return nil
+}
+
+// execGroupID returns a stable id of a command, which is the same for executions of the same file with the same
+// arguments, whatever their process and time, to group them into distinct command invocations. The path is
+// canonicalized (e.g. "/usr//bin/./ls" is "/usr/bin/ls"), while the arguments are taken verbatim: their order and
+// whitespace change what the command does, so commands differing in them are of different groups. The path and the
+// arguments are hashed along with their lengths, so splitting the same text into different arguments (e.g. "a b"
+// and "a", "b") changes the id
+func execGroupID(path string, argv []string) string {
+	h := sha256.New()
+	var length [8]byte
+	write := func(s string) {
+		binary.LittleEndian.PutUint64(length[:], uint64(len(s)))
+		h.Write(length[:])
+		h.Write([]byte(s))
+	}
+	if path != "" {
+		path = filepath.Clean(path)
+	}
+	write(path)
+	for _, arg := range argv {
+		write(arg)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:execGroupIDLength])
+}
@@ -0,0 +1,86 @@
+package ebpf
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_execGroupID(t *testing.T) {
+	id := execGroupID("/usr/bin/curl", []string{"curl", "-s", "https://example.com"})
+	assert.Len(t, id, execGroupIDLength*2)
+
+	testCases := []struct {
+		name      string
+		path      string
+		argv      []string
+		sameGroup bool
+	}{
+		{name: "same command", path: "/usr/bin/curl", argv: []string{"curl", "-s", "https://example.com"}, sameGroup: true},
+		{name: "uncanonical path", path: "/usr//bin/./curl", argv: []string{"curl", "-s", "https://example.com"}, sameGroup: true},
+		{name: "different file", path: "/usr/local/bin/curl", argv: []string{"curl", "-s", "https://example.com"}},
+		{name: "different argument", path: "/usr/bin/curl", argv: []string{"curl", "-s", "https://example.org"}},
+		{name: "more arguments", path: "/usr/bin/curl", argv: []string{"curl", "-s", "https://example.com", "-o", "out"}},
+		{name: "reordered arguments", path: "/usr/bin/curl", argv: []string{"curl", "https://example.com", "-s"}},
+		{name: "whitespace", path: "/usr/bin/curl", argv: []string{"curl", "-s ", "https://example.com"}},
+		{name: "joined arguments", path: "/usr/bin/curl", argv: []string{"curl", "-s https://example.com"}},
+		{name: "no arguments", path: "/usr/bin/curl", argv: []string{}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.sameGroup {
+				assert.Equal(t, id, execGroupID(tc.path, tc.argv))
+			} else {
+				assert.NotEqual(t, id, execGroupID(tc.path, tc.argv))
+			}
+		})
+	}
+}
+
+func Test_addExecGroupID(t *testing.T) {
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	newExec := func(t *testing.T, pid int, argv []string) *trace.Event {
+		event := newExecEvent(t, exe)
+		event.HostProcessID = pid
+		event.Args = append(event.Args, trace.Argument{ArgMeta: trace.ArgMeta{Name: "argv", Type: "const char**"}, Value: argv})
+		event.ArgsNum++
+		return event
+	}
+	groupID := func(t *testing.T, event *trace.Event) interface{} {
+		arg := events.GetArg(event, "exec_group_id")
+		require.NotNil(t, arg)
+		return arg.Value
+	}
+
+	trc := newTestTracee(t, Config{Capture: &CaptureConfig{}, Output: &OutputConfig{ExecGroupID: true}})
+	first := newExec(t, 1000, []string{"tool", "--scan", "/tmp"})
+	require.NoError(t, trc.processEvent(first))
+	assert.Equal(t, execGroupID(exe, []string{"tool", "--scan", "/tmp"}), groupID(t, first))
+	assert.Equal(t, 4, first.ArgsNum)
+
+	// the execs of the same command by different processes share their id, unlike the execs of other arguments
+	second := newExec(t, 2000, []string{"tool", "--scan", "/tmp"})
+	require.NoError(t, trc.processEvent(second))
+	assert.Equal(t, groupID(t, first), groupID(t, second))
+	other := newExec(t, 1000, []string{"tool", "--scan", "/var"})
+	require.NoError(t, trc.processEvent(other))
+	assert.NotEqual(t, groupID(t, first), groupID(t, other))
+
+	// the captured command line is complete, so it's preferred over argv
+	trc.config.Capture.Cmdline = true
+	captured := newExec(t, os.Getpid(), []string{"truncated"})
+	require.NoError(t, trc.processEvent(captured))
+	assert.Equal(t, execGroupID(exe, readCmdline(os.Getpid())), groupID(t, captured))
+
+	// unless the process exited before its command line was read
+	exited := newExec(t, 1<<30, []string{"tool", "--scan", "/tmp"})
+	require.NoError(t, trc.processEvent(exited))
+	assert.Equal(t, groupID(t, first), groupID(t, exited))
+
+	assert.Error(t, trc.processEvent(newExecEvent(t, exe)))
+}
@@ -0,0 +1,134 @@
+package ebpf
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/aquasecurity/tracee/pkg/logger"
+)
+
+// memoryRegion is a mapping of a process memory, as of /proc/PID/maps (see proc(5))
+type memoryRegion struct {
+	start uint64
+	end   uint64
+}
+
+// execMemHash returns the sha256 of the executable image of a process in its memory, which is the content of the
+// executable mappings of its executable, in the order of their addresses. Unlike the hash of the executable file, it
+// is the code which actually runs, even if the file was modified or deleted since it was executed (or never was on
+// disk, as with memfd_create files). It returns an empty string if the memory can't be read, e.g. when the process
+// has already exited or reading its memory isn't permitted
+func (t *Tracee) execMemHash(hostPid int) string {
+	t.openFiles.acquire(3)
+	defer t.openFiles.release(3)
+
+	// the mappings of the executable are told by its device and inode, which identify it whatever its path
+	var stat syscall.Stat_t
+	if err := syscall.Stat(fmt.Sprintf("/proc/%d/exe", hostPid), &stat); err != nil {
+		return ""
+	}
+	maps, err := os.Open(fmt.Sprintf("/proc/%d/maps", hostPid))
+	if err != nil {
+		return ""
+	}
+	defer maps.Close()
+	regions, err := parseExecutableMappings(maps, uint64(stat.Dev), stat.Ino)
+	if err != nil {
+		t.log(logger.DebugLevel, "failed reading the executable mappings of a process", "pid", hostPid, "error", err)
+		return ""
+	}
+
+	mem, err := os.Open(fmt.Sprintf("/proc/%d/mem", hostPid))
+	if err != nil {
+		return ""
+	}
+	defer mem.Close()
+	hash, err := hashMemoryRegions(mem, regions)
+	if err != nil {
+		t.log(logger.DebugLevel, "failed hashing the executable image of a process", "pid", hostPid, "error", err)
+		return ""
+	}
+	return hash
+}
+
+// parseExecutableMappings returns the executable mappings of a file, as of the maps of a process. The file is given
+// by its device and inode
+func parseExecutableMappings(maps io.Reader, dev uint64, inode uint64) ([]memoryRegion, error) {
+	var regions []memoryRegion
+	scanner := bufio.NewScanner(maps)
+	for scanner.Scan() {
+		// address perms offset dev inode [pathname]
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		perms := fields[1]
+		if len(perms) < 3 || perms[2] != 'x' {
+			continue
+		}
+		mappedInode, err := strconv.ParseUint(fields[4], 10, 64)
+		if err != nil || mappedInode != inode {
+			continue
+		}
+		devParts := strings.SplitN(fields[3], ":", 2)
+		if len(devParts) != 2 {
+			continue
+		}
+		major, err := strconv.ParseUint(devParts[0], 16, 32)
+		if err != nil {
+			continue
+		}
+		minor, err := strconv.ParseUint(devParts[1], 16, 32)
+		if err != nil {
+			continue
+		}
+		if unix.Mkdev(uint32(major), uint32(minor)) != dev {
+			continue
+		}
+
+		addresses := strings.SplitN(fields[0], "-", 2)
+		if len(addresses) != 2 {
+			return nil, fmt.Errorf("invalid mapping address range %s", fields[0])
+		}
+		start, err := strconv.ParseUint(addresses[0], 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mapping address range %s", fields[0])
+		}
+		end, err := strconv.ParseUint(addresses[1], 16, 64)
+		if err != nil || end < start {
+			return nil, fmt.Errorf("invalid mapping address range %s", fields[0])
+		}
+		regions = append(regions, memoryRegion{start: start, end: end})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("no executable mapping of the executable")
+	}
+	return regions, nil
+}
+
+// hashMemoryRegions returns the sha256 of the content of memory regions, read from the memory of a process
+func hashMemoryRegions(mem io.ReaderAt, regions []memoryRegion) (string, error) {
+	h := sha256.New()
+	for _, region := range regions {
+		n, err := io.Copy(h, io.NewSectionReader(mem, int64(region.start), int64(region.end-region.start)))
+		if err != nil {
+			return "", err
+		}
+		if uint64(n) != region.end-region.start {
+			return "", fmt.Errorf("short read of region %x-%x", region.start, region.end)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
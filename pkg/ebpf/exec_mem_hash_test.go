@@ -0,0 +1,87 @@
+package ebpf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func Test_parseExecutableMappings(t *testing.T) {
+	maps := `55d0c5a00000-55d0c5a04000 r--p 00000000 fd:01 1234                       /usr/bin/victim
+55d0c5a04000-55d0c5a18000 r-xp 00004000 fd:01 1234                       /usr/bin/victim
+55d0c5a18000-55d0c5a20000 r--p 00018000 fd:01 1234                       /usr/bin/victim
+55d0c5a21000-55d0c5a22000 rw-p 00020000 fd:01 1234                       /usr/bin/victim
+55d0c5a22000-55d0c5a23000 r-xp 00000000 fd:01 1234                       /usr/bin/victim (deleted)
+55d0c6b2c000-55d0c6b4d000 rw-p 00000000 00:00 0                          [heap]
+7f1e2a828000-7f1e2a9bd000 r-xp 00028000 fd:01 5678                       /usr/lib/x86_64-linux-gnu/libc.so.6
+7f1e2ab00000-7f1e2ab01000 r-xp 00000000 fd:02 1234                       /mnt/other
+7ffd4b5f1000-7ffd4b5f3000 r-xp 00000000 00:00 0                          [vdso]
+`
+	dev := unix.Mkdev(0xfd, 0x01)
+
+	regions, err := parseExecutableMappings(strings.NewReader(maps), dev, 1234)
+	require.NoError(t, err)
+	assert.Equal(t, []memoryRegion{
+		{start: 0x55d0c5a04000, end: 0x55d0c5a18000},
+		{start: 0x55d0c5a22000, end: 0x55d0c5a23000},
+	}, regions)
+
+	_, err = parseExecutableMappings(strings.NewReader(maps), dev, 9999)
+	assert.EqualError(t, err, "no executable mapping of the executable")
+
+	_, err = parseExecutableMappings(strings.NewReader("55d0c5a04000 r-xp 00004000 fd:01 1234 /usr/bin/victim\n"), dev, 1234)
+	assert.EqualError(t, err, "invalid mapping address range 55d0c5a04000")
+}
+
+func Test_hashMemoryRegions(t *testing.T) {
+	// a synthetic process memory, whose offsets are the addresses of the memory
+	mem, err := os.Create(filepath.Join(t.TempDir(), "mem"))
+	require.NoError(t, err)
+	defer mem.Close()
+	_, err = mem.WriteAt([]byte("\x7fELF header"), 0x1000)
+	require.NoError(t, err)
+	_, err = mem.WriteAt([]byte("text of main"), 0x3000)
+	require.NoError(t, err)
+
+	hash, err := hashMemoryRegions(mem, []memoryRegion{{start: 0x1000, end: 0x100b}, {start: 0x3000, end: 0x300c}})
+	require.NoError(t, err)
+	expected := sha256.Sum256([]byte("\x7fELF headertext of main"))
+	assert.Equal(t, hex.EncodeToString(expected[:]), hash)
+
+	// regions which can't be read entirely aren't hashed
+	_, err = hashMemoryRegions(mem, []memoryRegion{{start: 0x3000, end: 0x4000}})
+	assert.EqualError(t, err, "short read of region 3000-4000")
+}
+
+func Test_processEvent_execMemHash(t *testing.T) {
+	exe, err := os.Executable()
+	require.NoError(t, err)
+
+	trc := newTestTracee(t, Config{Output: &OutputConfig{ExecMemHash: true}})
+	event := newExecEvent(t, exe)
+	require.NoError(t, trc.processEvent(event))
+	arg := events.GetArg(event, "mem_sha256")
+	require.NotNil(t, arg)
+	assert.Len(t, arg.Value, 64)
+	assert.Equal(t, 3, event.ArgsNum)
+	// the image in memory is the same whenever it's hashed
+	assert.Equal(t, arg.Value, trc.execMemHash(os.Getpid()))
+
+	// processes whose memory can't be read have an empty hash
+	dir := t.TempDir()
+	path := filepath.Join(dir, "exited")
+	require.NoError(t, ioutil.WriteFile(path, []byte("exited"), 0755))
+	event = newExecEvent(t, path)
+	event.HostProcessID = 1 << 30
+	require.NoError(t, trc.processEvent(event))
+	assert.Equal(t, "", events.GetArg(event, "mem_sha256").Value)
+}
@@ -0,0 +1,147 @@
+package ebpf
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// capturesFiles checks if any files are captured, whose index follows the renames and deletions of the files
+func (t *Tracee) capturesFiles() bool {
+	return t.config.Capture.FileWrite || t.config.Capture.Exec || t.config.Capture.SharedObjects ||
+		len(t.config.Capture.FileOpenPaths) > 0
+}
+
+// movedPath returns the path of a file after a rename of oldPath (a file or a directory) to newPath, and false if
+// the file wasn't moved by it
+func movedPath(path string, oldPath string, newPath string) (string, bool) {
+	if path == oldPath {
+		return newPath, true
+	}
+	if strings.HasPrefix(path, oldPath+"/") {
+		return newPath + strings.TrimPrefix(path, oldPath), true
+	}
+	return "", false
+}
+
+// processFileRename updates the paths of the captured files moved by a security_inode_rename event (of the files or
+// of a directory containing them), so the index of the captured files follows them: the written files index maps
+// captured writes to the new paths, and moved executed files and shared objects are known to be captured by their
+// new paths
+func (t *Tracee) processFileRename(event *trace.Event) error {
+	args, err := decodeArgs(event)
+	if err != nil {
+		return err
+	}
+	oldPath := args.String("old_path")
+	newPath := args.String("new_path")
+	if oldPath == "" || newPath == "" {
+		return nil
+	}
+
+	// written files are indexed by container only (see processFileWrites)
+	writesDir := t.captureDir(event.ContainerID, 0) + "/"
+	for _, fileName := range t.writtenPaths.below(writesDir, oldPath) {
+		if moved, ok := movedPath(t.writtenFiles[fileName], oldPath, newPath); ok {
+			t.indexWrittenFile(fileName, moved)
+		}
+	}
+
+	// executed files are captured by their path through the root of a process, and shared objects by their path
+	capturesPrefix := t.captureDir(event.ContainerID, uint32(event.MountNS)) + ":"
+	movedIDs := make(map[string]string)
+	for _, capturedFileID := range t.capturedPaths.below(capturesPrefix, oldPath) {
+		pathPrefix, filePath := splitCapturedFileID(strings.TrimPrefix(capturedFileID, capturesPrefix))
+		if moved, ok := movedPath(filePath, oldPath, newPath); ok {
+			movedIDs[capturedFileID] = capturesPrefix + pathPrefix + moved
+		}
+	}
+	for oldID, newID := range movedIDs {
+		ctime := t.capturedFiles[oldID]
+		hash, hashed := t.capturedHashes[oldID]
+		t.forgetCaptured(oldID)
+		t.markCaptured(newID, ctime)
+		if hashed {
+			t.capturedHashes[newID] = hash
+		}
+	}
+	return nil
+}
+
+// splitCapturedFileID splits the id of a captured file (without its capture dir) into the prefix of the path of the
+// file and its path, or returns an empty path for files captured by their inode
+func splitCapturedFileID(id string) (string, string) {
+	if strings.HasPrefix(id, "so:") {
+		return "so:", strings.TrimPrefix(id, "so:")
+	}
+	// executed files are read as /proc/<pid>/root/<path>
+	if strings.HasPrefix(id, "/proc/") {
+		if i := strings.Index(id, "/root/"); i >= 0 {
+			return id[:i+len("/root")], id[i+len("/root"):]
+		}
+	}
+	return "", ""
+}
+
+// indexCaptured adds (or removes) a captured file to the index of the files captured by their path, if it was
+func (t *Tracee) indexCaptured(capturedFileID string, add bool) {
+	capturesPrefix := capturedFileNamespace(capturedFileID) + ":"
+	_, filePath := splitCapturedFileID(strings.TrimPrefix(capturedFileID, capturesPrefix))
+	if filePath == "" {
+		return
+	}
+	if add {
+		t.capturedPaths.add(capturesPrefix, filePath, capturedFileID)
+	} else {
+		t.capturedPaths.remove(capturesPrefix, filePath, capturedFileID)
+	}
+}
+
+// indexWrittenFile maps a captured written file to the path of the file it was written to, in the written files
+// index and by its path
+func (t *Tracee) indexWrittenFile(fileName string, filePath string) {
+	writesDir := path.Dir(fileName) + "/"
+	if oldPath, ok := t.writtenFiles[fileName]; ok {
+		t.writtenPaths.remove(writesDir, oldPath, fileName)
+	}
+	t.writtenFiles[fileName] = filePath
+	t.writtenPaths.add(writesDir, filePath, fileName)
+}
+
+// processFileUnlink adds a captured argument to a security_inode_unlink event, telling if the deleted file was
+// captured (by its inode as a written, opened or executed file, or by its path as an executed file or a shared
+// object), from which a file_deleted event is derived. The captures of the deleted file are forgotten, as a new file
+// at its path or inode is a different file, while the written files index keeps mapping the captured writes to their
+// path
+func (t *Tracee) processFileUnlink(event *trace.Event) error {
+	args, err := decodeArgs(event)
+	if err != nil {
+		return err
+	}
+	pathname := args.String("pathname")
+	dev := args.Uint32("dev")
+	inode := args.Uint64("inode")
+
+	_, captured := t.writtenFiles[fmt.Sprintf("%s/write.dev-%d.inode-%d", t.captureDir(event.ContainerID, 0), dev, inode)]
+
+	capturesPrefix := t.captureDir(event.ContainerID, uint32(event.MountNS)) + ":"
+	capturedFileIDs := append(t.capturedPaths.at(capturesPrefix, pathname),
+		fmt.Sprintf("%sopen:dev-%d.inode-%d", capturesPrefix, dev, inode),
+		fmt.Sprintf("%sexec:dev-%d.inode-%d", capturesPrefix, dev, inode),
+	)
+	for _, capturedFileID := range capturedFileIDs {
+		if _, ok := t.capturedFiles[capturedFileID]; ok {
+			captured = true
+			t.forgetCaptured(capturedFileID)
+		}
+	}
+
+	event.Args = append(event.Args, trace.Argument{
+		ArgMeta: trace.ArgMeta{Name: "captured", Type: "bool"},
+		Value:   captured,
+	})
+	event.ArgsNum++
+	return nil
+}
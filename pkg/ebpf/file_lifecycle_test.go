@@ -0,0 +1,135 @@
+package ebpf
+
+import (
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/events/derive"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRenameEvent(oldPath string, newPath string) *trace.Event {
+	return &trace.Event{
+		EventID:   int(events.SecurityInodeRename),
+		EventName: "security_inode_rename",
+		MountNS:   1,
+		ArgsNum:   2,
+		Args: []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Name: "old_path", Type: "const char*"}, Value: oldPath},
+			{ArgMeta: trace.ArgMeta{Name: "new_path", Type: "const char*"}, Value: newPath},
+		},
+	}
+}
+
+func newUnlinkEvent(pathname string, dev uint32, inode uint64) *trace.Event {
+	return &trace.Event{
+		EventID:   int(events.SecurityInodeUnlink),
+		EventName: "security_inode_unlink",
+		MountNS:   1,
+		ArgsNum:   4,
+		Args: []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: pathname},
+			{ArgMeta: trace.ArgMeta{Name: "inode", Type: "unsigned long"}, Value: inode},
+			{ArgMeta: trace.ArgMeta{Name: "dev", Type: "dev_t"}, Value: dev},
+			{ArgMeta: trace.ArgMeta{Name: "ctime", Type: "u64"}, Value: uint64(1000)},
+		},
+	}
+}
+
+func Test_processFileRename(t *testing.T) {
+	trc := newTestTracee(t, Config{Capture: &CaptureConfig{FileWrite: true, Exec: true, SharedObjects: true}})
+	trc.indexWrittenFile("host/write.dev-1.inode-10", "/tmp/build/out.bin")
+	trc.indexWrittenFile("host/write.dev-1.inode-11", "/tmp/other")
+	trc.indexWrittenFile("ab356bc4dd554/write.dev-1.inode-12", "/tmp/build/out.bin")
+	trc.markCaptured("host:/proc/42/root/tmp/build/tool", 100)
+	trc.markCaptured("host:so:/tmp/build/lib.so", 200)
+	trc.capturedHashes["host:so:/tmp/build/lib.so"] = "6e5c2b1d"
+	trc.markCaptured("host:open:dev-1.inode-13", 300)
+
+	// a directory containing captured files is moved
+	require.NoError(t, trc.processEvent(newRenameEvent("/tmp/build", "/opt/release")))
+
+	assert.Equal(t, map[string]string{
+		"host/write.dev-1.inode-10": "/opt/release/out.bin",
+		"host/write.dev-1.inode-11": "/tmp/other",
+		// files of other containers aren't moved
+		"ab356bc4dd554/write.dev-1.inode-12": "/tmp/build/out.bin",
+	}, trc.writtenFiles)
+	assert.Equal(t, map[string]int64{
+		"host:/proc/42/root/opt/release/tool": 100,
+		"host:so:/opt/release/lib.so":         200,
+		"host:open:dev-1.inode-13":            300,
+	}, trc.capturedFiles)
+	assert.Equal(t, map[string]string{"host:so:/opt/release/lib.so": "6e5c2b1d"}, trc.capturedHashes)
+
+	// a file is renamed, while a file whose path only starts with its path isn't
+	trc.indexWrittenFile("host/write.dev-1.inode-14", "/opt/release/out.bin.bak")
+	require.NoError(t, trc.processEvent(newRenameEvent("/opt/release/out.bin", "/opt/release/app")))
+	assert.Equal(t, "/opt/release/app", trc.writtenFiles["host/write.dev-1.inode-10"])
+	assert.Equal(t, "/opt/release/out.bin.bak", trc.writtenFiles["host/write.dev-1.inode-14"])
+
+	// moved files are indexed by their new paths, and no longer by the old ones
+	require.NoError(t, trc.processEvent(newRenameEvent("/tmp/build", "/tmp/stale")))
+	require.NoError(t, trc.processEvent(newRenameEvent("/opt", "/srv")))
+	assert.Equal(t, "/srv/release/app", trc.writtenFiles["host/write.dev-1.inode-10"])
+	assert.Equal(t, "/srv/release/out.bin.bak", trc.writtenFiles["host/write.dev-1.inode-14"])
+	assert.Equal(t, map[string]int64{
+		"host:/proc/42/root/srv/release/tool": 100,
+		"host:so:/srv/release/lib.so":         200,
+		"host:open:dev-1.inode-13":            300,
+	}, trc.capturedFiles)
+}
+
+func Test_processFileUnlink(t *testing.T) {
+	trc := newTestTracee(t, Config{Capture: &CaptureConfig{FileWrite: true, Exec: true, FileOpenPaths: []string{"/run/*"}}})
+	trc.indexWrittenFile("host/write.dev-1.inode-10", "/tmp/dropper")
+	trc.markCaptured("host:/proc/42/root/tmp/payload", 100)
+	trc.markCaptured("host:open:dev-1.inode-12", 200)
+	trc.markCaptured("host:exec:dev-1.inode-14", 300)
+	deriveFileDeleted := derive.FileDeleted()
+
+	testCases := []struct {
+		name     string
+		event    *trace.Event
+		captured bool
+	}{
+		{name: "written file", event: newUnlinkEvent("/tmp/dropper", 1, 10), captured: true},
+		{name: "executed file", event: newUnlinkEvent("/tmp/payload", 1, 11), captured: true},
+		{name: "opened file", event: newUnlinkEvent("/run/secrets/token", 1, 12), captured: true},
+		{name: "file which wasn't captured", event: newUnlinkEvent("/tmp/scratch", 1, 13)},
+		{name: "executed file by inode", event: newUnlinkEvent("/usr/local/bin/agent", 1, 14), captured: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, trc.processEvent(tc.event))
+			arg := events.GetArg(tc.event, "captured")
+			require.NotNil(t, arg)
+			assert.Equal(t, tc.captured, arg.Value)
+			assert.Equal(t, len(tc.event.Args), tc.event.ArgsNum)
+
+			derived, errs := deriveFileDeleted(*tc.event)
+			require.Empty(t, errs)
+			if !tc.captured {
+				assert.Empty(t, derived)
+				return
+			}
+			require.Len(t, derived, 1)
+			assert.Equal(t, "file_deleted", derived[0].EventName)
+			assert.Equal(t, tc.event.Args[0].Value, events.GetArg(&derived[0], "pathname").Value)
+			assert.Equal(t, tc.event.Args[1].Value, events.GetArg(&derived[0], "inode").Value)
+		})
+	}
+
+	// the captures of deleted files are forgotten, while the index of written files keeps them
+	assert.Empty(t, trc.capturedFiles)
+	assert.Equal(t, "/tmp/dropper", trc.writtenFiles["host/write.dev-1.inode-10"])
+
+	t.Run("no captures", func(t *testing.T) {
+		trc := newTestTracee(t, Config{})
+		event := newUnlinkEvent("/tmp/dropper", 1, 10)
+		require.NoError(t, trc.processEvent(event))
+		assert.Nil(t, events.GetArg(event, "captured"))
+	})
+}
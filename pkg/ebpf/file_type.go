@@ -0,0 +1,83 @@
+package ebpf
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// The types of captured files, as told by their magic bytes
+const (
+	FileTypeELF    = "elf"
+	FileTypeScript = "script"
+	FileTypePE     = "pe"
+	// FileTypeOther is the type of files of no known magic bytes
+	FileTypeOther = "other"
+)
+
+// fileMagics are the magic bytes the head of a file starts with, by the type of the file
+var fileMagics = []struct {
+	magic    []byte
+	fileType string
+}{
+	{magic: []byte("\x7fELF"), fileType: FileTypeELF},
+	{magic: []byte("#!"), fileType: FileTypeScript},
+	{magic: []byte("MZ"), fileType: FileTypePE},
+}
+
+// fileMagicSize is the size of the head of a file read to tell its type
+const fileMagicSize = 4
+
+// isFileType checks if a file type is one of the types told by detectFileType
+func isFileType(fileType string) bool {
+	if fileType == FileTypeOther {
+		return true
+	}
+	for _, m := range fileMagics {
+		if m.fileType == fileType {
+			return true
+		}
+	}
+	return false
+}
+
+// detectFileType returns the type of a file by the magic bytes it starts with, or FileTypeOther
+func detectFileType(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, fileMagicSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	for _, m := range fileMagics {
+		if bytes.HasPrefix(head[:n], m.magic) {
+			return m.fileType, nil
+		}
+	}
+	return FileTypeOther, nil
+}
+
+// captureFileType checks if an executed file is of a type captured by Capture.FileTypes, read from the given path.
+// Files whose type can't be told aren't captured when types are given
+func (t *Tracee) captureFileType(readFilePath string) bool {
+	if len(t.config.Capture.FileTypes) == 0 {
+		return true
+	}
+	t.openFiles.acquire(1)
+	fileType, err := detectFileType(readFilePath)
+	t.openFiles.release(1)
+	if err != nil {
+		return false
+	}
+	for _, captured := range t.config.Capture.FileTypes {
+		if captured == fileType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package ebpf
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_detectFileType(t *testing.T) {
+	testCases := []struct {
+		name         string
+		content      string
+		expectedType string
+	}{
+		{name: "elf", content: "\x7fELF\x02\x01\x01", expectedType: FileTypeELF},
+		{name: "script", content: "#!/bin/sh\necho hi\n", expectedType: FileTypeScript},
+		{name: "pe", content: "MZ\x90\x00\x03\x00", expectedType: FileTypePE},
+		{name: "other", content: "plain data", expectedType: FileTypeOther},
+		{name: "shorter than a magic", content: "\x7fE", expectedType: FileTypeOther},
+		{name: "empty", content: "", expectedType: FileTypeOther},
+	}
+	dir := t.TempDir()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name)
+			require.NoError(t, ioutil.WriteFile(path, []byte(tc.content), 0755))
+			fileType, err := detectFileType(path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedType, fileType)
+		})
+	}
+}
+
+func Test_processEvent_captureFileTypes(t *testing.T) {
+	dir := t.TempDir()
+	elfPath := filepath.Join(dir, "binary")
+	pePath := filepath.Join(dir, "binary.exe")
+	require.NoError(t, ioutil.WriteFile(elfPath, []byte("\x7fELF\x02\x01\x01 elf binary"), 0755))
+	require.NoError(t, ioutil.WriteFile(pePath, []byte("MZ\x90\x00 pe binary"), 0755))
+
+	trc := newTestTracee(t, Config{
+		Capture: &CaptureConfig{Exec: true, FileTypes: []string{FileTypeELF}},
+		Output:  &OutputConfig{ExecHash: true},
+	})
+	elfEvent := newExecEvent(t, elfPath)
+	elfEvent.Timestamp = 1
+	require.NoError(t, trc.processEvent(elfEvent))
+	peEvent := newExecEvent(t, pePath)
+	peEvent.Timestamp = 2
+	require.NoError(t, trc.processEvent(peEvent))
+
+	hostDir := filepath.Join(trc.outDir.Name(), "host")
+	assert.FileExists(t, filepath.Join(hostDir, "exec.1.binary"))
+	assert.NoFileExists(t, filepath.Join(hostDir, "exec.2.binary.exe"))
+	assert.Equal(t, int32(1), trc.stats.CapFileCount.Read())
+	// files of other types are still hashed
+	assert.NotEmpty(t, events.GetArg(peEvent, "sha256").Value)
+}
@@ -12,6 +12,7 @@ import (
 type Filter struct {
 	EventsToTrace     []events.ID
 	UIDFilter         *filters.UIntFilter
+	GIDFilter         *filters.UIntFilter // filtered in userspace only
 	PIDFilter         *filters.UIntFilter
 	NewPidFilter      *filters.BoolFilter
 	MntNSFilter       *filters.UIntFilter
@@ -22,8 +23,14 @@ type Filter struct {
 	NewContFilter     *filters.BoolFilter
 	ContIDFilter      *filters.ContIDFilter
 	RetFilter         *filters.RetFilter
+	ArgnumFilter      *filters.ArgnumFilter
 	ArgFilter         *filters.ArgFilter
+	ExprFilter        *filters.ExprFilter
 	ProcessTreeFilter *filters.ProcessTreeFilter
+	BinaryFilter      *filters.BinaryFilter // filtered in the bpf code only
+	TimeFilter        *filters.TimeFilter   // filtered in userspace only
+	FirstEventFilter  *filters.FirstEventFilter
+	QuietExecFilter   *filters.QuietExecFilter
 	Follow            bool
 	NetFilter         *NetIfaces
 }
@@ -0,0 +1,58 @@
+package ebpf
+
+import (
+	gocontext "context"
+	"fmt"
+
+	"github.com/aquasecurity/tracee/pkg/logger"
+)
+
+// fileHashesCacheSize is the number of executed files whose hash is cached
+const fileHashesCacheSize = 1024
+
+// logHashCacheStats logs the utilization and the hit ratio of the file hashes cache every configured interval,
+// until the context is cancelled. Hits and misses are counted over each interval, so the ratio follows changes in
+// the workload
+func (t *Tracee) logHashCacheStats(ctx gocontext.Context) {
+	ticker := t.clock.NewTicker(t.config.Capture.HashCacheStatsInterval)
+	defer ticker.Stop()
+
+	var lastHits, lastMisses int32
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			hits, misses := t.stats.HashCacheHits.Read(), t.stats.HashCacheMisses.Read()
+			t.logHashCacheUsage(ctx, hits-lastHits, misses-lastMisses)
+			lastHits, lastMisses = hits, misses
+		}
+	}
+}
+
+// logHashCacheUsage logs the stats of the file hashes cache at info level. Without a logger, info diagnostics are
+// dropped, so the stats are reported to the errors channel instead, as they were asked for
+func (t *Tracee) logHashCacheUsage(ctx gocontext.Context, hits int32, misses int32) {
+	entries := t.fileHashes.Len()
+	missRatio := 0
+	if lookups := hits + misses; lookups > 0 {
+		missRatio = int(misses) * 100 / int(lookups)
+	}
+	if t.config.Logger == nil {
+		err := fmt.Errorf("file hashes cache stats: entries=%d capacity=%d utilization=%d%% hits=%d misses=%d miss_ratio=%d%%",
+			entries, fileHashesCacheSize, entries*100/fileHashesCacheSize, hits, misses, missRatio)
+		select {
+		case t.config.ChanErrors <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	t.log(logger.InfoLevel, "file hashes cache stats",
+		"entries", entries,
+		"capacity", fileHashesCacheSize,
+		"utilization", fmt.Sprintf("%d%%", entries*100/fileHashesCacheSize),
+		"hits", hits,
+		"misses", misses,
+		"miss_ratio", fmt.Sprintf("%d%%", missRatio),
+	)
+}
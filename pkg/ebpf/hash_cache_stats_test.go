@@ -0,0 +1,89 @@
+package ebpf
+
+import (
+	gocontext "context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_logHashCacheStats(t *testing.T) {
+	recorder := newRecordingLogger()
+	trc := newTestTracee(t, Config{
+		Capture: &CaptureConfig{HashCacheStatsInterval: time.Minute},
+		Logger:  recorder.logger,
+	})
+	clock := utils.NewFakeClock(time.Unix(1000, 0), 0)
+	trc.clock = clock
+
+	for i := 0; i < 256; i++ {
+		trc.fileHashes.Add(fmt.Sprintf("inode:1:%d", i), fileExecInfo{})
+	}
+	trc.stats.HashCacheHits.Increment(1)
+	trc.stats.HashCacheMisses.Increment(9)
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	done := make(chan struct{})
+	go func() {
+		trc.logHashCacheStats(ctx)
+		close(done)
+	}()
+
+	// the clock is advanced until the ticker, which is created concurrently, fires
+	assert.Eventually(t, func() bool {
+		clock.Advance(time.Minute)
+		return len(recorder.logged()) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	entry := recorder.logged()[0]
+	assert.Equal(t, logger.InfoLevel, entry.level)
+	assert.Equal(t, "file hashes cache stats", entry.msg)
+	assert.Equal(t, int64(256), entry.fields["entries"])
+	assert.Equal(t, "25%", entry.fields["utilization"])
+	assert.Equal(t, int32(1), entry.fields["hits"])
+	assert.Equal(t, int32(9), entry.fields["misses"])
+	assert.Equal(t, "90%", entry.fields["miss_ratio"])
+}
+
+func Test_logHashCacheStats_noLogger(t *testing.T) {
+	chanErrors := make(chan error, 1)
+	trc := newTestTracee(t, Config{
+		Capture:    &CaptureConfig{HashCacheStatsInterval: time.Minute},
+		ChanErrors: chanErrors,
+	})
+	clock := utils.NewFakeClock(time.Unix(1000, 0), 0)
+	trc.clock = clock
+
+	trc.fileHashes.Add("inode:1:1", fileExecInfo{})
+	trc.stats.HashCacheHits.Increment(1)
+	trc.stats.HashCacheMisses.Increment(9)
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	done := make(chan struct{})
+	go func() {
+		trc.logHashCacheStats(ctx)
+		close(done)
+	}()
+
+	// the stats are reported along the errors, rather than dropped as info diagnostics without a logger
+	var err error
+	assert.Eventually(t, func() bool {
+		clock.Advance(time.Minute)
+		select {
+		case err = <-chanErrors:
+			return true
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	assert.EqualError(t, err, "file hashes cache stats: entries=1 capacity=1024 utilization=0% hits=1 misses=9 miss_ratio=90%")
+}
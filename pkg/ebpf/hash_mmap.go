@@ -0,0 +1,53 @@
+package ebpf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"runtime/debug"
+
+	"golang.org/x/sys/unix"
+)
+
+// mmapHashWindow is the size of the file region mapped at once while hashing, so hashing a huge file doesn't map
+// all of it
+const mmapHashWindow = 1 << 26
+
+// computeFileHashMmap hashes a file of the given size by mapping it to memory, one window at a time. Accessing a
+// mapping beyond the end of its file faults, so a file truncated while it is hashed returns an error instead of
+// crashing tracee
+func computeFileHashMmap(file *os.File, size int64) (fileHash string, err error) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("error hashing mapped file %s: %v", file.Name(), r)
+		}
+	}()
+
+	h := sha256.New()
+	for offset := int64(0); offset < size; offset += mmapHashWindow {
+		length := size - offset
+		if length > mmapHashWindow {
+			length = mmapHashWindow
+		}
+		if err := hashMappedWindow(h, file, offset, int(length)); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashMappedWindow maps a region of a file and writes it to the hash
+func hashMappedWindow(h hash.Hash, file *os.File, offset int64, length int) error {
+	data, err := unix.Mmap(int(file.Fd()), offset, length, unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return fmt.Errorf("error mapping file %s: %v", file.Name(), err)
+	}
+	defer unix.Munmap(data)
+	// the advice only affects the read-ahead, so failing to give it isn't an error
+	_ = unix.Madvise(data, unix.MADV_SEQUENTIAL)
+	h.Write(data)
+	return nil
+}
@@ -0,0 +1,94 @@
+package ebpf
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeRandomFile creates a temporary file of the given size with random content
+func writeRandomFile(t testing.TB, size int) *os.File {
+	f, err := ioutil.TempFile("", "Test_computeFileHash-*")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		f.Close()
+		os.Remove(f.Name())
+	})
+
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	_, err = f.Write(data)
+	require.NoError(t, err)
+	_, err = f.Seek(0, 0)
+	require.NoError(t, err)
+	return f
+}
+
+func Test_computeFileHashMmap(t *testing.T) {
+	pageSize := os.Getpagesize()
+	testCases := []struct {
+		name string
+		size int
+	}{
+		{name: "single byte", size: 1},
+		{name: "page", size: pageSize},
+		{name: "page and a byte", size: pageSize + 1},
+		{name: "window", size: mmapHashWindow},
+		{name: "many windows", size: 2*mmapHashWindow + pageSize + 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := writeRandomFile(t, tc.size)
+
+			streamHash, err := computeFileHash(f, 0)
+			require.NoError(t, err)
+			mmapHash, err := computeFileHashMmap(f, int64(tc.size))
+			require.NoError(t, err)
+			assert.Equal(t, streamHash, mmapHash)
+
+			// a file over the threshold is mapped, and gets the same hash
+			thresholdHash, err := computeFileHashAtPath(f.Name(), int64(tc.size))
+			require.NoError(t, err)
+			assert.Equal(t, streamHash, thresholdHash)
+		})
+	}
+}
+
+func Test_computeFileHashMmap_truncated(t *testing.T) {
+	pageSize := os.Getpagesize()
+	f := writeRandomFile(t, pageSize)
+
+	// hashing the file as if it was longer (as when it is truncated while hashed) faults when accessing the pages
+	// after its end
+	_, err := computeFileHashMmap(f, int64(3*pageSize))
+	assert.Error(t, err)
+}
+
+func Benchmark_computeFileHash(b *testing.B) {
+	f := writeRandomFile(b, 4*mmapHashWindow)
+
+	b.Run("stream", func(b *testing.B) {
+		b.SetBytes(4 * mmapHashWindow)
+		for i := 0; i < b.N; i++ {
+			if _, err := f.Seek(0, 0); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := computeFileHash(f, 0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("mmap", func(b *testing.B) {
+		b.SetBytes(4 * mmapHashWindow)
+		for i := 0; i < b.N; i++ {
+			if _, err := computeFileHashMmap(f, 4*mmapHashWindow); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package ebpf
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// hashXattr is the extended attribute holding the sha256 of a captured file
+const hashXattr = "user.tracee.sha256"
+
+// storeCapturedFileHash sets the sha256 of a file captured in the output directory as its extended attribute, so
+// the file can be verified on its own. The file is hashed if no hash is given. Output directories on filesystems
+// without extended attributes are silently skipped
+func (t *Tracee) storeCapturedFileHash(fileName string, fileHash string) {
+	f, err := t.openFiles.openAt(t.outDir, fileName, os.O_RDONLY, 0)
+	if err != nil {
+		t.handleError(err, "capture_file", fileName)
+		return
+	}
+	defer f.Close()
+
+	if fileHash == "" {
+		fileHash, err = t.hashCapturedFile(f.File, fileName)
+		if err != nil {
+			t.handleError(err, "capture_file", fileName)
+			return
+		}
+	}
+
+	err = unix.Fsetxattr(int(f.Fd()), hashXattr, []byte(fileHash), 0)
+	if err == unix.EOPNOTSUPP {
+		return
+	}
+	if err != nil {
+		t.handleError(fmt.Errorf("error storing hash of captured file %s: %v", fileName, err), "capture_file", fileName)
+		return
+	}
+	// compressed files are verified by their decompressed content
+	if _, ok := t.compressedCapture(fileName); ok {
+		if err := unix.Fsetxattr(int(f.Fd()), compressionXattr, []byte(t.config.Capture.Compression), 0); err != nil {
+			t.handleError(fmt.Errorf("error storing compression of captured file %s: %v", fileName, err), "capture_file", fileName)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package ebpf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func Test_processEvent_hashXattr(t *testing.T) {
+	f, err := ioutil.TempFile("", "Test_processEvent_hashXattr-*")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("captured executable")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	expectedHash, err := computeFileHashAtPath(f.Name(), 0)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		execHash bool
+	}{
+		{
+			name:     "with exec hash",
+			execHash: true,
+		},
+		{
+			name: "without exec hash",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			trc := newTestTracee(t, Config{
+				Capture: &CaptureConfig{Exec: true, HashXattr: true},
+				Output:  &OutputConfig{ExecHash: tc.execHash},
+			})
+			if err := unix.Setxattr(trc.outDir.Name(), "user.tracee.test", []byte("1"), 0); err == unix.EOPNOTSUPP {
+				t.Skip("the output directory doesn't support extended attributes")
+			}
+
+			event := newExecEvent(t, f.Name())
+			event.Timestamp = 1
+			require.NoError(t, trc.processEvent(event))
+
+			capturedPath := filepath.Join(trc.outDir.Name(), "host", "exec.1."+filepath.Base(f.Name()))
+			value := make([]byte, 64)
+			n, err := unix.Getxattr(capturedPath, hashXattr, value)
+			require.NoError(t, err)
+			assert.Equal(t, expectedHash, string(value[:n]))
+
+			capturedHash, err := computeFileHashAtPath(capturedPath, 0)
+			require.NoError(t, err)
+			assert.Equal(t, capturedHash, string(value[:n]))
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package ebpf
+
+import (
+	gocontext "context"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// emitHeartbeats emits a heartbeat event every configured interval, until the context is cancelled. Heartbeats run
+// on a ticker of their own, so they are emitted while no kernel events come. They are sent to the output directly,
+// as they don't describe any traced activity to process
+func (t *Tracee) emitHeartbeats(ctx gocontext.Context) {
+	ticker := t.clock.NewTicker(t.config.Output.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			select {
+			case t.config.ChanEvents <- t.heartbeatEvent():
+				t.stats.EventCount.Increment()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// heartbeatEvent creates a heartbeat event with the current stats: the number of events emitted before it, the
+// number of events lost (in the events, file writes and network perf buffers) and the uptime of tracee in
+// nanoseconds
+func (t *Tracee) heartbeatEvent() trace.Event {
+	now := t.clock.MonotonicNano()
+	lost := t.stats.LostEvCount.Read() + t.stats.LostWrCount.Read() + t.stats.LostNtCount.Read()
+	def := events.Definitions.Get(events.Heartbeat)
+	return trace.Event{
+		Timestamp:   int(t.eventTimestamp(uint64(now))),
+		ProcessName: "tracee-ebpf",
+		EventID:     int(events.Heartbeat),
+		EventName:   def.Name,
+		ArgsNum:     3,
+		Args: []trace.Argument{
+			{ArgMeta: def.Params[0], Value: uint64(t.stats.EventCount.Read())},
+			{ArgMeta: def.Params[1], Value: uint64(lost)},
+			{ArgMeta: def.Params[2], Value: uint64(now) - t.startTime},
+		},
+	}
+}
@@ -0,0 +1,82 @@
+package ebpf
+
+import (
+	gocontext "context"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_emitHeartbeats(t *testing.T) {
+	trc := newTestTracee(t, Config{
+		Output:     &OutputConfig{HeartbeatInterval: 10 * time.Second},
+		ChanEvents: make(chan trace.Event, 10),
+	})
+	clock := utils.NewFakeClock(time.Unix(1000, 0), 5*int64(time.Second))
+	trc.clock = clock
+	trc.initTimestamps()
+	trc.stats.EventCount.Increment(42)
+	trc.stats.LostEvCount.Increment(3)
+	trc.stats.LostWrCount.Increment(2)
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	done := make(chan struct{})
+	go func() {
+		trc.emitHeartbeats(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// the ticker is created concurrently, so the clock is advanced by an interval until the first heartbeat
+	var heartbeat trace.Event
+	require.Eventually(t, func() bool {
+		clock.Advance(10 * time.Second)
+		select {
+		case heartbeat = <-trc.config.ChanEvents:
+			return true
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+	// the clock may have been advanced again before the first heartbeat was received
+	time.Sleep(50 * time.Millisecond)
+	for len(trc.config.ChanEvents) > 0 {
+		heartbeat = <-trc.config.ChanEvents
+	}
+	assert.Equal(t, int(events.Heartbeat), heartbeat.EventID)
+	assert.Equal(t, "heartbeat", heartbeat.EventName)
+	eventsCount := events.GetArg(&heartbeat, "events_count").Value.(uint64)
+	assert.GreaterOrEqual(t, eventsCount, uint64(42))
+	assert.Equal(t, uint64(5), events.GetArg(&heartbeat, "lost_events").Value)
+	uptime := events.GetArg(&heartbeat, "uptime").Value.(uint64)
+	assert.Zero(t, uptime%uint64(10*time.Second))
+	assert.Equal(t, int32(eventsCount+1), trc.stats.EventCount.Read())
+
+	// no heartbeat is emitted before the interval elapses
+	clock.Advance(5 * time.Second)
+	select {
+	case event := <-trc.config.ChanEvents:
+		t.Fatalf("unexpected heartbeat before the interval elapsed: %v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the following heartbeats report the stats as of their interval
+	clock.Advance(5 * time.Second)
+	select {
+	case heartbeat = <-trc.config.ChanEvents:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no heartbeat was emitted once the interval elapsed")
+	}
+	assert.Equal(t, eventsCount+1, events.GetArg(&heartbeat, "events_count").Value)
+	assert.Equal(t, uptime+uint64(10*time.Second), events.GetArg(&heartbeat, "uptime").Value)
+	// heartbeats are timestamped as the events, in wall clock time
+	assert.Equal(t, int(time.Unix(1000, 0).UnixNano())+int(uptime)+int(10*time.Second), heartbeat.Timestamp)
+}
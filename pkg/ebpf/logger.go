@@ -0,0 +1,32 @@
+package ebpf
+
+import (
+	"errors"
+
+	"github.com/aquasecurity/tracee/pkg/logger"
+)
+
+// log reports an internal diagnostic to the configured logger. Without a logger, warnings and errors are sent to
+// the errors channel as they were reported, and lower levels are dropped
+func (t *Tracee) log(level logger.Level, msg string, keysAndValues ...interface{}) {
+	if t.config.Logger != nil {
+		t.config.Logger.Log(1, level, msg, keysAndValues...)
+		return
+	}
+	if level < logger.WarnLevel {
+		return
+	}
+	t.config.ChanErrors <- logFieldError(msg, keysAndValues)
+}
+
+// logFieldError returns the "error" field of a logged message, or the message as an error if there is none
+func logFieldError(msg string, keysAndValues []interface{}) error {
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if keysAndValues[i] == "error" {
+			if err, ok := keysAndValues[i+1].(error); ok {
+				return err
+			}
+		}
+	}
+	return errors.New(msg)
+}
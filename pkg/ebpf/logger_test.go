@@ -0,0 +1,180 @@
+package ebpf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+type logEntry struct {
+	level  logger.Level
+	msg    string
+	fields map[string]interface{}
+}
+
+// recordingLogger keeps the messages logged by its logger for inspection, with the values of their fields
+type recordingLogger struct {
+	zapcore.Encoder
+	logger *logger.Logger
+
+	mtx     sync.Mutex
+	entries []logEntry
+}
+
+func newRecordingLogger() *recordingLogger {
+	l := &recordingLogger{Encoder: logger.NewJSONEncoder(logger.NewProductionEncoderConfig())}
+	l.logger = logger.NewLogger(&logger.LoggerConfig{Writer: ioutil.Discard, Level: logger.DebugLevel, Encoder: l})
+	return l
+}
+
+func (l *recordingLogger) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	values := make(map[string]interface{})
+	for _, field := range fields {
+		// errors are encoded as their message, so they are kept as they were logged
+		if field.Type == zapcore.ErrorType {
+			values[field.Key] = field.Interface
+			continue
+		}
+		enc := zapcore.NewMapObjectEncoder()
+		field.AddTo(enc)
+		values[field.Key] = enc.Fields[field.Key]
+	}
+	l.mtx.Lock()
+	l.entries = append(l.entries, logEntry{level: entry.Level, msg: entry.Message, fields: values})
+	l.mtx.Unlock()
+	return l.Encoder.EncodeEntry(entry, fields)
+}
+
+func (l *recordingLogger) logged() []logEntry {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return append([]logEntry{}, l.entries...)
+}
+
+func TestLogger(t *testing.T) {
+	t.Run("undecodable event", func(t *testing.T) {
+		recorder := newRecordingLogger()
+		trc := newTestTracee(t, Config{Logger: recorder.logger})
+
+		assert.Nil(t, trc.decodeEvent([]byte{1, 2, 3}))
+
+		entries := recorder.logged()
+		require.Len(t, entries, 1)
+		assert.Equal(t, logger.ErrorLevel, entries[0].level)
+		assert.Equal(t, "can't read context from buffer: buffer too short", entries[0].msg)
+		assert.EqualError(t, entries[0].fields["error"].(error), entries[0].msg)
+		assert.Equal(t, int32(1), trc.stats.ErrorCount.Read())
+	})
+
+	t.Run("argument parse error", func(t *testing.T) {
+		recorder := newRecordingLogger()
+		trc := newTestTracee(t, Config{Logger: recorder.logger})
+
+		// the event claims one more argument than it holds
+		dataRaw := newRawOpenatEvent(t, 1000, 42, "/etc/passwd", 0)
+		dataRaw[118]++
+		require.NotNil(t, trc.decodeEvent(dataRaw))
+
+		entries := recorder.logged()
+		require.Len(t, entries, 1)
+		assert.Equal(t, logger.ErrorLevel, entries[0].level)
+		assert.Equal(t, "openat", entries[0].fields["event"])
+		assert.Equal(t, int64(2), entries[0].fields["arg"])
+	})
+
+	t.Run("capture failure", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "TestLogger-*")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		recorder := newRecordingLogger()
+		trc := newTestTracee(t, Config{
+			Capture: &CaptureConfig{Exec: true},
+			Logger:  recorder.logger,
+		})
+		event := newExecEvent(t, f.Name())
+		require.NoError(t, os.Remove(f.Name()))
+
+		assert.False(t, trc.processDecodedEvent(event))
+
+		entries := recorder.logged()
+		require.Len(t, entries, 1)
+		assert.Equal(t, logger.ErrorLevel, entries[0].level)
+		assert.Equal(t, "sched_process_exec", entries[0].fields["event"])
+		assert.True(t, errors.Is(entries[0].fields["error"].(error), os.ErrNotExist))
+	})
+
+	t.Run("lost events", func(t *testing.T) {
+		recorder := newRecordingLogger()
+		trc := newTestTracee(t, Config{Logger: recorder.logger})
+		trc.lostEvChannel = make(chan uint64)
+		go trc.processLostEvents()
+
+		trc.lostEvChannel <- 3
+		assert.Eventually(t, func() bool { return len(recorder.logged()) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+		entry := recorder.logged()[0]
+		assert.Equal(t, logger.WarnLevel, entry.level)
+		assert.Equal(t, "lost 3 events", entry.msg)
+		assert.Equal(t, uint64(3), entry.fields["count"])
+		assert.Equal(t, "events", entry.fields["buffer"])
+	})
+
+	t.Run("errors channel without a logger", func(t *testing.T) {
+		chanErrors := make(chan error, 4)
+		trc := newTestTracee(t, Config{ChanErrors: chanErrors})
+
+		seeded := errors.New("seeded failure")
+		trc.handleError(seeded, "event", "openat")
+		trc.log(logger.WarnLevel, "lost 3 events", "count", uint64(3))
+		trc.log(logger.DebugLevel, "stack trace not found in map", "stack_id", uint32(1))
+
+		require.Len(t, chanErrors, 2)
+		assert.Equal(t, seeded, <-chanErrors)
+		assert.EqualError(t, <-chanErrors, "lost 3 events")
+	})
+}
+
+func TestLogger_base(t *testing.T) {
+	out := &bytes.Buffer{}
+	newLogger := func(aggregate bool) *logger.Logger {
+		return logger.NewLogger(&logger.LoggerConfig{
+			Writer:    out,
+			Level:     logger.InfoLevel,
+			Encoder:   logger.NewJSONEncoder(logger.NewProductionEncoderConfig()),
+			Aggregate: aggregate,
+		})
+	}
+	trc := newTestTracee(t, Config{Logger: newLogger(false)})
+	trc.log(logger.DebugLevel, "dropped below the level of the logger")
+	trc.log(logger.WarnLevel, "lost 3 events", "count", 3, "buffer", "events")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "warn", entry["level"])
+	assert.Equal(t, "lost 3 events", entry["msg"])
+	assert.Equal(t, float64(3), entry["count"])
+	assert.Equal(t, "events", entry["buffer"])
+
+	// aggregated messages are counted by where tracee logged them, rather than by its log function
+	out.Reset()
+	trc = newTestTracee(t, Config{Logger: newLogger(true)})
+	for i := 0; i < 3; i++ {
+		trc.log(logger.WarnLevel, "repeated")
+	}
+	trc.log(logger.WarnLevel, "elsewhere")
+	assert.Equal(t, 2, strings.Count(out.String(), "\n"))
+	assert.Contains(t, out.String(), `"msg":"elsewhere"`)
+}
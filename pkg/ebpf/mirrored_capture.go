@@ -0,0 +1,71 @@
+package ebpf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"golang.org/x/sys/unix"
+)
+
+// captureDestination returns the path (relative to the output dir) a file of the given capture directory is copied
+// to, creating its directories. Files are named by the kind of their capture, the timestamp of their event and their
+// base name (e.g. exec.1661502472416361017.ls), or with Capture.MirrorPaths, mirror their original path under the
+// capture directory (e.g. host/usr/bin/ls). A file captured again at a mirrored path which is already taken (e.g.
+// once it was modified) is named by the timestamp of its event as well (e.g. host/usr/bin/ls.1661502472416361017),
+// so its previous captures are kept
+func (t *Tracee) captureDestination(captureDir string, kind string, filePath string, timestamp int) (string, error) {
+	if !t.config.Capture.MirrorPaths {
+		if err := utils.MkdirAtExist(t.outDir, captureDir, 0755); err != nil {
+			return "", err
+		}
+		return filepath.Join(captureDir, fmt.Sprintf("%s.%d.%s", kind, timestamp, filepath.Base(filePath))), nil
+	}
+
+	relativePath, err := mirroredPath(filePath)
+	if err != nil {
+		return "", err
+	}
+	destinationFilePath := filepath.Join(captureDir, relativePath)
+	if err := mkdirAllAt(t.outDir, filepath.Dir(destinationFilePath)); err != nil {
+		return "", err
+	}
+	// compressed copies are named by the extension of their algorithm as well (see copyCapturedFile)
+	var ext string
+	if compressor, ok := t.captureCompressor(); ok {
+		ext = compressor.ext
+	}
+	var stat unix.Stat_t
+	if unix.Fstatat(int(t.outDir.Fd()), destinationFilePath+ext, &stat, unix.AT_SYMLINK_NOFOLLOW) == nil {
+		destinationFilePath = fmt.Sprintf("%s.%d", destinationFilePath, timestamp)
+	}
+	return destinationFilePath, nil
+}
+
+// mirroredPath returns the path a file is mirrored at, relative to its capture directory, which is its absolute path
+// without the leading '/'. The path is cleaned first, so its ".." components can't escape the capture directory
+// (e.g. /usr/../../etc/passwd is mirrored at etc/passwd)
+func mirroredPath(filePath string) (string, error) {
+	if !filepath.IsAbs(filePath) {
+		return "", fmt.Errorf("can't mirror %s, which isn't an absolute path", filePath)
+	}
+	relativePath := strings.TrimPrefix(filepath.Clean(filePath), "/")
+	if relativePath == "" {
+		return "", fmt.Errorf("can't mirror %s, which has no file name", filePath)
+	}
+	return relativePath, nil
+}
+
+// mkdirAllAt creates a directory given by its path relative to a directory, along with its missing parents
+func mkdirAllAt(dir *os.File, relativePath string) error {
+	var path string
+	for _, name := range strings.Split(filepath.Clean(relativePath), "/") {
+		path = filepath.Join(path, name)
+		if err := utils.MkdirAtExist(dir, path, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package ebpf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_mirroredPath(t *testing.T) {
+	testCases := []struct {
+		filePath      string
+		expectedPath  string
+		expectedError string
+	}{
+		{filePath: "/usr/bin/curl", expectedPath: "usr/bin/curl"},
+		{filePath: "/usr//lib/./libc.so.6", expectedPath: "usr/lib/libc.so.6"},
+		// ".." components can't escape the capture directory
+		{filePath: "/usr/../../etc/passwd", expectedPath: "etc/passwd"},
+		{filePath: "/../../../../root/.ssh/id_rsa", expectedPath: "root/.ssh/id_rsa"},
+		{filePath: "/tmp/..", expectedError: "can't mirror /tmp/.., which has no file name"},
+		{filePath: "../outside", expectedError: "can't mirror ../outside, which isn't an absolute path"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.filePath, func(t *testing.T) {
+			path, err := mirroredPath(tc.filePath)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedPath, path)
+		})
+	}
+}
+
+func Test_captureDestination(t *testing.T) {
+	t.Run("flat layout", func(t *testing.T) {
+		trc := newTestTracee(t, Config{})
+		path, err := trc.captureDestination("mntns-42", "exec", "/usr/bin/curl", 1000)
+		require.NoError(t, err)
+		assert.Equal(t, "mntns-42/exec.1000.curl", path)
+		assert.DirExists(t, filepath.Join(trc.outDir.Name(), "mntns-42"))
+	})
+
+	t.Run("mirrored layout", func(t *testing.T) {
+		trc := newTestTracee(t, Config{Capture: &CaptureConfig{MirrorPaths: true}})
+		path, err := trc.captureDestination("mntns-42", "exec", "/usr/bin/curl", 1000)
+		require.NoError(t, err)
+		assert.Equal(t, "mntns-42/usr/bin/curl", path)
+		assert.DirExists(t, filepath.Join(trc.outDir.Name(), "mntns-42/usr/bin"))
+
+		// captures of taken paths keep the previous captures
+		require.NoError(t, ioutil.WriteFile(filepath.Join(trc.outDir.Name(), path), []byte("v1"), 0644))
+		path, err = trc.captureDestination("mntns-42", "exec", "/usr/bin/curl", 2000)
+		require.NoError(t, err)
+		assert.Equal(t, "mntns-42/usr/bin/curl.2000", path)
+
+		// traversing paths are mirrored within the capture directory
+		path, err = trc.captureDestination("mntns-42", "open", "/etc/../../../../escaped", 1000)
+		require.NoError(t, err)
+		assert.Equal(t, "mntns-42/escaped", path)
+		assert.NoFileExists(t, filepath.Join(filepath.Dir(trc.outDir.Name()), "escaped"))
+		_, err = trc.captureDestination("mntns-42", "open", "/..", 1000)
+		assert.Error(t, err)
+	})
+
+	t.Run("mirrored compressed layout", func(t *testing.T) {
+		trc := newTestTracee(t, Config{Capture: &CaptureConfig{MirrorPaths: true, Compression: CaptureCompressionGzip}})
+		path, err := trc.captureDestination("host", "so", "/usr/lib/libc.so.6", 1000)
+		require.NoError(t, err)
+		assert.Equal(t, "host/usr/lib/libc.so.6", path)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(trc.outDir.Name(), path+".gz"), []byte("v1"), 0644))
+		path, err = trc.captureDestination("host", "so", "/usr/lib/libc.so.6", 2000)
+		require.NoError(t, err)
+		assert.Equal(t, "host/usr/lib/libc.so.6.2000", path)
+	})
+}
+
+func Test_processEvent_mirroredExecCapture(t *testing.T) {
+	trc := newTestTracee(t, Config{Capture: &CaptureConfig{Exec: true, MirrorPaths: true}})
+	exe := filepath.Join(t.TempDir(), "bin", "tool")
+	require.NoError(t, os.MkdirAll(filepath.Dir(exe), 0755))
+	require.NoError(t, ioutil.WriteFile(exe, []byte("v1"), 0755))
+
+	mirrored := filepath.Join(trc.outDir.Name(), "host", strings.TrimPrefix(exe, "/"))
+	require.NoError(t, trc.processEvent(newExecEvent(t, exe)))
+	content, err := ioutil.ReadFile(mirrored)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", string(content))
+
+	// unmodified files aren't captured again, and modified ones are captured along their previous captures
+	require.NoError(t, trc.processEvent(newExecEvent(t, exe)))
+	captures, err := filepath.Glob(mirrored + "*")
+	require.NoError(t, err)
+	assert.Len(t, captures, 1)
+
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, ioutil.WriteFile(exe, []byte("v2"), 0755))
+	event := newExecEvent(t, exe)
+	event.Timestamp = 3000
+	require.NoError(t, trc.processEvent(event))
+	content, err = ioutil.ReadFile(mirrored + ".3000")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", string(content))
+	content, err = ioutil.ReadFile(mirrored)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", string(content))
+}
+
+func TestOpenCapturedFile_mirrored(t *testing.T) {
+	for _, compression := range []string{"", CaptureCompressionGzip} {
+		t.Run("compression "+compression, func(t *testing.T) {
+			trc := newTestTracee(t, Config{Capture: &CaptureConfig{Exec: true, MirrorPaths: true, Compression: compression}})
+			exe := filepath.Join(t.TempDir(), "bin", "tool")
+			require.NoError(t, os.MkdirAll(filepath.Dir(exe), 0755))
+			require.NoError(t, ioutil.WriteFile(exe, []byte("first version"), 0755))
+
+			// not captured yet
+			event := newExecEvent(t, exe)
+			event.Timestamp = 10
+			_, err := trc.OpenCapturedFile(event)
+			assert.ErrorIs(t, err, os.ErrNotExist)
+
+			require.NoError(t, trc.processEvent(event))
+			assert.Equal(t, "first version", readCapturedFile(t, trc, event))
+
+			// a modified file is captured along the first capture, while the earlier execs still resolve to it
+			require.NoError(t, ioutil.WriteFile(exe, []byte("second version"), 0755))
+			event = newExecEvent(t, exe)
+			event.Args[1].Value = event.Args[1].Value.(uint64) + 1 // make sure ctime changed
+			event.Timestamp = 30
+			require.NoError(t, trc.processEvent(event))
+			assert.Equal(t, "second version", readCapturedFile(t, trc, event))
+			event.Timestamp = 40
+			assert.Equal(t, "second version", readCapturedFile(t, trc, event))
+			event.Timestamp = 25
+			assert.Equal(t, "first version", readCapturedFile(t, trc, event))
+		})
+	}
+}
+
+func Test_updateFileSHA_mirrored(t *testing.T) {
+	trc := newTestTracee(t, Config{Capture: &CaptureConfig{Exec: true, MirrorPaths: true, Profile: true}})
+	trc.profiledFiles = make(map[string]profilerInfo)
+	exe := filepath.Join(t.TempDir(), "tool")
+	require.NoError(t, ioutil.WriteFile(exe, []byte("foo bar baz"), 0755))
+	require.NoError(t, trc.processEvent(newExecEvent(t, exe)))
+
+	trc.updateFileSHA()
+	require.Len(t, trc.profiledFiles, 1)
+	for _, pf := range trc.profiledFiles {
+		assert.Equal(t, "dbd318c1c462aee872f41109a4dfd3048871a03dedd0fe0e757ced57dad6f2d7", pf.FileHash)
+	}
+}
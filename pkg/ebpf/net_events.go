@@ -13,6 +13,7 @@ import (
 	"github.com/aquasecurity/tracee/pkg/bufferdecoder"
 	"github.com/aquasecurity/tracee/pkg/events"
 	"github.com/aquasecurity/tracee/pkg/events/derive"
+	"github.com/aquasecurity/tracee/pkg/logger"
 	"github.com/aquasecurity/tracee/pkg/procinfo"
 	"github.com/aquasecurity/tracee/pkg/utils"
 	"github.com/google/gopacket"
@@ -205,7 +206,11 @@ func (t *Tracee) processNetEvents(ctx gocontext.Context) {
 	// Todo: add stats for network packets (in epilog)
 	for {
 		select {
-		case in := <-t.netChannel:
+		case in, ok := <-t.netChannel:
+			if !ok {
+				// the perf buffer was stopped
+				return
+			}
 			// Sanity check - timestamp, event id, host tid and comm must exist in all net events
 			if len(in) < 32 {
 				continue
@@ -219,14 +224,7 @@ func (t *Tracee) processNetEvents(ctx gocontext.Context) {
 				continue
 			}
 
-			if t.config.Output.RelativeTime {
-				// To get the current ("wall") time, we add the boot time into it.
-				netEventMetadata.TimeStamp -= t.startTime
-			} else {
-				// timeStamp is nanoseconds since system boot time
-				// To get the monotonic time since tracee was started, we have to subtract the start time from the timestamp.
-				netEventMetadata.TimeStamp += t.bootTime
-			}
+			netEventMetadata.TimeStamp = t.eventTimestamp(netEventMetadata.TimeStamp)
 
 			// continue without checking for error, as packetContext will be valid anyway
 			packetContext, networkThread, _ := t.getPcapContextFromTid(netEventMetadata.HostTid)
@@ -290,9 +288,18 @@ func (t *Tracee) processNetEvents(ctx gocontext.Context) {
 						continue
 					}
 					if err := t.writePacket(netCaptureData, ifaceIdx, time.Unix(0, int64(netEventMetadata.TimeStamp)), packetContext, packetBytes); err != nil {
-						t.handleError(err)
+						t.handleError(err, "iface", ifaceName)
 						continue
 					}
+					if t.packetsPcap != nil {
+						// packets of interfaces without a hardware address (e.g. tun devices) start with their ip header
+						iface := t.netInfo.ifaces[int(netCaptureData.ConfigIfaceIndex)]
+						hasLinkLayer := len(iface.HardwareAddr) > 0 || iface.Flags&net.FlagLoopback != 0
+						if err := t.packetsPcap.WritePacket(time.Unix(0, int64(netEventMetadata.TimeStamp)), packetBytes, hasLinkLayer); err != nil {
+							t.handleError(err, "iface", ifaceName)
+							continue
+						}
+					}
 				}
 
 			}
@@ -303,7 +310,7 @@ func (t *Tracee) processNetEvents(ctx gocontext.Context) {
 			// https://github.com/aquasecurity/libbpfgo/issues/122
 			if lost > 0 {
 				t.stats.LostNtCount.Increment(int(lost))
-				t.config.ChanErrors <- fmt.Errorf("lost %d network events", lost)
+				t.log(logger.WarnLevel, fmt.Sprintf("lost %d network events", lost), "count", lost, "buffer", "network")
 			}
 		}
 	}
@@ -0,0 +1,46 @@
+package ebpf
+
+import (
+	"fmt"
+
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// captureOpenedFile captures a file opened at a path matching CaptureConfig.FileOpenPaths, as reported by a
+// security_file_open event, so files which are only read (e.g. secrets and configs) are captured as well. The file is
+// read through the root of the opening process, and captured once per inode, unless it is modified
+func (t *Tracee) captureOpenedFile(event *trace.Event) error {
+	if t.captureSkipped() || !t.lineageCaptured(event) {
+		return nil
+	}
+	args, err := decodeArgs(event)
+	if err != nil {
+		return err
+	}
+	filePath := args.String("pathname")
+	// path should be absolute, except for e.g memfd_create files
+	if filePath == "" || filePath[0] != '/' {
+		return nil
+	}
+	if !MatchFilter(t.config.Capture.FileOpenPaths, filePath) {
+		return nil
+	}
+	dev := args.Uint32("dev")
+	inode := args.Uint64("inode")
+	ctime := args.Uint64("ctime")
+
+	captureDir := t.captureDir(event.ContainerID, uint32(event.MountNS))
+	// a file may be opened by many paths (e.g. through links), so captures are deduplicated by its inode
+	capturedFileID := fmt.Sprintf("%s:open:dev-%d.inode-%d", captureDir, dev, inode)
+	if t.fileTooNew(event, int64(ctime)) || t.captureThrottled(event, capturedFileID, int64(ctime)) {
+		return nil
+	}
+	sourceFilePath := fmt.Sprintf("/proc/%d/root%s", event.HostProcessID, filePath)
+	destinationFilePath, err := t.captureDestination(captureDir, "open", filePath, event.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	_, err = t.captureFile(sourceFilePath, capturedFileID, destinationFilePath, int64(ctime))
+	return err
+}
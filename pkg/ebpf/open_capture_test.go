@@ -0,0 +1,81 @@
+package ebpf
+
+import (
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFileOpenEvent creates a security_file_open event of the current process opening the given file
+func newFileOpenEvent(t *testing.T, ts int, pathname string) *trace.Event {
+	info, err := os.Stat(pathname)
+	require.NoError(t, err)
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	require.True(t, ok)
+
+	return &trace.Event{
+		Timestamp:     ts,
+		EventID:       int(events.SecurityFileOpen),
+		EventName:     "security_file_open",
+		ProcessName:   "reader",
+		HostProcessID: os.Getpid(),
+		MountNS:       1,
+		ArgsNum:       5,
+		Args: []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: pathname},
+			{ArgMeta: trace.ArgMeta{Name: "flags", Type: "int"}, Value: int32(os.O_RDONLY)},
+			{ArgMeta: trace.ArgMeta{Name: "dev", Type: "dev_t"}, Value: uint32(stat.Dev)},
+			{ArgMeta: trace.ArgMeta{Name: "inode", Type: "unsigned long"}, Value: stat.Ino},
+			{ArgMeta: trace.ArgMeta{Name: "ctime", Type: "unsigned long"}, Value: uint64(stat.Ctim.Nano())},
+		},
+	}
+}
+
+func Test_captureOpenedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "Test_captureOpenedFile-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	secret := dir + "/secret"
+	require.NoError(t, ioutil.WriteFile(secret, []byte("password"), 0600))
+	other := dir + "/other"
+	require.NoError(t, ioutil.WriteFile(other, []byte("not a secret"), 0600))
+
+	trc := newTestTracee(t, Config{Capture: &CaptureConfig{FileOpenPaths: []string{dir + "/secret*"}}})
+
+	// a read only file at a matching path is captured
+	opened := newFileOpenEvent(t, 1000, secret)
+	require.NoError(t, trc.processEvent(opened))
+	assert.Equal(t, "password", readCapturedFile(t, trc, opened))
+	assert.Equal(t, int32(1), trc.stats.CapFileCount.Read())
+
+	// opening it again doesn't capture it again
+	require.NoError(t, trc.processEvent(newFileOpenEvent(t, 2000, secret)))
+	assert.Equal(t, int32(1), trc.stats.CapFileCount.Read())
+
+	// files at other paths aren't captured
+	unmatched := newFileOpenEvent(t, 3000, other)
+	require.NoError(t, trc.processEvent(unmatched))
+	assert.Equal(t, int32(1), trc.stats.CapFileCount.Read())
+	_, err = trc.OpenCapturedFile(unmatched)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// a modified file is captured again
+	require.NoError(t, ioutil.WriteFile(secret, []byte("new password"), 0600))
+	reopened := newFileOpenEvent(t, 4000, secret)
+	require.NoError(t, trc.processEvent(reopened))
+	assert.Equal(t, int32(2), trc.stats.CapFileCount.Read())
+	assert.Equal(t, "new password", readCapturedFile(t, trc, reopened))
+	assert.Equal(t, "password", readCapturedFile(t, trc, opened))
+
+	t.Run("no paths", func(t *testing.T) {
+		trc := newTestTracee(t, Config{})
+		require.NoError(t, trc.processEvent(newFileOpenEvent(t, 1000, secret)))
+		assert.Equal(t, int32(0), trc.stats.CapFileCount.Read())
+	})
+}
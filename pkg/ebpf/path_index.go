@@ -0,0 +1,80 @@
+package ebpf
+
+import (
+	"path"
+)
+
+// pathIndex indexes ids by the path of their files and by the directories containing them, so the files at a path
+// or below a directory are looked up (e.g. on renames and deletions) without scanning them all. Paths are indexed in
+// a scope, such as the capture dir of their namespace. The zero pathIndex is empty and ready to use.
+type pathIndex struct {
+	files map[string]map[string]struct{} // ids by the scope and the path of their file
+	dirs  map[string]map[string]struct{} // ids by the scope and the path of each directory containing their file
+}
+
+// parentDirs returns the paths of the directories containing a file, from its directory up to (excluding) the root
+func parentDirs(filePath string) []string {
+	var dirs []string
+	for dir := path.Dir(filePath); dir != "/" && dir != "."; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+func addIndexed(index map[string]map[string]struct{}, key string, id string) {
+	ids, ok := index[key]
+	if !ok {
+		ids = make(map[string]struct{})
+		index[key] = ids
+	}
+	ids[id] = struct{}{}
+}
+
+func removeIndexed(index map[string]map[string]struct{}, key string, id string) {
+	ids, ok := index[key]
+	if !ok {
+		return
+	}
+	delete(ids, id)
+	if len(ids) == 0 {
+		delete(index, key)
+	}
+}
+
+// add indexes an id by the path of its file
+func (x *pathIndex) add(scope string, filePath string, id string) {
+	if x.files == nil {
+		x.files = make(map[string]map[string]struct{})
+		x.dirs = make(map[string]map[string]struct{})
+	}
+	addIndexed(x.files, scope+filePath, id)
+	for _, dir := range parentDirs(filePath) {
+		addIndexed(x.dirs, scope+dir, id)
+	}
+}
+
+// remove forgets an id indexed by the path of its file
+func (x *pathIndex) remove(scope string, filePath string, id string) {
+	removeIndexed(x.files, scope+filePath, id)
+	for _, dir := range parentDirs(filePath) {
+		removeIndexed(x.dirs, scope+dir, id)
+	}
+}
+
+// at returns the ids of the files at a path
+func (x *pathIndex) at(scope string, filePath string) []string {
+	var ids []string
+	for id := range x.files[scope+filePath] {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// below returns the ids of the files at a path, or below it if it's a directory
+func (x *pathIndex) below(scope string, filePath string) []string {
+	ids := x.at(scope, filePath)
+	for id := range x.dirs[scope+filePath] {
+		ids = append(ids, id)
+	}
+	return ids
+}
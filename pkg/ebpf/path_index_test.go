@@ -0,0 +1,52 @@
+package ebpf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_pathIndex(t *testing.T) {
+	var index pathIndex
+	assert.Empty(t, index.below("host:", "/tmp"))
+
+	index.add("host:", "/tmp/build/tool", "host:/proc/42/root/tmp/build/tool")
+	index.add("host:", "/tmp/build/lib/lib.so", "host:so:/tmp/build/lib/lib.so")
+	index.add("host:", "/tmp/builder", "host:so:/tmp/builder")
+	index.add("ab356bc4dd554:", "/tmp/build/tool", "ab356bc4dd554:so:/tmp/build/tool")
+
+	assert.ElementsMatch(t, []string{"host:/proc/42/root/tmp/build/tool"}, index.at("host:", "/tmp/build/tool"))
+	assert.Empty(t, index.at("host:", "/tmp/build"))
+	// files below a directory, but not the files whose path only starts with its path, nor of other scopes
+	assert.ElementsMatch(t, []string{
+		"host:/proc/42/root/tmp/build/tool",
+		"host:so:/tmp/build/lib/lib.so",
+	}, index.below("host:", "/tmp/build"))
+
+	index.remove("host:", "/tmp/build/lib/lib.so", "host:so:/tmp/build/lib/lib.so")
+	assert.ElementsMatch(t, []string{"host:/proc/42/root/tmp/build/tool"}, index.below("host:", "/tmp/build"))
+	assert.Empty(t, index.below("host:", "/tmp/build/lib"))
+	assert.NotContains(t, index.dirs, "host:/tmp/build/lib")
+
+	// forgetting an id which isn't indexed does nothing
+	index.remove("host:", "/tmp/scratch", "host:so:/tmp/scratch")
+	assert.Len(t, index.files, 3)
+}
+
+func Test_pathIndex_capturedFiles(t *testing.T) {
+	trc := newTestTracee(t, Config{Capture: &CaptureConfig{Exec: true, SharedObjects: true}})
+	trc.capturedLimit = newCapturedFilesLimit(1)
+
+	// files captured by their inode aren't indexed by path
+	trc.markCaptured("host:open:dev-1.inode-13", 300)
+	assert.Empty(t, trc.capturedPaths.files)
+
+	// evicted and forgotten files leave the index
+	trc.markCaptured("mntns-2:so:/tmp/build/lib.so", 200)
+	trc.markCaptured("mntns-2:so:/tmp/build/other.so", 100)
+	assert.Empty(t, trc.capturedPaths.at("mntns-2:", "/tmp/build/lib.so"))
+	assert.Equal(t, []string{"mntns-2:so:/tmp/build/other.so"}, trc.capturedPaths.below("mntns-2:", "/tmp"))
+	trc.forgetCaptured("mntns-2:so:/tmp/build/other.so")
+	assert.Empty(t, trc.capturedPaths.files)
+	assert.Empty(t, trc.capturedPaths.dirs)
+}
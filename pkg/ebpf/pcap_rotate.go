@@ -0,0 +1,135 @@
+package ebpf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// ethernetHeaderLen is the length of the link layer header synthesized for packets which have none
+const ethernetHeaderLen = 14
+
+// rotatingPcap writes packets to libpcap files in the output directory, moving to a new file once the current one
+// has reached its maximal size. Files are named by the timestamp of their first packet (packets.<timestamp>.pcap).
+// All packets are written with an Ethernet link layer, so packets of interfaces without one (e.g. tun devices) get
+// a synthesized Ethernet header.
+type rotatingPcap struct {
+	mtx     sync.Mutex
+	outDir  *os.File
+	maxSize int64
+	file    *os.File
+	writer  *pcapgo.Writer
+	size    int64
+}
+
+func newRotatingPcap(outDir *os.File, maxSize int64) *rotatingPcap {
+	return &rotatingPcap{outDir: outDir, maxSize: maxSize}
+}
+
+// WritePacket writes a packet captured at the given time. hasLinkLayer tells if the packet starts with an Ethernet
+// header, or with its network layer header
+func (p *rotatingPcap) WritePacket(timeStamp time.Time, packetBytes []byte, hasLinkLayer bool) error {
+	if !hasLinkLayer {
+		var err error
+		packetBytes, err = synthesizeEthernetHeader(packetBytes)
+		if err != nil {
+			return err
+		}
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.writer == nil || p.size >= p.maxSize {
+		if err := p.rotate(timeStamp); err != nil {
+			return err
+		}
+	}
+
+	info := gopacket.CaptureInfo{
+		Timestamp:     timeStamp,
+		CaptureLength: len(packetBytes),
+		Length:        len(packetBytes),
+	}
+	if err := p.writer.WritePacket(info, packetBytes); err != nil {
+		return err
+	}
+	// every packet record has a 16 bytes header
+	p.size += int64(16 + len(packetBytes))
+
+	return nil
+}
+
+// rotate closes the current pcap file, and starts a new one for packets from the given time
+func (p *rotatingPcap) rotate(timeStamp time.Time) error {
+	if p.file != nil {
+		if err := p.file.Close(); err != nil {
+			return err
+		}
+		p.file = nil
+		p.writer = nil
+	}
+
+	fileName := fmt.Sprintf("packets.%d.pcap", timeStamp.UnixNano())
+	f, err := utils.OpenAt(p.outDir, fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("error creating pcap file: %v", err)
+	}
+	w := pcapgo.NewWriter(f)
+	if err := w.WriteFileHeader(uint32(math.MaxUint16), layers.LinkTypeEthernet); err != nil {
+		f.Close()
+		return err
+	}
+
+	p.file = f
+	p.writer = w
+	// the file header is 24 bytes long
+	p.size = 24
+
+	return nil
+}
+
+// Close closes the current pcap file
+func (p *rotatingPcap) Close() error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.file == nil {
+		return nil
+	}
+	err := p.file.Close()
+	p.file = nil
+	p.writer = nil
+	return err
+}
+
+// synthesizeEthernetHeader prepends an Ethernet header with zeroed addresses to a packet starting with its IP header
+func synthesizeEthernetHeader(packetBytes []byte) ([]byte, error) {
+	if len(packetBytes) == 0 {
+		return nil, fmt.Errorf("error synthesizing link layer header: empty packet")
+	}
+
+	var etherType layers.EthernetType
+	switch packetBytes[0] >> 4 {
+	case 4:
+		etherType = layers.EthernetTypeIPv4
+	case 6:
+		etherType = layers.EthernetTypeIPv6
+	default:
+		return nil, fmt.Errorf("error synthesizing link layer header: unknown ip version %d", packetBytes[0]>>4)
+	}
+
+	frame := make([]byte, ethernetHeaderLen+len(packetBytes))
+	binary.BigEndian.PutUint16(frame[12:ethernetHeaderLen], uint16(etherType))
+	copy(frame[ethernetHeaderLen:], packetBytes)
+
+	return frame, nil
+}
@@ -0,0 +1,139 @@
+package ebpf
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newUDPPacket serializes an IPv4 UDP packet, with an Ethernet header if withEthernet is set
+func newUDPPacket(t *testing.T, payload string, withEthernet bool) []byte {
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.IPv4(10, 0, 0, 1),
+		DstIP:    net.IPv4(10, 0, 0, 2),
+	}
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 4321}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+
+	serializable := []gopacket.SerializableLayer{ip, udp, gopacket.Payload(payload)}
+	if withEthernet {
+		eth := &layers.Ethernet{
+			SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+			DstMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 6},
+			EthernetType: layers.EthernetTypeIPv4,
+		}
+		serializable = append([]gopacket.SerializableLayer{eth}, serializable...)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true}
+	require.NoError(t, gopacket.SerializeLayers(buf, opts, serializable...))
+	return buf.Bytes()
+}
+
+func Test_rotatingPcap(t *testing.T) {
+	d, err := ioutil.TempDir("", "Test_rotatingPcap-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+	outDir, err := os.Open(d)
+	require.NoError(t, err)
+	defer outDir.Close()
+
+	start := time.Unix(1657290245, 0)
+	packets := []struct {
+		ts           time.Time
+		payload      string
+		hasLinkLayer bool
+	}{
+		{start, "first", true},
+		{start.Add(time.Millisecond), "second", false},
+		{start.Add(2 * time.Millisecond), "third", true},
+	}
+
+	// small enough for every packet to start a new file
+	pcap := newRotatingPcap(outDir, 64)
+	for _, p := range packets {
+		require.NoError(t, pcap.WritePacket(p.ts, newUDPPacket(t, p.payload, p.hasLinkLayer), p.hasLinkLayer))
+	}
+	require.NoError(t, pcap.Close())
+
+	files, err := filepath.Glob(filepath.Join(d, "packets.*.pcap"))
+	require.NoError(t, err)
+	sort.Strings(files)
+	require.Len(t, files, len(packets))
+
+	for i, p := range packets {
+		assert.Equal(t, filepath.Join(d, fmt.Sprintf("packets.%d.pcap", p.ts.UnixNano())), files[i])
+
+		f, err := os.Open(files[i])
+		require.NoError(t, err)
+		defer f.Close()
+		r, err := pcapgo.NewReader(f)
+		require.NoError(t, err)
+		assert.Equal(t, layers.LinkTypeEthernet, r.LinkType())
+
+		data, ci, err := r.ReadPacketData()
+		require.NoError(t, err)
+		assert.True(t, p.ts.Equal(ci.Timestamp))
+
+		packet := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+		require.NotNil(t, packet.Layer(layers.LayerTypeEthernet))
+		ipLayer := packet.Layer(layers.LayerTypeIPv4)
+		require.NotNil(t, ipLayer)
+		assert.Equal(t, "10.0.0.2", ipLayer.(*layers.IPv4).DstIP.String())
+		require.NotNil(t, packet.ApplicationLayer())
+		assert.Equal(t, p.payload, string(packet.ApplicationLayer().Payload()))
+
+		_, _, err = r.ReadPacketData()
+		assert.Equal(t, io.EOF, err)
+	}
+}
+
+func Test_rotatingPcap_sameFile(t *testing.T) {
+	d, err := ioutil.TempDir("", "Test_rotatingPcap_sameFile-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+	outDir, err := os.Open(d)
+	require.NoError(t, err)
+	defer outDir.Close()
+
+	pcap := newRotatingPcap(outDir, 1024*1024)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, pcap.WritePacket(time.Unix(0, int64(i+1)), newUDPPacket(t, "data", true), true))
+	}
+	require.NoError(t, pcap.Close())
+
+	f, err := os.Open(filepath.Join(d, "packets.1.pcap"))
+	require.NoError(t, err)
+	defer f.Close()
+	r, err := pcapgo.NewReader(f)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, _, err := r.ReadPacketData()
+		require.NoError(t, err)
+	}
+}
+
+func Test_synthesizeEthernetHeader(t *testing.T) {
+	_, err := synthesizeEthernetHeader([]byte{0x10, 0x00})
+	assert.Error(t, err)
+
+	frame, err := synthesizeEthernetHeader([]byte{0x60, 0x00})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x86, 0xdd, 0x60, 0x00}, frame)
+}
@@ -0,0 +1,198 @@
+package ebpf
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/require"
+)
+
+// processingMix is the number of events of each kind fed in every round of the processing benchmark, e.g.
+// go test ./pkg/ebpf/ -run ^$ -bench Benchmark_processEvent -args -processing-mix=exec=1,write=4,socket=5
+var processingMix = flag.String("processing-mix", "", "mix of events for Benchmark_processEvent/custom, as kind=count pairs of exec, write and socket events")
+
+const (
+	// benchExecutables is the number of distinct executed files, so exec hashing hits its cache as it would
+	benchExecutables = 8
+	// benchExecutableSize is the size of the executed files
+	benchExecutableSize = 1 << 20
+	// benchWrittenFiles is the number of distinct written files
+	benchWrittenFiles = 1024
+)
+
+// processingFixture generates synthetic events, which are processed without the bpf programs. Executed files are
+// temporary files hashed through the /proc/PID/root of this process, so exec hashing reads real files
+type processingFixture struct {
+	executables []string
+	generated   int
+}
+
+func newProcessingFixture(b *testing.B) *processingFixture {
+	dir, err := ioutil.TempDir("", "Benchmark_processEvent-*")
+	require.NoError(b, err)
+	b.Cleanup(func() { os.RemoveAll(dir) })
+
+	f := &processingFixture{}
+	data := make([]byte, benchExecutableSize)
+	for i := 0; i < benchExecutables; i++ {
+		data[0] = byte(i)
+		path := filepath.Join(dir, fmt.Sprintf("bin-%d", i))
+		require.NoError(b, ioutil.WriteFile(path, data, 0755))
+		f.executables = append(f.executables, path)
+	}
+	return f
+}
+
+func (f *processingFixture) execEvent(b *testing.B) *trace.Event {
+	event := newExecEvent(b, f.executables[f.generated%len(f.executables)])
+	event.Timestamp = f.generated
+	return event
+}
+
+func (f *processingFixture) writeEvent() *trace.Event {
+	inode := uint64(f.generated % benchWrittenFiles)
+	return &trace.Event{
+		EventID:       int(events.VfsWrite),
+		EventName:     "vfs_write",
+		HostProcessID: os.Getpid(),
+		MountNS:       1,
+		ArgsNum:       5,
+		Args: []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: fmt.Sprintf("/var/log/app-%d.log", inode)},
+			{ArgMeta: trace.ArgMeta{Name: "dev", Type: "dev_t"}, Value: uint32(2049)},
+			{ArgMeta: trace.ArgMeta{Name: "inode", Type: "unsigned long"}, Value: inode},
+			{ArgMeta: trace.ArgMeta{Name: "count", Type: "size_t"}, Value: uint64(4096)},
+			{ArgMeta: trace.ArgMeta{Name: "pos", Type: "off_t"}, Value: uint64(0)},
+		},
+	}
+}
+
+func (f *processingFixture) socketEvent() *trace.Event {
+	return &trace.Event{
+		EventID:       int(events.SecuritySocketConnect),
+		EventName:     "security_socket_connect",
+		HostProcessID: os.Getpid(),
+		MountNS:       1,
+		ArgsNum:       2,
+		Args: []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Name: "sockfd", Type: "int"}, Value: int32(3)},
+			{ArgMeta: trace.ArgMeta{Name: "remote_addr", Type: "struct sockaddr*"}, Value: map[string]string{
+				"sa_family": "AF_INET",
+				"sin_port":  strconv.Itoa(1024 + f.generated%1024),
+				"sin_addr":  "10.0.0.1",
+			}},
+		},
+	}
+}
+
+// events generates n events, following the given mix of event kinds
+func (f *processingFixture) events(b *testing.B, mix map[string]int, n int) []*trace.Event {
+	var round []string
+	for _, kind := range []string{"exec", "write", "socket"} {
+		for i := 0; i < mix[kind]; i++ {
+			round = append(round, kind)
+		}
+	}
+
+	generated := make([]*trace.Event, 0, n)
+	for len(generated) < n {
+		var event *trace.Event
+		switch round[f.generated%len(round)] {
+		case "exec":
+			event = f.execEvent(b)
+		case "write":
+			event = f.writeEvent()
+		case "socket":
+			event = f.socketEvent()
+		}
+		f.generated++
+		generated = append(generated, event)
+	}
+	return generated
+}
+
+// parseProcessingMix parses a mix of event kinds given as kind=count pairs (e.g. exec=1,write=4,socket=5)
+func parseProcessingMix(value string) (map[string]int, error) {
+	mix := make(map[string]int)
+	total := 0
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid processing mix %s: expected kind=count pairs", value)
+		}
+		switch parts[0] {
+		case "exec", "write", "socket":
+		default:
+			return nil, fmt.Errorf("invalid processing mix %s: unknown event kind %s", value, parts[0])
+		}
+		count, err := strconv.Atoi(parts[1])
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("invalid processing mix %s: invalid count of %s events", value, parts[0])
+		}
+		mix[parts[0]] = count
+		total += count
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("invalid processing mix %s: no events", value)
+	}
+	return mix, nil
+}
+
+// Benchmark_processEvent measures the processing of events between their decoding and their derivation, with exec
+// hashing and written files capture enabled
+func Benchmark_processEvent(b *testing.B) {
+	benchmarkProcessEvent(b, &OutputConfig{ExecHash: true})
+}
+
+// Benchmark_processEventMinimal measures the processing of the events of Benchmark_processEvent in minimal mode,
+// which skips their hashing and capture
+func Benchmark_processEventMinimal(b *testing.B) {
+	benchmarkProcessEvent(b, &OutputConfig{ExecHash: true, Minimal: true})
+}
+
+func benchmarkProcessEvent(b *testing.B, output *OutputConfig) {
+	mixes := []struct {
+		name string
+		mix  map[string]int
+	}{
+		{name: "exec_hash", mix: map[string]int{"exec": 1}},
+		{name: "vfs_write_capture", mix: map[string]int{"write": 1}},
+		{name: "socket", mix: map[string]int{"socket": 1}},
+		{name: "mixed", mix: map[string]int{"exec": 1, "write": 4, "socket": 5}},
+	}
+	if *processingMix != "" {
+		mix, err := parseProcessingMix(*processingMix)
+		require.NoError(b, err)
+		mixes = append(mixes, struct {
+			name string
+			mix  map[string]int
+		}{name: "custom", mix: mix})
+	}
+
+	for _, m := range mixes {
+		b.Run(m.name, func(b *testing.B) {
+			trc := newTestTracee(b, Config{
+				Capture: &CaptureConfig{FileWrite: true},
+				Output:  output,
+			})
+			fixture := newProcessingFixture(b)
+			generated := fixture.events(b, m.mix, b.N)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for _, event := range generated {
+				if !trc.processDecodedEvent(event) {
+					b.Fatalf("event %s was dropped", event.EventName)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package ebpf
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aquasecurity/tracee/types/trace"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// maxRawEventSize bounds the size of a recorded raw event, so a corrupted recording can't cause huge allocations
+const maxRawEventSize = 1 << 20
+
+// rawEventsRecorder writes raw events, as received from the bpf programs, to a recording.
+// Every event is written as its little endian 32 bit length followed by its bytes
+type rawEventsRecorder struct {
+	mtx sync.Mutex
+	w   io.Writer
+}
+
+func newRawEventsRecorder(w io.Writer) *rawEventsRecorder {
+	return &rawEventsRecorder{w: w}
+}
+
+func (r *rawEventsRecorder) write(dataRaw []byte) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(dataRaw)))
+	if _, err := r.w.Write(size[:]); err != nil {
+		return fmt.Errorf("error recording raw event: %v", err)
+	}
+	if _, err := r.w.Write(dataRaw); err != nil {
+		return fmt.Errorf("error recording raw event: %v", err)
+	}
+	return nil
+}
+
+// readRawEvent reads the next raw event of a recording. It returns io.EOF once the recording ends
+func readRawEvent(r io.Reader) ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("error reading raw event: truncated recording")
+		}
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint32(size[:])
+	if n > maxRawEventSize {
+		return nil, fmt.Errorf("error reading raw event: event size too big: %d", n)
+	}
+	dataRaw := make([]byte, n)
+	if _, err := io.ReadFull(r, dataRaw); err != nil {
+		return nil, fmt.Errorf("error reading raw event: truncated recording")
+	}
+	return dataRaw, nil
+}
+
+// Replay re-processes the raw events of a recording (see Config.RecordRawEvents) without the bpf programs.
+// The events go through the same decoding, filtering, processing and derivation as traced events, and are sent to
+// Config.ChanEvents in the recorded order. Replay returns once all the events were sent, or the context is done.
+// The tracee doesn't have to be initialized, in which case containers enrichment and events derivation are skipped,
+// and the recorded timestamps are kept as is.
+func (t *Tracee) Replay(ctx context.Context, r io.Reader) error {
+	if err := t.initReplay(); err != nil {
+		return err
+	}
+	t.replaying = true
+	defer func() { t.replaying = false }()
+
+	reader := bufio.NewReader(r)
+	for {
+		dataRaw, err := readRawEvent(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		event := t.decodeEvent(dataRaw)
+		if event == nil || !t.processDecodedEvent(event) {
+			continue
+		}
+
+		derivatives := t.deriveEvent(event)
+		replayed := []*trace.Event{event}
+		for i := range derivatives {
+			replayed = append(replayed, &derivatives[i])
+		}
+		for _, e := range replayed {
+			if !t.prepareEmittedEvent(e) {
+				continue
+			}
+			select {
+			case t.config.ChanEvents <- *e:
+				t.stats.EventCount.Increment()
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// initReplay creates the caches used by events processing when replaying on a tracee which wasn't initialized
+func (t *Tracee) initReplay() error {
+	if t.fileHashes != nil {
+		// tracee was initialized
+		return nil
+	}
+
+	var err error
+	t.fileHashes, err = lru.New(fileHashesCacheSize)
+	if err != nil {
+		return err
+	}
+	t.recentExecs, err = lru.New(recentExecsSize)
+	if err != nil {
+		return err
+	}
+	t.cgroupPaths, err = lru.New(cgroupPathsCacheSize)
+	if err != nil {
+		return err
+	}
+	t.indexedWrites, err = lru.New(firstWritesCacheSize)
+	if err != nil {
+		return err
+	}
+	t.firstWrites, err = lru.New(firstWritesCacheSize)
+	if err != nil {
+		return err
+	}
+	t.profiledFiles = make(map[string]profilerInfo)
+	if t.config.maxPidsCache == 0 {
+		t.config.maxPidsCache = 5
+	}
+	t.pidsInMntns.Init(t.config.maxPidsCache)
+	return nil
+}
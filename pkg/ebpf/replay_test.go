@@ -0,0 +1,161 @@
+package ebpf
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/bufferdecoder"
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/events/derive"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRawOpenatEvent encodes an openat event the way the bpf programs submit it
+func newRawOpenatEvent(t *testing.T, ts uint64, pid uint32, pathname string, flags int32) []byte {
+	ctx := bufferdecoder.Context{
+		Ts:      ts,
+		Pid:     pid,
+		Tid:     pid,
+		HostPid: pid,
+		HostTid: pid,
+		MntID:   1,
+		EventID: events.Openat,
+		Argnum:  2,
+	}
+	copy(ctx.Comm[:], "cat")
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, binary.Write(buf, binary.LittleEndian, ctx))
+	// pathname is the second parameter of openat, and flags the third
+	require.NoError(t, binary.Write(buf, binary.LittleEndian, uint8(1)))
+	require.NoError(t, binary.Write(buf, binary.LittleEndian, uint32(len(pathname)+1)))
+	buf.WriteString(pathname)
+	buf.WriteByte(0)
+	require.NoError(t, binary.Write(buf, binary.LittleEndian, uint8(2)))
+	require.NoError(t, binary.Write(buf, binary.LittleEndian, flags))
+
+	return buf.Bytes()
+}
+
+func newReplayTestTracee(t *testing.T) *Tracee {
+	trc := newTestTracee(t, Config{
+		ChanEvents: make(chan trace.Event, 16),
+		ChanErrors: make(chan error, 16),
+	})
+	trc.events = map[events.ID]eventConfig{
+		events.Openat: {submit: true, emit: true},
+	}
+	require.NoError(t, trc.config.Filter.ExprFilter.Parse("args.pathname != '/etc/shadow'"))
+	return trc
+}
+
+func collectEvents(ch chan trace.Event) []trace.Event {
+	var collected []trace.Event
+	for {
+		select {
+		case e := <-ch:
+			collected = append(collected, e)
+		default:
+			return collected
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	rawEvents := [][]byte{
+		newRawOpenatEvent(t, 1000, 42, "/etc/passwd", 0),
+		newRawOpenatEvent(t, 2000, 42, "/etc/shadow", 0),
+		newRawOpenatEvent(t, 3000, 43, "/tmp/file", 0x41),
+	}
+
+	// trace the events through the pipeline stages, recording them
+	recording := &bytes.Buffer{}
+	traced := newReplayTestTracee(t)
+	traced.rawEventsRecorder = newRawEventsRecorder(recording)
+	traced.eventsChannel = make(chan []byte, len(rawEvents))
+	for _, dataRaw := range rawEvents {
+		traced.eventsChannel <- dataRaw
+	}
+	close(traced.eventsChannel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	decoded, _ := traced.decodeEvents(ctx)
+	processed, _ := traced.processEvents(ctx, decoded)
+	for range traced.sinkEvents(ctx, processed) {
+	}
+	tracedEvents := collectEvents(traced.config.ChanEvents)
+	require.Len(t, tracedEvents, 2)
+	assert.Equal(t, "/etc/passwd", tracedEvents[0].Args[0].Value)
+	assert.Equal(t, "/tmp/file", tracedEvents[1].Args[0].Value)
+	assert.Equal(t, int32(0x41), tracedEvents[1].Args[1].Value)
+
+	// replaying the recording should produce the same events
+	replayed := newReplayTestTracee(t)
+	require.NoError(t, replayed.Replay(ctx, recording))
+	assert.Equal(t, tracedEvents, collectEvents(replayed.config.ChanEvents))
+	assert.Len(t, replayed.config.ChanErrors, 0)
+	assert.Equal(t, int32(1), replayed.stats.EventsFiltered.Read())
+}
+
+func TestReplay_derivedDedup(t *testing.T) {
+	recording := &bytes.Buffer{}
+	recorder := newRawEventsRecorder(recording)
+	require.NoError(t, recorder.write(newRawOpenatEvent(t, 1000, 42, "/etc/passwd", 0)))
+	require.NoError(t, recorder.write(newRawOpenatEvent(t, 2000, 42, "/etc/group", 0)))
+	require.NoError(t, recorder.write(newRawOpenatEvent(t, 3000, 43, "/etc/passwd", 0)))
+
+	trc := newReplayTestTracee(t)
+	trc.config.Output.DerivedDedupWindow = time.Second
+	trc.derivedDedup = derive.NewDedup(trc.config.Output.DerivedDedupWindow)
+	trc.events[events.WXMemoryMapping] = eventConfig{emit: true}
+	// each open derives the same condition of its process
+	trc.eventDerivations = derive.Table{
+		events.Openat: {
+			events.WXMemoryMapping: {
+				DeriveFunction: func(event trace.Event) ([]trace.Event, []error) {
+					derived := event
+					derived.EventID = int(events.WXMemoryMapping)
+					derived.Args = []trace.Argument{{ArgMeta: trace.ArgMeta{Name: "prot", Type: "int"}, Value: int32(0x7)}}
+					return []trace.Event{derived}, nil
+				},
+				Enabled: true,
+			},
+		},
+	}
+	require.NoError(t, trc.Replay(context.Background(), recording))
+
+	var derived []trace.Event
+	for _, e := range collectEvents(trc.config.ChanEvents) {
+		if e.EventID == int(events.WXMemoryMapping) {
+			derived = append(derived, e)
+		}
+	}
+	// the condition repeated by the first process within the window is reported once
+	require.Len(t, derived, 2)
+	assert.Equal(t, 42, derived[0].HostProcessID)
+	assert.Equal(t, 43, derived[1].HostProcessID)
+	assert.Equal(t, int32(1), trc.stats.EventsFiltered.Read())
+}
+
+func TestReplay_truncatedRecording(t *testing.T) {
+	recording := &bytes.Buffer{}
+	recorder := newRawEventsRecorder(recording)
+	require.NoError(t, recorder.write(newRawOpenatEvent(t, 1000, 42, "/etc/passwd", 0)))
+	require.NoError(t, recorder.write(newRawOpenatEvent(t, 2000, 42, "/tmp/file", 0)))
+	recording.Truncate(recording.Len() - 1)
+
+	trc := newReplayTestTracee(t)
+	err := trc.Replay(context.Background(), recording)
+	require.EqualError(t, err, "error reading raw event: truncated recording")
+
+	// the events recorded before the truncation are still replayed
+	replayedEvents := collectEvents(trc.config.ChanEvents)
+	require.Len(t, replayedEvents, 1)
+	assert.Equal(t, "/etc/passwd", replayedEvents[0].Args[0].Value)
+}
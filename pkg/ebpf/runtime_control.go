@@ -0,0 +1,92 @@
+package ebpf
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+)
+
+// WriteProfile writes the profile of the executed files captured so far (with Capture.Profile), including their
+// hashes. It may be called while tracee is running, e.g. to dump the profile on demand
+func (t *Tracee) WriteProfile(w io.Writer) error {
+	t.profileMtx.Lock()
+	defer t.profileMtx.Unlock()
+
+	t.updateFileSHA()
+	return t.writeProfilerStats(w)
+}
+
+// SetCapturePaused pauses or resumes capturing executed files, shared objects and written files while tracee is
+// running. The events of the artifacts which aren't captured are still emitted
+func (t *Tracee) SetCapturePaused(paused bool) {
+	var value int32
+	if paused {
+		value = 1
+	}
+	atomic.StoreInt32(&t.capturePaused, value)
+}
+
+// CapturePaused checks if capturing was paused by SetCapturePaused
+func (t *Tracee) CapturePaused() bool {
+	return atomic.LoadInt32(&t.capturePaused) == 1
+}
+
+// captureSkipped checks if files aren't captured for now, as capturing was paused or is still warming up
+func (t *Tracee) captureSkipped() bool {
+	return t.CapturePaused() || t.clock.Now().Before(t.captureWarmupEnd)
+}
+
+// ReloadFilters replaces the filters applied in userspace while tracee is running: the gid, time, return value,
+// arguments number, argument, expression, quiet exec and first event filters. The other filters (the traced events
+// and the scope of the traced processes) are set in the bpf code once tracee starts, so filters changing them are
+// refused. Each event is filtered by either the previous filters or the new ones
+func (t *Tracee) ReloadFilters(filter Filter) error {
+	if !sameBPFFilters(t.filters(), &filter) {
+		return errors.New("only the filters applied in userspace can be reloaded, the traced events and the scope of the traced processes must not change")
+	}
+	t.filter.Store(&filter)
+	return nil
+}
+
+// filters returns the filters applied in userspace, which are the configured ones until they're reloaded
+func (t *Tracee) filters() *Filter {
+	if filter, ok := t.filter.Load().(*Filter); ok {
+		return filter
+	}
+	return t.config.Filter
+}
+
+// sameBPFFilters checks if two filters have the same filters applied in the bpf code
+func sameBPFFilters(a *Filter, b *Filter) bool {
+	bpfFilters := func(f *Filter) Filter {
+		return Filter{
+			UIDFilter:         f.UIDFilter,
+			PIDFilter:         f.PIDFilter,
+			NewPidFilter:      f.NewPidFilter,
+			MntNSFilter:       f.MntNSFilter,
+			PidNSFilter:       f.PidNSFilter,
+			UTSFilter:         f.UTSFilter,
+			CommFilter:        f.CommFilter,
+			ContFilter:        f.ContFilter,
+			NewContFilter:     f.NewContFilter,
+			ContIDFilter:      f.ContIDFilter,
+			ProcessTreeFilter: f.ProcessTreeFilter,
+			BinaryFilter:      f.BinaryFilter,
+			Follow:            f.Follow,
+			NetFilter:         f.NetFilter,
+		}
+	}
+	// the events to trace aren't ordered
+	eventsSet := func(ids []events.ID) map[events.ID]struct{} {
+		set := make(map[events.ID]struct{}, len(ids))
+		for _, id := range ids {
+			set[id] = struct{}{}
+		}
+		return set
+	}
+	return reflect.DeepEqual(bpfFilters(a), bpfFilters(b)) &&
+		reflect.DeepEqual(eventsSet(a.EventsToTrace), eventsSet(b.EventsToTrace))
+}
@@ -0,0 +1,115 @@
+package ebpf
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/bufferdecoder"
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/filters"
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetCapturePaused(t *testing.T) {
+	f, err := ioutil.TempFile("", "TestSetCapturePaused-*.so")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	require.NoError(t, f.Close())
+
+	trc := newTestTracee(t, Config{Capture: &CaptureConfig{SharedObjects: true}})
+
+	trc.SetCapturePaused(true)
+	assert.True(t, trc.CapturePaused())
+	require.NoError(t, trc.processEvent(newSharedObjectLoadedEvent(1000, f.Name(), 1)))
+	assert.Equal(t, int32(0), trc.stats.CapFileCount.Read())
+
+	// the library is captured once capturing is resumed
+	trc.SetCapturePaused(false)
+	assert.False(t, trc.CapturePaused())
+	require.NoError(t, trc.processEvent(newSharedObjectLoadedEvent(2000, f.Name(), 1)))
+	assert.Equal(t, int32(1), trc.stats.CapFileCount.Read())
+}
+
+func TestReloadFilters(t *testing.T) {
+	trc := newTestTracee(t, Config{})
+	trc.config.Filter.EventsToTrace = []events.ID{events.Execve, events.Openat}
+	require.NoError(t, trc.config.Filter.ArgFilter.Parse("execve.pathname", "=/usr/bin/ls", events.Definitions.NamesToIDs()))
+
+	ctx := &bufferdecoder.Context{EventID: events.Execve}
+	args := []trace.Argument{{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/usr/bin/id"}}
+	assert.False(t, trc.shouldProcessEvent(ctx, args))
+
+	// the events to trace may be given in any order
+	reloaded := *trc.config.Filter
+	reloaded.EventsToTrace = []events.ID{events.Openat, events.Execve}
+	reloaded.ArgFilter = &filters.ArgFilter{Filters: make(map[events.ID]map[string]filters.ArgFilterVal)}
+	require.NoError(t, reloaded.ArgFilter.Parse("execve.pathname", "=/usr/bin/id", events.Definitions.NamesToIDs()))
+	require.NoError(t, trc.ReloadFilters(reloaded))
+	assert.True(t, trc.shouldProcessEvent(ctx, args))
+
+	t.Run("bpf filters", func(t *testing.T) {
+		for name, change := range map[string]func(f *Filter){
+			"events": func(f *Filter) { f.EventsToTrace = []events.ID{events.Execve} },
+			"follow": func(f *Filter) { f.Follow = true },
+			"uid":    func(f *Filter) { f.UIDFilter = &filters.UIntFilter{Equal: []uint64{0}, Enabled: true} },
+			"comm":   func(f *Filter) { f.CommFilter = &filters.StringFilter{Equal: []string{"ls"}, Enabled: true} },
+			"net":    func(f *Filter) { f.NetFilter = &NetIfaces{Ifaces: []string{"lo"}} },
+		} {
+			filter := reloaded
+			change(&filter)
+			assert.Error(t, trc.ReloadFilters(filter), name)
+		}
+		// the filters in use are kept
+		assert.True(t, trc.shouldProcessEvent(ctx, args))
+	})
+}
+
+func Test_captureWarmup(t *testing.T) {
+	f, err := ioutil.TempFile("", "Test_captureWarmup-*")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	require.NoError(t, f.Close())
+
+	trc := newTestTracee(t, Config{
+		Capture: &CaptureConfig{Exec: true, WarmupDelay: 10 * time.Second},
+		Output:  &OutputConfig{ExecHash: true},
+	})
+	clock := utils.NewFakeClock(time.Unix(1661500000, 0), 0)
+	trc.clock = clock
+	trc.captureWarmupEnd = clock.Now().Add(trc.config.Capture.WarmupDelay)
+
+	// during the warmup the events are still processed, without capturing their files
+	event := newExecEvent(t, f.Name())
+	require.NoError(t, trc.processEvent(event))
+	assert.NotNil(t, events.GetArg(event, "sha256"))
+	assert.Equal(t, int32(0), trc.stats.CapFileCount.Read())
+
+	clock.Advance(9 * time.Second)
+	require.NoError(t, trc.processEvent(newExecEvent(t, f.Name())))
+	assert.Equal(t, int32(0), trc.stats.CapFileCount.Read())
+
+	// the file is captured once the warmup is over
+	clock.Advance(time.Second)
+	require.NoError(t, trc.processEvent(newExecEvent(t, f.Name())))
+	assert.Equal(t, int32(1), trc.stats.CapFileCount.Read())
+}
+
+func TestWriteProfile(t *testing.T) {
+	trc := newTestTracee(t, Config{Capture: &CaptureConfig{Profile: true}})
+	trc.updateProfile("host/exec.ls:1", 123)
+	trc.updateProfile("host/exec.ls:1", 456)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, trc.WriteProfile(out))
+
+	var profile map[string]profilerInfo
+	require.NoError(t, json.Unmarshal(out.Bytes(), &profile))
+	assert.Equal(t, profilerInfo{Times: 2}, profile["host/exec.ls:1"])
+}
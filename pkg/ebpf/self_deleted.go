@@ -0,0 +1,60 @@
+package ebpf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// recentExecsSize is the number of recently executed processes watched for the deletion of their executable
+const recentExecsSize = 4096
+
+// recentExec is the executable of a recently executed process
+type recentExec struct {
+	path     string
+	execTime time.Time
+}
+
+// trackExec starts watching the executable of a process which was just executed
+func (t *Tracee) trackExec(hostPid int, path string) {
+	t.recentExecs.Add(hostPid, recentExec{path: path, execTime: t.clock.Now()})
+}
+
+// checkSelfDeleted adds a self_deleted argument to the event if the executable of its process was deleted within
+// the configured window of its execution. Processes are marked once, on their first event processed after the
+// deletion, and are no longer watched once the window has passed
+func (t *Tracee) checkSelfDeleted(event *trace.Event) {
+	record, ok := t.recentExecs.Get(event.HostProcessID)
+	if !ok {
+		return
+	}
+	exec := record.(recentExec)
+	if t.clock.Now().Sub(exec.execTime) > t.config.Output.SelfDeletedWindow {
+		t.recentExecs.Remove(event.HostProcessID)
+		return
+	}
+	if !executableDeleted(event.HostProcessID, exec.path) {
+		return
+	}
+
+	event.Args = append(event.Args, trace.Argument{
+		ArgMeta: trace.ArgMeta{Name: "self_deleted", Type: "bool"},
+		Value:   true,
+	})
+	event.ArgsNum++
+	t.recentExecs.Remove(event.HostProcessID)
+}
+
+// executableDeleted checks if the executed file no longer exists in the mount namespace of the process. Nothing can
+// be told of a process which has already exited, so it isn't reported
+func executableDeleted(hostPid int, path string) bool {
+	root := fmt.Sprintf("/proc/%d/root", hostPid)
+	if _, err := os.Stat(root); err != nil {
+		return false
+	}
+	_, err := os.Lstat(root + path)
+	return errors.Is(err, os.ErrNotExist)
+}
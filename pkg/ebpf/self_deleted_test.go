@@ -0,0 +1,100 @@
+package ebpf
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_processEvent_selfDeleted(t *testing.T) {
+	testCases := []struct {
+		name         string
+		deleteBefore bool          // delete the executable before the exec event is processed
+		deleteAfter  time.Duration // delete the executable this time after its execution
+		persistent   bool
+		expectExec   bool
+		expectLater  bool
+	}{
+		{
+			name:       "persistent binary",
+			persistent: true,
+		},
+		{
+			name:         "deleted before exec was processed",
+			deleteBefore: true,
+			expectExec:   true,
+		},
+		{
+			name:        "deleted within window",
+			deleteAfter: time.Second,
+			expectLater: true,
+		},
+		{
+			name:        "deleted after window",
+			deleteAfter: time.Minute,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "Test_processEvent_selfDeleted-*")
+			require.NoError(t, err)
+			defer os.Remove(f.Name())
+			require.NoError(t, f.Close())
+
+			trc := newTestTracee(t, Config{
+				Output: &OutputConfig{SelfDeletedWindow: 5 * time.Second},
+			})
+			clock := utils.NewFakeClock(time.Unix(1000, 0), 0)
+			trc.clock = clock
+
+			execEvent := newExecEvent(t, f.Name())
+			if tc.deleteBefore {
+				require.NoError(t, os.Remove(f.Name()))
+			}
+			require.NoError(t, trc.processEvent(execEvent))
+			assertSelfDeleted(t, tc.expectExec, execEvent)
+
+			clock.Advance(tc.deleteAfter)
+			if !tc.persistent && !tc.deleteBefore {
+				require.NoError(t, os.Remove(f.Name()))
+			}
+
+			laterEvent := &trace.Event{
+				EventID:       int(events.Openat),
+				EventName:     "openat",
+				HostProcessID: execEvent.HostProcessID,
+			}
+			require.NoError(t, trc.processEvent(laterEvent))
+			assertSelfDeleted(t, tc.expectLater, laterEvent)
+
+			// a process is marked once
+			nextEvent := &trace.Event{
+				EventID:       int(events.Openat),
+				EventName:     "openat",
+				HostProcessID: execEvent.HostProcessID,
+			}
+			require.NoError(t, trc.processEvent(nextEvent))
+			assertSelfDeleted(t, false, nextEvent)
+		})
+	}
+}
+
+func assertSelfDeleted(t *testing.T, expected bool, event *trace.Event) {
+	t.Helper()
+	arg := events.GetArg(event, "self_deleted")
+	if !expected {
+		assert.Nil(t, arg)
+		return
+	}
+	require.NotNil(t, arg)
+	assert.Equal(t, true, arg.Value)
+	assert.Equal(t, len(event.Args), event.ArgsNum)
+}
@@ -0,0 +1,164 @@
+package ebpf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/aquasecurity/tracee/types/trace"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// selfTestContent is the content of the file executed by the self-test
+const selfTestContent = "\x7fELF tracee self-test\n"
+
+// SelfTest validates capturing and hashing files end to end, e.g. before tracing in a new deployment: a temporary
+// file is executed by a simulated sched_process_exec event, processed as the events of the bpf code are, and the
+// hash of the event and the copy of the file in the output directory are checked against the content of the file.
+// The file is captured as configured (e.g. compressed, or content addressed) into a temporary directory of the output
+// directory, by a tracee of its own, so the state of tracee and its captures aren't affected. The temporary files
+// are removed once done
+func (t *Tracee) SelfTest() error {
+	if t.outDir == nil {
+		return fmt.Errorf("capture output directory is not initialized")
+	}
+	dir, err := ioutil.TempDir(t.outDir.Name(), ".self-test.")
+	if err != nil {
+		return fmt.Errorf("self-test: output directory %s is not writable: %v", t.outDir.Name(), err)
+	}
+	defer os.RemoveAll(dir)
+	source, err := ioutil.TempFile("", "tracee-self-test-")
+	if err != nil {
+		return fmt.Errorf("self-test: error creating the executed file: %v", err)
+	}
+	defer os.Remove(source.Name())
+	_, err = source.WriteString(selfTestContent)
+	if closeErr := source.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("self-test: error writing the executed file: %v", err)
+	}
+	sum := sha256.Sum256([]byte(selfTestContent))
+	expectedHash := hex.EncodeToString(sum[:])
+
+	st, err := t.newSelfTestTracee(dir)
+	if err != nil {
+		return fmt.Errorf("self-test: %v", err)
+	}
+	defer st.outDir.Close()
+	if err := st.openCaptureManifest(); err != nil {
+		return fmt.Errorf("self-test: %v", err)
+	}
+	event, err := newSelfTestExecEvent(source.Name(), t.hostMntns, t.clock.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("self-test: %v", err)
+	}
+	err = st.processEvent(event)
+	if closeErr := st.closeCaptureManifest(); err == nil && closeErr != nil {
+		err = fmt.Errorf("error closing capture manifest: %v", closeErr)
+	}
+	if err != nil {
+		return fmt.Errorf("self-test: error capturing %s: %v", source.Name(), err)
+	}
+
+	hashArg := events.GetArg(event, "sha256")
+	if hashArg == nil || hashArg.Value != expectedHash {
+		var hash interface{}
+		if hashArg != nil {
+			hash = hashArg.Value
+		}
+		return fmt.Errorf("self-test: %s was hashed as %v, expected %s", source.Name(), hash, expectedHash)
+	}
+	index, err := LoadCaptureIndex(dir)
+	if err != nil {
+		return fmt.Errorf("self-test: %v", err)
+	}
+	records := index.Records()
+	if len(records) != 1 {
+		return fmt.Errorf("self-test: %s wasn't captured", source.Name())
+	}
+	if _, err := os.Stat(records[0].Path); err != nil {
+		return fmt.Errorf("self-test: the capture of %s is missing: %v", source.Name(), err)
+	}
+	if records[0].SHA256 != expectedHash {
+		return fmt.Errorf("self-test: the capture of %s has sha256 %q, expected %s", source.Name(), records[0].SHA256, expectedHash)
+	}
+	return nil
+}
+
+// newSelfTestTracee returns a tracee capturing executed files into the given directory and hashing them, with the
+// settings of tracee changing how files are copied and hashed. Processing its events has no side effects other than
+// the captures (e.g. no hooks are run and no events are emitted)
+func (t *Tracee) newSelfTestTracee(dir string) (*Tracee, error) {
+	config := t.config
+	config.ProcessInfo = false
+	config.Capture = &CaptureConfig{
+		OutputPath:        dir,
+		Exec:              true,
+		Manifest:          true,
+		DedupByInode:      t.config.Capture.DedupByInode,
+		HashMmapThreshold: t.config.Capture.HashMmapThreshold,
+		HashXattr:         t.config.Capture.HashXattr,
+		ContentAddressed:  t.config.Capture.ContentAddressed,
+		MirrorPaths:       t.config.Capture.MirrorPaths,
+		Compression:       t.config.Capture.Compression,
+		CompressionLevel:  t.config.Capture.CompressionLevel,
+	}
+	config.Output = &OutputConfig{ExecHash: true}
+
+	outDir, err := utils.OpenExistingDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening out directory: %v", err)
+	}
+	fileHashes, err := lru.New(fileHashesCacheSize)
+	if err != nil {
+		outDir.Close()
+		return nil, err
+	}
+	st := &Tracee{
+		config:         config,
+		clock:          t.clock,
+		outDir:         outDir,
+		hostMntns:      t.hostMntns,
+		fileHashes:     fileHashes,
+		capturedFiles:  make(map[string]int64),
+		capturedHashes: make(map[string]string),
+		processTree:    make(map[int]processNode),
+	}
+	st.pidsInMntns.Init(1)
+	return st, nil
+}
+
+// newSelfTestExecEvent returns a sched_process_exec event of tracee executing the given file
+func newSelfTestExecEvent(path string, hostMntns uint32, ts int64) (*trace.Event, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var ctime uint64
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		ctime = uint64(stat.Ctim.Nano())
+	}
+	def := events.Definitions.Get(events.SchedProcessExec)
+	return &trace.Event{
+		Timestamp:     int(ts),
+		ProcessID:     os.Getpid(),
+		HostProcessID: os.Getpid(),
+		ProcessName:   filepath.Base(path),
+		MountNS:       int(hostMntns),
+		EventID:       int(events.SchedProcessExec),
+		EventName:     def.Name,
+		ArgsNum:       2,
+		Args: []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: path},
+			{ArgMeta: trace.ArgMeta{Name: "ctime", Type: "unsigned long"}, Value: ctime},
+		},
+	}, nil
+}
@@ -0,0 +1,51 @@
+package ebpf
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfTest(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		capture CaptureConfig
+	}{
+		{name: "defaults"},
+		{name: "compressed", capture: CaptureConfig{Compression: CaptureCompressionGzip}},
+		{name: "content addressed", capture: CaptureConfig{ContentAddressed: true, DedupByInode: true}},
+		{name: "mapped hashing", capture: CaptureConfig{HashMmapThreshold: 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// the captures of tracee aren't configured for the self-test
+			tc.capture.PostHook = "false"
+			tc.capture.QueueDepth = 1
+			trc := newTestTracee(t, Config{Capture: &tc.capture})
+			require.NoError(t, trc.SelfTest())
+
+			// tracee isn't affected, and the self-test cleans up after itself
+			entries, err := ioutil.ReadDir(trc.outDir.Name())
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+			assert.Empty(t, trc.capturedFiles)
+			assert.Equal(t, 0, trc.fileHashes.Len())
+			assert.Equal(t, int32(0), trc.stats.CapFileCount.Read())
+		})
+	}
+
+	t.Run("output directory not writable", func(t *testing.T) {
+		trc := newTestTracee(t, Config{})
+		require.NoError(t, os.Remove(trc.outDir.Name()))
+		err := trc.SelfTest()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "self-test: output directory "+trc.outDir.Name()+" is not writable")
+	})
+
+	t.Run("output directory not initialized", func(t *testing.T) {
+		trc := &Tracee{config: Config{Capture: &CaptureConfig{}}}
+		assert.EqualError(t, trc.SelfTest(), "capture output directory is not initialized")
+	})
+}
@@ -0,0 +1,122 @@
+package ebpf
+
+import (
+	"fmt"
+
+	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// captureFile copies a file into the output directory, unless it was already captured with the same ctime. With
+// Capture.QueueDepth, the copy is queued for the capture workers instead (see queueCapture).
+// It returns the path of the copy in the output directory, or an empty path if the file was already captured or its
+// copy was queued
+func (t *Tracee) captureFile(sourcePath string, capturedFileID string, destinationFilePath string, ctime int64) (string, error) {
+	//don't capture same file twice unless it was modified
+	if lastCtime, ok := t.capturedFiles[capturedFileID]; ok && lastCtime == ctime {
+		return "", nil
+	}
+
+	if t.captureQueue != nil {
+		// the file is marked as captured once queued, so it isn't queued again while it's copied
+		if t.queueCapture(captureJob{sourcePath: sourcePath, destinationFilePath: destinationFilePath}) {
+			t.markCaptured(capturedFileID, ctime)
+			t.eventCaptured = true
+		}
+		return "", nil
+	}
+	capturedPath, err := t.copyCapturedFile(sourcePath, destinationFilePath)
+	if err != nil {
+		return "", err
+	}
+	//mark this file as captured
+	t.markCaptured(capturedFileID, ctime)
+	t.eventCaptured = true
+	return capturedPath, nil
+}
+
+// copyCapturedFile copies a captured file into the output directory. With Capture.Compression, the copy is
+// compressed and the extension of the algorithm is added to its path. It returns the path of the copy
+func (t *Tracee) copyCapturedFile(sourcePath string, destinationFilePath string) (string, error) {
+	// the source and destination files are open at the same time
+	t.openFiles.acquire(2)
+	var copied int64
+	var err error
+	if compressor, ok := t.captureCompressor(); ok {
+		destinationFilePath += compressor.ext
+		copied, err = copyCompressed(sourcePath, t.outDir, destinationFilePath, compressor, t.config.Capture.CompressionLevel)
+	} else {
+		copied, err = utils.CopyRegularFileByRelativePath(sourcePath, t.outDir, destinationFilePath)
+	}
+	t.openFiles.release(2)
+	if err != nil {
+		return "", err
+	}
+	t.stats.CapFileCount.Increment()
+	t.stats.CapBytesCount.Increment(int(copied))
+	t.recordCapture(sourcePath, destinationFilePath, "")
+	t.runPostHook(destinationFilePath)
+	return destinationFilePath, nil
+}
+
+// captureSharedObject captures the shared object loaded by a shared_object_loaded event (an executable mmap of a
+// file), read through the root of the loading process. As executed files, shared objects are captured once per
+// mount namespace, unless they are modified
+func (t *Tracee) captureSharedObject(event *trace.Event) error {
+	if t.captureSkipped() || !t.lineageCaptured(event) {
+		return nil
+	}
+	args, err := decodeArgs(event)
+	if err != nil {
+		return err
+	}
+	filePath := args.String("pathname")
+	// path should be absolute, except for e.g memfd_create files
+	if filePath == "" || filePath[0] != '/' {
+		return nil
+	}
+	// the event is still emitted for excluded processes, only the file isn't captured
+	if MatchFilter(t.config.Capture.ExcludeComms, event.ProcessName) || MatchFilter(t.config.Capture.ExcludePaths, filePath) {
+		return nil
+	}
+	ctime := args.Uint64("ctime")
+
+	captureDir := t.captureDir(event.ContainerID, uint32(event.MountNS))
+	sourceFilePath := fmt.Sprintf("/proc/%d/root%s", event.HostProcessID, filePath)
+	// the loading processes differ, so captures are deduplicated by the path in the mount namespace
+	capturedFileID := fmt.Sprintf("%s:so:%s", captureDir, filePath)
+	if t.fileTooNew(event, int64(ctime)) || t.captureThrottled(event, capturedFileID, int64(ctime)) {
+		return nil
+	}
+
+	if t.contentAddressed() {
+		hash, capturedPath, err := t.captureContent(sourceFilePath, capturedFileID, int64(ctime))
+		if err != nil {
+			return err
+		}
+		// the event references the captured content
+		event.Args = append(event.Args, trace.Argument{
+			ArgMeta: trace.ArgMeta{Name: "sha256", Type: "const char*"},
+			Value:   hash,
+		})
+		event.ArgsNum += 1
+		if capturedPath != "" && t.config.Capture.HashXattr {
+			t.storeCapturedFileHash(capturedPath, hash)
+		}
+		return nil
+	}
+
+	destinationFilePath, err := t.captureDestination(captureDir, "so", filePath, event.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	capturedPath, err := t.captureFile(sourceFilePath, capturedFileID, destinationFilePath, int64(ctime))
+	if err != nil {
+		return err
+	}
+	if capturedPath != "" && t.config.Capture.HashXattr {
+		t.storeCapturedFileHash(capturedPath, "")
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package ebpf
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSharedObjectLoadedEvent(ts int, pathname string, ctime uint64) *trace.Event {
+	return &trace.Event{
+		Timestamp:     ts,
+		EventID:       int(events.SharedObjectLoaded),
+		EventName:     "shared_object_loaded",
+		ProcessName:   "victim",
+		HostProcessID: os.Getpid(),
+		MountNS:       1,
+		ArgsNum:       5,
+		Args: []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: pathname},
+			{ArgMeta: trace.ArgMeta{Name: "flags", Type: "int"}, Value: int32(0)},
+			{ArgMeta: trace.ArgMeta{Name: "dev", Type: "dev_t"}, Value: uint32(2049)},
+			{ArgMeta: trace.ArgMeta{Name: "inode", Type: "unsigned long"}, Value: uint64(1234)},
+			{ArgMeta: trace.ArgMeta{Name: "ctime", Type: "unsigned long"}, Value: ctime},
+		},
+	}
+}
+
+func Test_captureSharedObject(t *testing.T) {
+	f, err := ioutil.TempFile("", "Test_captureSharedObject-*.so")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("injected library")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	trc := newTestTracee(t, Config{Capture: &CaptureConfig{SharedObjects: true}})
+
+	loaded := newSharedObjectLoadedEvent(1000, f.Name(), 1)
+	require.NoError(t, trc.processEvent(loaded))
+	assert.Equal(t, "injected library", readCapturedFile(t, trc, loaded))
+	assert.Equal(t, int32(1), trc.stats.CapFileCount.Read())
+
+	// loading the same library again doesn't capture it again
+	require.NoError(t, trc.processEvent(newSharedObjectLoadedEvent(2000, f.Name(), 1)))
+	assert.Equal(t, int32(1), trc.stats.CapFileCount.Read())
+
+	// a modified library is captured again
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte("replaced library"), 0644))
+	reloaded := newSharedObjectLoadedEvent(3000, f.Name(), 2)
+	require.NoError(t, trc.processEvent(reloaded))
+	assert.Equal(t, int32(2), trc.stats.CapFileCount.Read())
+	assert.Equal(t, "replaced library", readCapturedFile(t, trc, reloaded))
+	assert.Equal(t, "injected library", readCapturedFile(t, trc, loaded))
+
+	t.Run("excluded library", func(t *testing.T) {
+		trc := newTestTracee(t, Config{Capture: &CaptureConfig{SharedObjects: true, ExcludePaths: []string{f.Name()}}})
+		require.NoError(t, trc.processEvent(newSharedObjectLoadedEvent(1000, f.Name(), 1)))
+		assert.Equal(t, int32(0), trc.stats.CapFileCount.Read())
+	})
+}
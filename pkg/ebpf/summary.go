@@ -0,0 +1,83 @@
+package ebpf
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// summaryTopExecs is the number of most executed binaries listed in the run summary
+const summaryTopExecs = 5
+
+// writeSummary writes a human readable report of the run, assembled from the stats counters and the profiler
+func (t *Tracee) writeSummary(wr io.Writer) error {
+	type profiledFile struct {
+		name  string
+		times int64
+	}
+	t.profileMtx.Lock()
+	profiled := make([]profiledFile, 0, len(t.profiledFiles))
+	for name, info := range t.profiledFiles {
+		profiled = append(profiled, profiledFile{name, info.Times})
+	}
+	t.profileMtx.Unlock()
+	sort.Slice(profiled, func(i, j int) bool {
+		if profiled[i].times != profiled[j].times {
+			return profiled[i].times > profiled[j].times
+		}
+		return profiled[i].name < profiled[j].name
+	})
+	if len(profiled) > summaryTopExecs {
+		profiled = profiled[:summaryTopExecs]
+	}
+
+	hashed := 0
+	if t.fileHashes != nil {
+		hashed = t.fileHashes.Len()
+	}
+
+	lost := t.stats.LostEvCount.Read() + t.stats.LostWrCount.Read() + t.stats.LostNtCount.Read()
+
+	if _, err := fmt.Fprintf(wr, "Summary:\n"+
+		"  events processed:        %d\n"+
+		"  events lost:             %d\n"+
+		"  files captured:          %d\n"+
+		"  bytes written:           %d\n"+
+		"  unique binaries hashed:  %d\n",
+		t.stats.EventCount.Read(), lost, t.stats.CapFileCount.Read(), t.stats.CapBytesCount.Read(), hashed); err != nil {
+		return err
+	}
+
+	if len(profiled) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(wr, "  top executed binaries:\n"); err != nil {
+		return err
+	}
+	for _, p := range profiled {
+		if _, err := fmt.Fprintf(wr, "    %-6d %s\n", p.times, p.name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printSummary prints the run summary to stderr and, if configured, to the summary file
+func (t *Tracee) printSummary() error {
+	if err := t.writeSummary(os.Stderr); err != nil {
+		return err
+	}
+	if t.config.Output.SummaryPath == "" {
+		return nil
+	}
+
+	f, err := os.Create(t.config.Output.SummaryPath)
+	if err != nil {
+		return fmt.Errorf("unable to open summary file for writing: %s", err)
+	}
+	defer f.Close()
+
+	return t.writeSummary(f)
+}
@@ -0,0 +1,66 @@
+package ebpf
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_writeSummary(t *testing.T) {
+	fileHashes, err := lru.New(1024)
+	require.NoError(t, err)
+	fileHashes.Add("host:/usr/bin/ls", fileExecInfo{1, "abc"})
+	fileHashes.Add("host:/usr/bin/cat", fileExecInfo{2, "def"})
+
+	trc := Tracee{
+		fileHashes:    fileHashes,
+		profiledFiles: make(map[string]profilerInfo),
+	}
+	for i := 1; i <= 7; i++ {
+		trc.profiledFiles[fmt.Sprintf("host/exec.bin%d:1", i)] = profilerInfo{Times: int64(i)}
+	}
+	trc.stats.EventCount.Increment(100)
+	trc.stats.LostEvCount.Increment(3)
+	trc.stats.LostWrCount.Increment(2)
+	trc.stats.CapFileCount.Increment(4)
+	trc.stats.CapBytesCount.Increment(4096)
+
+	var wr bytes.Buffer
+	require.NoError(t, trc.writeSummary(&wr))
+	summary := wr.String()
+
+	assert.Contains(t, summary, "events processed:        100\n")
+	assert.Contains(t, summary, "events lost:             5\n")
+	assert.Contains(t, summary, "files captured:          4\n")
+	assert.Contains(t, summary, "bytes written:           4096\n")
+	assert.Contains(t, summary, "unique binaries hashed:  2\n")
+	assert.Contains(t, summary, "top executed binaries:\n"+
+		"    7      host/exec.bin7:1\n"+
+		"    6      host/exec.bin6:1\n"+
+		"    5      host/exec.bin5:1\n"+
+		"    4      host/exec.bin4:1\n"+
+		"    3      host/exec.bin3:1\n")
+	assert.NotContains(t, summary, "host/exec.bin2:1")
+}
+
+func Test_writeSummary_concurrentProfiling(t *testing.T) {
+	trc := Tracee{profiledFiles: make(map[string]profilerInfo)}
+
+	// files executed while the summary is written (e.g. on a signal) are profiled concurrently
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			trc.updateProfile(fmt.Sprintf("host/exec.bin%d:1", i), uint64(i))
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, trc.writeSummary(ioutil.Discard))
+	}
+	<-done
+}
@@ -13,6 +13,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 	"unsafe"
@@ -30,13 +32,13 @@ import (
 	"github.com/aquasecurity/tracee/pkg/events/queue"
 	"github.com/aquasecurity/tracee/pkg/events/sorting"
 	"github.com/aquasecurity/tracee/pkg/events/trigger"
+	"github.com/aquasecurity/tracee/pkg/logger"
 	"github.com/aquasecurity/tracee/pkg/metrics"
 	"github.com/aquasecurity/tracee/pkg/procinfo"
 	"github.com/aquasecurity/tracee/pkg/utils"
 	"github.com/aquasecurity/tracee/pkg/utils/sharedobjs"
 	"github.com/aquasecurity/tracee/types/trace"
 	lru "github.com/hashicorp/golang-lru"
-	"golang.org/x/sys/unix"
 )
 
 // Config is a struct containing user defined configuration of tracee
@@ -59,6 +61,15 @@ type Config struct {
 	OSInfo             *helpers.OSInfo
 	Sockets            runtime.Sockets
 	ContainersEnrich   bool
+	ArgTransforms      events.ArgTransforms // transforms applied to event arguments after processing
+	// WriteThenExecWindow is the maximal time between the last write of a file and its execution for a
+	// write_then_exec event to be derived (default: 1 minute)
+	WriteThenExecWindow time.Duration
+	// Logger receives the internal diagnostics of tracee at their levels, as structured fields. Without it,
+	// warnings and errors are sent to ChanErrors
+	Logger *logger.Logger
+	// RecordRawEvents records the raw events received from the bpf programs, to be replayed later with Tracee.Replay
+	RecordRawEvents io.Writer
 }
 
 type CaptureConfig struct {
@@ -72,17 +83,190 @@ type CaptureConfig struct {
 	NetIfaces       *NetIfaces
 	NetPerContainer bool
 	NetPerProcess   bool
+	// NetPcapRotateSize also writes the captured packets to libpcap files in the output directory, moving to a new
+	// file once the current one reaches this size in bytes (0 means disabled)
+	NetPcapRotateSize int64
+	// ExcludeComms and ExcludePaths skip capturing and hashing executed files of matching process names or
+	// file paths. Values support the same wildcards as argument filters
+	ExcludeComms []string
+	ExcludePaths []string
+	// ParentComms and ParentPaths capture executed, loaded and opened files only for processes with an ancestor of a
+	// matching name or executable path (e.g. children of web servers), skipping the captures of other lineages
+	// altogether. Their events are still emitted and executed files are still hashed. Values support the same
+	// wildcards as argument filters
+	ParentComms []string
+	ParentPaths []string
+	// CaptureUnknownParents captures the files of processes whose lineage ends at an unknown ancestor without a
+	// match of ParentComms or ParentPaths (e.g. processes started before tracing), rather than skipping them
+	CaptureUnknownParents bool
+	// DedupByInode deduplicates the captures and hashes of executed files by their device and inode rather than their
+	// path, as written files are, so hardlinks to the same file are captured once, by the path they are first
+	// executed by
+	DedupByInode bool
+	// AlwaysCapturePaths captures executed files of matching paths on every exec, even if they were already
+	// captured and weren't modified since, e.g. to verify capture pipelines or to closely monitor specific binaries.
+	// Values support the same wildcards as argument filters
+	AlwaysCapturePaths []string
+	// FileTypes captures only executed files of these types, as told by their magic bytes (FileTypeELF,
+	// FileTypeScript, FileTypePE or FileTypeOther). Skipped files are still hashed (empty means all types)
+	FileTypes []string
+	// FirstWriteOnly captures written files only as they are first written, skipping any later writes to them. The
+	// most recently written files are tracked, so a file written again once many other files were written since is
+	// captured again
+	FirstWriteOnly bool
+	// WriteTailSize adds the last WriteTailSize bytes of written files to write events as a tail argument, read
+	// once the write was done (0 means disabled)
+	WriteTailSize int64
+	// SharedObjects captures the files mapped as executable by processes (i.e. loaded shared objects), as they are
+	// reported by shared_object_loaded events
+	SharedObjects bool
+	// FileOpenPaths captures the files opened at matching paths, as they are reported by security_file_open
+	// events, whether or not they are written (e.g. secrets which are only read). Values support the same wildcards
+	// as argument filters
+	FileOpenPaths []string
+	// Cmdline adds the command line of executed processes to exec events, as read from procfs
+	Cmdline bool
+	// HashMmapThreshold is the minimal size in bytes of files hashed by mapping them to memory, which is faster than
+	// reading large files (0 means files are always read)
+	HashMmapThreshold int64
+	// HashXattr stores the sha256 of captured executed files and kernel modules as their user.tracee.sha256
+	// extended attribute, where the output directory supports it
+	HashXattr bool
+	// ContentAddressed stores captured executed files and shared objects in the output directory by their sha256,
+	// as cas/<sha256[:2]>/<sha256>, so identical content captured for different containers is stored once. Events
+	// reference the captured content by their sha256 argument. Requires Output.ExecHash, without which files are
+	// captured in the plain layout
+	ContentAddressed bool
+	// MirrorPaths stores captured executed, loaded and opened files under their original path in their capture
+	// directory (e.g. host/usr/bin/curl), rather than by their base name and the timestamp of their event (e.g.
+	// host/exec.1661502472416361017.curl). Files captured by their content aren't mirrored
+	MirrorPaths bool
+	// Manifest records the captured executed, loaded and opened files in the manifest.jsonl file of the output
+	// directory, with their source, inode and sha256, to be looked up by LoadCaptureIndex
+	Manifest bool
+	// ManifestCompactInterval compacts the capture manifest every this interval while tracee runs, dropping the
+	// records of captures superseded by newer captures of the same files (see Tracee.CompactCaptureManifest). Requires
+	// Manifest (0 means the manifest is never compacted)
+	ManifestCompactInterval time.Duration
+	// PersistDedup saves the captured files and the cached file hashes to the output directory on shutdown, and
+	// restores them on startup, so files already captured or hashed by a previous run aren't processed again
+	PersistDedup bool
+	// MaxOpenFiles limits the number of files opened concurrently for capturing and hashing (0 means unlimited)
+	MaxOpenFiles int
+	// NamespaceRate limits the captures of files (executed, loaded shared objects and opened files) of each mount
+	// namespace to this number per second, allowing bursts of as many, so a noisy container can't monopolize the
+	// capture workers and the disk. Captures over the rate are skipped (0 means unlimited)
+	NamespaceRate int
+	// NamespaceMaxFiles limits the captured files (by inode or by path) known of each mount namespace (container) to
+	// this number, forgetting the oldest captured files of a namespace beyond it, so the captures of a container
+	// touching millions of files don't grow without bounds. Forgotten files are captured again if they are seen
+	// again (0 means unlimited)
+	NamespaceMaxFiles int
+	// WarmupDelay skips capturing files for a while after tracee starts, so capturing the files of the processes
+	// already running on a busy host doesn't cause a storm of I/O on startup. The events are still emitted (0 means
+	// capturing starts immediately)
+	WarmupDelay time.Duration
+	// MinFileAge skips capturing files changed (as of their ctime) within MinFileAge before their event, as they may
+	// be transient files deleted right after they're used, whose captures would be wasted I/O. The events are still
+	// emitted (0 means files are captured whatever their age)
+	MinFileAge time.Duration
+	// Compression compresses the copies of captured executed, loaded and opened files with this algorithm
+	// (CaptureCompressionGzip, or CaptureCompressionZstd when built with the zstd build tag), adding its extension to
+	// their names. Files captured by their content, written files and kernel modules aren't compressed
+	Compression string
+	// CompressionLevel is the level of the compression algorithm (0 means the default level of the algorithm)
+	CompressionLevel int
+	// QueueDepth copies the captured executed, loaded and opened files by capture workers, through a queue of this
+	// depth, rather than by the events pipeline. Captures which can't be queued as the queue is full are dropped
+	// and counted, so bursts of captures can't hold back the pipeline, at the cost of missing captures. Files stored
+	// by their content are still copied by the pipeline, as their events reference their hash (0 means the
+	// pipeline copies the files)
+	QueueDepth int
+	// QueueWorkers is the number of capture workers copying the queued files (default: 4)
+	QueueWorkers int
+	// ContainerExport enables exporting the files changed by containers, by Tracee.ExportContainerChanges
+	ContainerExport bool
+	// ContainerExportMaxSize limits the size of the content of the files exported from a container, skipping the
+	// files over it (0 means unlimited)
+	ContainerExportMaxSize int64
+	// HashCacheStatsInterval periodically logs the utilization and the hit ratio of the cache of executed files
+	// hashes at info level, for tuning its size (0 means disabled)
+	HashCacheStatsInterval time.Duration
+	// PostHook is a command run in the background on every executed, loaded and opened file copied into the output
+	// directory, e.g. to scan it (as "clamscan --no-summary {path}"). It's split into arguments by whitespace and
+	// isn't run by a shell, and {path} is replaced by the path of the copy, which is the last argument if there's no
+	// {path}. Its result is emitted as a capture_hook event, if selected
+	PostHook string
+	// PostHookTimeout kills the hooks running for longer (default: 30s)
+	PostHookTimeout time.Duration
+	// PostHookConcurrency limits the hooks running at once. Files captured while as many run aren't hooked, and are
+	// counted (default: 2)
+	PostHookConcurrency int
 }
 
 type OutputConfig struct {
-	StackAddresses    bool
-	DetectSyscall     bool
-	ExecEnv           bool
-	RelativeTime      bool
+	StackAddresses bool
+	DetectSyscall  bool
+	ExecEnv        bool
+	RelativeTime   bool
+	BootTimestamp  bool // add the raw timestamp of events, in nanoseconds since boot, along their normalized one
+	CgroupPath     bool // add the cgroup path of the process of events, as read from procfs
+	// NamespaceNames are friendly names of mount namespaces (e.g. of an orchestrator), added to events of processes
+	// of the namespaces as a namespace_name argument. Events of other namespaces have their raw mntns as the name
+	NamespaceNames map[uint32]string
+	// IncludeArgs keeps only the given arguments of the emitted events they are given for, and ExcludeArgs drops the
+	// given arguments of the emitted events, including the arguments added while processing them (e.g. sha256)
+	IncludeArgs       events.ArgNames
+	ExcludeArgs       events.ArgNames
 	ExecHash          bool
+	ParentExecHash    bool // with ExecHash, also add the hash of the parent process' executable
+	ExecMemHash       bool // add the hash of the executable image in the memory of executing processes
+	ExecGroupID       bool // add an id of the command of exec events, shared by the execs of the same file and arguments
 	ParseArguments    bool
 	ParseArgumentsFDs bool
+	IncludeRaw        bool // with ParseArguments, keep the raw values of parsed arguments alongside "<name>_decoded" ones
 	EventsSorting     bool
+	DecodeFlags       bool // add symbolic strings of bitmask arguments, keeping their raw values, and name socket domains and types
+	MaxArgLength      int  // truncate string arguments longer than this number of bytes (0 means no truncation)
+	MaxEvents         int  // stop tracing gracefully once this number of events was emitted (0 means no limit)
+	// HashDenylist is a set of lowercase sha256 hashes of known bad files. Executed files (with ExecHash) and
+	// captured kernel modules matching it trigger a malware_hash_match event
+	HashDenylist map[string]struct{}
+	// SelfDeletedWindow marks events of processes whose executable was deleted within this time of their
+	// execution with a self_deleted argument (0 means disabled)
+	SelfDeletedWindow time.Duration
+	// CoalesceWindow merges consecutive identical events (same event ID, process, thread and arguments) within this
+	// time of the first of them into it, counting them in a repeat_count argument (0 means disabled)
+	CoalesceWindow time.Duration
+	// DerivedDedupWindow drops derived events (e.g. wx_memory_mapping) repeating the condition of a derived event
+	// of the same process within this time of it, so a repeated condition is reported once per window
+	// (0 means disabled)
+	DerivedDedupWindow time.Duration
+	// Ancestry adds an ancestry argument to events, with the names of up to this number of ancestors of their
+	// process, parent first (0 means disabled)
+	Ancestry int
+	// Minimal disables the enrichment of events for the maximal throughput: executed files aren't hashed, no
+	// files are captured, events aren't derived and no arguments are added to events (e.g. ancestry or
+	// self_deleted), overriding the options enabling them. Only the state tracee relies on (processes and
+	// containers) is kept
+	Minimal bool
+	// LostChannelSize is the capacity of the channel reporting lost events from the events perf buffer.
+	// A larger buffer keeps bursts of loss reports from blocking the perf buffer polling, at the cost
+	// of 8 bytes of memory per slot. Zero means an unbuffered channel.
+	LostChannelSize int
+	// DrainTimeout bounds how long shutdown waits for the work in flight (events in the pipeline, files being
+	// captured and events buffered by the outputs) to finish, before abandoning it and logging what was abandoned.
+	// Zero means not waiting for the pipeline and captures, and waiting for the outputs until they are flushed
+	DrainTimeout time.Duration
+	// HeartbeatInterval emits a heartbeat event every interval, with the stats of the run, so consumers can tell a
+	// quiet tracee from a dead one (0 means disabled)
+	HeartbeatInterval time.Duration
+	// CapturedOnly drops the events which didn't result in the capture of a file (by its copy or, for written files,
+	// by indexing its capture), so the events emitted match the captured files
+	CapturedOnly bool
+	// Summary prints a report of the run to stderr on shutdown, and writes it to SummaryPath if given
+	Summary     bool
+	SummaryPath string
 }
 
 // InitValues determines if to initialize values that might be needed by eBPF programs
@@ -115,7 +299,7 @@ func (tc Config) Validate() error {
 			}
 			eventParams := eventDefinition.Params
 			// check if argument name exists for this event
-			argFound := false
+			argFound := events.IsEnrichmentParam(eventID, argName)
 			for i := range eventParams {
 				if eventParams[i].Name == argName {
 					argFound = true
@@ -133,6 +317,86 @@ func (tc Config) Validate() error {
 	if (tc.BlobPerfBufferSize & (tc.BlobPerfBufferSize - 1)) != 0 {
 		return fmt.Errorf("invalid perf buffer size - must be a power of 2")
 	}
+	if tc.Output.LostChannelSize < 0 {
+		return fmt.Errorf("invalid lost channel size - must not be negative")
+	}
+	if tc.Output.MaxArgLength < 0 {
+		return fmt.Errorf("invalid max argument length - must not be negative")
+	}
+	if tc.Output.MaxEvents < 0 {
+		return fmt.Errorf("invalid max events - must not be negative")
+	}
+	if len(tc.Output.HashDenylist) > 0 && !tc.Output.ExecHash {
+		return fmt.Errorf("invalid hash denylist - requires exec hash")
+	}
+	if tc.Output.SelfDeletedWindow < 0 {
+		return fmt.Errorf("invalid self deleted window - must not be negative")
+	}
+	if tc.Output.CoalesceWindow < 0 {
+		return fmt.Errorf("invalid coalesce window - must not be negative")
+	}
+	if tc.Output.DerivedDedupWindow < 0 {
+		return fmt.Errorf("invalid derived events dedup window - must not be negative")
+	}
+	if tc.Output.HeartbeatInterval < 0 {
+		return fmt.Errorf("invalid heartbeat interval - must not be negative")
+	}
+	if tc.Output.CapturedOnly && !tc.Capture.FileWrite && !tc.Capture.Exec && !tc.Capture.SharedObjects && len(tc.Capture.FileOpenPaths) == 0 {
+		return fmt.Errorf("invalid captured only - requires capturing files")
+	}
+	if tc.Output.Ancestry < 0 {
+		return fmt.Errorf("invalid ancestry - must not be negative")
+	}
+	if tc.Capture.NetPcapRotateSize < 0 {
+		return fmt.Errorf("invalid pcap rotation size - must not be negative")
+	}
+	if tc.Capture.HashMmapThreshold < 0 {
+		return fmt.Errorf("invalid hash mmap threshold - must not be negative")
+	}
+	for _, fileType := range tc.Capture.FileTypes {
+		if !isFileType(fileType) {
+			return fmt.Errorf("invalid capture file type %s", fileType)
+		}
+	}
+	if err := validateCaptureCompression(tc.Capture.Compression, tc.Capture.CompressionLevel); err != nil {
+		return err
+	}
+	if tc.Capture.QueueDepth < 0 {
+		return fmt.Errorf("invalid capture queue depth - must not be negative")
+	}
+	if tc.Capture.QueueWorkers < 0 {
+		return fmt.Errorf("invalid capture queue workers - must not be negative")
+	}
+	if tc.Capture.PostHookTimeout < 0 {
+		return fmt.Errorf("invalid capture post hook timeout - must not be negative")
+	}
+	if tc.Capture.PostHookConcurrency < 0 {
+		return fmt.Errorf("invalid capture post hook concurrency - must not be negative")
+	}
+	if tc.Capture.WriteTailSize < 0 {
+		return fmt.Errorf("invalid write tail size - must not be negative")
+	}
+	if tc.Capture.NamespaceRate < 0 {
+		return fmt.Errorf("invalid capture namespace rate - must not be negative")
+	}
+	if tc.Capture.NamespaceMaxFiles < 0 {
+		return fmt.Errorf("invalid capture namespace max files - must not be negative")
+	}
+	if tc.Capture.ContainerExportMaxSize < 0 {
+		return fmt.Errorf("invalid container export max size - must not be negative")
+	}
+	if tc.Capture.ManifestCompactInterval < 0 {
+		return fmt.Errorf("invalid capture manifest compact interval - must not be negative")
+	}
+	if tc.Capture.WarmupDelay < 0 {
+		return fmt.Errorf("invalid capture warmup delay - must not be negative")
+	}
+	if tc.Capture.MinFileAge < 0 {
+		return fmt.Errorf("invalid capture min file age - must not be negative")
+	}
+	if tc.Capture.MaxOpenFiles < 0 {
+		return fmt.Errorf("invalid max open files - must not be negative")
+	}
 	if len(tc.Capture.FilterFileWrite) > 3 {
 		return fmt.Errorf("too many file-write filters given")
 	}
@@ -161,6 +425,7 @@ type profilerInfo struct {
 	Times            int64  `json:"times,omitempty"`
 	FileHash         string `json:"file_hash,omitempty"`
 	FirstExecutionTs uint64 `json:"-"`
+	CapturedPath     string `json:"-"` // the first capture of the file, relative to the output dir
 }
 
 type fileExecInfo struct {
@@ -183,30 +448,60 @@ type Tracee struct {
 	fileWrPerfMap     *bpf.PerfBuffer
 	netPerfMap        *bpf.PerfBuffer
 	eventsChannel     chan []byte
+	rawEventsRecorder *rawEventsRecorder
 	fileWrChannel     chan []byte
 	netChannel        chan []byte
 	lostEvChannel     chan uint64
 	lostWrChannel     chan uint64
 	lostNetChannel    chan uint64
+	clock             utils.Clock
 	bootTime          uint64
 	startTime         uint64
 	stats             metrics.Stats
 	capturedFiles     map[string]int64
+	capturedMu        sync.Mutex          // guards the writes of capturedFiles, which may be saved while the pipeline runs
+	capturedHashes    map[string]string   // hashes of the files captured by their content, by captured file id
+	capturedLimit     *capturedFilesLimit // evicts the oldest captured files of namespaces with too many
+	capturedPaths     pathIndex           // captured files captured by their path, by namespace (see indexCaptured)
 	fileHashes        *lru.Cache
+	recentExecs       *lru.Cache // executables of recently executed processes, watched for their deletion
+	cgroupPaths       *lru.Cache // cgroup paths of mount namespaces and host processes, by cgroupPathKey
+	eventCaptured     bool       // whether the event being processed resulted in a capture (see processEventCaptured)
 	profiledFiles     map[string]profilerInfo
+	profileMtx        sync.Mutex   // guards profiledFiles, which may be written at runtime
+	capturePaused     int32        // set (atomically) while capturing is paused at runtime
+	filter            atomic.Value // *Filter replacing the configured filters applied in userspace (see ReloadFilters)
+	captureWarmupEnd  time.Time    // files are captured from then on (see CaptureConfig.WarmupDelay)
 	writtenFiles      map[string]string
+	writtenPaths      pathIndex                 // written files index entries, by capture dir (see indexWrittenFile)
+	indexedWrites     *lru.Cache                // written files indexed in FirstWriteOnly mode, by fileInode
+	firstWrites       *lru.Cache                // first writes of the files captured in FirstWriteOnly mode, by fileInode
 	pidsInMntns       bucketscache.BucketsCache //record the first n PIDs (host) in each mount namespace, for internal usage
+	hostMntns         uint32
+	replaying         bool             // set while replaying a recording, whose events weren't filtered by the bpf code
+	openFiles         *fileBudget      // limits the files opened concurrently for capturing
+	captureThrottle   *captureThrottle // limits the rate of captures of each mount namespace
+	captureQueue      chan captureJob  // copies of captured files queued for the capture workers, with QueueDepth
+	captureHooks      *captureHooks    // runs the post hook on the captured files, with Capture.PostHook
+	manifestMu        sync.Mutex       // guards manifest, which is written by the capture workers as well
+	manifest          *os.File         // the capture manifest, with Capture.Manifest
 	StackAddressesMap *bpf.BPFMap
 	FDArgPathMap      *bpf.BPFMap
 	netInfo           netInfo
+	packetsPcap       *rotatingPcap
 	containers        *containers.Containers
 	procInfo          *procinfo.ProcInfo
 	eventsSorter      *sorting.EventsChronologicalSorter
 	eventDerivations  derive.Table
+	derivedDedup      *derive.Dedup // nil if derived events aren't deduplicated
 	kernelSymbols     *helpers.KernelSymbolTable
 	triggerContexts   trigger.Context
 	running           bool
-	outDir            *os.File // All file operations to output dir should be through the utils package file operations (like utils.OpenAt) using this directory file.
+	stopRun           gocontext.CancelFunc // stops Run, once the events limit is reached
+	emittedEvents     int32                // events emitted so far (atomically), counted against Output.MaxEvents
+	workers           workers              // goroutines of Run, waited for on shutdown
+	processTree       map[int]processNode  // processes by host pid, for the ancestry of events
+	outDir            *os.File             // All file operations to output dir should be through the utils package file operations (like utils.OpenAt) using this directory file.
 }
 
 func (t *Tracee) Stats() *metrics.Stats {
@@ -241,8 +536,11 @@ func GetCaptureEventsList(cfg Config) map[events.ID]eventConfig {
 	if cfg.Capture.Mem {
 		captureEvents[events.CaptureMem] = eventConfig{}
 	}
-	if cfg.Capture.Profile {
-		captureEvents[events.CaptureProfile] = eventConfig{}
+	if cfg.Capture.SharedObjects {
+		captureEvents[events.CaptureSharedObject] = eventConfig{}
+	}
+	if len(cfg.Capture.FileOpenPaths) > 0 {
+		captureEvents[events.CaptureFileOpen] = eventConfig{}
 	}
 	if cfg.Capture.NetIfaces != nil {
 		captureEvents[events.CapturePcap] = eventConfig{}
@@ -276,12 +574,45 @@ func New(cfg Config) (*Tracee, error) {
 		return nil, fmt.Errorf("validation error: %v", err)
 	}
 
+	// nothing is captured in minimal mode, as the captures configure the bpf programs to submit their data
+	if cfg.Output.Minimal {
+		cfg.Capture = &CaptureConfig{OutputPath: cfg.Capture.OutputPath}
+	}
+
 	// create tracee
 	t := &Tracee{
-		config:        cfg,
-		writtenFiles:  make(map[string]string),
-		capturedFiles: make(map[string]int64),
-		events:        GetEssentialEventsList(),
+		config:          cfg,
+		clock:           utils.RealClock{},
+		openFiles:       newFileBudget(cfg.Capture.MaxOpenFiles),
+		captureThrottle: newCaptureThrottle(cfg.Capture.NamespaceRate),
+		capturedLimit:   newCapturedFilesLimit(cfg.Capture.NamespaceMaxFiles),
+		captureHooks:    newCaptureHooks(cfg.Capture.PostHook, cfg.Capture.PostHookTimeout, cfg.Capture.PostHookConcurrency),
+		writtenFiles:    make(map[string]string),
+		capturedFiles:   make(map[string]int64),
+		capturedHashes:  make(map[string]string),
+		events:          GetEssentialEventsList(),
+		processTree:     make(map[int]processNode),
+	}
+
+	if cfg.Output.DerivedDedupWindow > 0 {
+		t.derivedDedup = derive.NewDedup(cfg.Output.DerivedDedupWindow)
+	}
+
+	if cfg.RecordRawEvents != nil {
+		t.rawEventsRecorder = newRawEventsRecorder(cfg.RecordRawEvents)
+	}
+
+	if t.config.Output.DecodeFlags {
+		// the decoders are registered on a copy, so the transforms of the caller aren't changed
+		argTransforms := events.ArgTransforms{}
+		for id, transforms := range t.config.ArgTransforms {
+			argTransforms[id] = make(map[string][]events.ArgTransform, len(transforms))
+			for argName, argTransform := range transforms {
+				argTransforms[id][argName] = append([]events.ArgTransform(nil), argTransform...)
+			}
+		}
+		argTransforms.RegisterFlagsDecoders()
+		t.config.ArgTransforms = argTransforms
 	}
 
 	for eventID, eCfg := range GetCaptureEventsList(cfg) {
@@ -293,6 +624,29 @@ func New(cfg Config) (*Tracee, error) {
 		t.events[e] = eventConfig{submit: true, emit: true}
 	}
 
+	// heartbeats are emitted once they are enabled, whether or not they were chosen
+	if t.config.Output.HeartbeatInterval > 0 {
+		t.events[events.Heartbeat] = eventConfig{submit: true, emit: true}
+	}
+
+	// the process tree is tracked by the fork, exec and exit events
+	if t.tracksProcessTree() {
+		for _, id := range []events.ID{events.SchedProcessFork, events.SchedProcessExec, events.SchedProcessExit} {
+			ec := t.events[id]
+			ec.submit = true
+			t.events[id] = ec
+		}
+	}
+
+	// the index of captured files follows their renames and deletions
+	if t.capturesFiles() {
+		for _, id := range []events.ID{events.SecurityInodeRename, events.SecurityInodeUnlink} {
+			ec := t.events[id]
+			ec.submit = true
+			t.events[id] = ec
+		}
+	}
+
 	// Handles all essential events dependencies
 	for id := range t.events {
 		t.handleEventsDependencies(id)
@@ -375,7 +729,27 @@ func (t *Tracee) Init() error {
 		return err
 	}
 
-	t.fileHashes, err = lru.New(1024)
+	t.fileHashes, err = lru.New(fileHashesCacheSize)
+	if err != nil {
+		t.Close()
+		return err
+	}
+	t.recentExecs, err = lru.New(recentExecsSize)
+	if err != nil {
+		t.Close()
+		return err
+	}
+	t.cgroupPaths, err = lru.New(cgroupPathsCacheSize)
+	if err != nil {
+		t.Close()
+		return err
+	}
+	t.indexedWrites, err = lru.New(firstWritesCacheSize)
+	if err != nil {
+		t.Close()
+		return err
+	}
+	t.firstWrites, err = lru.New(firstWritesCacheSize)
 	if err != nil {
 		t.Close()
 		return err
@@ -392,6 +766,7 @@ func (t *Tracee) Init() error {
 		hostMntnsString := strings.TrimSuffix(strings.TrimPrefix(hostMntnsLink, "mnt:["), "]")
 		hostMntns, err := strconv.Atoi(hostMntnsString)
 		if err == nil {
+			t.hostMntns = uint32(hostMntns)
 			t.pidsInMntns.AddBucketItem(uint32(hostMntns), 1)
 		}
 	}
@@ -417,11 +792,24 @@ func (t *Tracee) Init() error {
 		return fmt.Errorf("error writing to readiness file: %w", err)
 	}
 
+	if t.config.Capture.PersistDedup {
+		t.loadDedupState()
+	}
+	if t.config.Capture.Manifest {
+		if err := t.openCaptureManifest(); err != nil {
+			t.Close()
+			return err
+		}
+	}
+
 	t.netInfo.pcapWriters, err = lru.NewWithEvict(openPcapsLimit, t.netInfo.PcapWriterOnEvict)
 	if err != nil {
 		t.Close()
 		return err
 	}
+	if t.config.Capture.NetIfaces != nil && t.config.Capture.NetPcapRotateSize > 0 {
+		t.packetsPcap = newRotatingPcap(t.outDir, t.config.Capture.NetPcapRotateSize)
+	}
 
 	// Get reference to stack trace addresses map
 	stackAddressesMap, err := t.bpfModule.GetMap("stack_addresses")
@@ -446,19 +834,72 @@ func (t *Tracee) Init() error {
 		}
 	}
 
+	t.initTimestamps()
+	t.captureWarmupEnd = t.clock.Now().Add(t.config.Capture.WarmupDelay)
+
+	return nil
+}
+
+// initTimestamps samples the clock to calculate event timestamps from the bpf code timestamps
+func (t *Tracee) initTimestamps() {
 	// Tracee bpf code uses monotonic clock as event timestamp.
 	// Get current monotonic clock so we can calculate event timestamps relative to it.
-	var ts unix.Timespec
-	unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts)
-	startTime := ts.Nano()
+	startTime := t.clock.MonotonicNano()
 	// Calculate the boot time using the monotonic time (since this is the clock we're using as a timestamp)
 	// Note: this is NOT the real boot time, as the monotonic clock doesn't take into account system sleeps.
-	bootTime := time.Now().UnixNano() - startTime
+	bootTime := t.clock.Now().UnixNano() - startTime
 
 	t.startTime = uint64(startTime)
 	t.bootTime = uint64(bootTime)
+}
 
-	return nil
+// eventTimestamp converts a monotonic clock timestamp of the bpf code to the event timestamp
+func (t *Tracee) eventTimestamp(ts uint64) uint64 {
+	// Currently, the timestamp received from the bpf code is of the monotonic clock.
+	// Todo: The monotonic clock doesn't take into account system sleep time.
+	// Starting from kernel 5.7, we can get the timestamp relative to the system boot time instead which is preferable.
+	if t.config.Output.RelativeTime {
+		// To get the monotonic time since tracee was started, we have to subtract the start time from the timestamp.
+		return ts - t.startTime
+	}
+	// To get the current ("wall") time, we add the boot time into it.
+	return ts + t.bootTime
+}
+
+// addBootTimestamp adds the raw timestamp of an event, which is of the monotonic clock of the bpf code (nanoseconds
+// since boot), as a timestamp_ns_boot argument, so it's emitted along the timestamp it was normalized to
+func (t *Tracee) addBootTimestamp(event *trace.Event) {
+	bootTs := uint64(event.Timestamp) - t.bootTime
+	if t.config.Output.RelativeTime {
+		bootTs = uint64(event.Timestamp) + t.startTime
+	}
+	event.Args = append(event.Args, trace.Argument{
+		ArgMeta: trace.ArgMeta{Name: "timestamp_ns_boot", Type: "unsigned long"},
+		Value:   bootTs,
+	})
+	event.ArgsNum++
+}
+
+// addNamespaceName adds the name of the mount namespace of an event as of Output.NamespaceNames as a namespace_name
+// argument, falling back to the raw mntns for namespaces which aren't named
+func (t *Tracee) addNamespaceName(event *trace.Event) {
+	name, ok := t.config.Output.NamespaceNames[uint32(event.MountNS)]
+	if !ok {
+		name = strconv.FormatUint(uint64(uint32(event.MountNS)), 10)
+	}
+	event.Args = append(event.Args, trace.Argument{
+		ArgMeta: trace.ArgMeta{Name: "namespace_name", Type: "const char*"},
+		Value:   name,
+	})
+	event.ArgsNum++
+}
+
+// eventWallTime returns the wall clock time of an event in nanoseconds, whether its timestamp is relative or not
+func (t *Tracee) eventWallTime(event *trace.Event) int64 {
+	if t.config.Output.RelativeTime {
+		return int64(uint64(event.Timestamp) + t.startTime + t.bootTime)
+	}
+	return int64(event.Timestamp)
 }
 
 func (t *Tracee) generateInitValues() (InitValues, error) {
@@ -480,7 +921,7 @@ func (t *Tracee) generateInitValues() (InitValues, error) {
 func (t *Tracee) initTailCall(mapName string, mapIndexes []uint32, progName string) error {
 	bpfMap, err := t.bpfModule.GetMap(mapName)
 	if err != nil {
-		return err
+		return fmt.Errorf("could not get BPF map %s: %v", mapName, err)
 	}
 	bpfProg, err := t.bpfModule.GetProgram(progName)
 	if err != nil {
@@ -524,6 +965,16 @@ func (t *Tracee) initDerivationTable() error {
 	pathResolver := containers.InitPathResolver(&t.pidsInMntns)
 	soLoader := sharedobjs.InitContainersSymbolsLoader(&pathResolver, 1024)
 
+	writeThenExecWindow := t.config.WriteThenExecWindow
+	if writeThenExecWindow == 0 {
+		writeThenExecWindow = time.Minute
+	}
+	writeThenExec, err := derive.WriteThenExec(writeThenExecWindow)
+	if err != nil {
+		return err
+	}
+	writeThenExecEnabled := t.events[events.WriteThenExec].submit
+
 	t.eventDerivations = derive.Table{
 		events.CgroupMkdir: {
 			events.ContainerCreate: {
@@ -561,6 +1012,70 @@ func (t *Tracee) initDerivationTable() error {
 				DeriveFunction: derive.HookedSeqOps(t.kernelSymbols),
 			},
 		},
+		events.Mmap: {
+			events.WXMemoryMapping: {
+				Enabled:        t.events[events.WXMemoryMapping].submit,
+				DeriveFunction: derive.WXMemoryMapping(),
+			},
+		},
+		events.Mprotect: {
+			events.WXMemoryMapping: {
+				Enabled:        t.events[events.WXMemoryMapping].submit,
+				DeriveFunction: derive.WXMemoryMapping(),
+			},
+		},
+		events.PkeyMprotect: {
+			events.WXMemoryMapping: {
+				Enabled:        t.events[events.WXMemoryMapping].submit,
+				DeriveFunction: derive.WXMemoryMapping(),
+			},
+		},
+		events.VfsWrite: {
+			events.WriteThenExec: {
+				Enabled:        writeThenExecEnabled,
+				DeriveFunction: writeThenExec,
+			},
+		},
+		events.VfsWritev: {
+			events.WriteThenExec: {
+				Enabled:        writeThenExecEnabled,
+				DeriveFunction: writeThenExec,
+			},
+		},
+		events.KernelWrite: {
+			events.WriteThenExec: {
+				Enabled:        writeThenExecEnabled,
+				DeriveFunction: writeThenExec,
+			},
+		},
+		events.SchedProcessExec: {
+			events.WriteThenExec: {
+				Enabled:        writeThenExecEnabled,
+				DeriveFunction: writeThenExec,
+			},
+			events.MalwareHashMatch: {
+				Enabled:        t.events[events.MalwareHashMatch].submit && len(t.config.Output.HashDenylist) > 0,
+				DeriveFunction: derive.MalwareHashMatch(t.config.Output.HashDenylist),
+			},
+		},
+		events.SecurityInodeUnlink: {
+			events.FileDeleted: {
+				Enabled:        t.events[events.FileDeleted].submit,
+				DeriveFunction: derive.FileDeleted(),
+			},
+		},
+		events.CommitCreds: {
+			events.PrivilegeEscalation: {
+				Enabled:        t.events[events.PrivilegeEscalation].submit,
+				DeriveFunction: derive.PrivilegeEscalation(),
+			},
+		},
+		events.Ptrace: {
+			events.ProcessInjection: {
+				Enabled:        t.events[events.ProcessInjection].submit,
+				DeriveFunction: derive.ProcessInjection(),
+			},
+		},
 		events.SharedObjectLoaded: {
 			events.SymbolsLoaded: {
 				Enabled: t.events[events.SymbolsLoaded].submit,
@@ -615,6 +1130,7 @@ const (
 	filterProcTreeOut
 	filterCgroupIdEnabled
 	filterCgroupIdOut
+	filterBinaryEnabled
 )
 
 func (t *Tracee) getOptionsConfig() uint32 {
@@ -723,6 +1239,9 @@ func (t *Tracee) getFiltersConfig() uint32 {
 	if t.config.Filter.Follow {
 		cFilterVal = cFilterVal | filterFollowEnabled
 	}
+	if t.config.Filter.BinaryFilter != nil && t.config.Filter.BinaryFilter.Enabled {
+		cFilterVal = cFilterVal | filterBinaryEnabled
+	}
 
 	return cFilterVal
 }
@@ -826,6 +1345,9 @@ func (t *Tracee) populateBPFMaps() error {
 	errmap["uts_ns_filter"] = t.config.Filter.UTSFilter.InitBPF(t.bpfModule, "uts_ns_filter")
 	errmap["comm_filter"] = t.config.Filter.CommFilter.InitBPF(t.bpfModule, "comm_filter")
 	errmap["cont_id_filter"] = t.config.Filter.ContIDFilter.InitBPF(t.bpfModule, t.containers, "cgroup_id_filter")
+	if t.config.Filter.BinaryFilter != nil {
+		errmap["binary_filter"] = t.config.Filter.BinaryFilter.InitBPF(t.bpfModule, "binary_filter_events", "binary_filter_paths", "binary_filter")
+	}
 
 	for k, v := range errmap {
 		if v != nil {
@@ -1090,7 +1612,7 @@ func (t *Tracee) initBPF() error {
 
 	// Initialize perf buffers
 	t.eventsChannel = make(chan []byte, 1000)
-	t.lostEvChannel = make(chan uint64)
+	t.lostEvChannel = make(chan uint64, t.config.Output.LostChannelSize)
 	t.eventsPerfMap, err = t.bpfModule.InitPerfBuf("events", t.eventsChannel, t.lostEvChannel, t.config.PerfBufferSize)
 	if err != nil {
 		return fmt.Errorf("error initializing events perf map: %v", err)
@@ -1145,22 +1667,44 @@ func (t *Tracee) getProcessCtx(hostTid uint32) (procinfo.ProcessCtx, error) {
 
 // Run starts the trace. it will run until ctx is cancelled
 func (t *Tracee) Run(ctx gocontext.Context) error {
+	// tracee stops by itself once it emitted the maximal number of events
+	ctx, t.stopRun = gocontext.WithCancel(ctx)
+	defer t.stopRun()
 	t.invokeInitEvents()
 	t.triggerSyscallsIntegrityCheck(trace.Event{})
 	t.triggerSeqOpsIntegrityCheck(trace.Event{})
 	t.eventsPerfMap.Start()
 	t.fileWrPerfMap.Start()
 	t.netPerfMap.Start()
-	go t.processLostEvents()
-	go t.handleEvents(ctx)
-	go t.processFileWrites()
-	go t.processNetEvents(ctx)
+	t.workers.start("lost_events", t.processLostEvents)
+	if t.config.Capture.QueueDepth > 0 {
+		t.startCaptureQueue()
+	}
+	t.workers.start("pipeline", func() {
+		t.handleEvents(ctx)
+		// the pipeline is done queueing captures
+		t.stopCaptureQueue()
+	})
+	t.workers.start("file_writes", func() { t.processFileWrites(ctx) })
+	t.workers.start("net_events", func() { t.processNetEvents(ctx) })
+	if t.config.Capture.HashCacheStatsInterval > 0 {
+		go t.logHashCacheStats(ctx)
+	}
+	if t.config.Capture.Manifest && t.config.Capture.ManifestCompactInterval > 0 {
+		go t.compactCaptureManifestPeriodically(ctx)
+	}
+	if t.config.Output.HeartbeatInterval > 0 {
+		go t.emitHeartbeats(ctx)
+	}
 	t.running = true
-	// block until ctx is cancelled elsewhere
+	// block until ctx is cancelled elsewhere, or the events limit is reached
 	<-ctx.Done()
 	t.eventsPerfMap.Stop()
 	t.fileWrPerfMap.Stop()
 	t.netPerfMap.Stop()
+	if t.config.Output.DrainTimeout > 0 {
+		t.drainWorkers(t.config.Output.DrainTimeout)
+	}
 	// capture profiler stats
 	if t.config.Capture.Profile {
 		f, err := utils.CreateAt(t.outDir, "tracee.profile")
@@ -1168,14 +1712,22 @@ func (t *Tracee) Run(ctx gocontext.Context) error {
 			return fmt.Errorf("unable to open tracee.profile for writing: %s", err)
 		}
 
-		// update SHA for all captured files
-		t.updateFileSHA()
-
-		if err := t.writeProfilerStats(f); err != nil {
+		if err := t.WriteProfile(f); err != nil {
 			return fmt.Errorf("unable to write profiler output: %s", err)
 		}
 	}
 
+	// persist what was captured and hashed, for the next run
+	if t.config.Capture.PersistDedup {
+		if err := t.saveDedupState(); err != nil {
+			return err
+		}
+	}
+	if err := t.closeCaptureManifest(); err != nil {
+		return fmt.Errorf("error closing capture manifest: %v", err)
+	}
+	t.captureHooks.stop()
+
 	// record index of written files
 	if t.config.Capture.FileWrite {
 		destinationFilePath := "written_files"
@@ -1202,6 +1754,12 @@ func (t *Tracee) Run(ctx gocontext.Context) error {
 		}
 	}
 
+	if t.config.Output.Summary {
+		if err := t.printSummary(); err != nil {
+			return fmt.Errorf("unable to write summary: %s", err)
+		}
+	}
+
 	t.Close()
 	return nil
 }
@@ -1211,7 +1769,7 @@ func (t *Tracee) Close() {
 	if t.probes != nil {
 		err := t.probes.DetachAll()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to detach probes when closing tracee: %s", err)
+			t.closeWarning("failed to detach probes when closing tracee", err)
 		}
 	}
 
@@ -1222,35 +1780,61 @@ func (t *Tracee) Close() {
 	if t.containers != nil {
 		err := t.containers.Close()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to clean containers module when closing tracee: %s", err)
+			t.closeWarning("failed to clean containers module when closing tracee", err)
+		}
+	}
+
+	if t.packetsPcap != nil {
+		if err := t.packetsPcap.Close(); err != nil {
+			t.closeWarning("failed to close pcap file when closing tracee", err)
 		}
 	}
 	t.running = false
 }
 
+// closeWarning reports a failure to clean up when closing tracee. Without a logger it is written to stderr, since
+// the errors channel may no longer be read
+func (t *Tracee) closeWarning(msg string, err error) {
+	if t.config.Logger != nil {
+		t.config.Logger.Warn(msg, "error", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s: %s", msg, err)
+}
+
 func (t *Tracee) Running() bool {
 	return t.running
 }
 
 func (t *Tracee) computeOutFileHash(fileName string) (string, error) {
-	f, err := utils.OpenAt(t.outDir, fileName, os.O_RDONLY, 0)
+	f, err := t.openFiles.openAt(t.outDir, fileName, os.O_RDONLY, 0)
 	if err != nil {
 		return "", err
 	}
 	defer f.Close()
-	return computeFileHash(f)
+	return t.hashCapturedFile(f.File, fileName)
 }
 
-func computeFileHashAtPath(fileName string) (string, error) {
+func computeFileHashAtPath(fileName string, mmapThreshold int64) (string, error) {
 	f, err := os.Open(fileName)
 	if err != nil {
 		return "", err
 	}
 	defer f.Close()
-	return computeFileHash(f)
+	return computeFileHash(f, mmapThreshold)
 }
 
-func computeFileHash(file *os.File) (string, error) {
+// computeFileHash returns the sha256 of a file. Regular files of at least mmapThreshold bytes (if positive) are
+// hashed by mapping them to memory, falling back to reading them if mapping fails
+func computeFileHash(file *os.File, mmapThreshold int64) (string, error) {
+	if mmapThreshold > 0 {
+		if info, err := file.Stat(); err == nil && info.Mode().IsRegular() && info.Size() >= mmapThreshold {
+			if hash, err := computeFileHashMmap(file, info.Size()); err == nil {
+				return hash, nil
+			}
+		}
+	}
+
 	h := sha256.New()
 	_, err := io.Copy(h, file)
 	if err != nil {
@@ -1259,11 +1843,57 @@ func computeFileHash(file *os.File) (string, error) {
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
+// parentExecHash returns the hash of the executable of the given parent process, or an empty string if it can't be
+// hashed (e.g. when the parent has already exited). Hashes are cached by the executable's inode and ctime, so the
+// same binary (e.g. a shell) isn't hashed again for each process it runs.
+func (t *Tracee) parentExecHash(hostPpid int) string {
+	exePath := fmt.Sprintf("/proc/%d/exe", hostPpid)
+	t.openFiles.acquire(1)
+	defer t.openFiles.release(1)
+	f, err := os.Open(exePath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return ""
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	fileID := fmt.Sprintf("inode:%d:%d", stat.Dev, stat.Ino)
+	ctime := stat.Ctim.Nano()
+
+	if hashInfoInterface, ok := t.fileHashes.Get(fileID); ok {
+		if hashInfoObj := hashInfoInterface.(fileExecInfo); hashInfoObj.LastCtime == ctime {
+			t.stats.HashCacheHits.Increment()
+			return hashInfoObj.Hash
+		}
+	}
+	t.stats.HashCacheMisses.Increment()
+	hash, err := computeFileHash(f, t.config.Capture.HashMmapThreshold)
+	if err != nil {
+		return ""
+	}
+	t.fileHashes.Add(fileID, fileExecInfo{ctime, hash})
+	return hash
+}
+
 func (t *Tracee) updateFileSHA() {
 	for k, v := range t.profiledFiles {
-		s := strings.Split(k, ".")
-		exeName := strings.Split(s[1], ":")[0]
-		filePath := fmt.Sprintf("%s.%d.%s", s[0], v.FirstExecutionTs, exeName)
+		// files copied in other layouts (e.g. mirrored at their original paths) can't be named by the profile
+		filePath := v.CapturedPath
+		if filePath == "" {
+			s := strings.Split(k, ".")
+			exeName := strings.Split(s[1], ":")[0]
+			filePath = fmt.Sprintf("%s.%d.%s", s[0], v.FirstExecutionTs, exeName)
+			if compressor, ok := t.captureCompressor(); ok {
+				filePath += compressor.ext
+			}
+		}
 		fileSHA, _ := t.computeOutFileHash(filePath)
 		v.FileHash = fileSHA
 		t.profiledFiles[k] = v
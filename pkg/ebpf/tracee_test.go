@@ -11,6 +11,8 @@ import (
 	"testing"
 
 	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/filters"
+	"github.com/aquasecurity/tracee/types/trace"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -102,6 +104,7 @@ func Test_updateFileSHA(t *testing.T) {
 	}()
 
 	trc := Tracee{
+		config: Config{Capture: &CaptureConfig{}},
 		profiledFiles: map[string]profilerInfo{
 			fmt.Sprintf("%s/.%s:%d", d, strings.TrimPrefix(filepath.Base(f.Name()), fmt.Sprintf(".%d.", ts)), 1234): {
 				Times:            123,
@@ -179,3 +182,31 @@ func Test_getTailCalls(t *testing.T) {
 		})
 	}
 }
+
+func TestNew_decodeFlags(t *testing.T) {
+	argTransforms := events.ArgTransforms{}
+	argTransforms.Register(events.Openat, "pathname", events.LowerCase)
+	trc, err := New(Config{
+		Filter: &Filter{
+			EventsToTrace: []events.ID{events.Openat},
+			ArgFilter:     &filters.ArgFilter{Filters: make(map[events.ID]map[string]filters.ArgFilterVal)},
+			RetFilter:     &filters.RetFilter{Filters: make(map[events.ID]filters.IntFilter)},
+			ArgnumFilter:  &filters.ArgnumFilter{Filters: make(map[events.ID]uint8)},
+			NetFilter:     &NetIfaces{},
+		},
+		Capture:       &CaptureConfig{},
+		Output:        &OutputConfig{DecodeFlags: true},
+		ChanEvents:    make(chan trace.Event),
+		ChanErrors:    make(chan error),
+		ArgTransforms: argTransforms,
+		BPFObjBytes:   []byte("bpf"),
+	})
+	require.NoError(t, err)
+
+	// the decoders are added to the transforms of the caller without changing them
+	assert.Len(t, trc.config.ArgTransforms[events.Openat]["pathname"], 1)
+	assert.Len(t, trc.config.ArgTransforms[events.Openat]["flags"], 1)
+	assert.Len(t, trc.config.ArgTransforms[events.Socket]["domain"], 1)
+	assert.Len(t, argTransforms, 1)
+	assert.Len(t, argTransforms[events.Openat], 1)
+}
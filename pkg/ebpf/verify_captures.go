@@ -0,0 +1,121 @@
+package ebpf
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Mismatch is a captured file whose content doesn't match the sha256 recorded for it when it was captured
+type Mismatch struct {
+	Path         string // path of the file, relative to the output directory
+	ExpectedHash string // sha256 recorded for the file
+	ActualHash   string // sha256 of the current content of the file
+}
+
+// VerifyCaptures hashes again the files captured in the given output directory, and returns the ones whose content
+// no longer matches the sha256 recorded for them (e.g. as they were tampered with or corrupted). The recorded hash
+// of a file is its extended attribute (with HashXattr), or else its name for files stored by their content and
+// kernel modules. Files without a recorded hash aren't verified, and compressed files are verified by their
+// decompressed content
+func VerifyCaptures(outputPath string) ([]Mismatch, error) {
+	var mismatches []Mismatch
+	err := filepath.Walk(outputPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		relativePath, err := filepath.Rel(outputPath, path)
+		if err != nil {
+			return err
+		}
+		expectedHash, err := recordedHash(path, relativePath)
+		if err != nil {
+			return err
+		}
+		if expectedHash == "" {
+			return nil
+		}
+
+		actualHash, err := hashVerifiedFile(path)
+		if err != nil {
+			return err
+		}
+		if actualHash != expectedHash {
+			mismatches = append(mismatches, Mismatch{Path: relativePath, ExpectedHash: expectedHash, ActualHash: actualHash})
+		}
+		return nil
+	})
+	return mismatches, err
+}
+
+// recordedHash returns the sha256 recorded for a captured file, or an empty string if there is none
+func recordedHash(path string, relativePath string) (string, error) {
+	value := make([]byte, 2*sha256HexLen)
+	n, err := unix.Getxattr(path, hashXattr, value)
+	switch err {
+	case nil:
+		return string(value[:n]), nil
+	case unix.ENODATA, unix.EOPNOTSUPP:
+	default:
+		return "", err
+	}
+
+	name := filepath.Base(relativePath)
+	if strings.HasPrefix(relativePath, casDir+string(filepath.Separator)) && isSha256(name) {
+		return name, nil
+	}
+	// kernel modules are renamed with their hash once they were fully captured
+	if ext := filepath.Ext(name); strings.HasPrefix(name, "module.") && isSha256(strings.TrimPrefix(ext, ".")) {
+		return strings.TrimPrefix(ext, "."), nil
+	}
+	return "", nil
+}
+
+// hashVerifiedFile returns the sha256 of the content of a captured file, decompressing it if its extended attribute
+// tells it was compressed
+func hashVerifiedFile(path string) (string, error) {
+	value := make([]byte, 16)
+	n, err := unix.Getxattr(path, compressionXattr, value)
+	switch err {
+	case nil:
+	case unix.ENODATA, unix.EOPNOTSUPP:
+		return computeFileHashAtPath(path, 0)
+	default:
+		return "", err
+	}
+
+	algorithm := string(value[:n])
+	compressor, ok := captureCompressors[algorithm]
+	if !ok {
+		return "", fmt.Errorf("can't verify %s, it was compressed with %s which isn't supported by this build of tracee-ebpf", path, algorithm)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash, err := hashCompressed(f, compressor)
+	if err != nil {
+		// a corrupted file may not even decompress
+		return computeFileHashAtPath(path, 0)
+	}
+	return hash, nil
+}
+
+// sha256HexLen is the length of a hex encoded sha256
+const sha256HexLen = 64
+
+func isSha256(s string) bool {
+	if len(s) != sha256HexLen {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,93 @@
+package ebpf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestVerifyCaptures(t *testing.T) {
+	f, err := ioutil.TempFile("", "TestVerifyCaptures-*.so")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("captured library")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	expectedHash, err := computeFileHashAtPath(f.Name(), 0)
+	require.NoError(t, err)
+
+	t.Run("content addressed layout", func(t *testing.T) {
+		trc := newTestTracee(t, Config{
+			Capture: &CaptureConfig{SharedObjects: true, ContentAddressed: true},
+			Output:  &OutputConfig{ExecHash: true},
+		})
+		require.NoError(t, trc.processEvent(newSharedObjectLoadedEvent(1000, f.Name(), 1)))
+		// a kernel module, named by its hash once captured
+		modulePath := filepath.Join(trc.outDir.Name(), "host", "module.pid-42."+expectedHash)
+		require.NoError(t, os.MkdirAll(filepath.Dir(modulePath), 0755))
+		require.NoError(t, ioutil.WriteFile(modulePath, []byte("captured library"), 0644))
+
+		mismatches, err := VerifyCaptures(trc.outDir.Name())
+		require.NoError(t, err)
+		assert.Empty(t, mismatches)
+
+		// corrupt the captured files
+		corruptedHash, err := computeFileHashAtPath(writeCorrupted(t, filepath.Join(trc.outDir.Name(), casPath(expectedHash))), 0)
+		require.NoError(t, err)
+		writeCorrupted(t, modulePath)
+
+		mismatches, err = VerifyCaptures(trc.outDir.Name())
+		require.NoError(t, err)
+		assert.Equal(t, []Mismatch{
+			{Path: casPath(expectedHash), ExpectedHash: expectedHash, ActualHash: corruptedHash},
+			{Path: filepath.Join("host", "module.pid-42."+expectedHash), ExpectedHash: expectedHash, ActualHash: corruptedHash},
+		}, mismatches)
+	})
+
+	t.Run("hash stored as extended attribute", func(t *testing.T) {
+		trc := newTestTracee(t, Config{
+			Capture: &CaptureConfig{Exec: true, HashXattr: true},
+			Output:  &OutputConfig{ExecHash: true},
+		})
+		if err := unix.Setxattr(trc.outDir.Name(), "user.tracee.test", []byte("1"), 0); err == unix.EOPNOTSUPP {
+			t.Skip("the output directory doesn't support extended attributes")
+		}
+		event := newExecEvent(t, f.Name())
+		event.Timestamp = 1
+		require.NoError(t, trc.processEvent(event))
+		// files without a recorded hash aren't verified
+		require.NoError(t, ioutil.WriteFile(filepath.Join(trc.outDir.Name(), "written_files"), []byte("index"), 0644))
+
+		mismatches, err := VerifyCaptures(trc.outDir.Name())
+		require.NoError(t, err)
+		assert.Empty(t, mismatches)
+
+		capturedPath := filepath.Join("host", "exec.1."+filepath.Base(f.Name()))
+		corruptedHash, err := computeFileHashAtPath(writeCorrupted(t, filepath.Join(trc.outDir.Name(), capturedPath)), 0)
+		require.NoError(t, err)
+
+		mismatches, err = VerifyCaptures(trc.outDir.Name())
+		require.NoError(t, err)
+		assert.Equal(t, []Mismatch{{Path: capturedPath, ExpectedHash: expectedHash, ActualHash: corruptedHash}}, mismatches)
+	})
+
+	t.Run("missing output directory", func(t *testing.T) {
+		_, err := VerifyCaptures(filepath.Join(os.TempDir(), "TestVerifyCaptures-missing"))
+		assert.Error(t, err)
+	})
+}
+
+// writeCorrupted overwrites the beginning of a captured file in place, keeping its extended attributes
+func writeCorrupted(t *testing.T, path string) string {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("tampered")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	return path
+}
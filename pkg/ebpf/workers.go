@@ -0,0 +1,81 @@
+package ebpf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/logger"
+)
+
+// workers tracks the goroutines doing the work of a run (the events pipeline, file and network captures and the
+// reporting of lost events) by name, so shutdown can wait for the work in flight to finish
+type workers struct {
+	mu      sync.Mutex
+	running map[string]int
+	wg      sync.WaitGroup
+}
+
+// start runs the given work in a goroutine of the given name
+func (w *workers) start(name string, work func()) {
+	w.mu.Lock()
+	if w.running == nil {
+		w.running = make(map[string]int)
+	}
+	w.running[name]++
+	w.mu.Unlock()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			w.running[name]--
+			if w.running[name] == 0 {
+				delete(w.running, name)
+			}
+		}()
+		work()
+	}()
+}
+
+// wait waits at most the given timeout for the workers to finish. It returns the names of the workers which were
+// still running once it timed out, sorted
+func (w *workers) wait(timeout time.Duration) []string {
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	abandoned := make([]string, 0, len(w.running))
+	for name := range w.running {
+		abandoned = append(abandoned, name)
+	}
+	sort.Strings(abandoned)
+	return abandoned
+}
+
+// drainWorkers waits for the work in flight to finish once the perf buffers are stopped, abandoning the work
+// which didn't finish within the timeout (e.g. a capture of a file whose reading is stuck)
+func (t *Tracee) drainWorkers(timeout time.Duration) {
+	abandoned := t.workers.wait(timeout)
+	if len(abandoned) == 0 {
+		return
+	}
+	t.log(logger.WarnLevel, fmt.Sprintf("abandoned %s after a drain timeout of %s", strings.Join(abandoned, ", "), timeout),
+		"workers", abandoned, "timeout", timeout.String())
+}
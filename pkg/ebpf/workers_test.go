@@ -0,0 +1,69 @@
+package ebpf
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_drainWorkers(t *testing.T) {
+	f, err := ioutil.TempFile("", "Test_drainWorkers-*.so")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("shared library")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	t.Run("stuck capture is abandoned", func(t *testing.T) {
+		recorder := newRecordingLogger()
+		trc := newTestTracee(t, Config{
+			Capture: &CaptureConfig{SharedObjects: true},
+			Logger:  recorder.logger,
+		})
+		// the files which may be open are all taken, so the capture is stuck until they are released
+		trc.openFiles = newFileBudget(2)
+		trc.openFiles.acquire(2)
+
+		captured := make(chan error, 1)
+		trc.workers.start("pipeline", func() {
+			captured <- trc.processEvent(newSharedObjectLoadedEvent(1000, f.Name(), 1))
+		})
+		trc.workers.start("lost_events", func() {})
+
+		start := time.Now()
+		trc.drainWorkers(100 * time.Millisecond)
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+		assert.Less(t, time.Since(start), 5*time.Second)
+
+		entries := recorder.logged()
+		require.Len(t, entries, 1)
+		assert.Equal(t, logger.WarnLevel, entries[0].level)
+		assert.Equal(t, "abandoned pipeline after a drain timeout of 100ms", entries[0].msg)
+		assert.Equal(t, []interface{}{"pipeline"}, entries[0].fields["workers"])
+		assert.Equal(t, int32(0), trc.stats.CapFileCount.Read())
+
+		// let the abandoned capture finish before its output directory is removed
+		trc.openFiles.release(2)
+		assert.NoError(t, <-captured)
+	})
+
+	t.Run("finished work is not reported", func(t *testing.T) {
+		recorder := newRecordingLogger()
+		trc := newTestTracee(t, Config{
+			Capture: &CaptureConfig{SharedObjects: true},
+			Logger:  recorder.logger,
+		})
+		trc.workers.start("pipeline", func() {
+			assert.NoError(t, trc.processEvent(newSharedObjectLoadedEvent(1000, f.Name(), 1)))
+		})
+
+		trc.drainWorkers(5 * time.Second)
+		assert.Empty(t, recorder.logged())
+		assert.Equal(t, int32(1), trc.stats.CapFileCount.Read())
+	})
+}
@@ -1,16 +1,90 @@
 package ebpf
 
 import (
+	gocontext "context"
 	"fmt"
 	"io"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/aquasecurity/tracee/pkg/bufferdecoder"
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/logger"
 	"github.com/aquasecurity/tracee/pkg/utils"
+	"github.com/aquasecurity/tracee/types/trace"
+	"golang.org/x/sys/unix"
 )
 
-func (t *Tracee) processFileWrites() {
+// writeChunkSize is the maximal size of a written file chunk. It should match F_CHUNK_SIZE defined in BPF code
+const writeChunkSize = 1 << 14
+
+// kernelMinorBits is the number of bits of the minor number in kernel device numbers (MINORBITS of the kernel),
+// which differ from the device numbers of userspace
+const kernelMinorBits = 20
+
+// formatKernelDev formats a kernel device number as major:minor
+func formatKernelDev(dev uint32) string {
+	return fmt.Sprintf("%d:%d", dev>>kernelMinorBits, dev&(1<<kernelMinorBits-1))
+}
+
+// kernelDev converts a userspace device number (e.g. of stat) to a kernel device number
+func kernelDev(dev uint64) uint32 {
+	return unix.Major(dev)<<kernelMinorBits | unix.Minor(dev)
+}
+
+// capturesWrittenPath checks if the data written to a file is captured by the bpf code, as of the path prefixes of
+// Capture.FilterFileWrite
+func capturesWrittenPath(filterFileWrite []string, filePath string) bool {
+	if len(filterFileWrite) == 0 {
+		return true
+	}
+	for _, prefix := range filterFileWrite {
+		if strings.HasPrefix(filePath, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileInode identifies a file across mount namespaces
+type fileInode struct {
+	dev   uint32
+	inode uint64
+}
+
+// firstWritesCacheSize is the number of written files tracked in FirstWriteOnly mode. The least recently written
+// files are forgotten beyond it, so their next write is captured as if it was their first one
+const firstWritesCacheSize = 16384
+
+// firstWrite is the progress of capturing the first write of a file in FirstWriteOnly mode
+type firstWrite struct {
+	nextOff uint64 // offset at which the next chunk of the first write starts
+	done    bool
+}
+
+// isFirstWriteChunk checks if a written file chunk belongs to the first write captured of the file.
+// Writes are sent as consecutive chunks, all full except for the last one, so the first write is over
+// after a short chunk, or once a chunk not continuing it is seen.
+func (t *Tracee) isFirstWriteChunk(file fileInode, off uint64, size int32) bool {
+	value, ok := t.firstWrites.Get(file)
+	if !ok {
+		t.firstWrites.Add(file, firstWrite{nextOff: off + uint64(size), done: size < writeChunkSize})
+		return true
+	}
+	w := value.(firstWrite)
+	if w.done {
+		return false
+	}
+	if off != w.nextOff {
+		t.firstWrites.Add(file, firstWrite{done: true})
+		return false
+	}
+	t.firstWrites.Add(file, firstWrite{nextOff: off + uint64(size), done: size < writeChunkSize})
+	return true
+}
+
+func (t *Tracee) processFileWrites(ctx gocontext.Context) {
 
 	const (
 		//S_IFMT uint32 = 0170000 // bit mask for the file type bit field
@@ -25,8 +99,12 @@ func (t *Tracee) processFileWrites() {
 
 	for {
 		select {
-		case dataRaw := <-t.fileWrChannel:
-			if len(dataRaw) == 0 {
+		case dataRaw, ok := <-t.fileWrChannel:
+			if !ok {
+				// the perf buffer was stopped
+				return
+			}
+			if len(dataRaw) == 0 || t.captureSkipped() {
 				continue
 			}
 			ebpfMsgDecoder := bufferdecoder.New(dataRaw)
@@ -50,12 +128,9 @@ func (t *Tracee) processFileWrites() {
 			}
 
 			containerId := t.containers.GetCgroupInfo(meta.CgroupID).Container.ContainerId
-			if containerId == "" {
-				containerId = "host"
-			}
-			pathname := containerId
+			pathname := t.captureDir(containerId, 0)
 			if err := utils.MkdirAtExist(t.outDir, pathname, 0755); err != nil {
-				t.handleError(err)
+				t.handleError(err, "capture_dir", pathname)
 				continue
 			}
 			filename := ""
@@ -71,6 +146,11 @@ func (t *Tracee) processFileWrites() {
 				if vfsMeta.Mode&S_IFSOCK == S_IFSOCK || vfsMeta.Mode&S_IFCHR == S_IFCHR || vfsMeta.Mode&S_IFIFO == S_IFIFO {
 					appendFile = true
 				}
+				// written streams have no offsets to tell writes apart, so they are always captured
+				if t.config.Capture.FirstWriteOnly && !appendFile &&
+					!t.isFirstWriteChunk(fileInode{vfsMeta.DevID, vfsMeta.Inode}, meta.Off, meta.Size) {
+					continue
+				}
 				if vfsMeta.Pid == 0 {
 					filename = fmt.Sprintf("write.dev-%d.inode-%d", vfsMeta.DevID, vfsMeta.Inode)
 				} else {
@@ -108,21 +188,25 @@ func (t *Tracee) processFileWrites() {
 
 			fullname := path.Join(pathname, filename)
 
-			f, err := utils.OpenAt(t.outDir, fullname, os.O_CREATE|os.O_WRONLY, 0640)
+			f, err := t.openFiles.openAt(t.outDir, fullname, os.O_CREATE|os.O_WRONLY, 0640)
 			if err != nil {
-				t.handleError(err)
+				t.handleError(err, "capture_file", fullname)
 				continue
 			}
+			// an empty file was just created by this chunk
+			if fileInfo, err := f.Stat(); err == nil && fileInfo.Size() == 0 {
+				t.stats.CapFileCount.Increment()
+			}
 			if appendFile {
 				if _, err := f.Seek(0, io.SeekEnd); err != nil {
 					f.Close()
-					t.handleError(err)
+					t.handleError(err, "capture_file", fullname)
 					continue
 				}
 			} else {
 				if _, err := f.Seek(int64(meta.Off), io.SeekStart); err != nil {
 					f.Close()
-					t.handleError(err)
+					t.handleError(err, "capture_file", fullname)
 					continue
 				}
 			}
@@ -130,16 +214,18 @@ func (t *Tracee) processFileWrites() {
 			dataBytes, err := bufferdecoder.ReadByteSliceFromBuff(ebpfMsgDecoder, int(meta.Size))
 			if err != nil {
 				f.Close()
-				t.handleError(err)
+				t.handleError(err, "capture_file", fullname)
 				continue
 			}
-			if _, err := f.Write(dataBytes); err != nil {
+			written, err := f.Write(dataBytes)
+			if err != nil {
 				f.Close()
-				t.handleError(err)
+				t.handleError(err, "capture_file", fullname)
 				continue
 			}
+			t.stats.CapBytesCount.Increment(written)
 			if err := f.Close(); err != nil {
-				t.handleError(err)
+				t.handleError(err, "capture_file", fullname)
 				continue
 			}
 			// Rename the file to add hash when last chunk was received
@@ -147,6 +233,10 @@ func (t *Tracee) processFileWrites() {
 				if uint64(meta.Size)+meta.Off == kernelModuleMeta.Size {
 					fileHash, _ := t.computeOutFileHash(fullname)
 					utils.RenameAt(t.outDir, fullname, t.outDir, fullname+"."+fileHash)
+					t.matchCapturedFileHash(ctx, fullname+"."+fileHash, fileHash, kernelModuleMeta.Pid, containerId)
+					if t.config.Capture.HashXattr {
+						t.storeCapturedFileHash(fullname+"."+fileHash, fileHash)
+					}
 				}
 			}
 		case lost := <-t.lostWrChannel:
@@ -155,8 +245,49 @@ func (t *Tracee) processFileWrites() {
 			// https://github.com/aquasecurity/libbpfgo/issues/122
 			if lost > 0 {
 				t.stats.LostWrCount.Increment(int(lost))
-				t.config.ChanErrors <- fmt.Errorf("lost %d write events", lost)
+				t.log(logger.WarnLevel, fmt.Sprintf("lost %d write events", lost), "count", lost, "buffer", "file_writes")
 			}
 		}
 	}
 }
+
+// matchCapturedFileHash emits a malware_hash_match event if the hash of a captured file is in the denylist. Files are
+// captured outside of the events pipeline, so the event is sent to the output directly, and counted against the
+// events limit as the events of the pipeline are
+func (t *Tracee) matchCapturedFileHash(ctx gocontext.Context, pathname string, fileHash string, hostPid uint32, containerId string) {
+	if !t.events[events.MalwareHashMatch].emit {
+		return
+	}
+	if _, ok := t.config.Output.HashDenylist[fileHash]; !ok {
+		return
+	}
+	if t.maxEventsReached() {
+		return
+	}
+
+	def := events.Definitions.Get(events.MalwareHashMatch)
+	event := trace.Event{
+		Timestamp:     int(t.eventTimestamp(uint64(t.clock.MonotonicNano()))),
+		HostProcessID: int(hostPid),
+		ContainerID:   containerId,
+		EventID:       int(events.MalwareHashMatch),
+		EventName:     def.Name,
+		ArgsNum:       2,
+		Args: []trace.Argument{
+			{ArgMeta: def.Params[0], Value: pathname},
+			{ArgMeta: def.Params[1], Value: fileHash},
+		},
+	}
+	select {
+	case t.config.ChanEvents <- event:
+	case <-ctx.Done():
+		// the output may not read the events anymore once tracee stops, so the event is only sent if there's room
+		select {
+		case t.config.ChanEvents <- event:
+		default:
+			return
+		}
+	}
+	t.stats.EventCount.Increment()
+	t.countEmitted()
+}
@@ -0,0 +1,145 @@
+package ebpf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFirstWrites(t *testing.T, size int) *lru.Cache {
+	firstWrites, err := lru.New(size)
+	require.NoError(t, err)
+	return firstWrites
+}
+
+func Test_isFirstWriteChunk(t *testing.T) {
+	type chunk struct {
+		off      uint64
+		size     int32
+		captured bool
+	}
+
+	testCases := []struct {
+		name   string
+		chunks []chunk
+	}{
+		{
+			name: "single chunk writes",
+			chunks: []chunk{
+				{off: 0, size: 100, captured: true},
+				{off: 100, size: 100, captured: false},
+				{off: 0, size: 100, captured: false},
+			},
+		},
+		{
+			name: "write of multiple chunks",
+			chunks: []chunk{
+				{off: 0, size: writeChunkSize, captured: true},
+				{off: writeChunkSize, size: writeChunkSize, captured: true},
+				{off: 2 * writeChunkSize, size: 10, captured: true},
+				{off: 2*writeChunkSize + 10, size: 10, captured: false},
+			},
+		},
+		{
+			name: "other write interleaving the first one",
+			chunks: []chunk{
+				{off: 0, size: writeChunkSize, captured: true},
+				{off: 0, size: 10, captured: false},
+				{off: writeChunkSize, size: 10, captured: false},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			trc := Tracee{firstWrites: newFirstWrites(t, firstWritesCacheSize)}
+			for i, c := range tc.chunks {
+				assert.Equal(t, c.captured, trc.isFirstWriteChunk(fileInode{1, 2}, c.off, c.size), "chunk %d", i)
+			}
+		})
+	}
+}
+
+func Test_isFirstWriteChunk_manyWrites(t *testing.T) {
+	trc := Tracee{firstWrites: newFirstWrites(t, firstWritesCacheSize)}
+
+	captured := map[fileInode]int{}
+	for i := 0; i < 1000; i++ {
+		for _, file := range []fileInode{{1, 2}, {1, 3}} {
+			if trc.isFirstWriteChunk(file, uint64(i%10)*64, 64) {
+				captured[file]++
+			}
+		}
+	}
+
+	assert.Equal(t, map[fileInode]int{{1, 2}: 1, {1, 3}: 1}, captured)
+}
+
+func Test_isFirstWriteChunk_bounded(t *testing.T) {
+	trc := Tracee{firstWrites: newFirstWrites(t, 2)}
+
+	assert.True(t, trc.isFirstWriteChunk(fileInode{1, 2}, 0, 10))
+	assert.True(t, trc.isFirstWriteChunk(fileInode{1, 3}, 0, 10))
+	assert.False(t, trc.isFirstWriteChunk(fileInode{1, 2}, 10, 10))
+	// the least recently written file is forgotten once too many files are written, and captured again
+	assert.True(t, trc.isFirstWriteChunk(fileInode{1, 4}, 0, 10))
+	assert.Equal(t, 2, trc.firstWrites.Len())
+	assert.False(t, trc.isFirstWriteChunk(fileInode{1, 2}, 20, 10))
+	assert.True(t, trc.isFirstWriteChunk(fileInode{1, 3}, 10, 10))
+}
+
+func Test_matchCapturedFileHash(t *testing.T) {
+	const badHash = "0f4e7a5c"
+	newTracee := func(t *testing.T, chanEvents chan trace.Event) (*Tracee, context.Context) {
+		trc := newTestTracee(t, Config{
+			ChanEvents: chanEvents,
+			Output:     &OutputConfig{MaxEvents: 1, HashDenylist: map[string]struct{}{badHash: {}}},
+		})
+		trc.events = map[events.ID]eventConfig{events.MalwareHashMatch: {submit: true, emit: true}}
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		trc.stopRun = cancel
+		return trc, ctx
+	}
+
+	t.Run("counted against the events limit", func(t *testing.T) {
+		chanEvents := make(chan trace.Event, 2)
+		trc, ctx := newTracee(t, chanEvents)
+
+		trc.matchCapturedFileHash(ctx, "host/module.ko."+badHash, badHash, 42, "")
+		trc.matchCapturedFileHash(ctx, "host/other.ko.8d1c", "8d1c", 42, "")
+		require.Len(t, chanEvents, 1)
+		event := <-chanEvents
+		assert.Equal(t, "malware_hash_match", event.EventName)
+		assert.Equal(t, "host/module.ko."+badHash, event.Args[0].Value)
+		assert.Equal(t, int32(1), trc.stats.EventCount.Read())
+		// the run is stopped once the limit is reached, and no more events are emitted
+		assert.Error(t, ctx.Err())
+		trc.matchCapturedFileHash(ctx, "host/module.ko."+badHash, badHash, 42, "")
+		assert.Empty(t, chanEvents)
+	})
+
+	t.Run("no blocking once stopped", func(t *testing.T) {
+		// nothing reads the events anymore
+		trc, ctx := newTracee(t, make(chan trace.Event))
+		trc.stopRun()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			trc.matchCapturedFileHash(ctx, "host/module.ko."+badHash, badHash, 42, "")
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the event was sent to an output which doesn't read events anymore")
+		}
+		assert.Equal(t, int32(0), trc.stats.EventCount.Read())
+	})
+}
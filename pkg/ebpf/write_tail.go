@@ -0,0 +1,65 @@
+package ebpf
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/aquasecurity/tracee/pkg/logger"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// addWriteTail adds the trailing bytes of the file written by a write event as its tail argument. The file is read
+// through the root of the writing process, as it is once the write was done. Files which can't be read (e.g. files
+// without an absolute path, or deleted since) get an empty tail
+func (t *Tracee) addWriteTail(event *trace.Event) error {
+	args, err := decodeArgs(event)
+	if err != nil {
+		return err
+	}
+	filePath := args.String("pathname")
+
+	var tail []byte
+	if filePath != "" && filePath[0] == '/' {
+		sourceFilePath := fmt.Sprintf("/proc/%d/root%s", event.HostProcessID, filePath)
+		t.openFiles.acquire(1)
+		tail, err = readFileTail(sourceFilePath, t.config.Capture.WriteTailSize)
+		t.openFiles.release(1)
+		if err != nil {
+			t.log(logger.DebugLevel, "can't read the tail of a written file", "error", err, "event", event.EventName, "pathname", filePath)
+		}
+	}
+
+	event.Args = append(event.Args, trace.Argument{
+		ArgMeta: trace.ArgMeta{Name: "tail", Type: "bytes"},
+		Value:   tail,
+	})
+	event.ArgsNum++
+	return nil
+}
+
+// readFileTail reads the last size bytes of a file, or the whole file if it is smaller
+func readFileTail(path string, size int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+	if info.Size() < size {
+		size = info.Size()
+	}
+	if _, err := f.Seek(-size, io.SeekEnd); err != nil {
+		return nil, err
+	}
+	// the file may be truncated meanwhile, so the tail is read up to its end
+	return ioutil.ReadAll(io.LimitReader(f, size))
+}
@@ -0,0 +1,109 @@
+package ebpf
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_readFileTail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "Test_readFileTail-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	large := bytes.Repeat([]byte("0123456789"), 1000)
+	large = append(large, []byte("last line\n")...)
+
+	testCases := []struct {
+		name         string
+		content      []byte
+		size         int64
+		expectedTail []byte
+	}{
+		{
+			name:         "large file",
+			content:      large,
+			size:         10,
+			expectedTail: []byte("last line\n"),
+		},
+		{
+			name:         "file smaller than the tail",
+			content:      []byte("short"),
+			size:         10,
+			expectedTail: []byte("short"),
+		},
+		{
+			name:         "file of the tail size",
+			content:      []byte("0123456789"),
+			size:         10,
+			expectedTail: []byte("0123456789"),
+		},
+		{
+			name:         "empty file",
+			content:      []byte{},
+			size:         10,
+			expectedTail: []byte{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name)
+			require.NoError(t, ioutil.WriteFile(path, tc.content, 0644))
+
+			tail, err := readFileTail(path, tc.size)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedTail, tail)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := readFileTail(filepath.Join(dir, "missing"), 10)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func Test_processEvent_writeTail(t *testing.T) {
+	f, err := ioutil.TempFile("", "Test_processEvent_writeTail-*")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("Aug  1 10:00:00 host sshd: accepted\nAug  1 10:00:01 host sshd: closed\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	trc := newTestTracee(t, Config{Capture: &CaptureConfig{WriteTailSize: 16}})
+	writeEvent := func(pathname string) *trace.Event {
+		return &trace.Event{
+			EventID:       int(events.VfsWrite),
+			EventName:     "vfs_write",
+			HostProcessID: os.Getpid(),
+			ArgsNum:       3,
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: pathname},
+				{ArgMeta: trace.ArgMeta{Name: "dev", Type: "dev_t"}, Value: uint32(1)},
+				{ArgMeta: trace.ArgMeta{Name: "inode", Type: "unsigned long"}, Value: uint64(1)},
+			},
+		}
+	}
+
+	event := writeEvent(f.Name())
+	require.NoError(t, trc.processEvent(event))
+	arg := events.GetArg(event, "tail")
+	require.NotNil(t, arg)
+	assert.Equal(t, []byte("st sshd: closed\n"), arg.Value)
+	assert.Equal(t, 4, event.ArgsNum)
+
+	// files which can't be read get an empty tail
+	event = writeEvent("memfd:payload")
+	require.NoError(t, trc.processEvent(event))
+	arg = events.GetArg(event, "tail")
+	require.NotNil(t, arg)
+	assert.Empty(t, arg.Value)
+}
@@ -0,0 +1,69 @@
+package events
+
+import "github.com/aquasecurity/tracee/types/trace"
+
+// ArgNames is a set of argument names, given for all events or for single events
+type ArgNames struct {
+	All    map[string]struct{}        // names of arguments of all events
+	Events map[ID]map[string]struct{} // names of arguments of single events, by their ID
+}
+
+// Add adds the name of an argument of all events
+func (an *ArgNames) Add(argName string) {
+	if an.All == nil {
+		an.All = make(map[string]struct{})
+	}
+	an.All[argName] = struct{}{}
+}
+
+// AddEvent adds the name of an argument of the given event
+func (an *ArgNames) AddEvent(id ID, argName string) {
+	if an.Events == nil {
+		an.Events = make(map[ID]map[string]struct{})
+	}
+	if an.Events[id] == nil {
+		an.Events[id] = make(map[string]struct{})
+	}
+	an.Events[id][argName] = struct{}{}
+}
+
+// Empty checks if no argument name is given
+func (an ArgNames) Empty() bool {
+	return len(an.All) == 0 && len(an.Events) == 0
+}
+
+// appliesTo checks if any argument name is given for the given event
+func (an ArgNames) appliesTo(id ID) bool {
+	return len(an.All) > 0 || len(an.Events[id]) > 0
+}
+
+// Contains checks if an argument name is given for the given event, or for all events
+func (an ArgNames) Contains(id ID, argName string) bool {
+	if _, ok := an.All[argName]; ok {
+		return true
+	}
+	_, ok := an.Events[id][argName]
+	return ok
+}
+
+// SelectArgs prunes the arguments of an event: only the included arguments are kept if any argument is included
+// for the event, and the excluded arguments are dropped. ArgsNum is decreased by the number of pruned arguments
+func SelectArgs(event *trace.Event, include ArgNames, exclude ArgNames) {
+	id := ID(event.EventID)
+	includes := include.appliesTo(id)
+	excludes := exclude.appliesTo(id)
+	if !includes && !excludes {
+		return
+	}
+
+	// the arguments may be shared with other events (e.g. the events derived from it), so they are copied
+	kept := make([]trace.Argument, 0, len(event.Args))
+	for _, arg := range event.Args {
+		if (includes && !include.Contains(id, arg.Name)) || (excludes && exclude.Contains(id, arg.Name)) {
+			event.ArgsNum--
+			continue
+		}
+		kept = append(kept, arg)
+	}
+	event.Args = kept
+}
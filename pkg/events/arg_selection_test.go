@@ -0,0 +1,95 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectArgs(t *testing.T) {
+	newOpenatEvent := func() trace.Event {
+		return trace.Event{
+			EventID: int(Openat),
+			ArgsNum: 5,
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "dirfd", Type: "int"}, Value: int32(-100)},
+				{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/etc/passwd"},
+				{ArgMeta: trace.ArgMeta{Name: "flags", Type: "int"}, Value: int32(0)},
+				{ArgMeta: trace.ArgMeta{Name: "mode", Type: "mode_t"}, Value: uint32(0)},
+				// added while processing the event
+				{ArgMeta: trace.ArgMeta{Name: "ancestry", Type: "const char**"}, Value: []string{"bash"}},
+			},
+		}
+	}
+	argNames := func(all []string, id ID, eventArgs ...string) ArgNames {
+		var names ArgNames
+		for _, name := range all {
+			names.Add(name)
+		}
+		for _, name := range eventArgs {
+			names.AddEvent(id, name)
+		}
+		return names
+	}
+
+	testCases := []struct {
+		name          string
+		include       ArgNames
+		exclude       ArgNames
+		expectedNames []string
+	}{
+		{
+			name:          "no selection",
+			expectedNames: []string{"dirfd", "pathname", "flags", "mode", "ancestry"},
+		},
+		{
+			name:          "include of the event",
+			include:       argNames(nil, Openat, "pathname", "flags"),
+			expectedNames: []string{"pathname", "flags"},
+		},
+		{
+			name:          "include of all events",
+			include:       argNames([]string{"ancestry"}, Openat, "pathname"),
+			expectedNames: []string{"pathname", "ancestry"},
+		},
+		{
+			name:          "exclude of the event",
+			exclude:       argNames(nil, Openat, "dirfd", "mode"),
+			expectedNames: []string{"pathname", "flags", "ancestry"},
+		},
+		{
+			name:          "exclude of all events",
+			exclude:       argNames([]string{"ancestry", "missing"}, Openat),
+			expectedNames: []string{"dirfd", "pathname", "flags", "mode"},
+		},
+		{
+			name:          "include and exclude",
+			include:       argNames(nil, Openat, "pathname", "flags"),
+			exclude:       argNames([]string{"flags"}, Openat),
+			expectedNames: []string{"pathname"},
+		},
+		{
+			name:          "selection of other events",
+			include:       argNames(nil, Execve, "argv"),
+			expectedNames: []string{"dirfd", "pathname", "flags", "mode", "ancestry"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			event := newOpenatEvent()
+			original := event.Args
+			SelectArgs(&event, tc.include, tc.exclude)
+
+			names := make([]string, 0, len(event.Args))
+			for _, arg := range event.Args {
+				names = append(names, arg.Name)
+			}
+			assert.Equal(t, tc.expectedNames, names)
+			assert.Equal(t, len(tc.expectedNames), event.ArgsNum)
+			// the arguments of the event aren't modified in place
+			assert.Equal(t, newOpenatEvent().Args, original)
+		})
+	}
+}
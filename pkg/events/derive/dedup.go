@@ -0,0 +1,75 @@
+package derive
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// DedupKeyArgs are the arguments identifying the condition reported by a derived event, for the events whose other
+// arguments (e.g. addresses or times) differ between repetitions of the same condition. The condition of the other
+// derived events is identified by all of their arguments
+var DedupKeyArgs = map[events.ID][]string{
+	events.WXMemoryMapping:     {"prot"},
+	events.WriteThenExec:       {"pathname", "dev", "inode"},
+	events.PrivilegeEscalation: {"old_euid", "new_euid", "old_egid", "new_egid"},
+	events.MalwareHashMatch:    {"pathname", "sha256"},
+	events.ProcessInjection:    {"tracee_pid", "request"},
+}
+
+// Dedup drops derived events repeating the condition of a derived event which was emitted for the same process
+// within a window (by the timestamps of the events), so a repeated condition is reported once per window
+type Dedup struct {
+	window    int
+	emitted   map[string]int // timestamp of the last emitted event, by its key
+	lastPrune int
+}
+
+// NewDedup creates a Dedup of derived events within the given window
+func NewDedup(window time.Duration) *Dedup {
+	return &Dedup{
+		window:  int(window.Nanoseconds()),
+		emitted: make(map[string]int),
+	}
+}
+
+// Emit checks if the derived event should be emitted, recording it if it should
+func (d *Dedup) Emit(event trace.Event) bool {
+	// forget the keys whose window has passed, so processes which are gone aren't kept
+	if event.Timestamp-d.lastPrune > d.window {
+		for key, ts := range d.emitted {
+			if event.Timestamp-ts > d.window {
+				delete(d.emitted, key)
+			}
+		}
+		d.lastPrune = event.Timestamp
+	}
+
+	key := dedupKey(event)
+	if ts, ok := d.emitted[key]; ok && event.Timestamp-ts <= d.window {
+		return false
+	}
+	d.emitted[key] = event.Timestamp
+	return true
+}
+
+// dedupKey returns the key of a derived event, by its type, process and the arguments identifying its condition
+func dedupKey(event trace.Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d:%d", event.EventID, event.HostProcessID)
+	if keyArgs, ok := DedupKeyArgs[events.ID(event.EventID)]; ok {
+		for _, name := range keyArgs {
+			if arg := events.GetArg(&event, name); arg != nil {
+				fmt.Fprintf(&b, ":%v", arg.Value)
+			}
+		}
+		return b.String()
+	}
+	for _, arg := range event.Args {
+		fmt.Fprintf(&b, ":%v", arg.Value)
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package derive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedup(t *testing.T) {
+	wxMapping := func(ts int, hostPid int, address uintptr, prot int32) trace.Event {
+		return trace.Event{
+			Timestamp:     ts,
+			EventID:       int(events.WXMemoryMapping),
+			EventName:     "wx_memory_mapping",
+			HostProcessID: hostPid,
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "pid", Type: "int"}, Value: int32(hostPid)},
+				{ArgMeta: trace.ArgMeta{Name: "address", Type: "void*"}, Value: address},
+				{ArgMeta: trace.ArgMeta{Name: "length", Type: "size_t"}, Value: uint64(4096)},
+				{ArgMeta: trace.ArgMeta{Name: "prot", Type: "int"}, Value: prot},
+			},
+		}
+	}
+	second := int(time.Second)
+
+	t.Run("repeated condition is emitted once per window", func(t *testing.T) {
+		dedup := NewDedup(time.Second)
+		assert.True(t, dedup.Emit(wxMapping(0, 42, 0x1000, 7)))
+		// the address isn't part of the condition
+		assert.False(t, dedup.Emit(wxMapping(second/2, 42, 0x2000, 7)))
+		assert.False(t, dedup.Emit(wxMapping(second, 42, 0x3000, 7)))
+		// the window starts at the emitted event
+		assert.True(t, dedup.Emit(wxMapping(second+1, 42, 0x4000, 7)))
+		assert.False(t, dedup.Emit(wxMapping(2*second, 42, 0x5000, 7)))
+	})
+
+	t.Run("other processes and conditions are emitted", func(t *testing.T) {
+		dedup := NewDedup(time.Second)
+		assert.True(t, dedup.Emit(wxMapping(0, 42, 0x1000, 7)))
+		assert.True(t, dedup.Emit(wxMapping(1, 43, 0x1000, 7)))
+		assert.True(t, dedup.Emit(wxMapping(2, 42, 0x1000, 6)))
+		// derived events of other types are distinct conditions
+		escalation := trace.Event{Timestamp: 3, EventID: int(events.PrivilegeEscalation), HostProcessID: 42}
+		assert.True(t, dedup.Emit(escalation))
+		escalation.Timestamp = 4
+		assert.False(t, dedup.Emit(escalation))
+	})
+
+	t.Run("events without key arguments are keyed by all of them", func(t *testing.T) {
+		symbolsLoaded := func(ts int, library string) trace.Event {
+			return trace.Event{
+				Timestamp:     ts,
+				EventID:       int(events.SymbolsLoaded),
+				HostProcessID: 42,
+				Args: []trace.Argument{
+					{ArgMeta: trace.ArgMeta{Name: "library_path", Type: "const char*"}, Value: library},
+				},
+			}
+		}
+		dedup := NewDedup(time.Second)
+		assert.True(t, dedup.Emit(symbolsLoaded(0, "/lib/libc.so.6")))
+		assert.True(t, dedup.Emit(symbolsLoaded(1, "/lib/libssl.so.3")))
+		assert.False(t, dedup.Emit(symbolsLoaded(2, "/lib/libc.so.6")))
+	})
+
+	t.Run("conditions whose window passed are forgotten", func(t *testing.T) {
+		dedup := NewDedup(time.Second)
+		for pid := 0; pid < 100; pid++ {
+			dedup.Emit(wxMapping(pid, pid, 0x1000, 7))
+		}
+		assert.Len(t, dedup.emitted, 100)
+		assert.True(t, dedup.Emit(wxMapping(3*second, 42, 0x1000, 7)))
+		assert.Len(t, dedup.emitted, 1)
+	})
+}
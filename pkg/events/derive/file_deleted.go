@@ -0,0 +1,39 @@
+package derive
+
+import (
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/events/parse"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// FileDeleted derives a file_deleted event from a security_inode_unlink event of a file which was captured, as
+// marked by the captured argument added to the event while it was processed.
+func FileDeleted() deriveFunction {
+	return deriveSingleEvent(events.FileDeleted, deriveFileDeletedArgs)
+}
+
+func deriveFileDeletedArgs(event trace.Event) ([]interface{}, error) {
+	// the argument is only added while files are captured
+	if events.GetArg(&event, "captured") == nil {
+		return nil, nil
+	}
+	captured, err := parse.ArgBoolVal(&event, "captured")
+	if err != nil || !captured {
+		return nil, err
+	}
+
+	pathname, err := parse.ArgStringVal(&event, "pathname")
+	if err != nil {
+		return nil, err
+	}
+	dev, err := parse.ArgUint32Val(&event, "dev")
+	if err != nil {
+		return nil, err
+	}
+	inode, err := parse.ArgUint64Val(&event, "inode")
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{pathname, dev, inode}, nil
+}
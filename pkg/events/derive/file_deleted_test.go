@@ -0,0 +1,68 @@
+package derive
+
+import (
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileDeleted(t *testing.T) {
+	unlinkEvent := func(args ...trace.Argument) trace.Event {
+		return trace.Event{
+			EventID:   int(events.SecurityInodeUnlink),
+			EventName: "security_inode_unlink",
+			ProcessID: 42,
+			Args: append([]trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/tmp/dropper"},
+				{ArgMeta: trace.ArgMeta{Name: "inode", Type: "unsigned long"}, Value: uint64(10)},
+				{ArgMeta: trace.ArgMeta{Name: "dev", Type: "dev_t"}, Value: uint32(1)},
+				{ArgMeta: trace.ArgMeta{Name: "ctime", Type: "u64"}, Value: uint64(1000)},
+			}, args...),
+		}
+	}
+	capturedArg := func(captured bool) trace.Argument {
+		return trace.Argument{ArgMeta: trace.ArgMeta{Name: "captured", Type: "bool"}, Value: captured}
+	}
+
+	testCases := []struct {
+		name            string
+		event           trace.Event
+		expectedDeleted bool
+	}{
+		{
+			name:            "captured file",
+			event:           unlinkEvent(capturedArg(true)),
+			expectedDeleted: true,
+		},
+		{
+			name:  "file which wasn't captured",
+			event: unlinkEvent(capturedArg(false)),
+		},
+		{
+			name:  "no captures",
+			event: unlinkEvent(),
+		},
+	}
+
+	deriveFn := FileDeleted()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			derivedEvents, errs := deriveFn(tc.event)
+			require.Empty(t, errs)
+			if !tc.expectedDeleted {
+				assert.Empty(t, derivedEvents)
+				return
+			}
+			require.Len(t, derivedEvents, 1)
+			derived := derivedEvents[0]
+			assert.Equal(t, int(events.FileDeleted), derived.EventID)
+			assert.Equal(t, 42, derived.ProcessID)
+			assert.Equal(t, "/tmp/dropper", events.GetArg(&derived, "pathname").Value)
+			assert.Equal(t, uint32(1), events.GetArg(&derived, "dev").Value)
+			assert.Equal(t, uint64(10), events.GetArg(&derived, "inode").Value)
+		})
+	}
+}
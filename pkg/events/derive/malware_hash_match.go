@@ -0,0 +1,37 @@
+package derive
+
+import (
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/events/parse"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// MalwareHashMatch derives a malware_hash_match event from a sched_process_exec event of a file whose hash (the
+// sha256 argument added with exec hashing) is in the given denylist of lowercase sha256 hashes.
+func MalwareHashMatch(denylist map[string]struct{}) deriveFunction {
+	return deriveSingleEvent(events.MalwareHashMatch, deriveMalwareHashMatchArgs(denylist))
+}
+
+func deriveMalwareHashMatchArgs(denylist map[string]struct{}) deriveArgsFunction {
+	return func(event trace.Event) ([]interface{}, error) {
+		// files which weren't hashed (e.g. excluded ones) have no hash argument
+		hashArg := events.GetArg(&event, "sha256")
+		if hashArg == nil {
+			return nil, nil
+		}
+		hash, ok := hashArg.Value.(string)
+		if !ok || hash == "" {
+			return nil, nil
+		}
+		if _, ok := denylist[hash]; !ok {
+			return nil, nil
+		}
+
+		pathname, err := parse.ArgStringVal(&event, "pathname")
+		if err != nil {
+			return nil, err
+		}
+
+		return []interface{}{pathname, hash}, nil
+	}
+}
@@ -0,0 +1,75 @@
+package derive
+
+import (
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMalwareHashMatch(t *testing.T) {
+	const (
+		badHash  = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+		goodHash = "dbd318c1c462aee872f41109a4dfd3048871a03dedd0fe0e757ced57dad6f2d7"
+	)
+
+	execEvent := func(args ...trace.Argument) trace.Event {
+		return trace.Event{
+			EventID:   int(events.SchedProcessExec),
+			EventName: "sched_process_exec",
+			ProcessID: 42,
+			Args: append([]trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/tmp/dropper"},
+			}, args...),
+		}
+	}
+	sha256Arg := func(hash string) trace.Argument {
+		return trace.Argument{ArgMeta: trace.ArgMeta{Name: "sha256", Type: "const char*"}, Value: hash}
+	}
+
+	testCases := []struct {
+		name          string
+		event         trace.Event
+		expectedMatch bool
+	}{
+		{
+			name:          "matching binary",
+			event:         execEvent(sha256Arg(badHash)),
+			expectedMatch: true,
+		},
+		{
+			name:  "non matching binary",
+			event: execEvent(sha256Arg(goodHash)),
+		},
+		{
+			name:  "binary which wasn't hashed",
+			event: execEvent(),
+		},
+		{
+			name:  "binary which failed hashing",
+			event: execEvent(sha256Arg("")),
+		},
+	}
+
+	deriveFn := MalwareHashMatch(map[string]struct{}{badHash: {}})
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			derivedEvents, errs := deriveFn(tc.event)
+			require.Empty(t, errs)
+			if !tc.expectedMatch {
+				assert.Empty(t, derivedEvents)
+				return
+			}
+			require.Len(t, derivedEvents, 1)
+			derived := derivedEvents[0]
+			assert.Equal(t, int(events.MalwareHashMatch), derived.EventID)
+			assert.Equal(t, "malware_hash_match", derived.EventName)
+			assert.Equal(t, 42, derived.ProcessID)
+			require.Len(t, derived.Args, 2)
+			assert.Equal(t, "/tmp/dropper", derived.Args[0].Value)
+			assert.Equal(t, badHash, derived.Args[1].Value)
+		})
+	}
+}
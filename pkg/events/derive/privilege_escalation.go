@@ -0,0 +1,54 @@
+package derive
+
+import (
+	"fmt"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// PrivilegeEscalation derives a privilege_escalation event from a commit_creds event in which a process gained
+// root privileges, i.e. its effective user or group ID changed from a non-root ID to 0 (e.g. with setuid(0) or
+// setgid(0)). Processes dropping their root privileges aren't reported.
+func PrivilegeEscalation() deriveFunction {
+	return deriveSingleEvent(events.PrivilegeEscalation, derivePrivilegeEscalationArgs)
+}
+
+func derivePrivilegeEscalationArgs(event trace.Event) ([]interface{}, error) {
+	oldCred, err := slimCredArg(&event, "old_cred")
+	if err != nil {
+		return nil, err
+	}
+	newCred, err := slimCredArg(&event, "new_cred")
+	if err != nil {
+		return nil, err
+	}
+
+	uidEscalated := oldCred.Euid != 0 && newCred.Euid == 0
+	gidEscalated := oldCred.Egid != 0 && newCred.Egid == 0
+	if !uidEscalated && !gidEscalated {
+		return nil, nil
+	}
+
+	// the syscall which changed the credentials is only known when detecting syscalls
+	syscall := int32(-1)
+	if syscallArg := events.GetArg(&event, "syscall"); syscallArg != nil {
+		if id, ok := syscallArg.Value.(int32); ok {
+			syscall = id
+		}
+	}
+
+	return []interface{}{oldCred.Euid, newCred.Euid, oldCred.Egid, newCred.Egid, syscall}, nil
+}
+
+func slimCredArg(event *trace.Event, argName string) (trace.SlimCred, error) {
+	arg := events.GetArg(event, argName)
+	if arg == nil {
+		return trace.SlimCred{}, fmt.Errorf("argument %s not found", argName)
+	}
+	cred, ok := arg.Value.(trace.SlimCred)
+	if !ok {
+		return trace.SlimCred{}, fmt.Errorf("argument %s is not of type trace.SlimCred", argName)
+	}
+	return cred, nil
+}
@@ -0,0 +1,78 @@
+package derive
+
+import (
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrivilegeEscalation(t *testing.T) {
+	cred := func(euid, egid uint32) trace.SlimCred {
+		return trace.SlimCred{Uid: 1000, Gid: 1000, Euid: euid, Egid: egid}
+	}
+	commitCredsEvent := func(oldCred, newCred trace.SlimCred, args ...trace.Argument) trace.Event {
+		return trace.Event{
+			EventID:   int(events.CommitCreds),
+			EventName: "commit_creds",
+			ProcessID: 42,
+			Args: append([]trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "old_cred", Type: "slim_cred_t"}, Value: oldCred},
+				{ArgMeta: trace.ArgMeta{Name: "new_cred", Type: "slim_cred_t"}, Value: newCred},
+			}, args...),
+		}
+	}
+	syscallArg := trace.Argument{ArgMeta: trace.ArgMeta{Name: "syscall", Type: "int"}, Value: int32(events.Setuid)}
+
+	testCases := []struct {
+		name         string
+		event        trace.Event
+		expectedArgs []interface{}
+	}{
+		{
+			name:         "setuid to root",
+			event:        commitCredsEvent(cred(1000, 1000), cred(0, 1000), syscallArg),
+			expectedArgs: []interface{}{uint32(1000), uint32(0), uint32(1000), uint32(1000), int32(events.Setuid)},
+		},
+		{
+			name:         "setgid to root without syscall detection",
+			event:        commitCredsEvent(cred(1000, 1000), cred(1000, 0)),
+			expectedArgs: []interface{}{uint32(1000), uint32(1000), uint32(1000), uint32(0), int32(-1)},
+		},
+		{
+			name:  "root dropping privileges",
+			event: commitCredsEvent(cred(0, 0), cred(1000, 1000), syscallArg),
+		},
+		{
+			name:  "change between non root ids",
+			event: commitCredsEvent(cred(1000, 1000), cred(1001, 1001), syscallArg),
+		},
+		{
+			name:  "root keeping its privileges",
+			event: commitCredsEvent(cred(0, 0), cred(0, 0)),
+		},
+	}
+
+	deriveFn := PrivilegeEscalation()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			derivedEvents, errs := deriveFn(tc.event)
+			require.Empty(t, errs)
+			if tc.expectedArgs == nil {
+				assert.Empty(t, derivedEvents)
+				return
+			}
+			require.Len(t, derivedEvents, 1)
+			derived := derivedEvents[0]
+			assert.Equal(t, int(events.PrivilegeEscalation), derived.EventID)
+			assert.Equal(t, "privilege_escalation", derived.EventName)
+			assert.Equal(t, 42, derived.ProcessID)
+			require.Len(t, derived.Args, len(tc.expectedArgs))
+			for i, expected := range tc.expectedArgs {
+				assert.Equal(t, expected, derived.Args[i].Value)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package derive
+
+import (
+	"fmt"
+
+	"github.com/aquasecurity/libbpfgo/helpers"
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/events/parse"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// injectionRequests are the ptrace requests taking control of another process or changing its memory or registers
+var injectionRequests = map[helpers.PtraceRequestArgument]struct{}{
+	helpers.PTRACE_ATTACH:     {},
+	helpers.PTRACE_SEIZE:      {},
+	helpers.PTRACE_POKETEXT:   {},
+	helpers.PTRACE_POKEDATA:   {},
+	helpers.PTRACE_POKEUSER:   {},
+	helpers.PTRACE_SETREGS:    {},
+	helpers.PTRACE_SETFPREGS:  {},
+	helpers.PTRACE_SETFPXREGS: {},
+	helpers.PTRACE_SETREGSET:  {},
+}
+
+// ProcessInjection derives a process_injection event from a ptrace event which successfully attached to another
+// process, or wrote to its memory or registers (e.g. PTRACE_ATTACH and PTRACE_POKETEXT). Requests which don't act on
+// another process (PTRACE_TRACEME) or only read from it aren't reported.
+func ProcessInjection() deriveFunction {
+	return deriveSingleEvent(events.ProcessInjection, deriveProcessInjectionArgs)
+}
+
+func deriveProcessInjectionArgs(event trace.Event) ([]interface{}, error) {
+	if event.ReturnValue < 0 && event.ReturnValue >= -maxErrno {
+		return nil, nil
+	}
+
+	requestArg := events.GetArg(&event, "request")
+	if requestArg == nil {
+		return nil, fmt.Errorf("argument request not found")
+	}
+	request, ok := requestArg.Value.(int64)
+	if !ok {
+		return nil, fmt.Errorf("argument request is not of type int64")
+	}
+	requestArgument, err := helpers.ParsePtraceRequestArgument(uint64(request))
+	if err != nil {
+		return nil, nil
+	}
+	if _, ok := injectionRequests[requestArgument]; !ok {
+		return nil, nil
+	}
+
+	pid, err := parse.ArgInt32Val(&event, "pid")
+	if err != nil {
+		return nil, err
+	}
+	addrArg := events.GetArg(&event, "addr")
+	if addrArg == nil {
+		return nil, fmt.Errorf("argument addr not found")
+	}
+	addr, ok := addrArg.Value.(uintptr)
+	if !ok {
+		return nil, fmt.Errorf("argument addr is not of type uintptr")
+	}
+
+	return []interface{}{int32(event.ProcessID), pid, requestArgument.String(), addr}, nil
+}
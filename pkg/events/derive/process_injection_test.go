@@ -0,0 +1,104 @@
+package derive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/libbpfgo/helpers"
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessInjection(t *testing.T) {
+	ptraceEvent := func(request helpers.PtraceRequestArgument, pid int32, addr uintptr, ret int) trace.Event {
+		return trace.Event{
+			EventID:       int(events.Ptrace),
+			EventName:     "ptrace",
+			ProcessID:     42,
+			HostProcessID: 4242,
+			ReturnValue:   ret,
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "request", Type: "long"}, Value: int64(request.Value())},
+				{ArgMeta: trace.ArgMeta{Name: "pid", Type: "pid_t"}, Value: pid},
+				{ArgMeta: trace.ArgMeta{Name: "addr", Type: "void*"}, Value: addr},
+				{ArgMeta: trace.ArgMeta{Name: "data", Type: "void*"}, Value: uintptr(0xcc)},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name         string
+		event        trace.Event
+		expectedArgs []interface{}
+	}{
+		{
+			name:         "attach",
+			event:        ptraceEvent(helpers.PTRACE_ATTACH, 1337, 0, 0),
+			expectedArgs: []interface{}{int32(42), int32(1337), "PTRACE_ATTACH", uintptr(0)},
+		},
+		{
+			name:         "write to the code of another process",
+			event:        ptraceEvent(helpers.PTRACE_POKETEXT, 1337, 0x401000, 0),
+			expectedArgs: []interface{}{int32(42), int32(1337), "PTRACE_POKETEXT", uintptr(0x401000)},
+		},
+		{
+			name:         "set the registers of another process",
+			event:        ptraceEvent(helpers.PTRACE_SETREGS, 1337, 0, 0),
+			expectedArgs: []interface{}{int32(42), int32(1337), "PTRACE_SETREGS", uintptr(0)},
+		},
+		{
+			name:  "traceme",
+			event: ptraceEvent(helpers.PTRACE_TRACEME, 0, 0, 0),
+		},
+		{
+			name:  "read from another process",
+			event: ptraceEvent(helpers.PTRACE_PEEKTEXT, 1337, 0x401000, 0),
+		},
+		{
+			name:  "failed attach",
+			event: ptraceEvent(helpers.PTRACE_ATTACH, 1, 0, -1),
+		},
+		{
+			name:  "unknown request",
+			event: ptraceEvent(helpers.PtraceRequestArgument(0x7fff), 1337, 0, 0),
+		},
+	}
+
+	deriveFn := ProcessInjection()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			derivedEvents, errs := deriveFn(tc.event)
+			require.Empty(t, errs)
+			if tc.expectedArgs == nil {
+				assert.Empty(t, derivedEvents)
+				return
+			}
+			require.Len(t, derivedEvents, 1)
+			derived := derivedEvents[0]
+			assert.Equal(t, int(events.ProcessInjection), derived.EventID)
+			assert.Equal(t, "process_injection", derived.EventName)
+			assert.Equal(t, 4242, derived.HostProcessID)
+			require.Len(t, derived.Args, len(tc.expectedArgs))
+			for i, expected := range tc.expectedArgs {
+				assert.Equal(t, expected, derived.Args[i].Value)
+			}
+		})
+	}
+
+	t.Run("repeated writes are one condition", func(t *testing.T) {
+		dedup := NewDedup(time.Second)
+		emit := func(ts int, request helpers.PtraceRequestArgument, addr uintptr) bool {
+			derivedEvents, errs := deriveFn(ptraceEvent(request, 1337, addr, 0))
+			require.Empty(t, errs)
+			require.Len(t, derivedEvents, 1)
+			derivedEvents[0].Timestamp = ts
+			return dedup.Emit(derivedEvents[0])
+		}
+		assert.True(t, emit(0, helpers.PTRACE_POKETEXT, 0x401000))
+		// the address isn't part of the condition
+		assert.False(t, emit(1, helpers.PTRACE_POKETEXT, 0x401008))
+		assert.True(t, emit(2, helpers.PTRACE_SETREGS, 0))
+	})
+}
@@ -0,0 +1,70 @@
+package derive
+
+import (
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/events/parse"
+	"github.com/aquasecurity/tracee/types/trace"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// writtenFilesCacheSize is the number of recently written files tracked for write_then_exec correlation
+const writtenFilesCacheSize = 4096
+
+type fileKey struct {
+	dev   uint32
+	inode uint64
+}
+
+type fileWrite struct {
+	timestamp int
+	pid       int
+}
+
+// WriteThenExec derives a write_then_exec event from a sched_process_exec event of a file which was written
+// within the given window before it was executed. It should be registered for both the write events (vfs_write,
+// vfs_writev and kernel_write), which are used to track the last write of each file, and sched_process_exec.
+func WriteThenExec(window time.Duration) (deriveFunction, error) {
+	writtenFiles, err := lru.New(writtenFilesCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	return deriveSingleEvent(events.WriteThenExec, deriveWriteThenExecArgs(writtenFiles, window)), nil
+}
+
+func deriveWriteThenExecArgs(writtenFiles *lru.Cache, window time.Duration) deriveArgsFunction {
+	return func(event trace.Event) ([]interface{}, error) {
+		dev, err := parse.ArgUint32Val(&event, "dev")
+		if err != nil {
+			return nil, err
+		}
+		inode, err := parse.ArgUint64Val(&event, "inode")
+		if err != nil {
+			return nil, err
+		}
+		key := fileKey{dev, inode}
+
+		if events.ID(event.EventID) != events.SchedProcessExec {
+			writtenFiles.Add(key, fileWrite{event.Timestamp, event.ProcessID})
+			return nil, nil
+		}
+
+		cached, ok := writtenFiles.Get(key)
+		if !ok {
+			return nil, nil
+		}
+		write := cached.(fileWrite)
+		elapsed := time.Duration(event.Timestamp - write.timestamp)
+		if elapsed < 0 || elapsed > window {
+			return nil, nil
+		}
+
+		pathname, err := parse.ArgStringVal(&event, "pathname")
+		if err != nil {
+			return nil, err
+		}
+
+		return []interface{}{pathname, dev, inode, int32(write.pid), uint64(write.timestamp), uint64(event.Timestamp)}, nil
+	}
+}
@@ -0,0 +1,110 @@
+package derive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteThenExec(t *testing.T) {
+	writeEvent := func(ts int, dev uint32, inode uint64) trace.Event {
+		return trace.Event{
+			EventID:   int(events.VfsWrite),
+			EventName: "vfs_write",
+			Timestamp: ts,
+			ProcessID: 10,
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/tmp/dropper"},
+				{ArgMeta: trace.ArgMeta{Name: "dev", Type: "dev_t"}, Value: dev},
+				{ArgMeta: trace.ArgMeta{Name: "inode", Type: "unsigned long"}, Value: inode},
+				{ArgMeta: trace.ArgMeta{Name: "count", Type: "size_t"}, Value: uint64(4096)},
+				{ArgMeta: trace.ArgMeta{Name: "pos", Type: "off_t"}, Value: uint64(0)},
+			},
+		}
+	}
+	execEvent := func(ts int, dev uint32, inode uint64) trace.Event {
+		return trace.Event{
+			EventID:   int(events.SchedProcessExec),
+			EventName: "sched_process_exec",
+			Timestamp: ts,
+			ProcessID: 20,
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/tmp/payload"},
+				{ArgMeta: trace.ArgMeta{Name: "dev", Type: "dev_t"}, Value: dev},
+				{ArgMeta: trace.ArgMeta{Name: "inode", Type: "unsigned long"}, Value: inode},
+			},
+		}
+	}
+
+	const window = 10 * time.Second
+	testCases := []struct {
+		name           string
+		events         []trace.Event
+		expectedWrite  int
+		expectedDerive bool
+	}{
+		{
+			name:           "exec within window",
+			events:         []trace.Event{writeEvent(1000, 1, 2), execEvent(1000+int(time.Second), 1, 2)},
+			expectedWrite:  1000,
+			expectedDerive: true,
+		},
+		{
+			name: "exec within window of last write",
+			events: []trace.Event{
+				writeEvent(1000, 1, 2),
+				writeEvent(1000+int(20*time.Second), 1, 2),
+				execEvent(1000+int(25*time.Second), 1, 2),
+			},
+			expectedWrite:  1000 + int(20*time.Second),
+			expectedDerive: true,
+		},
+		{
+			name:   "exec too late",
+			events: []trace.Event{writeEvent(1000, 1, 2), execEvent(1000+int(window)+1, 1, 2)},
+		},
+		{
+			name:   "exec of another file",
+			events: []trace.Event{writeEvent(1000, 1, 2), execEvent(2000, 1, 3)},
+		},
+		{
+			name:   "exec of file on another device",
+			events: []trace.Event{writeEvent(1000, 1, 2), execEvent(2000, 4, 2)},
+		},
+		{
+			name:   "exec never written file",
+			events: []trace.Event{execEvent(2000, 1, 2)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deriveFn, err := WriteThenExec(window)
+			require.NoError(t, err)
+
+			var derivedEvents []trace.Event
+			for _, event := range tc.events {
+				derived, errs := deriveFn(event)
+				require.Empty(t, errs)
+				derivedEvents = append(derivedEvents, derived...)
+			}
+
+			if !tc.expectedDerive {
+				assert.Empty(t, derivedEvents)
+				return
+			}
+			require.Len(t, derivedEvents, 1)
+			exec := tc.events[len(tc.events)-1]
+			derived := derivedEvents[0]
+			assert.Equal(t, int(events.WriteThenExec), derived.EventID)
+			assert.Equal(t, "write_then_exec", derived.EventName)
+			assert.Equal(t, exec.ProcessID, derived.ProcessID)
+			assert.Equal(t, []interface{}{"/tmp/payload", uint32(1), uint64(2), int32(10), uint64(tc.expectedWrite), uint64(exec.Timestamp)},
+				[]interface{}{derived.Args[0].Value, derived.Args[1].Value, derived.Args[2].Value, derived.Args[3].Value, derived.Args[4].Value, derived.Args[5].Value})
+		})
+	}
+}
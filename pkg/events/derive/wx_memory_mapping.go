@@ -0,0 +1,56 @@
+package derive
+
+import (
+	"fmt"
+
+	"github.com/aquasecurity/libbpfgo/helpers"
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/events/parse"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// maxErrno is the highest error number a syscall may return - a return value in [-maxErrno, -1] is an error
+const maxErrno = 4095
+
+// WXMemoryMapping derives a wx_memory_mapping event from a mmap, mprotect or pkey_mprotect event which
+// successfully created a writable and executable memory region.
+func WXMemoryMapping() deriveFunction {
+	return deriveSingleEvent(events.WXMemoryMapping, deriveWXMemoryMappingArgs)
+}
+
+func deriveWXMemoryMappingArgs(event trace.Event) ([]interface{}, error) {
+	if event.ReturnValue < 0 && event.ReturnValue >= -maxErrno {
+		return nil, nil
+	}
+
+	prot, err := parse.ArgInt32Val(&event, "prot")
+	if err != nil {
+		return nil, err
+	}
+	wx := int32(helpers.PROT_WRITE.Value() | helpers.PROT_EXEC.Value())
+	if prot&wx != wx {
+		return nil, nil
+	}
+
+	addrArg := events.GetArg(&event, "addr")
+	if addrArg == nil {
+		return nil, fmt.Errorf("argument addr not found")
+	}
+	addr, ok := addrArg.Value.(uintptr)
+	if !ok {
+		return nil, fmt.Errorf("argument addr is not of type uintptr")
+	}
+
+	lengthArgName := "len"
+	if events.ID(event.EventID) == events.Mmap {
+		lengthArgName = "length"
+		// the mapping address is chosen by the kernel, and returned by mmap
+		addr = uintptr(event.ReturnValue)
+	}
+	length, err := parse.ArgUint64Val(&event, lengthArgName)
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{int32(event.ProcessID), addr, length, prot}, nil
+}
@@ -0,0 +1,118 @@
+package derive
+
+import (
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWXMemoryMapping(t *testing.T) {
+	const (
+		protRead  = int32(0x1)
+		protWrite = int32(0x2)
+		protExec  = int32(0x4)
+	)
+
+	mmapEvent := func(prot int32, retval int) trace.Event {
+		return trace.Event{
+			EventID:     int(events.Mmap),
+			EventName:   "mmap",
+			ProcessID:   42,
+			ReturnValue: retval,
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "addr", Type: "void*"}, Value: uintptr(0)},
+				{ArgMeta: trace.ArgMeta{Name: "length", Type: "size_t"}, Value: uint64(4096)},
+				{ArgMeta: trace.ArgMeta{Name: "prot", Type: "int"}, Value: prot},
+				{ArgMeta: trace.ArgMeta{Name: "flags", Type: "int"}, Value: int32(0x22)},
+				{ArgMeta: trace.ArgMeta{Name: "fd", Type: "int"}, Value: int32(-1)},
+				{ArgMeta: trace.ArgMeta{Name: "off", Type: "off_t"}, Value: uint64(0)},
+			},
+		}
+	}
+	mprotectEvent := func(prot int32, retval int) trace.Event {
+		return trace.Event{
+			EventID:     int(events.Mprotect),
+			EventName:   "mprotect",
+			ProcessID:   42,
+			ReturnValue: retval,
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "addr", Type: "void*"}, Value: uintptr(0x7f0000001000)},
+				{ArgMeta: trace.ArgMeta{Name: "len", Type: "size_t"}, Value: uint64(8192)},
+				{ArgMeta: trace.ArgMeta{Name: "prot", Type: "int"}, Value: prot},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name         string
+		event        trace.Event
+		expectedArgs []interface{}
+	}{
+		{
+			name:         "mmap rwx",
+			event:        mmapEvent(protRead|protWrite|protExec, 0x7f0000000000),
+			expectedArgs: []interface{}{int32(42), uintptr(0x7f0000000000), uint64(4096), protRead | protWrite | protExec},
+		},
+		{
+			name:         "mmap wx",
+			event:        mmapEvent(protWrite|protExec, 0x7f0000000000),
+			expectedArgs: []interface{}{int32(42), uintptr(0x7f0000000000), uint64(4096), protWrite | protExec},
+		},
+		{
+			name:  "mmap failed",
+			event: mmapEvent(protRead|protWrite|protExec, -12),
+		},
+		{
+			name:  "mmap rw",
+			event: mmapEvent(protRead|protWrite, 0x7f0000000000),
+		},
+		{
+			name:  "mmap rx",
+			event: mmapEvent(protRead|protExec, 0x7f0000000000),
+		},
+		{
+			name:  "mmap w",
+			event: mmapEvent(protWrite, 0x7f0000000000),
+		},
+		{
+			name:  "mmap x",
+			event: mmapEvent(protExec, 0x7f0000000000),
+		},
+		{
+			name:         "mprotect rwx",
+			event:        mprotectEvent(protRead|protWrite|protExec, 0),
+			expectedArgs: []interface{}{int32(42), uintptr(0x7f0000001000), uint64(8192), protRead | protWrite | protExec},
+		},
+		{
+			name:  "mprotect failed",
+			event: mprotectEvent(protRead|protWrite|protExec, -13),
+		},
+		{
+			name:  "mprotect r",
+			event: mprotectEvent(protRead, 0),
+		},
+	}
+
+	deriveFn := WXMemoryMapping()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			derivedEvents, errs := deriveFn(tc.event)
+			require.Empty(t, errs)
+			if tc.expectedArgs == nil {
+				assert.Empty(t, derivedEvents)
+				return
+			}
+			require.Len(t, derivedEvents, 1)
+			derived := derivedEvents[0]
+			assert.Equal(t, int(events.WXMemoryMapping), derived.EventID)
+			assert.Equal(t, "wx_memory_mapping", derived.EventName)
+			require.Len(t, derived.Args, len(tc.expectedArgs))
+			for i, value := range tc.expectedArgs {
+				assert.Equal(t, value, derived.Args[i].Value)
+			}
+		})
+	}
+}
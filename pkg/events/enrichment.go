@@ -0,0 +1,25 @@
+package events
+
+import (
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// EnrichmentParams are event arguments which are not submitted by the eBPF programs,
+// but are added to the event in userspace while it is being processed (e.g. file hashes).
+// Filters on these arguments can only be applied after the event was processed.
+var EnrichmentParams = map[ID][]trace.ArgMeta{
+	SchedProcessExec: {
+		{Type: "const char*", Name: "sha256"},
+		{Type: "const char*", Name: "parent_sha256"},
+	},
+}
+
+// IsEnrichmentParam checks if the given argument is added to the event in userspace
+func IsEnrichmentParam(id ID, argName string) bool {
+	for _, param := range EnrichmentParams[id] {
+		if param.Name == argName {
+			return true
+		}
+	}
+	return false
+}
@@ -168,6 +168,14 @@ const (
 	HookedSyscalls
 	HookedSeqOps
 	SymbolsLoaded
+	WXMemoryMapping
+	WriteThenExec
+	MalwareHashMatch
+	PrivilegeEscalation
+	FileDeleted
+	Heartbeat
+	ProcessInjection
+	CaptureHook
 	MaxUserSpace
 )
 
@@ -179,6 +187,8 @@ const (
 	CaptureMem
 	CaptureProfile
 	CapturePcap
+	CaptureSharedObject
+	CaptureFileOpen
 )
 
 const (
@@ -5766,6 +5776,143 @@ var Definitions = eventDefinitions{
 				{Type: "const char*const*", Name: "symbols"},
 			},
 		},
+		WXMemoryMapping: {
+			ID32Bit: sys32undefined,
+			Name:    "wx_memory_mapping",
+			DocPath: "security_alerts/wx_memory_mapping.md",
+			Probes:  []probeDependency{},
+			Dependencies: dependencies{
+				Events: []eventDependency{
+					{EventID: Mmap},
+					{EventID: Mprotect},
+					{EventID: PkeyMprotect},
+				},
+			},
+			Sets: []string{"derived", "proc_mem", "security_alert"},
+			Params: []trace.ArgMeta{
+				{Type: "int", Name: "pid"},
+				{Type: "void*", Name: "address"},
+				{Type: "size_t", Name: "length"},
+				{Type: "int", Name: "prot"},
+			},
+		},
+		WriteThenExec: {
+			ID32Bit: sys32undefined,
+			Name:    "write_then_exec",
+			DocPath: "security_alerts/write_then_exec.md",
+			Probes:  []probeDependency{},
+			Dependencies: dependencies{
+				Events: []eventDependency{
+					{EventID: VfsWrite},
+					{EventID: VfsWritev},
+					{EventID: KernelWrite},
+					{EventID: SchedProcessExec},
+				},
+			},
+			Sets: []string{"derived", "fs", "proc", "security_alert"},
+			Params: []trace.ArgMeta{
+				{Type: "const char*", Name: "pathname"},
+				{Type: "dev_t", Name: "dev"},
+				{Type: "unsigned long", Name: "inode"},
+				{Type: "int", Name: "writer_pid"},
+				{Type: "unsigned long", Name: "write_time"},
+				{Type: "unsigned long", Name: "exec_time"},
+			},
+		},
+		MalwareHashMatch: {
+			ID32Bit: sys32undefined,
+			Name:    "malware_hash_match",
+			DocPath: "security_alerts/malware_hash_match.md",
+			Probes:  []probeDependency{},
+			Dependencies: dependencies{
+				Events: []eventDependency{
+					{EventID: SchedProcessExec},
+				},
+			},
+			Sets: []string{"derived", "fs", "proc", "security_alert"},
+			Params: []trace.ArgMeta{
+				{Type: "const char*", Name: "pathname"},
+				{Type: "const char*", Name: "sha256"},
+			},
+		},
+		FileDeleted: {
+			ID32Bit: sys32undefined,
+			Name:    "file_deleted",
+			DocPath: "derived/file_deleted.md",
+			Probes:  []probeDependency{},
+			Dependencies: dependencies{
+				Events: []eventDependency{
+					{EventID: SecurityInodeUnlink},
+					{EventID: SecurityInodeRename},
+				},
+			},
+			Sets: []string{"derived", "fs"},
+			Params: []trace.ArgMeta{
+				{Type: "const char*", Name: "pathname"},
+				{Type: "dev_t", Name: "dev"},
+				{Type: "unsigned long", Name: "inode"},
+			},
+		},
+		PrivilegeEscalation: {
+			ID32Bit: sys32undefined,
+			Name:    "privilege_escalation",
+			DocPath: "security_alerts/privilege_escalation.md",
+			Probes:  []probeDependency{},
+			Dependencies: dependencies{
+				Events: []eventDependency{
+					{EventID: CommitCreds},
+				},
+			},
+			Sets: []string{"derived", "proc", "proc_ids", "security_alert"},
+			Params: []trace.ArgMeta{
+				{Type: "u32", Name: "old_euid"},
+				{Type: "u32", Name: "new_euid"},
+				{Type: "u32", Name: "old_egid"},
+				{Type: "u32", Name: "new_egid"},
+				{Type: "int", Name: "syscall"},
+			},
+		},
+		Heartbeat: {
+			ID32Bit: sys32undefined,
+			Name:    "heartbeat",
+			DocPath: "usermode/heartbeat.md",
+			Sets:    []string{},
+			Params: []trace.ArgMeta{
+				{Type: "unsigned long", Name: "events_count"},
+				{Type: "unsigned long", Name: "lost_events"},
+				{Type: "unsigned long", Name: "uptime"},
+			},
+		},
+		ProcessInjection: {
+			ID32Bit: sys32undefined,
+			Name:    "process_injection",
+			DocPath: "security_alerts/process_injection.md",
+			Probes:  []probeDependency{},
+			Dependencies: dependencies{
+				Events: []eventDependency{
+					{EventID: Ptrace},
+				},
+			},
+			Sets: []string{"derived", "proc", "security_alert"},
+			Params: []trace.ArgMeta{
+				{Type: "int", Name: "tracer_pid"},
+				{Type: "pid_t", Name: "tracee_pid"},
+				{Type: "const char*", Name: "request"},
+				{Type: "void*", Name: "addr"},
+			},
+		},
+		CaptureHook: {
+			ID32Bit: sys32undefined,
+			Name:    "capture_hook",
+			DocPath: "usermode/capture_hook.md",
+			Sets:    []string{},
+			Params: []trace.ArgMeta{
+				{Type: "const char*", Name: "pathname"},
+				{Type: "int", Name: "exit_code"},
+				{Type: "const char*", Name: "output"},
+				{Type: "bool", Name: "timed_out"},
+			},
+		},
 		CaptureFileWrite: {
 			ID32Bit:  sys32undefined,
 			Name:     "capture_file_write",
@@ -5844,6 +5991,30 @@ var Definitions = eventDefinitions{
 				Capabilities: []cap.Value{cap.NET_ADMIN},
 			},
 		},
+		CaptureSharedObject: {
+			ID32Bit:  sys32undefined,
+			Name:     "capture_shared_object",
+			Internal: true,
+			Dependencies: dependencies{
+				Events: []eventDependency{{EventID: SharedObjectLoaded}},
+				Capabilities: []cap.Value{
+					cap.SYS_PTRACE,
+					cap.DAC_OVERRIDE,
+				},
+			},
+		},
+		CaptureFileOpen: {
+			ID32Bit:  sys32undefined,
+			Name:     "capture_file_open",
+			Internal: true,
+			Dependencies: dependencies{
+				Events: []eventDependency{{EventID: SecurityFileOpen}},
+				Capabilities: []cap.Value{
+					cap.SYS_PTRACE,
+					cap.DAC_OVERRIDE,
+				},
+			},
+		},
 		DoInitModule: {
 			ID32Bit: sys32undefined,
 			Name:    "do_init_module",
@@ -0,0 +1,32 @@
+package events
+
+import (
+	"sync"
+)
+
+var (
+	namesToIDsOnce sync.Once
+	namesToIDs     map[string]ID
+)
+
+// EventName returns the name of the event with the given ID
+func EventName(id uint32) (string, bool) {
+	evt, ok := Definitions.GetSafe(ID(id))
+	if !ok {
+		return "", false
+	}
+	return evt.Name, true
+}
+
+// EventID returns the ID of the event with the given name
+func EventID(name string) (uint32, bool) {
+	// event definitions don't change after initialization, so the lookup table is built once
+	namesToIDsOnce.Do(func() {
+		namesToIDs = Definitions.NamesToIDs()
+	})
+	id, ok := namesToIDs[name]
+	if !ok {
+		return 0, false
+	}
+	return uint32(id), true
+}
@@ -0,0 +1,65 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventName(t *testing.T) {
+	testCases := []struct {
+		name         string
+		id           uint32
+		expectedName string
+		expectedOk   bool
+	}{
+		{name: "syscall", id: uint32(Execve), expectedName: "execve", expectedOk: true},
+		{name: "common event", id: uint32(VfsWrite), expectedName: "vfs_write", expectedOk: true},
+		{name: "derived event", id: uint32(WriteThenExec), expectedName: "write_then_exec", expectedOk: true},
+		{name: "unknown", id: 99999, expectedOk: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			name, ok := EventName(tc.id)
+			assert.Equal(t, tc.expectedOk, ok)
+			assert.Equal(t, tc.expectedName, name)
+		})
+	}
+}
+
+func TestEventID(t *testing.T) {
+	testCases := []struct {
+		name       string
+		eventName  string
+		expectedID uint32
+		expectedOk bool
+	}{
+		{name: "syscall", eventName: "execve", expectedID: uint32(Execve), expectedOk: true},
+		{name: "common event", eventName: "vfs_write", expectedID: uint32(VfsWrite), expectedOk: true},
+		{name: "derived event", eventName: "write_then_exec", expectedID: uint32(WriteThenExec), expectedOk: true},
+		{name: "unknown", eventName: "not_an_event", expectedOk: false},
+		{name: "empty", eventName: "", expectedOk: false},
+		{name: "case sensitive", eventName: "VFS_WRITE", expectedOk: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			id, ok := EventID(tc.eventName)
+			assert.Equal(t, tc.expectedOk, ok)
+			assert.Equal(t, tc.expectedID, id)
+		})
+	}
+}
+
+func TestEventIDRoundTrip(t *testing.T) {
+	for id, evt := range Definitions.Events() {
+		name, ok := EventName(uint32(id))
+		assert.True(t, ok)
+		assert.Equal(t, evt.Name, name)
+
+		gotID, ok := EventID(name)
+		assert.True(t, ok)
+		assert.Equal(t, uint32(id), gotID, name)
+	}
+}
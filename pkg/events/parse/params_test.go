@@ -352,3 +352,61 @@ func TestArgUlongArrVal(t *testing.T) {
 		})
 	}
 }
+
+func TestArgBoolVal(t *testing.T) {
+	tests := []struct {
+		name          string
+		arg           trace.Argument
+		expectedValue bool
+		errorMessage  string
+	}{
+		{
+			name: "valid_val",
+			arg: trace.Argument{
+				ArgMeta: trace.ArgMeta{
+					Name: "valid_val",
+					Type: "bool",
+				},
+				Value: true,
+			},
+			expectedValue: true,
+		},
+		{
+			name: "invalid_val",
+			arg: trace.Argument{
+				ArgMeta: trace.ArgMeta{
+					Name: "invalid_val",
+					Type: "bool",
+				},
+				Value: int32(1),
+			},
+			errorMessage: "argument invalid_val is not of type bool",
+		},
+		{
+			name: "no_val",
+			arg: trace.Argument{
+				ArgMeta: trace.ArgMeta{
+					Name: "does_not_exist_val",
+					Type: "bool",
+				},
+				Value: true,
+			},
+			errorMessage: "argument no_val not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := trace.Event{Args: []trace.Argument{tt.arg}}
+			val, err := ArgBoolVal(&e, tt.name)
+			if tt.errorMessage != "" {
+				assert.Error(t, err)
+				assert.Equal(t, tt.errorMessage, err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedValue, val)
+			}
+		})
+	}
+
+}
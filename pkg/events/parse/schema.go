@@ -0,0 +1,144 @@
+package parse
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// ArgType is the type of the decoded value of an argument
+type ArgType int
+
+const (
+	Int32 ArgType = iota
+	Uint32
+	Uint64
+	Bool
+	String
+	StringArray
+	UlongArray
+)
+
+func (t ArgType) String() string {
+	switch t {
+	case Int32:
+		return "int32"
+	case Uint32:
+		return "uint32"
+	case Uint64:
+		return "uint64"
+	case Bool:
+		return "bool"
+	case String:
+		return "string"
+	case StringArray:
+		return "string array"
+	case UlongArray:
+		return "ulong array"
+	}
+	return fmt.Sprintf("ArgType(%d)", int(t))
+}
+
+// matches checks if a decoded value is of the type
+func (t ArgType) matches(value interface{}) bool {
+	var ok bool
+	switch t {
+	case Int32:
+		_, ok = value.(int32)
+	case Uint32:
+		_, ok = value.(uint32)
+	case Uint64:
+		_, ok = value.(uint64)
+	case Bool:
+		_, ok = value.(bool)
+	case String:
+		_, ok = value.(string)
+	case StringArray:
+		_, ok = value.([]string)
+	case UlongArray:
+		_, ok = value.([]uint64)
+	}
+	return ok
+}
+
+// Schema is the types of the arguments of an event which are used, by their names
+type Schema map[string]ArgType
+
+// Args are the arguments of an event decoded by a Schema, whose values are of the types of the schema
+type Args struct {
+	values map[string]interface{}
+}
+
+// Decode checks that an event has all the arguments of the schema, of their types, and returns their values. As
+// with the Arg*Val functions, the first argument of each name is decoded
+func (s Schema) Decode(event *trace.Event) (Args, error) {
+	values := make(map[string]interface{}, len(s))
+	for _, arg := range event.Args {
+		argType, ok := s[arg.Name]
+		if !ok {
+			continue
+		}
+		if _, decoded := values[arg.Name]; decoded {
+			continue
+		}
+		if !argType.matches(arg.Value) {
+			return Args{}, fmt.Errorf("argument %s is not of type %s", arg.Name, argType)
+		}
+		values[arg.Name] = arg.Value
+	}
+	if len(values) < len(s) {
+		missing := make([]string, 0, len(s)-len(values))
+		for name := range s {
+			if _, ok := values[name]; !ok {
+				missing = append(missing, name)
+			}
+		}
+		sort.Strings(missing)
+		return Args{}, fmt.Errorf("argument %s not found", missing[0])
+	}
+	return Args{values: values}, nil
+}
+
+// Validate checks that an event has all the arguments of the schema, of their types
+func (s Schema) Validate(event *trace.Event) error {
+	_, err := s.Decode(event)
+	return err
+}
+
+// The getters of the decoded arguments return the zero value for arguments which aren't of the schema
+
+func (a Args) Int32(name string) int32 {
+	val, _ := a.values[name].(int32)
+	return val
+}
+
+func (a Args) Uint32(name string) uint32 {
+	val, _ := a.values[name].(uint32)
+	return val
+}
+
+func (a Args) Uint64(name string) uint64 {
+	val, _ := a.values[name].(uint64)
+	return val
+}
+
+func (a Args) Bool(name string) bool {
+	val, _ := a.values[name].(bool)
+	return val
+}
+
+func (a Args) String(name string) string {
+	val, _ := a.values[name].(string)
+	return val
+}
+
+func (a Args) StringArray(name string) []string {
+	val, _ := a.values[name].([]string)
+	return val
+}
+
+func (a Args) UlongArray(name string) []uint64 {
+	val, _ := a.values[name].([]uint64)
+	return val
+}
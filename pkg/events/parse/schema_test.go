@@ -0,0 +1,73 @@
+package parse
+
+import (
+	"testing"
+
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaDecode(t *testing.T) {
+	schema := Schema{
+		"pathname": String,
+		"dev":      Uint32,
+		"inode":    Uint64,
+		"argv":     StringArray,
+	}
+	newEvent := func(args ...trace.Argument) *trace.Event {
+		return &trace.Event{EventName: "test", Args: args}
+	}
+	pathname := trace.Argument{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/bin/ls"}
+	dev := trace.Argument{ArgMeta: trace.ArgMeta{Name: "dev", Type: "dev_t"}, Value: uint32(271581185)}
+	inode := trace.Argument{ArgMeta: trace.ArgMeta{Name: "inode", Type: "unsigned long"}, Value: uint64(2493759)}
+	argv := trace.Argument{ArgMeta: trace.ArgMeta{Name: "argv", Type: "const char**"}, Value: []string{"ls", "-l"}}
+	flags := trace.Argument{ArgMeta: trace.ArgMeta{Name: "flags", Type: "int"}, Value: int32(0)}
+
+	t.Run("well formed", func(t *testing.T) {
+		// arguments which aren't of the schema are ignored
+		event := newEvent(flags, pathname, dev, inode, argv)
+		require.NoError(t, schema.Validate(event))
+
+		args, err := schema.Decode(event)
+		require.NoError(t, err)
+		assert.Equal(t, "/bin/ls", args.String("pathname"))
+		assert.Equal(t, uint32(271581185), args.Uint32("dev"))
+		assert.Equal(t, uint64(2493759), args.Uint64("inode"))
+		assert.Equal(t, []string{"ls", "-l"}, args.StringArray("argv"))
+		assert.Equal(t, int32(0), args.Int32("flags"))
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		testCases := []struct {
+			name         string
+			event        *trace.Event
+			errorMessage string
+		}{
+			{
+				name:         "missing argument",
+				event:        newEvent(pathname, inode, argv),
+				errorMessage: "argument dev not found",
+			},
+			{
+				name: "mismatched type",
+				event: newEvent(pathname, dev, argv, trace.Argument{
+					ArgMeta: trace.ArgMeta{Name: "inode", Type: "unsigned long"}, Value: int64(2493759),
+				}),
+				errorMessage: "argument inode is not of type uint64",
+			},
+			{
+				name:         "no arguments",
+				event:        newEvent(),
+				errorMessage: "argument argv not found",
+			},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				assert.EqualError(t, schema.Validate(tc.event), tc.errorMessage)
+				_, err := schema.Decode(tc.event)
+				assert.EqualError(t, err, tc.errorMessage)
+			})
+		}
+	})
+}
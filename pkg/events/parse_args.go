@@ -44,7 +44,7 @@ func ParseArgs(event *trace.Event) error {
 				alertArg.Type = "string"
 			}
 		}
-	case SysEnter, SysExit, CapCapable, CommitCreds, SecurityFileOpen, TaskRename, SecurityMmapFile:
+	case SysEnter, SysExit, CapCapable, CommitCreds, PrivilegeEscalation, SecurityFileOpen, TaskRename, SecurityMmapFile:
 		if syscallArg := GetArg(event, "syscall"); syscallArg != nil {
 			if id, isInt32 := syscallArg.Value.(int32); isInt32 {
 				if event, isKnown := Definitions.GetSafe(ID(id)); isKnown {
@@ -222,6 +222,34 @@ func ParseArgs(event *trace.Event) error {
 	return nil
 }
 
+// ParseArgsIncludeRaw parses the arguments of an event like ParseArgs, but keeps the raw values of the parsed
+// arguments, adding their parsed values as "<name>_decoded" arguments
+func ParseArgsIncludeRaw(event *trace.Event) error {
+	rawArgs := make([]trace.Argument, len(event.Args))
+	copy(rawArgs, event.Args)
+
+	if err := ParseArgs(event); err != nil {
+		return err
+	}
+
+	// arguments are parsed into strings, so parsed arguments are the ones which weren't strings before
+	for i := range rawArgs {
+		if _, wasString := rawArgs[i].Value.(string); wasString {
+			continue
+		}
+		if _, isString := event.Args[i].Value.(string); !isString {
+			continue
+		}
+		decoded := event.Args[i]
+		decoded.Name = rawArgs[i].Name + "_decoded"
+		event.Args[i] = rawArgs[i]
+		event.Args = append(event.Args, decoded)
+		event.ArgsNum++
+	}
+
+	return nil
+}
+
 func ParseArgsFDs(event *trace.Event, fdArgPathMap *bpf.BPFMap) error {
 	if fdArg := GetArg(event, "fd"); fdArg != nil {
 		if fd, isInt32 := fdArg.Value.(int32); isInt32 {
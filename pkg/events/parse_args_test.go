@@ -187,3 +187,41 @@ func TestParseArgs(t *testing.T) {
 		}
 	})
 }
+
+func TestParseArgsIncludeRaw(t *testing.T) {
+	newOpenatEvent := func() *trace.Event {
+		return &trace.Event{
+			EventID: int(Openat),
+			ArgsNum: 3,
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "dirfd", Type: "int"}, Value: int32(-100)},
+				{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/etc/passwd"},
+				{ArgMeta: trace.ArgMeta{Name: "flags", Type: "int"}, Value: int32(helpers.O_WRONLY.Value() | helpers.O_CREAT.Value())},
+			},
+		}
+	}
+	decodedFlags, err := helpers.ParseOpenFlagArgument(helpers.O_WRONLY.Value() | helpers.O_CREAT.Value())
+	require.NoError(t, err)
+
+	t.Run("enabled", func(t *testing.T) {
+		event := newOpenatEvent()
+		require.NoError(t, ParseArgsIncludeRaw(event))
+
+		assert.Equal(t, 4, event.ArgsNum)
+		assert.Equal(t, &trace.Argument{ArgMeta: trace.ArgMeta{Name: "flags", Type: "int"}, Value: int32(helpers.O_WRONLY.Value() | helpers.O_CREAT.Value())}, GetArg(event, "flags"))
+		assert.Equal(t, &trace.Argument{ArgMeta: trace.ArgMeta{Name: "flags_decoded", Type: "string"}, Value: decodedFlags.String()}, GetArg(event, "flags_decoded"))
+		// arguments which aren't parsed are kept once
+		assert.Equal(t, int32(-100), GetArg(event, "dirfd").Value)
+		assert.Nil(t, GetArg(event, "dirfd_decoded"))
+		assert.Nil(t, GetArg(event, "pathname_decoded"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		event := newOpenatEvent()
+		require.NoError(t, ParseArgs(event))
+
+		assert.Equal(t, 3, event.ArgsNum)
+		assert.Equal(t, &trace.Argument{ArgMeta: trace.ArgMeta{Name: "flags", Type: "string"}, Value: decodedFlags.String()}, GetArg(event, "flags"))
+		assert.Nil(t, GetArg(event, "flags_decoded"))
+	})
+}
@@ -0,0 +1,5 @@
+package events
+
+// SchemaVersion is the version of the structure of the emitted events, so consumers of structured outputs can tell
+// formats apart. It should be bumped whenever the emitted structure of events or of their arguments changes.
+const SchemaVersion = "1"
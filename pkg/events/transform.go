@@ -0,0 +1,187 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aquasecurity/libbpfgo/helpers"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// ArgTransform normalizes the value of an event argument in place, and may return new arguments derived from it
+// to be appended to the event. Transforms are applied to every matching event, so they must be pure and cheap.
+type ArgTransform func(arg *trace.Argument) []trace.Argument
+
+// ArgTransforms maps an event argument to the transforms applied to it, by order of registration
+type ArgTransforms map[ID]map[string][]ArgTransform
+
+// Register adds a transform of the given event argument
+func (at ArgTransforms) Register(id ID, argName string, transform ArgTransform) {
+	if at[id] == nil {
+		at[id] = make(map[string][]ArgTransform)
+	}
+	at[id][argName] = append(at[id][argName], transform)
+}
+
+// Apply runs the registered transforms on the arguments of the given event
+func (at ArgTransforms) Apply(event *trace.Event) {
+	argTransforms, ok := at[ID(event.EventID)]
+	if !ok {
+		return
+	}
+
+	// only transform the original arguments, and not the ones derived by the transforms
+	argsNum := len(event.Args)
+	for i := 0; i < argsNum; i++ {
+		for _, transform := range argTransforms[event.Args[i].Name] {
+			derivedArgs := transform(&event.Args[i])
+			event.Args = append(event.Args, derivedArgs...)
+			event.ArgsNum += len(derivedArgs)
+		}
+	}
+}
+
+// TrimNullBytes trims trailing null bytes of a string argument
+func TrimNullBytes(arg *trace.Argument) []trace.Argument {
+	if str, isString := arg.Value.(string); isString {
+		arg.Value = strings.TrimRight(str, "\x00")
+	}
+	return nil
+}
+
+// LowerCase lowercases a string argument
+func LowerCase(arg *trace.Argument) []trace.Argument {
+	if str, isString := arg.Value.(string); isString {
+		arg.Value = strings.ToLower(str)
+	}
+	return nil
+}
+
+// DecodeSocketDomain decodes a socket address family argument (e.g. AF_INET)
+func DecodeSocketDomain(arg *trace.Argument) []trace.Argument {
+	if dom, isInt32 := arg.Value.(int32); isInt32 {
+		if socketDomainArgument, err := helpers.ParseSocketDomainArgument(uint64(dom)); err == nil {
+			arg.Type = "string"
+			arg.Value = socketDomainArgument.String()
+		}
+	}
+	return nil
+}
+
+// DecodeSocketType decodes a socket type argument (e.g. SOCK_STREAM|SOCK_CLOEXEC)
+func DecodeSocketType(arg *trace.Argument) []trace.Argument {
+	if typ, isInt32 := arg.Value.(int32); isInt32 {
+		if socketTypeArgument, err := helpers.ParseSocketType(uint64(typ)); err == nil {
+			arg.Type = "string"
+			arg.Value = socketTypeArgument.String()
+		}
+	}
+	return nil
+}
+
+// RegisterFlagsDecoders registers the transforms decoding bitmask arguments into symbolic strings, and the socket
+// domain and type arguments into their names
+func (at ArgTransforms) RegisterFlagsDecoders() {
+	for _, id := range []ID{Open, Openat, SecurityFileOpen} {
+		at.Register(id, "flags", DecodeOpenFlags)
+	}
+	for _, id := range []ID{Mmap, Mprotect, PkeyMprotect, SecurityFileMprotect, SecurityMmapFile} {
+		at.Register(id, "prot", DecodeMmapProt)
+	}
+	for _, id := range []ID{Socket, Socketpair} {
+		at.Register(id, "domain", DecodeSocketDomain)
+		at.Register(id, "type", DecodeSocketType)
+	}
+	at.Register(SecuritySocketCreate, "family", DecodeSocketDomain)
+	at.Register(SecuritySocketCreate, "type", DecodeSocketType)
+}
+
+// argUint64Val returns the value of an integer argument as uint64
+func argUint64Val(arg *trace.Argument) (uint64, bool) {
+	switch v := arg.Value.(type) {
+	case int32:
+		return uint64(uint32(v)), true
+	case uint32:
+		return uint64(v), true
+	case int64:
+		return uint64(v), true
+	case uint64:
+		return v, true
+	}
+	return 0, false
+}
+
+// decodeFlags decodes the given flags out of a bitmask, and returns them with the hex value of any unknown bits left
+func decodeFlags(rawValue uint64, flags []helpers.SystemFunctionArgument) []string {
+	var f []string
+	for _, flag := range flags {
+		if flag.Value() != 0 && rawValue&flag.Value() == flag.Value() {
+			f = append(f, flag.String())
+			rawValue &^= flag.Value()
+		}
+	}
+	if rawValue != 0 {
+		f = append(f, fmt.Sprintf("0x%x", rawValue))
+	}
+	return f
+}
+
+// openFlags are the open(2) file creation and status flags, with flags containing others (e.g. O_SYNC contains
+// O_DSYNC) coming first
+var openFlags = []helpers.SystemFunctionArgument{
+	helpers.O_CREAT, helpers.O_EXCL, helpers.O_NOCTTY, helpers.O_TRUNC, helpers.O_APPEND, helpers.O_NONBLOCK,
+	helpers.O_SYNC, helpers.O_DSYNC, helpers.FASYNC, helpers.O_DIRECT, helpers.O_LARGEFILE, helpers.O_DIRECTORY,
+	helpers.O_NOFOLLOW, helpers.O_NOATIME, helpers.O_CLOEXEC, helpers.O_PATH, helpers.O_TMPFILE,
+}
+
+// DecodeOpenFlags decodes an open(2) flags argument into a "<name>_str" argument (e.g. O_RDWR|O_CREAT|O_CLOEXEC),
+// keeping the raw value. Unknown bits are decoded to their hex value
+func DecodeOpenFlags(arg *trace.Argument) []trace.Argument {
+	rawValue, ok := argUint64Val(arg)
+	if !ok {
+		return nil
+	}
+
+	var f []string
+	switch rawValue & helpers.O_ACCMODE.Value() {
+	case helpers.O_RDONLY.Value():
+		f = append(f, helpers.O_RDONLY.String())
+	case helpers.O_WRONLY.Value():
+		f = append(f, helpers.O_WRONLY.String())
+	case helpers.O_RDWR.Value():
+		f = append(f, helpers.O_RDWR.String())
+	default:
+		f = append(f, fmt.Sprintf("0x%x", rawValue&helpers.O_ACCMODE.Value()))
+	}
+	f = append(f, decodeFlags(rawValue&^helpers.O_ACCMODE.Value(), openFlags)...)
+
+	return []trace.Argument{
+		{ArgMeta: trace.ArgMeta{Name: arg.Name + "_str", Type: "string"}, Value: strings.Join(f, "|")},
+	}
+}
+
+// mmapProtFlags are the mmap(2) and mprotect(2) memory protection flags
+var mmapProtFlags = []helpers.SystemFunctionArgument{
+	helpers.PROT_READ, helpers.PROT_WRITE, helpers.PROT_EXEC, helpers.PROT_SEM, helpers.PROT_GROWSDOWN, helpers.PROT_GROWSUP,
+}
+
+// DecodeMmapProt decodes a memory protection argument into a "<name>_str" argument (e.g. PROT_READ|PROT_EXEC),
+// keeping the raw value. It also adds a "wx" argument, which is true for writable and executable mappings.
+// Unknown bits are decoded to their hex value
+func DecodeMmapProt(arg *trace.Argument) []trace.Argument {
+	rawValue, ok := argUint64Val(arg)
+	if !ok {
+		return nil
+	}
+
+	protStr := helpers.PROT_NONE.String()
+	if rawValue != helpers.PROT_NONE.Value() {
+		protStr = strings.Join(decodeFlags(rawValue, mmapProtFlags), "|")
+	}
+	wx := helpers.PROT_WRITE.Value() | helpers.PROT_EXEC.Value()
+
+	return []trace.Argument{
+		{ArgMeta: trace.ArgMeta{Name: arg.Name + "_str", Type: "string"}, Value: protStr},
+		{ArgMeta: trace.ArgMeta{Name: "wx", Type: "bool"}, Value: rawValue&wx == wx},
+	}
+}
@@ -0,0 +1,278 @@
+package events
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aquasecurity/libbpfgo/helpers"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArgTransforms(t *testing.T) {
+	testCases := []struct {
+		name         string
+		transforms   func(at ArgTransforms)
+		event        trace.Event
+		expectedArgs []trace.Argument
+	}{
+		{
+			name:       "no transforms",
+			transforms: func(at ArgTransforms) {},
+			event: trace.Event{
+				EventID: int(Socket),
+				Args: []trace.Argument{
+					{ArgMeta: trace.ArgMeta{Name: "domain", Type: "int"}, Value: int32(helpers.AF_INET.Value())},
+				},
+			},
+			expectedArgs: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "domain", Type: "int"}, Value: int32(helpers.AF_INET.Value())},
+			},
+		},
+		{
+			name: "socket domain and type",
+			transforms: func(at ArgTransforms) {
+				at.Register(Socket, "domain", DecodeSocketDomain)
+				at.Register(Socket, "type", DecodeSocketType)
+			},
+			event: trace.Event{
+				EventID: int(Socket),
+				Args: []trace.Argument{
+					{ArgMeta: trace.ArgMeta{Name: "domain", Type: "int"}, Value: int32(helpers.AF_INET.Value())},
+					{ArgMeta: trace.ArgMeta{Name: "type", Type: "int"}, Value: int32(helpers.SOCK_STREAM.Value())},
+					{ArgMeta: trace.ArgMeta{Name: "protocol", Type: "int"}, Value: int32(0)},
+				},
+			},
+			expectedArgs: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "domain", Type: "string"}, Value: "AF_INET"},
+				{ArgMeta: trace.ArgMeta{Name: "type", Type: "string"}, Value: "SOCK_STREAM"},
+				{ArgMeta: trace.ArgMeta{Name: "protocol", Type: "int"}, Value: int32(0)},
+			},
+		},
+		{
+			name: "transforms of another event",
+			transforms: func(at ArgTransforms) {
+				at.Register(SecuritySocketCreate, "family", DecodeSocketDomain)
+			},
+			event: trace.Event{
+				EventID: int(Socket),
+				Args: []trace.Argument{
+					{ArgMeta: trace.ArgMeta{Name: "family", Type: "int"}, Value: int32(helpers.AF_INET.Value())},
+				},
+			},
+			expectedArgs: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "family", Type: "int"}, Value: int32(helpers.AF_INET.Value())},
+			},
+		},
+		{
+			name: "chained string transforms",
+			transforms: func(at ArgTransforms) {
+				at.Register(Openat, "pathname", TrimNullBytes)
+				at.Register(Openat, "pathname", LowerCase)
+			},
+			event: trace.Event{
+				EventID: int(Openat),
+				Args: []trace.Argument{
+					{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/Tmp/FOO\x00\x00"},
+				},
+			},
+			expectedArgs: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/tmp/foo"},
+			},
+		},
+		{
+			name: "derived argument",
+			transforms: func(at ArgTransforms) {
+				at.Register(Openat, "pathname", func(arg *trace.Argument) []trace.Argument {
+					return []trace.Argument{
+						{ArgMeta: trace.ArgMeta{Name: "pathname_upper", Type: "string"}, Value: strings.ToUpper(arg.Value.(string))},
+					}
+				})
+			},
+			event: trace.Event{
+				EventID: int(Openat),
+				ArgsNum: 1,
+				Args: []trace.Argument{
+					{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/tmp/foo"},
+				},
+			},
+			expectedArgs: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/tmp/foo"},
+				{ArgMeta: trace.ArgMeta{Name: "pathname_upper", Type: "string"}, Value: "/TMP/FOO"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			at := ArgTransforms{}
+			tc.transforms(at)
+			event := tc.event
+			at.Apply(&event)
+			assert.Equal(t, tc.expectedArgs, event.Args)
+			assert.Equal(t, len(tc.expectedArgs)-len(tc.event.Args), event.ArgsNum-tc.event.ArgsNum)
+		})
+	}
+}
+
+func TestDecodeOpenFlags(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    interface{}
+		expected string
+	}{
+		{
+			name:     "read only",
+			value:    int32(0),
+			expected: "O_RDONLY",
+		},
+		{
+			name:     "read write create cloexec",
+			value:    int32(helpers.O_RDWR.Value() | helpers.O_CREAT.Value() | helpers.O_CLOEXEC.Value()),
+			expected: "O_RDWR|O_CREAT|O_CLOEXEC",
+		},
+		{
+			name:     "write only truncate",
+			value:    int32(helpers.O_WRONLY.Value() | helpers.O_CREAT.Value() | helpers.O_TRUNC.Value()),
+			expected: "O_WRONLY|O_CREAT|O_TRUNC",
+		},
+		{
+			name:     "directory",
+			value:    int32(helpers.O_RDONLY.Value() | helpers.O_NONBLOCK.Value() | helpers.O_DIRECTORY.Value() | helpers.O_CLOEXEC.Value()),
+			expected: "O_RDONLY|O_NONBLOCK|O_DIRECTORY|O_CLOEXEC",
+		},
+		{
+			name:     "sync contains dsync",
+			value:    int32(helpers.O_WRONLY.Value() | helpers.O_SYNC.Value()),
+			expected: "O_WRONLY|O_SYNC",
+		},
+		{
+			name:     "dsync",
+			value:    int32(helpers.O_WRONLY.Value() | helpers.O_DSYNC.Value()),
+			expected: "O_WRONLY|O_DSYNC",
+		},
+		{
+			name:     "unknown bits",
+			value:    int32(helpers.O_RDWR.Value() | helpers.O_APPEND.Value() | 0x40000000),
+			expected: "O_RDWR|O_APPEND|0x40000000",
+		},
+		{
+			name:     "invalid access mode",
+			value:    int32(helpers.O_ACCMODE.Value()),
+			expected: "0x3",
+		},
+		{
+			name:     "unsigned value",
+			value:    uint32(helpers.O_RDWR.Value() | helpers.O_EXCL.Value()),
+			expected: "O_RDWR|O_EXCL",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			arg := trace.Argument{ArgMeta: trace.ArgMeta{Name: "flags", Type: "int"}, Value: tc.value}
+			derivedArgs := DecodeOpenFlags(&arg)
+			assert.Equal(t, []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "flags_str", Type: "string"}, Value: tc.expected},
+			}, derivedArgs)
+			// raw value is kept
+			assert.Equal(t, tc.value, arg.Value)
+		})
+	}
+
+	t.Run("registered decoders", func(t *testing.T) {
+		at := ArgTransforms{}
+		at.RegisterFlagsDecoders()
+		event := trace.Event{
+			EventID: int(Openat),
+			ArgsNum: 2,
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: "/etc/passwd"},
+				{ArgMeta: trace.ArgMeta{Name: "flags", Type: "int"}, Value: int32(helpers.O_RDONLY.Value() | helpers.O_CLOEXEC.Value())},
+			},
+		}
+		at.Apply(&event)
+		assert.Equal(t, 3, event.ArgsNum)
+		flagsStr := GetArg(&event, "flags_str")
+		if assert.NotNil(t, flagsStr) {
+			assert.Equal(t, "O_RDONLY|O_CLOEXEC", flagsStr.Value)
+		}
+
+		// socket domains and types are replaced by their names
+		socket := trace.Event{
+			EventID: int(Socket),
+			ArgsNum: 3,
+			Args: []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "domain", Type: "int"}, Value: int32(helpers.AF_UNIX.Value())},
+				{ArgMeta: trace.ArgMeta{Name: "type", Type: "int"}, Value: int32(helpers.SOCK_DGRAM.Value())},
+				{ArgMeta: trace.ArgMeta{Name: "protocol", Type: "int"}, Value: int32(0)},
+			},
+		}
+		at.Apply(&socket)
+		assert.Equal(t, []trace.Argument{
+			{ArgMeta: trace.ArgMeta{Name: "domain", Type: "string"}, Value: "AF_UNIX"},
+			{ArgMeta: trace.ArgMeta{Name: "type", Type: "string"}, Value: "SOCK_DGRAM"},
+			{ArgMeta: trace.ArgMeta{Name: "protocol", Type: "int"}, Value: int32(0)},
+		}, socket.Args)
+	})
+}
+
+func TestDecodeMmapProt(t *testing.T) {
+	testCases := []struct {
+		name        string
+		value       interface{}
+		expectedStr string
+		expectedWX  bool
+	}{
+		{
+			name:        "none",
+			value:       int32(0),
+			expectedStr: "PROT_NONE",
+		},
+		{
+			name:        "read",
+			value:       int32(helpers.PROT_READ.Value()),
+			expectedStr: "PROT_READ",
+		},
+		{
+			name:        "read write",
+			value:       int32(helpers.PROT_READ.Value() | helpers.PROT_WRITE.Value()),
+			expectedStr: "PROT_READ|PROT_WRITE",
+		},
+		{
+			name:        "read exec",
+			value:       int32(helpers.PROT_READ.Value() | helpers.PROT_EXEC.Value()),
+			expectedStr: "PROT_READ|PROT_EXEC",
+		},
+		{
+			name:        "read write exec",
+			value:       int32(helpers.PROT_READ.Value() | helpers.PROT_WRITE.Value() | helpers.PROT_EXEC.Value()),
+			expectedStr: "PROT_READ|PROT_WRITE|PROT_EXEC",
+			expectedWX:  true,
+		},
+		{
+			name:        "write exec",
+			value:       int32(helpers.PROT_WRITE.Value() | helpers.PROT_EXEC.Value()),
+			expectedStr: "PROT_WRITE|PROT_EXEC",
+			expectedWX:  true,
+		},
+		{
+			name:        "unknown bits",
+			value:       int32(helpers.PROT_READ.Value() | 0x10),
+			expectedStr: "PROT_READ|0x10",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			arg := trace.Argument{ArgMeta: trace.ArgMeta{Name: "prot", Type: "int"}, Value: tc.value}
+			derivedArgs := DecodeMmapProt(&arg)
+			assert.Equal(t, []trace.Argument{
+				{ArgMeta: trace.ArgMeta{Name: "prot_str", Type: "string"}, Value: tc.expectedStr},
+				{ArgMeta: trace.ArgMeta{Name: "wx", Type: "bool"}, Value: tc.expectedWX},
+			}, derivedArgs)
+			// raw value is kept
+			assert.Equal(t, tc.value, arg.Value)
+		})
+	}
+}
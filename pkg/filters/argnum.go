@@ -0,0 +1,56 @@
+package filters
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+)
+
+// ArgnumFilter drops events submitted with fewer arguments than a minimum set per event, e.g. when their arguments
+// were truncated by the eBPF programs
+type ArgnumFilter struct {
+	Filters map[events.ID]uint8
+	Enabled bool
+}
+
+func (filter *ArgnumFilter) Parse(filterName string, operatorAndValues string, eventsNameToID map[string]events.ID) error {
+	filter.Enabled = true
+	// Argnum filter has the following format: "event.argnum>=val"
+	// filterName have the format event.argnum, and operatorAndValues have the format ">=val" or ">val"
+	splitFilter := strings.Split(filterName, ".")
+	if len(splitFilter) != 2 || splitFilter[1] != "argnum" {
+		return fmt.Errorf("invalid argnum filter format %s%s", filterName, operatorAndValues)
+	}
+	eventName := splitFilter[0]
+
+	id, ok := eventsNameToID[eventName]
+	if !ok {
+		return fmt.Errorf("invalid argnum filter event name: %s", eventName)
+	}
+
+	var valueString string
+	inclusive := false
+	switch {
+	case strings.HasPrefix(operatorAndValues, ">="):
+		valueString = operatorAndValues[2:]
+		inclusive = true
+	case strings.HasPrefix(operatorAndValues, ">"):
+		valueString = operatorAndValues[1:]
+	default:
+		return fmt.Errorf("invalid argnum filter operator %s%s, only '>' and '>=' are supported", filterName, operatorAndValues)
+	}
+
+	val, err := strconv.ParseUint(valueString, 10, 8)
+	if err != nil || (!inclusive && val == 255) {
+		return fmt.Errorf("invalid argnum filter value: %s", valueString)
+	}
+	min := uint8(val)
+	if !inclusive {
+		min++
+	}
+	filter.Filters[id] = min
+
+	return nil
+}
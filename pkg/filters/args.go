@@ -13,8 +13,9 @@ type ArgFilter struct {
 }
 
 type ArgFilterVal struct {
-	Equal    []string
-	NotEqual []string
+	Equal      []string
+	NotEqual   []string
+	EqualFiles []*FileSet // sets of values read from files, which the argument may equal as well as Equal
 }
 
 func (filter *ArgFilter) Parse(filterName string, operatorAndValues string, eventsNameToID map[string]events.ID) error {
@@ -40,7 +41,7 @@ func (filter *ArgFilter) Parse(filterName string, operatorAndValues string, even
 	eventParams := eventDefinition.Params
 
 	// check if argument name exists for this event
-	argFound := false
+	argFound := events.IsEnrichmentParam(id, argName)
 	for i := range eventParams {
 		if eventParams[i].Name == argName {
 			argFound = true
@@ -52,6 +53,21 @@ func (filter *ArgFilter) Parse(filterName string, operatorAndValues string, even
 		return fmt.Errorf("invalid argument filter argument name: %s", argName)
 	}
 
+	// the values to equal may be given by a file, as "=@/path/to/file"
+	if strings.HasPrefix(operatorAndValues, "=@") {
+		set, err := NewFileSet(strings.TrimPrefix(operatorAndValues, "=@"))
+		if err != nil {
+			return err
+		}
+		if _, ok := filter.Filters[id]; !ok {
+			filter.Filters[id] = make(map[string]ArgFilterVal)
+		}
+		val := filter.Filters[id][argName]
+		val.EqualFiles = append(val.EqualFiles, set)
+		filter.Filters[id][argName] = val
+		return nil
+	}
+
 	strFilter := &StringFilter{
 		Equal:    []string{},
 		NotEqual: []string{},
@@ -0,0 +1,133 @@
+package filters
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"unsafe"
+
+	bpf "github.com/aquasecurity/libbpfgo"
+	"github.com/aquasecurity/tracee/pkg/events"
+)
+
+// MaxBinaryPathSize value should match MAX_BIN_PATH_SIZE defined in BPF code
+const MaxBinaryPathSize = 128
+
+// BinaryFilter keeps only the events of processes executing one of the binaries allowed per event. Unlike the
+// argument filters, it is filtered by the bpf code, so the dropped events are never submitted to userspace
+type BinaryFilter struct {
+	Filters map[events.ID][]string
+	Enabled bool
+}
+
+func (filter *BinaryFilter) Parse(filterName string, operatorAndValues string, eventsNameToID map[string]events.ID) error {
+	filter.Enabled = true
+	// Binary filter has the following format: "event.binary=/path/to/binary1,/path/to/binary2"
+	// filterName have the format event.binary, and operatorAndValues have the format "=/path/to/binary1,..."
+	splitFilter := strings.Split(filterName, ".")
+	if len(splitFilter) != 2 || splitFilter[1] != "binary" {
+		return fmt.Errorf("invalid binary filter format %s%s", filterName, operatorAndValues)
+	}
+	eventName := splitFilter[0]
+
+	id, ok := eventsNameToID[eventName]
+	if !ok {
+		return fmt.Errorf("invalid binary filter event name: %s", eventName)
+	}
+
+	if !strings.HasPrefix(operatorAndValues, "=") || len(operatorAndValues) == 1 {
+		return fmt.Errorf("invalid binary filter operator %s%s, only '=' is supported", filterName, operatorAndValues)
+	}
+
+	for _, path := range strings.Split(operatorAndValues[1:], ",") {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("invalid binary filter value: %s, binaries are given by their absolute path", path)
+		}
+		if len(path) >= MaxBinaryPathSize {
+			return fmt.Errorf("invalid binary filter value: %s, paths are limited to %d characters", path, MaxBinaryPathSize-1)
+		}
+		filter.Filters[id] = append(filter.Filters[id], path)
+	}
+
+	return nil
+}
+
+// BPFMap is the part of a bpf map used to populate it
+type BPFMap interface {
+	Update(key, value unsafe.Pointer) error
+}
+
+func (filter *BinaryFilter) InitBPF(bpfModule *bpf.Module, eventsMapName string, pathsMapName string, binariesMapName string) error {
+	if !filter.Enabled {
+		return nil
+	}
+
+	eventsMap, err := bpfModule.GetMap(eventsMapName)
+	if err != nil {
+		return err
+	}
+	pathsMap, err := bpfModule.GetMap(pathsMapName)
+	if err != nil {
+		return err
+	}
+	binariesMap, err := bpfModule.GetMap(binariesMapName)
+	if err != nil {
+		return err
+	}
+	return filter.UpdateBPF(eventsMap, pathsMap, binariesMap)
+}
+
+// binaryIDs numbers the binaries of the filter from 1 (0 is for the binaries which aren't filtered), in the order
+// of their paths
+func (filter *BinaryFilter) binaryIDs() map[string]uint32 {
+	var paths []string
+	ids := make(map[string]uint32)
+	for _, eventPaths := range filter.Filters {
+		for _, path := range eventPaths {
+			if _, ok := ids[path]; !ok {
+				ids[path] = 0
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	for i, path := range paths {
+		ids[path] = uint32(i + 1)
+	}
+	return ids
+}
+
+// UpdateBPF populates the maps of the binary filter. The bpf code looks up the id of the binary of a task once it
+// executes it, so the events of the task are filtered by its id rather than by its path:
+// 1. binary_filter_events   u32, u32                      // events filtered by the binary of their process
+// 2. binary_filter_paths    char[MAX_BIN_PATH_SIZE], u32  // ids of the binaries of the binary filter
+// 3. binary_filter          {u32, u32}, u32               // binary ids allowed for each filtered event
+func (filter *BinaryFilter) UpdateBPF(eventsMap BPFMap, pathsMap BPFMap, binariesMap BPFMap) error {
+	allowed := uint32(1) // const need local var for bpfMap.Update()
+	ids := filter.binaryIDs()
+	for path, id := range ids {
+		binaryID := id
+		key := make([]byte, MaxBinaryPathSize)
+		copy(key, path)
+		if err := pathsMap.Update(unsafe.Pointer(&key[0]), unsafe.Pointer(&binaryID)); err != nil {
+			return err
+		}
+	}
+	for id, paths := range filter.Filters {
+		eventID := uint32(id)
+		if err := eventsMap.Update(unsafe.Pointer(&eventID), unsafe.Pointer(&allowed)); err != nil {
+			return err
+		}
+		for _, path := range paths {
+			key := make([]byte, 8)
+			binary.LittleEndian.PutUint32(key[0:4], eventID)
+			binary.LittleEndian.PutUint32(key[4:8], ids[path])
+			if err := binariesMap.Update(unsafe.Pointer(&key[0]), unsafe.Pointer(&allowed)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
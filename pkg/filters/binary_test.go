@@ -0,0 +1,64 @@
+package filters_test
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/filters"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBPFMap records the entries it's updated with
+type fakeBPFMap struct {
+	keySize int
+	entries map[string]uint32
+}
+
+func (m *fakeBPFMap) Update(key, value unsafe.Pointer) error {
+	m.entries[string(unsafe.Slice((*byte)(key), m.keySize))] = *(*uint32)(value)
+	return nil
+}
+
+func TestBinaryFilterUpdateBPF(t *testing.T) {
+	filter := &filters.BinaryFilter{Filters: make(map[events.ID][]string)}
+	require.NoError(t, filter.Parse("openat.binary", "=/usr/sbin/nginx,/usr/bin/curl", events.Definitions.NamesToIDs()))
+	require.NoError(t, filter.Parse("close.binary", "=/usr/sbin/nginx", events.Definitions.NamesToIDs()))
+
+	eventsMap := &fakeBPFMap{keySize: 4, entries: make(map[string]uint32)}
+	pathsMap := &fakeBPFMap{keySize: filters.MaxBinaryPathSize, entries: make(map[string]uint32)}
+	binariesMap := &fakeBPFMap{keySize: 8, entries: make(map[string]uint32)}
+	require.NoError(t, filter.UpdateBPF(eventsMap, pathsMap, binariesMap))
+
+	eventKey := func(id events.ID) string {
+		key := make([]byte, 4)
+		binary.LittleEndian.PutUint32(key, uint32(id))
+		return string(key)
+	}
+	pathKey := func(path string) string {
+		return path + strings.Repeat("\x00", filters.MaxBinaryPathSize-len(path))
+	}
+	binaryKey := func(id events.ID, binaryID uint32) string {
+		key := make([]byte, 4)
+		binary.LittleEndian.PutUint32(key, binaryID)
+		return eventKey(id) + string(key)
+	}
+
+	assert.Equal(t, map[string]uint32{
+		eventKey(events.Openat): 1,
+		eventKey(events.Close):  1,
+	}, eventsMap.entries)
+	// binaries are numbered once, in the order of their paths
+	assert.Equal(t, map[string]uint32{
+		pathKey("/usr/bin/curl"):   1,
+		pathKey("/usr/sbin/nginx"): 2,
+	}, pathsMap.entries)
+	assert.Equal(t, map[string]uint32{
+		binaryKey(events.Openat, 2): 1,
+		binaryKey(events.Openat, 1): 1,
+		binaryKey(events.Close, 2):  1,
+	}, binariesMap.entries)
+}
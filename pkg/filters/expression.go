@@ -0,0 +1,185 @@
+package filters
+
+import (
+	"fmt"
+
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// ExprFilter filters events by Common Expression Language (CEL) expressions, evaluated against the event context
+// (as 'event') and the event arguments (as 'args'), e.g. "event.retval < 0 && args.pathname.startsWith('/etc')".
+// An event must match all the expressions to pass the filter. An expression which fails evaluating on an event
+// (e.g. accessing an argument the event doesn't have) doesn't match it.
+type ExprFilter struct {
+	programs []cel.Program
+	Enabled  bool
+}
+
+var exprFilterEnv *cel.Env
+
+func init() {
+	var err error
+	exprFilterEnv, err = cel.NewEnv(cel.Declarations(
+		decls.NewVar("event", decls.NewMapType(decls.String, decls.Dyn)),
+		decls.NewVar("args", decls.NewMapType(decls.String, decls.Dyn)),
+	))
+	if err != nil {
+		panic(fmt.Sprintf("failed constructing filter expression environment: %v", err))
+	}
+}
+
+// Parse compiles an expression, so it is only evaluated when filtering events
+func (filter *ExprFilter) Parse(expression string) error {
+	ast, issues := exprFilterEnv.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return fmt.Errorf("invalid filter expression %s: %v", expression, issues.Err())
+	}
+	resultType := ast.ResultType()
+	if resultType.GetPrimitive() != exprpb.Type_BOOL && resultType.GetDyn() == nil {
+		return fmt.Errorf("invalid filter expression %s: expected a boolean expression", expression)
+	}
+	program, err := exprFilterEnv.Program(ast)
+	if err != nil {
+		return fmt.Errorf("invalid filter expression %s: %v", expression, err)
+	}
+
+	filter.programs = append(filter.programs, program)
+	filter.Enabled = true
+	return nil
+}
+
+// unprintedFields are the context fields of the events being processed which printed events don't have, as
+// trace.Event doesn't carry them
+var unprintedFields = map[string]bool{"gid": true}
+
+// ParsePrinted compiles an expression evaluated on printed events (see FilterEvent). Expressions using context fields
+// which printed events don't have are refused, rather than never matching
+func (filter *ExprFilter) ParsePrinted(expression string) error {
+	if ast, issues := exprFilterEnv.Parse(expression); issues == nil || issues.Err() == nil {
+		if field, ok := unprintedField(ast.Expr()); ok {
+			return fmt.Errorf("invalid filter expression %s: printed events have no event.%s", expression, field)
+		}
+	}
+	return filter.Parse(expression)
+}
+
+// unprintedField returns a field of unprintedFields which an expression reads from the event context, if any
+func unprintedField(e *exprpb.Expr) (string, bool) {
+	var children []*exprpb.Expr
+	switch kind := e.ExprKind.(type) {
+	case *exprpb.Expr_SelectExpr:
+		// event.gid
+		if ident := kind.SelectExpr.Operand.GetIdentExpr(); ident != nil && ident.Name == "event" && unprintedFields[kind.SelectExpr.Field] {
+			return kind.SelectExpr.Field, true
+		}
+		children = append(children, kind.SelectExpr.Operand)
+	case *exprpb.Expr_CallExpr:
+		// event["gid"]
+		if args := kind.CallExpr.Args; kind.CallExpr.Function == operators.Index && len(args) == 2 {
+			if ident := args[0].GetIdentExpr(); ident != nil && ident.Name == "event" {
+				if field := args[1].GetConstExpr().GetStringValue(); unprintedFields[field] {
+					return field, true
+				}
+			}
+		}
+		if kind.CallExpr.Target != nil {
+			children = append(children, kind.CallExpr.Target)
+		}
+		children = append(children, kind.CallExpr.Args...)
+	case *exprpb.Expr_ListExpr:
+		children = append(children, kind.ListExpr.Elements...)
+	case *exprpb.Expr_StructExpr:
+		for _, entry := range kind.StructExpr.Entries {
+			if key := entry.GetMapKey(); key != nil {
+				children = append(children, key)
+			}
+			children = append(children, entry.Value)
+		}
+	case *exprpb.Expr_ComprehensionExpr:
+		comprehension := kind.ComprehensionExpr
+		children = append(children, comprehension.IterRange, comprehension.AccuInit, comprehension.LoopCondition, comprehension.LoopStep, comprehension.Result)
+	}
+	for _, child := range children {
+		if field, ok := unprintedField(child); ok {
+			return field, true
+		}
+	}
+	return "", false
+}
+
+// Filter checks if an event with the given context fields and arguments matches all the expressions
+func (filter *ExprFilter) Filter(context map[string]interface{}, args map[string]interface{}) bool {
+	vars := map[string]interface{}{
+		"event": context,
+		"args":  args,
+	}
+	for _, program := range filter.programs {
+		out, _, err := program.Eval(vars)
+		if err != nil || out.Type() != types.BoolType || !out.Value().(bool) {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterEvent checks if a printed event matches all the expressions. Its context fields are named as those of the
+// events being processed (except for the unprintedFields), along with the container fields it is enriched with
+func (filter *ExprFilter) FilterEvent(event trace.Event) bool {
+	context := map[string]interface{}{
+		"timestamp":      int64(event.Timestamp),
+		"processorId":    int64(event.ProcessorID),
+		"pid":            int64(event.ProcessID),
+		"tid":            int64(event.ThreadID),
+		"ppid":           int64(event.ParentProcessID),
+		"hostPid":        int64(event.HostProcessID),
+		"hostTid":        int64(event.HostThreadID),
+		"hostPpid":       int64(event.HostParentProcessID),
+		"uid":            int64(event.UserID),
+		"mntns":          int64(event.MountNS),
+		"pidns":          int64(event.PIDNS),
+		"comm":           event.ProcessName,
+		"uts":            event.HostName,
+		"cgroupId":       int64(event.CgroupID),
+		"eventId":        int64(event.EventID),
+		"eventName":      event.EventName,
+		"argnum":         int64(event.ArgsNum),
+		"retval":         int64(event.ReturnValue),
+		"containerId":    event.ContainerID,
+		"containerImage": event.ContainerImage,
+		"containerName":  event.ContainerName,
+		"podName":        event.PodName,
+		"podNamespace":   event.PodNamespace,
+		"podUid":         event.PodUID,
+	}
+	return filter.Filter(context, ExprArgs(event.Args))
+}
+
+// ExprArgs returns the arguments of an event by their names. Integers are given as int, like the context fields,
+// except for 64 bit unsigned arguments which may not fit
+func ExprArgs(args []trace.Argument) map[string]interface{} {
+	values := make(map[string]interface{}, len(args))
+	for _, arg := range args {
+		switch v := arg.Value.(type) {
+		case int8:
+			values[arg.Name] = int64(v)
+		case int16:
+			values[arg.Name] = int64(v)
+		case int32:
+			values[arg.Name] = int64(v)
+		case uint8:
+			values[arg.Name] = int64(v)
+		case uint16:
+			values[arg.Name] = int64(v)
+		case uint32:
+			values[arg.Name] = int64(v)
+		default:
+			values[arg.Name] = v
+		}
+	}
+	return values
+}
@@ -0,0 +1,51 @@
+package filters_test
+
+import (
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/filters"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExprFilter_FilterEvent(t *testing.T) {
+	filter := &filters.ExprFilter{}
+	require.NoError(t, filter.ParsePrinted("event.uid == 0 && event.containerImage.startsWith('nginx')"))
+	require.NoError(t, filter.ParsePrinted("args.pathname != '/etc/shadow'"))
+
+	event := trace.Event{
+		UserID:         0,
+		ContainerImage: "nginx:1.23",
+		Args:           []trace.Argument{{ArgMeta: trace.ArgMeta{Name: "pathname"}, Value: "/etc/passwd"}},
+	}
+	assert.True(t, filter.FilterEvent(event))
+	event.UserID = 1000
+	assert.False(t, filter.FilterEvent(event))
+	event.UserID = 0
+	event.Args[0].Value = "/etc/shadow"
+	assert.False(t, filter.FilterEvent(event))
+}
+
+func TestExprFilter_ParsePrinted(t *testing.T) {
+	// printed events have no gid, so expressions using it would never match
+	for _, expression := range []string{
+		"event.gid == 0",
+		"event['gid'] == 0",
+		"event.uid == 0 || event.gid == 0",
+		"[0, 4].exists(g, event.gid == g)",
+	} {
+		filter := &filters.ExprFilter{}
+		err := filter.ParsePrinted(expression)
+		assert.EqualError(t, err, "invalid filter expression "+expression+": printed events have no event.gid", expression)
+		assert.False(t, filter.Enabled, expression)
+	}
+
+	// the gid of the arguments of an event is printed
+	filter := &filters.ExprFilter{}
+	require.NoError(t, filter.ParsePrinted("args.gid == 0"))
+	assert.True(t, filter.FilterEvent(trace.Event{Args: []trace.Argument{{ArgMeta: trace.ArgMeta{Name: "gid"}, Value: int32(0)}}}))
+
+	// invalid expressions are refused as by Parse
+	assert.EqualError(t, filter.ParsePrinted("event.uid +"), (&filters.ExprFilter{}).Parse("event.uid +").Error())
+}
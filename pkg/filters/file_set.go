@@ -0,0 +1,82 @@
+package filters
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultFileSetCheckInterval is how often a FileSet checks if its file was modified
+const DefaultFileSetCheckInterval = time.Second
+
+// FileSet is a set of values given by a file, one per line, which is read again once the file is modified (by its
+// mtime), so large lists changing independently of the configuration (e.g. an allowlist of binaries) can filter
+// events. Empty lines and lines starting with '#' are ignored
+type FileSet struct {
+	Path          string
+	CheckInterval time.Duration // how often the file is checked for modifications
+
+	mtx       sync.Mutex
+	values    []string
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+// NewFileSet reads the set of values of the given file
+func NewFileSet(path string) (*FileSet, error) {
+	set := &FileSet{Path: path, CheckInterval: DefaultFileSetCheckInterval}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read values file: %v", err)
+	}
+	if err := set.load(info.ModTime()); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// Values returns the current values of the set, reading the file again if it was modified since it was last read.
+// If the file can't be read anymore, the values last read are kept
+func (set *FileSet) Values() []string {
+	set.mtx.Lock()
+	defer set.mtx.Unlock()
+
+	now := time.Now()
+	if now.Sub(set.lastCheck) < set.CheckInterval {
+		return set.values
+	}
+	set.lastCheck = now
+	if info, err := os.Stat(set.Path); err == nil && !info.ModTime().Equal(set.modTime) {
+		set.load(info.ModTime())
+	}
+	return set.values
+}
+
+// load reads the values of the file, which was modified at the given time
+func (set *FileSet) load(modTime time.Time) error {
+	f, err := os.Open(set.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read values file: %v", err)
+	}
+	defer f.Close()
+
+	var values []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		values = append(values, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read values file %s: %v", set.Path, err)
+	}
+	set.values = values
+	set.modTime = modTime
+	set.lastCheck = time.Now()
+	return nil
+}
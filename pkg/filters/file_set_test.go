@@ -0,0 +1,73 @@
+package filters_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/filters"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeValuesFile writes the values file with the given content and modification time
+func writeValuesFile(t *testing.T, path string, content string, modTime time.Time) {
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestFileSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allowlist")
+	modTime := time.Unix(1661500000, 0)
+	writeValuesFile(t, path, "# permitted binaries\n/usr/bin/ls\n\n  /usr/sbin/nginx  \n", modTime)
+
+	set, err := filters.NewFileSet(path)
+	require.NoError(t, err)
+	set.CheckInterval = 0
+	assert.Equal(t, []string{"/usr/bin/ls", "/usr/sbin/nginx"}, set.Values())
+
+	// the file is read again once it's modified
+	writeValuesFile(t, path, "/usr/bin/curl\n", modTime.Add(time.Second))
+	assert.Equal(t, []string{"/usr/bin/curl"}, set.Values())
+
+	// the values last read are kept if the file is gone
+	require.NoError(t, os.Remove(path))
+	assert.Equal(t, []string{"/usr/bin/curl"}, set.Values())
+
+	t.Run("modifications are checked at intervals", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "allowlist")
+		writeValuesFile(t, path, "/usr/bin/ls\n", modTime)
+		set, err := filters.NewFileSet(path)
+		require.NoError(t, err)
+		set.CheckInterval = time.Hour
+
+		writeValuesFile(t, path, "/usr/bin/curl\n", modTime.Add(time.Second))
+		assert.Equal(t, []string{"/usr/bin/ls"}, set.Values())
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := filters.NewFileSet(filepath.Join(t.TempDir(), "missing"))
+		assert.ErrorContains(t, err, "failed to read values file")
+	})
+}
+
+func TestArgFilterParseFileSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allowlist")
+	writeValuesFile(t, path, "/usr/bin/ls\n", time.Unix(1661500000, 0))
+
+	filter := &filters.ArgFilter{Filters: make(map[events.ID]map[string]filters.ArgFilterVal)}
+	require.NoError(t, filter.Parse("execve.pathname", "=@"+path, events.Definitions.NamesToIDs()))
+	require.NoError(t, filter.Parse("execve.pathname", "=/usr/bin/curl", events.Definitions.NamesToIDs()))
+	assert.True(t, filter.Enabled)
+
+	val := filter.Filters[events.Execve]["pathname"]
+	assert.Equal(t, []string{"/usr/bin/curl"}, val.Equal)
+	require.Len(t, val.EqualFiles, 1)
+	assert.Equal(t, path, val.EqualFiles[0].Path)
+	assert.Equal(t, []string{"/usr/bin/ls"}, val.EqualFiles[0].Values())
+
+	assert.ErrorContains(t, filter.Parse("execve.pathname", "=@"+path+".missing", events.Definitions.NamesToIDs()), "failed to read values file")
+}
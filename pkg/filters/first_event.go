@@ -0,0 +1,51 @@
+package filters
+
+import (
+	"sync"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+)
+
+// FirstEventFilter keeps only the first event of every event type of a process, dropping its repeats, for a summary
+// of what each process did. Processes are told by their host pid, and are forgotten once they exit, as their pid may
+// be reused
+type FirstEventFilter struct {
+	Enabled bool
+
+	mtx  sync.Mutex
+	seen map[uint32]map[events.ID]struct{} // the event types seen of every process, by its host pid
+}
+
+// Filter checks if an event is the first of its type of the process of the given host pid, recording it as seen
+func (filter *FirstEventFilter) Filter(eventID events.ID, hostPid uint32) bool {
+	if filter == nil || !filter.Enabled {
+		return true
+	}
+	filter.mtx.Lock()
+	defer filter.mtx.Unlock()
+
+	if filter.seen == nil {
+		filter.seen = make(map[uint32]map[events.ID]struct{})
+	}
+	seen, ok := filter.seen[hostPid]
+	if !ok {
+		seen = make(map[events.ID]struct{})
+		filter.seen[hostPid] = seen
+	}
+	if _, ok := seen[eventID]; ok {
+		return false
+	}
+	seen[eventID] = struct{}{}
+	return true
+}
+
+// ProcessExited forgets the events seen of the process of the given host pid, so a new process reusing its pid has
+// its first events kept as well
+func (filter *FirstEventFilter) ProcessExited(hostPid uint32) {
+	if filter == nil || !filter.Enabled {
+		return
+	}
+	filter.mtx.Lock()
+	defer filter.mtx.Unlock()
+	delete(filter.seen, hostPid)
+}
@@ -0,0 +1,36 @@
+package filters_test
+
+import (
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/filters"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFirstEventFilter(t *testing.T) {
+	filter := &filters.FirstEventFilter{Enabled: true}
+
+	assert.True(t, filter.Filter(events.Openat, 1000))
+	assert.False(t, filter.Filter(events.Openat, 1000))
+	assert.False(t, filter.Filter(events.Openat, 1000))
+	// the events of every type, and of every process, are tracked apart
+	assert.True(t, filter.Filter(events.Close, 1000))
+	assert.True(t, filter.Filter(events.Openat, 1001))
+	assert.False(t, filter.Filter(events.Close, 1000))
+
+	// a process reusing the pid of an exited one is tracked anew
+	filter.ProcessExited(1000)
+	assert.True(t, filter.Filter(events.Openat, 1000))
+	assert.False(t, filter.Filter(events.Openat, 1001))
+
+	t.Run("disabled", func(t *testing.T) {
+		var nilFilter *filters.FirstEventFilter
+		assert.True(t, nilFilter.Filter(events.Openat, 1000))
+		nilFilter.ProcessExited(1000)
+
+		filter := &filters.FirstEventFilter{}
+		assert.True(t, filter.Filter(events.Openat, 1000))
+		assert.True(t, filter.Filter(events.Openat, 1000))
+	})
+}
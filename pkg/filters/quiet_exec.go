@@ -0,0 +1,119 @@
+package filters
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/types/trace"
+)
+
+// DefaultQuietExecPaths are the binaries of common system daemons, which execute routinely (e.g. systemd units and
+// cron jobs) and whose exec events of the host are dropped by default. A trailing '*' matches the paths prefixed by
+// the rest
+var DefaultQuietExecPaths = []string{
+	"/lib/systemd/systemd*",
+	"/usr/lib/systemd/systemd*",
+	"/usr/sbin/cron",
+	"/usr/sbin/crond",
+	"/usr/sbin/anacron",
+	"/usr/sbin/atd",
+	"/usr/sbin/rsyslogd",
+	"/usr/sbin/irqbalance",
+	"/usr/sbin/chronyd",
+	"/usr/bin/dbus-daemon",
+}
+
+// quietExecEvents are the exec events dropped of the quiet binaries, by the argument of their executed path
+var quietExecEvents = map[events.ID]string{
+	events.SchedProcessExec: "pathname",
+	events.Execve:           "pathname",
+	events.Execveat:         "pathname",
+}
+
+// QuietExecFilter drops the exec events of quiet binaries (e.g. of system daemons), to cut their noise out of the
+// baseline. Binaries are matched by their executed path, and the binaries of Allowed are traced even if they are
+// quiet. Paths with a trailing '*' match the paths prefixed by the rest. The Defaults are quiet in the host mount
+// namespace only, since any container (or process of a mount namespace of its own) can place its own binaries at
+// their paths, and the Paths added are quiet in all mount namespaces
+type QuietExecFilter struct {
+	Defaults []string
+	Paths    []string
+	Allowed  []string
+	Enabled  bool
+}
+
+// NewQuietExecFilter returns an enabled filter of the DefaultQuietExecPaths
+func NewQuietExecFilter() *QuietExecFilter {
+	defaults := make([]string, len(DefaultQuietExecPaths))
+	copy(defaults, DefaultQuietExecPaths)
+	return &QuietExecFilter{Defaults: defaults, Enabled: true}
+}
+
+// Parse adds the given paths to the quiet binaries (=), or traces them even if they are quiet (!=)
+func (filter *QuietExecFilter) Parse(operatorAndValues string) error {
+	var operator string
+	switch {
+	case strings.HasPrefix(operatorAndValues, "!="):
+		operator = "!="
+	case strings.HasPrefix(operatorAndValues, "="):
+		operator = "="
+	default:
+		return fmt.Errorf("invalid filter operator: %s", operatorAndValues)
+	}
+	for _, path := range strings.Split(strings.TrimPrefix(operatorAndValues, operator), ",") {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("invalid filter value: %s", path)
+		}
+		if operator == "=" {
+			filter.Paths = append(filter.Paths, path)
+		} else {
+			filter.Allowed = append(filter.Allowed, path)
+		}
+	}
+	filter.Enabled = true
+	return nil
+}
+
+// RemoveDefaults removes the Defaults from the quiet binaries, keeping the ones added by Parse
+func (filter *QuietExecFilter) RemoveDefaults() {
+	filter.Defaults = nil
+}
+
+// Filter checks if an event isn't the exec of a quiet binary, where hostMntns tells if the event is of the host
+// mount namespace
+func (filter *QuietExecFilter) Filter(eventID events.ID, hostMntns bool, args []trace.Argument) bool {
+	if filter == nil || !filter.Enabled {
+		return true
+	}
+	argName, ok := quietExecEvents[eventID]
+	if !ok {
+		return true
+	}
+	for _, arg := range args {
+		if arg.Name != argName {
+			continue
+		}
+		path, ok := arg.Value.(string)
+		if !ok {
+			return true
+		}
+		quiet := matchQuietPath(filter.Paths, path) || hostMntns && matchQuietPath(filter.Defaults, path)
+		return !quiet || matchQuietPath(filter.Allowed, path)
+	}
+	return true
+}
+
+// matchQuietPath checks if a path matches one of the given paths, or is prefixed by one of them ending with '*'
+func matchQuietPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(path, strings.TrimSuffix(p, "*")) {
+				return true
+			}
+		} else if p == path {
+			return true
+		}
+	}
+	return false
+}
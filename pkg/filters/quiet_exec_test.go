@@ -0,0 +1,58 @@
+package filters_test
+
+import (
+	"testing"
+
+	"github.com/aquasecurity/tracee/pkg/events"
+	"github.com/aquasecurity/tracee/pkg/filters"
+	"github.com/aquasecurity/tracee/types/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func execArgs(path string) []trace.Argument {
+	return []trace.Argument{{ArgMeta: trace.ArgMeta{Name: "pathname", Type: "const char*"}, Value: path}}
+}
+
+func TestQuietExecFilter(t *testing.T) {
+	filter := filters.NewQuietExecFilter()
+
+	// executed daemons of the host are dropped, by their exact path or by a prefix
+	assert.False(t, filter.Filter(events.SchedProcessExec, true, execArgs("/usr/sbin/cron")))
+	assert.False(t, filter.Filter(events.Execve, true, execArgs("/usr/sbin/cron")))
+	assert.False(t, filter.Filter(events.SchedProcessExec, true, execArgs("/usr/lib/systemd/systemd-journald")))
+	assert.True(t, filter.Filter(events.SchedProcessExec, true, execArgs("/usr/bin/curl")))
+	assert.True(t, filter.Filter(events.SchedProcessExec, true, execArgs("/usr/sbin/cron.d")))
+	// the binaries at the paths of daemons in containers are traced, as any container can place its own there
+	assert.True(t, filter.Filter(events.SchedProcessExec, false, execArgs("/usr/sbin/cron")))
+	assert.True(t, filter.Filter(events.SchedProcessExec, false, execArgs("/usr/lib/systemd/systemd-journald")))
+	// events other than execs of daemons are kept
+	assert.True(t, filter.Filter(events.Openat, true, execArgs("/usr/sbin/cron")))
+	assert.True(t, filter.Filter(events.SchedProcessExec, true, nil))
+
+	// the binaries added are quiet in containers as well
+	require.NoError(t, filter.Parse("=/opt/agent/bin/agentd,/opt/monitor/*"))
+	require.NoError(t, filter.Parse("!=/usr/sbin/cron,/usr/lib/systemd/systemd-logind"))
+	assert.False(t, filter.Filter(events.SchedProcessExec, true, execArgs("/opt/agent/bin/agentd")))
+	assert.False(t, filter.Filter(events.SchedProcessExec, false, execArgs("/opt/agent/bin/agentd")))
+	assert.False(t, filter.Filter(events.SchedProcessExec, false, execArgs("/opt/monitor/probe")))
+	assert.True(t, filter.Filter(events.SchedProcessExec, true, execArgs("/usr/sbin/cron")))
+	assert.True(t, filter.Filter(events.SchedProcessExec, true, execArgs("/usr/lib/systemd/systemd-logind")))
+	assert.False(t, filter.Filter(events.SchedProcessExec, true, execArgs("/usr/lib/systemd/systemd-journald")))
+
+	// the binaries added are kept when the defaults are removed
+	filter.RemoveDefaults()
+	assert.True(t, filter.Filter(events.SchedProcessExec, true, execArgs("/usr/lib/systemd/systemd-journald")))
+	assert.False(t, filter.Filter(events.SchedProcessExec, true, execArgs("/opt/agent/bin/agentd")))
+
+	assert.EqualError(t, filter.Parse("=bin/agentd"), "invalid filter value: bin/agentd")
+	assert.EqualError(t, filter.Parse(">/usr/bin"), "invalid filter operator: >/usr/bin")
+	// the default list isn't changed by filters
+	assert.Contains(t, filters.DefaultQuietExecPaths, "/usr/sbin/cron")
+
+	t.Run("disabled", func(t *testing.T) {
+		var nilFilter *filters.QuietExecFilter
+		assert.True(t, nilFilter.Filter(events.SchedProcessExec, true, execArgs("/usr/sbin/cron")))
+		assert.True(t, (&filters.QuietExecFilter{Paths: []string{"/usr/sbin/cron"}}).Filter(events.SchedProcessExec, true, execArgs("/usr/sbin/cron")))
+	})
+}
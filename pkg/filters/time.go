@@ -0,0 +1,66 @@
+package filters
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeFilter selects events by their wall clock time, within a window whose start and end are optional
+type TimeFilter struct {
+	Start   int64 // earliest time of traced events, in nanoseconds since the epoch (0 if not set)
+	End     int64 // latest time of traced events, in nanoseconds since the epoch (0 if not set)
+	Enabled bool
+}
+
+// Parse parses a bound of the window, given as one of the operators '>', '>=', '<' and '<=' followed by a time
+// in RFC3339 format (e.g. >=2022-08-26T10:00:00Z)
+func (filter *TimeFilter) Parse(operatorAndValues string) error {
+	var operator string
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if strings.HasPrefix(operatorAndValues, op) {
+			operator = op
+			break
+		}
+	}
+	if operator == "" {
+		return fmt.Errorf("invalid operator given to time filter: %s. accepted operators - '>', '>=', '<' and '<='", operatorAndValues)
+	}
+
+	value := strings.TrimPrefix(operatorAndValues, operator)
+	bound, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return fmt.Errorf("invalid time given to time filter: %s. expected a time in RFC3339 format (e.g. 2022-08-26T10:00:00Z)", value)
+	}
+	ts := bound.UnixNano()
+
+	switch operator {
+	case ">":
+		filter.Start = ts + 1
+	case ">=":
+		filter.Start = ts
+	case "<":
+		filter.End = ts - 1
+	case "<=":
+		filter.End = ts
+	}
+	if filter.Start != 0 && filter.End != 0 && filter.Start > filter.End {
+		return fmt.Errorf("invalid time filter: the window starts after it ends")
+	}
+	filter.Enabled = true
+	return nil
+}
+
+// Filter checks if an event of the given time, in nanoseconds since the epoch, is in the window
+func (filter *TimeFilter) Filter(ts int64) bool {
+	if filter == nil || !filter.Enabled {
+		return true
+	}
+	if filter.Start != 0 && ts < filter.Start {
+		return false
+	}
+	if filter.End != 0 && ts > filter.End {
+		return false
+	}
+	return true
+}
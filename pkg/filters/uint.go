@@ -109,6 +109,31 @@ func (filter *UIntFilter) InitBPF(bpfModule *bpf.Module, filterMapName string) e
 	return nil
 }
 
+// Filter checks if a value passes the filter, matching the filtering of the value by the bpf code.
+// A nil or disabled filter passes any value
+func (filter *UIntFilter) Filter(val uint64) bool {
+	if filter == nil || !filter.Enabled {
+		return true
+	}
+	for _, v := range filter.Equal {
+		if v == val {
+			return true
+		}
+	}
+	for _, v := range filter.NotEqual {
+		if v == val {
+			return false
+		}
+	}
+	if filter.Less != LessNotSetUint && val >= filter.Less {
+		return false
+	}
+	if filter.Greater != GreaterNotSetUint && val <= filter.Greater {
+		return false
+	}
+	return filter.FilterOut()
+}
+
 func (filter *UIntFilter) FilterOut() bool {
 	if len(filter.Equal) > 0 && len(filter.NotEqual) == 0 && filter.Greater == GreaterNotSetUint && filter.Less == LessNotSetUint {
 		return false
@@ -262,6 +262,22 @@ func (l *Logger) Error(msg string, keysAndValues ...interface{}) {
 	errorw(1, l, msg, keysAndValues...)
 }
 
+// Log logs a message of the given level, for loggers wrapping this one on behalf of their callers: callerSkip is the
+// number of frames between the logging code and Log (e.g. 1 for a wrapper), so aggregated logs are counted by the
+// origin of the message. Levels above ErrorLevel are logged as errors, so Log never panics nor exits
+func (l *Logger) Log(callerSkip int, level Level, msg string, keysAndValues ...interface{}) {
+	switch level {
+	case DebugLevel:
+		debugw(callerSkip+1, l, msg, keysAndValues...)
+	case InfoLevel:
+		infow(callerSkip+1, l, msg, keysAndValues...)
+	case WarnLevel:
+		warnw(callerSkip+1, l, msg, keysAndValues...)
+	default:
+		errorw(callerSkip+1, l, msg, keysAndValues...)
+	}
+}
+
 // Fatal
 func fatalw(skip int, l *Logger, msg string, keysAndValues ...interface{}) {
 	if isAggregateSetAndIsLogNotNew(skip+1, l) {
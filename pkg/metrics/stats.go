@@ -14,6 +14,23 @@ type Stats struct {
 	LostEvCount    counter.Counter
 	LostWrCount    counter.Counter
 	LostNtCount    counter.Counter
+	CapFileCount   counter.Counter
+	CapBytesCount  counter.Counter
+	// CapThrottledCount counts the captures skipped as their mount namespace exceeded its capture rate
+	CapThrottledCount counter.Counter
+	// CapEvictedCount counts the captured files forgotten as their mount namespace had too many
+	CapEvictedCount counter.Counter
+	// CapDroppedCount counts the captures dropped as the queue of the capture workers was full
+	CapDroppedCount counter.Counter
+	// CapHookSkippedCount counts the captured files not hooked as too many capture hooks were running
+	CapHookSkippedCount counter.Counter
+	// HashCacheHits and HashCacheMisses count the lookups of executed files in the cache of their hashes
+	HashCacheHits   counter.Counter
+	HashCacheMisses counter.Counter
+	// KafkaDeliveredCount and KafkaFailedCount count the events published to kafka, and the events which couldn't be
+	// (after their retries, or as they were dropped)
+	KafkaDeliveredCount counter.Counter
+	KafkaFailedCount    counter.Counter
 }
 
 // Register Stats to prometheus metrics exporter
@@ -78,6 +95,106 @@ func (stats *Stats) RegisterPrometheus() error {
 		return err
 	}
 
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "captured_files_total",
+		Help:      "files captured by tracee-ebpf",
+	}, func() float64 { return float64(stats.CapFileCount.Read()) }))
+
+	if err != nil {
+		return err
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "captured_bytes_total",
+		Help:      "bytes written to the capture directory by tracee-ebpf",
+	}, func() float64 { return float64(stats.CapBytesCount.Read()) }))
+
+	if err != nil {
+		return err
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "captures_throttled_total",
+		Help:      "captures skipped by tracee-ebpf as their mount namespace exceeded its capture rate",
+	}, func() float64 { return float64(stats.CapThrottledCount.Read()) }))
+
+	if err != nil {
+		return err
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "captures_evicted_total",
+		Help:      "captured files forgotten by tracee-ebpf as their mount namespace had too many",
+	}, func() float64 { return float64(stats.CapEvictedCount.Read()) }))
+
+	if err != nil {
+		return err
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "capture_hooks_skipped_total",
+		Help:      "captured files not hooked by tracee-ebpf as too many capture hooks were running",
+	}, func() float64 { return float64(stats.CapHookSkippedCount.Read()) }))
+
+	if err != nil {
+		return err
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "capture_dropped_total",
+		Help:      "captures dropped by tracee-ebpf as the queue of the capture workers was full",
+	}, func() float64 { return float64(stats.CapDroppedCount.Read()) }))
+
+	if err != nil {
+		return err
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "hash_cache_hits_total",
+		Help:      "executed files whose hash was found in the cache of file hashes",
+	}, func() float64 { return float64(stats.HashCacheHits.Read()) }))
+
+	if err != nil {
+		return err
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "hash_cache_misses_total",
+		Help:      "executed files whose hash wasn't found in the cache of file hashes",
+	}, func() float64 { return float64(stats.HashCacheMisses.Read()) }))
+
+	if err != nil {
+		return err
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "kafka_delivered_total",
+		Help:      "events published to kafka by tracee-ebpf",
+	}, func() float64 { return float64(stats.KafkaDeliveredCount.Read()) }))
+
+	if err != nil {
+		return err
+	}
+
+	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "tracee_ebpf",
+		Name:      "kafka_failed_total",
+		Help:      "events which tracee-ebpf failed publishing to kafka",
+	}, func() float64 { return float64(stats.KafkaFailedCount.Read()) }))
+
+	if err != nil {
+		return err
+	}
+
 	err = prometheus.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
 		Namespace: "tracee_ebpf",
 		Name:      "errors_total",
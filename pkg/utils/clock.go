@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Clock is the source of the current time for time dependent logic, so it can be replaced with a fake in tests
+type Clock interface {
+	// Now returns the current wall clock time
+	Now() time.Time
+	// MonotonicNano returns the current time of the monotonic clock in nanoseconds, which is the clock used for the
+	// timestamps of the bpf code
+	MonotonicNano() int64
+	// NewTicker returns a ticker delivering the time of the clock every interval
+	NewTicker(interval time.Duration) Ticker
+}
+
+// Ticker delivers ticks at intervals, like time.Ticker
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// RealClock is the system clock
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (RealClock) MonotonicNano() int64 {
+	var ts unix.Timespec
+	unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts)
+	return ts.Nano()
+}
+
+func (RealClock) NewTicker(interval time.Duration) Ticker {
+	return realTicker{time.NewTicker(interval)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// FakeClock is a manually advanced clock. Its wall and monotonic times always advance together
+type FakeClock struct {
+	mtx       sync.Mutex
+	now       time.Time
+	monotonic int64
+	tickers   []*fakeTicker
+}
+
+// NewFakeClock creates a fake clock set to the given wall and monotonic times
+func NewFakeClock(now time.Time, monotonicNano int64) *FakeClock {
+	return &FakeClock{now: now, monotonic: monotonicNano}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) MonotonicNano() int64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.monotonic
+}
+
+func (c *FakeClock) NewTicker(interval time.Duration) Ticker {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	ticker := &fakeTicker{clock: c, c: make(chan time.Time, 1), interval: interval, next: c.now.Add(interval)}
+	c.tickers = append(c.tickers, ticker)
+	return ticker
+}
+
+// Advance moves the clock forward by the given duration, firing the tickers whose interval elapsed. As with
+// time.Ticker, ticks are dropped while a ticker holds an unread one
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.now = c.now.Add(d)
+	c.monotonic += int64(d)
+	for _, ticker := range c.tickers {
+		for !ticker.next.After(c.now) {
+			select {
+			case ticker.c <- ticker.next:
+			default:
+			}
+			ticker.next = ticker.next.Add(ticker.interval)
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock    *FakeClock
+	c        chan time.Time
+	interval time.Duration
+	next     time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mtx.Lock()
+	defer t.clock.mtx.Unlock()
+	for i, ticker := range t.clock.tickers {
+		if ticker == t {
+			t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+			return
+		}
+	}
+}
@@ -90,28 +90,25 @@ func CopyRegularFileByPath(src, dst string) error {
 	return nil
 }
 
-// CopyRegularFileByRelativePath copies a file from src to dst, where destination is relative to a given directory
-func CopyRegularFileByRelativePath(srcName string, dstDir *os.File, dstName string) error {
+// CopyRegularFileByRelativePath copies a file from src to dst, where destination is relative to a given directory.
+// It returns the number of bytes copied
+func CopyRegularFileByRelativePath(srcName string, dstDir *os.File, dstName string) (int64, error) {
 	sourceFileStat, err := os.Stat(srcName)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if !sourceFileStat.Mode().IsRegular() {
-		return fmt.Errorf("%s is not a regular file", srcName)
+		return 0, fmt.Errorf("%s is not a regular file", srcName)
 	}
 	source, err := os.Open(srcName)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer source.Close()
 	destination, err := CreateAt(dstDir, dstName)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer destination.Close()
-	_, err = io.Copy(destination, source)
-	if err != nil {
-		return err
-	}
-	return nil
+	return io.Copy(destination, source)
 }